@@ -0,0 +1,57 @@
+package core
+
+import (
+	. "github.com/balzaczyy/golucene/analysis/util"
+	. "github.com/balzaczyy/golucene/core/analysis"
+	"github.com/balzaczyy/golucene/core/util"
+	"io"
+	"unicode"
+)
+
+// core/WhitespaceTokenizer.java
+
+/*
+A WhitespaceTokenizer is a tokenizer that divides text at whitespace.
+Adjacent sequences of non-Whitespace characters form tokens.
+*/
+type WhitespaceTokenizer struct {
+	*CharTokenizer
+}
+
+/* Construct a new WhitespaceTokenizer. */
+func NewWhitespaceTokenizer(matchVersion util.Version, in io.RuneReader) *WhitespaceTokenizer {
+	ans := new(WhitespaceTokenizer)
+	ans.CharTokenizer = NewCharTokenizer(ans, in)
+	return ans
+}
+
+/* Collects only characters which do not satisfy unicode.IsSpace(). */
+func (t *WhitespaceTokenizer) IsTokenChar(c rune) bool {
+	return !unicode.IsSpace(c)
+}
+
+// core/WhitespaceAnalyzer.java
+
+/*
+An Analyzer that uses WhitespaceTokenizer.
+
+You may specify the Version compatibility when creating
+WhitespaceAnalyzer:
+
+  - GoLucene supports 4.5+ only.
+*/
+type WhitespaceAnalyzer struct {
+	*AnalyzerImpl
+}
+
+/* Creates a new WhitespaceAnalyzer. */
+func NewWhitespaceAnalyzer() *WhitespaceAnalyzer {
+	ans := &WhitespaceAnalyzer{NewAnalyzer()}
+	ans.Spi = ans
+	return ans
+}
+
+func (a *WhitespaceAnalyzer) CreateComponents(fieldName string, reader io.RuneReader) *TokenStreamComponents {
+	src := NewWhitespaceTokenizer(a.Version(), reader)
+	return NewTokenStreamComponents(src, src)
+}
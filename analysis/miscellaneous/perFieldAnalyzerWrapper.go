@@ -0,0 +1,87 @@
+package miscellaneous
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/analysis"
+	"io"
+)
+
+// miscellaneous/PerFieldAnalyzerWrapper.java
+
+/*
+This analyzer is used to facilitate scenarios where different fields
+require different analysis techniques. Use the map argument in
+NewPerFieldAnalyzerWrapper() to add non-default analyzers for fields.
+
+Example usage:
+
+	analyzerPerField := map[string]Analyzer{
+		"firstname": core.NewWhitespaceAnalyzer(),
+		"lastname":  core.NewWhitespaceAnalyzer(),
+	}
+	aWrapper := NewPerFieldAnalyzerWrapper(standard.NewStandardAnalyzer(), analyzerPerField)
+
+In this example, StandardAnalyzer will be used for all fields except
+"firstname" and "lastname", for which WhitespaceAnalyzer will be used.
+
+A PerFieldAnalyzerWrapper can be used like any other analyzer, for
+both indexing and query parsing. Each call is delegated to the
+analyzer of the field, which keeps reusing its own components.
+*/
+type PerFieldAnalyzerWrapper struct {
+	defaultAnalyzer Analyzer
+	fieldAnalyzers  map[string]Analyzer
+}
+
+/*
+Constructs with default analyzer and a map of analyzers to use for
+specific fields. The map is copied, so later changes to it are not
+visible to the wrapper.
+*/
+func NewPerFieldAnalyzerWrapper(defaultAnalyzer Analyzer,
+	fieldAnalyzers map[string]Analyzer) *PerFieldAnalyzerWrapper {
+
+	assert2(defaultAnalyzer != nil, "default analyzer must not be nil")
+	ans := &PerFieldAnalyzerWrapper{
+		defaultAnalyzer: defaultAnalyzer,
+		fieldAnalyzers:  make(map[string]Analyzer),
+	}
+	for field, analyzer := range fieldAnalyzers {
+		ans.fieldAnalyzers[field] = analyzer
+	}
+	return ans
+}
+
+/* Returns the analyzer used for the given field. */
+func (w *PerFieldAnalyzerWrapper) WrappedAnalyzer(fieldName string) Analyzer {
+	if analyzer, ok := w.fieldAnalyzers[fieldName]; ok && analyzer != nil {
+		return analyzer
+	}
+	return w.defaultAnalyzer
+}
+
+func (w *PerFieldAnalyzerWrapper) TokenStreamForReader(fieldName string, reader io.RuneReader) (TokenStream, error) {
+	return w.WrappedAnalyzer(fieldName).TokenStreamForReader(fieldName, reader)
+}
+
+func (w *PerFieldAnalyzerWrapper) TokenStreamForString(fieldName, text string) (TokenStream, error) {
+	return w.WrappedAnalyzer(fieldName).TokenStreamForString(fieldName, text)
+}
+
+func (w *PerFieldAnalyzerWrapper) PositionIncrementGap(fieldName string) int {
+	return w.WrappedAnalyzer(fieldName).PositionIncrementGap(fieldName)
+}
+
+func (w *PerFieldAnalyzerWrapper) OffsetGap(fieldName string) int {
+	return w.WrappedAnalyzer(fieldName).OffsetGap(fieldName)
+}
+
+func (w *PerFieldAnalyzerWrapper) String() string {
+	return fmt.Sprintf("PerFieldAnalyzerWrapper(%v, default=%v)", w.fieldAnalyzers, w.defaultAnalyzer)
+}
+
+func assert2(ok bool, msg string, args ...interface{}) {
+	if !ok {
+		panic(fmt.Sprintf(msg, args...))
+	}
+}
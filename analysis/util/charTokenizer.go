@@ -0,0 +1,112 @@
+package util
+
+import (
+	. "github.com/balzaczyy/golucene/core/analysis"
+	. "github.com/balzaczyy/golucene/core/analysis/tokenattributes"
+	"io"
+)
+
+// util/CharTokenizer.java
+
+const CHAR_TOKENIZER_MAX_WORD_LEN = 255
+
+type CharTokenizerSPI interface {
+	// Returns true iff a codepoint should be included in a token. This
+	// tokenizer generates as tokens adjacent sequences of codepoints
+	// which satisfy this predicate. Codepoints for which this is false
+	// are used to define token boundaries and are not included in
+	// tokens.
+	IsTokenChar(c rune) bool
+	// Called on each token character to normalize it before it is
+	// added to the token. The default implementation does nothing.
+	// Subclasses may use this to, e.g., lowercase tokens.
+	Normalize(c rune) rune
+}
+
+/*
+An abstract base class for simple, character-oriented tokenizers. You
+have to implement IsTokenChar() to define which codepoints belong to
+a token; adjacent runs of such codepoints are emitted as tokens, at
+most CHAR_TOKENIZER_MAX_WORD_LEN long.
+
+Offsets are counted in runes.
+*/
+type CharTokenizer struct {
+	*Tokenizer
+	spi         CharTokenizerSPI
+	offset      int
+	finalOffset int
+	buffer      []rune
+	termAtt     CharTermAttribute
+	offsetAtt   OffsetAttribute
+}
+
+/* Creates a new CharTokenizer instance. */
+func NewCharTokenizer(spi CharTokenizerSPI, input io.RuneReader) *CharTokenizer {
+	ans := &CharTokenizer{
+		Tokenizer: NewTokenizer(input),
+		spi:       spi,
+		buffer:    make([]rune, 0, CHAR_TOKENIZER_MAX_WORD_LEN),
+	}
+	ans.termAtt = ans.Attributes().Add("CharTermAttribute").(CharTermAttribute)
+	ans.offsetAtt = ans.Attributes().Add("OffsetAttribute").(OffsetAttribute)
+	return ans
+}
+
+func (t *CharTokenizer) Normalize(c rune) rune {
+	return c
+}
+
+func (t *CharTokenizer) IncrementToken() (bool, error) {
+	t.Attributes().Clear()
+	t.buffer = t.buffer[:0]
+	start := -1
+	for {
+		c, _, err := t.Input.ReadRune()
+		if err == io.EOF {
+			if len(t.buffer) > 0 {
+				break
+			}
+			t.finalOffset = t.CorrectOffset(t.offset)
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		t.offset++
+
+		if t.spi.IsTokenChar(c) { // if it's a token char
+			if len(t.buffer) == 0 { // start of token
+				start = t.offset - 1
+			}
+			t.buffer = append(t.buffer, t.spi.Normalize(c)) // buffer it, normalized
+			if len(t.buffer) >= CHAR_TOKENIZER_MAX_WORD_LEN {
+				break // buffer overflow! make sure to check for >= surrogate pair could break == test
+			}
+		} else if len(t.buffer) > 0 { // at non-Letter w/ chars
+			break // return 'em
+		}
+	}
+
+	t.termAtt.CopyBuffer(t.buffer)
+	t.finalOffset = t.CorrectOffset(start + len(t.buffer))
+	t.offsetAtt.SetOffset(t.CorrectOffset(start), t.finalOffset)
+	return true, nil
+}
+
+func (t *CharTokenizer) End() error {
+	if err := t.Tokenizer.End(); err != nil {
+		return err
+	}
+	// set final offset
+	t.offsetAtt.SetOffset(t.finalOffset, t.finalOffset)
+	return nil
+}
+
+func (t *CharTokenizer) Reset() error {
+	if err := t.Tokenizer.Reset(); err != nil {
+		return err
+	}
+	t.offset = 0
+	t.finalOffset = 0
+	return nil
+}
@@ -505,12 +505,105 @@ func (e *SegmentTermsEnum) printSeekState() {
 }
 
 func (e *SegmentTermsEnum) Next() (buf []byte, err error) {
-	panic("not implemented yet")
+	if e.in == nil {
+		// Fresh TermsEnum; seek to first term:
+		var arc *fst.Arc
+		if e.fr.index != nil {
+			arc = e.fr.index.FirstArc(e.arcs[0])
+			// Empty string prefix must have an output in the index!
+			assert(arc.IsFinal())
+		}
+		if e.currentFrame, err = e.pushFrame(arc, e.fr.rootCode, 0); err != nil {
+			return nil, err
+		}
+		if err = e.currentFrame.loadBlock(); err != nil {
+			return nil, err
+		}
+	}
+
+	e.targetBeforeCurrentLength = e.currentFrame.ord
+
+	assert(!e.eof)
+	// fmt.Printf("BTTR.next seg=%v term=%v termExists?=%v field=%v termBlockOrd=%v validIndexPrefix=%v\n",
+	// 	e.fr.parent.segment, brToString(e.Term()), e.termExists, e.fr.fieldInfo.Name,
+	// 	e.currentFrame.state.TermBlockOrd, e.validIndexPrefix)
+	// e.printSeekState()
+
+	if e.currentFrame == e.staticFrame {
+		// If seek was previously called and the term was cached, or
+		// seek(TermState) was called, usually caller is just going to
+		// pull a D/&PEnum or get docFreq, etc. But, if they then call
+		// next(), this method catches up all internal state so next()
+		// works properly:
+		ok, err := e.SeekExact(e.Term())
+		if err != nil {
+			return nil, err
+		}
+		assert(ok)
+	}
+
+	// Pop finished blocks
+	for e.currentFrame.nextEnt == e.currentFrame.entCount {
+		if !e.currentFrame.isLastInFloor {
+			if err = e.currentFrame.loadNextFloorBlock(); err != nil {
+				return nil, err
+			}
+		} else {
+			// fmt.Printf("  pop frame\n")
+			if e.currentFrame.ord == 0 {
+				// fmt.Println("  return nil")
+				e.eof = true
+				e.term.Clear()
+				e.validIndexPrefix = 0
+				e.currentFrame.rewind()
+				e.termExists = false
+				return nil, nil
+			}
+			lastFP := e.currentFrame.fpOrig
+			e.currentFrame = e.stack[e.currentFrame.ord-1]
+
+			if e.currentFrame.nextEnt == -1 || e.currentFrame.lastSubFP != lastFP {
+				// We popped into a frame that's not loaded yet or not
+				// scan'd to the right entry
+				e.currentFrame.scanToFloorFrame(e.Term())
+				if err = e.currentFrame.loadBlock(); err != nil {
+					return nil, err
+				}
+				e.currentFrame.scanToSubBlock(lastFP)
+			}
+
+			// Note that the seek state (last seek) has been invalidated
+			// beyond this depth
+			if e.currentFrame.prefix < e.validIndexPrefix {
+				e.validIndexPrefix = e.currentFrame.prefix
+			}
+			// fmt.Printf("  reset validIndexPrefix=%v\n", e.validIndexPrefix)
+		}
+	}
+
+	for {
+		if !e.currentFrame.next() {
+			// fmt.Printf("  return term=%v currentFrame.ord=%v\n", brToString(e.Term()), e.currentFrame.ord)
+			return e.Term(), nil
+		}
+		// Push to new block:
+		// fmt.Println("  push frame")
+		if e.currentFrame, err = e.pushFrameAt(nil, e.currentFrame.lastSubFP, e.term.Length()); err != nil {
+			return nil, err
+		}
+		// This is a "next" frame -- even if it's floor'd we must
+		// pretend it isn't so we don't try to scan to the right floor
+		// frame:
+		e.currentFrame.isFloor = false
+		if err = e.currentFrame.loadBlock(); err != nil {
+			return nil, err
+		}
+	}
 }
 
 func (e *SegmentTermsEnum) Term() []byte {
 	assert(!e.eof)
-	return e.term.Bytes()
+	return e.term.Bytes()[:e.term.Length()]
 }
 
 func assert(ok bool) {
@@ -552,8 +645,17 @@ func (e *SegmentTermsEnum) DocsByFlags(skipDocs util.Bits, reuse DocsEnum, flags
 	return e.fr.parent.postingsReader.Docs(e.fr.fieldInfo, e.currentFrame.state, skipDocs, reuse, flags)
 }
 
-func (e *SegmentTermsEnum) DocsAndPositionsByFlags(skipDocs util.Bits, reuse DocsAndPositionsEnum, flags int) DocsAndPositionsEnum {
-	panic("not implemented yet")
+func (e *SegmentTermsEnum) DocsAndPositionsByFlags(skipDocs util.Bits, reuse DocsAndPositionsEnum, flags int) (DocsAndPositionsEnum, error) {
+	if e.fr.fieldInfo.IndexOptions() < INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS {
+		// Positions were not indexed:
+		return nil, nil
+	}
+
+	assert(!e.eof)
+	if err := e.currentFrame.decodeMetaData(); err != nil {
+		return nil, err
+	}
+	return e.fr.parent.postingsReader.DocsAndPositions(e.fr.fieldInfo, e.currentFrame.state, skipDocs, reuse, flags)
 }
 
 func (e *SegmentTermsEnum) SeekExactFromLast(target []byte, otherState TermState) error {
@@ -220,6 +220,14 @@ func (f *segmentTermsEnumFrame) loadBlock() (err error) {
 	return nil
 }
 
+func (f *segmentTermsEnumFrame) loadNextFloorBlock() error {
+	// fmt.Printf("    loadNextFloorBlock fp=%v fpEnd=%v\n", f.fp, f.fpEnd)
+	assert2(f.arc == nil || f.isFloor, "arc=%v isFloor=%v", f.arc, f.isFloor)
+	f.fp = f.fpEnd
+	f.nextEnt = -1
+	return f.loadBlock()
+}
+
 func (f *segmentTermsEnumFrame) rewind() {
 	// Force reload:
 	f.fp = f.fpOrig
@@ -243,11 +251,77 @@ func (f *segmentTermsEnumFrame) next() bool {
 
 // Decodes next entry; returns true if it's a sub-block
 func (f *segmentTermsEnumFrame) nextLeaf() bool {
-	panic("not implemented yet")
+	// fmt.Printf("  frame.next ord=%v nextEnt=%v entCount=%v\n", f.ord, f.nextEnt, f.entCount)
+	assert2(f.nextEnt != -1 && f.nextEnt < f.entCount,
+		"nextEnt=%v entCount=%v fp=%v", f.nextEnt, f.entCount, f.fp)
+	f.nextEnt++
+	f.suffix, _ = asInt(f.suffixesReader.ReadVInt()) // no error
+	f.startBytePos = f.suffixesReader.Position()
+	f.readSuffix()
+	// A normal term
+	f.ste.termExists = true
+	return false
 }
 
 func (f *segmentTermsEnumFrame) nextNonLeaf() bool {
-	panic("not implemented yet")
+	// fmt.Printf("  frame.next ord=%v nextEnt=%v entCount=%v\n", f.ord, f.nextEnt, f.entCount)
+	assert2(f.nextEnt != -1 && f.nextEnt < f.entCount,
+		"nextEnt=%v entCount=%v fp=%v", f.nextEnt, f.entCount, f.fp)
+	f.nextEnt++
+	code, _ := f.suffixesReader.ReadVInt() // no error
+	f.suffix = int(uint32(code) >> 1)
+	f.startBytePos = f.suffixesReader.Position()
+	f.readSuffix()
+	if (code & 1) == 0 {
+		// A normal term
+		f.ste.termExists = true
+		f.subCode = 0
+		f.state.TermBlockOrd++
+		return false
+	}
+	// A sub-block; make sub-FP absolute:
+	f.ste.termExists = false
+	f.subCode, _ = f.suffixesReader.ReadVLong() // no error
+	f.lastSubFP = f.fp - f.subCode
+	// fmt.Printf("    lastSubFP=%v\n", f.lastSubFP)
+	return true
+}
+
+// Reads the suffix of the current entry into the term, after the
+// prefix of this block.
+func (f *segmentTermsEnumFrame) readSuffix() {
+	termLength := f.prefix + f.suffix
+	f.ste.term.Grow(termLength)
+	f.ste.term.SetLength(termLength)
+	f.suffixesReader.ReadBytes(f.ste.term.Bytes()[f.prefix:termLength]) // no error
+}
+
+// Skips entries until the sub-block at subFP, which must follow the
+// current entry in this (non-leaf) block.
+func (f *segmentTermsEnumFrame) scanToSubBlock(subFP int64) {
+	assert(!f.isLeafBlock)
+	// fmt.Printf("  scanToSubBlock fp=%v subFP=%v entCount=%v lastSubFP=%v\n", f.fp, subFP, f.entCount, f.lastSubFP)
+	if f.lastSubFP == subFP {
+		// fmt.Println("    already positioned")
+		return
+	}
+	assert2(subFP < f.fp, "fp=%v subFP=%v", f.fp, subFP)
+	targetSubCode := f.fp - subFP
+	for {
+		assert(f.nextEnt < f.entCount)
+		f.nextEnt++
+		code, _ := f.suffixesReader.ReadVInt() // no error
+		f.suffixesReader.SkipBytes(int64(uint32(code) >> 1))
+		if (code & 1) != 0 {
+			subCode, _ := f.suffixesReader.ReadVLong() // no error
+			if targetSubCode == subCode {
+				f.lastSubFP = subFP
+				return
+			}
+		} else {
+			f.state.TermBlockOrd++
+		}
+	}
 }
 
 // TODO: make this array'd so we can do bin search?
@@ -261,10 +335,10 @@ func (f *segmentTermsEnumFrame) scanToFloorFrame(target []byte) {
 	}
 
 	targetLabel := int(target[f.prefix])
-	fmt.Printf("    scanToFloorFrame fpOrig=%v targetLabel=%x vs nextFloorLabel=%x numFollowFloorBlocks=%v\n",
-		f.fpOrig, targetLabel, f.nextFloorLabel, f.numFollowFloorBlocks)
+	// fmt.Printf("    scanToFloorFrame fpOrig=%v targetLabel=%x vs nextFloorLabel=%x numFollowFloorBlocks=%v\n",
+	// 	f.fpOrig, targetLabel, f.nextFloorLabel, f.numFollowFloorBlocks)
 	if targetLabel < f.nextFloorLabel {
-		fmt.Println("      already on correct block")
+		// fmt.Println("      already on correct block")
 		return
 	}
 
@@ -283,16 +357,21 @@ func (f *segmentTermsEnumFrame) scanToFloorFrame(target []byte) {
 
 		if f.isLastInFloor {
 			f.nextFloorLabel = 256
-			fmt.Printf("        stop!  last block nextFloorLabel=%x\n", f.nextFloorLabel)
+			// fmt.Printf("        stop!  last block nextFloorLabel=%x\n", f.nextFloorLabel)
+			break
+		}
+		b, _ := f.floorDataReader.ReadByte() // no error
+		f.nextFloorLabel = int(b)
+		// fmt.Printf("        nextFloorLabel=%x\n", f.nextFloorLabel)
+		if targetLabel < f.nextFloorLabel {
+			// fmt.Println("        stop!")
 			break
-		} else {
-			panic("niy")
 		}
 	}
 
 	if newFP != f.fp {
 		// Force re-load of the block:
-		fmt.Printf("      force switch to fp=%v oldFP=%v\n", newFP, f.fp)
+		// fmt.Printf("      force switch to fp=%v oldFP=%v\n", newFP, f.fp)
 		f.nextEnt = -1
 		f.fp = newFP
 	} else {
@@ -104,6 +104,8 @@ func NewBlockTreeTermsReader(dir store.Directory,
 		if err != nil {
 			return nil, err
 		}
+		// the terms index is loaded into memory in one pass
+		indexIn.SetReadAdvice(store.READ_ADVICE_SEQUENTIAL)
 
 		indexVersion, err := fp.readIndexHeader(indexIn)
 		if err != nil {
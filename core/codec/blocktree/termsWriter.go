@@ -609,7 +609,7 @@ func (w *TermsWriter) writeBlock(
 			if err = w.suffixWriter.WriteVInt(int32(suffix)); err != nil {
 				return nil, err
 			}
-			if err = w.suffixWriter.WriteBytes(term.term[prefixLength : prefixLength+suffix]); err != nil {
+			if err = w.suffixWriter.WriteBytesRange(term.term, prefixLength, suffix); err != nil {
 				return nil, err
 			}
 			assert(floorLeadLabel == -1 || int(term.term[prefixLength]) >= floorLeadLabel)
@@ -656,7 +656,7 @@ func (w *TermsWriter) writeBlock(
 				if err = w.suffixWriter.WriteVInt(int32(suffix << 1)); err != nil {
 					return nil, err
 				}
-				if err = w.suffixWriter.WriteBytes(term.term[prefixLength : prefixLength+suffix]); err != nil {
+				if err = w.suffixWriter.WriteBytesRange(term.term, prefixLength, suffix); err != nil {
 					return nil, err
 				}
 				assert(floorLeadLabel == -1 || int(term.term[prefixLength]) >= floorLeadLabel)
@@ -700,7 +700,7 @@ func (w *TermsWriter) writeBlock(
 				if err = w.suffixWriter.WriteVInt(int32((suffix << 1) | 1)); err != nil {
 					return nil, err
 				}
-				if err = w.suffixWriter.WriteBytes(block.prefix[prefixLength : prefixLength+suffix]); err != nil {
+				if err = w.suffixWriter.WriteBytesRange(block.prefix, prefixLength, suffix); err != nil {
 					return nil, err
 				}
 
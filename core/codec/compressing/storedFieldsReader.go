@@ -10,6 +10,7 @@ import (
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
 	"github.com/balzaczyy/golucene/core/util/packed"
+	"math"
 )
 
 // codec/compressing/CompressingStoredFieldsReader.java
@@ -111,6 +112,13 @@ func newCompressingStoredFieldsReader(d store.Directory,
 	if r.fieldsStream, err = d.OpenInput(fieldsStreamFN, ctx); err != nil {
 		return nil, err
 	}
+	// Documents are fetched one at a time when searching, but streamed
+	// front to back when merging.
+	if advice := ctx.ReadAdvice(); advice == store.READ_ADVICE_SEQUENTIAL {
+		r.fieldsStream.SetReadAdvice(advice)
+	} else {
+		r.fieldsStream.SetReadAdvice(store.READ_ADVICE_RANDOM)
+	}
 	if r.version >= VERSION_CHECKSUM {
 		if maxPointer+codec.FOOTER_LENGTH != r.fieldsStream.Length() {
 			return nil, errors.New(fmt.Sprintf(
@@ -182,7 +190,15 @@ func (r *CompressingStoredFieldsReader) readField(in util.DataInput,
 	visitor StoredFieldVisitor, info *model.FieldInfo, bits int) (err error) {
 	switch bits & TYPE_MASK {
 	case BYTE_ARR:
-		panic("not implemented yet")
+		var length int
+		if length, err = int32AsInt(in.ReadVInt()); err != nil {
+			return err
+		}
+		data := make([]byte, length)
+		if err = in.ReadBytes(data); err != nil {
+			return err
+		}
+		return visitor.BinaryField(info, data)
 	case STRING:
 		var length int
 		if length, err = int32AsInt(in.ReadVInt()); err != nil {
@@ -192,15 +208,49 @@ func (r *CompressingStoredFieldsReader) readField(in util.DataInput,
 		if err = in.ReadBytes(data); err != nil {
 			return err
 		}
-		visitor.StringField(info, string(data))
+		return visitor.StringField(info, string(data))
 	case NUMERIC_INT:
-		panic("not implemented yet")
+		var n int32
+		if n, err = in.ReadInt(); err != nil {
+			return err
+		}
+		return visitor.IntField(info, int(n))
 	case NUMERIC_FLOAT:
-		panic("not implemented yet")
+		var n int32
+		if n, err = in.ReadInt(); err != nil {
+			return err
+		}
+		return visitor.FloatField(info, math.Float32frombits(uint32(n)))
 	case NUMERIC_LONG:
-		panic("not implemented yet")
+		var n int64
+		if n, err = in.ReadLong(); err != nil {
+			return err
+		}
+		return visitor.LongField(info, n)
 	case NUMERIC_DOUBLE:
-		panic("not implemented yet")
+		var n int64
+		if n, err = in.ReadLong(); err != nil {
+			return err
+		}
+		return visitor.DoubleField(info, math.Float64frombits(uint64(n)))
+	default:
+		panic(fmt.Sprintf("Unknown type flag: %x", bits))
+	}
+}
+
+// Moves in past a field's value without decoding it.
+func skipField(in *store.ByteArrayDataInput, bits int) error {
+	switch bits & TYPE_MASK {
+	case BYTE_ARR, STRING:
+		length, err := in.ReadVInt()
+		if err != nil {
+			return err
+		}
+		in.SkipBytes(int64(length))
+	case NUMERIC_INT, NUMERIC_FLOAT:
+		in.SkipBytes(4)
+	case NUMERIC_LONG, NUMERIC_DOUBLE:
+		in.SkipBytes(8)
 	default:
 		panic(fmt.Sprintf("Unknown type flag: %x", bits))
 	}
@@ -253,7 +303,19 @@ func (r *CompressingStoredFieldsReader) VisitDocument(docID int, visitor StoredF
 			return errors.New(fmt.Sprintf("bitsPerStoredFields=%v (resource=%v)",
 				bitsPerStoredFields, r.fieldsStream))
 		} else {
-			panic("not implemented yet")
+			it := packed.ReaderIteratorNoHeader(
+				r.fieldsStream, packed.PackedFormat(packed.PACKED), r.packedIntsVersion,
+				chunkDocs, bitsPerStoredFields, 1)
+			// consume all values to position the stream after them
+			for i := 0; i < chunkDocs; i++ {
+				n, err := it.Next()
+				if err != nil {
+					return err
+				}
+				if i == docID-docBase {
+					numStoredFields = int(n)
+				}
+			}
 		}
 
 		bitsPerLength, err := int32AsInt(r.fieldsStream.ReadVInt())
@@ -307,7 +369,7 @@ func (r *CompressingStoredFieldsReader) VisitDocument(docID int, visitor StoredF
 		return nil
 	}
 
-	var documentInput util.DataInput
+	var documentInput *store.ByteArrayDataInput
 	if r.version >= VERSION_BIG_CHUNKS && totalLength >= 2*r.chunkSize {
 		panic("not implemented yet")
 	} else {
@@ -342,9 +404,13 @@ func (r *CompressingStoredFieldsReader) VisitDocument(docID int, visitor StoredF
 		}
 		switch status {
 		case STORED_FIELD_VISITOR_STATUS_YES:
-			r.readField(documentInput, visitor, fieldInfo, bits)
+			if err = r.readField(documentInput, visitor, fieldInfo, bits); err != nil {
+				return err
+			}
 		case STORED_FIELD_VISITOR_STATUS_NO:
-			panic("not implemented yet")
+			if err = skipField(documentInput, bits); err != nil {
+				return err
+			}
 		case STORED_FIELD_VISITOR_STATUS_STOP:
 			return nil
 		}
@@ -50,7 +50,7 @@ type CompressingStoredFieldsWriter struct {
 	compressor      Compressor
 	chunkSize       int
 
-	bufferedDocs    *GrowableByteArrayDataOutput
+	bufferedDocs    *util.GrowableByteArrayDataOutput
 	numStoredFields []int // number of stored fields
 	endOffsets      []int // ned offsets in bufferedDocs
 	docBase         int   // doc ID at the beginning of the chunk
@@ -72,7 +72,7 @@ func NewCompressingStoredFieldsWriter(dir store.Directory, si *model.SegmentInfo
 		compressor:      compressionMode.NewCompressor(),
 		chunkSize:       chunkSize,
 		docBase:         0,
-		bufferedDocs:    newGrowableByteArrayDataOutput(chunkSize),
+		bufferedDocs:    util.NewGrowableByteArrayDataOutput(chunkSize),
 		numStoredFields: make([]int, 16),
 		endOffsets:      make([]int, 16),
 		numBufferedDocs: 0,
@@ -168,7 +168,7 @@ func (w *CompressingStoredFieldsWriter) FinishDocument() error {
 	}
 	w.numStoredFields[w.numBufferedDocs] = w.numStoredFieldsInDoc
 	w.numStoredFieldsInDoc = 0
-	w.endOffsets[w.numBufferedDocs] = w.bufferedDocs.length
+	w.endOffsets[w.numBufferedDocs] = w.bufferedDocs.Position()
 	w.numBufferedDocs++
 	if w.triggerFlush() {
 		return w.flush()
@@ -238,7 +238,7 @@ func (w *CompressingStoredFieldsWriter) writeHeader(docBase,
 }
 
 func (w *CompressingStoredFieldsWriter) triggerFlush() bool {
-	return w.bufferedDocs.length >= w.chunkSize || // chunks of at least chunkSize bytes
+	return w.bufferedDocs.Position() >= w.chunkSize || // chunks of at least chunkSize bytes
 		w.numBufferedDocs >= MAX_DOCUMENTS_PER_CHUNK
 }
 
@@ -260,20 +260,20 @@ func (w *CompressingStoredFieldsWriter) flush() error {
 	}
 
 	// compress stored fields to fieldsStream
-	if w.bufferedDocs.length >= 2*w.chunkSize {
+	if w.bufferedDocs.Position() >= 2*w.chunkSize {
 		// big chunk, slice it
-		for compressed := 0; compressed < w.bufferedDocs.length; compressed += w.chunkSize {
-			size := w.bufferedDocs.length - compressed
+		for compressed := 0; compressed < w.bufferedDocs.Position(); compressed += w.chunkSize {
+			size := w.bufferedDocs.Position() - compressed
 			if w.chunkSize < size {
 				size = w.chunkSize
 			}
-			err = w.compressor(w.bufferedDocs.bytes[compressed:compressed+size], w.fieldsStream)
+			err = w.compressor(w.bufferedDocs.Bytes()[compressed:compressed+size], w.fieldsStream)
 			if err != nil {
 				return err
 			}
 		}
 	} else {
-		err = w.compressor(w.bufferedDocs.bytes[:w.bufferedDocs.length], w.fieldsStream)
+		err = w.compressor(w.bufferedDocs.Bytes()[:w.bufferedDocs.Position()], w.fieldsStream)
 		if err != nil {
 			return err
 		}
@@ -282,7 +282,7 @@ func (w *CompressingStoredFieldsWriter) flush() error {
 	// reset
 	w.docBase += w.numBufferedDocs
 	w.numBufferedDocs = 0
-	w.bufferedDocs.length = 0
+	w.bufferedDocs.Reset()
 	return nil
 }
 
@@ -313,10 +313,7 @@ func (w *CompressingStoredFieldsWriter) WriteField(info *model.FieldInfo, field
 			bits = BYTE_ARR
 		} else {
 			bits = STRING
-			str = field.StringValue()
-			assert2(str != "",
-				"field %v is stored but does not have binaryValue, stringValue nor numericValue",
-				field.Name())
+			str = field.StringValue() // may be empty
 		}
 	}
 
@@ -332,7 +329,7 @@ func (w *CompressingStoredFieldsWriter) WriteField(info *model.FieldInfo, field
 		if err == nil {
 			err = w.bufferedDocs.WriteBytes(bytes)
 		}
-	case str != "":
+	case bits == STRING:
 		err = w.bufferedDocs.WriteString(str)
 	case bits == NUMERIC_INT:
 		err = w.bufferedDocs.WriteInt(number.(int32))
@@ -368,7 +365,7 @@ func (w *CompressingStoredFieldsWriter) Finish(fis model.FieldInfos, numDocs int
 			return err
 		}
 	} else {
-		assert(w.bufferedDocs.length == 0)
+		assert(w.bufferedDocs.Position() == 0)
 	}
 	assert2(w.docBase == numDocs,
 		"Wrote %v docs, finish called with numDocs=%v", w.docBase, numDocs)
@@ -378,47 +375,6 @@ func (w *CompressingStoredFieldsWriter) Finish(fis model.FieldInfos, numDocs int
 	if err = codec.WriteFooter(w.fieldsStream); err != nil {
 		return err
 	}
-	assert(w.bufferedDocs.length == 0)
-	return nil
-}
-
-// util/GrowableByteArrayDataOutput.java
-
-/* A DataOutput that can be used to build a []byte */
-type GrowableByteArrayDataOutput struct {
-	*util.DataOutputImpl
-	bytes  []byte
-	length int
-}
-
-func newGrowableByteArrayDataOutput(cp int) *GrowableByteArrayDataOutput {
-	ans := &GrowableByteArrayDataOutput{bytes: make([]byte, 0, util.Oversize(cp, 1))}
-	ans.DataOutputImpl = util.NewDataOutput(ans)
-	return ans
-}
-
-func (out *GrowableByteArrayDataOutput) WriteByte(b byte) error {
-	assert(out.length <= len(out.bytes))
-	if out.length < len(out.bytes) {
-		out.bytes[out.length] = b
-	} else {
-		out.bytes = append(out.bytes, b)
-	}
-	out.length++
-	return nil
-}
-
-func (out *GrowableByteArrayDataOutput) WriteBytes(b []byte) error {
-	assert(out.length <= len(out.bytes))
-	remaining := len(out.bytes) - out.length
-	if remaining > len(b) {
-		copy(out.bytes[out.length:], b)
-	} else if remaining == 0 {
-		out.bytes = append(out.bytes, b...)
-	} else {
-		copy(out.bytes[out.length:], b[:remaining])
-		out.bytes = append(out.bytes, b[remaining:]...)
-	}
-	out.length += len(b)
+	assert(w.bufferedDocs.Position() == 0)
 	return nil
 }
@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+// index/CorruptIndexException.java
+
+/*
+Sentinel matched by every CorruptIndexError, so callers can test with
+errors.Is(err, ErrCorruptIndex) regardless of where it was wrapped.
+*/
+var ErrCorruptIndex = errors.New("corrupt index")
+
+/*
+Returned when Lucene detects an inconsistency in the index, e.g. a
+checksum or codec header/footer mismatch.
+*/
+type CorruptIndexError struct {
+	msg      string
+	resource string // description of the corrupt input
+}
+
+func NewCorruptIndexError(msg string, resource interface{}) *CorruptIndexError {
+	return &CorruptIndexError{msg, fmt.Sprintf("%v", resource)}
+}
+
+func (err *CorruptIndexError) Error() string {
+	return fmt.Sprintf("%v (resource=%v)", err.msg, err.resource)
+}
+
+/* Returns the original message, without the resource description. */
+func (err *CorruptIndexError) OriginalMessage() string {
+	return err.msg
+}
+
+/* Returns a description of the file that was corrupt. */
+func (err *CorruptIndexError) ResourceDescription() string {
+	return err.resource
+}
+
+func (err *CorruptIndexError) Is(target error) bool {
+	return target == ErrCorruptIndex
+}
+
+// index/IndexFormatTooOldException.java
+
+/*
+Returned when Lucene detects an index that is too old for this
+library version.
+*/
+type IndexFormatTooOldError struct {
+	*CorruptIndexError
+	Version, MinVersion, MaxVersion int32
+}
+
+func NewIndexFormatTooOldError(in interface{}, version, minVersion, maxVersion int32) *IndexFormatTooOldError {
+	return &IndexFormatTooOldError{
+		NewCorruptIndexError(fmt.Sprintf(
+			"Format version is not supported: %v (needs to be between %v and %v). This version of Lucene only supports indexes created with release 3.0 and later.",
+			version, minVersion, maxVersion), in),
+		version, minVersion, maxVersion,
+	}
+}
+
+// index/IndexFormatTooNewException.java
+
+/*
+Returned when Lucene detects an index that is newer than this library
+version.
+*/
+type IndexFormatTooNewError struct {
+	*CorruptIndexError
+	Version, MinVersion, MaxVersion int32
+}
+
+func NewIndexFormatTooNewError(in interface{}, version, minVersion, maxVersion int32) *IndexFormatTooNewError {
+	return &IndexFormatTooNewError{
+		NewCorruptIndexError(fmt.Sprintf(
+			"Format version is not supported: %v (needs to be between %v and %v)",
+			version, minVersion, maxVersion), in),
+		version, minVersion, maxVersion,
+	}
+}
@@ -12,6 +12,12 @@ import (
 const (
 	CODEC = "BitVector"
 
+	/* Version before version tracking was added: */
+	BV_VERSION_PRE = -1
+
+	/* First version: */
+	BV_VERSION_START = 0
+
 	/* Change DGaps to encode gaps between cleared bits, not set: */
 	BV_VERSION_DGAPS_CLEARED = 1
 
@@ -34,6 +40,65 @@ func NewBitVector(n int) *BitVector {
 	}
 }
 
+/*
+Reads a BitVector from a file written by Write(), e.g. the live docs
+of a segment.
+*/
+func ReadBitVector(d store.Directory, name string, ctx store.IOContext) (bv *BitVector, err error) {
+	var input store.ChecksumIndexInput
+	if input, err = d.OpenChecksumInput(name, ctx); err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = mergeError(err, input.Close())
+	}()
+
+	var format, version int32
+	if format, err = input.ReadInt(); err != nil {
+		return nil, err
+	}
+	bv = new(BitVector)
+	if format == -2 {
+		// New format, with full header & version:
+		if version, err = codec.CheckHeader(input, CODEC, BV_VERSION_START, BV_VERSION_CURRENT); err != nil {
+			return nil, err
+		}
+		if format, err = input.ReadInt(); err != nil {
+			return nil, err
+		}
+	} else {
+		version = BV_VERSION_PRE
+	}
+	if format == -1 {
+		if version >= BV_VERSION_DGAPS_CLEARED {
+			err = bv.readClearedDgaps(input)
+		} else {
+			err = bv.readSetDgaps(input)
+		}
+	} else {
+		bv.size = int(format)
+		err = bv.readBits(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if version < BV_VERSION_DGAPS_CLEARED {
+		bv.InvertAll()
+	}
+
+	if version >= BV_VERSION_CHECKSUM {
+		_, err = codec.CheckFooter(input)
+	} else {
+		err = codec.CheckEOF(input)
+	}
+	if err != nil {
+		return nil, err
+	}
+	bv.assertCount()
+	return bv, nil
+}
+
 func numBytes(size int) int {
 	bytesLength := int(uint(size) >> 3)
 	if (size & 7) != 0 {
@@ -138,6 +203,16 @@ func (bv *BitVector) InvertAll() {
 		for idx, v := range bv.bits {
 			bv.bits[idx] = byte(^v)
 		}
+		bv.clearUnusedBits()
+	}
+}
+
+/* Set all bits in the last byte beyond size to 0, so Count() only counts bits in range. */
+func (bv *BitVector) clearUnusedBits() {
+	if len(bv.bits) > 0 {
+		if lastNBits := uint(bv.size) & 7; lastNBits != 0 {
+			bv.bits[len(bv.bits)-1] &= byte(1<<lastNBits) - 1
+		}
 	}
 }
 
@@ -183,12 +258,117 @@ func (bv *BitVector) writeClearedDgaps(output store.IndexOutput) error {
 	return nil
 }
 
+/* Read as a bit set */
+func (bv *BitVector) readBits(input store.IndexInput) error {
+	count, err := input.ReadInt()
+	if err != nil {
+		return err
+	}
+	bv.count = int(count)
+	bv.bits = make([]byte, numBytes(bv.size))
+	return input.ReadBytes(bv.bits)
+}
+
+/* Read as a d-gaps list of set bits */
+func (bv *BitVector) readSetDgaps(input store.IndexInput) error {
+	if err := bv.readSizeAndCount(input); err != nil {
+		return err
+	}
+	bv.bits = make([]byte, numBytes(bv.size))
+	last, n := 0, bv.count
+	for n > 0 {
+		gap, err := input.ReadVInt()
+		if err != nil {
+			return err
+		}
+		last += int(gap)
+		if bv.bits[last], err = input.ReadByte(); err != nil {
+			return err
+		}
+		n -= util.BitCount(bv.bits[last])
+		assert(n >= 0)
+	}
+	return nil
+}
+
+/* Read as a d-gaps list of cleared bits */
+func (bv *BitVector) readClearedDgaps(input store.IndexInput) error {
+	if err := bv.readSizeAndCount(input); err != nil {
+		return err
+	}
+	bv.bits = make([]byte, numBytes(bv.size))
+	for i := range bv.bits {
+		bv.bits[i] = 0xff
+	}
+	bv.clearUnusedBits()
+	last, numCleared := 0, bv.size-bv.count
+	for numCleared > 0 {
+		gap, err := input.ReadVInt()
+		if err != nil {
+			return err
+		}
+		last += int(gap)
+		if bv.bits[last], err = input.ReadByte(); err != nil {
+			return err
+		}
+		numCleared -= 8 - util.BitCount(bv.bits[last])
+		assert(numCleared >= 0 ||
+			last == len(bv.bits)-1 && numCleared == -(8-(bv.size&7)))
+	}
+	return nil
+}
+
+func (bv *BitVector) readSizeAndCount(input store.IndexInput) error {
+	size, err := input.ReadInt()
+	if err != nil {
+		return err
+	}
+	count, err := input.ReadInt()
+	if err != nil {
+		return err
+	}
+	bv.size, bv.count = int(size), int(count)
+	return nil
+}
+
 /*
 Indicates if the bit vector is sparse and should be saved as a d-gaps
 list, or dense, and should be saved as a bit set.
 */
 func (bv *BitVector) isSparse() bool {
-	panic("not implemented yet")
+	clearedCount := bv.size - bv.Count()
+	if clearedCount == 0 {
+		return true
+	}
+
+	avgGapLength := len(bv.bits) / clearedCount
+
+	// expected number of bytes for vInt encoding of each gap
+	var expectedDGapBytes int
+	switch {
+	case avgGapLength <= (1 << 7):
+		expectedDGapBytes = 1
+	case avgGapLength <= (1 << 14):
+		expectedDGapBytes = 2
+	case avgGapLength <= (1 << 21):
+		expectedDGapBytes = 3
+	case avgGapLength <= (1 << 28):
+		expectedDGapBytes = 4
+	default:
+		expectedDGapBytes = 5
+	}
+
+	// +1 because we write the byte itself that contains the set bit
+	bytesPerSetBit := expectedDGapBytes + 1
+
+	// note: adding 32 because we start with ((int) -1) to indicate
+	// d-gaps format.
+	expectedBits := 32 + 8*int64(bytesPerSetBit)*int64(clearedCount)
+
+	// note: factor is for read/write of byte-arrays being faster than
+	// vints.
+	const factor = 10
+	return factor*expectedBits < int64(bv.size)
 }
 
 func (bv *BitVector) assertCount() {
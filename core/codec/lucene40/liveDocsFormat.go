@@ -1,6 +1,8 @@
 package lucene40
 
 import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
 	. "github.com/balzaczyy/golucene/core/codec/spi"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
@@ -61,6 +63,26 @@ func (format *Lucene40LiveDocsFormat) NewLiveDocs(size int) util.MutableBits {
 	return ans
 }
 
+func (format *Lucene40LiveDocsFormat) ReadLiveDocs(dir store.Directory,
+	info *SegmentCommitInfo, ctx store.IOContext) (util.Bits, error) {
+
+	filename := util.FileNameFromGeneration(info.Info.Name, DELETES_EXTENSION, info.DelGen())
+	liveDocs, err := ReadBitVector(dir, filename, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if liveDocs.Length() != info.Info.DocCount() {
+		return nil, codec.NewCorruptIndexError(fmt.Sprintf(
+			"liveDocs.length()=%v info.docCount=%v", liveDocs.Length(), info.Info.DocCount()), filename)
+	}
+	if liveDocs.Count() != info.Info.DocCount()-info.DelCount() {
+		return nil, codec.NewCorruptIndexError(fmt.Sprintf(
+			"liveDocs.count()=%v info.docCount=%v info.getDelCount()=%v",
+			liveDocs.Count(), info.Info.DocCount(), info.DelCount()), filename)
+	}
+	return liveDocs, nil
+}
+
 func (format *Lucene40LiveDocsFormat) WriteLiveDocs(bits util.MutableBits,
 	dir store.Directory, info *SegmentCommitInfo, newDelCount int,
 	ctx store.IOContext) error {
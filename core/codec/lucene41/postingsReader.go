@@ -1,6 +1,7 @@
 package lucene41
 
 import (
+	"errors"
 	"fmt"
 	"github.com/balzaczyy/golucene/core/codec"
 	. "github.com/balzaczyy/golucene/core/codec/spi"
@@ -410,8 +411,19 @@ func (de *blockDocsEnum) refillDocs() (err error) {
 	assert(left > 0)
 
 	if left >= LUCENE41_BLOCK_SIZE {
-		fmt.Println("    fill doc block from fp=", de.docIn.FilePointer())
-		panic("not implemented yet")
+		if err = de.forUtil.readBlock(de.docIn, de.encoded, de.docDeltaBuffer); err != nil {
+			return err
+		}
+		if de.indexHasFreq {
+			if de.needsFreq {
+				err = de.forUtil.readBlock(de.docIn, de.encoded, de.freqBuffer)
+			} else {
+				err = de.forUtil.skipBlock(de.docIn) // skip over freqs
+			}
+			if err != nil {
+				return err
+			}
+		}
 	} else if de.docFreq == 1 {
 		de.docDeltaBuffer[0] = de.singletonDocID
 		de.freqBuffer[0] = int(de.totalTermFreq)
@@ -508,3 +520,365 @@ func (de *blockDocsEnum) Advance(target int) (int, error) {
 		return de.NextDoc()
 	}
 }
+
+func (r *Lucene41PostingsReader) DocsAndPositions(fieldInfo *FieldInfo,
+	termState *BlockTermState, liveDocs util.Bits,
+	reuse DocsAndPositionsEnum, flags int) (DocsAndPositionsEnum, error) {
+
+	if fieldInfo.HasPayloads() {
+		// payload lengths are interleaved with the position deltas, so
+		// positions can't be decoded either, whether payloads are asked
+		// for or not
+		return nil, errors.New(fmt.Sprintf(
+			"field '%v' has payloads, which are not supported yet", fieldInfo.Name))
+	}
+
+	var docsAndPositionsEnum *blockDocsAndPositionsEnum
+	if v, ok := reuse.(*blockDocsAndPositionsEnum); ok && v.canReuse(r.docIn, fieldInfo) {
+		docsAndPositionsEnum = v
+	} else {
+		docsAndPositionsEnum = newBlockDocsAndPositionsEnum(r, fieldInfo)
+	}
+	return docsAndPositionsEnum.reset(liveDocs, termState.Self.(*intBlockTermState), flags)
+}
+
+/*
+Iterates docs, freqs, positions and, if indexed and asked for,
+offsets. This combines Lucene's BlockDocsAndPositionsEnum and
+EverythingEnum; fields with payloads are not supported yet.
+*/
+type blockDocsAndPositionsEnum struct {
+	*Lucene41PostingsReader // embedded struct
+
+	encoded []byte
+
+	docDeltaBuffer         []int
+	freqBuffer             []int
+	posDeltaBuffer         []int
+	offsetStartDeltaBuffer []int
+	offsetLengthBuffer     []int
+
+	docBufferUpto int
+	posBufferUpto int
+
+	startDocIn store.IndexInput
+
+	docIn store.IndexInput
+	posIn store.IndexInput
+	payIn store.IndexInput
+
+	indexHasOffsets  bool
+	indexHasPayloads bool
+
+	docFreq       int
+	totalTermFreq int64
+	docUpto       int
+	doc           int
+	accum         int
+	freq          int
+	position      int
+
+	lastStartOffset int
+	startOffset     int
+	endOffset       int
+
+	// how many positions "behind" we are; nextPosition must skip
+	// these to "catch up":
+	posPendingCount int
+
+	// lazy seek to these file pointers, when nextPosition() is first
+	// called after a new doc:
+	posPendingFP int64
+	payPendingFP int64
+
+	// Where this term's postings start in the .doc file:
+	docTermStartFP int64
+
+	// Where this term's postings start in the .pos file:
+	posTermStartFP int64
+
+	// Where this term's payloads/offsets start in the .pay file:
+	payTermStartFP int64
+
+	// File pointer where the last (vInt encoded) pos delta block is.
+	// We need this to know whether to bulk decode vs vInt decode the
+	// block:
+	lastPosBlockFP int64
+
+	liveDocs util.Bits
+
+	needsOffsets   bool
+	singletonDocID int
+}
+
+func newBlockDocsAndPositionsEnum(owner *Lucene41PostingsReader,
+	fieldInfo *FieldInfo) *blockDocsAndPositionsEnum {
+
+	ans := &blockDocsAndPositionsEnum{
+		Lucene41PostingsReader: owner,
+		encoded:                make([]byte, MAX_ENCODED_SIZE),
+		docDeltaBuffer:         make([]int, MAX_DATA_SIZE),
+		freqBuffer:             make([]int, MAX_DATA_SIZE),
+		posDeltaBuffer:         make([]int, MAX_DATA_SIZE),
+		startDocIn:             owner.docIn,
+		posIn:                  owner.posIn.Clone(),
+		indexHasOffsets:        fieldInfo.IndexOptions() >= INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS,
+		indexHasPayloads:       fieldInfo.HasPayloads(),
+		startOffset:            -1,
+		endOffset:              -1,
+	}
+	if ans.indexHasOffsets || ans.indexHasPayloads {
+		ans.payIn = owner.payIn.Clone()
+	}
+	if ans.indexHasOffsets {
+		ans.offsetStartDeltaBuffer = make([]int, MAX_DATA_SIZE)
+		ans.offsetLengthBuffer = make([]int, MAX_DATA_SIZE)
+	}
+	return ans
+}
+
+func (e *blockDocsAndPositionsEnum) canReuse(docIn store.IndexInput, fieldInfo *FieldInfo) bool {
+	return docIn == e.startDocIn &&
+		e.indexHasOffsets == (fieldInfo.IndexOptions() >= INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS) &&
+		e.indexHasPayloads == fieldInfo.HasPayloads()
+}
+
+func (e *blockDocsAndPositionsEnum) reset(liveDocs util.Bits,
+	termState *intBlockTermState, flags int) (DocsAndPositionsEnum, error) {
+
+	e.liveDocs = liveDocs
+	e.docFreq = termState.DocFreq
+	e.docTermStartFP = termState.docStartFP
+	e.posTermStartFP = termState.posStartFP
+	e.payTermStartFP = termState.payStartFP
+	e.totalTermFreq = termState.TotalTermFreq
+	e.singletonDocID = termState.singletonDocID
+	if e.docFreq > 1 {
+		if e.docIn == nil {
+			// lazy init
+			e.docIn = e.startDocIn.Clone()
+		}
+		if err := e.docIn.Seek(e.docTermStartFP); err != nil {
+			return nil, err
+		}
+	}
+	e.posPendingFP = e.posTermStartFP
+	e.payPendingFP = e.payTermStartFP
+	e.posPendingCount = 0
+	if e.totalTermFreq < LUCENE41_BLOCK_SIZE {
+		e.lastPosBlockFP = e.posTermStartFP
+	} else if e.totalTermFreq == LUCENE41_BLOCK_SIZE {
+		e.lastPosBlockFP = -1
+	} else {
+		e.lastPosBlockFP = e.posTermStartFP + termState.lastPosBlockOffset
+	}
+
+	e.needsOffsets = (flags & DOCS_POSITIONS_ENUM_FLAG_OFF_SETS) != 0
+
+	e.doc = -1
+	e.accum = 0
+	e.docUpto = 0
+	e.docBufferUpto = LUCENE41_BLOCK_SIZE
+	return e, nil
+}
+
+func (e *blockDocsAndPositionsEnum) Freq() (int, error) {
+	return e.freq, nil
+}
+
+func (e *blockDocsAndPositionsEnum) DocId() int {
+	return e.doc
+}
+
+func (e *blockDocsAndPositionsEnum) refillDocs() (err error) {
+	left := e.docFreq - e.docUpto
+	assert(left > 0)
+
+	if left >= LUCENE41_BLOCK_SIZE {
+		if err = e.forUtil.readBlock(e.docIn, e.encoded, e.docDeltaBuffer); err == nil {
+			err = e.forUtil.readBlock(e.docIn, e.encoded, e.freqBuffer)
+		}
+	} else if e.docFreq == 1 {
+		e.docDeltaBuffer[0] = e.singletonDocID
+		e.freqBuffer[0] = int(e.totalTermFreq)
+	} else {
+		err = readVIntBlock(e.docIn, e.docDeltaBuffer, e.freqBuffer, left, true)
+	}
+	e.docBufferUpto = 0
+	return
+}
+
+func (e *blockDocsAndPositionsEnum) refillPositions() error {
+	if e.posIn.FilePointer() == e.lastPosBlockFP {
+		count := int(e.totalTermFreq % LUCENE41_BLOCK_SIZE)
+		offsetLength := 0
+		for i := 0; i < count; i++ {
+			code, err := asInt(e.posIn.ReadVInt())
+			if err != nil {
+				return err
+			}
+			e.posDeltaBuffer[i] = code
+			if e.indexHasOffsets {
+				deltaCode, err := asInt(e.posIn.ReadVInt())
+				if err != nil {
+					return err
+				}
+				if (deltaCode & 1) != 0 {
+					if offsetLength, err = asInt(e.posIn.ReadVInt()); err != nil {
+						return err
+					}
+				}
+				e.offsetStartDeltaBuffer[i] = int(uint(deltaCode) >> 1)
+				e.offsetLengthBuffer[i] = offsetLength
+			}
+		}
+		return nil
+	}
+
+	if err := e.forUtil.readBlock(e.posIn, e.encoded, e.posDeltaBuffer); err != nil {
+		return err
+	}
+	if e.indexHasOffsets {
+		if e.needsOffsets {
+			if err := e.forUtil.readBlock(e.payIn, e.encoded, e.offsetStartDeltaBuffer); err != nil {
+				return err
+			}
+			return e.forUtil.readBlock(e.payIn, e.encoded, e.offsetLengthBuffer)
+		}
+		if err := e.forUtil.skipBlock(e.payIn); err != nil {
+			return err
+		}
+		return e.forUtil.skipBlock(e.payIn)
+	}
+	return nil
+}
+
+func (e *blockDocsAndPositionsEnum) NextDoc() (int, error) {
+	for {
+		if e.docUpto == e.docFreq {
+			e.doc = NO_MORE_DOCS
+			return e.doc, nil
+		}
+		if e.docBufferUpto == LUCENE41_BLOCK_SIZE {
+			if err := e.refillDocs(); err != nil {
+				return 0, err
+			}
+		}
+
+		e.accum += e.docDeltaBuffer[e.docBufferUpto]
+		e.freq = e.freqBuffer[e.docBufferUpto]
+		e.posPendingCount += e.freq
+		e.docBufferUpto++
+		e.docUpto++
+
+		if e.liveDocs == nil || e.liveDocs.At(e.accum) {
+			e.doc = e.accum
+			e.position = 0
+			e.lastStartOffset = 0
+			return e.doc, nil
+		}
+	}
+}
+
+// TODO: use skip data once Lucene41SkipReader is ported; until then
+// this scans linearly.
+func (e *blockDocsAndPositionsEnum) Advance(target int) (int, error) {
+	for {
+		doc, err := e.NextDoc()
+		if err != nil || doc >= target {
+			return doc, err
+		}
+	}
+}
+
+func (e *blockDocsAndPositionsEnum) skipPositions() error {
+	// Skip positions now:
+	toSkip := e.posPendingCount - e.freq
+
+	leftInBlock := LUCENE41_BLOCK_SIZE - e.posBufferUpto
+	if toSkip < leftInBlock {
+		e.posBufferUpto += toSkip
+	} else {
+		toSkip -= leftInBlock
+		for toSkip >= LUCENE41_BLOCK_SIZE {
+			assert(e.posIn.FilePointer() != e.lastPosBlockFP)
+			if err := e.forUtil.skipBlock(e.posIn); err != nil {
+				return err
+			}
+			if e.indexHasOffsets {
+				// must skip offset blocks
+				if err := e.forUtil.skipBlock(e.payIn); err != nil {
+					return err
+				}
+				if err := e.forUtil.skipBlock(e.payIn); err != nil {
+					return err
+				}
+			}
+			toSkip -= LUCENE41_BLOCK_SIZE
+		}
+		if err := e.refillPositions(); err != nil {
+			return err
+		}
+		e.posBufferUpto = toSkip
+	}
+
+	e.position = 0
+	e.lastStartOffset = 0
+	return nil
+}
+
+func (e *blockDocsAndPositionsEnum) NextPosition() (int, error) {
+	if e.posPendingFP != -1 {
+		if err := e.posIn.Seek(e.posPendingFP); err != nil {
+			return 0, err
+		}
+		e.posPendingFP = -1
+
+		if e.payIn != nil {
+			if err := e.payIn.Seek(e.payPendingFP); err != nil {
+				return 0, err
+			}
+			e.payPendingFP = -1
+		}
+
+		// Force buffer refill:
+		e.posBufferUpto = LUCENE41_BLOCK_SIZE
+	}
+
+	if e.posPendingCount > e.freq {
+		if err := e.skipPositions(); err != nil {
+			return 0, err
+		}
+		e.posPendingCount = e.freq
+	}
+
+	if e.posBufferUpto == LUCENE41_BLOCK_SIZE {
+		if err := e.refillPositions(); err != nil {
+			return 0, err
+		}
+		e.posBufferUpto = 0
+	}
+	e.position += e.posDeltaBuffer[e.posBufferUpto]
+
+	if e.indexHasOffsets && e.needsOffsets {
+		e.startOffset = e.lastStartOffset + e.offsetStartDeltaBuffer[e.posBufferUpto]
+		e.endOffset = e.startOffset + e.offsetLengthBuffer[e.posBufferUpto]
+		e.lastStartOffset = e.startOffset
+	}
+
+	e.posBufferUpto++
+	e.posPendingCount--
+	return e.position, nil
+}
+
+func (e *blockDocsAndPositionsEnum) StartOffset() int {
+	return e.startOffset
+}
+
+func (e *blockDocsAndPositionsEnum) EndOffset() int {
+	return e.endOffset
+}
+
+func (e *blockDocsAndPositionsEnum) Payload() ([]byte, error) {
+	return nil, nil
+}
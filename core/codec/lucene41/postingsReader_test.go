@@ -0,0 +1,19 @@
+package lucene41
+
+import (
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"strings"
+	"testing"
+)
+
+func TestDocsAndPositionsRejectsPayloads(t *testing.T) {
+	fi := NewFieldInfo("body", true, 0, false, false, true,
+		INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS, 0, 0, -1, nil)
+	r := &Lucene41PostingsReader{}
+	for _, flags := range []int{0, DOCS_POSITIONS_ENUM_FLAG_PAYLOADS} {
+		e, err := r.DocsAndPositions(fi, nil, nil, nil, flags)
+		if e != nil || err == nil || !strings.Contains(err.Error(), "payloads") {
+			t.Errorf("flags=%v: expected an error about payloads, got %v, %v", flags, e, err)
+		}
+	}
+}
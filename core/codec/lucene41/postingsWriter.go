@@ -334,7 +334,11 @@ func (w *Lucene41PostingsWriter) AddPosition(position int,
 	}
 
 	if w.fieldHasOffsets {
-		panic("not implemented yet")
+		assert(startOffset >= w.lastStartOffset)
+		assert(endOffset >= startOffset)
+		w.offsetStartDeltaBuffer[w.posBufferUpto] = startOffset - w.lastStartOffset
+		w.offsetLengthBuffer[w.posBufferUpto] = endOffset - startOffset
+		w.lastStartOffset = startOffset
 	}
 
 	w.posBufferUpto++
@@ -349,7 +353,12 @@ func (w *Lucene41PostingsWriter) AddPosition(position int,
 			panic("niy")
 		}
 		if w.fieldHasOffsets {
-			panic("niy")
+			if err = w.forUtil.writeBlock(w.offsetStartDeltaBuffer, w.encoded, w.payOut); err != nil {
+				return err
+			}
+			if err = w.forUtil.writeBlock(w.offsetLengthBuffer, w.encoded, w.payOut); err != nil {
+				return err
+			}
 		}
 		w.posBufferUpto = 0
 	}
@@ -433,7 +442,7 @@ func (w *Lucene41PostingsWriter) FinishTerm(_state *BlockTermState) error {
 
 			// vInt encode the remaining positions/payloads/offsets:
 			// lastPayloadLength := -1 // force first payload length to be written
-			// lastOffsetLength := -1  // force first offset length to be written
+			lastOffsetLength := -1 // force first offset length to be written
 			payloadBytesReadUpto := 0
 			for i := 0; i < w.posBufferUpto; i++ {
 				posDelta := w.posDeltaBuffer[i]
@@ -447,7 +456,20 @@ func (w *Lucene41PostingsWriter) FinishTerm(_state *BlockTermState) error {
 				}
 
 				if w.fieldHasOffsets {
-					panic("not implemented yet")
+					delta := w.offsetStartDeltaBuffer[i]
+					length := w.offsetLengthBuffer[i]
+					var err error
+					if length == lastOffsetLength {
+						err = w.posOut.WriteVInt(int32(delta << 1))
+					} else {
+						if err = w.posOut.WriteVInt(int32(delta<<1 | 1)); err == nil {
+							err = w.posOut.WriteVInt(int32(length))
+						}
+						lastOffsetLength = length
+					}
+					if err != nil {
+						return err
+					}
 				}
 			}
 
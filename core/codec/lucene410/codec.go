@@ -6,6 +6,7 @@ import (
 	"github.com/balzaczyy/golucene/core/codec/lucene42"
 	"github.com/balzaczyy/golucene/core/codec/lucene46"
 	"github.com/balzaczyy/golucene/core/codec/lucene49"
+	"github.com/balzaczyy/golucene/core/codec/lucene60"
 	"github.com/balzaczyy/golucene/core/codec/perfield"
 	. "github.com/balzaczyy/golucene/core/codec/spi"
 )
@@ -25,6 +26,7 @@ extend FilterCodec (or embeds the Codec in Go style).
 */
 type Lucene410Codec struct {
 	*CodecImpl
+	pointsFormat PointsFormat
 }
 
 func newLucene410Codec() *Lucene410Codec {
@@ -38,8 +40,14 @@ func newLucene410Codec() *Lucene410Codec {
 			return LoadPostingsFormat("Lucene41")
 		}),
 		perfield.NewPerFieldDocValuesFormat(func(field string) DocValuesFormat {
-			panic("not implemented yet")
+			// TODO: Lucene410DocValuesFormat is not ported yet
+			return LoadDocValuesFormat("Lucene42")
 		}),
 		new(lucene49.Lucene49NormsFormat),
-	)}
+	), lucene60.NewLucene60PointsFormat()}
+}
+
+/* Points aren't part of the Lucene 4.10 format; they use the Lucene 6.0 one. */
+func (codec *Lucene410Codec) PointsFormat() PointsFormat {
+	return codec.pointsFormat
 }
@@ -28,7 +28,7 @@ var Lucene42Codec = NewCodec("Lucene42",
 		panic("not implemented yet")
 	}),
 	perfield.NewPerFieldDocValuesFormat(func(field string) DocValuesFormat {
-		panic("not implemented yet")
+		return LoadDocValuesFormat("Lucene42")
 	}),
 	newReadonlyLucene42NormsFormat(),
 )
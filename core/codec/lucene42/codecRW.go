@@ -5,7 +5,6 @@ import (
 	"github.com/balzaczyy/golucene/core/codec/lucene41"
 	"github.com/balzaczyy/golucene/core/codec/perfield"
 	. "github.com/balzaczyy/golucene/core/codec/spi"
-	. "github.com/balzaczyy/golucene/core/index/model"
 )
 
 // lucene42/Lucene42RWCodec.java
@@ -35,10 +34,6 @@ type Lucene42RWDocValuesFormat struct {
 	*Lucene42DocValuesFormat
 }
 
-func (f *Lucene42RWDocValuesFormat) FieldsConsumer(state *SegmentWriteState) (w DocValuesConsumer, err error) {
-	panic("not implemented yet")
-}
-
 func newLucene42RWDocValuesFormat() *Lucene42RWDocValuesFormat {
 	return &Lucene42RWDocValuesFormat{
 		NewLucene42DocValuesFormat(),
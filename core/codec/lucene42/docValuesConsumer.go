@@ -0,0 +1,406 @@
+package lucene42
+
+import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/fst"
+	"github.com/balzaczyy/golucene/core/util/packed"
+	"math"
+	"sort"
+)
+
+// lucene42/Lucene42DocValuesConsumer.java
+
+const LUCENE42_DV_BLOCK_SIZE = 4096
+
+/* Writer for Lucene42DocValuesFormat */
+type Lucene42DocValuesConsumer struct {
+	data, meta              store.IndexOutput
+	maxDoc                  int
+	acceptableOverheadRatio float32
+}
+
+func newLucene42DocValuesConsumer(state *SegmentWriteState,
+	dataCodec, dataExtension, metaCodec, metaExtension string,
+	acceptableOverheadRatio float32) (dvc *Lucene42DocValuesConsumer, err error) {
+
+	dvc = &Lucene42DocValuesConsumer{
+		maxDoc:                  state.SegmentInfo.DocCount(),
+		acceptableOverheadRatio: acceptableOverheadRatio,
+	}
+	var success = false
+	defer func() {
+		if !success {
+			util.CloseWhileSuppressingError(dvc)
+		}
+	}()
+
+	dataName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, dataExtension)
+	if dvc.data, err = state.Directory.CreateOutput(dataName, state.Context); err != nil {
+		return nil, err
+	}
+	if err = codec.WriteHeader(dvc.data, dataCodec, LUCENE42_DV_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	metaName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, metaExtension)
+	if dvc.meta, err = state.Directory.CreateOutput(metaName, state.Context); err != nil {
+		return nil, err
+	}
+	if err = codec.WriteHeader(dvc.meta, metaCodec, LUCENE42_DV_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	success = true
+	return dvc, nil
+}
+
+func (dvc *Lucene42DocValuesConsumer) AddNumericField(field *FieldInfo,
+	iter func() func() (interface{}, bool)) error {
+	return dvc.addNumericField(field, iter, true)
+}
+
+/* Missing values are written as 0. */
+func numericValue(nv interface{}) int64 {
+	if nv == nil {
+		return 0
+	}
+	return nv.(int64)
+}
+
+func (dvc *Lucene42DocValuesConsumer) addNumericField(field *FieldInfo,
+	iter func() func() (interface{}, bool), optimizeStorage bool) (err error) {
+
+	if err = store.Stream(dvc.meta).WriteVInt(field.Number).
+		WriteByte(LUCENE42_DV_NUMBER).
+		WriteLong(dvc.data.FilePointer()).
+		Close(); err != nil {
+		return err
+	}
+	minValue, maxValue := int64(math.MaxInt64), int64(math.MinInt64)
+	var gcd int64
+	// TODO: more efficient?
+	var uniqueValues map[int64]bool
+	if optimizeStorage {
+		uniqueValues = make(map[int64]bool)
+
+		count := 0
+		next := iter()
+		for {
+			nv, ok := next()
+			if !ok {
+				break
+			}
+			v := numericValue(nv)
+
+			if gcd != 1 {
+				if v < math.MinInt64/2 || v > math.MaxInt64/2 {
+					// in that case v - minValue might overflow and make the GCD
+					// computation return wrong results. Since these extreme
+					// values are unlikely, we just discard GCD computation for
+					// them
+					gcd = 1
+				} else if count != 0 { // minValue needs to be set first
+					gcd = util.Gcd(gcd, v-minValue)
+				}
+			}
+
+			if v < minValue {
+				minValue = v
+			}
+			if v > maxValue {
+				maxValue = v
+			}
+
+			if uniqueValues != nil {
+				if uniqueValues[v] = true; len(uniqueValues) > 256 {
+					uniqueValues = nil
+				}
+			}
+
+			count++
+		}
+		assert2(count == dvc.maxDoc,
+			"illegal doc values for field %v, expected %v values, got %v",
+			field.Name, dvc.maxDoc, count)
+	}
+
+	if uniqueValues != nil {
+		// small number of unique values
+		bitsPerValue := packed.BitsRequired(int64(len(uniqueValues)) - 1)
+		formatAndBits := packed.FastestFormatAndBits(dvc.maxDoc, bitsPerValue, dvc.acceptableOverheadRatio)
+		if formatAndBits.BitsPerValue == 8 && minValue >= math.MinInt8 && maxValue <= math.MaxInt8 {
+			if err = dvc.meta.WriteByte(LUCENE42_DV_UNCOMPRESSED); err != nil { // uncompressed
+				return err
+			}
+			next := iter()
+			for {
+				nv, ok := next()
+				if !ok {
+					break
+				}
+				if err = dvc.data.WriteByte(byte(numericValue(nv))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err = dvc.meta.WriteByte(LUCENE42_DV_TABLE_COMPRESSED); err != nil { // table-compressed
+			return err
+		}
+		decode := make([]int64, 0, len(uniqueValues))
+		for v, _ := range uniqueValues {
+			decode = append(decode, v)
+		}
+		sort.Sort(int64Slice(decode))
+		encode := make(map[int64]int64)
+		if err = dvc.data.WriteVInt(int32(len(decode))); err != nil {
+			return err
+		}
+		for i, v := range decode {
+			if err = dvc.data.WriteLong(v); err != nil {
+				return err
+			}
+			encode[v] = int64(i)
+		}
+
+		if err = dvc.meta.WriteVInt(packed.VERSION_CURRENT); err != nil {
+			return err
+		}
+		if err = store.Stream(dvc.data).WriteVInt(int32(formatAndBits.Format.Id())).
+			WriteVInt(int32(formatAndBits.BitsPerValue)).
+			Close(); err != nil {
+			return err
+		}
+
+		writer := packed.WriterNoHeader(dvc.data, formatAndBits.Format,
+			dvc.maxDoc, formatAndBits.BitsPerValue, packed.DEFAULT_BUFFER_SIZE)
+		next := iter()
+		for {
+			nv, ok := next()
+			if !ok {
+				break
+			}
+			if err = writer.Add(encode[numericValue(nv)]); err != nil {
+				return err
+			}
+		}
+		return writer.Finish()
+	}
+
+	var writer *packed.BlockPackedWriter
+	if gcd != 0 && gcd != 1 {
+		if err = store.Stream(dvc.meta).WriteByte(LUCENE42_DV_GCD_COMPRESSED).
+			WriteVInt(packed.VERSION_CURRENT).
+			Close(); err != nil {
+			return err
+		}
+		if err = store.Stream(dvc.data).WriteLong(minValue).
+			WriteLong(gcd).
+			WriteVInt(LUCENE42_DV_BLOCK_SIZE).
+			Close(); err != nil {
+			return err
+		}
+		writer = packed.NewBlockPackedWriter(dvc.data, LUCENE42_DV_BLOCK_SIZE)
+		next := iter()
+		for {
+			nv, ok := next()
+			if !ok {
+				break
+			}
+			if err = writer.Add((numericValue(nv) - minValue) / gcd); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err = store.Stream(dvc.meta).WriteByte(LUCENE42_DV_DELTA_COMPRESSED). // delta-compressed
+												WriteVInt(packed.VERSION_CURRENT).
+												Close(); err != nil {
+			return err
+		}
+		if err = dvc.data.WriteVInt(LUCENE42_DV_BLOCK_SIZE); err != nil {
+			return err
+		}
+		writer = packed.NewBlockPackedWriter(dvc.data, LUCENE42_DV_BLOCK_SIZE)
+		next := iter()
+		for {
+			nv, ok := next()
+			if !ok {
+				break
+			}
+			if err = writer.Add(numericValue(nv)); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Finish()
+}
+
+func (dvc *Lucene42DocValuesConsumer) AddSortedField(field *FieldInfo,
+	values func() func() ([]byte, bool),
+	docToOrd func() func() (interface{}, bool)) error {
+
+	// three cases for simulating the old writer:
+	// 1. no missing
+	// 2. missing (and empty string in use): remap ord=-1 -> ord=0
+	// 3. missing (and empty string not in use): remap all ords +1, insert empty string into values
+	anyMissing := false
+	next := docToOrd()
+	for {
+		nv, ok := next()
+		if !ok {
+			break
+		}
+		if numericValue(nv) == -1 {
+			anyMissing = true
+			break
+		}
+	}
+
+	hasEmptyString := false
+	if v, ok := values()(); ok {
+		hasEmptyString = len(v) == 0
+	}
+
+	if !anyMissing {
+		// nothing to do
+	} else if hasEmptyString {
+		docToOrd = mapMissingToOrd0(docToOrd)
+	} else {
+		docToOrd = mapAllOrds(docToOrd)
+		values = insertEmptyValue(values)
+	}
+
+	// write the ordinals as numerics
+	if err := dvc.addNumericField(field, docToOrd, false); err != nil {
+		return err
+	}
+
+	// write the values as FST
+	return dvc.writeFST(field, values)
+}
+
+func (dvc *Lucene42DocValuesConsumer) writeFST(field *FieldInfo,
+	values func() func() ([]byte, bool)) (err error) {
+
+	if err = store.Stream(dvc.meta).WriteVInt(field.Number).
+		WriteByte(LUCENE42_DV_FST).
+		WriteLong(dvc.data.FilePointer()).
+		Close(); err != nil {
+		return err
+	}
+	outputs := fst.PositiveIntOutputsSingleton()
+	builder := fst.NewBuilder(fst.INPUT_TYPE_BYTE1,
+		0, 0, true, true, math.MaxInt32,
+		outputs, false,
+		packed.PackedInts.COMPACT, true, 15)
+	scratch := util.NewIntsRefBuilder()
+	var ord int64
+	next := values()
+	for {
+		v, ok := next()
+		if !ok {
+			break
+		}
+		if err = builder.Add(fst.ToIntsRef(v, scratch), ord); err != nil {
+			return err
+		}
+		ord++
+	}
+	var f *fst.FST
+	if f, err = builder.Finish(); err != nil {
+		return err
+	}
+	if f != nil {
+		if err = f.Save(dvc.data); err != nil {
+			return err
+		}
+	}
+	return dvc.meta.WriteVLong(ord)
+}
+
+// codecs/MissingOrdRemapper.java
+
+/* Remaps ord -1 to ord 0 on an iterator. */
+func mapMissingToOrd0(iter func() func() (interface{}, bool)) func() func() (interface{}, bool) {
+	return func() func() (interface{}, bool) {
+		next := iter()
+		return func() (interface{}, bool) {
+			nv, ok := next()
+			if ok && numericValue(nv) == -1 {
+				return int64(0), true
+			}
+			return nv, ok
+		}
+	}
+}
+
+/* Remaps every ord+1 on an iterator. */
+func mapAllOrds(iter func() func() (interface{}, bool)) func() func() (interface{}, bool) {
+	return func() func() (interface{}, bool) {
+		next := iter()
+		return func() (interface{}, bool) {
+			nv, ok := next()
+			if !ok {
+				return nil, false
+			}
+			return numericValue(nv) + 1, true
+		}
+	}
+}
+
+/* Insert an empty []byte as the first value. */
+func insertEmptyValue(iter func() func() ([]byte, bool)) func() func() ([]byte, bool) {
+	return func() func() ([]byte, bool) {
+		next := iter()
+		seenEmpty := false
+		return func() ([]byte, bool) {
+			if !seenEmpty {
+				seenEmpty = true
+				return []byte{}, true
+			}
+			return next()
+		}
+	}
+}
+
+func (dvc *Lucene42DocValuesConsumer) Close() (err error) {
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(dvc.data, dvc.meta)
+		} else {
+			util.CloseWhileSuppressingError(dvc.data, dvc.meta)
+		}
+	}()
+
+	if dvc.meta != nil {
+		if err = dvc.meta.WriteVInt(-1); err != nil { // write EOF marker
+			return
+		}
+		if err = codec.WriteFooter(dvc.meta); err != nil { // write checksum
+			return
+		}
+	}
+	if dvc.data != nil {
+		if err = codec.WriteFooter(dvc.data); err != nil { // write checksum
+			return
+		}
+	}
+	success = true
+	return nil
+}
+
+type int64Slice []int64
+
+func (a int64Slice) Len() int           { return len(a) }
+func (a int64Slice) Less(i, j int) bool { return a[i] < a[j] }
+func (a int64Slice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+func assert2(ok bool, msg string, args ...interface{}) {
+	if !ok {
+		panic(fmt.Sprintf(msg, args...))
+	}
+}
@@ -123,6 +123,14 @@ sequences of increasing VLongs, delta-encoded.
 Limitations:
 - Binary doc values can be at most MAX_BINARY_FIELD_LENGTH in length.
 */
+func init() {
+	RegisterDocValuesFormat(NewLucene42DocValuesFormat())
+}
+
+/*
+NOTE: unlike Lucene Java, which only reads this format, it is also
+written here until a newer DocValues format is ported.
+*/
 type Lucene42DocValuesFormat struct {
 	AcceptableOverheadRatio float32
 }
@@ -136,7 +144,10 @@ func (f *Lucene42DocValuesFormat) Name() string {
 }
 
 func (f *Lucene42DocValuesFormat) FieldsConsumer(state *SegmentWriteState) (w DocValuesConsumer, err error) {
-	panic("this codec can only be used for reading")
+	return newLucene42DocValuesConsumer(state,
+		LUCENE42_DV_DATA_CODEC, LUCENE42_DV_DATA_EXTENSION,
+		LUCENE42_DV_METADATA_CODEC, LUCENE42_DV_METADATA_EXTENSION,
+		f.AcceptableOverheadRatio)
 }
 
 func (f *Lucene42DocValuesFormat) FieldsProducer(state SegmentReadState) (r DocValuesProducer, err error) {
@@ -8,6 +8,8 @@ import (
 	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/fst"
+	"github.com/balzaczyy/golucene/core/util/packed"
 	"reflect"
 	"sync"
 	"sync/atomic"
@@ -43,6 +45,7 @@ type Lucene42DocValuesProducer struct {
 	data     store.IndexInput
 
 	numericInstances map[int]NumericDocValues
+	sortedInstances  map[int]SortedDocValues
 
 	maxDoc       int
 	ramBytesUsed int64
@@ -51,14 +54,15 @@ type Lucene42DocValuesProducer struct {
 func newLucene42DocValuesProducer(state SegmentReadState,
 	dataCodec, dataExtension, metaCodec, metaExtension string) (dvp *Lucene42DocValuesProducer, err error) {
 
-	fmt.Println("Initializing Lucene42DocValuesProducer...")
+	// fmt.Println("Initializing Lucene42DocValuesProducer...")
 	dvp = &Lucene42DocValuesProducer{
 		numericInstances: make(map[int]NumericDocValues),
+		sortedInstances:  make(map[int]SortedDocValues),
 	}
 	dvp.maxDoc = state.SegmentInfo.DocCount()
 
 	metaName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, metaExtension)
-	fmt.Println("Reading", metaName)
+	// fmt.Println("Reading", metaName)
 	// read in the entries from the metadata file.
 	var in store.ChecksumIndexInput
 	if in, err = state.Dir.OpenChecksumInput(metaName, state.Context); err != nil {
@@ -107,7 +111,7 @@ func newLucene42DocValuesProducer(state SegmentReadState,
 	}()
 
 	dataName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, dataExtension)
-	fmt.Println("Reading", dataName)
+	// fmt.Println("Reading", dataName)
 	if dvp.data, err = state.Dir.OpenInput(dataName, state.Context); err != nil {
 		return nil, err
 	}
@@ -122,7 +126,14 @@ func newLucene42DocValuesProducer(state SegmentReadState,
 	}
 
 	if version >= LUCENE42_DV_VERSION_CHECKSUM {
-		panic("niy")
+		// NOTE: data file is too costly to verify checksum against all the
+		// bytes on open, but for now we at least verify proper structure
+		// of the checksum footer: which looks for FOOTER_MAGIC +
+		// algorithmID. This is cheap and can detect some forms of
+		// corruption such as file trucation.
+		if _, err = codec.RetrieveChecksum(dvp.data); err != nil {
+			return nil, err
+		}
 	}
 
 	success = true
@@ -178,13 +189,41 @@ func (dvp *Lucene42DocValuesProducer) readFields(meta store.IndexInput,
 					return
 				}
 			}
-			fmt.Printf("Found entry [offset=%v, format=%v, packedIntsVersion=%v\n",
-				entry.offset, entry.format, entry.packedIntsVersion)
+			// fmt.Printf("Found entry [offset=%v, format=%v, packedIntsVersion=%v\n",
+			// 	entry.offset, entry.format, entry.packedIntsVersion)
 			dvp.numerics[fieldNumber] = entry
 		case LUCENE42_DV_BYTES:
-			panic("not implemented yet")
+			entry := BinaryEntry{}
+			if entry.offset, err = meta.ReadLong(); err != nil {
+				return
+			}
+			if entry.numBytes, err = meta.ReadLong(); err != nil {
+				return
+			}
+			if entry.minLength, err = asInt(meta.ReadVInt()); err != nil {
+				return
+			}
+			if entry.maxLength, err = asInt(meta.ReadVInt()); err != nil {
+				return
+			}
+			if entry.minLength != entry.maxLength {
+				if entry.packedIntsVersion, err = asInt(meta.ReadVInt()); err != nil {
+					return
+				}
+				if entry.blockSize, err = asInt(meta.ReadVInt()); err != nil {
+					return
+				}
+			}
+			dvp.binaries[fieldNumber] = entry
 		case LUCENE42_DV_FST:
-			panic("not implemented yet")
+			entry := FSTEntry{}
+			if entry.offset, err = meta.ReadLong(); err != nil {
+				return
+			}
+			if entry.numOrds, err = meta.ReadVLong(); err != nil {
+				return
+			}
+			dvp.fsts[fieldNumber] = entry
 		default:
 			return errors.New(fmt.Sprintf("invalid entry type: %v, input=%v", fieldType, meta))
 		}
@@ -200,7 +239,10 @@ func asInt(n int32, err error) (n2 int, err2 error) {
 func (dvp *Lucene42DocValuesProducer) Numeric(field *FieldInfo) (v NumericDocValues, err error) {
 	dvp.lock.Lock()
 	defer dvp.lock.Unlock()
+	return dvp.numeric(field)
+}
 
+func (dvp *Lucene42DocValuesProducer) numeric(field *FieldInfo) (v NumericDocValues, err error) {
 	v, exists := dvp.numericInstances[int(field.Number)]
 	if !exists {
 		if v, err = dvp.loadNumeric(field); err == nil {
@@ -218,23 +260,85 @@ func (dvp *Lucene42DocValuesProducer) loadNumeric(field *FieldInfo) (v NumericDo
 
 	switch entry.format {
 	case LUCENE42_DV_TABLE_COMPRESSED:
-		panic("not implemented yet")
+		var size int
+		if size, err = asInt(dvp.data.ReadVInt()); err != nil {
+			return
+		}
+		if size > 256 {
+			return nil, errors.New(fmt.Sprintf(
+				"TABLE_COMPRESSED cannot have more than 256 distinct values, input=%v",
+				dvp.data))
+		}
+		decode := make([]int64, size)
+		for i, _ := range decode {
+			if decode[i], err = dvp.data.ReadLong(); err != nil {
+				return
+			}
+		}
+		var formatId, bitsPerValue int
+		if formatId, err = asInt(dvp.data.ReadVInt()); err != nil {
+			return
+		}
+		if bitsPerValue, err = asInt(dvp.data.ReadVInt()); err != nil {
+			return
+		}
+		var ordsReader packed.PackedIntsReader
+		if ordsReader, err = packed.ReaderNoHeader(dvp.data,
+			packed.PackedFormat(formatId), int32(entry.packedIntsVersion),
+			int32(dvp.maxDoc), uint32(bitsPerValue)); err != nil {
+			return
+		}
+		atomic.AddInt64(&dvp.ramBytesUsed, util.SizeOf(decode)+ordsReader.RamBytesUsed())
+		return func(docID int) int64 {
+			return decode[int(ordsReader.Get(docID))]
+		}, nil
 	case LUCENE42_DV_DELTA_COMPRESSED:
-		panic("not implemented yet")
+		var blockSize int
+		if blockSize, err = asInt(dvp.data.ReadVInt()); err != nil {
+			return
+		}
+		var reader *packed.BlockPackedReader
+		if reader, err = packed.NewBlockPackedReader(dvp.data,
+			int32(entry.packedIntsVersion), blockSize, int64(dvp.maxDoc)); err != nil {
+			return
+		}
+		atomic.AddInt64(&dvp.ramBytesUsed, reader.RamBytesUsed())
+		return func(docID int) int64 {
+			return reader.Get(int64(docID))
+		}, nil
 	case LUCENE42_DV_UNCOMPRESSED:
 		bytes := make([]byte, dvp.maxDoc)
-		if err = dvp.data.ReadBytes(bytes); err == nil {
-			atomic.AddInt64(&dvp.ramBytesUsed, util.SizeOf(bytes))
-			return func(docID int) int64 {
-				return int64(bytes[docID])
-			}, nil
+		if err = dvp.data.ReadBytes(bytes); err != nil {
+			return
 		}
+		atomic.AddInt64(&dvp.ramBytesUsed, util.SizeOf(bytes))
+		return func(docID int) int64 {
+			return int64(int8(bytes[docID]))
+		}, nil
 	case LUCENE42_DV_GCD_COMPRESSED:
-		panic("not implemented yet")
+		var min, mult int64
+		if min, err = dvp.data.ReadLong(); err != nil {
+			return
+		}
+		if mult, err = dvp.data.ReadLong(); err != nil {
+			return
+		}
+		var quotientBlockSize int
+		if quotientBlockSize, err = asInt(dvp.data.ReadVInt()); err != nil {
+			return
+		}
+		var quotientReader *packed.BlockPackedReader
+		if quotientReader, err = packed.NewBlockPackedReader(dvp.data,
+			int32(entry.packedIntsVersion), quotientBlockSize, int64(dvp.maxDoc)); err != nil {
+			return
+		}
+		atomic.AddInt64(&dvp.ramBytesUsed, quotientReader.RamBytesUsed())
+		return func(docID int) int64 {
+			return min + mult*quotientReader.Get(int64(docID))
+		}, nil
 	default:
 		panic("assert fail")
 	}
-	return
 }
 
 func (dvp *Lucene42DocValuesProducer) Binary(field *FieldInfo) (v BinaryDocValues, err error) {
@@ -243,8 +347,58 @@ func (dvp *Lucene42DocValuesProducer) Binary(field *FieldInfo) (v BinaryDocValue
 }
 
 func (dvp *Lucene42DocValuesProducer) Sorted(field *FieldInfo) (v SortedDocValues, err error) {
-	panic("not implemented yet")
-	return nil, nil
+	dvp.lock.Lock()
+	defer dvp.lock.Unlock()
+
+	v, exists := dvp.sortedInstances[int(field.Number)]
+	if !exists {
+		if v, err = dvp.loadSorted(field); err == nil {
+			dvp.sortedInstances[int(field.Number)] = v
+		}
+	}
+	return
+}
+
+func (dvp *Lucene42DocValuesProducer) loadSorted(field *FieldInfo) (v SortedDocValues, err error) {
+	entry := dvp.fsts[int(field.Number)]
+	var docToOrd NumericDocValues
+	if docToOrd, err = dvp.numeric(field); err != nil {
+		return
+	}
+
+	terms := make([][]byte, 0, int(entry.numOrds))
+	if entry.numOrds > 0 {
+		if err = dvp.data.Seek(entry.offset); err != nil {
+			return
+		}
+		var instance *fst.FST
+		if instance, err = fst.LoadFST(dvp.data, fst.PositiveIntOutputsSingleton()); err != nil {
+			return
+		}
+		// TODO: look up ords with Util.getByOutput() instead of decoding
+		// every term up front.
+		var bytesUsed int64
+		enum := fst.NewBytesRefFSTEnum(instance)
+		for {
+			var io *fst.BytesRefFSTEnumIO
+			if io, err = enum.Next(); err != nil {
+				return
+			}
+			if io == nil {
+				break
+			}
+			term := append([]byte(nil), io.Input.ToBytes()...)
+			terms = append(terms, term)
+			bytesUsed += int64(len(term))
+		}
+		if int64(len(terms)) != entry.numOrds {
+			return nil, errors.New(fmt.Sprintf(
+				"FST has %v terms, expected %v (resource=%v)",
+				len(terms), entry.numOrds, dvp.data))
+		}
+		atomic.AddInt64(&dvp.ramBytesUsed, bytesUsed)
+	}
+	return &sortedDocValues{docToOrd, terms}, nil
 }
 
 func (dvp *Lucene42DocValuesProducer) SortedSet(field *FieldInfo) (v SortedSetDocValues, err error) {
@@ -274,6 +428,31 @@ type BinaryEntry struct {
 	blockSize         int
 }
 
+type sortedDocValues struct {
+	docToOrd NumericDocValues
+	terms    [][]byte
+}
+
+func (v *sortedDocValues) Get(docID int) []byte {
+	ord := v.Ord(docID)
+	if ord == -1 {
+		return nil
+	}
+	return v.LookupOrd(ord)
+}
+
+func (v *sortedDocValues) Ord(docID int) int {
+	return int(v.docToOrd(docID))
+}
+
+func (v *sortedDocValues) LookupOrd(ord int) []byte {
+	return v.terms[ord]
+}
+
+func (v *sortedDocValues) ValueCount() int {
+	return len(v.terms)
+}
+
 type FSTEntry struct {
 	offset  int64
 	numOrds int64
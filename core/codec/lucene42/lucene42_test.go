@@ -1,7 +1,9 @@
 package lucene42
 
 import (
+	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
 	"testing"
 )
 
@@ -24,3 +26,157 @@ func TestReadFieldInfos(t *testing.T) {
 		t.Errorf("hasNorms must be true and hasDocValues must be false, but found %v", fis)
 	}
 }
+
+func TestNumericDocValuesRoundTrip(t *testing.T) {
+	const maxDoc = 1000
+	fields := map[string]struct {
+		format byte
+		value  func(doc int) int64
+	}{
+		"uncompressed": {LUCENE42_DV_UNCOMPRESSED, func(doc int) int64 { return int64(doc%256 - 128) }},
+		"table":        {LUCENE42_DV_TABLE_COMPRESSED, func(doc int) int64 { return int64(doc%3) << 40 }},
+		"gcd":          {LUCENE42_DV_GCD_COMPRESSED, func(doc int) int64 { return -5000 + int64(doc)*1000 }},
+		"delta":        {LUCENE42_DV_DELTA_COMPRESSED, func(doc int) int64 { return int64(doc*doc) - 7 }},
+	}
+	var infos []*FieldInfo
+	for name, _ := range fields {
+		infos = append(infos, NewFieldInfo(name, false, int32(len(infos)), false, false, false,
+			0, DOC_VALUES_TYPE_NUMERIC, 0, -1, nil))
+	}
+	fis := NewFieldInfos(infos)
+
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	si := NewSegmentInfo(dir, util.VERSION_LATEST, "_0", maxDoc, false, nil, nil)
+	format := NewLucene42DocValuesFormat()
+	consumer, err := format.FieldsConsumer(NewSegmentWriteState(nil, dir, si, fis,
+		0, nil, store.IO_CONTEXT_DEFAULT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		value := fields[fi.Name].value
+		if err = consumer.AddNumericField(fi, func() func() (interface{}, bool) {
+			doc := 0
+			return func() (interface{}, bool) {
+				if doc == maxDoc {
+					return nil, false
+				}
+				doc++
+				return value(doc - 1), true
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = consumer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	producer, err := format.FieldsProducer(NewSegmentReadState(dir, si, fis, store.IO_CONTEXT_READ, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for _, fi := range infos {
+		field := fields[fi.Name]
+		if got := producer.(*Lucene42DocValuesProducer).numerics[int(fi.Number)].format; got != field.format {
+			t.Errorf("%v: expected format %v, got %v", fi.Name, field.format, got)
+		}
+		values, err := producer.Numeric(fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for doc := 0; doc < maxDoc; doc++ {
+			if got := values(doc); got != field.value(doc) {
+				t.Fatalf("%v: doc %v should be %v, got %v", fi.Name, doc, field.value(doc), got)
+			}
+		}
+	}
+}
+
+func TestSortedDocValuesRoundTrip(t *testing.T) {
+	const maxDoc = 10
+	fields := map[string]struct {
+		values     []string // the unique values, in sorted order
+		ord        func(doc int) int64
+		valueCount int
+	}{
+		"full": {[]string{"apple", "banana", "cherry"},
+			func(doc int) int64 { return int64(doc % 3) }, 3},
+		// missing docs read as the empty string
+		"emptyInUse": {[]string{"", "banana", "cherry"},
+			func(doc int) int64 { return int64(doc%4) - 1 }, 3},
+		// the empty string is inserted as ord 0 for missing docs
+		"emptyNotInUse": {[]string{"apple", "banana", "cherry"},
+			func(doc int) int64 { return int64(doc%4) - 1 }, 4},
+	}
+	var infos []*FieldInfo
+	for name, _ := range fields {
+		infos = append(infos, NewFieldInfo(name, false, int32(len(infos)), false, false, false,
+			0, DOC_VALUES_TYPE_SORTED, 0, -1, nil))
+	}
+	fis := NewFieldInfos(infos)
+
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	si := NewSegmentInfo(dir, util.VERSION_LATEST, "_0", maxDoc, false, nil, nil)
+	format := NewLucene42DocValuesFormat()
+	consumer, err := format.FieldsConsumer(NewSegmentWriteState(nil, dir, si, fis,
+		0, nil, store.IO_CONTEXT_DEFAULT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		field := fields[fi.Name]
+		if err = consumer.AddSortedField(fi, func() func() ([]byte, bool) {
+			ord := 0
+			return func() ([]byte, bool) {
+				if ord == len(field.values) {
+					return nil, false
+				}
+				ord++
+				return []byte(field.values[ord-1]), true
+			}
+		}, func() func() (interface{}, bool) {
+			doc := 0
+			return func() (interface{}, bool) {
+				if doc == maxDoc {
+					return nil, false
+				}
+				doc++
+				return field.ord(doc - 1), true
+			}
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = consumer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	producer, err := format.FieldsProducer(NewSegmentReadState(dir, si, fis, store.IO_CONTEXT_READ, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for _, fi := range infos {
+		field := fields[fi.Name]
+		values, err := producer.Sorted(fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values.ValueCount() != field.valueCount {
+			t.Errorf("%v: expected %v values, got %v", fi.Name, field.valueCount, values.ValueCount())
+		}
+		for doc := 0; doc < maxDoc; doc++ {
+			var expected string
+			if ord := field.ord(doc); ord >= 0 {
+				expected = field.values[ord]
+			}
+			if got := string(values.Get(doc)); got != expected {
+				t.Errorf("%v: doc %v should be %q, got %q", fi.Name, doc, expected, got)
+			}
+		}
+	}
+}
@@ -31,6 +31,9 @@ const (
 	FI_STORE_PAYLOADS               = 0x20
 	FI_OMIT_TERM_FREQ_AND_POSITIONS = 0x40
 	FI_OMIT_POSITIONS               = 0x80
+	// Not part of the Lucene 4.6 format: the field indexes single
+	// dimension points.
+	FI_HAS_POINT_VALUES = 0x8
 )
 
 type Lucene46FieldInfosFormat struct {
@@ -136,9 +139,13 @@ var Lucene46FieldInfosReader = func(dir store.Directory,
 		if attributes, err = input.ReadStringStringMap(); err != nil {
 			return
 		}
-		infos = append(infos, NewFieldInfo(name, isIndexed, fieldNumber,
+		fi := NewFieldInfo(name, isIndexed, fieldNumber,
 			storeTermVector, omitNorms, storePayloads, indexOptions,
-			docValuesType, normsType, dvGen, attributes))
+			docValuesType, normsType, dvGen, attributes)
+		if (bits & FI_HAS_POINT_VALUES) != 0 {
+			fi.SetPointDimensionCount(1)
+		}
+		infos = append(infos, fi)
 	}
 
 	if codecVersion >= FI_FORMAT_CHECKSUM {
@@ -167,6 +174,8 @@ func getDocValuesType(input store.IndexInput, b byte) (t DocValuesType, err erro
 		return DOC_VALUES_TYPE_SORTED, nil
 	case 4:
 		return DOC_VALUES_TYPE_SORTED_SET, nil
+	case 5:
+		return DOC_VALUES_TYPE_SORTED_NUMERIC, nil
 	default:
 		return DocValuesType(0), errors.New(
 			fmt.Sprintf("invalid docvalues byte: %v (resource=%v)", b, input))
@@ -209,6 +218,9 @@ var Lucene46FieldInfosWriter = func(dir store.Directory,
 		if fi.HasPayloads() {
 			bits |= FI_STORE_PAYLOADS
 		}
+		if fi.PointDimensionCount() != 0 {
+			bits |= FI_HAS_POINT_VALUES
+		}
 		if fi.IsIndexed() {
 			bits |= FI_IS_INDEXED
 			assert(indexOptions >= INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS || !fi.HasPayloads())
@@ -0,0 +1,68 @@
+package lucene46
+
+import (
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"testing"
+)
+
+func TestFieldInfosRoundTrip(t *testing.T) {
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+
+	fis := NewFieldInfos([]*FieldInfo{
+		NewFieldInfo("id", true, 0, false, true, false,
+			INDEX_OPT_DOCS_ONLY, 0, 0, -1, nil),
+		NewFieldInfo("body", true, 1, true, false, true,
+			INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS,
+			0, DOC_VALUES_TYPE_NUMERIC, -1, nil),
+		NewFieldInfo("stored", false, 2, false, false, false,
+			INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS, 0, 0, -1, nil),
+		NewFieldInfo("price", false, 3, false, false, false,
+			INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS, DOC_VALUES_TYPE_NUMERIC, 0, 4, nil),
+		NewFieldInfo("tags", false, 5, false, false, false,
+			INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS, DOC_VALUES_TYPE_SORTED_NUMERIC, 0, -1,
+			map[string]string{"format": "sorted"}),
+	})
+
+	format := NewLucene46FieldInfosFormat()
+	if err := format.FieldInfosWriter()(dir, "_0", "", fis, store.IO_CONTEXT_DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	got, err := format.FieldInfosReader()(dir, "_0", "", store.IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Size() != fis.Size() {
+		t.Fatalf("read %v fields, expected %v", got.Size(), fis.Size())
+	}
+	if got.HasVectors != fis.HasVectors || got.HasNorms != fis.HasNorms ||
+		got.HasPayloads != fis.HasPayloads || got.HasDocValues != fis.HasDocValues ||
+		got.HasOffsets != fis.HasOffsets || got.HasFreq != fis.HasFreq {
+		t.Errorf("flags differ: expected %v, got %v", fis, got)
+	}
+	for _, expected := range fis.Values {
+		actual := got.FieldInfoByName(expected.Name)
+		if actual == nil {
+			t.Errorf("missing field %v", expected.Name)
+			continue
+		}
+		if got.FieldInfoByNumber(int(expected.Number)) != actual {
+			t.Errorf("%v: lookup by number %v mismatch", expected.Name, expected.Number)
+		}
+		if actual.IsIndexed() != expected.IsIndexed() ||
+			actual.IndexOptions() != expected.IndexOptions() ||
+			actual.HasVectors() != expected.HasVectors() ||
+			actual.OmitsNorms() != expected.OmitsNorms() ||
+			actual.NormType() != expected.NormType() ||
+			actual.HasPayloads() != expected.HasPayloads() ||
+			actual.DocValuesType() != expected.DocValuesType() ||
+			actual.DocValuesGen() != expected.DocValuesGen() {
+			t.Errorf("expected %v, got %v", expected, actual)
+		}
+	}
+	if v := got.FieldInfoByName("tags").Attribute("format"); v != "sorted" {
+		t.Errorf("attribute format=%v", v)
+	}
+}
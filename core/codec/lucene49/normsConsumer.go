@@ -192,6 +192,12 @@ func (a Longs) Len() int           { return len(a) }
 func (a Longs) Less(i, j int) bool { return a[i] < a[j] }
 func (a Longs) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 
+func (nc *NormsConsumer) AddSortedField(field *FieldInfo,
+	values func() func() ([]byte, bool),
+	docToOrd func() func() (interface{}, bool)) error {
+	panic("not supported")
+}
+
 func (nc *NormsConsumer) Close() (err error) {
 	var success = false
 	defer func() {
@@ -263,10 +269,22 @@ func (m *NormMap) add(l int64) bool {
 
 /* Gets the ordinal for a previously added item. */
 func (m *NormMap) ord(l int64) int {
-	panic("niy")
+	if l >= math.MinInt8 && l <= math.MaxInt8 {
+		return int(m.singleByteRange[int(l+128)])
+	}
+	return int(m.other[l])
 }
 
 /* Retrieves the ordinal table for previously added items. */
 func (m *NormMap) decodeTable() []int64 {
-	panic("niy")
+	decode := make([]int64, m.size)
+	for i, s := range m.singleByteRange {
+		if s >= 0 {
+			decode[s] = int64(i) - 128
+		}
+	}
+	for l, s := range m.other {
+		decode[s] = l
+	}
+	return decode
 }
@@ -0,0 +1,29 @@
+package lucene49
+
+import (
+	"testing"
+)
+
+func TestNormMap(t *testing.T) {
+	m := newNormMap()
+	values := []int64{3, -128, 127, 1000, -1, 3, -50000, 1000}
+	for _, v := range values {
+		m.add(v)
+	}
+	if m.size != 6 {
+		t.Fatalf("expected 6 distinct values, got %v", m.size)
+	}
+	table := m.decodeTable()
+	if len(table) != m.size {
+		t.Fatalf("expected a table of %v values, got %v", m.size, table)
+	}
+	for _, v := range values {
+		if ord := m.ord(v); table[ord] != v {
+			t.Errorf("ord(%v)=%v decodes to %v", v, ord, table[ord])
+		}
+	}
+	// ordinals are assigned in order of first appearance
+	if m.ord(3) != 0 || m.ord(-128) != 1 || m.ord(1000) != 3 || m.ord(-50000) != 5 {
+		t.Errorf("unexpected ordinals, table is %v", table)
+	}
+}
@@ -0,0 +1,77 @@
+package lucene60
+
+import (
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/util/bkd"
+)
+
+// codecs/lucene60/Lucene60PointsFormat.java
+
+const (
+	// Filename extension for the leaf blocks
+	POINTS_DATA_EXTENSION = "dim"
+	// Filename extension for the index per field
+	POINTS_INDEX_EXTENSION = "dii"
+
+	POINTS_DATA_CODEC_NAME  = "Lucene60PointsFormatData"
+	POINTS_INDEX_CODEC_NAME = "Lucene60PointsFormatIndex"
+
+	POINTS_VERSION_START   = 0
+	POINTS_VERSION_CURRENT = POINTS_VERSION_START
+)
+
+/*
+Lucene 6.0 point format, which encodes single dimension points in a
+block KD-tree structure for fast range searching. See bkd.BKDWriter
+for details.
+
+This data structure is written as a series of blocks on disk, with an
+in-memory perfectly balanced binary tree of split values referencing
+those blocks at the end of each field's data.
+
+The .dim file has both blocks and the index split values, for each
+field. The file starts with CodecHeader, followed by the trees of all
+fields, and ends with CodecFooter.
+
+The .dii file records the file pointer in the .dim file where each
+field's index data was written:
+
+	Index (.dii) --> Header,Count,<FieldNumber,IndexFP>^Count,Footer
+	Count,FieldNumber --> VInt
+	IndexFP --> VLong
+*/
+type Lucene60PointsFormat struct {
+	maxPointsInLeafNode int
+	maxPointsSortInHeap int
+}
+
+func NewLucene60PointsFormat() *Lucene60PointsFormat {
+	return NewLucene60PointsFormatWith(bkd.DEFAULT_MAX_POINTS_IN_LEAF_NODE,
+		bkd.DEFAULT_MAX_POINTS_SORT_IN_HEAP)
+}
+
+/*
+Expert: creates a format whose trees hold up to maxPointsInLeafNode
+points per leaf block, and which sorts up to maxPointsSortInHeap
+points of a field in memory before sorting offline.
+*/
+func NewLucene60PointsFormatWith(maxPointsInLeafNode, maxPointsSortInHeap int) *Lucene60PointsFormat {
+	return &Lucene60PointsFormat{maxPointsInLeafNode, maxPointsSortInHeap}
+}
+
+func (f *Lucene60PointsFormat) FieldsWriter(state *SegmentWriteState) (PointsWriter, error) {
+	w, err := newLucene60PointsWriter(state, f.maxPointsInLeafNode, f.maxPointsSortInHeap)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (f *Lucene60PointsFormat) FieldsReader(state SegmentReadState) (PointsReader, error) {
+	r, err := newLucene60PointsReader(state)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,105 @@
+package lucene60
+
+import (
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPointsRoundTrip(t *testing.T) {
+	const maxDoc = 1000
+	fields := map[string]func(doc int) int64{
+		"small": func(doc int) int64 { return int64(doc%100 - 50) },
+		"large": func(doc int) int64 { return int64(maxDoc-doc) << 40 },
+	}
+	var infos []*FieldInfo
+	for _, name := range []string{"small", "none", "large"} {
+		infos = append(infos, NewFieldInfo(name, false, int32(len(infos)), false, false, false,
+			0, 0, 0, -1, nil))
+	}
+	fis := NewFieldInfos(infos)
+
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	si := NewSegmentInfo(dir, util.VERSION_LATEST, "_0", maxDoc, false, nil, nil)
+	// a tiny heap forces every field through the offline sort
+	format := NewLucene60PointsFormatWith(16, 50)
+	w, err := format.FieldsWriter(NewSegmentWriteState(nil, dir, si, fis,
+		0, nil, store.IO_CONTEXT_DEFAULT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range infos {
+		value, ok := fields[fi.Name]
+		if !ok {
+			continue
+		}
+		if err = w.WriteField(fi, func(visit func(docID int, value int64) error) error {
+			for doc := maxDoc - 1; doc >= 0; doc-- {
+				if err := visit(doc, value(doc)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := dir.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if expected := []string{"_0.dii", "_0.dim"}; !reflect.DeepEqual(expected, files) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+
+	r, err := format.FieldsReader(NewSegmentReadState(dir, si, fis, store.IO_CONTEXT_READ, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, fi := range infos {
+		points, err := r.PointValues(fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, ok := fields[fi.Name]
+		if !ok {
+			if points != nil {
+				t.Errorf("%v: field without points has %v points", fi.Name, points.Size())
+			}
+			continue
+		}
+		if points.Size() != maxDoc {
+			t.Errorf("%v: expected %v points, got %v", fi.Name, maxDoc, points.Size())
+		}
+
+		min, max := value(maxDoc/4), value(maxDoc/2)
+		if min > max {
+			min, max = max, min
+		}
+		var expected, actual []int
+		for doc := 0; doc < maxDoc; doc++ {
+			if v := value(doc); v >= min && v <= max {
+				expected = append(expected, doc)
+			}
+		}
+		if err = points.Intersect(min, max, func(docID int) {
+			actual = append(actual, docID)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Ints(actual)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%v: [%v, %v]: expected %v, got %v", fi.Name, min, max, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package lucene60
+
+import (
+	"github.com/balzaczyy/golucene/core/codec"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/bkd"
+)
+
+// codecs/lucene60/Lucene60PointsReader.java
+
+/* Reads point values previously written with Lucene60PointsWriter */
+type Lucene60PointsReader struct {
+	dataIn  store.IndexInput
+	readers map[int32]*bkd.BKDReader // by field number
+}
+
+func newLucene60PointsReader(state SegmentReadState) (*Lucene60PointsReader, error) {
+	indexFPs, err := readPointsIndex(state)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Lucene60PointsReader{readers: make(map[int32]*bkd.BKDReader)}
+	var success = false
+	defer func() {
+		if !success {
+			util.CloseWhileSuppressingError(r)
+		}
+	}()
+
+	dataFileName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, POINTS_DATA_EXTENSION)
+	if r.dataIn, err = state.Dir.OpenInput(dataFileName, state.Context); err != nil {
+		return nil, err
+	}
+	if _, err = codec.CheckHeader(r.dataIn, POINTS_DATA_CODEC_NAME,
+		POINTS_VERSION_START, POINTS_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	// NOTE: data file is too costly to verify checksum against all the
+	// bytes on open, but for now we at least verify proper structure of
+	// the checksum footer: which looks for FOOTER_MAGIC + algorithmID.
+	// This is cheap and can detect some forms of corruption such as
+	// file truncation.
+	if _, err = codec.RetrieveChecksum(r.dataIn); err != nil {
+		return nil, err
+	}
+
+	for number, indexFP := range indexFPs {
+		if r.readers[number], err = bkd.NewBKDReader(r.dataIn, indexFP); err != nil {
+			return nil, err
+		}
+	}
+	success = true
+	return r, nil
+}
+
+/* Reads the index file pointer of each field from the .dii file. */
+func readPointsIndex(state SegmentReadState) (indexFPs map[int32]int64, err error) {
+	indexFileName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, POINTS_INDEX_EXTENSION)
+	var in store.ChecksumIndexInput
+	if in, err = state.Dir.OpenChecksumInput(indexFileName, state.Context); err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = util.CloseWhileHandlingError(err, in)
+	}()
+
+	if _, err = codec.CheckHeader(in, POINTS_INDEX_CODEC_NAME,
+		POINTS_VERSION_START, POINTS_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	count, err := in.ReadVInt()
+	if err != nil {
+		return nil, err
+	}
+	indexFPs = make(map[int32]int64)
+	for i := int32(0); i < count; i++ {
+		number, err := in.ReadVInt()
+		if err != nil {
+			return nil, err
+		}
+		if indexFPs[number], err = in.ReadVLong(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = codec.CheckFooter(in); err != nil {
+		return nil, err
+	}
+	return indexFPs, nil
+}
+
+func (r *Lucene60PointsReader) PointValues(field *FieldInfo) (PointValues, error) {
+	if reader, ok := r.readers[field.Number]; ok {
+		return reader, nil
+	}
+	return nil, nil
+}
+
+func (r *Lucene60PointsReader) Close() error {
+	if r.dataIn == nil {
+		return nil
+	}
+	return r.dataIn.Close()
+}
@@ -0,0 +1,117 @@
+package lucene60
+
+import (
+	"github.com/balzaczyy/golucene/core/codec"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/bkd"
+	"sort"
+)
+
+// codecs/lucene60/Lucene60PointsWriter.java
+
+/* Writes dimensional values */
+type Lucene60PointsWriter struct {
+	state               *SegmentWriteState
+	dataOut             store.IndexOutput
+	indexFPs            map[int32]int64 // by field number
+	maxPointsInLeafNode int
+	maxPointsSortInHeap int
+}
+
+func newLucene60PointsWriter(state *SegmentWriteState,
+	maxPointsInLeafNode, maxPointsSortInHeap int) (w *Lucene60PointsWriter, err error) {
+
+	w = &Lucene60PointsWriter{
+		state:               state,
+		indexFPs:            make(map[int32]int64),
+		maxPointsInLeafNode: maxPointsInLeafNode,
+		maxPointsSortInHeap: maxPointsSortInHeap,
+	}
+	dataFileName := util.SegmentFileName(state.SegmentInfo.Name, state.SegmentSuffix, POINTS_DATA_EXTENSION)
+	if w.dataOut, err = state.Directory.CreateOutput(dataFileName, state.Context); err != nil {
+		return nil, err
+	}
+	if err = codec.WriteHeader(w.dataOut, POINTS_DATA_CODEC_NAME, POINTS_VERSION_CURRENT); err != nil {
+		util.CloseWhileSuppressingError(w.dataOut)
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Lucene60PointsWriter) WriteField(field *FieldInfo,
+	values func(visit func(docID int, value int64) error) error) (err error) {
+
+	writer := bkd.NewBKDWriterWithOptions(w.state.Directory, w.state.SegmentInfo.Name,
+		w.maxPointsInLeafNode, w.maxPointsSortInHeap)
+	defer func() {
+		err = util.CloseWhileHandlingError(err, writer)
+	}()
+
+	if err = values(func(docID int, value int64) error {
+		return writer.Add(value, docID)
+	}); err != nil {
+		return err
+	}
+	indexFP, err := writer.Finish(w.dataOut)
+	if err != nil {
+		return err
+	}
+	w.indexFPs[field.Number] = indexFP
+	return nil
+}
+
+/* Writes the footer of the .dim file, and the .dii file. */
+func (w *Lucene60PointsWriter) Close() (err error) {
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(w.dataOut)
+		} else {
+			util.CloseWhileSuppressingError(w.dataOut)
+		}
+	}()
+
+	if err = codec.WriteFooter(w.dataOut); err != nil {
+		return err
+	}
+
+	if err = w.writeIndex(); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+func (w *Lucene60PointsWriter) writeIndex() (err error) {
+	indexFileName := util.SegmentFileName(w.state.SegmentInfo.Name, w.state.SegmentSuffix, POINTS_INDEX_EXTENSION)
+	var indexOut store.IndexOutput
+	if indexOut, err = w.state.Directory.CreateOutput(indexFileName, w.state.Context); err != nil {
+		return err
+	}
+	defer func() {
+		err = util.CloseWhileHandlingError(err, indexOut)
+	}()
+
+	if err = codec.WriteHeader(indexOut, POINTS_INDEX_CODEC_NAME, POINTS_VERSION_CURRENT); err != nil {
+		return err
+	}
+	var numbers []int
+	for number := range w.indexFPs {
+		numbers = append(numbers, int(number))
+	}
+	sort.Ints(numbers)
+	if err = indexOut.WriteVInt(int32(len(numbers))); err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		if err = indexOut.WriteVInt(int32(number)); err != nil {
+			return err
+		}
+		if err = indexOut.WriteVLong(w.indexFPs[int32(number)]); err != nil {
+			return err
+		}
+	}
+	return codec.WriteFooter(indexOut)
+}
@@ -5,6 +5,7 @@ import (
 	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/util"
 	"io"
+	"strconv"
 )
 
 // perfield/PerFieldDocValuesFormat.java
@@ -22,10 +23,11 @@ containing the format name. For example, in a per-field configuration
 instead of _1.dat fielnames would look like _1_Lucene40_0.dat.
 */
 type PerFieldDocValuesFormat struct {
+	docValuesFormatForField func(string) DocValuesFormat
 }
 
 func NewPerFieldDocValuesFormat(f func(field string) DocValuesFormat) *PerFieldDocValuesFormat {
-	return &PerFieldDocValuesFormat{}
+	return &PerFieldDocValuesFormat{f}
 }
 
 func (pf *PerFieldDocValuesFormat) Name() string {
@@ -33,13 +35,119 @@ func (pf *PerFieldDocValuesFormat) Name() string {
 }
 
 func (pf *PerFieldDocValuesFormat) FieldsConsumer(state *SegmentWriteState) (w DocValuesConsumer, err error) {
-	panic("not implemented yet")
+	return newPerFieldDocValuesWriter(pf, state), nil
 }
 
 func (pf *PerFieldDocValuesFormat) FieldsProducer(state SegmentReadState) (r DocValuesProducer, err error) {
 	return newPerFieldDocValuesReader(state)
 }
 
+const (
+	DV_PER_FIELD_FORMAT_KEY = "PerFieldDocValuesFormat.format"
+	DV_PER_FIELD_SUFFIX_KEY = "PerFieldDocValuesFormat.suffix"
+)
+
+type DocValuesConsumerAndSuffix struct {
+	consumer DocValuesConsumer
+	suffix   int
+}
+
+func (cas *DocValuesConsumerAndSuffix) Close() error {
+	return cas.consumer.Close()
+}
+
+type PerFieldDocValuesWriter struct {
+	owner             *PerFieldDocValuesFormat
+	formats           map[DocValuesFormat]*DocValuesConsumerAndSuffix
+	suffixes          map[string]int
+	segmentWriteState *SegmentWriteState
+}
+
+func newPerFieldDocValuesWriter(owner *PerFieldDocValuesFormat,
+	state *SegmentWriteState) DocValuesConsumer {
+	return &PerFieldDocValuesWriter{
+		owner,
+		make(map[DocValuesFormat]*DocValuesConsumerAndSuffix),
+		make(map[string]int),
+		state,
+	}
+}
+
+func (w *PerFieldDocValuesWriter) AddNumericField(field *FieldInfo,
+	iter func() func() (interface{}, bool)) error {
+
+	consumer, err := w.instance(field)
+	if err != nil {
+		return err
+	}
+	return consumer.AddNumericField(field, iter)
+}
+
+func (w *PerFieldDocValuesWriter) AddSortedField(field *FieldInfo,
+	values func() func() ([]byte, bool),
+	docToOrd func() func() (interface{}, bool)) error {
+
+	consumer, err := w.instance(field)
+	if err != nil {
+		return err
+	}
+	return consumer.AddSortedField(field, values, docToOrd)
+}
+
+func (w *PerFieldDocValuesWriter) instance(field *FieldInfo) (DocValuesConsumer, error) {
+	format := w.owner.docValuesFormatForField(field.Name)
+	assert2(format != nil, "invalid nil DocValuesFormat for field='%v'", field.Name)
+	formatName := format.Name()
+
+	previousValue := field.PutAttribute(DV_PER_FIELD_FORMAT_KEY, formatName)
+	assert2(previousValue == "", "formatName=%v prevValue=%v", formatName, previousValue)
+
+	var suffix int
+
+	consumer, ok := w.formats[format]
+	if !ok {
+		// First time we are seeing this format; create a new instance
+
+		// bump the suffix
+		if suffix, ok = w.suffixes[formatName]; ok {
+			suffix++
+		}
+		w.suffixes[formatName] = suffix
+
+		segmentSuffix := dvFullSegmentSuffix(w.segmentWriteState.SegmentSuffix,
+			dvSuffix(formatName, strconv.Itoa(suffix)))
+
+		consumer = new(DocValuesConsumerAndSuffix)
+		var err error
+		if consumer.consumer, err = format.FieldsConsumer(
+			NewSegmentWriteStateFrom(w.segmentWriteState, segmentSuffix)); err != nil {
+			return nil, err
+		}
+		consumer.suffix = suffix
+		w.formats[format] = consumer
+	} else {
+		// we've already seen this format, so just grab its suffix
+		_, ok := w.suffixes[formatName]
+		assert(ok)
+		suffix = consumer.suffix
+	}
+
+	previousValue = field.PutAttribute(DV_PER_FIELD_SUFFIX_KEY, strconv.Itoa(suffix))
+	assert2(previousValue == "", "suffix=%v prevValue=%v", suffix, previousValue)
+
+	// TODO: we should only provide the "slice" of FIS that this DVF
+	// actually sees ...
+	return consumer.consumer, nil
+}
+
+func (w *PerFieldDocValuesWriter) Close() error {
+	var subs []io.Closer
+	for _, v := range w.formats {
+		subs = append(subs, v)
+	}
+	return util.Close(subs...)
+}
+
 func dvSuffix(format, suffix string) string {
 	return format + "_" + suffix
 }
@@ -78,17 +186,19 @@ func newPerFieldDocValuesReader(state SegmentReadState) (dvp DocValuesProducer,
 	for _, fi := range state.FieldInfos.Values {
 		if fi.HasDocValues() {
 			fieldName := fi.Name
-			if formatName := fi.Attribute(PER_FIELD_FORMAT_KEY); formatName != "" {
+			if formatName := fi.Attribute(DV_PER_FIELD_FORMAT_KEY); formatName != "" {
 				// null formatName means the field is in fieldInfos, but has no docvalues!
-				suffix := fi.Attribute(PER_FIELD_SUFFIX_KEY)
-				// assert suffix != nil
+				suffix := fi.Attribute(DV_PER_FIELD_SUFFIX_KEY)
+				assert2(suffix != "", "missing attribute: %v for field: %v", DV_PER_FIELD_SUFFIX_KEY, fieldName)
 				segmentSuffix := dvFullSegmentSuffix(state.SegmentSuffix, dvSuffix(formatName, suffix))
 				if _, ok := ans.formats[segmentSuffix]; !ok {
 					newReadState := state // clone
-					newReadState.SegmentSuffix = formatName + "_" + suffix
-					if p, err := LoadDocValuesProducer(formatName, newReadState); err == nil {
-						ans.formats[segmentSuffix] = p
+					newReadState.SegmentSuffix = segmentSuffix
+					var p DocValuesProducer
+					if p, err = LoadDocValuesProducer(formatName, newReadState); err != nil {
+						return nil, err
 					}
+					ans.formats[segmentSuffix] = p
 				}
 				ans.fields[fieldName] = ans.formats[segmentSuffix]
 			}
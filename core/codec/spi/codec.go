@@ -1,10 +1,12 @@
 package spi
 
 import (
+	"errors"
 	"fmt"
 	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
+	"sort"
 )
 
 // codecs/Codec.java
@@ -14,11 +16,11 @@ Encodes/decodes an inverted index segment.
 
 Note, when extending this class, the name is written into the index.
 In order for the segment to be read, the name must resolve to your
-implementation via forName(). This method use hard-coded map to
-resolve codec names.
+implementation via ForName(). Codecs are resolved from a registry
+which is populated by RegisterCodec().
 
-If you implement your own codec, make sure that it is included so
-SPI can load it.
+If you implement your own codec, make sure it registers itself
+(usually from an init func) so it can be loaded.
 */
 type Codec interface {
 	// Returns this codec's name
@@ -39,6 +41,8 @@ type Codec interface {
 	NormsFormat() NormsFormat
 	// Encodes/decodes live docs
 	LiveDocsFormat() LiveDocsFormat
+	// Encodes/decodes points, or nil if the codec can't index points
+	PointsFormat() PointsFormat
 }
 
 type CodecImpl struct {
@@ -102,6 +106,14 @@ func (codec *CodecImpl) LiveDocsFormat() LiveDocsFormat {
 	return codec.liveDocsFormat
 }
 
+/*
+Returns nil, as the Lucene 4.x formats have no points; codecs which
+index points override this.
+*/
+func (codec *CodecImpl) PointsFormat() PointsFormat {
+	return nil
+}
+
 /*
 returns the codec's name. Subclass can override to provide more
 detail (such as parameters.)
@@ -120,23 +132,35 @@ func RegisterCodec(codecs ...Codec) {
 	}
 }
 
-// looks up a codec by name
+/*
+Looks up a codec by name, returning an error which lists the
+registered codecs when there is no such codec.
+*/
+func ForName(name string) (Codec, error) {
+	if c, ok := allCodecs[name]; ok {
+		return c, nil
+	}
+	return nil, errors.New(fmt.Sprintf(
+		"A Codec with name '%v' does not exist. You need to import the package providing it. The current registered codecs: %v",
+		name, AvailableCodecs()))
+}
+
+// looks up a codec by name, panics if it's not registered
 func LoadCodec(name string) Codec {
-	c, ok := allCodecs[name]
-	if !ok {
-		fmt.Println("Unknown codec:", name)
-		fmt.Println("Available codecs:", allCodecs)
-		assert(ok)
+	c, err := ForName(name)
+	if err != nil {
+		panic(err)
 	}
 	return c
 }
 
-// returns a list of all available codec names
+// returns a sorted list of all available codec names
 func AvailableCodecs() []string {
 	ans := make([]string, 0, len(allCodecs))
 	for name, _ := range allCodecs {
 		ans = append(ans, name)
 	}
+	sort.Strings(ans)
 	return ans
 }
 
@@ -212,6 +236,8 @@ type LiveDocsFormat interface {
 	NewLiveDocs(size int) util.MutableBits
 	// Creates a new MutableBits of the same bits set and size of existing.
 	// NewLiveDocs(existing util.Bits) (util.MutableBits, error)
+	// Read live docs bits.
+	ReadLiveDocs(dir store.Directory, info *SegmentCommitInfo, ctx store.IOContext) (util.Bits, error)
 	// Persist live docs bits. Use SegmentCommitInfo.nextDelGen() to
 	// determine the generation of the deletes file you should write to.
 	WriteLiveDocs(bits util.MutableBits, dir store.Directory,
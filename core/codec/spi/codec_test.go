@@ -0,0 +1,38 @@
+package spi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCodecRegistry(t *testing.T) {
+	fake := NewCodec("FakeCodec", nil, nil, nil, nil, nil, nil, nil, nil)
+	RegisterCodec(fake)
+	defer delete(allCodecs, "FakeCodec")
+
+	c, err := ForName("FakeCodec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != fake {
+		t.Errorf("ForName returned %v, expected the registered codec", c)
+	}
+	if LoadCodec("FakeCodec") != fake {
+		t.Error("LoadCodec should resolve the registered codec")
+	}
+
+	found := false
+	for _, name := range AvailableCodecs() {
+		found = found || name == "FakeCodec"
+	}
+	if !found {
+		t.Errorf("FakeCodec missing from %v", AvailableCodecs())
+	}
+
+	if c, err = ForName("NoSuchCodec"); err == nil {
+		t.Fatalf("expected an error for an unknown codec, got %v", c)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "NoSuchCodec") || !strings.Contains(msg, "FakeCodec") {
+		t.Errorf("error should name the missing and the registered codecs: %v", msg)
+	}
+}
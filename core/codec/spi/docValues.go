@@ -45,17 +45,14 @@ func RegisterDocValuesFormat(formats ...DocValuesFormat) {
 	}
 }
 
+func LoadDocValuesFormat(name string) DocValuesFormat {
+	v, ok := allDocValuesFormats[name]
+	assert2(ok, "Service '%v' not found.", name)
+	return v
+}
+
 func LoadDocValuesProducer(name string, state SegmentReadState) (fp DocValuesProducer, err error) {
-	panic("not implemented yet")
-	// switch name {
-	// case "Lucene42":
-	// 	return newLucene42DocValuesProducer(state, LUCENE42_DV_DATA_CODEC, LUCENE42_DV_DATA_EXTENSION,
-	// 		LUCENE42_DV_METADATA_CODEC, LUCENE42_DV_METADATA_EXTENSION)
-	// case "Lucene45":
-	// 	return newLucene45DocValuesProducer(state, LUCENE45_DV_DATA_CODEC, LUCENE45_DV_DATA_EXTENSION,
-	// 		LUCENE45_DV_META_CODEC, LUCENE45_DV_META_EXTENSION)
-	// }
-	// panic(fmt.Sprintf("Service '%v' not found.", name))
+	return LoadDocValuesFormat(name).FieldsProducer(state)
 }
 
 // codecs/DocValuesConsumer.java
@@ -78,6 +75,12 @@ type DocValuesConsumer interface {
 	io.Closer
 	// Writes numeric docvalues for a field.
 	AddNumericField(*FieldInfo, func() func() (interface{}, bool)) error
+	// Writes pre-sorted binary docvalues for a field: the unique values
+	// in sorted order, and the ordinal of each document (-1 if the
+	// document has no value).
+	AddSortedField(field *FieldInfo,
+		values func() func() ([]byte, bool),
+		docToOrd func() func() (interface{}, bool)) error
 }
 
 // codecs/DocvaluesProducer.java
@@ -0,0 +1,64 @@
+package spi
+
+import (
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"io"
+)
+
+// index/PointValues.java
+
+/*
+Access to indexed numeric values of a field, to find the documents
+whose values fall in a range. The values of a segment are indexed as a
+single dimension BKD tree (see bkd.BKDReader, which implements this
+interface).
+*/
+type PointValues interface {
+	// Calls visit with the docID of every value within [min, max],
+	// both inclusive; a document is visited once per matching value.
+	Intersect(min, max int64, visit func(docID int)) error
+	// Calls visit with every indexed value and its docID, in value
+	// order.
+	VisitAll(visit func(docID int, value int64) error) error
+	// Returns the total number of indexed values.
+	Size() int64
+	// Returns the minimum indexed value.
+	MinValue() int64
+	// Returns the maximum indexed value.
+	MaxValue() int64
+}
+
+// codecs/PointsFormat.java
+
+/* Encodes/decodes indexed points. */
+type PointsFormat interface {
+	// Returns a PointsWriter to write points to the index.
+	FieldsWriter(state *SegmentWriteState) (w PointsWriter, err error)
+	// Returns a PointsReader to read points from the index.
+	//
+	// NOTE: by the time this call returns, it must hold open any files
+	// it will need to use; else, those files may be deleted.
+	FieldsReader(state SegmentReadState) (r PointsReader, err error)
+}
+
+// codecs/PointsWriter.java
+
+/*
+Abstract API to write points. Close() completes the files, so it must
+be called once all fields are written.
+*/
+type PointsWriter interface {
+	io.Closer
+	// Writes the points of one field: values calls visit once per
+	// indexed value of a document, in any order.
+	WriteField(field *FieldInfo, values func(visit func(docID int, value int64) error) error) error
+}
+
+// codecs/PointsReader.java
+
+/* Abstract API to read points. */
+type PointsReader interface {
+	io.Closer
+	// Returns the points of the field, or nil if it has none.
+	PointValues(field *FieldInfo) (PointValues, error)
+}
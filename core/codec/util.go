@@ -1,7 +1,6 @@
 package codec
 
 import (
-	"errors"
 	"fmt"
 	"github.com/balzaczyy/golucene/core/util"
 )
@@ -71,6 +70,12 @@ type DataInput interface {
 	ReadString() (string, error)
 }
 
+/*
+Reads and validates a header previously written with WriteHeader(),
+returning the actual version. A wrong magic or codec name is reported
+as *CorruptIndexError, a version outside [minVersion, maxVersion] as
+*IndexFormatTooOldError or *IndexFormatTooNewError.
+*/
 func CheckHeader(in DataInput, codec string, minVersion, maxVersion int32) (v int32, err error) {
 	// Safety to guard against reading a bogus string:
 	actualHeader, err := in.ReadInt()
@@ -78,21 +83,22 @@ func CheckHeader(in DataInput, codec string, minVersion, maxVersion int32) (v in
 		return 0, err
 	}
 	if actualHeader != CODEC_MAGIC {
-		return 0, errors.New(fmt.Sprintf(
-			"codec header mismatch: actual header=%v vs expected header=%v (resource: %v)",
-			actualHeader, CODEC_MAGIC, in))
+		return 0, NewCorruptIndexError(fmt.Sprintf(
+			"codec header mismatch: actual header=%v vs expected header=%v",
+			actualHeader, CODEC_MAGIC), in)
 	}
 	return CheckHeaderNoMagic(in, codec, minVersion, maxVersion)
 }
 
+/* Like CheckHeader() except this version assumes the magic has already been read and validated. */
 func CheckHeaderNoMagic(in DataInput, codec string, minVersion, maxVersion int32) (v int32, err error) {
 	actualCodec, err := in.ReadString()
 	if err != nil {
 		return 0, err
 	}
 	if actualCodec != codec {
-		return 0, errors.New(fmt.Sprintf(
-			"codec mismatch: actual codec=%v vs expected codec=%v (resource: %v)", actualCodec, codec, in))
+		return 0, NewCorruptIndexError(fmt.Sprintf(
+			"codec mismatch: actual codec=%v vs expected codec=%v", actualCodec, codec), in)
 	}
 
 	actualVersion, err := in.ReadInt()
@@ -109,18 +115,6 @@ func CheckHeaderNoMagic(in DataInput, codec string, minVersion, maxVersion int32
 	return actualVersion, nil
 }
 
-func NewIndexFormatTooNewError(in DataInput, version, minVersion, maxVersion int32) error {
-	return errors.New(fmt.Sprintf(
-		"Format version is not supported (resource: %v): %v (needs to be between %v and %v)",
-		in, version, minVersion, maxVersion))
-}
-
-func NewIndexFormatTooOldError(in DataInput, version, minVersion, maxVersion int32) error {
-	return errors.New(fmt.Sprintf(
-		"Format version is not supported (resource: %v): %v (needs to be between %v and %v). This version of Lucene only supports indexes created with release 3.0 and later.",
-		in, version, minVersion, maxVersion))
-}
-
 type IndexOutput interface {
 	WriteInt(n int32) error
 	WriteLong(n int64) error
@@ -153,22 +147,48 @@ type ChecksumIndexInput interface {
 	Checksum() int64
 }
 
-/* Validates the codec footer previously written by WriteFooter(). */
+/*
+Validates the codec footer previously written by WriteFooter().
+Returns *CorruptIndexError if the footer or checksum doesn't match.
+*/
 func CheckFooter(in ChecksumIndexInput) (cs int64, err error) {
+	if cs, err = checkFooterChecksum(in); err == nil {
+		if in.FilePointer() != in.Length() {
+			return 0, NewCorruptIndexError(fmt.Sprintf(
+				"did not read all bytes from file: read %v vs size %v",
+				in.FilePointer(), in.Length()), in)
+		}
+	}
+	return
+}
+
+/*
+Like CheckFooter(), but for a footer written at footerOffset instead
+of the end of the stream, e.g. a sub-file inside a compound file.
+Reads all bytes up to footerOffset, updating the checksum, then
+validates the footer found there.
+*/
+func SkipToFooter(in ChecksumIndexInput, footerOffset int64) error {
+	if footerOffset < in.FilePointer() || footerOffset+FOOTER_LENGTH > in.Length() {
+		return NewCorruptIndexError(fmt.Sprintf(
+			"footer offset %v out of bounds: position=%v length=%v",
+			footerOffset, in.FilePointer(), in.Length()), in)
+	}
+	if err := in.Seek(footerOffset); err != nil {
+		return err
+	}
+	_, err := checkFooterChecksum(in)
+	return err
+}
+
+func checkFooterChecksum(in ChecksumIndexInput) (cs int64, err error) {
 	if err = validateFooter(in); err == nil {
 		cs = in.Checksum()
 		var cs2 int64
-		if cs2, err = in.ReadLong(); err == nil {
-			if cs != cs2 {
-				return 0, errors.New(fmt.Sprintf(
-					"checksum failed (hardware problem?): expected=%v actual=%v (resource=%v)",
-					util.ItoHex(cs2), util.ItoHex(cs), in))
-			}
-			if in.FilePointer() != in.Length() {
-				return 0, errors.New(fmt.Sprintf(
-					"did not read all bytes from file: read %v vs size %v (resource: %v)",
-					in.FilePointer(), in.Length(), in))
-			}
+		if cs2, err = in.ReadLong(); err == nil && cs != cs2 {
+			return 0, NewCorruptIndexError(fmt.Sprintf(
+				"checksum failed (hardware problem?): expected=%v actual=%v",
+				util.ItoHex(cs2), util.ItoHex(cs)), in)
 		}
 	}
 	return
@@ -192,9 +212,9 @@ func validateFooter(in IndexInput) error {
 		return err
 	}
 	if magic != FOOTER_MAGIC {
-		return errors.New(fmt.Sprintf(
-			"codec footer mismatch: actual footer=%v vs expected footer=%v (resource: %v)",
-			magic, FOOTER_MAGIC, in))
+		return NewCorruptIndexError(fmt.Sprintf(
+			"codec footer mismatch: actual footer=%v vs expected footer=%v",
+			magic, FOOTER_MAGIC), in)
 	}
 
 	algorithmId, err := in.ReadInt()
@@ -202,9 +222,9 @@ func validateFooter(in IndexInput) error {
 		return err
 	}
 	if algorithmId != 0 {
-		return errors.New(fmt.Sprintf(
+		return NewCorruptIndexError(fmt.Sprintf(
 			"codec footer mismatch: unknown algorithmID: %v",
-			algorithmId))
+			algorithmId), in)
 	}
 	return nil
 }
@@ -220,9 +240,9 @@ type IndexInput interface {
 /* Checks that the stream is positioned at the end, and returns error if it is not. */
 func CheckEOF(in IndexInput) error {
 	if in.FilePointer() != in.Length() {
-		return errors.New(fmt.Sprintf(
-			"did not read all bytes from file: read %v vs size %v (resources: %v)",
-			in.FilePointer(), in.Length(), in))
+		return NewCorruptIndexError(fmt.Sprintf(
+			"did not read all bytes from file: read %v vs size %v",
+			in.FilePointer(), in.Length()), in)
 	}
 	return nil
 }
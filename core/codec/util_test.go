@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"errors"
+	"github.com/balzaczyy/golucene/core/util"
+	"io"
+	"testing"
+)
+
+type sliceDataInput struct {
+	*util.DataInputImpl
+	data []byte
+}
+
+func newSliceDataInput(data []byte) *sliceDataInput {
+	ans := &sliceDataInput{data: data}
+	ans.DataInputImpl = util.NewDataInput(ans)
+	return ans
+}
+
+func (in *sliceDataInput) ReadByte() (byte, error) {
+	if len(in.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b, nil
+}
+
+func (in *sliceDataInput) ReadBytes(buf []byte) error {
+	if len(in.data) < len(buf) {
+		return io.ErrUnexpectedEOF
+	}
+	in.data = in.data[copy(buf, in.data):]
+	return nil
+}
+
+func header(t *testing.T, magic int32, codec string, version int32) []byte {
+	out := util.NewGrowableByteArrayDataOutput(16)
+	err := out.WriteInt(magic)
+	if err == nil {
+		err = out.WriteString(codec)
+	}
+	if err == nil {
+		err = out.WriteInt(version)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()[:out.Position()]
+}
+
+func TestWriteHeader(t *testing.T) {
+	out := util.NewGrowableByteArrayDataOutput(16)
+	if err := WriteHeader(out, "FooBar", 5); err != nil {
+		t.Fatal(err)
+	}
+	if out.Position() != HeaderLength("FooBar") {
+		t.Errorf("header length %v, expected %v", out.Position(), HeaderLength("FooBar"))
+	}
+	in := newSliceDataInput(out.Bytes()[:out.Position()])
+	v, err := CheckHeader(in, "FooBar", 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 5 {
+		t.Errorf("version=%v, expected 5", v)
+	}
+}
+
+func TestCheckHeaderErrors(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		header []byte
+		check  func(error) bool
+	}{
+		{"wrong magic", header(t, 0x1234, "FooBar", 5), func(err error) bool {
+			_, ok := err.(*CorruptIndexError)
+			return ok
+		}},
+		{"wrong codec", header(t, CODEC_MAGIC, "Other", 5), func(err error) bool {
+			_, ok := err.(*CorruptIndexError)
+			return ok
+		}},
+		{"too old", header(t, CODEC_MAGIC, "FooBar", 2), func(err error) bool {
+			e, ok := err.(*IndexFormatTooOldError)
+			return ok && e.Version == 2 && e.MinVersion == 3 && e.MaxVersion == 7
+		}},
+		{"too new", header(t, CODEC_MAGIC, "FooBar", 8), func(err error) bool {
+			e, ok := err.(*IndexFormatTooNewError)
+			return ok && e.Version == 8 && e.MinVersion == 3 && e.MaxVersion == 7
+		}},
+	} {
+		_, err := CheckHeader(newSliceDataInput(c.header), "FooBar", 3, 7)
+		if err == nil {
+			t.Errorf("%v: expected an error", c.name)
+			continue
+		}
+		if !c.check(err) {
+			t.Errorf("%v: unexpected error %T: %v", c.name, err, err)
+		}
+		if !errors.Is(err, ErrCorruptIndex) {
+			t.Errorf("%v: %v should match ErrCorruptIndex", c.name, err)
+		}
+	}
+}
@@ -64,6 +64,70 @@ func (doc *Document) Get(name string) string {
 	return ""
 }
 
+/*
+Returns the first field with the given name, or nil if there is none.
+*/
+func (doc *Document) GetField(name string) IndexableField {
+	for _, field := range doc.fields {
+		if field.Name() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+/*
+Returns the binary value of the first field with the given name that
+has one, or nil.
+*/
+func (doc *Document) GetBinaryValue(name string) []byte {
+	for _, field := range doc.fields {
+		if field.Name() == name {
+			if v := field.BinaryValue(); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the numeric value of the first named field with one, or nil.
+func (doc *Document) numericValue(name string) interface{} {
+	for _, field := range doc.fields {
+		if field.Name() == name {
+			if v := field.NumericValue(); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Returns the value of the first field with the given name that has a
+numeric value, and whether it was an int. The same goes for
+GetLong(), GetFloat() and GetDouble().
+*/
+func (doc *Document) GetInt(name string) (int32, bool) {
+	v, ok := doc.numericValue(name).(int32)
+	return v, ok
+}
+
+func (doc *Document) GetLong(name string) (int64, bool) {
+	v, ok := doc.numericValue(name).(int64)
+	return v, ok
+}
+
+func (doc *Document) GetFloat(name string) (float32, bool) {
+	v, ok := doc.numericValue(name).(float32)
+	return v, ok
+}
+
+func (doc *Document) GetDouble(name string) (float64, bool) {
+	v, ok := doc.numericValue(name).(float64)
+	return v, ok
+}
+
 // document/DocumentStoredFieldVisitor.java
 /*
 A StoredFieldVisitor that creates a Document containing all
@@ -78,17 +142,27 @@ type DocumentStoredFieldVisitor struct {
 	fieldsToAdd map[string]bool
 }
 
-/** Load all stored fields. */
-func NewDocumentStoredFieldVisitor() *DocumentStoredFieldVisitor {
-	return &DocumentStoredFieldVisitor{
+/*
+Loads only the given stored fields, or all stored fields if none is
+given. Fields not requested are skipped in the stored fields stream
+without being decoded.
+*/
+func NewDocumentStoredFieldVisitor(fieldsToAdd ...string) *DocumentStoredFieldVisitor {
+	ans := &DocumentStoredFieldVisitor{
 		doc: NewDocument(),
 	}
+	if len(fieldsToAdd) > 0 {
+		ans.fieldsToAdd = make(map[string]bool)
+		for _, name := range fieldsToAdd {
+			ans.fieldsToAdd[name] = true
+		}
+	}
+	return ans
 }
 
 func (visitor *DocumentStoredFieldVisitor) BinaryField(fi *FieldInfo, value []byte) error {
-	panic("not implemented yet")
-	// visitor.doc.add(newStoredField(fieldInfo.name, value))
-	// return nil
+	visitor.doc.Add(NewStoredFieldFromBytes(fi.Name, value))
+	return nil
 }
 
 func (visitor *DocumentStoredFieldVisitor) StringField(fi *FieldInfo, value string) error {
@@ -102,19 +176,23 @@ func (visitor *DocumentStoredFieldVisitor) StringField(fi *FieldInfo, value stri
 }
 
 func (visitor *DocumentStoredFieldVisitor) IntField(fi *FieldInfo, value int) error {
-	panic("not implemented yet")
+	visitor.doc.Add(NewStoredFieldFromInt(fi.Name, int32(value)))
+	return nil
 }
 
 func (visitor *DocumentStoredFieldVisitor) LongField(fi *FieldInfo, value int64) error {
-	panic("not implemented yet")
+	visitor.doc.Add(NewStoredFieldFromLong(fi.Name, value))
+	return nil
 }
 
 func (visitor *DocumentStoredFieldVisitor) FloatField(fi *FieldInfo, value float32) error {
-	panic("not implemented yet")
+	visitor.doc.Add(NewStoredFieldFromFloat(fi.Name, value))
+	return nil
 }
 
 func (visitor *DocumentStoredFieldVisitor) DoubleField(fi *FieldInfo, value float64) error {
-	panic("not implemented yet")
+	visitor.doc.Add(NewStoredFieldFromDouble(fi.Name, value))
+	return nil
 }
 
 func (visitor *DocumentStoredFieldVisitor) NeedsField(fi *FieldInfo) (status StoredFieldVisitorStatus, err error) {
@@ -41,18 +41,27 @@ func NewFieldFromReader(name string, reader io.RuneReader, ft *FieldType) *Field
 // Create field with String value
 func NewFieldFromString(name, value string, ft *FieldType) *Field {
 	assert2(name != "", "name cannot be empty")
-	assert2(value != "", "value cannot be empty")
 	assert2(ft.stored || ft.indexed,
 		"it doesn't make sense to have a field that is neither indexed nor stored")
 	return &Field{_type: ft, _name: name, _data: value, _boost: 1}
 }
 
 func (f *Field) StringValue() string {
-	switch f._data.(type) {
+	switch v := f._data.(type) {
 	case string:
-		return f._data.(string)
+		return v
 	case int:
-		return strconv.Itoa(f._data.(int))
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return ""
 	default:
 		log.Println("Unknown type", f._data)
 		panic("not implemented yet")
@@ -288,12 +297,130 @@ type StoredField struct {
 	*Field
 }
 
+func newStoredField(name string, value interface{}) *StoredField {
+	assert2(name != "", "name cannot be empty")
+	return &StoredField{&Field{_type: STORED_FIELD_TYPE, _name: name, _data: value, _boost: 1}}
+}
+
 /*
 Create a stored-only field with the given binary value.
 
 NOTE: the provided byte[] is not copied so be sure
 not to change it until you're done with this field.
 */
-// func newStoredField(name string, value []byte) *StoredField {
-// 	return &StoredField{newStringField(name, value, STORED_FIELD_TYPE)}
-// }
+func NewStoredFieldFromBytes(name string, value []byte) *StoredField {
+	assert2(value != nil, "value cannot be nil")
+	return newStoredField(name, value)
+}
+
+// Create a stored-only field with the given string value.
+func NewStoredFieldFromString(name, value string) *StoredField {
+	return newStoredField(name, value)
+}
+
+// Create a stored-only field with the given int value.
+func NewStoredFieldFromInt(name string, value int32) *StoredField {
+	return newStoredField(name, value)
+}
+
+// Create a stored-only field with the given long value.
+func NewStoredFieldFromLong(name string, value int64) *StoredField {
+	return newStoredField(name, value)
+}
+
+// Create a stored-only field with the given float value.
+func NewStoredFieldFromFloat(name string, value float32) *StoredField {
+	return newStoredField(name, value)
+}
+
+// Create a stored-only field with the given double value.
+func NewStoredFieldFromDouble(name string, value float64) *StoredField {
+	return newStoredField(name, value)
+}
+
+// document/NumericDocValuesField.java
+
+/* Type for numeric DocValues. */
+var NUMERIC_DOC_VALUES_FIELD_TYPE = func() *FieldType {
+	ft := newFieldType()
+	ft._docValueType = model.DOC_VALUES_TYPE_NUMERIC
+	ft.frozen = true
+	return ft
+}()
+
+/*
+Field that stores a per-document int64 value for scoring, sorting or
+value retrieval. Here's an example usage:
+
+	doc.Add(NewNumericDocValuesField(name, 22))
+
+If you also need to store the value, you should add a separate
+StoredField instance.
+*/
+type NumericDocValuesField struct {
+	*Field
+}
+
+/* Creates a new DocValues field with the specified int64 value. */
+func NewNumericDocValuesField(name string, value int64) *NumericDocValuesField {
+	assert2(name != "", "name cannot be empty")
+	return &NumericDocValuesField{&Field{_type: NUMERIC_DOC_VALUES_FIELD_TYPE, _name: name, _data: value, _boost: 1}}
+}
+
+// document/LongPoint.java
+
+/* Type for an indexed int64 point. */
+var LONG_POINT_FIELD_TYPE = func() *FieldType {
+	ft := newFieldType()
+	ft.pointDimensionCount = 1
+	ft.frozen = true
+	return ft
+}()
+
+/*
+An indexed int64 field for fast range filters, found via
+AtomicReader.PointValues(). Here's an example usage:
+
+	doc.Add(NewLongPoint(name, 22))
+
+A document may have more than one value per field. If you also need
+to store the value, you should add a separate StoredField instance.
+*/
+type LongPoint struct {
+	*Field
+}
+
+/* Creates a new point field with the specified int64 value. */
+func NewLongPoint(name string, value int64) *LongPoint {
+	assert2(name != "", "name cannot be empty")
+	return &LongPoint{&Field{_type: LONG_POINT_FIELD_TYPE, _name: name, _data: value, _boost: 1}}
+}
+
+// document/SortedDocValuesField.java
+
+/* Type for sorted bytes DocValues */
+var SORTED_DOC_VALUES_FIELD_TYPE = func() *FieldType {
+	ft := newFieldType()
+	ft._docValueType = model.DOC_VALUES_TYPE_SORTED
+	ft.frozen = true
+	return ft
+}()
+
+/*
+Field that stores a per-document []byte value, indexed for sorting.
+Here's an example usage:
+
+	doc.Add(NewSortedDocValuesField(name, []byte("hello")))
+
+If you also need to store the value, you should add a separate
+StoredField instance.
+*/
+type SortedDocValuesField struct {
+	*Field
+}
+
+/* Creates a new sorted DocValues field. */
+func NewSortedDocValuesField(name string, bytes []byte) *SortedDocValuesField {
+	assert2(name != "", "name cannot be empty")
+	return &SortedDocValuesField{&Field{_type: SORTED_DOC_VALUES_FIELD_TYPE, _name: name, _data: bytes, _boost: 1}}
+}
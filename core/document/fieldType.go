@@ -34,6 +34,7 @@ type FieldType struct {
 	frozen                   bool
 	numericPrecisionStep     int
 	_docValueType            model.DocValuesType
+	pointDimensionCount      int
 }
 
 // Create a new mutable FieldType with all of the properties from <code>ref</code>
@@ -50,6 +51,7 @@ func NewFieldTypeFrom(ref *FieldType) *FieldType {
 	ft._indexOptions = ref._indexOptions
 	ft._docValueType = ref._docValueType
 	ft.numericType = ref.numericType
+	ft.pointDimensionCount = ref.pointDimensionCount
 	// Do not copy frozen!
 	return ft
 }
@@ -95,6 +97,35 @@ func (ft *FieldType) OmitNorms() bool                   { return ft._omitNorms }
 func (ft *FieldType) IndexOptions() model.IndexOptions  { return ft._indexOptions }
 func (ft *FieldType) NumericType() NumericType          { return ft.numericType }
 func (ft *FieldType) DocValueType() model.DocValuesType { return ft._docValueType }
+func (ft *FieldType) PointDimensionCount() int          { return ft.pointDimensionCount }
+
+/*
+Sets the indexing options for the field; any option beyond DOCS_ONLY
+also indexes the preceding ones.
+*/
+func (ft *FieldType) SetIndexOptions(v model.IndexOptions) {
+	ft.checkIfFrozen()
+	ft._indexOptions = v
+}
+
+/*
+Sets the field's DocValuesType, or 0 if no doc values should be
+stored.
+*/
+func (ft *FieldType) SetDocValueType(v model.DocValuesType) {
+	ft.checkIfFrozen()
+	ft._docValueType = v
+}
+
+/*
+Sets the number of dimensions of the field's points, or 0 if the
+field indexes no points. Only single dimension points are supported.
+*/
+func (ft *FieldType) SetPointDimensionCount(v int) {
+	ft.checkIfFrozen()
+	assert2(v == 0 || v == 1, fmt.Sprintf("only single dimension points are supported; got %v", v))
+	ft.pointDimensionCount = v
+}
 
 // Prints a Field for human consumption.
 func (ft *FieldType) String() string {
@@ -138,5 +169,11 @@ func (ft *FieldType) String() string {
 		}
 		fmt.Fprintf(&buf, "docValueType=%v", ft.DocValueType())
 	}
+	if ft.pointDimensionCount != 0 {
+		if buf.Len() > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, "pointDimensionCount=%v", ft.pointDimensionCount)
+	}
 	return buf.String()
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/balzaczyy/golucene/core/util"
 	"io"
 	"runtime/debug"
+	"sort"
 	"strconv"
 )
 
@@ -61,6 +62,33 @@ type CheckIndexStatus struct {
 
 	// Holds the userData of the last commit in the index
 	userData map[string]string
+
+	// Status of the comparison against the checksum manifest (nil if
+	// it was not requested).
+	ChecksumManifestStatus *ChecksumManifestStatus
+}
+
+/* Result of comparing the index files against the checksum manifest. */
+type ChecksumManifestStatus struct {
+	// Name of the segments_N file the manifest was written for.
+	SegmentsFileName string
+
+	// True if the manifest was written for an older commit; only the
+	// files shared with it were verified.
+	Stale bool
+
+	// Number of files whose checksum matched the manifest.
+	NumVerified int
+
+	// Files of the current commit that are not in the manifest.
+	Unverified []string
+
+	// Files whose checksum differs from the manifest, or which could
+	// not be read.
+	Mismatched []string
+
+	// Error reading the manifest, e.g. because it doesn't exist.
+	err error
 }
 
 /* Holds the status of each segment in the index. */
@@ -149,6 +177,7 @@ type CheckIndex struct {
 	dir                   store.Directory
 	crossCheckTermVectors bool
 	failFast              bool
+	verifyManifest        bool
 }
 
 func NewCheckIndex(dir store.Directory, crossCheckTermVectors bool, infoStream io.Writer) *CheckIndex {
@@ -159,6 +188,16 @@ func NewCheckIndex(dir store.Directory, crossCheckTermVectors bool, infoStream i
 	}
 }
 
+/*
+If true, CheckIndex() also recomputes the CRC32 of every file of the
+current commit and compares it against the checksum manifest written
+by IndexWriter (see IndexWriterConfig.SetWriteChecksumManifest()). This
+reads every file in full.
+*/
+func (ch *CheckIndex) SetVerifyChecksumManifest(v bool) {
+	ch.verifyManifest = v
+}
+
 func (ch *CheckIndex) msg(msg string, args ...interface{}) {
 	fmt.Fprintf(ch.infoStream, msg, args...)
 	fmt.Fprintln(ch.infoStream)
@@ -481,6 +520,14 @@ func (ch *CheckIndex) CheckIndex(onlySegments []string) *CheckIndexStatus {
 			sis.counter, result.maxSegmentName)
 	}
 
+	if ch.verifyManifest {
+		status := ch.testChecksumManifest(sis)
+		result.ChecksumManifestStatus = status
+		if status.err != nil || len(status.Mismatched) > 0 {
+			result.Clean = false
+		}
+	}
+
 	if result.Clean {
 		ch.msg("No problems were detected with this index.\n")
 	}
@@ -488,6 +535,52 @@ func (ch *CheckIndex) CheckIndex(onlySegments []string) *CheckIndexStatus {
 	return result
 }
 
+func (ch *CheckIndex) testChecksumManifest(sis *SegmentInfos) *ChecksumManifestStatus {
+	status := new(ChecksumManifestStatus)
+	ch.msg("    test: checksum manifest...")
+	manifest, err := readChecksumManifest(ch.dir)
+	if err != nil {
+		ch.msg("ERROR: could not read checksum manifest: %v", err)
+		status.err = err
+		return status
+	}
+	status.SegmentsFileName = manifest.segmentsFileName
+	status.Stale = manifest.segmentsFileName != sis.SegmentsFileName()
+
+	files := sis.files(ch.dir, true)
+	sort.Strings(files)
+	for _, file := range files {
+		expected, ok := manifest.checksums[file]
+		if !ok {
+			status.Unverified = append(status.Unverified, file)
+			continue
+		}
+		actual, err := computeFileChecksum(ch.dir, file)
+		if err != nil {
+			ch.msg("ERROR: could not checksum %v: %v", file, err)
+			status.Mismatched = append(status.Mismatched, file)
+		} else if actual != expected {
+			ch.msg("ERROR: checksum mismatch for %v: manifest=%v actual=%v",
+				file, util.ItoHex(expected), util.ItoHex(actual))
+			status.Mismatched = append(status.Mismatched, file)
+		} else {
+			status.NumVerified++
+		}
+	}
+
+	if status.Stale {
+		ch.msg("WARNING: checksum manifest was written for %v; %v files not verified",
+			status.SegmentsFileName, len(status.Unverified))
+	}
+	if len(status.Mismatched) > 0 {
+		ch.msg("FAILED [%v of %v files mismatch]",
+			len(status.Mismatched), len(status.Mismatched)+status.NumVerified)
+	} else {
+		ch.msg("OK [%v files]", status.NumVerified)
+	}
+	return status
+}
+
 func (ch *CheckIndex) testFieldNorms(reader AtomicReader) *FieldNormStatus {
 	panic("not implemented yet")
 }
@@ -0,0 +1,163 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"sort"
+)
+
+/*
+Not part of Lucene: a small side file, written by IndexWriter on
+commit if IndexWriterConfig.SetWriteChecksumManifest() is enabled, that
+records the CRC32 of every file referenced by the commit. It lets
+CheckIndex detect bit-rot by comparing against a known good value,
+while normal operation never has to re-hash the files.
+
+The recorded value is the one already stored in each file's codec
+footer, so writing the manifest only reads the last bytes of each
+file. Since index files are write-once, files shared by a later commit
+can still be verified against an older manifest.
+
+checksums --> Header, SegmentsFileName, NumFiles, <FileName, Checksum> ^NumFiles, Footer
+*/
+const (
+	CHECKSUMS_FILENAME        = "checksums"
+	CHECKSUMS_CODEC           = "checksums"
+	CHECKSUMS_VERSION_START   = 0
+	CHECKSUMS_VERSION_CURRENT = CHECKSUMS_VERSION_START
+)
+
+type checksumManifest struct {
+	segmentsFileName string
+	checksums        map[string]int64
+}
+
+/*
+Writes the checksum manifest for the given commit, replacing any
+previous one. All files must have a codec footer.
+*/
+func writeChecksumManifest(dir store.Directory, sis *SegmentInfos) (err error) {
+	files := sis.files(dir, true)
+	sort.Strings(files)
+	checksums := make([]int64, len(files))
+	for i, file := range files {
+		if checksums[i], err = retrieveFileChecksum(dir, file); err != nil {
+			return err
+		}
+	}
+
+	var out store.IndexOutput
+	if out, err = dir.CreateOutput(CHECKSUMS_FILENAME, store.IO_CONTEXT_DEFAULT); err != nil {
+		return err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = out.Close()
+		} else {
+			util.CloseWhileSuppressingError(out)
+			dir.DeleteFile(CHECKSUMS_FILENAME) // ignore error
+		}
+	}()
+
+	if err = codec.WriteHeader(out, CHECKSUMS_CODEC, CHECKSUMS_VERSION_CURRENT); err != nil {
+		return err
+	}
+	if err = out.WriteString(sis.SegmentsFileName()); err != nil {
+		return err
+	}
+	if err = out.WriteVInt(int32(len(files))); err != nil {
+		return err
+	}
+	for i, file := range files {
+		if err = out.WriteString(file); err != nil {
+			return err
+		}
+		if err = out.WriteLong(checksums[i]); err != nil {
+			return err
+		}
+	}
+	if err = codec.WriteFooter(out); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+func retrieveFileChecksum(dir store.Directory, file string) (int64, error) {
+	in, err := dir.OpenInput(file, store.IO_CONTEXT_READONCE)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	if in.Length() < codec.FOOTER_LENGTH {
+		return 0, codec.NewCorruptIndexError(fmt.Sprintf(
+			"file is too short (%v bytes) to have a codec footer", in.Length()), in)
+	}
+	return codec.RetrieveChecksum(in)
+}
+
+func readChecksumManifest(dir store.Directory) (m *checksumManifest, err error) {
+	var in store.ChecksumIndexInput
+	if in, err = dir.OpenChecksumInput(CHECKSUMS_FILENAME, store.IO_CONTEXT_READONCE); err != nil {
+		return nil, err
+	}
+	defer func() {
+		err2 := in.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	if _, err = codec.CheckHeader(in, CHECKSUMS_CODEC, CHECKSUMS_VERSION_START, CHECKSUMS_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	m = &checksumManifest{checksums: make(map[string]int64)}
+	if m.segmentsFileName, err = in.ReadString(); err != nil {
+		return nil, err
+	}
+	var numFiles int32
+	if numFiles, err = in.ReadVInt(); err != nil {
+		return nil, err
+	} else if numFiles < 0 {
+		return nil, errors.New(fmt.Sprintf("invalid file count: %v (resource: %v)", numFiles, in))
+	}
+	for i := int32(0); i < numFiles; i++ {
+		var file string
+		if file, err = in.ReadString(); err != nil {
+			return nil, err
+		}
+		if m.checksums[file], err = in.ReadLong(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = codec.CheckFooter(in); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+/*
+Computes the CRC32 of the file's content before the checksum in its
+footer, i.e. the value WriteFooter() recorded when the file was
+written, by reading the whole file.
+*/
+func computeFileChecksum(dir store.Directory, file string) (int64, error) {
+	in, err := dir.OpenChecksumInput(file, store.IO_CONTEXT_READONCE)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	if in.Length() < codec.FOOTER_LENGTH {
+		return 0, codec.NewCorruptIndexError(fmt.Sprintf(
+			"file is too short (%v bytes) to have a codec footer", in.Length()), in)
+	}
+	// checksum covers everything up to the checksum value itself
+	if err = in.Seek(in.Length() - 8); err != nil {
+		return 0, err
+	}
+	return in.Checksum(), nil
+}
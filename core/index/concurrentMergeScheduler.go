@@ -1,21 +1,19 @@
 package index
 
 import (
+	"errors"
 	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
 	"log"
+	"math"
+	"runtime"
+	"sort"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
 // index/ConcurrentMergeScheduler.java
 
-type MergeJob struct {
-	start  time.Time
-	writer *IndexWriter
-	merge  *OneMerge
-}
-
 /*
 Default maxThreadCount. We default to 1: tests on spinning-magnet
 drives showed slower indexing performance if more than one merge
@@ -26,6 +24,38 @@ const DEFAULT_MAX_ROUTINE_COUNT = 1
 // Default maxMergeCount.
 const DEFAULT_MAX_MERGE_COUNT = 2
 
+const (
+	// Initial merge IO rate limit in auto mode, in MB/sec.
+	START_MB_PER_SEC = 20.0
+	// Floor for the merge IO rate limit in auto mode, in MB/sec.
+	MIN_MERGE_MB_PER_SEC = 5.0
+	// Ceiling for the merge IO rate limit in auto mode, in MB/sec.
+	MAX_MERGE_MB_PER_SEC = 10240.0
+)
+
+/*
+Returns the maxMergeCount and maxRoutineCount IndexWriter picks for
+storage that does (spins) or doesn't spin. Spinning disks get a
+single merge routine, since concurrent merges just thrash the heads;
+SSDs and RAM get up to 4 routines depending on available CPUs.
+*/
+func DefaultMaxMergesAndRoutines(spins bool) (maxMergeCount, maxRoutineCount int) {
+	return defaultMaxMergesAndRoutines(spins, runtime.NumCPU())
+}
+
+func defaultMaxMergesAndRoutines(spins bool, numCPU int) (maxMergeCount, maxRoutineCount int) {
+	if spins {
+		return 6, 1
+	}
+	maxRoutineCount = numCPU / 2
+	if maxRoutineCount > 4 {
+		maxRoutineCount = 4
+	} else if maxRoutineCount < 1 {
+		maxRoutineCount = 1
+	}
+	return maxRoutineCount + 5, maxRoutineCount
+}
+
 /*
 A MergeScheduler that runs each merge using a separate goroutine.
 
@@ -53,75 +83,129 @@ type ConcurrentMergeScheduler struct {
 	// incoming routines
 	maxMergeCount int
 
+	// Buffer size in bytes for merge inputs and outputs; 0 for the
+	// IOContext's default.
+	mergeBufferSize int
+
 	// IndexWriter that owns this instance.
 	writer *IndexWriter
 
 	// How many merges have kicked off (this is used to name them).
-	mergeThreadCount int32 // atomic
+	mergeThreadCount int
 
 	suppressErrors bool
 
-	chRequest            chan *MergeJob
-	chSync               chan *sync.WaitGroup
-	concurrentMergeCount int32 // atomic
-	numMergeRoutines     int32 // atomic
+	// Max total estimated bytes of merges running at once; 0 for no
+	// limit. Guarded by budgetCond.L, not by the scheduler's lock,
+	// which Merge() holds while handing out merges.
+	maxConcurrentMergeBytes int64
+	// Bytes of the merges holding budget which are not paused: a
+	// paused merge doesn't count, or it would keep the smaller merges
+	// it was paused for from starting.
+	runningMergeBytes int64
+	budgetMerges      map[*OneMerge]bool // merges holding budget
+	budgetCond        *sync.Cond
+
+	// Shared by the writes of all merges; nil for no limit.
+	mergeRateLimiter *store.SimpleRateLimiter
+	// Whether the rate limit follows the merge backlog.
+	autoIOThrottle bool
+
+	// Routines of the merges started and not yet finished, whether
+	// running or paused.
+	mergeRoutines []*mergeRoutine
+	// Signaled whenever a merge routine finishes.
+	routineDone *sync.Cond
 }
 
 func NewConcurrentMergeScheduler() *ConcurrentMergeScheduler {
+	lock := &sync.Mutex{}
 	cms := &ConcurrentMergeScheduler{
-		Locker:    &sync.Mutex{},
-		chRequest: make(chan *MergeJob),
-		chSync:    make(chan *sync.WaitGroup),
+		Locker:       lock,
+		budgetMerges: make(map[*OneMerge]bool),
+		budgetCond:   sync.NewCond(&sync.Mutex{}),
+		routineDone:  sync.NewCond(lock),
 	}
-	cms.SetMaxMergesAndRoutines(DEFAULT_MAX_MERGE_COUNT, DEFAULT_MAX_ROUTINE_COUNT)
+	cms.maxMergeCount, cms.maxRoutineCount = DEFAULT_MAX_MERGE_COUNT, DEFAULT_MAX_ROUTINE_COUNT
 	return cms
 }
 
-/*
-Daemon worker that accepts and processes merge job.
-
-GoLucene assumes each merge is pre-sorted according to its merge size
-before acquired from IndexWriter. It makes use of pre-allocated
-go routines, instead of MergeThread to do the real merge work,
-witout explicit synchronizations and waitings.
+/* A goroutine running a single merge. */
+type mergeRoutine struct {
+	id     int
+	writer *IndexWriter
+	merge  *OneMerge
+}
 
-Note, however, change of merge count won't pause/resume workers.
-*/
-func (cms *ConcurrentMergeScheduler) worker(id int) {
-	atomic.AddInt32(&cms.numMergeRoutines, 1)
-	fmt.Printf("CMS Worker %v is started.\n", id)
-	var isRunning = true
-	var wg *sync.WaitGroup
-	for isRunning && id < cms.maxRoutineCount {
-		select {
-		case job := <-cms.chRequest:
-			cms.process(job)
-		case wg = <-cms.chSync:
-			isRunning = false
-			defer wg.Done()
-		}
-	}
-	fmt.Printf("CMS Worker %v is stopped.\n", id)
-	atomic.AddInt32(&cms.numMergeRoutines, -1)
+func (r *mergeRoutine) String() string {
+	return fmt.Sprintf("Lucene Merge Routine #%v", r.id)
 }
 
-func (cms *ConcurrentMergeScheduler) process(job *MergeJob) {
-	atomic.AddInt32(&cms.concurrentMergeCount, 1)
+func (cms *ConcurrentMergeScheduler) run(r *mergeRoutine) {
 	defer func() {
-		atomic.AddInt32(&cms.concurrentMergeCount, -1)
+		cms.Lock()
+		defer cms.Unlock()
+		for i, v := range cms.mergeRoutines {
+			if v == r {
+				cms.mergeRoutines = append(cms.mergeRoutines[:i], cms.mergeRoutines[i+1:]...)
+				break
+			}
+		}
+		// let another paused merge run, or a stalled Merge() go on
+		cms.updateMergeRoutines()
+		cms.routineDone.Broadcast()
 	}()
 
 	if cms.verbose() {
-		elapsed := time.Now().Sub(job.start)
-		cms.message("  stalled for %v", elapsed)
-		cms.message("  consider merge %v", job.writer.readerPool.segmentsToString(job.merge.segments))
-		// OK to spawn a new merge routine to handle this merge
-		cms.message("    launch new thread [%v]", atomic.AddInt32(&cms.mergeThreadCount, 1))
-		cms.message("  merge thread: start")
+		cms.message("  %v: start", r)
+	}
+
+	for {
+		cms.doMerge(r.writer, r.merge)
+
+		// Subsequent merges, e.g. those the merge policy found once
+		// this one finished:
+		merge := r.writer.nextMerge()
+		if merge == nil {
+			break
+		}
+		cms.Lock()
+		r.merge = merge
+		cms.updateMergeRoutines()
+		cms.Unlock()
+		if cms.verbose() {
+			cms.message("  %v: merge %v", r, r.writer.readerPool.segmentsToString(merge.segments))
+		}
+	}
+	if cms.verbose() {
+		cms.message("  %v: done", r)
+	}
+}
+
+/* Does the actual merge, by calling IndexWriter.merge(). */
+func (cms *ConcurrentMergeScheduler) doMerge(writer *IndexWriter, merge *OneMerge) {
+	if cms.MaxConcurrentMergeMB() > 0 {
+		if err := merge.estimateMergeBytes(writer.readerPool); err != nil {
+			cms.handleMergeError(err)
+			// IndexWriter.merge() still has to release the merge
+			merge.abort()
+		} else {
+			cms.acquireMergeBytes(merge)
+			defer cms.releaseMergeBytes(merge)
+		}
+	}
+
+	if limiter := cms.rateLimiter(); limiter != nil {
+		dir := store.NewRateLimitedDirectoryWrapper(writer.directory)
+		dir.SetRateLimiter(limiter, store.IO_CONTEXT_TYPE_MERGE)
+		merge.mergeDirectory = dir
+		defer func() { merge.mergeDirectory = nil }()
 	}
+	merge.mergeContext = cms.mergeContext(merge)
 
-	err := job.writer.merge(job.merge)
-	if err != nil {
+	// IndexWriter.merge() waits while the merge is paused in favor of
+	// smaller ones, and always releases the merge, even aborted.
+	if err := writer.merge(merge); err != nil {
 		// Ignore the error if it was due to abort:
 		if _, ok := err.(MergeAbortedError); !ok && !cms.suppressErrors {
 			// suppressErrors is normally only set during testing.
@@ -130,23 +214,284 @@ func (cms *ConcurrentMergeScheduler) process(job *MergeJob) {
 	}
 }
 
-// Sets the maximum number of merge goroutines and simultaneous
-// merges allowed.
-func (cms *ConcurrentMergeScheduler) SetMaxMergesAndRoutines(maxMergeCount, maxRoutineCount int) {
-	assert2(maxRoutineCount >= 1, "maxRoutineCount should be at least 1")
-	assert2(maxMergeCount >= 1, "maxMergeCount should be at least 1")
-	assert2(maxRoutineCount <= maxMergeCount, fmt.Sprintf(
-		"maxRoutineCount should be <= maxMergeCount (= %v)", maxMergeCount))
+type byMergeDocCountDescending []*mergeRoutine
 
-	oldCount := cms.maxRoutineCount
+func (a byMergeDocCountDescending) Len() int      { return len(a) }
+func (a byMergeDocCountDescending) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byMergeDocCountDescending) Less(i, j int) bool {
+	return a[i].merge.totalDocCount > a[j].merge.totalDocCount
+}
+
+/*
+Called whenever the running merges have changed, to pause & unpause
+routines. Only the maxRoutineCount smallest merges run; the larger
+ones are paused until one of those completes. Must be called with the
+lock held.
+*/
+func (cms *ConcurrentMergeScheduler) updateMergeRoutines() {
+	// Sort the merge routines in descending order of merge size.
+	routines := make([]*mergeRoutine, len(cms.mergeRoutines))
+	copy(routines, cms.mergeRoutines)
+	sort.Stable(byMergeDocCountDescending(routines))
+
+	for i, r := range routines {
+		doPause := i < len(routines)-cms.maxRoutineCount
+		if doPause != r.merge.isPaused() {
+			if cms.verbose() {
+				if doPause {
+					cms.message("pause %v", r)
+				} else {
+					cms.message("unpause %v", r)
+				}
+			}
+			cms.setPause(r.merge, doPause)
+		}
+	}
+}
+
+// Pauses or resumes the merge, taking its bytes out of or back into the budget.
+func (cms *ConcurrentMergeScheduler) setPause(merge *OneMerge, paused bool) {
+	cms.budgetCond.L.Lock()
+	defer cms.budgetCond.L.Unlock()
+	merge.setPause(paused)
+	if cms.budgetMerges[merge] {
+		if paused {
+			cms.runningMergeBytes -= merge.estimatedMergeBytes
+			cms.budgetCond.Broadcast()
+		} else {
+			cms.runningMergeBytes += merge.estimatedMergeBytes
+		}
+	}
+}
+
+/*
+Sets the maximum number of merge goroutines and simultaneous merges
+allowed. maxMergeCount is the max number of merges accepted before
+forcefully throttling the incoming routines, and maxRoutineCount the
+max number of merge routines that may run at once, which must be <=
+maxMergeCount. Merges already started are paused or resumed to honor
+the new settings.
+*/
+func (cms *ConcurrentMergeScheduler) SetMaxMergesAndRoutines(maxMergeCount, maxRoutineCount int) error {
+	if maxRoutineCount < 1 {
+		return errors.New(fmt.Sprintf("maxRoutineCount should be at least 1 (got %v)", maxRoutineCount))
+	}
+	if maxMergeCount < 1 {
+		return errors.New(fmt.Sprintf("maxMergeCount should be at least 1 (got %v)", maxMergeCount))
+	}
+	if maxRoutineCount > maxMergeCount {
+		return errors.New(fmt.Sprintf("maxRoutineCount should be <= maxMergeCount (= %v)", maxMergeCount))
+	}
+
+	cms.Lock()
+	defer cms.Unlock()
 	cms.maxRoutineCount = maxRoutineCount
 	cms.maxMergeCount = maxMergeCount
+	cms.updateMergeRoutines()
+	// a larger maxMergeCount may let a stalled Merge() go on
+	cms.routineDone.Broadcast()
+	return nil
+}
+
+/*
+Sets max merges and routines to the defaults for storage that does
+(spins) or doesn't spin, and the number of CPUs of this machine. See
+DefaultMaxMergesAndRoutines().
+*/
+func (cms *ConcurrentMergeScheduler) SetDefaultMaxMergesAndRoutines(spins bool) {
+	err := cms.SetMaxMergesAndRoutines(DefaultMaxMergesAndRoutines(spins))
+	assert(err == nil)
+}
+
+/*
+Limits the write rate of all running merges together to the given
+MB/sec, so merges don't starve searches of IO. Writes are paused
+whenever they get ahead of the rate. 0 means no limit, the default.
+This turns the auto IO throttle off.
+
+The new rate applies to running merges too.
+*/
+func (cms *ConcurrentMergeScheduler) SetMaxMergeMBPerSec(mbPerSec float64) {
+	assert2(mbPerSec >= 0, "maxMergeMBPerSec must be >= 0 (got %v)", mbPerSec)
+	cms.Lock()
+	defer cms.Unlock()
+	cms.autoIOThrottle = false
+	cms.setMergeMBPerSec(mbPerSec)
+}
+
+func (cms *ConcurrentMergeScheduler) setMergeMBPerSec(mbPerSec float64) {
+	if mbPerSec == 0 {
+		if cms.mergeRateLimiter != nil {
+			// release merges still holding the limiter
+			cms.mergeRateLimiter.SetMbPerSec(math.MaxFloat64)
+			cms.mergeRateLimiter = nil
+		}
+	} else if cms.mergeRateLimiter != nil {
+		cms.mergeRateLimiter.SetMbPerSec(mbPerSec)
+	} else {
+		cms.mergeRateLimiter = store.NewSimpleRateLimiter(mbPerSec)
+	}
+}
 
+/*
+Returns the current write rate limit of merges in MB/sec, or 0 if
+there is none. In auto mode, the rate changes as merges start.
+*/
+func (cms *ConcurrentMergeScheduler) MaxMergeMBPerSec() float64 {
+	if limiter := cms.rateLimiter(); limiter != nil {
+		return limiter.MbPerSec()
+	}
+	return 0
+}
+
+func (cms *ConcurrentMergeScheduler) rateLimiter() *store.SimpleRateLimiter {
+	cms.Lock()
+	defer cms.Unlock()
+	return cms.mergeRateLimiter
+}
+
+/*
+Turn on dynamic IO throttling, to adaptively rate limit writes bytes/sec
+to the minimal rate necessary so merges do not fall behind. Starting
+at START_MB_PER_SEC, the rate goes up by 20% whenever a merge starts
+while others have to wait, and down by 10% whenever a merge starts
+alone, between MIN_MERGE_MB_PER_SEC and MAX_MERGE_MB_PER_SEC.
+*/
+func (cms *ConcurrentMergeScheduler) EnableAutoIOThrottle() {
+	cms.Lock()
+	defer cms.Unlock()
+	cms.autoIOThrottle = true
+	cms.setMergeMBPerSec(START_MB_PER_SEC)
+}
+
+/* Turn off auto IO throttling, which also removes the rate limit. */
+func (cms *ConcurrentMergeScheduler) DisableAutoIOThrottle() {
+	cms.SetMaxMergeMBPerSec(0)
+}
+
+/* Returns true if auto IO throttling is enabled. */
+func (cms *ConcurrentMergeScheduler) AutoIOThrottle() bool {
 	cms.Lock()
 	defer cms.Unlock()
-	for i := oldCount; i < maxRoutineCount; i++ {
-		go cms.worker(i)
+	return cms.autoIOThrottle
+}
+
+/*
+Simplistic closed-loop feedback control, called when a new merge is
+started: if merges have to wait for a routine, we are falling behind,
+so we bump up the IO throttle; if the new merge runs alone, we lower
+it. Must be called with the lock held.
+*/
+func (cms *ConcurrentMergeScheduler) updateIOThrottle() {
+	if !cms.autoIOThrottle {
+		return
+	}
+	curMBPerSec := cms.mergeRateLimiter.MbPerSec()
+	newMBPerSec := curMBPerSec
+	if len(cms.mergeRoutines) > cms.maxRoutineCount {
+		newMBPerSec = math.Min(curMBPerSec*1.20, MAX_MERGE_MB_PER_SEC)
+	} else if len(cms.mergeRoutines) <= 1 {
+		newMBPerSec = math.Max(curMBPerSec/1.10, MIN_MERGE_MB_PER_SEC)
 	}
+	if newMBPerSec != curMBPerSec {
+		if cms.verbose() {
+			cms.message("updateIOThrottle: %v merges; %.1f -> %.1f MB/sec",
+				len(cms.mergeRoutines), curMBPerSec, newMBPerSec)
+		}
+		cms.mergeRateLimiter.SetMbPerSec(newMBPerSec)
+	}
+}
+
+/*
+Sets the size of the buffers used to read and write segments while
+merging. Larger buffers mean fewer, longer I/Os, which mostly helps
+spinning disks. 0 restores the default (store.MERGE_BUFFER_SIZE).
+*/
+func (cms *ConcurrentMergeScheduler) SetMergeBufferSizeMB(mb float64) {
+	assert2(mb >= 0, "mergeBufferSizeMB must be >= 0 (got %v)", mb)
+	size := mb * 1024 * 1024
+	assert2(size <= math.MaxInt32, "mergeBufferSizeMB is too large (got %v)", mb)
+	assert2(size == 0 || size >= store.MIN_BUFFER_SIZE,
+		"mergeBufferSizeMB is too small (got %v)", mb)
+	cms.mergeBufferSize = int(size)
+}
+
+// Returns the merge buffer size in MB, or 0 if the default is used.
+func (cms *ConcurrentMergeScheduler) MergeBufferSizeMB() float64 {
+	return float64(cms.mergeBufferSize) / 1024 / 1024
+}
+
+/*
+Caps the total estimated size of merges running at once, so several
+large merges don't compete for IO. A merge that would exceed the
+budget waits, holding its merge routine, until enough running merges
+finish; further merges then queue up in Merge() as usual. A merge
+larger than the whole budget still runs, but alone. Paused merges
+don't count against the budget. 0 (the default) means no limit.
+*/
+func (cms *ConcurrentMergeScheduler) SetMaxConcurrentMergeMB(mb float64) {
+	assert2(mb >= 0, "maxConcurrentMergeMB must be >= 0 (got %v)", mb)
+	cms.budgetCond.L.Lock()
+	defer cms.budgetCond.L.Unlock()
+	cms.maxConcurrentMergeBytes = int64(mb * 1024 * 1024)
+	cms.budgetCond.Broadcast()
+}
+
+// Returns the merge byte budget in MB, or 0 if there is none.
+func (cms *ConcurrentMergeScheduler) MaxConcurrentMergeMB() float64 {
+	cms.budgetCond.L.Lock()
+	defer cms.budgetCond.L.Unlock()
+	return float64(cms.maxConcurrentMergeBytes) / 1024 / 1024
+}
+
+/*
+Waits until the merge fits into the byte budget, then accounts it.
+The bytes of a merge only count while it's not paused.
+*/
+func (cms *ConcurrentMergeScheduler) acquireMergeBytes(merge *OneMerge) {
+	cms.budgetCond.L.Lock()
+	defer cms.budgetCond.L.Unlock()
+	for cms.maxConcurrentMergeBytes > 0 && cms.runningMergeBytes > 0 &&
+		cms.runningMergeBytes+merge.estimatedMergeBytes > cms.maxConcurrentMergeBytes {
+		if cms.verbose() {
+			cms.message("    merge of %v bytes exceeds budget (%v of %v bytes running); waiting...",
+				merge.estimatedMergeBytes, cms.runningMergeBytes, cms.maxConcurrentMergeBytes)
+		}
+		cms.budgetCond.Wait()
+	}
+	cms.budgetMerges[merge] = true
+	if !merge.isPaused() {
+		cms.runningMergeBytes += merge.estimatedMergeBytes
+	}
+}
+
+func (cms *ConcurrentMergeScheduler) releaseMergeBytes(merge *OneMerge) {
+	cms.budgetCond.L.Lock()
+	defer cms.budgetCond.L.Unlock()
+	delete(cms.budgetMerges, merge)
+	if !merge.isPaused() {
+		cms.runningMergeBytes -= merge.estimatedMergeBytes
+	}
+	cms.budgetCond.Broadcast()
+}
+
+// Returns the IOContext merges run by this scheduler should use.
+func (cms *ConcurrentMergeScheduler) mergeContext(merge *OneMerge) store.IOContext {
+	return store.NewIOContextForMerge(merge.MergeInfo()).WithBufferSize(cms.mergeBufferSize)
+}
+
+// Returns maxRoutineCount.
+func (cms *ConcurrentMergeScheduler) MaxRoutineCount() int {
+	cms.Lock()
+	defer cms.Unlock()
+	return cms.maxRoutineCount
+}
+
+// Returns maxMergeCount.
+func (cms *ConcurrentMergeScheduler) MaxMergeCount() int {
+	cms.Lock()
+	defer cms.Unlock()
+	return cms.maxMergeCount
 }
 
 /*
@@ -175,20 +520,25 @@ func (cms *ConcurrentMergeScheduler) Close() error {
 }
 
 /*
-Wait for any running merge threads to finish. This call is not
-Interruptible as used by Close()
+Wait for any running merge routines to finish. This call is not
+interruptible as used by Close()
 */
 func (cms *ConcurrentMergeScheduler) sync() {
 	cms.Lock()
 	defer cms.Unlock()
-
-	wg := new(sync.WaitGroup)
-	// no need to synchronize on numMergeRoutines
-	for i, limit := 0, int(cms.numMergeRoutines); i < limit; i++ {
-		wg.Add(1)
-		cms.chSync <- wg
+	for len(cms.mergeRoutines) > 0 {
+		if cms.verbose() {
+			cms.message("now wait for %v merge routines", len(cms.mergeRoutines))
+		}
+		cms.routineDone.Wait()
 	}
-	wg.Wait()
+}
+
+/* Returns the number of merge routines started and not yet finished. */
+func (cms *ConcurrentMergeScheduler) mergeRoutineCount() int {
+	cms.Lock()
+	defer cms.Unlock()
+	return len(cms.mergeRoutines)
 }
 
 func (cms *ConcurrentMergeScheduler) Merge(writer *IndexWriter,
@@ -197,37 +547,53 @@ func (cms *ConcurrentMergeScheduler) Merge(writer *IndexWriter,
 	defer cms.Unlock()
 
 	// assert !Thread.holdsLock(writer)
-	cms.writer = writer
-
-	// First, quickly run through the newly proposed merges
-	// and add any orthogonal merges (ie a merge not
-	// involving segments already pending to be merged) to
-	// the queue.  If we are way behind on merging, many of
-	// these newly proposed merges will likely already be
-	// registered.
+	if cms.writer != writer {
+		cms.writer = writer
+	}
+
 	if cms.verbose() {
 		cms.message("now merge")
 		cms.message("  index: %v", writer.segString())
 	}
 
-	// Iterate, pulling from the IndexWriter's queue of
-	// pending merges, until it's empty:
+	// Iterate, pulling from the IndexWriter's queue of pending merges,
+	// until it's empty:
 	for merge := writer.nextMerge(); merge != nil; merge = writer.nextMerge() {
-		if atomic.LoadInt32(&cms.concurrentMergeCount) >= int32(cms.maxMergeCount) {
-			// This means merging has fallen too far behind: we
-			// have already created maxMergeCount threads, and
-			// now there's at least one more merge pending.
-			// Note that only maxThreadCount of
-			// those created merge threads will actually be
-			// running; the rest will be paused (see
-			// updateMergeThreads).  We stall this producer
-			// thread to prevent creation of new segments,
-			// until merging has caught up:
+		if len(cms.mergeRoutines) >= cms.maxMergeCount {
+			// This means merging has fallen too far behind: we have
+			// already created maxMergeCount routines, and now there's at
+			// least one more merge pending. Note that only
+			// maxRoutineCount of those created merge routines will
+			// actually be running; the rest will be paused (see
+			// updateMergeRoutines). We stall this producer routine to
+			// prevent creation of new segments, until merging has caught
+			// up:
 			if cms.verbose() {
 				cms.message("    too many merges; stalling...")
 			}
+			start := time.Now()
+			for len(cms.mergeRoutines) >= cms.maxMergeCount {
+				cms.routineDone.Wait()
+			}
+			if cms.verbose() {
+				cms.message("  stalled for %v", time.Now().Sub(start))
+			}
 		}
-		cms.chRequest <- &MergeJob{time.Now(), writer, merge}
+
+		if cms.verbose() {
+			cms.message("  consider merge %v", writer.readerPool.segmentsToString(merge.segments))
+		}
+
+		// OK to spawn a new merge routine to handle this merge
+		cms.mergeThreadCount++
+		r := &mergeRoutine{cms.mergeThreadCount, writer, merge}
+		if cms.verbose() {
+			cms.message("    launch new routine [%v]", r)
+		}
+		cms.mergeRoutines = append(cms.mergeRoutines, r)
+		cms.updateIOThrottle()
+		cms.updateMergeRoutines()
+		go cms.run(r)
 	}
 	if cms.verbose() {
 		cms.message("  no more merges pending; now return")
@@ -251,6 +617,27 @@ func (cms *ConcurrentMergeScheduler) handleMergeError(err error) {
 	log.Printf("Merge error: %v", err)
 }
 
+/*
+Returns a new scheduler with the same settings, independent of the
+IndexWriter and the merges of this one.
+*/
+func (cms *ConcurrentMergeScheduler) Clone() MergeScheduler {
+	ans := NewConcurrentMergeScheduler()
+	ans.maxMergeCount, ans.maxRoutineCount = cms.MaxMergeCount(), cms.MaxRoutineCount()
+	ans.mergeBufferSize = cms.mergeBufferSize
+	ans.maxConcurrentMergeBytes = int64(cms.MaxConcurrentMergeMB() * 1024 * 1024)
+	ans.suppressErrors = cms.suppressErrors
+	if cms.AutoIOThrottle() {
+		ans.EnableAutoIOThrottle()
+	} else {
+		ans.SetMaxMergeMBPerSec(cms.MaxMergeMBPerSec())
+	}
+	return ans
+}
+
 func (cms *ConcurrentMergeScheduler) String() string {
-	panic("not implemented yet")
+	cms.Lock()
+	defer cms.Unlock()
+	return fmt.Sprintf("ConcurrentMergeScheduler: maxRoutineCount=%v, maxMergeCount=%v, mergeBufferSizeMB=%v",
+		cms.maxRoutineCount, cms.maxMergeCount, float64(cms.mergeBufferSize)/1024/1024)
 }
@@ -1,7 +1,10 @@
 package index
 
 import (
+	"errors"
+	"fmt"
 	"github.com/balzaczyy/golucene/core/analysis"
+	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
 )
 
@@ -79,12 +82,27 @@ conveniently, for example:
 type IndexWriterConfig struct {
 	*LiveIndexWriterConfigImpl
 	writer *util.SetOnce
+	// true if SetMergeScheduler() was called
+	mergeSchedulerSet     bool
+	commitHook            CommitHook
+	writeChecksumManifest bool
 }
 
-// Sets the IndexWriter this config is attached to.
-func (conf *IndexWriterConfig) setIndexWriter(writer *IndexWriter) *IndexWriterConfig {
-	conf.writer.Set(writer)
-	return conf
+/*
+Called by IndexWriter after each successful commit, with the
+generation of the new segments_N file and the commit's user data.
+*/
+type CommitHook func(generation int64, userData map[string]string) error
+
+/*
+Sets the IndexWriter this config is attached to. Returns an error if
+the config is already used by another IndexWriter.
+*/
+func (conf *IndexWriterConfig) setIndexWriter(writer *IndexWriter) error {
+	if !conf.writer.TrySet(writer) {
+		return errors.New("do not share IndexWriterConfig instances across IndexWriters")
+	}
+	return nil
 }
 
 // L523
@@ -139,6 +157,24 @@ func (conf *IndexWriterConfig) SetIndexDeletionPolicy(delPolicy IndexDeletionPol
 	return conf
 }
 
+/* Returns the IndexDeletionPolicy specified in SetIndexDeletionPolicy(). */
+func (conf *IndexWriterConfig) IndexDeletionPolicy() IndexDeletionPolicy {
+	return conf.delPolicy
+}
+
+/* Specifies OpenMode of the index. Only takes effect when IndexWriter is first created. */
+func (conf *IndexWriterConfig) SetOpenMode(openMode OpenMode) *IndexWriterConfig {
+	assert2(openMode >= OPEN_MODE_CREATE && openMode <= OPEN_MODE_CREATE_OR_APPEND,
+		"illegal open mode: %v", int(openMode))
+	conf.openMode = openMode
+	return conf
+}
+
+/* Returns the OpenMode set by SetOpenMode(). */
+func (conf *IndexWriterConfig) OpenMode() OpenMode {
+	return conf.openMode
+}
+
 type Similarity interface {
 	ComputeNorm(fs *FieldInvertState) int64
 }
@@ -159,7 +195,8 @@ func (conf *IndexWriterConfig) SetSimilarity(similarity Similarity) *IndexWriter
 
 /*
 Expert: sets the merge scheduler used by this writer. The default is
-ConcurentMergeScheduler.
+ConcurentMergeScheduler, tuned by IndexWriter for the directory's
+storage (see DefaultMaxMergesAndRoutines()).
 
 NOTE: the merge scheduler cannot be nil.
 
@@ -168,6 +205,52 @@ Only takes effect when IndexWriter is first created.
 func (conf *IndexWriterConfig) SetMergeScheduler(mergeScheduler MergeScheduler) *IndexWriterConfig {
 	assert2(mergeScheduler != nil, "mergeScheduler must not be nil")
 	conf.mergeScheduler = mergeScheduler
+	conf.mergeSchedulerSet = true
+	return conf
+}
+
+/* Returns the MergeScheduler that was set by SetMergeScheduler(). */
+func (conf *IndexWriterConfig) MergeScheduler() MergeScheduler {
+	return conf.mergeScheduler
+}
+
+/*
+Unless a merge scheduler was set explicitly, tunes the default
+ConcurrentMergeScheduler for the storage backing d: conservative on
+spinning disks, more concurrent on SSDs and RAM.
+*/
+func (conf *IndexWriterConfig) initMergeScheduler(d store.Directory) {
+	if conf.mergeSchedulerSet {
+		return
+	}
+	if cms, ok := conf.mergeScheduler.(*ConcurrentMergeScheduler); ok {
+		cms.SetDefaultMaxMergesAndRoutines(store.Spins(d))
+	}
+}
+
+/*
+Sets a hook IndexWriter calls after each successful commit, e.g. to
+trigger replication or invalidate caches. The hook is not called on
+rollback. Since the commit is already durable when the hook runs, an
+error returned by the hook is logged but doesn't undo the commit.
+
+Only takes effect when IndexWriter is first created.
+*/
+func (conf *IndexWriterConfig) SetCommitHook(hook CommitHook) *IndexWriterConfig {
+	conf.commitHook = hook
+	return conf
+}
+
+/*
+If true, IndexWriter writes a CHECKSUMS_FILENAME side file on each
+commit, recording the CRC32 of every file the commit references, which
+CheckIndex can later compare against (see
+CheckIndex.SetVerifyChecksumManifest()). Off by default.
+
+Only takes effect when IndexWriter is first created.
+*/
+func (conf *IndexWriterConfig) SetWriteChecksumManifest(writeChecksumManifest bool) *IndexWriterConfig {
+	conf.writeChecksumManifest = writeChecksumManifest
 	return conf
 }
 
@@ -201,6 +284,16 @@ func (conf *IndexWriterConfig) SetMaxBufferedDocs(maxBufferedDocs int) *IndexWri
 	return conf
 }
 
+func (conf *IndexWriterConfig) SetRAMBufferSizeMB(ramBufferSizeMB float64) *IndexWriterConfig {
+	conf.LiveIndexWriterConfigImpl.SetRAMBufferSizeMB(ramBufferSizeMB)
+	return conf
+}
+
+func (conf *IndexWriterConfig) SetMergePolicy(mergePolicy MergePolicy) *IndexWriterConfig {
+	conf.LiveIndexWriterConfigImpl.SetMergePolicy(mergePolicy)
+	return conf
+}
+
 func (conf *IndexWriterConfig) SetMergedSegmentWarmer(mergeSegmentWarmer IndexReaderWarmer) *IndexWriterConfig {
 	conf.LiveIndexWriterConfigImpl.SetMergedSegmentWarmer(mergeSegmentWarmer)
 	return conf
@@ -217,5 +310,6 @@ func (conf *IndexWriterConfig) SetUseCompoundFile(useCompoundFile bool) *IndexWr
 }
 
 func (conf *IndexWriterConfig) String() string {
-	panic("not implemented yet")
+	return fmt.Sprintf("%vwriter=%v\n", conf.LiveIndexWriterConfigImpl,
+		conf.writer.Get() != nil)
 }
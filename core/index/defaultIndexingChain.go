@@ -69,6 +69,9 @@ func (c *DefaultIndexingChain) flush(state *SegmentWriteState) (err error) {
 	if err = c.writeDocValues(state); err != nil {
 		return
 	}
+	if err = c.writePoints(state); err != nil {
+		return
+	}
 
 	// it's possible all docs hit non-aboritng errors...
 	if err = c.initStoredFieldsWriter(); err != nil {
@@ -110,11 +113,13 @@ func (c *DefaultIndexingChain) writeDocValues(state *SegmentWriteState) (err err
 	docCount := state.SegmentInfo.DocCount()
 	var dvConsumer DocValuesConsumer
 	var success = false
-	if success {
-		err = util.Close(dvConsumer)
-	} else {
-		util.CloseWhileSuppressingError(dvConsumer)
-	}
+	defer func() {
+		if success {
+			err = util.Close(dvConsumer)
+		} else {
+			util.CloseWhileSuppressingError(dvConsumer)
+		}
+	}()
 
 	for _, perField := range c.fieldHash {
 		for perField != nil {
@@ -141,6 +146,42 @@ func (c *DefaultIndexingChain) writeDocValues(state *SegmentWriteState) (err err
 	return nil
 }
 
+/* Writes all buffered points (called from flush()) */
+func (c *DefaultIndexingChain) writePoints(state *SegmentWriteState) (err error) {
+	var pointsWriter PointsWriter
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(pointsWriter)
+		} else {
+			util.CloseWhileSuppressingError(pointsWriter)
+		}
+	}()
+
+	for _, perField := range c.fieldHash {
+		for perField != nil {
+			if perField.pointValuesWriter != nil {
+				if pointsWriter == nil {
+					// lazy init
+					fmt := state.SegmentInfo.Codec().(Codec).PointsFormat()
+					if pointsWriter, err = fmt.FieldsWriter(state); err != nil {
+						return
+					}
+				}
+
+				if err = perField.pointValuesWriter.flush(pointsWriter); err != nil {
+					return
+				}
+				perField.pointValuesWriter = nil
+			}
+			perField = perField.next
+		}
+	}
+
+	success = true
+	return nil
+}
+
 /*
 Catch up for all docs before us that had no stored fields, or hit
 non-aborting errors before writing stored fields.
@@ -354,14 +395,12 @@ func (c *DefaultIndexingChain) processField(field IndexableField,
 			fieldCount++
 			fp.fieldGen = fieldGen
 		}
-	} else {
-		panic("not implemented yet")
 	}
 
 	// Add stored fields:
 	if fieldType.Stored() {
 		if fp == nil {
-			panic("not implemented yet")
+			fp = c.getOrAddField(fieldName, fieldType, false)
 		}
 		if fieldType.Stored() {
 			if err := func() error {
@@ -385,14 +424,88 @@ func (c *DefaultIndexingChain) processField(field IndexableField,
 
 	if dvType := fieldType.DocValueType(); int(dvType) != 0 {
 		if fp == nil {
-			panic("not implemented yet")
+			fp = c.getOrAddField(fieldName, fieldType, false)
+		}
+		if err := c.indexDocValue(fp, dvType, field); err != nil {
+			return 0, err
 		}
-		panic("not implemented yet")
+	}
+
+	if fieldType.PointDimensionCount() != 0 {
+		if fp == nil {
+			fp = c.getOrAddField(fieldName, fieldType, false)
+		}
+		c.indexPoint(fp, fieldType.PointDimensionCount(), field)
 	}
 
 	return fieldCount, nil
 }
 
+/* Called from processDocument to index one field's point. */
+func (c *DefaultIndexingChain) indexPoint(fp *PerField,
+	dimensionCount int, field IndexableField) {
+
+	if c.docWriter.codec.PointsFormat() == nil {
+		panic(fmt.Sprintf("codec %v cannot index points: field '%v'",
+			c.docWriter.codec.Name(), fp.fieldInfo.Name))
+	}
+	// This will panic if the caller tried to change the dimension count
+	// for the field:
+	fp.fieldInfo.SetPointDimensionCount(dimensionCount)
+
+	if fp.pointValuesWriter == nil {
+		fp.pointValuesWriter = newPointValuesWriter(fp.fieldInfo, c.bytesUsed)
+	}
+	fp.pointValuesWriter.addValue(c.docState.docID, numericValueAsLong(field.NumericValue()))
+}
+
+/* Called from processDocument to index one field's doc values. */
+func (c *DefaultIndexingChain) indexDocValue(fp *PerField,
+	dvType DocValuesType, field IndexableField) error {
+
+	hasDocValues := fp.fieldInfo.HasDocValues()
+
+	// This will panic if the caller tried to change the DV type for
+	// the field:
+	fp.fieldInfo.SetDocValueType(dvType)
+	if !hasDocValues {
+		c.fieldInfos.GlobalFieldNumbers().SetDocValuesType(
+			int(fp.fieldInfo.Number), fp.fieldInfo.Name, dvType)
+	}
+
+	docId := c.docState.docID
+
+	switch dvType {
+	case DOC_VALUES_TYPE_NUMERIC:
+		if fp.docValuesWriter == nil {
+			fp.docValuesWriter = newNumericDocValuesWriter(fp.fieldInfo, c.bytesUsed, true)
+		}
+		fp.docValuesWriter.(*NumericDocValuesWriter).addValue(docId, numericValueAsLong(field.NumericValue()))
+	case DOC_VALUES_TYPE_SORTED:
+		if fp.docValuesWriter == nil {
+			fp.docValuesWriter = newSortedDocValuesWriter(fp.fieldInfo, c.bytesUsed)
+		}
+		return fp.docValuesWriter.(*SortedDocValuesWriter).addValue(docId, field.BinaryValue())
+	default:
+		panic(fmt.Sprintf("unrecognized DocValues.Type: %v", dvType))
+	}
+	return nil
+}
+
+func numericValueAsLong(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	panic(fmt.Sprintf("field has no numeric value: %v", v))
+}
+
 /*
 Returns a previously created PerField, or nil if this field name
 wasn't seen yet.
@@ -460,6 +573,9 @@ type PerField struct {
 	// non-nil if this field ever had doc values in this segment:
 	docValuesWriter DocValuesWriter
 
+	// non-nil if this field ever had points in this segment:
+	pointValuesWriter *PointValuesWriter
+
 	// We use this to know when a PerField is seen for the first time
 	// in the current document.
 	fieldGen int64
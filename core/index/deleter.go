@@ -1,5 +1,9 @@
 package index
 
+import (
+	"sort"
+)
+
 // index/IndexDeletionPolicy.java
 
 /*
@@ -58,6 +62,9 @@ type IndexDeletionPolicy interface {
 	  the index content while doing that.
 	*/
 	onCommit(commits []IndexCommit) error
+	// Returns an independent instance, able to work with any other
+	// IndexWriter or Directory instance.
+	Clone() IndexDeletionPolicy
 }
 
 // index/NoDeletionPolicy.java
@@ -102,3 +109,43 @@ func (p KeepOnlyLastCommitDeletionPolicy) Clone() IndexDeletionPolicy {
 }
 
 const DEFAULT_DELETION_POLICY = KeepOnlyLastCommitDeletionPolicy(true)
+
+/*
+An IndexDeletionPolicy that keeps the N most recent commits and
+removes all older ones, a middle ground between
+KeepOnlyLastCommitDeletionPolicy and NoDeletionPolicy.
+*/
+type KeepAllButNDeletionPolicy struct {
+	n int
+}
+
+/* Creates a policy keeping the n (>= 1) most recent commits. */
+func NewKeepAllButNDeletionPolicy(n int) *KeepAllButNDeletionPolicy {
+	assert2(n >= 1, "n must be >= 1 to keep the current commit (got %v)", n)
+	return &KeepAllButNDeletionPolicy{n}
+}
+
+/* Returns the number of commits kept. */
+func (p *KeepAllButNDeletionPolicy) N() int {
+	return p.n
+}
+
+// Deletes all commits except the n most recent ones.
+func (p *KeepAllButNDeletionPolicy) onInit(commits []IndexCommit) error {
+	return p.onCommit(commits)
+}
+
+// Deletes all commits except the n most recent ones.
+func (p *KeepAllButNDeletionPolicy) onCommit(commits []IndexCommit) error {
+	sorted := make([]IndexCommit, len(commits))
+	copy(sorted, commits)
+	sort.Sort(IndexCommits(sorted))
+	for i, limit := 0, len(sorted)-p.n; i < limit; i++ {
+		sorted[i].Delete()
+	}
+	return nil
+}
+
+func (p *KeepAllButNDeletionPolicy) Clone() IndexDeletionPolicy {
+	return &KeepAllButNDeletionPolicy{p.n}
+}
@@ -0,0 +1,58 @@
+package index
+
+import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
+	"testing"
+)
+
+type fakeCommit struct {
+	gen     int64
+	deleted bool
+}
+
+func (c *fakeCommit) SegmentsFileName() string    { return fmt.Sprintf("segments_%v", c.gen) }
+func (c *fakeCommit) FileNames() []string         { return []string{c.SegmentsFileName()} }
+func (c *fakeCommit) Directory() store.Directory  { return nil }
+func (c *fakeCommit) Delete()                     { c.deleted = true }
+func (c *fakeCommit) IsDeleted() bool             { return c.deleted }
+func (c *fakeCommit) SegmentCount() int           { return 0 }
+func (c *fakeCommit) Generation() int64           { return c.gen }
+func (c *fakeCommit) UserData() map[string]string { return nil }
+
+func TestKeepAllButNDeletionPolicy(t *testing.T) {
+	p := NewKeepAllButNDeletionPolicy(2)
+	var commits []IndexCommit
+	for gen := int64(1); gen <= 5; gen++ {
+		commits = append(commits, &fakeCommit{gen: gen})
+		// the deleter removes deleted commits before the next call
+		var live []IndexCommit
+		for _, c := range commits {
+			if !c.IsDeleted() {
+				live = append(live, c)
+			}
+		}
+		if err := p.onCommit(live); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range commits {
+		if expected := i < 3; c.IsDeleted() != expected {
+			t.Errorf("Expected commit %v deleted=%v", c.Generation(), expected)
+		}
+	}
+
+	// commits are sorted by generation before deleting
+	commits = []IndexCommit{&fakeCommit{gen: 9}, &fakeCommit{gen: 7}, &fakeCommit{gen: 8}}
+	if err := p.Clone().onInit(commits); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range commits {
+		if expected := c.Generation() == 7; c.IsDeleted() != expected {
+			t.Errorf("Expected commit %v deleted=%v", c.Generation(), expected)
+		}
+	}
+	if n := p.Clone().(*KeepAllButNDeletionPolicy).N(); n != 2 {
+		t.Errorf("Expected cloned N 2, got %v", n)
+	}
+}
@@ -13,12 +13,12 @@ import (
 const DEFAULT_TERMS_INDEX_DIVISOR = 1
 
 type DirectoryReader interface {
-	IndexReader
-	// doOpenIfChanged() error
+	CompositeReader
+	doOpenIfChanged() (DirectoryReader, error)
 	// doOpenIfChanged(c IndexCommit) error
 	// doOpenIfChanged(w IndexWriter, c IndexCommit) error
 	Version() int64
-	IsCurrent() bool
+	IsCurrent() (bool, error)
 }
 
 type DirectoryReaderImpl struct {
@@ -38,10 +38,68 @@ func newDirectoryReader(spi BaseCompositeReaderSPI, directory store.Directory, s
 	}
 }
 
+/* Returns a DirectoryReader reading the latest commit of the index in the given Directory. */
 func OpenDirectoryReader(directory store.Directory) (r DirectoryReader, err error) {
 	return openStandardDirectoryReader(directory, nil, DEFAULT_TERMS_INDEX_DIVISOR)
 }
 
+/*
+Expert: returns a DirectoryReader reading the index as of the given
+IndexCommit. If the commit was handed out by an open IndexWriter,
+e.g. through SnapshotDeletionPolicy, the writer keeps the commit's
+files for as long as the reader is open, even after its deletion
+policy deleted the commit.
+*/
+func OpenDirectoryReaderAt(commit IndexCommit) (r DirectoryReader, err error) {
+	// look through commits wrapped for a deletion policy
+	for {
+		if wrapped, ok := commit.(*snapshotCommitPoint); ok {
+			commit = wrapped.cp
+		} else {
+			break
+		}
+	}
+	var writer *IndexWriter
+	if cp, ok := commit.(*CommitPoint); ok && cp.deleter != nil {
+		ok, err := cp.deleter.writer.incRefDeleter(cp.files)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			writer = cp.deleter.writer
+		}
+	}
+	r, err = openStandardDirectoryReader(commit.Directory(), commit, DEFAULT_TERMS_INDEX_DIVISOR)
+	if writer != nil {
+		files := commit.FileNames()
+		if err != nil {
+			writer.decRefDeleter(files)
+		} else {
+			sdr := r.(*StandardDirectoryReader)
+			sdr.releaseCommit = func() { writer.decRefDeleter(files) }
+		}
+	}
+	return r, err
+}
+
+/*
+If the index has changed since the provided reader was opened, open
+and return a new reader; else, return nil. The new reader, if not
+nil, will be the same type of reader as the previous one.
+
+This method is typically far less costly than opening a fully new
+DirectoryReader as it shares resources (for example sub-readers) with
+the provided DirectoryReader, when possible.
+
+The provided reader is not closed (you are responsible for doing so);
+if a new reader is returned you also must eventually close it. Be
+sure to never close a reader while other goroutines are still using
+it.
+*/
+func OpenDirectoryReaderIfChanged(oldReader DirectoryReader) (DirectoryReader, error) {
+	return oldReader.doOpenIfChanged()
+}
+
 /*
 Returns true if an index likely exists at the specified directory. Note that
 if a corrupt index exists, or if an index in the process of committing
@@ -88,15 +146,21 @@ func IsIndexFileExists(files []string) bool {
 
 type StandardDirectoryReader struct {
 	*DirectoryReaderImpl
-	writer       *IndexWriter // NRT
-	segmentInfos *SegmentInfos
+	writer                *IndexWriter // NRT
+	segmentInfos          *SegmentInfos
+	termInfosIndexDivisor int
+	// releases the commit's files kept by the writer, if any
+	releaseCommit func()
 }
 
 // TODO support IndexWriter
 func newStandardDirectoryReader(directory store.Directory, readers []AtomicReader,
 	sis *SegmentInfos, termInfosIndexDivisor int, applyAllDeletes bool) *StandardDirectoryReader {
 	// log.Printf("Initializing StandardDirectoryReader with %v sub readers...", len(readers))
-	ans := &StandardDirectoryReader{segmentInfos: sis}
+	ans := &StandardDirectoryReader{
+		segmentInfos:          sis,
+		termInfosIndexDivisor: termInfosIndexDivisor,
+	}
 	ans.DirectoryReaderImpl = newDirectoryReader(ans, directory, readers)
 	return ans
 }
@@ -155,22 +219,84 @@ func (r *StandardDirectoryReader) Version() int64 {
 	return r.segmentInfos.version
 }
 
-func (r *StandardDirectoryReader) IsCurrent() bool {
+/*
+Check whether any new changes have occurred to the index since this
+reader was opened, i.e. whether a commit newer than the one this
+reader reads exists in the directory. Prepared commits which haven't
+been finished yet don't count.
+*/
+func (r *StandardDirectoryReader) IsCurrent() (bool, error) {
 	r.ensureOpen()
-	// if writer == nill || writer.IsClosed() {
-	// Fully read the segments file: this ensures that it's
-	// completely written so that if
-	// IndexWriter.prepareCommit has been called (but not
-	// yet commit), then the reader will still see itself as
-	// current:
-	sis := SegmentInfos{}
-	sis.ReadAll(r.directory)
-
-	// we loaded SegmentInfos from the directory
-	return sis.version == r.segmentInfos.version
-	// } else {
-	// return writer.nrtIsCurrent(r.segmentInfos)
-	// }
+	// TODO support NRT readers: writer.nrtIsCurrent(r.segmentInfos)
+	files, err := r.directory.ListAll()
+	if err != nil {
+		return false, err
+	}
+	gen, err := LastFinishedCommitGeneration(r.directory, files)
+	if err != nil {
+		return false, err
+	}
+	return gen == r.segmentInfos.lastGeneration, nil
+}
+
+func (r *StandardDirectoryReader) doOpenIfChanged() (DirectoryReader, error) {
+	r.ensureOpen()
+	// TODO support NRT readers
+	if current, err := r.IsCurrent(); current || err != nil {
+		return nil, err
+	}
+	obj, err := NewFindSegmentsFile(r.directory, func(segmentFileName string) (interface{}, error) {
+		sis := &SegmentInfos{}
+		if err := sis.Read(r.directory, segmentFileName); err != nil {
+			return nil, err
+		}
+		return r.reopen(sis)
+	}).run(nil)
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*StandardDirectoryReader), nil
+}
+
+/*
+Opens a reader on the given SegmentInfos, sharing the SegmentReaders
+of segments which didn't change since this reader was opened. Shared
+readers are incRef'ed, so closing this reader doesn't close them.
+*/
+func (r *StandardDirectoryReader) reopen(sis *SegmentInfos) (*StandardDirectoryReader, error) {
+	// we put the old SegmentReaders in a map, that allows us to
+	// lookup a reader using its segment name
+	segmentReaders := make(map[string]*SegmentReader)
+	for _, sub := range r.getSequentialSubReaders() {
+		sr := sub.(*SegmentReader)
+		segmentReaders[sr.si.Info.Name] = sr
+	}
+
+	readers := make([]AtomicReader, len(sis.Segments))
+	for i := len(sis.Segments) - 1; i >= 0; i-- {
+		info := sis.Segments[i]
+		if old, ok := segmentReaders[info.Info.Name]; ok &&
+			old.si.Info.IsCompoundFile() == info.Info.IsCompoundFile() &&
+			old.si.DelGen() == info.DelGen() &&
+			old.si.FieldInfosGen() == info.FieldInfosGen() {
+			// this segment didn't change; share its reader
+			old.incRef()
+			readers[i] = old
+			continue
+		}
+		sr, err := NewSegmentReader(info, r.termInfosIndexDivisor, store.IO_CONTEXT_READ)
+		if err != nil {
+			// release what we have opened or shared so far
+			for _, reader := range readers {
+				if reader != nil {
+					reader.(*SegmentReader).decRef()
+				}
+			}
+			return nil, err
+		}
+		readers[i] = sr
+	}
+	return newStandardDirectoryReader(r.directory, readers, sis, r.termInfosIndexDivisor, false), nil
 }
 
 func (r *StandardDirectoryReader) doClose() error {
@@ -191,6 +317,10 @@ func (r *StandardDirectoryReader) doClose() error {
 		}()
 	}
 
+	if r.releaseCommit != nil {
+		r.releaseCommit()
+	}
+
 	if w := r.writer; w != nil {
 		panic("not implemented yet")
 		// Since we just closed, writer may now be able to delete unused files:
@@ -26,3 +26,57 @@ func TestLeaves(t *testing.T) {
 		t.Error("Should have one sub reader.")
 	}
 }
+
+func TestFilterLeafReader(t *testing.T) {
+	d, err := store.OpenFSDirectory("../search/testdata/win8/belfrysample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenDirectoryReader(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	leaf := r.Leaves()[0].Reader().(*SegmentReader)
+	leaf.incRef() // owned by the filter from here on
+	f := NewFilterLeafReader(leaf)
+
+	assertEquals(t, leaf.NumDocs(), f.NumDocs())
+	assertEquals(t, leaf.MaxDoc(), f.MaxDoc())
+	assertEquals(t, leaf.LiveDocs(), f.LiveDocs())
+	assertEquals(t, leaf.Fields(), f.Fields())
+	term := NewTerm("content", "bat")
+	n1, err := leaf.DocFreq(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2, err := f.DocFreq(term)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, n1, n2)
+	for docID := 0; docID < leaf.MaxDoc(); docID++ {
+		doc1, err := leaf.Document(docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc2, err := f.Document(docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields1, fields2 := doc1.Fields(), doc2.Fields()
+		assertEquals(t, len(fields1), len(fields2))
+		for i, field := range fields1 {
+			assertEquals(t, field.Name(), fields2[i].Name())
+			assertEquals(t, field.StringValue(), fields2[i].StringValue())
+		}
+	}
+
+	assertEquals(t, int32(2), leaf.refCount)
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, int32(1), leaf.refCount)
+	leaf.ensureOpen() // still referenced by r
+}
@@ -64,7 +64,7 @@ func (w *NumericDocValuesWriter) docsWithFieldBytesUsed() int64 {
 	if w.docsWithField == nil {
 		return 0
 	}
-	return util.SizeOf(w.docsWithField.Bits()) + 64
+	return w.docsWithField.RamBytesUsed() + 64
 }
 
 func (w *NumericDocValuesWriter) updateBytesUsed() {
@@ -80,10 +80,9 @@ func (w *NumericDocValuesWriter) flush(state *SegmentWriteState,
 
 	maxDoc := state.SegmentInfo.DocCount()
 	values := w.pending.Build()
-	dvConsumer.AddNumericField(w.fieldInfo, func() func() (interface{}, bool) {
+	return dvConsumer.AddNumericField(w.fieldInfo, func() func() (interface{}, bool) {
 		return newNumericIterator(maxDoc, values, w.docsWithField)
 	})
-	return nil
 }
 
 /* Iterates over the values we have in ram */
@@ -116,3 +115,144 @@ func newNumericIterator(maxDoc int,
 		return value, true
 	}
 }
+
+// index/SortedDocValuesWriter.java
+
+const EMPTY_ORD int64 = -1
+
+/* Buffers up pending []byte per doc, deref and sorting via int ord, then flushes when segment flushes. */
+type SortedDocValuesWriter struct {
+	hash        *util.BytesRefHash
+	pending     packed.PackedLongValuesBuilder
+	iwBytesUsed util.Counter
+	bytesUsed   int64 // this currently only tracks differences in 'pending'
+	fieldInfo   *FieldInfo
+}
+
+func newSortedDocValuesWriter(fieldInfo *FieldInfo,
+	iwBytesUsed util.Counter) *SortedDocValuesWriter {
+	ans := &SortedDocValuesWriter{
+		fieldInfo:   fieldInfo,
+		iwBytesUsed: iwBytesUsed,
+		hash: util.NewBytesRefHash(
+			util.NewByteBlockPool(util.NewDirectTrackingAllocator(iwBytesUsed)),
+			util.BYTES_REF_HASH_DEFAULT_CAPACITY,
+			util.NewDirectBytesStartArray(util.BYTES_REF_HASH_DEFAULT_CAPACITY, iwBytesUsed)),
+		pending: packed.DeltaPackedBuilder(packed.PackedInts.COMPACT),
+	}
+	ans.bytesUsed = ans.pending.RamBytesUsed()
+	ans.iwBytesUsed.AddAndGet(ans.bytesUsed)
+	return ans
+}
+
+func (w *SortedDocValuesWriter) addValue(docId int, value []byte) error {
+	assert2(int64(docId) >= w.pending.Size(),
+		"DocValuesField '%v' appears more than once in this document (only one value is allowed per field)",
+		w.fieldInfo.Name)
+	assert2(value != nil, "field '%v': null value not allowed", w.fieldInfo.Name)
+	assert2(len(value) <= util.BYTE_BLOCK_SIZE-2,
+		"DocValuesField '%v' is too large, must be <= %v",
+		w.fieldInfo.Name, util.BYTE_BLOCK_SIZE-2)
+
+	// Fill in any holes:
+	for int64(docId) > w.pending.Size() {
+		w.pending.Add(EMPTY_ORD)
+	}
+
+	return w.addOneValue(value)
+}
+
+func (w *SortedDocValuesWriter) finish(maxDoc int) {
+	for int64(maxDoc) > w.pending.Size() {
+		w.pending.Add(EMPTY_ORD)
+	}
+	w.updateBytesUsed()
+}
+
+func (w *SortedDocValuesWriter) addOneValue(value []byte) error {
+	termId, err := w.hash.Add(value)
+	if err != nil {
+		return err
+	}
+	if termId < 0 {
+		termId = -termId - 1
+	} else {
+		// reserve additional space for each unique value:
+		// 1. when indexing, when hash is 50% full, rehash() suddenly
+		//    needs 2*size ints.
+		// 2. when flushing, we need 1 int per value (slot in the ordMap).
+		w.iwBytesUsed.AddAndGet(2 * util.NUM_BYTES_INT)
+	}
+
+	w.pending.Add(int64(termId))
+	w.updateBytesUsed()
+	return nil
+}
+
+func (w *SortedDocValuesWriter) updateBytesUsed() {
+	newBytesUsed := w.pending.RamBytesUsed()
+	w.iwBytesUsed.AddAndGet(newBytesUsed - w.bytesUsed)
+	w.bytesUsed = newBytesUsed
+}
+
+func (w *SortedDocValuesWriter) flush(state *SegmentWriteState,
+	dvConsumer DocValuesConsumer) error {
+
+	maxDoc := state.SegmentInfo.DocCount()
+	assert(w.pending.Size() == int64(maxDoc))
+	valueCount := w.hash.Size()
+	ords := w.pending.Build()
+
+	sortedValues := w.hash.Sort(util.UTF8SortedAsUnicodeLess)
+	ordMap := make([]int, valueCount)
+	for ord := 0; ord < valueCount; ord++ {
+		ordMap[sortedValues[ord]] = ord
+	}
+
+	return dvConsumer.AddSortedField(w.fieldInfo,
+		// ord -> value
+		func() func() ([]byte, bool) {
+			return newValuesIterator(sortedValues, valueCount, w.hash)
+		},
+		// doc -> ord
+		func() func() (interface{}, bool) {
+			return newOrdsIterator(ordMap, maxDoc, ords)
+		})
+}
+
+/* Iterates over the unique values we have in ram */
+func newValuesIterator(sortedValues []int, valueCount int,
+	hash *util.BytesRefHash) func() ([]byte, bool) {
+
+	scratch := util.NewEmptyBytesRef()
+	ordUpto := 0
+	return func() ([]byte, bool) {
+		if ordUpto >= valueCount {
+			return nil, false
+		}
+		hash.Get(sortedValues[ordUpto], scratch)
+		ordUpto++
+		return scratch.ToBytes(), true
+	}
+}
+
+/* Iterates over the ords for each doc we have in ram */
+func newOrdsIterator(ordMap []int, maxDoc int,
+	ords packed.PackedLongValues) func() (interface{}, bool) {
+
+	iter := ords.Iterator()
+	assert(ords.Size() == int64(maxDoc))
+	docUpto := 0
+	return func() (interface{}, bool) {
+		if docUpto >= maxDoc {
+			return nil, false
+		}
+		v, _ := iter()
+		ord := v.(int64)
+		docUpto++
+		if ord == EMPTY_ORD {
+			return ord, true
+		}
+		return int64(ordMap[ord]), true
+	}
+}
@@ -176,6 +176,7 @@ func (dw *DocumentsWriter) abortThreadState(perThread *ThreadState, newFiles map
 			}()
 			dw.subtractFlushedNumDocs(perThread.dwpt.numDocsInRAM)
 			perThread.dwpt.abort(newFiles)
+			dw.putEvent(newSegmentAbortedEvent(perThread.dwpt.segmentInfo.Name))
 		} else {
 			dw.flushControl.doOnAbort(perThread)
 		}
@@ -312,6 +313,7 @@ func (dw *DocumentsWriter) updateDocument(doc []model.IndexableField,
 					if len(dwpt.filesToDelete) > 0 {
 						dw.putEvent(newDeleteNewFilesEvent(dwpt.filesToDelete))
 					}
+					dw.putEvent(newSegmentAbortedEvent(dwpt.segmentInfo.Name))
 					dw.subtractFlushedNumDocs(dwptNuMDocs)
 					dw.flushControl.doOnAbort(perThread)
 				}
@@ -407,6 +409,10 @@ func (dw *DocumentsWriter) doFlush(flushingDWPT *DocumentsWriterPerThread) (bool
 					if err != nil {
 						return err
 					}
+					if newSegment == nil { // aborted
+						dw.putEvent(newSegmentAbortedEvent(flushingDWPT.segmentInfo.Name))
+						hasEvents = true
+					}
 					dw.ticketQueue.addSegment(ticket, newSegment)
 					dwptSuccess = true
 					return nil
@@ -568,7 +574,8 @@ func (dw *DocumentsWriter) processEvents(writer *IndexWriter,
 	dw.eventsLock.RLock()
 	defer dw.eventsLock.RUnlock()
 
-	for e := dw.events.Front(); e != nil; e = e.Next() {
+	// Remove() clears e's links, so always restart from the front
+	for e := dw.events.Front(); e != nil; e = dw.events.Front() {
 		dw.events.Remove(e)
 		processed = true
 		if err = e.Value.(Event)(writer, triggerMerge, forcePurge); err != nil {
@@ -344,11 +344,7 @@ func (dwpt *DocumentsWriterPerThread) flush() (fs *FlushedSegment, err error) {
 		return nil, err
 	}
 	dwpt.pendingUpdates.terms = make(map[*Term]int)
-	files := make(map[string]bool)
-	dwpt.directory.EachCreatedFiles(func(name string) {
-		files[name] = true
-	})
-	dwpt.segmentInfo.SetFiles(files)
+	dwpt.segmentInfo.SetFiles(dwpt.directory.CreatedFiles())
 
 	info := NewSegmentCommitInfo(dwpt.segmentInfo, 0, -1, -1, -1)
 	if dwpt.infoStream.IsEnabled("DWPT") {
@@ -32,6 +32,19 @@ func newFlushFailedEvent(info *model.SegmentInfo) Event {
 	})
 }
 
+/*
+Drops the segment of an aborted DWPT from the writer's segments in
+flight, so that IndexFileDeleter may delete any file left from it.
+*/
+func newSegmentAbortedEvent(name string) Event {
+	return Event(func(writer *IndexWriter, triggerMerge, forcePurge bool) error {
+		writer.Lock()
+		defer writer.Unlock()
+		delete(writer.segmentsInFlight, name)
+		return nil
+	})
+}
+
 func newDeleteNewFilesEvent(files map[string]bool) Event {
 	return Event(func(writer *IndexWriter, triggerMerge, forcePurge bool) error {
 		writer.Lock()
@@ -0,0 +1,93 @@
+package index
+
+import (
+	"github.com/balzaczyy/golucene/core/store"
+	"time"
+)
+
+// index/ExpirationTimeDeletionPolicy.java
+
+/*
+An IndexDeletionPolicy that keeps the most recent commit and deletes
+any older commit only once its segments_N file has not been modified
+for more than the configured expiration time. This gives readers
+sharing the index over filesystems like NFS time to refresh to the
+new commit before the files of the old one are removed.
+
+The modification time is read from the Directory, or the one wrapped
+by FilterDirectory, through a FileModified(name string) (time.Time,
+error) method, as FSDirectory provides. Commits in a Directory without
+it, e.g. RAMDirectory, never expire.
+*/
+type ExpirationTimeDeletionPolicy struct {
+	dir            store.Directory
+	expirationTime time.Duration
+	// Returns the current time; time.Now unless overridden by tests.
+	now func() time.Time
+	// Returns the modification time of the named file; nil if dir
+	// doesn't provide it.
+	modTime func(name string) (time.Time, error)
+}
+
+/*
+Creates a policy which removes a commit, other than the most recent
+one, once its segments_N file in dir is older than expirationTime.
+*/
+func NewExpirationTimeDeletionPolicy(dir store.Directory,
+	expirationTime time.Duration) *ExpirationTimeDeletionPolicy {
+
+	ans := &ExpirationTimeDeletionPolicy{
+		dir:            dir,
+		expirationTime: expirationTime,
+		now:            time.Now,
+	}
+	if d, ok := fileModifiedDirectory(dir); ok {
+		ans.modTime = d.FileModified
+	}
+	return ans
+}
+
+type fileModifier interface {
+	FileModified(name string) (time.Time, error)
+}
+
+// Returns dir, or the Directory it wraps, if it provides file
+// modification times.
+func fileModifiedDirectory(dir store.Directory) (fileModifier, bool) {
+	if d, ok := dir.(fileModifier); ok {
+		return d, true
+	}
+	d, ok := store.UnwrapDirectory(dir).(fileModifier)
+	return d, ok
+}
+
+/* Expiration time of stale commits. */
+func (p *ExpirationTimeDeletionPolicy) ExpirationTime() time.Duration {
+	return p.expirationTime
+}
+
+func (p *ExpirationTimeDeletionPolicy) onInit(commits []IndexCommit) error {
+	return p.onCommit(commits)
+}
+
+func (p *ExpirationTimeDeletionPolicy) onCommit(commits []IndexCommit) error {
+	if len(commits) == 0 || p.modTime == nil {
+		return nil // no commit to expire, or no way to tell their age
+	}
+	now := p.now()
+	// Delete all but last one if they are older than expirationTime:
+	for _, commit := range commits[:len(commits)-1] {
+		modTime, err := p.modTime(commit.SegmentsFileName())
+		if err != nil {
+			return err
+		}
+		if now.Sub(modTime) > p.expirationTime {
+			commit.Delete()
+		}
+	}
+	return nil
+}
+
+func (p *ExpirationTimeDeletionPolicy) Clone() IndexDeletionPolicy {
+	return NewExpirationTimeDeletionPolicy(p.dir, p.expirationTime)
+}
@@ -0,0 +1,123 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExpirationTimeDeletionPolicy(t *testing.T) {
+	start := time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	modTimes := make(map[string]time.Time)
+
+	p := NewExpirationTimeDeletionPolicy(store.NewRAMDirectory(), 10*time.Minute)
+	p.now = func() time.Time { return now }
+	p.modTime = func(name string) (time.Time, error) {
+		modTime, ok := modTimes[name]
+		if !ok {
+			return time.Time{}, errors.New(fmt.Sprintf("%v not found", name))
+		}
+		return modTime, nil
+	}
+
+	var commits []IndexCommit
+	commit := func() {
+		c := &fakeCommit{gen: int64(len(commits) + 1)}
+		modTimes[c.SegmentsFileName()] = now
+		commits = append(commits, c)
+		if err := p.onCommit(commits); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertDeleted := func(expected ...bool) {
+		for i, c := range commits {
+			if c.IsDeleted() != expected[i] {
+				t.Errorf("Expected commit %v deleted=%v at %v", c.Generation(), expected[i], now.Sub(start))
+			}
+		}
+	}
+
+	commit() // 0m
+	assertDeleted(false)
+	now = now.Add(5 * time.Minute)
+	commit() // 5m
+	assertDeleted(false, false)
+	now = now.Add(5 * time.Minute)
+	commit() // 10m: first commit is exactly 10 minutes old
+	assertDeleted(false, false, false)
+	now = now.Add(time.Second)
+	commit()
+	assertDeleted(true, false, false, false)
+
+	// the newest commit is always kept
+	now = now.Add(time.Hour)
+	if err := p.onInit(commits[1:]); err != nil {
+		t.Fatal(err)
+	}
+	assertDeleted(true, true, true, false)
+}
+
+func TestExpirationTimeDeletionPolicyErrors(t *testing.T) {
+	p := NewExpirationTimeDeletionPolicy(store.NewRAMDirectory(), time.Minute)
+	commits := []IndexCommit{&fakeCommit{gen: 1}, &fakeCommit{gen: 2}}
+	p.modTime = func(name string) (time.Time, error) {
+		return time.Time{}, errors.New("fake IO error")
+	}
+	if err := p.onCommit(commits); err == nil {
+		t.Error("Expected error reading the modification time")
+	}
+	if commits[0].IsDeleted() {
+		t.Error("Expected commit not deleted on error")
+	}
+
+	if d := p.Clone().(*ExpirationTimeDeletionPolicy).ExpirationTime(); d != time.Minute {
+		t.Errorf("Expected cloned expiration time %v, got %v", time.Minute, d)
+	}
+}
+
+func TestExpirationTimeDeletionPolicyDirectories(t *testing.T) {
+	// commits never expire without modification times
+	p := NewExpirationTimeDeletionPolicy(store.NewFilterDirectory(store.NewRAMDirectory()), 0)
+	p.now = func() time.Time { return time.Now().Add(time.Hour) }
+	commits := []IndexCommit{&fakeCommit{gen: 1}, &fakeCommit{gen: 2}}
+	if err := p.onCommit(commits); err != nil {
+		t.Fatal(err)
+	}
+	if commits[0].IsDeleted() {
+		t.Error("Expected commit kept without modification times")
+	}
+
+	// FSDirectory is found under FilterDirectory
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	fsd, err := store.OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsd.Close()
+	for _, c := range commits {
+		out, err := fsd.CreateOutput(c.SegmentsFileName(), store.IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p = NewExpirationTimeDeletionPolicy(store.NewFilterDirectory(fsd), time.Minute)
+	p.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if err := p.onCommit(commits); err != nil {
+		t.Fatal(err)
+	}
+	if !commits[0].IsDeleted() || commits[1].IsDeleted() {
+		t.Error("Expected only the expired commit deleted")
+	}
+}
@@ -163,7 +163,7 @@ func newIndexFileDeleter(directory store.Directory, policy IndexDeletionPolicy,
 						// aborted "future" commit, so suppress exc in this case
 						sis = nil
 					} else { // sis != nil
-						commitPoint := newCommitPoint(fd.commitsToDelete, directory, sis)
+						commitPoint := fd.newCommitPoint(sis)
 						if sis.generation == segmentInfos.generation {
 							currentCommitPoint = commitPoint
 						}
@@ -196,7 +196,7 @@ func newIndexFileDeleter(directory store.Directory, policy IndexDeletionPolicy,
 			infoStream.Message("IFD", "forced open of current segments file %v",
 				segmentInfos.SegmentsFileName())
 		}
-		currentCommitPoint = newCommitPoint(fd.commitsToDelete, directory, sis)
+		currentCommitPoint = fd.newCommitPoint(sis)
 		fd.commits = append(fd.commits, currentCommitPoint)
 		fd.incRef(sis, true)
 	}
@@ -315,12 +315,14 @@ func (fd *IndexFileDeleter) deleteCommits() {
 		// Now compact commits to remove deleted ones (preserving the sort):
 		var writeTo = 0
 		for readFrom, commit := range fd.commits {
-			if !commit.IsDeleted() && readFrom != writeTo {
-				fd.commits[writeTo] = commit
+			if !commit.IsDeleted() {
+				if readFrom != writeTo {
+					fd.commits[writeTo] = commit
+				}
 				writeTo++
 			}
 		}
-		for i, _ := range fd.commits[writeTo:] {
+		for i := writeTo; i < len(fd.commits); i++ {
 			fd.commits[i] = nil
 		}
 		fd.commits = fd.commits[:writeTo]
@@ -332,6 +334,11 @@ Writer calls this when it has hit an error and had to roll back, to
 tell us that there may now be unreferenced files in the filesystem.
 So we re-list the filesystem and delete such files. If segmentName is
 non-empty, we only delete files correspoding to that segment.
+
+Files still referenced by a checkpoint or commit, and the segments file
+of a pending (prepared but not yet finished) commit, are never removed.
+Neither are the files of segments which are still being flushed or
+merged: they are not incRef'd until the segment is checkpointed.
 */
 func (fd *IndexFileDeleter) refresh(segmentName string) error {
 	// assert locked()
@@ -353,6 +360,8 @@ func (fd *IndexFileDeleter) refresh(segmentName string) error {
 			strings.HasPrefix(filename, prefix2)) &&
 			!strings.HasSuffix(filename, WRITE_LOCK_NAME) &&
 			!hasRef && filename != INDEX_FILENAME_SEGMENTS_GEN &&
+			!fd.isPendingCommitFile(filename) &&
+			!fd.writer.segmentsInFlight[util.ParseSegmentName(filename)] &&
 			(m.MatchString(filename) || strings.HasPrefix(filename, INDEX_FILENAME_SEGMENTS)) {
 
 			// Unreferenced file, so remove it
@@ -367,6 +376,19 @@ func (fd *IndexFileDeleter) refresh(segmentName string) error {
 	return nil
 }
 
+/*
+The pending commit's segments file is written before it's incRef'd.
+Write() already advanced its generation, but lastGeneration (and so
+SegmentsFileName()) still names the previous commit until it's
+finished.
+*/
+func (fd *IndexFileDeleter) isPendingCommitFile(filename string) bool {
+	pc := fd.writer.pendingCommit
+	return pc != nil && pc.pendingSegnOutput != nil &&
+		filename == util.FileNameFromGeneration(util.SEGMENTS, "", pc.generation)
+}
+
+/* Deletes every unreferenced index file in the directory. */
 func (fd *IndexFileDeleter) refreshList() error {
 	// set to nil so that we regenerate the list of pending files;
 	// else we can accumulate some file more than once
@@ -394,11 +416,12 @@ func (fd *IndexFileDeleter) deletePendingFiles() {
 			if fd.infoStream.IsEnabled("IFD") {
 				fd.infoStream.Message("IFD", "delete pending file %v", filename)
 			}
-			rc, ok := fd.refCounts[filename]
-			assert2(!ok || rc.count <= 0,
-				// LUCENE-5904: should never happen!  This means we are about to pending-delete a referenced index file
-				"filename=%v is in pending delete list but also has refCount=%v",
-				filename, rc.count)
+			if rc, ok := fd.refCounts[filename]; ok {
+				assert2(rc.count <= 0,
+					// LUCENE-5904: should never happen!  This means we are about to pending-delete a referenced index file
+					"filename=%v is in pending delete list but also has refCount=%v",
+					filename, rc.count)
+			}
 			fd.deleteFile(filename)
 		}
 	}
@@ -446,7 +469,7 @@ func (fd *IndexFileDeleter) checkpoint(segmentInfos *SegmentInfos, isCommit bool
 
 	if isCommit {
 		// Append to our commits list:
-		fd.commits = append(fd.commits, newCommitPoint(fd.commitsToDelete, fd.directory, segmentInfos))
+		fd.commits = append(fd.commits, fd.newCommitPoint(segmentInfos))
 
 		// Tell policy so it can remove commits:
 		err := fd.policy.onCommit(fd.commits)
@@ -584,7 +607,8 @@ func (del *IndexFileDeleter) deleteFile(filename string) {
 	}
 	err := del.directory.DeleteFile(filename)
 	if err != nil { // if delete fails
-		if del.directory.FileExists(filename) {
+		// A file that's already gone needs no retry
+		if !os.IsNotExist(err) {
 			// Some operating systems (e.g. Windows) don't
 			// permit a file to be deleted while it is opened
 			// for read (e.g. by another process or thread). So
@@ -644,13 +668,14 @@ type CommitPoint struct {
 	segmentsFileName string
 	deleted          bool
 	directory        store.Directory
-	commitsToDelete  []*CommitPoint
+	commitsToDelete  *[]*CommitPoint // shared with the deleter
+	deleter          *IndexFileDeleter
 	generation       int64
 	userData         map[string]string
 	segmentCount     int
 }
 
-func newCommitPoint(commitsToDelete []*CommitPoint, directory store.Directory,
+func newCommitPoint(commitsToDelete *[]*CommitPoint, directory store.Directory,
 	segmentInfos *SegmentInfos) *CommitPoint {
 	return &CommitPoint{
 		directory:        directory,
@@ -663,6 +688,13 @@ func newCommitPoint(commitsToDelete []*CommitPoint, directory store.Directory,
 	}
 }
 
+/* Returns a CommitPoint which the deleter knows to be alive. */
+func (fd *IndexFileDeleter) newCommitPoint(segmentInfos *SegmentInfos) *CommitPoint {
+	ans := newCommitPoint(&fd.commitsToDelete, fd.directory, segmentInfos)
+	ans.deleter = fd
+	return ans
+}
+
 func (cp *CommitPoint) String() string {
 	return fmt.Sprintf("IndexFileDeleter.CommitPoint(%v)", cp.segmentsFileName)
 }
@@ -694,7 +726,7 @@ func (cp *CommitPoint) UserData() map[string]string {
 func (cp *CommitPoint) Delete() {
 	if !cp.deleted {
 		cp.deleted = true
-		cp.commitsToDelete = append(cp.commitsToDelete, cp)
+		*cp.commitsToDelete = append(*cp.commitsToDelete, cp)
 	}
 }
 
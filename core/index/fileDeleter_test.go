@@ -0,0 +1,203 @@
+package index
+
+import (
+	"errors"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"testing"
+)
+
+func newTestFileDeleter(d store.Directory, policy IndexDeletionPolicy) *IndexFileDeleter {
+	return &IndexFileDeleter{
+		infoStream: util.NO_OUTPUT,
+		directory:  d,
+		policy:     policy,
+		refCounts:  make(map[string]*RefCount),
+		writer:     &IndexWriter{ClosingControl: newClosingControl()},
+	}
+}
+
+// Writes an empty segments_N file and makes sis refer to it.
+func setSegmentsGeneration(t *testing.T, sis *SegmentInfos, d store.Directory, gen int64) {
+	sis.generation, sis.lastGeneration = gen, gen
+	out, err := d.CreateOutput(sis.SegmentsFileName(), store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFileExists(t *testing.T, d store.Directory, name string, exists bool) {
+	if d.FileExists(name) != exists {
+		t.Errorf("Expected file '%v' to exist: %v", name, exists)
+	}
+}
+
+func TestFileDeleterCheckpoint(t *testing.T) {
+	d := store.NewRAMDirectory()
+	fd := newTestFileDeleter(d, NO_DELETION_POLICY)
+	sis := &SegmentInfos{}
+	first := addSyntheticSegment(t, sis, d, 10, 100)
+	addSyntheticSegment(t, sis, d, 20, 200)
+
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, fd.refCounts["_0.dat"].count)
+
+	// each checkpoint releases the files of the previous one
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, fd.refCounts["_0.dat"].count)
+	assertFileExists(t, d, "_0.dat", true)
+
+	// dropping a segment deletes its file once nothing refers to it
+	sis.remove(first)
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "_0.dat", false)
+	assertFileExists(t, d, "_1.dat", true)
+	_, ok := fd.refCounts["_0.dat"]
+	assertEquals(t, false, ok)
+}
+
+func TestFileDeleterCommitHoldsFiles(t *testing.T) {
+	d := store.NewRAMDirectory()
+	fd := newTestFileDeleter(d, DEFAULT_DELETION_POLICY)
+	sis := &SegmentInfos{}
+	first := addSyntheticSegment(t, sis, d, 10, 100)
+
+	setSegmentsGeneration(t, sis, d, 1)
+	if err := fd.checkpoint(sis, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 2, fd.refCounts["_0.dat"].count)
+
+	// the last commit still refers to _0.dat
+	addSyntheticSegment(t, sis, d, 20, 200)
+	sis.remove(first)
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "_0.dat", true)
+	assertEquals(t, 1, fd.refCounts["_0.dat"].count)
+
+	// until the policy deletes that commit
+	setSegmentsGeneration(t, sis, d, 2)
+	if err := fd.checkpoint(sis, true); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(fd.commits))
+	assertFileExists(t, d, "_0.dat", false)
+	assertFileExists(t, d, "segments_1", false)
+	assertFileExists(t, d, "segments_2", true)
+	assertFileExists(t, d, "_1.dat", true)
+}
+
+// Fails the first attempt to delete each file.
+type failOnceDirectory struct {
+	store.Directory
+	failed map[string]bool
+}
+
+func (d *failOnceDirectory) DeleteFile(name string) error {
+	if !d.failed[name] {
+		d.failed[name] = true
+		return errors.New("file is in use")
+	}
+	return d.Directory.DeleteFile(name)
+}
+
+func TestFileDeleterRetriesPendingDeletes(t *testing.T) {
+	d := &failOnceDirectory{store.NewRAMDirectory(), make(map[string]bool)}
+	fd := newTestFileDeleter(d, NO_DELETION_POLICY)
+	sis := &SegmentInfos{}
+	first := addSyntheticSegment(t, sis, d, 10, 100)
+	addSyntheticSegment(t, sis, d, 20, 200)
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+
+	sis.remove(first)
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "_0.dat", true)
+	assertEquals(t, true, fd.deletable["_0.dat"])
+
+	// the next checkpoint tries again
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "_0.dat", false)
+	assertEquals(t, 0, len(fd.deletable))
+}
+
+func TestFileDeleterRefreshKeepsSegmentsInFlight(t *testing.T) {
+	d := store.NewRAMDirectory()
+	fd := newTestFileDeleter(d, NO_DELETION_POLICY)
+	sis := &SegmentInfos{}
+	addSyntheticSegment(t, sis, d, 10, 100)
+	if err := fd.checkpoint(sis, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// _1 is still being flushed; _2 was left behind by a failed one
+	addSyntheticSegment(t, sis, d, 20, 200)
+	addSyntheticSegment(t, sis, d, 30, 300)
+	fd.writer.segmentsInFlight = map[string]bool{"_1": true}
+	if err := fd.refresh(""); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "_0.dat", true)
+	assertFileExists(t, d, "_1.dat", true)
+	assertFileExists(t, d, "_2.dat", false)
+}
+
+func TestCommitPointDelete(t *testing.T) {
+	d := store.NewRAMDirectory()
+	var commitsToDelete []*CommitPoint
+	var commits []*CommitPoint
+	sis := &SegmentInfos{}
+	for gen := int64(1); gen <= 3; gen++ {
+		addSyntheticSegment(t, sis, d, 10*int(gen), 100)
+		setSegmentsGeneration(t, sis, d, gen)
+		commits = append(commits, newCommitPoint(&commitsToDelete, d, sis))
+	}
+	files, err := d.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, cp := range commits {
+		assertEquals(t, int64(i+1), cp.Generation())
+		assertEquals(t, i+1, cp.SegmentCount())
+		assertEquals(t, false, cp.IsDeleted())
+	}
+	commits[0].Delete()
+	commits[2].Delete()
+	commits[2].Delete() // recorded once
+	assertEquals(t, true, commits[0].IsDeleted())
+	assertEquals(t, false, commits[1].IsDeleted())
+	assertEquals(t, true, commits[2].IsDeleted())
+	assertEquals(t, 2, len(commitsToDelete))
+	assertEquals(t, commits[0], commitsToDelete[0])
+	assertEquals(t, commits[2], commitsToDelete[1])
+
+	// the deleter removes the files later; Delete() only marks the commit
+	after, err := d.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(files), len(after))
+	for _, cp := range commits {
+		assertFileExists(t, d, cp.SegmentsFileName(), true)
+	}
+}
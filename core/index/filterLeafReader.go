@@ -0,0 +1,106 @@
+package index
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// index/FilterAtomicReader.java
+
+/*
+A FilterLeafReader contains another AtomicReader, which it uses as
+its basic source of data, possibly transforming the data along the
+way or providing additional functionality. FilterLeafReader itself
+simply forwards all requests to the contained reader. Wrappers
+embedding FilterLeafReader may further override some of these
+methods and may also provide additional methods and fields.
+
+The wrapped reader is owned by the filter: closing the filter
+decRefs the wrapped reader.
+
+NOTE: if a wrapper overrides one of the SPI methods, it must be
+passed to newAtomicReader() itself, as done by NewFilterLeafReader(),
+so that calls made through the embedded AtomicReaderImpl reach the
+override.
+*/
+type FilterLeafReader struct {
+	*AtomicReaderImpl
+	in AtomicReader
+}
+
+/*
+Construct a FilterLeafReader based on the specified base reader.
+
+Note that base reader is closed if this FilterLeafReader is closed.
+*/
+func NewFilterLeafReader(in AtomicReader) *FilterLeafReader {
+	assert(in != nil)
+	ans := &FilterLeafReader{in: in}
+	ans.AtomicReaderImpl = newAtomicReader(ans)
+	in.registerParentReader(ans)
+	return ans
+}
+
+// Return the wrapped AtomicReader.
+func (r *FilterLeafReader) Delegate() AtomicReader {
+	return r.in
+}
+
+func (r *FilterLeafReader) LiveDocs() util.Bits {
+	r.ensureOpen()
+	return r.in.LiveDocs()
+}
+
+func (r *FilterLeafReader) Fields() Fields {
+	r.ensureOpen()
+	return r.in.Fields()
+}
+
+func (r *FilterLeafReader) NumericDocValues(field string) (NumericDocValues, error) {
+	r.ensureOpen()
+	return r.in.NumericDocValues(field)
+}
+
+func (r *FilterLeafReader) SortedDocValues(field string) (SortedDocValues, error) {
+	r.ensureOpen()
+	return r.in.SortedDocValues(field)
+}
+
+func (r *FilterLeafReader) PointValues(field string) (PointValues, error) {
+	r.ensureOpen()
+	return r.in.PointValues(field)
+}
+
+func (r *FilterLeafReader) NormValues(field string) (NumericDocValues, error) {
+	r.ensureOpen()
+	return r.in.NormValues(field)
+}
+
+func (r *FilterLeafReader) FieldInfos() FieldInfos {
+	return r.in.FieldInfos()
+}
+
+func (r *FilterLeafReader) NumDocs() int {
+	// Don't call ensureOpen() here (it could affect performance)
+	return r.in.NumDocs()
+}
+
+func (r *FilterLeafReader) MaxDoc() int {
+	// Don't call ensureOpen() here (it could affect performance)
+	return r.in.MaxDoc()
+}
+
+func (r *FilterLeafReader) VisitDocument(docID int, visitor StoredFieldVisitor) error {
+	r.ensureOpen()
+	return r.in.VisitDocument(docID, visitor)
+}
+
+func (r *FilterLeafReader) doClose() error {
+	return r.in.decRef()
+}
+
+func (r *FilterLeafReader) String() string {
+	return fmt.Sprintf("FilterLeafReader(%v)", r.in)
+}
@@ -287,14 +287,8 @@ func (fc *DocumentsWriterFlushControl) waitForFlush() {
 /*
 Sets flush pending state on the given ThreadState. The ThreadState
 must have indexed at least one Document and must not be already
-pending.
+pending. Assumes the caller holds the lock.
 */
-func (fc *DocumentsWriterFlushControl) setFlushPending(perThread *ThreadState) {
-	fc.Lock()
-	defer fc.Unlock()
-	fc._setFlushPending(perThread)
-}
-
 func (fc *DocumentsWriterFlushControl) _setFlushPending(perThread *ThreadState) {
 	assert(!perThread.flushPending)
 	if perThread.dwpt.numDocsInRAM > 0 {
@@ -595,6 +589,7 @@ func (fc *DocumentsWriterFlushControl) abortPendingFlushes(newFiles map[string]b
 	for e := fc.flushQueue.Front(); e != nil; e = e.Next() {
 		dwpt := e.Value.(*DocumentsWriterPerThread)
 		fc.documentsWriter.subtractFlushedNumDocs(dwpt.numDocsInRAM)
+		fc.documentsWriter.putEvent(newSegmentAbortedEvent(dwpt.segmentInfo.Name))
 		fc.doAfterFlush(dwpt)
 	}
 
@@ -603,6 +598,7 @@ func (fc *DocumentsWriterFlushControl) abortPendingFlushes(newFiles map[string]b
 		fc.flushingWriters[blockedFlush.dwpt] = blockedFlush.bytes
 		fc.documentsWriter.subtractFlushedNumDocs(blockedFlush.dwpt.numDocsInRAM)
 		blockedFlush.dwpt.abort(newFiles)
+		fc.documentsWriter.putEvent(newSegmentAbortedEvent(blockedFlush.dwpt.segmentInfo.Name))
 		fc.doAfterFlush(blockedFlush.dwpt)
 	}
 }
@@ -204,6 +204,33 @@ func (conf *LiveIndexWriterConfigImpl) SetMergePolicy(mergePolicy MergePolicy) *
 	return conf
 }
 
+/*
+Determines the amount of RAM that may be used for buffering added
+documents and deletions before they are flushed to the Directory.
+Generally for faster indexing performance it's best to flush by RAM
+usage instead of document count and use as large a RAM buffer as you
+can.
+
+When this is set, the writer will flush whenever buffered documents
+and deletions use this much RAM. Pass in DISABLE_AUTO_FLUSH to
+prevent triggering a flush due to RAM usage. Note that if flushing by
+document count is also enabled, then the flush will be triggered by
+whichever comes first.
+
+The default value is DEFAULT_RAM_BUFFER_SIZE_MB.
+
+Takes effect immediately, but only the next time a document is added,
+updated or deleted.
+*/
+func (conf *LiveIndexWriterConfigImpl) SetRAMBufferSizeMB(ramBufferSizeMB float64) *LiveIndexWriterConfigImpl {
+	assert2(ramBufferSizeMB == DISABLE_AUTO_FLUSH || ramBufferSizeMB > 0,
+		"ramBufferSize should be > 0.0 MB when enabled")
+	assert2(ramBufferSizeMB != DISABLE_AUTO_FLUSH || conf.maxBufferedDocs != DISABLE_AUTO_FLUSH,
+		"at least one of ramBufferSize and maxBufferedDocs must be enabled")
+	conf.ramBufferSizeMB = ramBufferSizeMB
+	return conf
+}
+
 func (conf *LiveIndexWriterConfigImpl) RAMBufferSizeMB() float64 {
 	return conf.ramBufferSizeMB
 }
@@ -3,6 +3,7 @@ package index
 import (
 	"fmt"
 	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/store"
 	// "github.com/balzaczyy/golucene/core/util"
 	"io"
 	"math"
@@ -34,13 +35,42 @@ type CheckAbort interface {
 	// time-consuming code into SegmentMerger, you should test
 	// different values for units to ensure that the time inbetwen
 	// calls to merge.checkAborted is up to ~ 1 second.
-	work(float64) error
+	Work(units float64) error
+}
+
+// Units of work after which a merge checks whether it was aborted.
+const CHECK_ABORT_INTERVAL = 10000.0
+
+/*
+Checks whether a merge was aborted every CHECK_ABORT_INTERVAL units
+of work, returning MergeAbortedError if so. Since the check blocks
+while the merge is paused, this is also where a merge yields to the
+MergeScheduler.
+*/
+type checkAbortMerge struct {
+	merge     *OneMerge
+	workCount float64 // since the last check
+	total     float64
+}
+
+func newCheckAbort(merge *OneMerge) *checkAbortMerge {
+	return &checkAbortMerge{merge: merge}
+}
+
+func (ca *checkAbortMerge) Work(units float64) error {
+	ca.workCount += units
+	ca.total += units
+	if ca.workCount >= CHECK_ABORT_INTERVAL {
+		ca.workCount = 0
+		return ca.merge.checkAborted()
+	}
+	return nil
 }
 
 /* If you use this: IW.close(false) cannot abort your merge! */
 type CheckAbortNone int
 
-func (ca CheckAbortNone) work(units float64) error { return nil } // do nothing
+func (ca CheckAbortNone) Work(units float64) error { return nil } // do nothing
 
 // index/SerialMergeScheduler.java
 
@@ -71,6 +101,33 @@ func (ms *SerialMergeScheduler) Merge(writer *IndexWriter,
 
 func (ms *SerialMergeScheduler) Close() error { return nil }
 
+// index/NoMergeScheduler.java
+
+/*
+A MergeScheduler which never executes any merges. It is also a
+singleton and can be accessed through NO_MERGE_SCHEDULER. Use it if
+you want to prevent an IndexWriter from ever executing merges,
+regardless of the MergePolicy used. Note that you can achieve the
+same thing by using NoMergePolicy, however with NoMergeScheduler you
+also ensure that no unnecessary code of any MergeScheduler
+implementation is ever executed. Hence it is recommended to use both
+if you want to disable merges from ever happening.
+*/
+type NoMergeScheduler bool
+
+const NO_MERGE_SCHEDULER = NoMergeScheduler(true)
+
+func (ms NoMergeScheduler) Merge(writer *IndexWriter,
+	trigger MergeTrigger, newMergesFound bool) error {
+	return nil
+}
+
+func (ms NoMergeScheduler) Close() error { return nil }
+
+func (ms NoMergeScheduler) Clone() MergeScheduler {
+	return ms
+}
+
 // index/MergePolicy.java
 
 // Default max segment size in order to use compound file system.
@@ -101,6 +158,9 @@ The default MergePolicy is TieredMergePolicy.
 type MergePolicy interface {
 	SetNoCFSRatio(noCFSRatio float64)
 	SetMaxCFSSegmentSizeMB(v float64)
+	// Returns true if the new segment (which may be the result of a
+	// merge) should use the compound file format.
+	UseCompoundFile(*SegmentInfos, *SegmentCommitInfo, *IndexWriter) (bool, error)
 	MergeSpecifier
 }
 
@@ -185,14 +245,49 @@ pending deletes, is in the same dir as the writer, and matches the
 current compound file setting)
 */
 func (mp *MergePolicyImpl) isMerged(infos *SegmentInfos,
-	info *SegmentCommitInfo, w *IndexWriter) bool {
-	panic("not implemented yet")
+	info *SegmentCommitInfo, w *IndexWriter) (bool, error) {
 	assert(w != nil)
 	hasDeletions := w.readerPool.numDeletedDocs(info) > 0
-	return !hasDeletions &&
-		!info.Info.HasSeparateNorms() &&
-		info.Info.Dir == w.directory &&
-		(mp.noCFSRatio > 0 && mp.noCFSRatio < 1 || mp.maxCFSSegmentSize < math.MaxInt64)
+	if hasDeletions || info.Info.HasSeparateNorms() || info.Info.Dir != w.directory {
+		return false, nil
+	}
+	useCFS, err := mp.UseCompoundFile(infos, info, w)
+	if err != nil {
+		return false, err
+	}
+	return useCFS == info.Info.IsCompoundFile(), nil
+}
+
+/*
+Returns true if the new segment (which may be the result of a merge)
+should use the compound file format: unless it's larger than
+maxCFSSegmentSize, or than noCFSRatio of the whole index.
+*/
+func (mp *MergePolicyImpl) UseCompoundFile(infos *SegmentInfos,
+	mergedInfo *SegmentCommitInfo, w *IndexWriter) (bool, error) {
+
+	if mp.noCFSRatio == 0 {
+		return false, nil
+	}
+	mergedInfoSize, err := mp.SizeSPI.Size(mergedInfo, w)
+	if err != nil {
+		return false, err
+	}
+	if float64(mergedInfoSize) > mp.maxCFSSegmentSize {
+		return false, nil
+	}
+	if mp.noCFSRatio >= 1 {
+		return true, nil
+	}
+	var totalSize int64
+	for _, info := range infos.Segments {
+		n, err := mp.SizeSPI.Size(info, w)
+		if err != nil {
+			return false, err
+		}
+		totalSize += n
+	}
+	return float64(mergedInfoSize) <= mp.noCFSRatio*float64(totalSize), nil
 }
 
 /*
@@ -223,6 +318,46 @@ func (mp *MergePolicyImpl) SetMaxCFSSegmentSizeMB(v float64) {
 	}
 }
 
+// index/NoMergePolicy.java
+
+/*
+A MergePolicy which never returns merges to execute. Use it if you
+want to prevent segment merges, e.g. while bulk-loading an index
+which is merged later on. It is a singleton, accessed through
+NO_MERGE_POLICY.
+
+NOTE: forceMerge() becomes a no-op under this policy, since it never
+finds any merge to do either.
+*/
+type NoMergePolicy bool
+
+const NO_MERGE_POLICY = NoMergePolicy(true)
+
+func (mp NoMergePolicy) FindMerges(MergeTrigger, *SegmentInfos, *IndexWriter) (MergeSpecification, error) {
+	return nil, nil
+}
+
+func (mp NoMergePolicy) FindForcedMerges(*SegmentInfos, int,
+	map[*SegmentCommitInfo]bool, *IndexWriter) (MergeSpecification, error) {
+	return nil, nil
+}
+
+func (mp NoMergePolicy) FindForcedDeletesMerges(*SegmentInfos, *IndexWriter) (MergeSpecification, error) {
+	return nil, nil
+}
+
+// Segments are never merged, so the compound file settings don't apply.
+func (mp NoMergePolicy) SetNoCFSRatio(noCFSRatio float64) {}
+func (mp NoMergePolicy) SetMaxCFSSegmentSizeMB(v float64) {}
+
+func (mp NoMergePolicy) UseCompoundFile(*SegmentInfos, *SegmentCommitInfo, *IndexWriter) (bool, error) {
+	return false, nil
+}
+
+func (mp NoMergePolicy) String() string {
+	return "NoMergePolicy"
+}
+
 // Passed to MergePolicy.FindMerges(MergeTrigger, SegmentInfos) to
 // indicate the event that triggered the merge
 type MergeTrigger int
@@ -276,6 +411,24 @@ type OneMerge struct {
 	// accounting for deletions.
 	totalDocCount int
 	aborted       bool
+	paused        bool
+	pauseCond     *sync.Cond // signaled when unpaused or aborted
+
+	// Estimated size in bytes of the merged segment, ignoring deleted
+	// docs; set by estimateMergeBytes().
+	estimatedMergeBytes int64
+
+	// The writer's directory wrapped by the MergeScheduler while the
+	// merge runs, e.g. to rate limit its writes; nil if not wrapped.
+	mergeDirectory store.Directory
+	// The IOContext set by the MergeScheduler for the merge, e.g. with
+	// larger buffers; the zero value for the default one.
+	mergeContext store.IOContext
+
+	// The merged segment, set by mergeInit().
+	info *SegmentCommitInfo
+	// Readers of the segments, opened by mergeMiddle().
+	readers []*SegmentReader
 }
 
 func NewOneMerge(segments []*SegmentCommitInfo) *OneMerge {
@@ -287,17 +440,138 @@ func NewOneMerge(segments []*SegmentCommitInfo) *OneMerge {
 	for _, info := range segments {
 		count += info.Info.DocCount()
 	}
+	lock := &sync.Mutex{}
 	return &OneMerge{
+		Locker:         lock,
 		maxNumSegments: -1,
 		segments:       segments2,
 		totalDocCount:  count,
+		pauseCond:      sync.NewCond(lock),
 	}
 }
 
+/* Record that an error occurred while executing this merge. */
 func (m *OneMerge) abort() {
 	m.Lock()
 	defer m.Unlock()
 	m.aborted = true
+	m.pauseCond.Broadcast()
+}
+
+/*
+Returns MergeAbortedError if this merge was aborted. Blocks while the
+merge is paused.
+*/
+func (m *OneMerge) checkAborted() error {
+	m.Lock()
+	defer m.Unlock()
+	for m.paused && !m.aborted {
+		m.pauseCond.Wait()
+	}
+	if m.aborted {
+		return MergeAbortedError(fmt.Sprintf("merge of %v segments is aborted", len(m.segments)))
+	}
+	return nil
+}
+
+/*
+Returns true if this merge was aborted. Unlike checkAborted(), it
+doesn't wait while the merge is paused, so it's safe to call while
+holding IndexWriter's lock.
+*/
+func (m *OneMerge) isAborted() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.aborted
+}
+
+/* Set or clear whether this merge is paused (for example ConcurrentMergeScheduler will pause merges if too many are running). */
+func (m *OneMerge) setPause(paused bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.paused = paused
+	if !paused {
+		// wakeup merge routine, if it's waiting
+		m.pauseCond.Broadcast()
+	}
+}
+
+/* Returns true if this merge is paused. */
+func (m *OneMerge) isPaused() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.paused
+}
+
+/* Returns the directory the merged segment should be written through, given the writer's one. */
+func (m *OneMerge) directory(dir store.Directory) store.Directory {
+	if m.mergeDirectory != nil {
+		return m.mergeDirectory
+	}
+	return dir
+}
+
+/* Returns the IOContext the segments should be read and the merged one written with. */
+func (m *OneMerge) context() store.IOContext {
+	if m.mergeContext.MergeInfo != nil {
+		return m.mergeContext
+	}
+	return store.NewIOContextForMerge(m.MergeInfo())
+}
+
+// Total number of documents in segments to be merged, not accounting
+// for deletions.
+func (m *OneMerge) TotalDocCount() int {
+	return m.totalDocCount
+}
+
+// Returns the MergeInfo describing this merge, for its IOContext.
+func (m *OneMerge) MergeInfo() *store.MergeInfo {
+	return &store.MergeInfo{
+		TotalDocCount:       m.totalDocCount,
+		MergeMaxNumSegments: m.maxNumSegments,
+	}
+}
+
+/*
+Estimates the size of the merged segment as the size of the merging
+segments, less the share of their deleted docs, the same way
+MergePolicyImpl.Size() does.
+*/
+func (m *OneMerge) estimateMergeBytes(pool *ReaderPool) error {
+	var total int64
+	for _, info := range m.segments {
+		byteSize, err := info.SizeInBytes()
+		if err != nil {
+			return err
+		}
+		if docCount := info.Info.DocCount(); docCount > 0 {
+			delRatio := float64(pool.numDeletedDocs(info)) / float64(docCount)
+			assert(delRatio <= 1)
+			byteSize = int64(float64(byteSize) * (1 - delRatio))
+		}
+		total += byteSize
+	}
+	m.estimatedMergeBytes = total
+	return nil
+}
+
+/*
+Returns MergeTooLargeError if the merged segment would hold more than
+maxDocs live documents. Deletions are taken from the ReaderPool, so
+pending deletes that will be dropped by the merge are not counted.
+*/
+func (m *OneMerge) checkDocCount(pool *ReaderPool, maxDocs int) error {
+	var liveDocCount int64
+	for _, info := range m.segments {
+		liveDocCount += int64(info.Info.DocCount() - pool.numDeletedDocs(info))
+	}
+	if liveDocCount > int64(maxDocs) {
+		return MergeTooLargeError(fmt.Sprintf(
+			"merge of %v segments would produce %v documents, exceeding the limit of %v",
+			len(m.segments), liveDocCount, maxDocs))
+	}
+	return nil
 }
 
 /*
@@ -318,6 +592,17 @@ func (err MergeAbortedError) Error() string {
 	return string(err)
 }
 
+/*
+Returned by IndexWriter when a merge would produce a segment with
+more than MAX_DOCS documents. Nothing has been written when this
+error is returned, so the MergePolicy may select a smaller merge.
+*/
+type MergeTooLargeError string
+
+func (err MergeTooLargeError) Error() string {
+	return string(err)
+}
+
 // index/TieredMergePolicy.java
 
 // Default noCFSRatio. If a merge's size is >= 10% of the index, then
@@ -390,6 +675,11 @@ func (tmp *TieredMergePolicy) SetMaxMergeAtOnce(v int) *TieredMergePolicy {
 	return tmp
 }
 
+/* Returns the current maxMergeAtOnce setting. */
+func (tmp *TieredMergePolicy) MaxMergeAtOnce() int {
+	return tmp.maxMergeAtOnce
+}
+
 /*
 Maximum number of segments to be merged at a time, during forceMerge
 or forceMergeDeletes. Default is 30.
@@ -400,6 +690,11 @@ func (tmp *TieredMergePolicy) SetMaxMergeAtOnceExplicit(v int) *TieredMergePolic
 	return tmp
 }
 
+/* Returns the current maxMergeAtOnceExplicit setting. */
+func (tmp *TieredMergePolicy) MaxMergeAtOnceExplicit() int {
+	return tmp.maxMergeAtOnceExplicit
+}
+
 /*
 Maximum sized segment to produce during normal merging. This setting
 is approximate: the estimate of the merged segment size is made by
@@ -416,6 +711,11 @@ func (tmp *TieredMergePolicy) SetMaxMergedSegmentMB(v float64) *TieredMergePolic
 	return tmp
 }
 
+/* Returns the current maxMergedSegmentMB setting. */
+func (tmp *TieredMergePolicy) MaxMergedSegmentMB() float64 {
+	return float64(tmp.maxMergedSegmentBytes) / 1024 / 1024
+}
+
 /*
 Controls how aggressively merges that reclaim more deletions are
 favored. Higher values will more aggresively target merges that
@@ -429,6 +729,11 @@ func (tmp *TieredMergePolicy) SetReclaimDeletesWeight(v float64) *TieredMergePol
 	return tmp
 }
 
+/* See SetReclaimDeletesWeight(). */
+func (tmp *TieredMergePolicy) ReclaimDeletesWeight() float64 {
+	return tmp.reclaimDeletesWeight
+}
+
 /*
 Segments smaller than this are "rounded up" to this size, ie treated
 as equal (floor) size for merge selection. This is to prevent
@@ -445,6 +750,11 @@ func (tmp *TieredMergePolicy) SetFloorSegmentMB(v float64) *TieredMergePolicy {
 	return tmp
 }
 
+/* Returns the current floorSegmentMB. */
+func (tmp *TieredMergePolicy) FloorSegmentMB() float64 {
+	return float64(tmp.floorSegmentBytes) / 1024 / 1024
+}
+
 /*
 When forceMergeDeletes is called, we only merge away a segment if its
 delete percentage is over this threshold. Default is 10%.
@@ -455,6 +765,11 @@ func (tmp *TieredMergePolicy) SetForceMergeDeletesPctAllowed(v float64) *TieredM
 	return tmp
 }
 
+/* Returns the current forceMergeDeletesPctAllowed setting. */
+func (tmp *TieredMergePolicy) ForceMergeDeletesPctAllowed() float64 {
+	return tmp.forceMergeDeletesPctAllowed
+}
+
 /*
 Sets the allowed number of segments per tier. Smaller values mean
 more merging but fewer segments.
@@ -468,6 +783,11 @@ func (tmp *TieredMergePolicy) SetSegmentsPerTier(v float64) *TieredMergePolicy {
 	return tmp
 }
 
+/* Returns the current segmentsPerTier setting. */
+func (tmp *TieredMergePolicy) SegmentsPerTier() float64 {
+	return tmp.segsPerTier
+}
+
 type BySizeDescendingSegments struct {
 	values []*SegmentCommitInfo
 	writer *IndexWriter
@@ -484,12 +804,32 @@ func (a *BySizeDescendingSegments) Less(i, j int) bool {
 	sz2, err = a.spi.Size(a.values[j], a.writer)
 	assert(err == nil)
 	if sz1 != sz2 {
-		return sz1 < sz2
+		return sz1 > sz2
 	}
 	return a.values[i].Info.Name < a.values[j].Info.Name
 }
 
-type MergeScore interface{}
+/* Holds score and explanation for a single candidate merge. */
+type MergeScore interface {
+	// Returns the score for this merge candidate; lower scores are
+	// better.
+	Score() float64
+	// Returns a human readable explanation of how the merge got this
+	// score.
+	Explanation() string
+}
+
+type tieredMergeScore struct {
+	score, skew, nonDelRatio float64
+}
+
+func (s *tieredMergeScore) Score() float64 {
+	return s.score
+}
+
+func (s *tieredMergeScore) Explanation() string {
+	return fmt.Sprintf("skew=%.3f nonDelRatio=%.3f", s.skew, s.nonDelRatio)
+}
 
 func (tmp *TieredMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 	infos *SegmentInfos, w *IndexWriter) (spec MergeSpecification, err error) {
@@ -522,7 +862,7 @@ func (tmp *TieredMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 			}
 			if segBytes >= tmp.maxMergedSegmentBytes/2 {
 				extra += " [skip: too large]"
-			} else {
+			} else if segBytes < tmp.floorSegmentBytes {
 				extra += " [floored]"
 			}
 			tmp.message(w, "  seg=%v size=%v MB%v",
@@ -588,12 +928,12 @@ func (tmp *TieredMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 			}
 		}
 
-		// maxMergeIsRunning := mergingBytes >= tmp.maxMergedSegmentBytes
+		maxMergeIsRunning := mergingBytes >= tmp.maxMergedSegmentBytes
 
 		if tmp.verbose(w) {
 			tmp.message(w,
 				"  allowedSegmentCount=%v vs count=%v (eligible count=%v) tooBigCount=%v",
-				allowedSegCountInt, len(infosSorted), len(eligible), tooBigCount, w)
+				allowedSegCountInt, len(infosSorted), len(eligible), tooBigCount)
 		}
 
 		if len(eligible) == 0 {
@@ -603,16 +943,16 @@ func (tmp *TieredMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 		if len(eligible) > allowedSegCountInt {
 
 			// OK we are over budget -- find best merge!
-			// var bestScore MergeScore
+			var bestScore MergeScore
 			var best []*SegmentCommitInfo
-			// var bestTooLarge bool
-			// var bestMergeBytes int64
+			var bestTooLarge bool
+			var bestMergeBytes int64
 
 			// Consider all merge starts:
-			for startIdx := 0; startIdx < len(eligible)-tmp.maxMergeAtOnce; startIdx++ {
-				var totAfterMergesBytes int64
+			for startIdx := 0; startIdx <= len(eligible)-tmp.maxMergeAtOnce; startIdx++ {
+				var totAfterMergeBytes int64
 				var candidate []*SegmentCommitInfo
-				// var hitTooLarge bool
+				var hitTooLarge bool
 				for idx := startIdx; idx < len(eligible) && len(candidate) < tmp.maxMergeAtOnce; idx++ {
 					info := eligible[idx]
 					var segBytes int64
@@ -620,30 +960,208 @@ func (tmp *TieredMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 						return nil, err
 					}
 
-					if totAfterMergesBytes+segBytes > tmp.maxMergedSegmentBytes {
-						panic("niy")
+					if totAfterMergeBytes+segBytes > tmp.maxMergedSegmentBytes {
+						hitTooLarge = true
+						// NOTE: we continue, so that we can try "packing" smaller
+						// segments into this merge to see if we can get closer to
+						// the max size; this in general is not perfect since this
+						// is really "bin packing" and we'd have to try different
+						// permutations.
+						continue
 					}
-					panic("niy")
+					candidate = append(candidate, info)
+					totAfterMergeBytes += segBytes
 				}
 
-				panic("niy")
+				// We should never see an empty candidate: we iterated over
+				// maxMergeAtOnce segments, and already pre-excluded the
+				// too-large segments:
+				assert(len(candidate) > 0)
+
+				var score MergeScore
+				if score, err = tmp.score(candidate, hitTooLarge, mergingBytes, w); err != nil {
+					return nil, err
+				}
+				if tmp.verbose(w) {
+					tmp.message(w, "  maybe=%v score=%v %v tooLarge=%v size=%v MB",
+						w.readerPool.segmentsToString(candidate), score.Score(),
+						score.Explanation(), hitTooLarge,
+						fmt.Sprintf("%.3f", float64(totAfterMergeBytes)/1024/1024))
+				}
+
+				// If we are already running a max sized merge
+				// (maxMergeIsRunning), don't allow another max sized merge to
+				// kick off:
+				if (bestScore == nil || score.Score() < bestScore.Score()) &&
+					(!hitTooLarge || !maxMergeIsRunning) {
+					best = candidate
+					bestScore = score
+					bestTooLarge = hitTooLarge
+					bestMergeBytes = totAfterMergeBytes
+				}
 			}
 
-			if best != nil {
-				panic("NIY")
-			} else {
+			if best == nil {
 				return spec, nil
 			}
+			merge := NewOneMerge(best)
+			spec = append(spec, merge)
+			for _, info := range merge.segments {
+				toBeMerged[info] = true
+			}
+
+			if tmp.verbose(w) {
+				var tooLarge string
+				if bestTooLarge {
+					tooLarge = " [max merge]"
+				}
+				tmp.message(w, "  add merge=%v size=%v MB score=%v %v%v",
+					w.readerPool.segmentsToString(merge.segments),
+					fmt.Sprintf("%.3f", float64(bestMergeBytes)/1024/1024),
+					fmt.Sprintf("%.3f", bestScore.Score()), bestScore.Explanation(), tooLarge)
+			}
 		} else {
 			return
 		}
 	}
 }
 
+/* Expert: scores one merge; lower scores are better. */
+func (tmp *TieredMergePolicy) score(candidate []*SegmentCommitInfo,
+	hitTooLarge bool, mergingBytes int64, w *IndexWriter) (MergeScore, error) {
+
+	var totBeforeMergeBytes, totAfterMergeBytes, totAfterMergeBytesFloored int64
+	for _, info := range candidate {
+		segBytes, err := tmp.Size(info, w)
+		if err != nil {
+			return nil, err
+		}
+		totAfterMergeBytes += segBytes
+		totAfterMergeBytesFloored += tmp.floorSize(segBytes)
+		n, err := info.SizeInBytes()
+		if err != nil {
+			return nil, err
+		}
+		totBeforeMergeBytes += n
+	}
+
+	// Roughly measure "skew" of the merge, i.e. how "balanced" the
+	// merge is (whether it has one big segment and lots of tiny
+	// segments, or is merging roughly equal sized segments)
+	var skew float64
+	if hitTooLarge {
+		// Pretend the merge has perfect skew; skew doesn't matter in
+		// this case because this merge will not "cascade" and so it
+		// cannot lead to N^2 merge cost over time:
+		skew = 1.0 / float64(tmp.maxMergeAtOnce)
+	} else {
+		first, err := tmp.Size(candidate[0], w)
+		if err != nil {
+			return nil, err
+		}
+		skew = float64(tmp.floorSize(first)) / float64(totAfterMergeBytesFloored)
+	}
+
+	// Strongly favor merges with less skew (smaller mergeScore is
+	// better):
+	mergeScore := skew
+
+	// Gently favor smaller merges over bigger ones. We don't want to
+	// make this exponent too large else we can end up doing poor merges
+	// of small segments in order to avoid the large merges:
+	mergeScore *= math.Pow(float64(totAfterMergeBytes), 0.05)
+
+	// Strongly favor merges that reclaim deletes:
+	nonDelRatio := float64(totAfterMergeBytes) / float64(totBeforeMergeBytes)
+	mergeScore *= math.Pow(nonDelRatio, tmp.reclaimDeletesWeight)
+
+	return &tieredMergeScore{mergeScore, skew, nonDelRatio}, nil
+}
+
 func (tmp *TieredMergePolicy) FindForcedMerges(infos *SegmentInfos,
 	maxSegmentCount int, segmentsToMerge map[*SegmentCommitInfo]bool,
-	w *IndexWriter) (MergeSpecification, error) {
-	panic("not implemented yet")
+	w *IndexWriter) (spec MergeSpecification, err error) {
+
+	if tmp.verbose(w) {
+		tmp.message(w, "findForcedMerges maxSegmentCount=%v infos=%v segmentsToMerge=%v",
+			maxSegmentCount, w.readerPool.segmentsToString(infos.Segments), len(segmentsToMerge))
+	}
+
+	var eligible []*SegmentCommitInfo
+	forceMergeRunning := false
+	merging := w.MergingSegments()
+	segmentIsOriginal := false
+	for _, info := range infos.Segments {
+		if isOriginal, ok := segmentsToMerge[info]; ok {
+			segmentIsOriginal = isOriginal
+			if _, ok := merging[info]; !ok {
+				eligible = append(eligible, info)
+			} else {
+				forceMergeRunning = true
+			}
+		}
+	}
+
+	if len(eligible) == 0 {
+		return nil, nil
+	}
+
+	if maxSegmentCount > 1 && len(eligible) <= maxSegmentCount {
+		if tmp.verbose(w) {
+			tmp.message(w, "already merged")
+		}
+		return nil, nil
+	}
+	if maxSegmentCount == 1 && len(eligible) == 1 {
+		merged := !segmentIsOriginal
+		if !merged {
+			if merged, err = tmp.isMerged(infos, eligible[0], w); err != nil {
+				return nil, err
+			}
+		}
+		if merged {
+			if tmp.verbose(w) {
+				tmp.message(w, "already merged")
+			}
+			return nil, nil
+		}
+	}
+
+	for _, info := range eligible {
+		// BySizeDescendingSegments can't report errors
+		if _, err = tmp.Size(info, w); err != nil {
+			return nil, err
+		}
+	}
+	sort.Sort(&BySizeDescendingSegments{eligible, w, tmp})
+
+	if tmp.verbose(w) {
+		tmp.message(w, "eligible=%v", w.readerPool.segmentsToString(eligible))
+		tmp.message(w, "forceMergeRunning=%v", forceMergeRunning)
+	}
+
+	end := len(eligible)
+
+	// Do full merges, first, backwards:
+	for end >= tmp.maxMergeAtOnceExplicit+maxSegmentCount-1 {
+		merge := NewOneMerge(eligible[end-tmp.maxMergeAtOnceExplicit : end])
+		if tmp.verbose(w) {
+			tmp.message(w, "add merge=%v", w.readerPool.segmentsToString(merge.segments))
+		}
+		spec = append(spec, merge)
+		end -= tmp.maxMergeAtOnceExplicit
+	}
+
+	if spec == nil && !forceMergeRunning {
+		// Do final merge
+		numToMerge := end - maxSegmentCount + 1
+		merge := NewOneMerge(eligible[end-numToMerge : end])
+		if tmp.verbose(w) {
+			tmp.message(w, "add final merge=%v", w.readerPool.segmentsToString(merge.segments))
+		}
+		spec = append(spec, merge)
+	}
+	return spec, nil
 }
 
 func (tmp *TieredMergePolicy) floorSize(bytes int64) int64 {
@@ -680,6 +1198,10 @@ const LEVEL_LOG_SPAN = 0.75
 // Default merge factor, which is how many segments are merged at a time
 const DEFAULT_MERGE_FACTOR = 10
 
+// Default maximum segment size. A segment of this size or larger will
+// never be merged.
+const DEFAULT_MAX_MERGE_DOCS = math.MaxInt32
+
 /*
 This class implements a MergePolicy that tries to merge segments into
 levels of exponentially increasing size, where each level has fewer
@@ -711,6 +1233,9 @@ type LogMergePolicy struct {
 	// If the size of a segment exceeds this value then it will never
 	// be merged during ForceMerge()
 	maxMergeSizeForForcedMerge int64
+	// If a segment has more than this many documents then it will
+	// never be merged.
+	maxMergeDocs int
 	// If true, we pro-rate a segment's size by the percentage of
 	// non-deleted documents.
 	calibrateSizeByDeletes bool
@@ -722,6 +1247,7 @@ func NewLogMergePolicy(min, max int64) *LogMergePolicy {
 		minMergeSize:               min,
 		maxMergeSize:               max,
 		maxMergeSizeForForcedMerge: math.MaxInt64,
+		maxMergeDocs:               DEFAULT_MAX_MERGE_DOCS,
 		calibrateSizeByDeletes:     true,
 	}
 	res.MergePolicyImpl = newMergePolicyImpl(res, DEFAULT_NO_CFS_RATIO, DEFAULT_MAX_CFS_SEGMENT_SIZE)
@@ -754,6 +1280,37 @@ func (mp *LogMergePolicy) SetMergeFactor(mergeFactor int) {
 	mp.mergeFactor = mergeFactor
 }
 
+/*
+Returns the number of segments that are merged at once and also
+controls the total number of segments allowed to accumulate in the
+index.
+*/
+func (mp *LogMergePolicy) MergeFactor() int {
+	return mp.mergeFactor
+}
+
+/*
+Determines the largest segment (measured by document count) that may
+be merged with other segments. Small values (e.g., less than 10,000)
+are best for interactive indexing, as this limits the length of
+pauses while indexing to a few seconds. Larger values are best for
+batched indexing and speedier searches.
+
+The default value is math.MaxInt32.
+
+The default merge policy (LogByteSizeMergePolicy) also allows you to
+set this limit by net size (in MB) of the segment, using
+SetMaxMergeMB().
+*/
+func (mp *LogMergePolicy) SetMaxMergeDocs(maxMergeDocs int) {
+	mp.maxMergeDocs = maxMergeDocs
+}
+
+/* Returns the largest segment (measured by document count) that may be merged with other segments. */
+func (mp *LogMergePolicy) MaxMergeDocs() int {
+	return mp.maxMergeDocs
+}
+
 // Sets whether the segment size should be calibrated by the number
 // of delets when choosing segments to merge
 func (mp *LogMergePolicy) SetCalbrateSizeByDeletes(calibrateSizeByDeletes bool) {
@@ -793,14 +1350,253 @@ than or equal to the specified maxNumSegments.
 */
 func (mp *LogMergePolicy) isMergedBy(infos *SegmentInfos,
 	maxNumSegments int, segmentsToMerge map[*SegmentCommitInfo]bool,
-	w *IndexWriter) bool {
-	panic("not implemented yet")
+	w *IndexWriter) (bool, error) {
+
+	numToMerge := 0
+	var mergeInfo *SegmentCommitInfo
+	segmentIsOriginal := false
+	for _, info := range infos.Segments {
+		if numToMerge > maxNumSegments {
+			break
+		}
+		if isOriginal, ok := segmentsToMerge[info]; ok {
+			segmentIsOriginal = isOriginal
+			numToMerge++
+			mergeInfo = info
+		}
+	}
+
+	if numToMerge > maxNumSegments {
+		return false, nil
+	}
+	if numToMerge != 1 || !segmentIsOriginal {
+		return true, nil
+	}
+	return mp.isMerged(infos, mergeInfo, w)
 }
 
+/*
+Returns the merges necessary to merge the index, taking the max merge
+size or max merge docs into consideration. This method attempts to
+respect the maxNumSegments parameter, however it might be, due to size
+constraints, that more than that number of segments will remain in
+the index. Also, this method does not guarantee that exactly
+maxNumSegments will remain, but <= that number.
+*/
+func (mp *LogMergePolicy) findForcedMergesSizeLimit(infos *SegmentInfos,
+	maxNumSegments, last int, w *IndexWriter) (spec MergeSpecification, err error) {
+
+	segments := infos.Segments
+
+	start := last - 1
+	for start >= 0 {
+		info := segments[start]
+		var tooLarge bool
+		if tooLarge, err = mp.tooLargeForForcedMerge(info, w); err != nil {
+			return nil, err
+		}
+		if tooLarge {
+			mp.message(fmt.Sprintf("findForcedMergesSizeLimit: skip segment=%v: size is > maxMergeSize (%v) or sizeDocs is > maxMergeDocs (%v)",
+				info, mp.maxMergeSizeForForcedMerge, mp.maxMergeDocs), w)
+			// need to skip that segment + add a merge for the 'right'
+			// segments, unless there is only 1 which is merged.
+			if last-start-1 > 1 {
+				spec = append(spec, NewOneMerge(segments[start+1:last]))
+			} else if start != last-1 {
+				var merged bool
+				if merged, err = mp.isMerged(infos, segments[start+1], w); err != nil {
+					return nil, err
+				}
+				if !merged {
+					// a mergeable single segment
+					spec = append(spec, NewOneMerge(segments[start+1:last]))
+				}
+			}
+			last = start
+		} else if last-start == mp.mergeFactor {
+			// mergeFactor eligible segments were found, add them as a merge.
+			spec = append(spec, NewOneMerge(segments[start:last]))
+			last = start
+		}
+		start--
+	}
+
+	// Add any left-over segments, unless there is just 1 already fully
+	// merged
+	if last > 0 {
+		start++
+		merged := false
+		if start+1 >= last {
+			if merged, err = mp.isMerged(infos, segments[start], w); err != nil {
+				return nil, err
+			}
+		}
+		if !merged {
+			spec = append(spec, NewOneMerge(segments[start:last]))
+		}
+	}
+	return spec, nil
+}
+
+/*
+Returns the merges necessary to forceMerge the index. This method
+constraints the returned merges only by the maxNumSegments parameter,
+and guaranteed that exactly that number of segments will remain in
+the index.
+*/
+func (mp *LogMergePolicy) findForcedMergesMaxNumSegments(infos *SegmentInfos,
+	maxNumSegments, last int, w *IndexWriter) (spec MergeSpecification, err error) {
+
+	segments := infos.Segments
+
+	// First, enroll all "full" merges (size mergeFactor) to potentially
+	// be run concurrently:
+	for last-maxNumSegments+1 >= mp.mergeFactor {
+		spec = append(spec, NewOneMerge(segments[last-mp.mergeFactor:last]))
+		last -= mp.mergeFactor
+	}
+
+	// Only if there are no full merges pending do we add a final
+	// partial (< mergeFactor segments) merge:
+	if len(spec) > 0 {
+		return spec, nil
+	}
+	if maxNumSegments == 1 {
+		// Since we must merge down to 1 segment, the choice is simple:
+		merged := false
+		if last <= 1 {
+			if merged, err = mp.isMerged(infos, segments[0], w); err != nil {
+				return nil, err
+			}
+		}
+		if !merged {
+			spec = append(spec, NewOneMerge(segments[:last]))
+		}
+	} else if last > maxNumSegments {
+		// Take care to pick a partial merge that is least cost, but does
+		// not make the index too lopsided. If we always just picked the
+		// partial tail then we could produce a highly lopsided index
+		// over time:
+
+		// We must merge this many segments to leave maxNumSegments in
+		// the index (from when forceMerge was first kicked off):
+		finalMergeSize := last - maxNumSegments + 1
+
+		// Consider all possible starting points:
+		var bestSize int64
+		bestStart := 0
+
+		for i := 0; i < last-finalMergeSize+1; i++ {
+			var sumSize int64
+			for _, info := range segments[i : i+finalMergeSize] {
+				var n int64
+				if n, err = mp.SizeSPI.Size(info, w); err != nil {
+					return nil, err
+				}
+				sumSize += n
+			}
+			if i == 0 {
+				bestStart, bestSize = i, sumSize
+				continue
+			}
+			var prevSize int64
+			if prevSize, err = mp.SizeSPI.Size(segments[i-1], w); err != nil {
+				return nil, err
+			}
+			if sumSize < 2*prevSize && sumSize < bestSize {
+				bestStart, bestSize = i, sumSize
+			}
+		}
+
+		spec = append(spec, NewOneMerge(segments[bestStart:bestStart+finalMergeSize]))
+	}
+	return spec, nil
+}
+
+// Returns true if info is over maxMergeSizeForForcedMerge or
+// maxMergeDocs, and so is never merged by a forced merge.
+func (mp *LogMergePolicy) tooLargeForForcedMerge(info *SegmentCommitInfo, w *IndexWriter) (bool, error) {
+	size, err := mp.SizeSPI.Size(info, w)
+	if err != nil {
+		return false, err
+	}
+	if size > mp.maxMergeSizeForForcedMerge {
+		return true, nil
+	}
+	docs, err := mp.sizeDocs(info, w)
+	if err != nil {
+		return false, err
+	}
+	return docs > int64(mp.maxMergeDocs), nil
+}
+
+/*
+Returns the merges necessary to merge the index down to a specified
+number of segments. This respects the maxMergeSizeForForcedMerge
+setting. By default, and assuming maxNumSegments=1, only one segment
+will be left in the index, where that segment has no deletions
+pending nor separate norms, and it is in compound file format if the
+current useCompoundFile setting is true. This method returns multiple
+merges (mergeFactor at a time) so the MergeScheduler in use may make
+use of concurrency.
+*/
 func (mp *LogMergePolicy) FindForcedMerges(infos *SegmentInfos,
-	maxSegmentCount int, segmentsToMerge map[*SegmentCommitInfo]bool,
+	maxNumSegments int, segmentsToMerge map[*SegmentCommitInfo]bool,
 	w *IndexWriter) (MergeSpecification, error) {
-	panic("not implemented yet")
+
+	assert(maxNumSegments > 0)
+	mp.message(fmt.Sprintf("findForcedMerges: maxNumSegs=%v segsToMerge=%v",
+		maxNumSegments, len(segmentsToMerge)), w)
+
+	// If the segments are already merged (e.g. there's only 1 segment),
+	// or there are <maxNumSegments:
+	merged, err := mp.isMergedBy(infos, maxNumSegments, segmentsToMerge, w)
+	if err != nil {
+		return nil, err
+	}
+	if merged {
+		mp.message("already merged; skip", w)
+		return nil, nil
+	}
+
+	// Find the newest (rightmost) segment that needs to be merged
+	// (other segments may have been flushed since merging started):
+	last := len(infos.Segments)
+	for last > 0 {
+		last--
+		if _, ok := segmentsToMerge[infos.Segments[last]]; ok {
+			last++
+			break
+		}
+	}
+
+	if last == 0 {
+		mp.message("last == 0; skip", w)
+		return nil, nil
+	}
+
+	// There is only one segment already, and it is merged
+	if maxNumSegments == 1 && last == 1 {
+		if merged, err = mp.isMerged(infos, infos.Segments[0], w); err != nil {
+			return nil, err
+		}
+		if merged {
+			mp.message("already 1 seg; skip", w)
+			return nil, nil
+		}
+	}
+
+	// Check if there are any segments above the threshold
+	for _, info := range infos.Segments[:last] {
+		tooLarge, err := mp.tooLargeForForcedMerge(info, w)
+		if err != nil {
+			return nil, err
+		}
+		if tooLarge {
+			return mp.findForcedMergesSizeLimit(infos, maxNumSegments, last, w)
+		}
+	}
+	return mp.findForcedMergesMaxNumSegments(infos, maxNumSegments, last, w)
 }
 
 type SegmentInfoAndLevel struct {
@@ -835,7 +1631,7 @@ func (mp *LogMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 	mergingSegments := w.mergingSegments
 
 	for i, info := range infos.Segments {
-		size, err := mp.Size(info, w)
+		size, err := mp.SizeSPI.Size(info, w)
 		if err != nil {
 			return nil, err
 		}
@@ -863,7 +1659,7 @@ func (mp *LogMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 			mp.message(fmt.Sprintf("seg=%v level=%v size=%.3f MB%v",
 				w.readerPool.segmentToString(info),
 				infoLevel.level,
-				segBytes/1024/1024,
+				float64(segBytes)/1024/1024,
 				extra), w)
 		}
 	}
@@ -919,7 +1715,41 @@ func (mp *LogMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 		// Finally, record all merges that are viable at this level:
 		end := start + mp.mergeFactor
 		for end <= 1+upto {
-			panic("not implemented yet")
+			anyTooLarge, anyMerging := false, false
+			for _, level := range levels[start:end] {
+				info := level.info
+				size, err := mp.SizeSPI.Size(info, w)
+				if err != nil {
+					return nil, err
+				}
+				docs, err := mp.sizeDocs(info, w)
+				if err != nil {
+					return nil, err
+				}
+				anyTooLarge = anyTooLarge || size >= mp.maxMergeSize || docs >= int64(mp.maxMergeDocs)
+				if _, ok := mergingSegments[info]; ok {
+					anyMerging = true
+					break
+				}
+			}
+
+			if anyMerging {
+				// skip
+			} else if !anyTooLarge {
+				mergeInfos := make([]*SegmentCommitInfo, 0, end-start)
+				for _, level := range levels[start:end] {
+					mergeInfos = append(mergeInfos, level.info)
+				}
+				mp.message(fmt.Sprintf("  add merge=%v start=%v end=%v",
+					w.readerPool.segmentsToString(mergeInfos), start, end), w)
+				spec = append(spec, NewOneMerge(mergeInfos))
+			} else {
+				mp.message(fmt.Sprintf("    %v to %v: contains segment over maxMergeSize or maxMergeDocs; skipping",
+					start, end), w)
+			}
+
+			start = end
+			end = start + mp.mergeFactor
 		}
 
 		start = 1 + upto
@@ -929,7 +1759,17 @@ func (mp *LogMergePolicy) FindMerges(mergeTrigger MergeTrigger,
 }
 
 func (mp *LogMergePolicy) String() string {
-	panic("not implemented yet")
+	name := "LogMergePolicy"
+	switch mp.SizeSPI.(type) {
+	case *LogDocMergePolicy:
+		name = "LogDocMergePolicy"
+	case *LogByteSizeMergePolicy:
+		name = "LogByteSizeMergePolicy"
+	}
+	return fmt.Sprintf("[%v: minMergeSize=%v, mergeFactor=%v, maxMergeSize=%v, maxMergeSizeForForcedMerge=%v, calibrateSizeByDeletes=%v, maxMergeDocs=%v, maxCFSSegmentSizeMB=%v, noCFSRatio=%v]",
+		name, mp.minMergeSize, mp.mergeFactor, mp.maxMergeSize,
+		mp.maxMergeSizeForForcedMerge, mp.calibrateSizeByDeletes,
+		mp.maxMergeDocs, mp.maxCFSSegmentSize/1024/1024, mp.noCFSRatio)
 }
 
 // index/LogDocMergePolicy.java
@@ -945,7 +1785,7 @@ type LogDocMergePolicy struct {
 	*LogMergePolicy
 }
 
-func NewLogDocMergePolicy() *LogMergePolicy {
+func NewLogDocMergePolicy() *LogDocMergePolicy {
 	ans := &LogDocMergePolicy{
 		LogMergePolicy: NewLogMergePolicy(DEFAULT_MIN_MERGE_DOCS, math.MaxInt64),
 	}
@@ -953,13 +1793,31 @@ func NewLogDocMergePolicy() *LogMergePolicy {
 	// set it to math.MaxInt64 to disable it
 	ans.maxMergeSizeForForcedMerge = math.MaxInt64
 	ans.SizeSPI = ans
-	return ans.LogMergePolicy
+	return ans
 }
 
 func (p *LogDocMergePolicy) Size(info *SegmentCommitInfo, w *IndexWriter) (int64, error) {
 	return p.sizeDocs(info, w)
 }
 
+/*
+Sets the minimum size for the lowest level segments. Any segments
+below this size are considered to be on the same level (even if they
+vary drastically in size) and will be merged whenever there are
+mergeFactor of them. This effectively truncates the "long tail" of
+small segments that would otherwise be created into a single level.
+If you set this too large, it could greatly increase the merging
+cost during indexing (if you flush many small segments).
+*/
+func (p *LogDocMergePolicy) SetMinMergeDocs(minMergeDocs int) {
+	p.minMergeSize = int64(minMergeDocs)
+}
+
+/* Get the minimum size for a segment to remain un-merged. */
+func (p *LogDocMergePolicy) MinMergeDocs() int {
+	return int(p.minMergeSize)
+}
+
 // index/LogByteSizeMergePolicy.java
 
 // Default minimum segment size.
@@ -979,16 +1837,60 @@ type LogByteSizeMergePolicy struct {
 	*LogMergePolicy
 }
 
-func NewLogByteSizeMergePolicy() *LogMergePolicy {
+func NewLogByteSizeMergePolicy() *LogByteSizeMergePolicy {
 	ans := &LogByteSizeMergePolicy{
 		LogMergePolicy: NewLogMergePolicy(int64(DEFAULT_MIN_MERGE_MB*1024*1024),
 			int64(DEFAULT_MAX_MERGE_MB*1024*1024)),
 	}
 	ans.maxMergeSizeForForcedMerge = int64(DEFAULT_MAX_MERGE_MB_FOR_FORCED_MERGE * 1024 * 1024)
 	ans.SizeSPI = ans
-	return ans.LogMergePolicy
+	return ans
 }
 
 func (p *LogByteSizeMergePolicy) Size(info *SegmentCommitInfo, w *IndexWriter) (int64, error) {
 	return p.sizeBytes(info, w)
 }
+
+/*
+Determines the largest segment (measured by total byte size of the
+segment's files, in MB) that may be merged with other segments. Small
+values (e.g., less than 50 MB) are best for interactive indexing, as
+this limits the length of pauses while indexing to a few seconds.
+Larger values are best for batched indexing and speedier searches.
+
+Note that SetMaxMergeDocs() is also used to check whether a segment
+is too large for merging (it's either or).
+*/
+func (p *LogByteSizeMergePolicy) SetMaxMergeMB(mb float64) {
+	p.maxMergeSize = mbToBytes(mb)
+}
+
+/* Returns the largest segment (measured by total byte size of the segment's files, in MB) that may be merged with other segments. */
+func (p *LogByteSizeMergePolicy) MaxMergeMB() float64 {
+	return float64(p.maxMergeSize) / 1024 / 1024
+}
+
+/*
+Sets the minimum size for the lowest level segments. Any segments
+below this size are considered to be on the same level (even if they
+vary drastically in size) and will be merged whenever there are
+mergeFactor of them. This effectively truncates the "long tail" of
+small segments that would otherwise be created into a single level.
+If you set this too large, it could greatly increase the merging
+cost during indexing (if you flush many small segments).
+*/
+func (p *LogByteSizeMergePolicy) SetMinMergeMB(mb float64) {
+	p.minMergeSize = mbToBytes(mb)
+}
+
+/* Get the minimum size for a segment to remain un-merged. */
+func (p *LogByteSizeMergePolicy) MinMergeMB() float64 {
+	return float64(p.minMergeSize) / 1024 / 1024
+}
+
+func mbToBytes(mb float64) int64 {
+	if bytes := mb * 1024 * 1024; bytes < math.MaxInt64 {
+		return int64(bytes)
+	}
+	return math.MaxInt64
+}
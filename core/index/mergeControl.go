@@ -41,12 +41,18 @@ func newMergeControl(infoStream util.InfoStream, readerPool *ReaderPool) *MergeC
 Aborts runing merges. Be careful when using this method: when you
 abort a long-running merge, you lose a lot of work that must later be
 redone.
+
+Merges are stopped while aborting, and stay stopped afterwards if
+they were stopped by the caller already.
 */
 func (mc *MergeControl) abortAllMerges() {
 	mc.Lock() // synchronized
 	defer mc.Unlock()
 
-	mc.stopMerges = true
+	stopped := mc.stopMerges
+	if !stopped {
+		mc.stopMerges = true
+	}
 
 	// Abort all pending & running merges:
 	for e := mc.pendingMerges.Front(); e != nil; e = e.Next() {
@@ -81,7 +87,9 @@ func (mc *MergeControl) abortAllMerges() {
 		mc.mergeSignal.Wait()
 	}
 
-	mc.stopMerges = false
+	if !stopped {
+		mc.stopMerges = false
+	}
 
 	assert(len(mc.mergingSegments) == 0)
 
@@ -139,6 +147,6 @@ func (mc *MergeControl) mergeFinish(merge *OneMerge) {
 
 	delete(mc.runningMerges, merge)
 	if len(mc.runningMerges) == 0 {
-		mc.mergeSignal.Signal()
+		mc.mergeSignal.Broadcast()
 	}
 }
@@ -0,0 +1,1493 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	acore "github.com/balzaczyy/golucene/analysis/core"
+	_ "github.com/balzaczyy/golucene/core/codec/lucene410"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	docu "github.com/balzaczyy/golucene/core/document"
+	"github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSyntheticMerge(d store.Directory, delCount int, docCounts ...int) *OneMerge {
+	var infos []*SegmentCommitInfo
+	for i, n := range docCounts {
+		si := model.NewSegmentInfo(d, util.VERSION_LATEST, fmt.Sprintf("_%v", i), n, false, nil, nil)
+		infos = append(infos, NewSegmentCommitInfo(si, delCount, -1, -1, -1))
+	}
+	return NewOneMerge(infos)
+}
+
+func TestMergeDocCountOverflow(t *testing.T) {
+	d := store.NewRAMDirectory()
+	pool := newReaderPool(&IndexWriter{directory: d})
+
+	merge := newSyntheticMerge(d, 0, MAX_DOCS/2, MAX_DOCS/2, 1000)
+	if err := merge.checkDocCount(pool, MAX_DOCS); err == nil {
+		t.Error("merge exceeding MAX_DOCS should be rejected")
+	} else if _, ok := err.(MergeTooLargeError); !ok {
+		t.Errorf("unexpected error type: %T", err)
+	}
+
+	// deleted docs don't count against the limit
+	merge = newSyntheticMerge(d, 1000, MAX_DOCS/2, MAX_DOCS/2, 1000)
+	if err := merge.checkDocCount(pool, MAX_DOCS); err != nil {
+		t.Error(err)
+	}
+	assertEquals(t, 2*(MAX_DOCS/2)+1000, merge.TotalDocCount())
+}
+
+func TestMergeDocCountConfigurableLimit(t *testing.T) {
+	prev := setMaxDocs(10)
+	defer setMaxDocs(prev)
+
+	d := store.NewRAMDirectory()
+	pool := newReaderPool(&IndexWriter{directory: d})
+	if err := newSyntheticMerge(d, 0, 5, 5).checkDocCount(pool, actualMaxDocs); err != nil {
+		t.Error(err)
+	}
+	if err := newSyntheticMerge(d, 0, 5, 6).checkDocCount(pool, actualMaxDocs); err == nil {
+		t.Error("merge exceeding configured limit should be rejected")
+	}
+}
+
+// Storage Spins() knows nothing about, so it's assumed to spin.
+type spinningDirectory struct {
+	*store.RAMDirectory
+}
+
+func TestDefaultMergeSchedulerBySpins(t *testing.T) {
+	for _, c := range []struct {
+		d     store.Directory
+		spins bool
+	}{
+		{store.NewRAMDirectory(), false},
+		{spinningDirectory{store.NewRAMDirectory()}, true},
+	} {
+		cms := NewConcurrentMergeScheduler()
+		conf := &IndexWriterConfig{LiveIndexWriterConfigImpl: &LiveIndexWriterConfigImpl{
+			mergeScheduler: cms,
+		}}
+		conf.initMergeScheduler(c.d)
+		maxMergeCount, maxRoutineCount := DefaultMaxMergesAndRoutines(c.spins)
+		assertEquals(t, maxMergeCount, cms.MaxMergeCount())
+		assertEquals(t, maxRoutineCount, cms.MaxRoutineCount())
+		cms.Close()
+	}
+	if _, n := DefaultMaxMergesAndRoutines(true); n != 1 {
+		t.Errorf("spinning disks should merge with a single routine, got %v", n)
+	}
+
+	// an explicitly set scheduler is left alone
+	cms := NewConcurrentMergeScheduler()
+	conf := &IndexWriterConfig{LiveIndexWriterConfigImpl: &LiveIndexWriterConfigImpl{}}
+	conf.SetMergeScheduler(cms)
+	conf.initMergeScheduler(store.NewRAMDirectory())
+	assertEquals(t, DEFAULT_MAX_ROUTINE_COUNT, cms.MaxRoutineCount())
+	cms.Close()
+}
+
+func TestDefaultMaxMergesAndRoutinesByCPU(t *testing.T) {
+	for _, c := range []struct {
+		spins                          bool
+		numCPU                         int
+		maxMergeCount, maxRoutineCount int
+	}{
+		{true, 1, 6, 1}, {true, 16, 6, 1},
+		{false, 1, 6, 1}, {false, 2, 6, 1}, {false, 4, 7, 2},
+		{false, 6, 8, 3}, {false, 8, 9, 4}, {false, 64, 9, 4},
+	} {
+		maxMergeCount, maxRoutineCount := defaultMaxMergesAndRoutines(c.spins, c.numCPU)
+		if maxMergeCount != c.maxMergeCount || maxRoutineCount != c.maxRoutineCount {
+			t.Errorf("spins=%v numCPU=%v: expected (%v, %v), but (%v, %v)", c.spins, c.numCPU,
+				c.maxMergeCount, c.maxRoutineCount, maxMergeCount, maxRoutineCount)
+		}
+	}
+}
+
+func TestSetMaxMergesAndRoutines(t *testing.T) {
+	cms := NewConcurrentMergeScheduler()
+	defer cms.Close()
+	assertEquals(t, DEFAULT_MAX_MERGE_COUNT, cms.MaxMergeCount())
+	assertEquals(t, DEFAULT_MAX_ROUTINE_COUNT, cms.MaxRoutineCount())
+
+	if err := cms.SetMaxMergesAndRoutines(4, 4); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][2]int{{3, 4}, {0, 0}, {4, 0}, {-1, 1}} {
+		if err := cms.SetMaxMergesAndRoutines(v[0], v[1]); err == nil {
+			t.Errorf("Expected maxMergeCount=%v, maxRoutineCount=%v to be rejected", v[0], v[1])
+		}
+	}
+	// invalid settings are not applied
+	assertEquals(t, 4, cms.MaxMergeCount())
+	assertEquals(t, 4, cms.MaxRoutineCount())
+}
+
+func TestMergeBufferSizeMB(t *testing.T) {
+	cms := NewConcurrentMergeScheduler()
+	defer cms.Close()
+	assertEquals(t, float64(0), cms.MergeBufferSizeMB())
+	cms.SetMergeBufferSizeMB(2)
+	assertEquals(t, float64(2), cms.MergeBufferSizeMB())
+
+	d := store.NewRAMDirectory()
+	ctx := cms.mergeContext(newSyntheticMerge(d, 0, 10, 20))
+	assertEquals(t, 30, ctx.MergeInfo.TotalDocCount)
+	assertEquals(t, store.READ_ADVICE_SEQUENTIAL, ctx.ReadAdvice())
+}
+
+// Records the IOContext of every file opened or created through it.
+type contextRecordingDirectory struct {
+	store.Directory
+	sync.Mutex
+	contexts []store.IOContext
+}
+
+func (d *contextRecordingDirectory) record(ctx store.IOContext) {
+	d.Lock()
+	defer d.Unlock()
+	d.contexts = append(d.contexts, ctx)
+}
+
+func (d *contextRecordingDirectory) OpenInput(name string, ctx store.IOContext) (store.IndexInput, error) {
+	d.record(ctx)
+	return d.Directory.OpenInput(name, ctx)
+}
+
+func (d *contextRecordingDirectory) CreateOutput(name string, ctx store.IOContext) (store.IndexOutput, error) {
+	d.record(ctx)
+	return d.Directory.CreateOutput(name, ctx)
+}
+
+func TestConcurrentMergeSchedulerMergeContext(t *testing.T) {
+	d := &contextRecordingDirectory{Directory: store.NewRAMDirectory()}
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMergeBufferSizeMB(0.5)
+	w := newSegmentedWriter(t, d, cms, 2, 10, 0)
+	merge := registerSegmentMerges(t, w, 2)[0]
+	d.contexts = nil
+	if err := cms.Merge(w, MERGE_TRIGGER_EXPLICIT, true); err != nil {
+		t.Fatal(err)
+	}
+	w.waitForMerges()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the segments are read and the merged one written with larger buffers
+	expected := cms.mergeContext(merge)
+	var merging int
+	for _, ctx := range d.contexts {
+		if ctx.MergeInfo != nil {
+			merging++
+			if !reflect.DeepEqual(expected, ctx) {
+				t.Fatalf("Expected a merge context with %vMB buffers, but was %v",
+					cms.MergeBufferSizeMB(), ctx)
+			}
+		}
+	}
+	if merging == 0 {
+		t.Error("Expected the merge to use the scheduler's context")
+	}
+}
+
+// Runs two merges of 600MB each through the budget gate and returns
+// how many ran at once at most.
+func maxConcurrentBudgetedMerges(t *testing.T, budgetMB float64) int {
+	cms := NewConcurrentMergeScheduler()
+	defer cms.Close()
+	cms.SetMaxConcurrentMergeMB(budgetMB)
+
+	d := store.NewRAMDirectory()
+	var lock sync.Mutex
+	var running, maxRunning int
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		merge := newSyntheticMerge(d, 0, 10)
+		merge.estimatedMergeBytes = 600 * 1024 * 1024
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cms.acquireMergeBytes(merge)
+			lock.Lock()
+			if running++; running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(50 * time.Millisecond) // merging
+			lock.Lock()
+			running--
+			lock.Unlock()
+			cms.releaseMergeBytes(merge)
+		}()
+	}
+	wg.Wait()
+	assertEquals(t, int64(0), cms.runningMergeBytes)
+	return maxRunning
+}
+
+func TestMergeByteBudget(t *testing.T) {
+	if n := maxConcurrentBudgetedMerges(t, 1000); n != 1 {
+		t.Errorf("%v merges ran at once under a tight budget", n)
+	}
+	if n := maxConcurrentBudgetedMerges(t, 2000); n != 2 {
+		t.Errorf("merges didn't run at once under a loose budget (max %v)", n)
+	}
+	if n := maxConcurrentBudgetedMerges(t, 0); n != 2 {
+		t.Errorf("merges didn't run at once without a budget (max %v)", n)
+	}
+	// a merge larger than the whole budget still runs
+	if n := maxConcurrentBudgetedMerges(t, 100); n != 1 {
+		t.Errorf("%v oversized merges ran at once", n)
+	}
+}
+
+func TestMergeByteBudgetSkipsPausedMerges(t *testing.T) {
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxConcurrentMergeMB(1000)
+	d := store.NewRAMDirectory()
+	large, small := newSyntheticMerge(d, 0, 20), newSyntheticMerge(d, 0, 10)
+	large.estimatedMergeBytes = 600 * 1024 * 1024
+	small.estimatedMergeBytes = 600 * 1024 * 1024
+
+	// the large merge is paused in favor of the small one after it got
+	// its budget, as CMS does when the small one starts
+	cms.acquireMergeBytes(large)
+	cms.setPause(large, true)
+	acquired := make(chan bool)
+	go func() {
+		cms.acquireMergeBytes(small)
+		acquired <- true
+	}()
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a paused merge kept its budget from a running one")
+	}
+	assertEquals(t, small.estimatedMergeBytes, cms.runningMergeBytes)
+
+	cms.releaseMergeBytes(small)
+	cms.setPause(large, false)
+	assertEquals(t, large.estimatedMergeBytes, cms.runningMergeBytes)
+	cms.releaseMergeBytes(large)
+	assertEquals(t, int64(0), cms.runningMergeBytes)
+}
+
+func TestMergeByteBudgetUnderIndexing(t *testing.T) {
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	d := store.NewRAMDirectory()
+	cms := NewConcurrentMergeScheduler()
+	// every merge is larger than the budget, and the larger ones get
+	// paused while smaller ones run
+	cms.SetMaxConcurrentMergeMB(0.0001)
+	conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+	conf.SetMergePolicy(NewTieredMergePolicy().SetSegmentsPerTier(2).SetMaxMergeAtOnce(2))
+	conf.SetMergeScheduler(cms)
+	w, err := NewIndexWriter(d, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			doc := docu.NewDocument()
+			doc.Add(docu.NewTextFieldFromString("body", fmt.Sprintf("seg%v doc0", i), docu.STORE_YES))
+			if err := w.AddDocument(doc.Fields()); err != nil {
+				done <- err
+				return
+			}
+			if err := w.Commit(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- w.Close()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("indexing stalled on the merge byte budget")
+	}
+	assertSegmentedDocs(t, d, 20, 1)
+}
+
+// Fails FileLength() once failLength is set.
+type failingLengthDirectory struct {
+	store.Directory
+	failLength bool
+}
+
+func (d *failingLengthDirectory) FileLength(name string) (int64, error) {
+	if d.failLength {
+		return 0, errors.New("simulated FileLength failure")
+	}
+	return d.Directory.FileLength(name)
+}
+
+func TestMergeByteBudgetEstimateError(t *testing.T) {
+	d := &failingLengthDirectory{Directory: store.NewRAMDirectory()}
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxConcurrentMergeMB(1)
+	cms.suppressErrors = true
+	w := newSegmentedWriter(t, d, cms, 2, 10, 0)
+	merge := registerSegmentMerges(t, w, 2)[0]
+	d.failLength = true
+	if err := cms.Merge(w, MERGE_TRIGGER_EXPLICIT, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed merge is released like any other
+	done := make(chan bool)
+	go func() {
+		w.waitForMerges()
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the merge which size couldn't be estimated was never finished")
+	}
+	assertEquals(t, true, merge.isAborted())
+	assertEquals(t, 2, len(w.segmentInfos.Segments))
+	d.failLength = false
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentedDocs(t, d, 2, 10)
+}
+
+// Returns a writer which merges are pulled from by a MergeScheduler.
+func newMergingWriter(d store.Directory, merges ...*OneMerge) *IndexWriter {
+	w := &IndexWriter{Locker: &sync.Mutex{}, directory: d, infoStream: util.NO_OUTPUT}
+	w.readerPool = newReaderPool(w)
+	w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+	for _, merge := range merges {
+		w.pendingMerges.PushBack(merge)
+	}
+	return w
+}
+
+// Norms of every field are the same: scoring doesn't matter here.
+type constantSimilarity struct{}
+
+func (sim constantSimilarity) ComputeNorm(state *FieldInvertState) int64 { return 1 }
+
+/*
+Returns a writer over segmentCount committed segments of docCount
+documents each, with payloadSize random bytes stored per document.
+It merges nothing but what the test registers, with ms.
+*/
+func newSegmentedWriter(t *testing.T, d store.Directory, ms MergeScheduler,
+	segmentCount, docCount, payloadSize int) *IndexWriter {
+
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+	conf.SetMergePolicy(NO_MERGE_POLICY)
+	conf.SetMergeScheduler(ms)
+	w, err := NewIndexWriter(d, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(int64(segmentCount*docCount + payloadSize)))
+	for i := 0; i < segmentCount; i++ {
+		for j := 0; j < docCount; j++ {
+			doc := docu.NewDocument()
+			doc.Add(docu.NewTextFieldFromString("body", fmt.Sprintf("seg%v doc%v", i, j), docu.STORE_YES))
+			if payloadSize > 0 {
+				payload := make([]byte, payloadSize)
+				random.Read(payload)
+				doc.Add(docu.NewStoredFieldFromBytes("payload", payload))
+			}
+			if err = w.AddDocument(doc.Fields()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEquals(t, segmentCount, len(w.segmentInfos.Segments))
+	return w
+}
+
+// Registers merges of the writer's segments, size segments at a time.
+func registerSegmentMerges(t *testing.T, w *IndexWriter, size int) (merges []*OneMerge) {
+	w.Lock()
+	defer w.Unlock()
+	segments := w.segmentInfos.Segments
+	for i := 0; i < len(segments); i += size {
+		merge := NewOneMerge(segments[i : i+size])
+		if registered, err := w.registerMerge(merge); err != nil {
+			t.Fatal(err)
+		} else if !registered {
+			t.Fatalf("merge of %v was not registered", w.readerPool.segmentsToString(merge.segments))
+		}
+		merges = append(merges, merge)
+	}
+	return
+}
+
+// Checks every document indexed by newSegmentedWriter() is searchable and stored.
+func assertSegmentedDocs(t *testing.T, d store.Directory, segmentCount, docCount int) {
+	reader, err := OpenDirectoryReader(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	assertEquals(t, segmentCount*docCount, reader.NumDocs())
+	seen := make(map[string]bool)
+	for i := 0; i < reader.MaxDoc(); i++ {
+		doc, err := reader.Document(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[doc.Get("body")] = true
+	}
+	for i := 0; i < segmentCount; i++ {
+		for j := 0; j < docCount; j++ {
+			if body := fmt.Sprintf("seg%v doc%v", i, j); !seen[body] {
+				t.Errorf("document %q is missing after merge", body)
+			}
+		}
+	}
+	for _, leaf := range reader.Leaves() {
+		terms := leaf.Reader().(AtomicReader).Terms("body")
+		if terms == nil || terms.DocCount() != leaf.Reader().MaxDoc() {
+			t.Errorf("merged postings don't cover every document of the segment")
+		}
+	}
+}
+
+// Meant to be run with -race.
+func TestConcurrentMergeSchedulerMerge(t *testing.T) {
+	const numMerges, docCount = 12, 5
+	d := store.NewRAMDirectory()
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxMergesAndRoutines(4, 2)
+	w := newSegmentedWriter(t, d, cms, 2*numMerges, docCount, 0)
+	merges := registerSegmentMerges(t, w, 2)
+
+	done := make(chan bool)
+	maxRoutines := make(chan int)
+	go func() {
+		var max int
+		for {
+			select {
+			case <-done:
+				maxRoutines <- max
+				return
+			default:
+			}
+			if n := cms.mergeRoutineCount(); n > max {
+				max = n
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	// several producers, as if flushing from several routines
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cms.Merge(w, MERGE_TRIGGER_SEGMENT_FLUSH, true); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	w.waitForMerges()
+	done <- true
+	if max := <-maxRoutines; max > 4 {
+		t.Errorf("%v merges were started at once, over maxMergeCount", max)
+	}
+
+	assertEquals(t, numMerges, len(w.segmentInfos.Segments))
+	for i, merge := range merges {
+		if merge.info == nil || w.segmentInfos.Segments[i] != merge.info {
+			t.Errorf("merge of %v was not committed", w.readerPool.segmentsToString(merge.segments))
+			continue
+		}
+		assertEquals(t, 2*docCount, merge.info.Info.DocCount())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 0, cms.mergeRoutineCount())
+	assertSegmentedDocs(t, d, 2*numMerges, docCount)
+}
+
+func TestRollbackAbortsRunningMerge(t *testing.T) {
+	d := store.NewRAMDirectory()
+	cms := NewConcurrentMergeScheduler()
+	// 80KB at 20KB/sec take about 4s to merge
+	cms.SetMaxMergeMBPerSec(0.02)
+	w := newSegmentedWriter(t, d, cms, 2, 100, 400)
+	merge := registerSegmentMerges(t, w, 2)[0]
+	if err := cms.Merge(w, MERGE_TRIGGER_EXPLICIT, true); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	for name == "" {
+		time.Sleep(time.Millisecond)
+		w.Lock()
+		if merge.info != nil {
+			name = merge.info.Info.Name
+		}
+		w.Unlock()
+	}
+
+	start := time.Now()
+	if err := w.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("rollback waited %v for the merge to finish", elapsed)
+	}
+	assertEquals(t, true, merge.isAborted())
+	assertEquals(t, 0, len(w.runningMerges))
+	assertEquals(t, 0, len(w.mergingSegments))
+
+	// the index is left as it was committed
+	files, err := d.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file, name+".") || strings.HasPrefix(file, name+"_") {
+			t.Errorf("file %v of the aborted merge was not deleted", file)
+		}
+	}
+	assertSegmentedDocs(t, d, 2, 100)
+}
+
+func TestAbortAllMergesKeepsMergesStopped(t *testing.T) {
+	mc := newMergeControl(util.NO_OUTPUT, nil)
+	mc.abortAllMerges()
+	assertEquals(t, false, mc.stopMerges)
+
+	// rollback stops merges before aborting them, so that none can
+	// register once the abort is done
+	mc.stopMerges = true
+	mc.abortAllMerges()
+	assertEquals(t, true, mc.stopMerges)
+}
+
+func TestConcurrentMergeSchedulerPausesLargestMerges(t *testing.T) {
+	d := store.NewRAMDirectory()
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxMergesAndRoutines(3, 1)
+	cms.Lock()
+	for i, docCount := range []int{30, 10, 20} {
+		cms.mergeRoutines = append(cms.mergeRoutines,
+			&mergeRoutine{i, nil, newSyntheticMerge(d, 0, docCount)})
+	}
+	paused := func() (ans []int) {
+		for _, r := range cms.mergeRoutines {
+			if r.merge.isPaused() {
+				ans = append(ans, r.merge.TotalDocCount())
+			}
+		}
+		return
+	}
+
+	// only the smallest merge runs
+	cms.updateMergeRoutines()
+	assertEquals(t, "[30 20]", fmt.Sprintf("%v", paused()))
+
+	// once it's done, the next smallest one is resumed
+	cms.mergeRoutines = cms.mergeRoutines[:1:1]
+	cms.mergeRoutines = append(cms.mergeRoutines, &mergeRoutine{3, nil, newSyntheticMerge(d, 0, 20)})
+	cms.mergeRoutines[1].merge.setPause(true)
+	cms.updateMergeRoutines()
+	assertEquals(t, "[30]", fmt.Sprintf("%v", paused()))
+	cms.Unlock()
+
+	// more routines let paused merges run
+	cms.SetMaxMergesAndRoutines(3, 2)
+	cms.Lock()
+	assertEquals(t, "[]", fmt.Sprintf("%v", paused()))
+	cms.Unlock()
+
+	// a paused merge blocks until it's resumed
+	merge := newSyntheticMerge(d, 0, 10)
+	merge.setPause(true)
+	resumed := make(chan error)
+	go func() { resumed <- merge.checkAborted() }()
+	select {
+	case <-resumed:
+		t.Fatal("paused merge went on")
+	case <-time.After(20 * time.Millisecond):
+	}
+	merge.setPause(false)
+	if err := <-resumed; err != nil {
+		t.Error(err)
+	}
+
+	// aborting wakes it up too
+	merge.setPause(true)
+	go func() { resumed <- merge.checkAborted() }()
+	merge.abort()
+	if _, ok := (<-resumed).(MergeAbortedError); !ok {
+		t.Error("Expected aborted paused merge to fail")
+	}
+}
+
+func TestConcurrentMergeSchedulerClone(t *testing.T) {
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxMergesAndRoutines(5, 3)
+	cms.SetMergeBufferSizeMB(1)
+	clone := cms.Clone().(*ConcurrentMergeScheduler)
+	assertEquals(t, 5, clone.MaxMergeCount())
+	assertEquals(t, 3, clone.MaxRoutineCount())
+	assertEquals(t, float64(1), clone.MergeBufferSizeMB())
+	assertEquals(t, cms.String(), clone.String())
+	if clone == cms || clone.routineDone == cms.routineDone {
+		t.Error("Expected an independent clone")
+	}
+}
+
+// Runs a merge of about 40KB through cms and returns how long it took.
+func timeRateLimitedMerge(t *testing.T, cms *ConcurrentMergeScheduler) time.Duration {
+	d := store.NewRAMDirectory()
+	w := newSegmentedWriter(t, d, cms, 2, 10, 2*1024)
+	merge := registerSegmentMerges(t, w, 2)[0]
+	start := time.Now()
+	if err := cms.Merge(w, MERGE_TRIGGER_EXPLICIT, true); err != nil {
+		t.Fatal(err)
+	}
+	w.waitForMerges()
+	elapsed := time.Since(start)
+	assertEquals(t, 1, len(w.segmentInfos.Segments))
+	// closing waits for the merge routine to finish
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if merge.mergeDirectory != nil {
+		t.Error("Expected the directory to be unwrapped after the merge")
+	}
+	assertSegmentedDocs(t, d, 2, 10)
+	return elapsed
+}
+
+func TestMergeMergedSegment(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newSegmentedWriter(t, d, NewSerialMergeScheduler(), 3, 5, 0)
+	mergeSegments := func(segments ...*SegmentCommitInfo) *OneMerge {
+		merge := NewOneMerge(segments)
+		w.Lock()
+		registered, err := w.registerMerge(merge)
+		w.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		} else if !registered {
+			t.Fatalf("merge of %v was not registered", w.readerPool.segmentsToString(segments))
+		}
+		if err = w.merge(w.nextMerge()); err != nil {
+			t.Fatal(err)
+		}
+		return merge
+	}
+
+	first := mergeSegments(w.segmentInfos.Segments[:2]...)
+	assertEquals(t, false, first.info.Info.IsCompoundFile())
+	// closing the readers of the non-compound merged segment used to panic
+	mergeSegments(w.segmentInfos.Segments...)
+	assertEquals(t, 1, len(w.segmentInfos.Segments))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentedDocs(t, d, 3, 5)
+}
+
+func TestMergeWritesThroughMergeDirectory(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newSegmentedWriter(t, d, NewSerialMergeScheduler(), 2, 10, 0)
+	mp := NewTieredMergePolicy()
+	mp.SetNoCFSRatio(1)
+	w.config.(*IndexWriterConfig).SetMergePolicy(mp)
+	merge := registerSegmentMerges(t, w, 2)[0]
+
+	// as a MergeScheduler wraps it, e.g. to rate limit merges
+	dir := store.NewTrackingDirectoryWrapper(d)
+	merge.mergeDirectory = dir
+	if err := w.merge(w.nextMerge()); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, merge.info.Info.IsCompoundFile())
+	created := dir.CreatedFiles()
+	for _, file := range merge.info.Files() {
+		if !created[file] {
+			t.Errorf("merged file %v was not written through the merge directory", file)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentedDocs(t, d, 2, 10)
+}
+
+// Norms each document by its number of tokens, so merged norms can be told apart.
+type lengthSimilarity struct{}
+
+func (sim lengthSimilarity) ComputeNorm(state *FieldInvertState) int64 {
+	return int64(state.Length())
+}
+
+/*
+Deletes the given docs of a committed segment, writing its live docs
+the way the ReaderPool would, since the writer can't buffer deletes
+yet.
+*/
+func deleteSegmentDocs(t *testing.T, w *IndexWriter, info *SegmentCommitInfo, docIDs ...int) {
+	w.Lock()
+	defer w.Unlock()
+	format := info.Info.Codec().(Codec).LiveDocsFormat()
+	liveDocs := format.NewLiveDocs(info.Info.DocCount())
+	for _, docID := range docIDs {
+		liveDocs.Clear(docID)
+	}
+	if err := format.WriteLiveDocs(liveDocs, w.directory, info, len(docIDs), store.IO_CONTEXT_DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	info.AdvanceDelGen()
+	info.SetDelCount(len(docIDs))
+	if err := w._checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeSegmentsWithDeletions(t *testing.T) {
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	d := store.NewRAMDirectory()
+	conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+	conf.SetMergePolicy(NO_MERGE_POLICY)
+	conf.SetMergeScheduler(NewSerialMergeScheduler())
+	conf.SetSimilarity(lengthSimilarity{})
+	w, err := NewIndexWriter(d, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the j-th doc of the i-th segment has j+1 tokens and num i*10+j
+	type expectedDoc struct {
+		body string
+		num  int64
+	}
+	expected := make(map[string]expectedDoc)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 5; j++ {
+			id := fmt.Sprintf("s%vd%v", i, j)
+			body := strings.TrimSpace(strings.Repeat(id+" ", j+1))
+			expected[id] = expectedDoc{body, int64(i*10 + j)}
+			doc := docu.NewDocument()
+			doc.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+			doc.Add(docu.NewTextFieldFromString("body", body, docu.STORE_YES))
+			doc.Add(docu.NewNumericDocValuesField("num", int64(i*10+j)))
+			doc.Add(docu.NewSortedDocValuesField("sorted", []byte(id)))
+			if err = w.AddDocument(doc.Fields()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, i := range []int{0, 2} {
+		deleteSegmentDocs(t, w, w.segmentInfos.Segments[i], 1, 3)
+		delete(expected, fmt.Sprintf("s%vd1", i))
+		delete(expected, fmt.Sprintf("s%vd3", i))
+	}
+
+	registerSegmentMerges(t, w, 3)
+	if err = w.merge(w.nextMerge()); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(w.segmentInfos.Segments))
+	assertEquals(t, 0, w.segmentInfos.Segments[0].DelCount())
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenDirectoryReader(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	assertEquals(t, len(expected), reader.NumDocs())
+	assertEquals(t, len(expected), reader.MaxDoc())
+	leaf := reader.Leaves()[0].Reader().(AtomicReader)
+	norms, err := leaf.NormValues("body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nums, err := leaf.NumericDocValues("num")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sorted, err := leaf.SortedDocValues("sorted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for docID := 0; docID < leaf.MaxDoc(); docID++ {
+		doc, err := leaf.Document(docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := doc.Get("id")
+		want, ok := expected[id]
+		if !ok || seen[id] {
+			t.Fatalf("doc %v: unexpected id %q", docID, id)
+		}
+		seen[id] = true
+		if doc.Get("body") != want.body {
+			t.Errorf("doc %v: body %q, want %q", id, doc.Get("body"), want.body)
+		}
+		if norm, length := norms(docID), int64(len(strings.Fields(want.body))); norm != length {
+			t.Errorf("doc %v: norm %v, want %v", id, norm, length)
+		}
+		if num := nums(docID); num != want.num {
+			t.Errorf("doc %v: num %v, want %v", id, num, want.num)
+		}
+		if value := string(sorted.Get(docID)); value != id {
+			t.Errorf("doc %v: sorted value %q", id, value)
+		}
+
+		// the doc's postings point back at it
+		termsEnum := leaf.Terms("id").Iterator(nil)
+		if ok, err := termsEnum.SeekExact([]byte(id)); !ok || err != nil {
+			t.Fatalf("term %v is missing: %v", id, err)
+		}
+		docs, err := termsEnum.Docs(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := docs.NextDoc(); got != docID {
+			t.Errorf("term %v points at doc %v instead of %v", id, got, docID)
+		}
+	}
+	// deleted docs left no terms behind
+	termsEnum := leaf.Terms("id").Iterator(nil)
+	numTerms := 0
+	for term, err := termsEnum.Next(); term != nil || err != nil; term, err = termsEnum.Next() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		numTerms++
+	}
+	assertEquals(t, len(expected), numTerms)
+	assertEquals(t, len(expected), leaf.Terms("id").DocCount())
+}
+
+/*
+Adds a segment whose only field stores term vectors, which the
+indexing chain can't write yet, so as if it came from another writer.
+*/
+func addSegmentWithVectors(t *testing.T, w *IndexWriter, name string) *SegmentCommitInfo {
+	w.Lock()
+	defer w.Unlock()
+	fi := model.NewFieldInfo("body", true, 0, true, false, false,
+		model.INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS, 0, 0, -1, nil)
+	if err := w.codec.FieldInfosFormat().FieldInfosWriter()(w.directory, name, "",
+		model.NewFieldInfos([]*model.FieldInfo{fi}), store.IO_CONTEXT_DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	si := model.NewSegmentInfo(w.directory, util.VERSION_LATEST, name, 1, false, w.codec, nil)
+	info := NewSegmentCommitInfo(si, 0, -1, -1, -1)
+	w.segmentInfos.Segments = append(w.segmentInfos.Segments, info)
+	return info
+}
+
+func TestRegisterMergeSkipsSegmentsWithTermVectors(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newSegmentedWriter(t, d, NewSerialMergeScheduler(), 2, 3, 0)
+	defer w.Close()
+
+	vectors := addSegmentWithVectors(t, w, "_vectors")
+	infos := w.segmentInfos.Segments
+	registered, err := w.registerMerge(NewOneMerge(infos[1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, false, registered)
+	assertEquals(t, 0, w.pendingMerges.Len())
+	assertEquals(t, 0, len(w.mergingSegments))
+	w.segmentInfos.remove(vectors)
+
+	// the other segments still merge
+	if registered, err = w.registerMerge(NewOneMerge(infos[:2])); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, true, registered)
+	if err = w.merge(w.nextMerge()); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(w.segmentInfos.Segments))
+}
+
+func TestConcurrentMergeSchedulerMaxMergeMBPerSec(t *testing.T) {
+	cms := NewConcurrentMergeScheduler()
+	assertEquals(t, float64(0), cms.MaxMergeMBPerSec())
+	unlimited := timeRateLimitedMerge(t, cms)
+
+	// 40KB at 0.1MB/sec take about 400ms
+	cms = NewConcurrentMergeScheduler()
+	cms.SetMaxMergeMBPerSec(0.1)
+	assertEquals(t, 0.1, cms.MaxMergeMBPerSec())
+	if elapsed := timeRateLimitedMerge(t, cms); elapsed < 300*time.Millisecond || elapsed < unlimited {
+		t.Errorf("merge writes were not paused: took %v vs %v unlimited", elapsed, unlimited)
+	}
+
+	cms.SetMaxMergeMBPerSec(0)
+	assertEquals(t, float64(0), cms.MaxMergeMBPerSec())
+}
+
+func TestConcurrentMergeSchedulerAutoIOThrottle(t *testing.T) {
+	d := store.NewRAMDirectory()
+	cms := NewConcurrentMergeScheduler()
+	cms.SetMaxMergesAndRoutines(3, 1)
+	cms.EnableAutoIOThrottle()
+	assertEquals(t, true, cms.AutoIOThrottle())
+	assertEquals(t, START_MB_PER_SEC, cms.MaxMergeMBPerSec())
+
+	startMerges := func(n int) {
+		cms.Lock()
+		defer cms.Unlock()
+		cms.mergeRoutines = nil
+		for i := 0; i < n; i++ {
+			cms.mergeRoutines = append(cms.mergeRoutines,
+				&mergeRoutine{i, nil, newSyntheticMerge(d, 0, 10)})
+		}
+		cms.updateIOThrottle()
+	}
+
+	// backlogged merges raise the limit
+	start, max := START_MB_PER_SEC, MAX_MERGE_MB_PER_SEC
+	startMerges(2)
+	assertEquals(t, start*1.2, cms.MaxMergeMBPerSec())
+	// up to a ceiling
+	for i := 0; i < 100; i++ {
+		startMerges(3)
+	}
+	assertEquals(t, MAX_MERGE_MB_PER_SEC, cms.MaxMergeMBPerSec())
+
+	// caught up merges lower it, down to a floor
+	startMerges(1)
+	assertEquals(t, max/1.1, cms.MaxMergeMBPerSec())
+	for i := 0; i < 200; i++ {
+		startMerges(1)
+	}
+	assertEquals(t, MIN_MERGE_MB_PER_SEC, cms.MaxMergeMBPerSec())
+
+	// a fixed rate turns auto mode off
+	cms.SetMaxMergeMBPerSec(50)
+	assertEquals(t, false, cms.AutoIOThrottle())
+	startMerges(3)
+	assertEquals(t, float64(50), cms.MaxMergeMBPerSec())
+	cms.DisableAutoIOThrottle()
+	assertEquals(t, float64(0), cms.MaxMergeMBPerSec())
+}
+
+// Returns the names of the segments of each merge found by tmp over
+// segments of the given sizes in KB.
+func findTieredMerges(t *testing.T, tmp *TieredMergePolicy, sizesKB ...int) []string {
+	d := store.NewRAMDirectory()
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+	w.readerPool = newReaderPool(w)
+	w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+	sis := &SegmentInfos{}
+	for _, size := range sizesKB {
+		addSyntheticSegment(t, sis, d, 10, size*1024)
+	}
+	spec, err := tmp.FindMerges(MERGE_TRIGGER_EXPLICIT, sis, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mergeNames(spec)
+}
+
+// Returns the names of the segments of each merge of spec.
+func mergeNames(spec MergeSpecification) []string {
+	var merges []string
+	for _, merge := range spec {
+		var names []string
+		for _, info := range merge.segments {
+			names = append(names, info.Info.Name)
+		}
+		merges = append(merges, fmt.Sprintf("%v", names))
+	}
+	return merges
+}
+
+func assertMerges(t *testing.T, expected, actual []string) {
+	if fmt.Sprintf("%v", expected) != fmt.Sprintf("%v", actual) {
+		t.Errorf("Expected merges %v, but %v", expected, actual)
+	}
+}
+
+func TestTieredMergePolicyFindMerges(t *testing.T) {
+	newTMP := func() *TieredMergePolicy {
+		return NewTieredMergePolicy().SetFloorSegmentMB(0.001)
+	}
+
+	// 12 equal segments exceed the budget of 11 by one tier; ties in
+	// size are ordered by segment name
+	equal := []int{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100}
+	assertMerges(t, []string{"[_0 _1 _10 _11 _2 _3 _4 _5 _6 _7]"},
+		findTieredMerges(t, newTMP(), equal...))
+
+	// within budget
+	assertMerges(t, nil, findTieredMerges(t, newTMP(), equal[1:]...))
+
+	// the least skewed merge leaves the big segment alone
+	assertMerges(t, []string{"[_1 _10 _11 _12]"},
+		findTieredMerges(t, newTMP().SetMaxMergeAtOnce(4).SetSegmentsPerTier(4),
+			900, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10))
+
+	// merges are kept under maxMergedSegmentMB
+	assertMerges(t, []string{"[_0 _1 _10 _11 _2]"},
+		findTieredMerges(t, newTMP().SetMaxMergedSegmentMB(0.5), equal...))
+}
+
+func TestTieredMergePolicyMaxMergedSegmentMB(t *testing.T) {
+	const segmentBytes = 100 * 1024
+	equal := []int{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100}
+	for _, v := range []struct {
+		maxMB float64
+		size  int // of the selected merge
+	}{
+		{0.25, 2}, {0.5, 5}, {0.75, 7}, {1024, 10},
+	} {
+		tmp := NewTieredMergePolicy().SetFloorSegmentMB(0.001).SetMaxMergedSegmentMB(v.maxMB)
+		assertEquals(t, v.maxMB, tmp.MaxMergedSegmentMB())
+		merges := findTieredMerges(t, tmp, equal...)
+		if len(merges) != 1 {
+			t.Errorf("maxMergedSegmentMB=%v: expected 1 merge, but %v", v.maxMB, merges)
+			continue
+		}
+		size := len(strings.Fields(merges[0]))
+		assertEquals(t, v.size, size)
+		if bytes := float64(size * segmentBytes); bytes > v.maxMB*1024*1024 {
+			t.Errorf("maxMergedSegmentMB=%v: merge of %v bytes", v.maxMB, bytes)
+		}
+	}
+}
+
+func TestTieredMergePolicySetters(t *testing.T) {
+	tmp := NewTieredMergePolicy().
+		SetMaxMergeAtOnce(5).
+		SetMaxMergeAtOnceExplicit(20).
+		SetSegmentsPerTier(8).
+		SetFloorSegmentMB(1).
+		SetReclaimDeletesWeight(3).
+		SetForceMergeDeletesPctAllowed(20)
+	assertEquals(t, 5, tmp.MaxMergeAtOnce())
+	assertEquals(t, 20, tmp.MaxMergeAtOnceExplicit())
+	assertEquals(t, float64(8), tmp.SegmentsPerTier())
+	assertEquals(t, float64(1), tmp.FloorSegmentMB())
+	assertEquals(t, float64(3), tmp.ReclaimDeletesWeight())
+	assertEquals(t, float64(20), tmp.ForceMergeDeletesPctAllowed())
+
+	for name, set := range map[string]func(){
+		"segmentsPerTier":             func() { tmp.SetSegmentsPerTier(1.5) },
+		"maxMergeAtOnce":              func() { tmp.SetMaxMergeAtOnce(1) },
+		"maxMergeAtOnceExplicit":      func() { tmp.SetMaxMergeAtOnceExplicit(1) },
+		"maxMergedSegmentMB":          func() { tmp.SetMaxMergedSegmentMB(-1) },
+		"floorSegmentMB":              func() { tmp.SetFloorSegmentMB(0) },
+		"reclaimDeletesWeight":        func() { tmp.SetReclaimDeletesWeight(-1) },
+		"forceMergeDeletesPctAllowed": func() { tmp.SetForceMergeDeletesPctAllowed(101) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected invalid %v to be rejected", name)
+				}
+			}()
+			set()
+		}()
+	}
+	assertEquals(t, float64(8), tmp.SegmentsPerTier())
+}
+
+// Repeatedly applies the merges found by mp over segments of the given
+// sizes, in docs or KB according to the policy, until none is found,
+// and returns the sizes merged at each round.
+func cascadeLogMerges(t *testing.T, mp *LogMergePolicy, sizes ...int) [][]string {
+	_, byDocs := mp.SizeSPI.(*LogDocMergePolicy)
+	var rounds [][]string
+	for len(rounds) < 10 {
+		d := store.NewRAMDirectory()
+		w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+		w.readerPool = newReaderPool(w)
+		w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+		sis := &SegmentInfos{}
+		index := make(map[*SegmentCommitInfo]int)
+		for i, size := range sizes {
+			if byDocs {
+				index[addSyntheticSegment(t, sis, d, size, 1)] = i
+			} else {
+				index[addSyntheticSegment(t, sis, d, 10, size*1024)] = i
+			}
+		}
+		spec, err := mp.FindMerges(MERGE_TRIGGER_SEGMENT_FLUSH, sis, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spec) == 0 {
+			return rounds
+		}
+
+		// the merged segment takes the place of the first one
+		var merges []string
+		merged := make(map[int]int)
+		for _, merge := range spec {
+			var mergeSizes []int
+			first := index[merge.segments[0]]
+			for _, info := range merge.segments {
+				mergeSizes = append(mergeSizes, sizes[index[info]])
+				merged[first] += sizes[index[info]]
+				if i := index[info]; i != first {
+					merged[i] = 0
+				}
+			}
+			merges = append(merges, fmt.Sprintf("%v", mergeSizes))
+		}
+		rounds = append(rounds, merges)
+
+		var next []int
+		for i, size := range sizes {
+			if total, ok := merged[i]; !ok {
+				next = append(next, size)
+			} else if total > 0 {
+				next = append(next, total)
+			}
+		}
+		sizes = next
+	}
+	t.Fatalf("merges don't converge: %v", rounds)
+	return nil
+}
+
+func assertRounds(t *testing.T, expected, actual [][]string) {
+	if fmt.Sprintf("%v", expected) != fmt.Sprintf("%v", actual) {
+		t.Errorf("Expected merge rounds %v, but %v", expected, actual)
+	}
+}
+
+func TestLogDocMergePolicyFindMerges(t *testing.T) {
+	newLDMP := func() *LogDocMergePolicy {
+		mp := NewLogDocMergePolicy()
+		mp.SetMergeFactor(3)
+		mp.SetMinMergeDocs(1)
+		return mp
+	}
+	equal := []int{10, 10, 10, 10, 10, 10, 10, 10, 10}
+
+	// equal segments cascade up one level at a time
+	assertRounds(t, [][]string{
+		{"[10 10 10]", "[10 10 10]", "[10 10 10]"},
+		{"[30 30 30]"},
+	}, cascadeLogMerges(t, newLDMP().LogMergePolicy, equal...))
+
+	// only adjacent runs of mergeFactor segments of a level are merged
+	assertRounds(t, [][]string{
+		{"[10 10 10]"},
+		{"[30 30 30]"},
+	}, cascadeLogMerges(t, newLDMP().LogMergePolicy, 90, 30, 30, 10, 10, 10))
+	assertRounds(t, nil, cascadeLogMerges(t, newLDMP().LogMergePolicy, 90, 10, 10))
+
+	// segments below minMergeDocs share the lowest level
+	mp := newLDMP()
+	mp.SetMinMergeDocs(100)
+	assertEquals(t, 100, mp.MinMergeDocs())
+	assertRounds(t, [][]string{{"[90 10 10]"}},
+		cascadeLogMerges(t, mp.LogMergePolicy, 90, 10, 10))
+
+	// segments at maxMergeDocs are never merged
+	mp = newLDMP()
+	mp.SetMaxMergeDocs(30)
+	assertEquals(t, 30, mp.MaxMergeDocs())
+	assertRounds(t, [][]string{
+		{"[10 10 10]", "[10 10 10]", "[10 10 10]"},
+	}, cascadeLogMerges(t, mp.LogMergePolicy, equal...))
+}
+
+func TestLogByteSizeMergePolicyFindMerges(t *testing.T) {
+	newLBSMP := func() *LogByteSizeMergePolicy {
+		mp := NewLogByteSizeMergePolicy()
+		mp.SetMergeFactor(3)
+		return mp
+	}
+	equal := []int{100, 100, 100, 100, 100, 100, 100, 100, 100}
+
+	// segments under the default minMergeMB all share one level
+	assertRounds(t, [][]string{
+		{"[100 100 100]", "[100 100 100]", "[100 100 100]"},
+		{"[300 300 300]"},
+	}, cascadeLogMerges(t, newLBSMP().LogMergePolicy, equal...))
+
+	// without the floor, sizes far apart fall into different levels
+	mp := newLBSMP()
+	mp.SetMinMergeMB(0.25)
+	assertEquals(t, 0.25, mp.MinMergeMB())
+	assertRounds(t, nil, cascadeLogMerges(t, mp.LogMergePolicy, 900, 100, 100))
+	assertRounds(t, [][]string{{"[900 100 100]"}},
+		cascadeLogMerges(t, newLBSMP().LogMergePolicy, 900, 100, 100))
+
+	// runs with a segment over maxMergeMB are skipped
+	mp = newLBSMP()
+	mp.SetMaxMergeMB(0.25)
+	assertEquals(t, 0.25, mp.MaxMergeMB())
+	assertRounds(t, [][]string{
+		{"[100 100 100]", "[100 100 100]"},
+	}, cascadeLogMerges(t, mp.LogMergePolicy, 100, 100, 100, 300, 100, 100, 100, 100, 100))
+}
+
+// Returns the names of the segments of each merge forced by mp down to
+// maxSegmentCount over segments of the given doc counts and sizes in KB.
+func findForcedMerges(t *testing.T, mp MergeSpecifier, maxSegmentCount int,
+	docCounts, sizesKB []int) []string {
+
+	d := store.NewRAMDirectory()
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+	w.readerPool = newReaderPool(w)
+	w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+	sis := &SegmentInfos{}
+	segmentsToMerge := make(map[*SegmentCommitInfo]bool)
+	for i, docCount := range docCounts {
+		segmentsToMerge[addSyntheticSegment(t, sis, d, docCount, sizesKB[i]*1024)] = true
+	}
+	spec, err := mp.FindForcedMerges(sis, maxSegmentCount, segmentsToMerge, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mergeNames(spec)
+}
+
+func TestTieredMergePolicyFindForcedMerges(t *testing.T) {
+	docs := []int{10, 10, 10, 10, 10}
+	equal := []int{100, 100, 100, 100, 100}
+	forced := func(tmp *TieredMergePolicy, maxSegmentCount int, sizesKB ...int) []string {
+		return findForcedMerges(t, tmp, maxSegmentCount, docs[:len(sizesKB)], sizesKB)
+	}
+
+	assertMerges(t, []string{"[_0 _1 _2 _3 _4]"}, forced(NewTieredMergePolicy(), 1, equal...))
+	assertMerges(t, []string{"[_2 _3 _4]"}, forced(NewTieredMergePolicy(), 3, equal...))
+
+	// the smallest segments are merged first
+	assertMerges(t, []string{"[_2 _0]"}, forced(NewTieredMergePolicy(), 2, 100, 300, 200))
+
+	// full merges of maxMergeAtOnceExplicit segments come first
+	assertMerges(t, []string{"[_3 _4]", "[_1 _2]"},
+		forced(NewTieredMergePolicy().SetMaxMergeAtOnceExplicit(2), 1, equal...))
+
+	// already merged
+	assertMerges(t, nil, forced(NewTieredMergePolicy(), 1, 100))
+	assertMerges(t, nil, forced(NewTieredMergePolicy(), 3, 100, 100))
+}
+
+func TestLogMergePolicyFindForcedMerges(t *testing.T) {
+	newLDMP := func() *LogDocMergePolicy {
+		mp := NewLogDocMergePolicy()
+		mp.SetMergeFactor(3)
+		return mp
+	}
+	sizes := []int{1, 1, 1, 1, 1}
+
+	// full merges of mergeFactor segments come first, from the tail
+	assertMerges(t, []string{"[_2 _3 _4]"},
+		findForcedMerges(t, newLDMP(), 1, []int{10, 10, 10, 10, 10}, sizes))
+	assertMerges(t, []string{"[_0 _1]"},
+		findForcedMerges(t, newLDMP(), 1, []int{10, 30}, sizes[:2]))
+
+	// the final partial merge avoids making the index lopsided
+	assertMerges(t, []string{"[_1 _2]"},
+		findForcedMerges(t, newLDMP(), 2, []int{100, 10, 10}, sizes[:3]))
+
+	// already merged
+	assertMerges(t, nil, findForcedMerges(t, newLDMP(), 1, []int{10}, sizes[:1]))
+	assertMerges(t, nil, findForcedMerges(t, newLDMP(), 3, []int{10, 10}, sizes[:2]))
+
+	// segments over maxMergeDocs are left alone
+	mp := newLDMP()
+	mp.SetMaxMergeDocs(15)
+	assertMerges(t, []string{"[_3 _4]", "[_0 _1]"},
+		findForcedMerges(t, mp, 1, []int{10, 10, 20, 10, 10}, sizes))
+	assertMerges(t, nil, findForcedMerges(t, mp, 1, []int{10, 20}, sizes[:2]))
+}
+
+func TestNoMergePolicy(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{}
+	for i := 0; i < 50; i++ {
+		addSyntheticSegment(t, sis, d, 10, 1024)
+	}
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+	for _, trigger := range []MergeTrigger{MERGE_TRIGGER_SEGMENT_FLUSH, MERGE_TRIGGER_EXPLICIT} {
+		spec, err := NO_MERGE_POLICY.FindMerges(trigger, sis, w)
+		if err != nil || spec != nil {
+			t.Errorf("Expected no merges, but %v (%v)", spec, err)
+		}
+	}
+	spec, err := NO_MERGE_POLICY.FindForcedMerges(sis, 1, nil, w)
+	if err != nil || spec != nil {
+		t.Errorf("Expected no forced merges, but %v (%v)", spec, err)
+	}
+	spec, err = NO_MERGE_POLICY.FindForcedDeletesMerges(sis, w)
+	if err != nil || spec != nil {
+		t.Errorf("Expected no forced deletes merges, but %v (%v)", spec, err)
+	}
+	assertEquals(t, 50, len(sis.Segments))
+}
+
+func TestNoMergeScheduler(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+	w.readerPool = newReaderPool(w)
+	w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+	w.pendingMerges.PushBack(newSyntheticMerge(d, 0, 10, 10))
+
+	if err := NO_MERGE_SCHEDULER.Merge(w, MERGE_TRIGGER_SEGMENT_FLUSH, true); err != nil {
+		t.Fatal(err)
+	}
+	// the pending merge was never pulled by the scheduler
+	assertEquals(t, 1, w.pendingMerges.Len())
+	if NO_MERGE_SCHEDULER.Clone() != NO_MERGE_SCHEDULER {
+		t.Error("Expected the singleton to clone to itself")
+	}
+	if err := NO_MERGE_SCHEDULER.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckAbort(t *testing.T) {
+	merge := newSyntheticMerge(store.NewRAMDirectory(), 0, 10)
+	checkAbort := newCheckAbort(merge)
+	for i := 0; i < 10; i++ {
+		if err := checkAbort.Work(CHECK_ABORT_INTERVAL / 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEquals(t, CHECK_ABORT_INTERVAL*10/4, checkAbort.total)
+
+	// aborted mid-merge, it fails within one check interval
+	merge.abort()
+	var err error
+	var units float64
+	for err == nil && units <= CHECK_ABORT_INTERVAL {
+		err = checkAbort.Work(100)
+		units += 100
+	}
+	if _, ok := err.(MergeAbortedError); !ok {
+		t.Errorf("Expected MergeAbortedError after %v units, but %v", units, err)
+	}
+
+	// never for flushes
+	if err = CheckAbortNone(0).Work(CHECK_ABORT_INTERVAL * 2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterMerge(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newMergingWriter(d)
+	w.segmentInfos = &SegmentInfos{}
+	var infos []*SegmentCommitInfo
+	for i := 0; i < 4; i++ {
+		infos = append(infos, addSyntheticSegment(t, w.segmentInfos, d, 10, 1024))
+	}
+
+	first := NewOneMerge(infos[:2])
+	for _, c := range []struct {
+		merge      *OneMerge
+		registered bool
+	}{
+		{first, true},
+		{first, true},                    // already registered
+		{NewOneMerge(infos[1:3]), false}, // infos[1] is being merged
+		{newSyntheticMerge(d, 0, 10), false},
+		{NewOneMerge(infos[2:]), true},
+	} {
+		registered, err := w.registerMerge(c.merge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, c.registered, registered)
+	}
+	assertEquals(t, 2, w.pendingMerges.Len())
+	assertEquals(t, 4, len(w.mergingSegments))
+
+	// a merge that can't be run releases its segments
+	prev := setMaxDocs(15)
+	merge := w.nextMerge()
+	if err := w.merge(merge); err == nil {
+		t.Error("Expected merging segments to fail")
+	}
+	setMaxDocs(prev)
+	assertEquals(t, 2, len(w.mergingSegments))
+	assertEquals(t, false, merge.registerDone)
+
+	w.stopMerges = true
+	merge = NewOneMerge(infos[:1])
+	if _, err := w.registerMerge(merge); err == nil {
+		t.Error("Expected MergeAbortedError once merges are stopped")
+	}
+	assertEquals(t, true, merge.checkAborted() != nil)
+}
+
+func TestSerialMergeSchedulerDrainsMergesInOrder(t *testing.T) {
+	d := store.NewRAMDirectory()
+	ms := NewSerialMergeScheduler()
+	w := newSegmentedWriter(t, d, ms, 5, 3, 0)
+	merges := registerSegmentMerges(t, w, 1)
+
+	if err := ms.Merge(w, MERGE_TRIGGER_EXPLICIT, true); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 0, w.pendingMerges.Len())
+	assertEquals(t, 0, len(w.runningMerges))
+	assertEquals(t, 0, len(w.mergingSegments))
+	// merged segments are named as the merges start
+	var prev int64 = -1
+	for i, merge := range merges {
+		if merge.info == nil {
+			t.Fatalf("merge %v didn't run", i)
+		}
+		assertEquals(t, merge.info, w.segmentInfos.Segments[i])
+		n, err := strconv.ParseInt(merge.info.Info.Name[1:], 36, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n <= prev {
+			t.Errorf("Expected merge %v to run in order, but got %v", i, merge.info.Info.Name)
+		}
+		prev = n
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentedDocs(t, d, 5, 3)
+}
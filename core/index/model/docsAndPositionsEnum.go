@@ -1,9 +1,29 @@
 package model
 
+// index/DocsAndPositionsEnum.java
+
 const (
+	// Flag to pass to TermsEnum.DocsAndPositionsByFlags() if you require
+	// offsets in the returned enum.
 	DOCS_POSITIONS_ENUM_FLAG_OFF_SETS = 1
+	// Flag to pass to TermsEnum.DocsAndPositionsByFlags() if you require
+	// payloads in the returned enum.
 	DOCS_POSITIONS_ENUM_FLAG_PAYLOADS = 2
 )
 
-type DocsAndPositionsEnum struct {
+/* Also iterates through positions. */
+type DocsAndPositionsEnum interface {
+	DocsEnum
+	// Returns the next position. You should only call this up to
+	// Freq() times else the behavior is not defined.
+	NextPosition() (int, error)
+	// Returns start offset for the current position, or -1 if offsets
+	// were not indexed.
+	StartOffset() int
+	// Returns end offset for the current position, or -1 if offsets
+	// were not indexed.
+	EndOffset() int
+	// Returns the payload at this position, or nil if no payload was
+	// indexed. The returned slice may be reused by later calls.
+	Payload() ([]byte, error)
 }
@@ -21,6 +21,9 @@ type FieldInfo struct {
 	indexOptions  IndexOptions
 	storePayloads bool
 
+	// 0 if the field has no points
+	pointDimensionCount int
+
 	*AttributesMixin
 
 	dvGen int64
@@ -31,7 +34,7 @@ func NewFieldInfo(name string, indexed bool, number int32,
 	indexOptions IndexOptions, docValues, normsType DocValuesType,
 	dvGen int64, attributes map[string]string) *FieldInfo {
 
-	assert(indexOptions > 0)
+	assert(!indexed || indexOptions > 0)
 	assert(indexOptions <= INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS)
 
 	fi := &FieldInfo{Name: name, indexed: indexed, Number: number, docValueType: docValues}
@@ -101,13 +104,29 @@ func (info *FieldInfo) update(indexed, storeTermVector, omitNorms, storePayloads
 }
 
 func (info *FieldInfo) SetDocValueType(v DocValuesType) {
-	assert2(int(info.docValueType) != 0 && info.docValueType != v,
+	assert2(int(info.docValueType) == 0 || info.docValueType == v,
 		"cannot change DocValues type from %v to %v for field '%v'",
 		info.docValueType, v, info.Name)
 	info.docValueType = v
 	info.checkConsistency()
 }
 
+/*
+Records that the field indexes points of the given number of
+dimensions; only single dimension points are supported.
+*/
+func (info *FieldInfo) SetPointDimensionCount(count int) {
+	assert2(count == 1, "only single dimension points are supported; got %v for field '%v'",
+		count, info.Name)
+	assert2(info.pointDimensionCount == 0 || info.pointDimensionCount == count,
+		"cannot change point dimension count from %v to %v for field '%v'",
+		info.pointDimensionCount, count, info.Name)
+	info.pointDimensionCount = count
+}
+
+/* Returns the number of dimensions of the field's points, or 0 if it has none. */
+func (info *FieldInfo) PointDimensionCount() int { return info.pointDimensionCount }
+
 /* Returns IndexOptions for the field, or 0 if the field is not indexed */
 func (info *FieldInfo) IndexOptions() IndexOptions { return info.indexOptions }
 
@@ -276,4 +295,10 @@ type IndexableFieldType interface {
 	 * will be indexed into docValues.
 	 */
 	DocValueType() DocValuesType
+
+	/**
+	 * If non-zero, the field's numeric value is indexed as a point of
+	 * this many dimensions, for range searching.
+	 */
+	PointDimensionCount() int
 }
@@ -15,6 +15,8 @@ type FieldInfos struct {
 	HasVectors   bool
 	HasNorms     bool
 	HasDocValues bool
+	// True if any field indexes points
+	HasPointValues bool
 
 	byNumber map[int32]*FieldInfo
 	byName   map[string]*FieldInfo
@@ -44,6 +46,7 @@ func NewFieldInfos(infos []*FieldInfo) FieldInfos {
 		self.HasNorms = self.HasNorms || info.normType != 0
 		self.HasDocValues = self.HasDocValues || info.docValueType != 0
 		self.HasPayloads = self.HasPayloads || info.storePayloads
+		self.HasPointValues = self.HasPointValues || info.pointDimensionCount != 0
 	}
 
 	sort.Sort(Int32Slice(numbers))
@@ -155,6 +158,30 @@ func (fn *FieldNumbers) addOrGet(name string, preferredNumber int, dv DocValuesT
 	return number
 }
 
+func (fn *FieldNumbers) verifyConsistent(number int, name string, dv DocValuesType) {
+	assert2(fn.numberToName[number] == name,
+		"field number %v is already mapped to field name '%v', not '%v'",
+		number, fn.numberToName[number], name)
+	assert2(fn.nameToNumber[name] == number,
+		"field name '%v' is already mapped to field number %v, not %v",
+		name, fn.nameToNumber[name], number)
+	currentDv := fn.docValuesType[name]
+	assert2(dv == 0 || currentDv == 0 || dv == currentDv,
+		"cannot change DocValues type from %v to %v for field '%v'",
+		currentDv, dv, name)
+}
+
+/*
+Records the DocValuesType of a field which was first added without
+doc values, so that later segments can't change it.
+*/
+func (fn *FieldNumbers) SetDocValuesType(number int, name string, dv DocValuesType) {
+	fn.Lock()
+	defer fn.Unlock()
+	fn.verifyConsistent(number, name, dv)
+	fn.docValuesType[name] = dv
+}
+
 type FieldInfosBuilder struct {
 	byName             map[string]*FieldInfo
 	globalFieldNumbers *FieldNumbers
@@ -168,6 +195,11 @@ func NewFieldInfosBuilder(globalFieldNumbers *FieldNumbers) *FieldInfosBuilder {
 	}
 }
 
+/* Returns the field numbers shared by all segments of the index. */
+func (b *FieldInfosBuilder) GlobalFieldNumbers() *FieldNumbers {
+	return b.globalFieldNumbers
+}
+
 func assert(ok bool) {
 	assert2(ok, "assert fail")
 }
@@ -178,6 +210,20 @@ func assert2(ok bool, msg string, args ...interface{}) {
 	}
 }
 
+/*
+Adds the given FieldInfo, reusing its field number if possible, for
+consistent field numbers across segments.
+*/
+func (b *FieldInfosBuilder) Add(fi *FieldInfo) *FieldInfo {
+	ans := b.addOrUpdateInternal(fi.Name, int(fi.Number), fi.indexed,
+		fi.storeTermVector, fi.omitNorms, fi.storePayloads,
+		fi.indexOptions, fi.docValueType, fi.normType)
+	if fi.pointDimensionCount != 0 {
+		ans.SetPointDimensionCount(fi.pointDimensionCount)
+	}
+	return ans
+}
+
 /*
 NOTE: this method does not carry over termVector booleans nor
 docValuesType; the indexer chain  (TermVectorsConsumerPerField,
@@ -200,7 +246,21 @@ func (b *FieldInfosBuilder) addOrUpdateInternal(name string,
 	docValues DocValuesType, normType DocValuesType) *FieldInfo {
 
 	if fi, ok := b.byName[name]; ok {
-		panic("not implemented yet")
+		fi.update(isIndexed, storeTermVector, omitNorms, storePayloads, indexOptions)
+		if docValues != 0 {
+			// only pay the synchronization cost if fi does not already
+			// have a DVType
+			updateGlobal := !fi.HasDocValues()
+			fi.SetDocValueType(docValues) // this will also perform the consistency check.
+			if updateGlobal {
+				// must also update docValuesType map so it's aware of this
+				// field's DocValueType
+				b.globalFieldNumbers.SetDocValuesType(int(fi.Number), name, docValues)
+			}
+		}
+		if !fi.OmitsNorms() && normType != 0 {
+			fi.SetNormValueType(normType)
+		}
 		return fi
 	} else {
 		// This field wasn't yet added to this in-RAM segment's
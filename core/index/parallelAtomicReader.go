@@ -0,0 +1,253 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// index/ParallelAtomicReader.java
+
+/*
+An AtomicReader which reads multiple, parallel indexes. Each index
+added must have the same number of documents, but typically each
+contains different fields. Deletions are taken from the first reader.
+Each document contains the union of the fields of all documents with
+the same document number. When searching, matches for a query term
+are from the first index added that has the field.
+
+This is useful, e.g., with collections that have large fields which
+change rarely and small fields that change more frequently. The
+smaller fields may be re-indexed in a new index and both indexes may
+be searched together.
+
+Warning: It is up to you to make sure all indexes are created and
+modified the same way. For example, if you add documents to one
+index, you need to add the same documents in the same order to the
+other indexes. Failure to do so will result in undefined behavior.
+*/
+type ParallelAtomicReader struct {
+	*AtomicReaderImpl
+	fieldInfos          FieldInfos
+	fields              *parallelFields
+	parallelReaders     []AtomicReader
+	storedFieldsReaders []AtomicReader
+	completeReaderSet   map[AtomicReader]bool
+	closeSubReaders     bool
+	maxDoc, numDocs     int
+	hasDeletions        bool
+	fieldToReader       map[string]AtomicReader
+	// for synthetic sub readers of ParallelCompositeReader, which owns
+	// the wrapped readers; closing such a reader is a no-op.
+	synthetic bool
+}
+
+/*
+Create a ParallelAtomicReader based on the provided readers. If
+closeSubReaders is false, the readers are incRef'd and decRef'd on
+close, otherwise they are closed along with this reader.
+*/
+func NewParallelAtomicReader(closeSubReaders bool, readers ...AtomicReader) (*ParallelAtomicReader, error) {
+	return newParallelAtomicReader(closeSubReaders, readers, readers)
+}
+
+/*
+Expert: create a ParallelAtomicReader based on the provided readers
+and storedFieldsReaders; when a document is loaded, only
+storedFieldsReaders will be used.
+*/
+func newParallelAtomicReader(closeSubReaders bool,
+	readers, storedFieldsReaders []AtomicReader) (*ParallelAtomicReader, error) {
+
+	if len(readers) == 0 && len(storedFieldsReaders) > 0 {
+		return nil, errors.New("There must be at least one main reader if storedFieldsReaders are used.")
+	}
+	ans := &ParallelAtomicReader{
+		fields:              newParallelFields(),
+		parallelReaders:     readers,
+		storedFieldsReaders: storedFieldsReaders,
+		completeReaderSet:   make(map[AtomicReader]bool),
+		closeSubReaders:     closeSubReaders,
+		fieldToReader:       make(map[string]AtomicReader),
+	}
+	if len(readers) > 0 {
+		first := readers[0]
+		ans.maxDoc = first.MaxDoc()
+		ans.numDocs = first.NumDocs()
+		ans.hasDeletions = first.MaxDoc() > first.NumDocs()
+	}
+	for _, r := range readers {
+		ans.completeReaderSet[r] = true
+	}
+	for _, r := range storedFieldsReaders {
+		ans.completeReaderSet[r] = true
+	}
+
+	// check compatibility:
+	for r, _ := range ans.completeReaderSet {
+		if r.MaxDoc() != ans.maxDoc {
+			return nil, errors.New(fmt.Sprintf(
+				"All readers must have same maxDoc: %v!=%v", ans.maxDoc, r.MaxDoc()))
+		}
+	}
+
+	// build FieldInfos and fieldToReader map:
+	builder := NewFieldInfosBuilder(NewFieldNumbers())
+	for _, r := range readers {
+		for _, fi := range r.FieldInfos().Values {
+			// NOTE: first reader having a given field "wins":
+			if _, ok := ans.fieldToReader[fi.Name]; !ok {
+				builder.Add(fi)
+				ans.fieldToReader[fi.Name] = r
+			}
+		}
+	}
+	ans.fieldInfos = builder.Finish()
+
+	// build Fields instance
+	for name, r := range ans.fieldToReader {
+		if fields := r.Fields(); fields != nil {
+			if terms := fields.Terms(name); terms != nil {
+				ans.fields.addField(name, terms)
+			}
+		}
+	}
+
+	ans.AtomicReaderImpl = newAtomicReader(ans)
+
+	// do this finally so any errors occurred before don't affect refcounts:
+	for r, _ := range ans.completeReaderSet {
+		if !closeSubReaders {
+			r.incRef()
+		}
+		r.registerParentReader(ans)
+	}
+	return ans, nil
+}
+
+func (r *ParallelAtomicReader) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("ParallelAtomicReader(")
+	for i, sub := range r.parallelReaders {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%v", sub)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// Single instance of this, per ParallelAtomicReader instance
+type parallelFields struct {
+	fields map[string]Terms
+}
+
+func newParallelFields() *parallelFields {
+	return &parallelFields{make(map[string]Terms)}
+}
+
+func (f *parallelFields) addField(fieldName string, terms Terms) {
+	f.fields[fieldName] = terms
+}
+
+func (f *parallelFields) Terms(field string) Terms {
+	return f.fields[field]
+}
+
+/*
+Get the FieldInfos describing all fields in this reader.
+
+NOTE: the returned field numbers will likely not correspond to the
+actual field numbers in the underlying readers, and codec metadata
+(FieldInfo.Attribute()) will be unavailable.
+*/
+func (r *ParallelAtomicReader) FieldInfos() FieldInfos {
+	return r.fieldInfos
+}
+
+func (r *ParallelAtomicReader) LiveDocs() util.Bits {
+	r.ensureOpen()
+	if r.hasDeletions {
+		return r.parallelReaders[0].LiveDocs()
+	}
+	return nil
+}
+
+func (r *ParallelAtomicReader) Fields() Fields {
+	r.ensureOpen()
+	return r.fields
+}
+
+func (r *ParallelAtomicReader) NumDocs() int {
+	// Don't call ensureOpen() here (it could affect performance)
+	return r.numDocs
+}
+
+func (r *ParallelAtomicReader) MaxDoc() int {
+	// Don't call ensureOpen() here (it could affect performance)
+	return r.maxDoc
+}
+
+func (r *ParallelAtomicReader) VisitDocument(docID int, visitor StoredFieldVisitor) error {
+	r.ensureOpen()
+	for _, reader := range r.storedFieldsReaders {
+		if err := reader.VisitDocument(docID, visitor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ParallelAtomicReader) doClose() (err error) {
+	if r.synthetic {
+		return nil
+	}
+	for reader, _ := range r.completeReaderSet {
+		var err2 error
+		if r.closeSubReaders {
+			err2 = reader.Close()
+		} else {
+			err2 = reader.decRef()
+		}
+		if err == nil {
+			err = err2
+		}
+	}
+	return
+}
+
+func (r *ParallelAtomicReader) NumericDocValues(field string) (NumericDocValues, error) {
+	r.ensureOpen()
+	if reader, ok := r.fieldToReader[field]; ok {
+		return reader.NumericDocValues(field)
+	}
+	return nil, nil
+}
+
+func (r *ParallelAtomicReader) SortedDocValues(field string) (SortedDocValues, error) {
+	r.ensureOpen()
+	if reader, ok := r.fieldToReader[field]; ok {
+		return reader.SortedDocValues(field)
+	}
+	return nil, nil
+}
+
+func (r *ParallelAtomicReader) PointValues(field string) (PointValues, error) {
+	r.ensureOpen()
+	if reader, ok := r.fieldToReader[field]; ok {
+		return reader.PointValues(field)
+	}
+	return nil, nil
+}
+
+func (r *ParallelAtomicReader) NormValues(field string) (NumericDocValues, error) {
+	r.ensureOpen()
+	if reader, ok := r.fieldToReader[field]; ok {
+		return reader.NormValues(field)
+	}
+	return nil, nil
+}
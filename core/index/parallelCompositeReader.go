@@ -0,0 +1,185 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// index/ParallelCompositeReader.java
+
+/*
+A CompositeReader which reads multiple, parallel indexes. Each index
+added must have the same number of documents, and exactly the same
+number of leaves (with equal MaxDoc()), but typically each contains
+different fields. Deletions are taken from the first reader. Each
+document contains the union of the fields of all documents with the
+same document number. When searching, matches for a query term are
+from the first index added that has the field.
+
+This is useful, e.g., with collections that have large fields which
+change rarely and small fields that change more frequently. The
+smaller fields may be re-indexed in a new index and both indexes may
+be searched together.
+
+Warning: It is up to you to make sure all indexes are created and
+modified the same way. For example, if you add documents to one
+index, you need to add the same documents in the same order to the
+other indexes. Failure to do so will result in undefined behavior. A
+good strategy to create suitable indexes with IndexWriter is to use
+LogDocMergePolicy, as this one does not reorder documents during
+merging (like TieredMergePolicy) and triggers merges by number of
+documents per segment. If you use different MergePolicies it might
+happen that the segment structure of your index is no longer
+predictable.
+*/
+type ParallelCompositeReader struct {
+	*BaseCompositeReader
+	closeSubReaders   bool
+	completeReaderSet map[CompositeReader]bool
+}
+
+/*
+Create a ParallelCompositeReader based on the provided readers. If
+closeSubReaders is false, the readers are incRef'd and decRef'd on
+close, otherwise they are closed along with this reader.
+*/
+func NewParallelCompositeReader(closeSubReaders bool, readers ...CompositeReader) (*ParallelCompositeReader, error) {
+	return newParallelCompositeReader(closeSubReaders, readers, readers)
+}
+
+/*
+Expert: create a ParallelCompositeReader based on the provided
+readers and storedFieldsReaders; when a document is loaded, only
+storedFieldsReaders will be used.
+*/
+func newParallelCompositeReader(closeSubReaders bool,
+	readers, storedFieldsReaders []CompositeReader) (*ParallelCompositeReader, error) {
+
+	subReaders, err := prepareParallelSubReaders(readers, storedFieldsReaders)
+	if err != nil {
+		return nil, err
+	}
+	ans := &ParallelCompositeReader{
+		closeSubReaders:   closeSubReaders,
+		completeReaderSet: make(map[CompositeReader]bool),
+	}
+	ans.BaseCompositeReader = newBaseCompositeReader(ans, subReaders)
+	for _, r := range readers {
+		ans.completeReaderSet[r] = true
+	}
+	for _, r := range storedFieldsReaders {
+		ans.completeReaderSet[r] = true
+	}
+	// update ref-counts (like MultiReader):
+	for r, _ := range ans.completeReaderSet {
+		if !closeSubReaders {
+			r.incRef()
+		}
+		r.registerParentReader(ans)
+	}
+	return ans, nil
+}
+
+func prepareParallelSubReaders(readers, storedFieldsReaders []CompositeReader) ([]IndexReader, error) {
+	if len(readers) == 0 {
+		if len(storedFieldsReaders) > 0 {
+			return nil, errors.New("There must be at least one main reader if storedFieldsReaders are used.")
+		}
+		return nil, nil
+	}
+
+	firstSubReaders := readers[0].getSequentialSubReaders()
+
+	// check compatibility:
+	maxDoc, noSubs := readers[0].MaxDoc(), len(firstSubReaders)
+	childMaxDoc := make([]int, noSubs)
+	for i, r := range firstSubReaders {
+		childMaxDoc[i] = r.MaxDoc()
+	}
+	if err := validateParallelSubReaders(readers, maxDoc, childMaxDoc); err != nil {
+		return nil, err
+	}
+	if err := validateParallelSubReaders(storedFieldsReaders, maxDoc, childMaxDoc); err != nil {
+		return nil, err
+	}
+
+	// hierarchically build the same subreader structure as the first
+	// CompositeReader with parallel readers:
+	subReaders := make([]IndexReader, noSubs)
+	for i, r := range firstSubReaders {
+		if _, ok := r.(AtomicReader); !ok {
+			return nil, errors.New(fmt.Sprintf(
+				"Sub-reader %v of a parallel reader must be an AtomicReader: %v", i, r))
+		}
+		atomicSubs := make([]AtomicReader, len(readers))
+		for j, reader := range readers {
+			atomicSubs[j] = reader.getSequentialSubReaders()[i].(AtomicReader)
+		}
+		storedSubs := make([]AtomicReader, len(storedFieldsReaders))
+		for j, reader := range storedFieldsReaders {
+			storedSubs[j] = reader.getSequentialSubReaders()[i].(AtomicReader)
+		}
+		// we simply enable closing of subReaders, to prevent incRefs on
+		// subReaders -> for synthetic subReaders, close() is never called
+		// by our doClose()
+		sub, err := newParallelAtomicReader(true, atomicSubs, storedSubs)
+		if err != nil {
+			return nil, err
+		}
+		sub.synthetic = true
+		subReaders[i] = sub
+	}
+	return subReaders, nil
+}
+
+func validateParallelSubReaders(readers []CompositeReader, maxDoc int, childMaxDoc []int) error {
+	for i, reader := range readers {
+		subs := reader.getSequentialSubReaders()
+		if reader.MaxDoc() != maxDoc {
+			return errors.New(fmt.Sprintf(
+				"All readers must have same maxDoc: %v!=%v", maxDoc, reader.MaxDoc()))
+		}
+		if len(subs) != len(childMaxDoc) {
+			return errors.New("All readers must have same number of subReaders")
+		}
+		for subIDX, sub := range subs {
+			if sub.MaxDoc() != childMaxDoc[subIDX] {
+				return errors.New(fmt.Sprintf(
+					"All readers must have same corresponding subReader maxDoc: reader %v, subReader %v has maxDoc %v instead of %v",
+					i, subIDX, sub.MaxDoc(), childMaxDoc[subIDX]))
+			}
+		}
+	}
+	return nil
+}
+
+func (r *ParallelCompositeReader) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("ParallelCompositeReader(")
+	first := true
+	for sub, _ := range r.completeReaderSet {
+		if !first {
+			buf.WriteString(", ")
+		}
+		first = false
+		fmt.Fprintf(&buf, "%v", sub)
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func (r *ParallelCompositeReader) doClose() (err error) {
+	for reader, _ := range r.completeReaderSet {
+		var err2 error
+		if r.closeSubReaders {
+			err2 = reader.Close()
+		} else {
+			err2 = reader.decRef()
+		}
+		if err == nil {
+			err = err2
+		}
+	}
+	return
+}
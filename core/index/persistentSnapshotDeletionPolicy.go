@@ -0,0 +1,327 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"strconv"
+	"strings"
+)
+
+// index/PersistentSnapshotDeletionPolicy.java
+
+/* Prefix used for the save file. */
+const SNAPSHOTS_PREFIX = "snapshots_"
+
+const (
+	SNAPSHOTS_CODEC_NAME      = "snapshots"
+	SNAPSHOTS_VERSION_START   = 0
+	SNAPSHOTS_VERSION_CURRENT = SNAPSHOTS_VERSION_START
+)
+
+/*
+A SnapshotDeletionPolicy which adds a persistence layer so that
+snapshots can be maintained across the life of an application. The
+snapshots are persisted in a Directory and are committed as soon as
+Snapshot() or Release() is called.
+
+NOTE: Sharing PersistentSnapshotDeletionPolicy instances that write
+to the same directory across writers will corrupt snapshots. Make
+sure every IndexWriter has its own policy, each writing to a different
+Directory. It is OK to use the same Directory that holds the index.
+*/
+type PersistentSnapshotDeletionPolicy struct {
+	*SnapshotDeletionPolicy
+	// The next generation of the save file
+	nextWriteGen int64
+	// Directory storing the snapshots
+	dir store.Directory
+}
+
+/*
+PersistentSnapshotDeletionPolicy wraps another IndexDeletionPolicy to
+enable flexible snapshotting, passing OPEN_MODE_CREATE_OR_APPEND by
+default.
+*/
+func NewPersistentSnapshotDeletionPolicy(primary IndexDeletionPolicy,
+	dir store.Directory) (*PersistentSnapshotDeletionPolicy, error) {
+	return NewPersistentSnapshotDeletionPolicyWithMode(primary, dir, OPEN_MODE_CREATE_OR_APPEND)
+}
+
+/*
+PersistentSnapshotDeletionPolicy wraps another IndexDeletionPolicy to
+enable flexible snapshotting. Prior snapshots saved in dir are loaded
+unless mode is OPEN_MODE_CREATE, in which case they are removed; with
+OPEN_MODE_APPEND there must be prior snapshots to load.
+*/
+func NewPersistentSnapshotDeletionPolicyWithMode(primary IndexDeletionPolicy,
+	dir store.Directory, mode OpenMode) (*PersistentSnapshotDeletionPolicy, error) {
+
+	ans := &PersistentSnapshotDeletionPolicy{
+		SnapshotDeletionPolicy: NewSnapshotDeletionPolicy(primary),
+		dir:                    dir,
+	}
+	if mode == OPEN_MODE_CREATE {
+		if err := ans.clearPriorSnapshots(); err != nil {
+			return nil, err
+		}
+	}
+	if err := ans.loadPriorSnapshots(); err != nil {
+		return nil, err
+	}
+	if mode == OPEN_MODE_APPEND && ans.nextWriteGen == 0 {
+		return nil, errors.New("no snapshots stored in this directory")
+	}
+	return ans, nil
+}
+
+/*
+Drops snapshots of commits that no longer exist, e.g. when the index
+was changed by another writer while the snapshots were saved.
+*/
+func (p *PersistentSnapshotDeletionPolicy) onInit(commits []IndexCommit) error {
+	if err := p.SnapshotDeletionPolicy.onInit(commits); err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+	var changed bool
+	for gen, _ := range p.refCounts {
+		if _, ok := p.indexCommits[gen]; !ok {
+			delete(p.refCounts, gen)
+			changed = true
+		}
+	}
+	if changed {
+		return p.persist()
+	}
+	return nil
+}
+
+/*
+Snapshots the last commit. Once this method returns, the snapshot
+information is persisted in the directory.
+*/
+func (p *PersistentSnapshotDeletionPolicy) Snapshot() (IndexCommit, error) {
+	p.Lock()
+	defer p.Unlock()
+	ic, err := p.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.persist(); err != nil {
+		p.releaseGen(ic.Generation()) // ignore error
+		return nil, err
+	}
+	return ic, nil
+}
+
+/*
+Deletes a snapshotted commit. Once this method returns, the snapshot
+information is persisted in the directory.
+*/
+func (p *PersistentSnapshotDeletionPolicy) Release(commit IndexCommit) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.releaseGen(commit.Generation()); err != nil {
+		return err
+	}
+	if err := p.persist(); err != nil {
+		p.incRef(commit)
+		return err
+	}
+	return nil
+}
+
+/*
+Deletes a snapshotted commit by generation. Once this method returns,
+the snapshot information is persisted in the directory.
+*/
+func (p *PersistentSnapshotDeletionPolicy) ReleaseGen(gen int64) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.releaseGen(gen); err != nil {
+		return err
+	}
+	return p.persist()
+}
+
+func (p *PersistentSnapshotDeletionPolicy) Clone() IndexDeletionPolicy {
+	other := p.SnapshotDeletionPolicy.Clone().(*SnapshotDeletionPolicy)
+	p.Lock()
+	defer p.Unlock()
+	return &PersistentSnapshotDeletionPolicy{
+		SnapshotDeletionPolicy: other,
+		nextWriteGen:           p.nextWriteGen,
+		dir:                    p.dir,
+	}
+}
+
+func (p *PersistentSnapshotDeletionPolicy) persist() (err error) {
+	fileName := fmt.Sprintf("%v%v", SNAPSHOTS_PREFIX, p.nextWriteGen)
+	if err = func() (err error) {
+		var out store.IndexOutput
+		if out, err = p.dir.CreateOutput(fileName, store.IO_CONTEXT_DEFAULT); err != nil {
+			return err
+		}
+		var success = false
+		defer func() {
+			if success {
+				err = out.Close()
+			} else {
+				util.CloseWhileSuppressingError(out)
+				p.dir.DeleteFile(fileName) // ignore error
+			}
+		}()
+
+		if err = codec.WriteHeader(out, SNAPSHOTS_CODEC_NAME, SNAPSHOTS_VERSION_CURRENT); err != nil {
+			return err
+		}
+		if err = out.WriteVInt(int32(len(p.refCounts))); err != nil {
+			return err
+		}
+		for gen, refCount := range p.refCounts {
+			if err = out.WriteVLong(gen); err != nil {
+				return err
+			}
+			if err = out.WriteVInt(int32(refCount)); err != nil {
+				return err
+			}
+		}
+		success = true
+		return nil
+	}(); err != nil {
+		return err
+	}
+
+	if err = p.dir.Sync([]string{fileName}); err != nil {
+		return err
+	}
+
+	if p.nextWriteGen > 0 {
+		lastSaveFile := fmt.Sprintf("%v%v", SNAPSHOTS_PREFIX, p.nextWriteGen-1)
+		p.dir.DeleteFile(lastSaveFile) // ignore error
+	}
+	p.nextWriteGen++
+	return nil
+}
+
+func (p *PersistentSnapshotDeletionPolicy) clearPriorSnapshots() error {
+	files, err := p.dir.ListAll()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file, SNAPSHOTS_PREFIX) {
+			if err = p.dir.DeleteFile(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/*
+Returns the file name the snapshots are currently saved to, or "" if
+no snapshots have been saved.
+*/
+func (p *PersistentSnapshotDeletionPolicy) LastSaveFile() string {
+	p.Lock()
+	defer p.Unlock()
+	if p.nextWriteGen == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v%v", SNAPSHOTS_PREFIX, p.nextWriteGen-1)
+}
+
+func readSnapshots(dir store.Directory, file string) (m map[int64]int, err error) {
+	var in store.IndexInput
+	if in, err = dir.OpenInput(file, store.IO_CONTEXT_DEFAULT); err != nil {
+		return nil, err
+	}
+	defer func() {
+		err2 := in.Close()
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	if _, err = codec.CheckHeader(in, SNAPSHOTS_CODEC_NAME, SNAPSHOTS_VERSION_START, SNAPSHOTS_VERSION_START); err != nil {
+		return nil, err
+	}
+	var count int32
+	if count, err = in.ReadVInt(); err != nil {
+		return nil, err
+	}
+	m = make(map[int64]int)
+	for i := int32(0); i < count; i++ {
+		var commitGen int64
+		if commitGen, err = in.ReadVLong(); err != nil {
+			return nil, err
+		}
+		var refCount int32
+		if refCount, err = in.ReadVInt(); err != nil {
+			return nil, err
+		}
+		m[commitGen] = int(refCount)
+	}
+	return m, nil
+}
+
+/*
+Loads the snapshots saved by the latest save file in the directory,
+and removes any older (or broken) save files.
+*/
+func (p *PersistentSnapshotDeletionPolicy) loadPriorSnapshots() error {
+	p.Lock()
+	defer p.Unlock()
+
+	files, err := p.dir.ListAll()
+	if err != nil {
+		return err
+	}
+	var genLoaded int64 = -1
+	var firstErr error
+	var snapshotFiles []string
+	for _, file := range files {
+		if !strings.HasPrefix(file, SNAPSHOTS_PREFIX) {
+			continue
+		}
+		gen, err := strconv.ParseInt(file[len(SNAPSHOTS_PREFIX):], 10, 64)
+		if err != nil {
+			return err
+		}
+		if genLoaded == -1 || gen > genLoaded {
+			snapshotFiles = append(snapshotFiles, file)
+			m, err := readSnapshots(p.dir, file)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			genLoaded = gen
+			p.refCounts = make(map[int64]int)
+			for commitGen, refCount := range m {
+				p.refCounts[commitGen] = refCount
+			}
+		}
+	}
+
+	if genLoaded == -1 {
+		// Nothing was loaded...
+		return firstErr
+	}
+	if len(snapshotFiles) > 1 {
+		// Remove any broken / old snapshot files:
+		curFileName := fmt.Sprintf("%v%v", SNAPSHOTS_PREFIX, genLoaded)
+		for _, file := range snapshotFiles {
+			if file != curFileName {
+				if err = p.dir.DeleteFile(file); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	p.nextWriteGen = 1 + genLoaded
+	return nil
+}
@@ -0,0 +1,50 @@
+package index
+
+import (
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// index/PointValuesWriter.java
+
+/* Buffers up pending int64 points per doc, then flushes when segment flushes. */
+type PointValuesWriter struct {
+	fieldInfo   *FieldInfo
+	docIDs      []int32
+	values      []int64
+	iwBytesUsed util.Counter
+	bytesUsed   int64
+}
+
+func newPointValuesWriter(fieldInfo *FieldInfo, iwBytesUsed util.Counter) *PointValuesWriter {
+	return &PointValuesWriter{
+		fieldInfo:   fieldInfo,
+		iwBytesUsed: iwBytesUsed,
+	}
+}
+
+/* A document may have many values, added in increasing docID order. */
+func (w *PointValuesWriter) addValue(docID int, value int64) {
+	w.docIDs = append(w.docIDs, int32(docID))
+	w.values = append(w.values, value)
+	w.updateBytesUsed()
+}
+
+func (w *PointValuesWriter) updateBytesUsed() {
+	newBytesUsed := int64(cap(w.docIDs))*util.NUM_BYTES_INT +
+		int64(cap(w.values))*util.NUM_BYTES_LONG
+	w.iwBytesUsed.AddAndGet(newBytesUsed - w.bytesUsed)
+	w.bytesUsed = newBytesUsed
+}
+
+func (w *PointValuesWriter) flush(writer PointsWriter) error {
+	return writer.WriteField(w.fieldInfo, func(visit func(docID int, value int64) error) error {
+		for i, docID := range w.docIDs {
+			if err := visit(int(docID), w.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
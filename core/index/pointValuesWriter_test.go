@@ -0,0 +1,176 @@
+package index
+
+import (
+	acore "github.com/balzaczyy/golucene/analysis/core"
+	_ "github.com/balzaczyy/golucene/core/codec/lucene410"
+	docu "github.com/balzaczyy/golucene/core/document"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+/*
+Indexes segmentCount segments of docCount docs each. The id of the
+j-th doc of the i-th segment is i*docCount+j; its "value" points are
+returned by values(id), and it stores its id.
+*/
+func newPointsWriter(t *testing.T, d store.Directory, segmentCount, docCount int,
+	values func(id int) []int64) *IndexWriter {
+
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+	conf.SetMergePolicy(NO_MERGE_POLICY)
+	conf.SetMergeScheduler(NewSerialMergeScheduler())
+	w, err := NewIndexWriter(d, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < segmentCount; i++ {
+		for j := 0; j < docCount; j++ {
+			id := i*docCount + j
+			doc := docu.NewDocument()
+			doc.Add(docu.NewStoredFieldFromString("id", strconv.Itoa(id)))
+			for _, v := range values(id) {
+				doc.Add(docu.NewLongPoint("value", v))
+			}
+			if err = w.AddDocument(doc.Fields()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err = w.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+/* Checks the ids of the docs of the leaf found by a range search, one per matching value. */
+func assertPointRange(t *testing.T, leaf AtomicReader, values func(id int) []int64, min, max int64) {
+	var expected, actual []int
+	for docID := 0; docID < leaf.MaxDoc(); docID++ {
+		if leaf.LiveDocs() != nil && !leaf.LiveDocs().At(docID) {
+			continue
+		}
+		doc, err := leaf.Document(docID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := strconv.Atoi(doc.Get("id"))
+		for _, v := range values(id) {
+			if v >= min && v <= max {
+				expected = append(expected, id)
+			}
+		}
+	}
+
+	points, err := leaf.PointValues("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points != nil {
+		if err = points.Intersect(min, max, func(docID int) {
+			doc, err := leaf.Document(docID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, _ := strconv.Atoi(doc.Get("id"))
+			actual = append(actual, id)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Ints(expected)
+	sort.Ints(actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("[%v, %v]: expected %v, got %v", min, max, expected, actual)
+	}
+}
+
+// Every third doc has no value; the others have one or two.
+func testPointValues(id int) []int64 {
+	switch id % 3 {
+	case 0:
+		return nil
+	case 1:
+		return []int64{int64(id * 10)}
+	}
+	return []int64{int64(-id), int64(id * 10)}
+}
+
+var testPointRanges = [][2]int64{
+	{0, 100}, {-20, -5}, {35, 35}, {math.MinInt64, math.MaxInt64}, {1000, 2000}, {-4, 9},
+}
+
+func TestIndexPointValues(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newPointsWriter(t, d, 2, 20, testPointValues)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenDirectoryReader(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	assertEquals(t, 2, len(reader.Leaves()))
+	for _, ctx := range reader.Leaves() {
+		leaf := ctx.Reader().(AtomicReader)
+		for _, r := range testPointRanges {
+			assertPointRange(t, leaf, testPointValues, r[0], r[1])
+		}
+		points, err := leaf.PointValues("id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if points != nil {
+			t.Errorf("field without points has %v points", points.Size())
+		}
+	}
+}
+
+func TestMergePointValues(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := newPointsWriter(t, d, 3, 10, testPointValues)
+	deleteSegmentDocs(t, w, w.segmentInfos.Segments[1], 1, 2, 5)
+
+	registerSegmentMerges(t, w, 3)
+	if err := w.merge(w.nextMerge()); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, len(w.segmentInfos.Segments))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := OpenDirectoryReader(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	assertEquals(t, 27, reader.NumDocs())
+	leaf := reader.Leaves()[0].Reader().(AtomicReader)
+	assertEquals(t, 27, leaf.MaxDoc())
+	for _, r := range testPointRanges {
+		assertPointRange(t, leaf, testPointValues, r[0], r[1])
+	}
+
+	// the points of deleted docs are gone
+	points, err := leaf.PointValues("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for id := 0; id < 30; id++ {
+		if id != 11 && id != 12 && id != 15 {
+			size += int64(len(testPointValues(id)))
+		}
+	}
+	assertEquals(t, size, points.Size())
+}
@@ -16,6 +16,7 @@ import (
 
 type IndexReader interface {
 	io.Closer
+	incRef()
 	decRef() error
 	ensureOpen()
 	registerParentReader(r IndexReader)
@@ -49,6 +50,14 @@ type IndexReader interface {
 	// Document returned here contains that class not
 	//model.IndexableField
 	Document(docID int) (doc *docu.Document, err error)
+	// Loads the stored fields of the given document into visitor,
+	// materializing only the fields its NeedsField() accepts. E.g.
+	//
+	//   visitor := docu.NewDocumentStoredFieldVisitor("title")
+	//   err := reader.DocumentWithVisitor(docID, visitor)
+	//   title := visitor.Document().Get("title")
+	//
+	DocumentWithVisitor(docID int, visitor StoredFieldVisitor) error
 	doClose() error
 	Context() IndexReaderContext
 	Leaves() []*AtomicReaderContext
@@ -94,6 +103,18 @@ func newIndexReader(spi IndexReaderImplSPI) *IndexReaderImpl {
 	}
 }
 
+/*
+Expert: increments the refCount of this IndexReader instance.
+RefCounts are used to determine when a reader can be closed safely,
+i.e. as soon as there are no more references. Be sure to always call
+a corresponding decRef(), in a defer clause; otherwise the reader may
+never be closed.
+*/
+func (r *IndexReaderImpl) incRef() {
+	r.ensureOpen()
+	atomic.AddInt32(&r.refCount, 1)
+}
+
 func (r *IndexReaderImpl) decRef() error {
 	// only check refcount here (don't call ensureOpen()), so we can
 	// still close the reader if it was made invalid by a child:
@@ -151,23 +172,27 @@ func (r *IndexReaderImpl) reportCloseToParentReaders() {
 	r.parentReadersLock.RLock()
 	defer r.parentReadersLock.RUnlock()
 	for parent, _ := range r.parentReaders {
-		if p, ok := parent.(*IndexReaderImpl); ok {
-			p.closedByChild = true
-			// cross memory barrier by a fake write:
-			// FIXME do we need it in Go?
-			atomic.AddInt32(&p.refCount, 0)
-			// recurse:
-			p.reportCloseToParentReaders()
-		} else if p, ok := parent.(*BaseCompositeReader); ok {
-			p.closedByChild = true
-			// cross memory barrier by a fake write:
-			// FIXME do we need it in Go?
-			atomic.AddInt32(&p.refCount, 0)
-			// recurse:
-			p.reportCloseToParentReaders()
-		} else {
+		var p *IndexReaderImpl
+		switch parent := parent.(type) {
+		case *IndexReaderImpl:
+			p = parent
+		case *BaseCompositeReader:
+			p = parent.IndexReaderImpl
+		case *FilterLeafReader:
+			p = parent.IndexReaderImpl
+		case *ParallelAtomicReader:
+			p = parent.IndexReaderImpl
+		case *ParallelCompositeReader:
+			p = parent.IndexReaderImpl
+		default:
 			panic(fmt.Sprintf("Unknown IndexReader type: %v", reflect.TypeOf(parent).Name()))
 		}
+		p.closedByChild = true
+		// cross memory barrier by a fake write:
+		// FIXME do we need it in Go?
+		atomic.AddInt32(&p.refCount, 0)
+		// recurse:
+		p.reportCloseToParentReaders()
 	}
 }
 
@@ -184,6 +209,10 @@ func (r *IndexReaderImpl) Document(docID int) (doc *docu.Document, err error) {
 	return visitor.Document(), nil
 }
 
+func (r *IndexReaderImpl) DocumentWithVisitor(docID int, visitor StoredFieldVisitor) error {
+	return r.VisitDocument(docID, visitor)
+}
+
 /*
 Returns true if any documents have been deleted. Implementers should
 consider overriding this method if maxDoc() or numDocs() are not
@@ -244,6 +273,17 @@ type ARFieldsReader interface {
 	 *  were indexed. The returned instance should only be
 	 *  used by a single thread. */
 	NormValues(field string) (ndv NumericDocValues, err error)
+	// Returns NumericDocValues for this field, or nil if no
+	// NumericDocValues were indexed for this field.
+	NumericDocValues(field string) (ndv NumericDocValues, err error)
+	// Returns SortedDocValues for this field, or nil if no
+	// SortedDocValues were indexed for this field.
+	SortedDocValues(field string) (sdv SortedDocValues, err error)
+	// Returns the PointValues for this field, or nil if no points were
+	// indexed for this field.
+	PointValues(field string) (pv PointValues, err error)
+	// Get the FieldInfos describing all fields in this reader.
+	FieldInfos() FieldInfos
 }
 
 type AtomicReader interface {
@@ -9,6 +9,8 @@ import (
 	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -321,6 +323,63 @@ func LastCommitGeneration(files []string) int64 {
 	return max
 }
 
+/*
+Returns the generation of the newest segments_N file in the listing
+which was fully committed, i.e. ends with a codec footer. A segments_N
+file of a prepared but not yet finished commit has no footer yet, so
+it's skipped in favor of the previous commit.
+*/
+func LastFinishedCommitGeneration(directory store.Directory, files []string) (int64, error) {
+	var gens []int64
+	for _, file := range files {
+		if strings.HasPrefix(file, INDEX_FILENAME_SEGMENTS) && file != INDEX_FILENAME_SEGMENTS_GEN {
+			gens = append(gens, GenerationFromSegmentsFileName(file))
+		}
+	}
+	sort.Sort(sort.Reverse(int64Slice(gens)))
+	for _, gen := range gens {
+		finished, err := hasCommitFooter(directory,
+			util.FileNameFromGeneration(util.SEGMENTS, "", gen))
+		if err != nil {
+			return -1, err
+		}
+		if finished {
+			return gen, nil
+		}
+	}
+	return -1, nil
+}
+
+func hasCommitFooter(directory store.Directory, name string) (ok bool, err error) {
+	in, err := directory.OpenInput(name, store.IO_CONTEXT_READONCE)
+	if os.IsNotExist(err) {
+		return false, nil // deleted since listed
+	} else if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err2 := in.Close(); err == nil {
+			err = err2
+		}
+	}()
+	if in.Length() < codec.FOOTER_LENGTH {
+		return false, nil
+	}
+	if _, err = codec.RetrieveChecksum(in); err != nil {
+		if _, corrupt := err.(*codec.CorruptIndexError); corrupt {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type int64Slice []int64
+
+func (p int64Slice) Len() int           { return len(p) }
+func (p int64Slice) Less(i, j int) bool { return p[i] < p[j] }
+func (p int64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
 func (sis *SegmentInfos) SegmentsFileName() string {
 	return util.FileNameFromGeneration(util.SEGMENTS, "", sis.lastGeneration)
 }
@@ -451,8 +510,9 @@ func (sis *SegmentInfos) Read(directory store.Directory, segmentFileName string)
 			if codecName, err = input.ReadString(); err != nil {
 				return
 			}
-			fCodec = LoadCodec(codecName)
-			assert2(fCodec != nil, "Invalid codec name: %v", codecName)
+			if fCodec, err = ForName(codecName); err != nil {
+				return errors.New(fmt.Sprintf("%v (segment: %v, resource: %v)", err, segName, input))
+			}
 			// fmt.Printf("SIS.read seg=%v codec=%v\n", seg, fCodec)
 			var info *SegmentInfo
 			if info, err = fCodec.SegmentInfoFormat().SegmentInfoReader().Read(directory, segName, store.IO_CONTEXT_READ); err != nil {
@@ -556,7 +616,13 @@ func (sis *SegmentInfos) ReadAll(directory store.Directory) error {
 	return err
 }
 
-func (sis *SegmentInfos) write(directory store.Directory) (err error) {
+/*
+Writes the next segments_N file, advancing the generation, but leaves
+it pending: the checksum footer is only written by FinishCommit(), so
+until then readers see the file as corrupt and keep using the
+previous commit.
+*/
+func (sis *SegmentInfos) Write(directory store.Directory) (err error) {
 	segmentsFilename := sis.nextSegmentFilename()
 
 	// Always advance the generation on write:
@@ -676,7 +742,9 @@ func (sis *SegmentInfos) write(directory store.Directory) (err error) {
 // }
 
 /*
-Returns a copy of this instance, also copying each SegmentInfo.
+Returns a copy of this instance, also copying each SegmentCommitInfo
+so that the copy can be changed independently. SegmentInfo instances
+are shared.
 */
 func (sis *SegmentInfos) Clone() *SegmentInfos {
 	return sis.clone(false)
@@ -708,7 +776,11 @@ func (sis *SegmentInfos) updateGeneration(other *SegmentInfos) {
 	sis.generation = other.generation
 }
 
-func (sis *SegmentInfos) rollbackCommit(dir store.Directory) {
+/*
+Aborts a commit started by Write(), closing and deleting the pending
+segments_N file.
+*/
+func (sis *SegmentInfos) RollbackCommit(dir store.Directory) {
 	if sis.pendingSegnOutput != nil {
 		// Suppress so we keep throwing the original error in our caller
 		util.CloseWhileSuppressingError(sis.pendingSegnOutput)
@@ -736,24 +808,25 @@ func (sis *SegmentInfos) toString(dir store.Directory) string {
 /*
 Call this to start a commit. This writes the new segments file, but
 writes an invalid checksum at the end, so that it is not visible to
-readers. Once this is called you must call finishCommit() to complete
-the commit or rollbackCommit() to abort it.
+readers. Once this is called you must call FinishCommit() to complete
+the commit or RollbackCommit() to abort it.
 
 Note: changed() should be called prior to this method if changes have
 been made to this SegmentInfos instance.
 */
 func (sis *SegmentInfos) prepareCommit(dir store.Directory) error {
 	assert2(sis.pendingSegnOutput == nil, "prepareCommit was already called")
-	return sis.write(dir)
+	return sis.Write(dir)
 }
 
 /*
-Returns all file names referenced by SegmentInfo instances matching
-the provided Directory (ie files associated with any "external"
-segments are skipped). The returned collection is recomputed on each
-invocation.
+Returns all file names referenced by SegmentInfo instances, plus the
+segments_N file if includeSegmentsFile is true, deduplicated and
+sorted. The returned slice is recomputed on each invocation.
+
+All segments must reside in the same Directory.
 */
-func (sis *SegmentInfos) files(dir store.Directory, includeSegmentsFile bool) []string {
+func (sis *SegmentInfos) Files(includeSegmentsFile bool) ([]string, error) {
 	files := make(map[string]bool)
 	if includeSegmentsFile {
 		if segmentFileName := sis.SegmentsFileName(); segmentFileName != "" {
@@ -761,21 +834,42 @@ func (sis *SegmentInfos) files(dir store.Directory, includeSegmentsFile bool) []
 		}
 	}
 	for _, info := range sis.Segments {
-		assert(info.Info.Dir == dir)
-		// if info.Info.dir == dir {
+		if first := sis.Segments[0].Info; info.Info.Dir != first.Dir {
+			return nil, errors.New(fmt.Sprintf(
+				"segment %v is in directory %v, but segment %v is in directory %v",
+				info.Info.Name, info.Info.Dir, first.Name, first.Dir))
+		}
 		for _, file := range info.Files() {
 			files[file] = true
 		}
-		// }
 	}
 	var res = make([]string, 0, len(files))
 	for file, _ := range files {
 		res = append(res, file)
 	}
-	return res
+	sort.Strings(res)
+	return res, nil
 }
 
-func (sis *SegmentInfos) finishCommit(dir store.Directory) (fileName string, err error) {
+/*
+Returns all file names referenced by SegmentInfo instances, which
+must all reside in the provided Directory.
+*/
+func (sis *SegmentInfos) files(dir store.Directory, includeSegmentsFile bool) []string {
+	for _, info := range sis.Segments {
+		assert(info.Info.Dir == dir)
+	}
+	files, err := sis.Files(includeSegmentsFile)
+	assertn(err == nil, "%v", err)
+	return files
+}
+
+/*
+Completes a commit started by Write(): writes the checksum footer,
+syncs the segments_N file and points segments.gen at it. Returns the
+name of the committed segments_N file.
+*/
+func (sis *SegmentInfos) FinishCommit(dir store.Directory) (fileName string, err error) {
 	assert(dir != nil)
 	assert2(sis.pendingSegnOutput != nil, "prepareCommit was not called")
 	if err = func() error {
@@ -783,14 +877,14 @@ func (sis *SegmentInfos) finishCommit(dir store.Directory) (fileName string, err
 		defer func() {
 			if !success {
 				// Closes pendingSegnOutput & delets partial segments_N:
-				sis.rollbackCommit(dir)
+				sis.RollbackCommit(dir)
 			} else {
 				err := func() error {
 					var success = false
 					defer func() {
 						if !success {
 							// Closes pendingSegnOutput & delets partial segments_N:
-							sis.rollbackCommit(dir)
+							sis.RollbackCommit(dir)
 						} else {
 							sis.pendingSegnOutput = nil
 						}
@@ -888,11 +982,107 @@ func (sis *SegmentInfos) Clear() {
 	sis.Segments = sis.Segments[:0] // reuse existing space
 }
 
+/*
+Returns the position of the provided SegmentCommitInfo, or -1 if it
+is not in this SegmentInfos.
+
+WARNING: O(N) cost
+*/
+func (sis *SegmentInfos) indexOf(si *SegmentCommitInfo) int {
+	for i, info := range sis.Segments {
+		if info == si {
+			return i
+		}
+	}
+	return -1
+}
+
 /*
 Remove the provided SegmentCommitInfo.
 
 WARNING: O(N) cost
 */
 func (sis *SegmentInfos) remove(si *SegmentCommitInfo) {
-	panic("not implemented yet")
+	for i, info := range sis.Segments {
+		if info == si {
+			copy(sis.Segments[i:], sis.Segments[i+1:])
+			sis.Segments[len(sis.Segments)-1] = nil
+			sis.Segments = sis.Segments[:len(sis.Segments)-1]
+			return
+		}
+	}
+}
+
+/*
+Replaces all segments in the merge with the merged segment, placed
+where the first merged segment was; the merged segment is dropped
+instead if dropSegment is true.
+*/
+func (sis *SegmentInfos) applyMergeChanges(merge *OneMerge, dropSegment bool) {
+	mergedAway := make(map[*SegmentCommitInfo]bool)
+	for _, info := range merge.segments {
+		mergedAway[info] = true
+	}
+	inserted := false
+	newSegIdx := 0
+	for _, info := range sis.Segments {
+		assert(info != nil)
+		if mergedAway[info] {
+			if !inserted && !dropSegment {
+				sis.Segments[newSegIdx] = merge.info
+				inserted = true
+				newSegIdx++
+			}
+		} else {
+			sis.Segments[newSegIdx] = info
+			newSegIdx++
+		}
+	}
+	// the rest of the segments in list are duplicates, so don't remove
+	// from map, only list!
+	for i := newSegIdx; i < len(sis.Segments); i++ {
+		sis.Segments[i] = nil
+	}
+	sis.Segments = sis.Segments[:newSegIdx]
+
+	// Either we found place to insert segment, or, we did not, but only
+	// because all segments we merged becamee deleted while we are
+	// merging, in which case it should be the case that the new
+	// segment is also all deleted, we insert it at the beginning if it
+	// should not be dropped:
+	if !inserted && !dropSegment {
+		sis.Segments = append([]*SegmentCommitInfo{merge.info}, sis.Segments...)
+	}
+}
+
+/*
+Returns the sum of all segments' docCount, including deleted
+documents.
+*/
+func (sis *SegmentInfos) TotalMaxDoc() int {
+	count := 0
+	for _, info := range sis.Segments {
+		count += info.Info.DocCount()
+	}
+	return count
+}
+
+/*
+Returns the total size in bytes of all files referenced by the
+segments, as found in dir. Each segment's share is computed the same
+way as SegmentCommitInfo.SizeInBytes(), which is what merge policies
+start from before pro-rating deletions.
+*/
+func (sis *SegmentInfos) SizeInBytes(dir store.Directory) (int64, error) {
+	var sum int64
+	for _, info := range sis.Segments {
+		for _, name := range info.Files() {
+			n, err := dir.FileLength(name)
+			if err != nil {
+				return 0, err
+			}
+			sum += n
+		}
+	}
+	return sum, nil
 }
@@ -1,7 +1,13 @@
 package index
 
 import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"strings"
 	"testing"
 )
 
@@ -21,8 +27,234 @@ func TestLastCommitGeneration(t *testing.T) {
 	}
 }
 
+// Just enough of a codec for SegmentCommitInfo.Files() and
+// ReadFieldInfos(): no live docs, no fields.
+type noDeletesCodec struct{ Codec }
+
+func (c noDeletesCodec) LiveDocsFormat() LiveDocsFormat { return noLiveDocsFormat{} }
+
+func (c noDeletesCodec) FieldInfosFormat() FieldInfosFormat { return noFieldInfosFormat{} }
+
+type noLiveDocsFormat struct{ LiveDocsFormat }
+
+func (f noLiveDocsFormat) Files(*SegmentCommitInfo) []string { return nil }
+
+type noFieldInfosFormat struct{ FieldInfosFormat }
+
+func (f noFieldInfosFormat) FieldInfosReader() FieldInfosReader {
+	return func(store.Directory, string, string, store.IOContext) (model.FieldInfos, error) {
+		return model.NewFieldInfos(nil), nil
+	}
+}
+
+// Adds a segment of docCount docs, backed by a single file of size bytes.
+func addSyntheticSegment(t *testing.T, sis *SegmentInfos, d store.Directory,
+	docCount, size int) *SegmentCommitInfo {
+
+	name := fmt.Sprintf("_%v", len(sis.Segments))
+	out, err := d.CreateOutput(name+".dat", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.WriteBytes(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	si := model.NewSegmentInfo(d, util.VERSION_LATEST, name, docCount, false, noDeletesCodec{}, nil)
+	si.SetFiles(map[string]bool{name + ".dat": true})
+	info := NewSegmentCommitInfo(si, 0, -1, -1, -1)
+	sis.Segments = append(sis.Segments, info)
+	return info
+}
+
+func assertTotals(t *testing.T, sis *SegmentInfos, d store.Directory, maxDoc int, size int64) {
+	assertEquals(t, maxDoc, sis.TotalMaxDoc())
+	n, err := sis.SizeInBytes(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, size, n)
+}
+
+func TestSegmentInfosTotals(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{}
+	assertTotals(t, sis, d, 0, 0)
+
+	first := addSyntheticSegment(t, sis, d, 10, 100)
+	addSyntheticSegment(t, sis, d, 20, 300)
+	assertTotals(t, sis, d, 30, 400)
+
+	// must agree with what merge policies size segments from
+	size, err := first.SizeInBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, int64(100), size)
+
+	addSyntheticSegment(t, sis, d, 5, 50)
+	assertTotals(t, sis, d, 35, 450)
+
+	sis.remove(first)
+	assertEquals(t, 2, len(sis.Segments))
+	assertTotals(t, sis, d, 25, 350)
+
+	sis.Clear()
+	assertTotals(t, sis, d, 0, 0)
+}
+
 func assertEquals(t *testing.T, a, b interface{}) {
 	if a != b {
 		t.Errorf("Expected '%v', but '%v'", a, b)
 	}
 }
+
+func readCommit(t *testing.T, d store.Directory) *SegmentInfos {
+	sis := &SegmentInfos{}
+	if err := sis.ReadAll(d); err != nil {
+		t.Fatal(err)
+	}
+	return sis
+}
+
+func TestSegmentInfosCommit(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{generation: -1, lastGeneration: -1}
+	sis.userData = map[string]string{"commit": "first"}
+	if err := sis.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	name, err := sis.FinishCommit(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "segments_1", name)
+	assertFileExists(t, d, INDEX_FILENAME_SEGMENTS_GEN, true)
+	assertEquals(t, "first", readCommit(t, d).userData["commit"])
+
+	// a rolled back commit leaves nothing behind
+	sis.userData = map[string]string{"commit": "rolled back"}
+	if err = sis.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	sis.RollbackCommit(d)
+	assertFileExists(t, d, "segments_2", false)
+	assertEquals(t, "first", readCommit(t, d).userData["commit"])
+
+	// the next commit still advances the generation
+	sis.userData = map[string]string{"commit": "second"}
+	if err = sis.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	if name, err = sis.FinishCommit(d); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "segments_3", name)
+	assertEquals(t, "second", readCommit(t, d).userData["commit"])
+
+	// crash between Write and FinishCommit: segments_4 made it to
+	// disk, but without its checksum footer
+	sis.userData = map[string]string{"commit": "crashed"}
+	if err = sis.Write(d); err != nil {
+		t.Fatal(err)
+	}
+	if err = sis.pendingSegnOutput.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileExists(t, d, "segments_4", true)
+	last := readCommit(t, d)
+	assertEquals(t, int64(3), last.lastGeneration)
+	assertEquals(t, "second", last.userData["commit"])
+}
+
+func TestSegmentInfosClone(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{userData: map[string]string{"a": "b"}}
+	addSyntheticSegment(t, sis, d, 10, 100)
+
+	clone := sis.Clone()
+	clone.Segments[0].SetDelCount(3)
+	addSyntheticSegment(t, clone, d, 20, 200)
+	clone.userData["a"] = "c"
+	clone.changed()
+
+	assertEquals(t, 1, len(sis.Segments))
+	assertEquals(t, 0, sis.Segments[0].DelCount())
+	assertEquals(t, "b", sis.userData["a"])
+	assertEquals(t, int64(0), sis.version)
+	assertEquals(t, 2, len(clone.Segments))
+}
+
+func TestSegmentInfosFiles(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{}
+	sis.generation, sis.lastGeneration = 2, 2
+	addSyntheticSegment(t, sis, d, 10, 100)
+	cfs := addSyntheticSegment(t, sis, d, 20, 200)
+	cfs.Info.SetUseCompoundFile(true)
+	cfs.Info.SetFiles(map[string]bool{"_1.cfs": true, "_1.cfe": true, "_1.si": true})
+
+	files, err := sis.Files(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, "[_0.dat _1.cfe _1.cfs _1.si]", fmt.Sprintf("%v", files))
+
+	// stable across calls, so that ref counting is too
+	for i := 0; i < 5; i++ {
+		again, err := sis.Files(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, "[_0.dat _1.cfe _1.cfs _1.si segments_2]", fmt.Sprintf("%v", again))
+	}
+
+	// segments of another directory can't be accounted for
+	addSyntheticSegment(t, sis, store.NewRAMDirectory(), 5, 50)
+	if _, err = sis.Files(false); err == nil {
+		t.Error("Expected error on segments of different directories")
+	}
+}
+
+func TestReadUnknownCodec(t *testing.T) {
+	d := store.NewRAMDirectory()
+	out, err := d.CreateOutput("segments_1", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = codec.WriteHeader(out, "segments", VERSION_49)
+	if err == nil {
+		err = out.WriteLong(1) // version
+	}
+	if err == nil {
+		err = out.WriteInt(1) // counter
+	}
+	if err == nil {
+		err = out.WriteInt(1) // number of segments
+	}
+	if err == nil {
+		err = out.WriteString("_0")
+	}
+	if err == nil {
+		err = out.WriteString("NoSuchCodec")
+	}
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sis := &SegmentInfos{}
+	err = sis.Read(d, "segments_1")
+	if err == nil {
+		t.Fatal("expected an error for an unknown codec")
+	}
+	if !strings.Contains(err.Error(), "NoSuchCodec") {
+		t.Errorf("error should name the unknown codec: %v", err)
+	}
+	assertEquals(t, 0, len(sis.Segments))
+}
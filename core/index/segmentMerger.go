@@ -0,0 +1,762 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	docu "github.com/balzaczyy/golucene/core/document"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"sort"
+)
+
+// index/MergeState.java
+
+/* Holds common state used during segment merging. */
+type MergeState struct {
+	// SegmentInfo of the newly merged segment.
+	segmentInfo *SegmentInfo
+	// FieldInfos of the newly merged segment.
+	fieldInfos FieldInfos
+	// Readers being merged.
+	readers []*SegmentReader
+	// Maps docIDs of each reader around its deletions, to the merged
+	// segment's docIDs less the reader's docBase; nil entry if the
+	// reader has no deletions.
+	docMaps [][]int
+	// New docID base per reader.
+	docBase []int
+	// Holds the CheckAbort instance, which is invoked periodically to
+	// see if the merge has been aborted.
+	checkAbort CheckAbort
+	infoStream util.InfoStream
+}
+
+func newMergeState(readers []*SegmentReader, segmentInfo *SegmentInfo,
+	infoStream util.InfoStream, checkAbort CheckAbort) *MergeState {
+	return &MergeState{
+		readers:     readers,
+		segmentInfo: segmentInfo,
+		infoStream:  infoStream,
+		checkAbort:  checkAbort,
+	}
+}
+
+/* Returns the docID in the merged segment of the given doc of the given reader, or -1 if it's deleted. */
+func (ms *MergeState) mapDoc(reader, docID int) int {
+	if docMap := ms.docMaps[reader]; docMap != nil {
+		if docID = docMap[docID]; docID == -1 {
+			return -1
+		}
+	}
+	return ms.docBase[reader] + docID
+}
+
+// index/SegmentMerger.java
+
+/*
+The SegmentMerger class combines two or more Segments, represented by
+a SegmentReader, into a single Segment. Call the merge() method to
+combine the segments.
+*/
+type SegmentMerger struct {
+	directory         store.Directory
+	termIndexInterval int
+	codec             Codec
+	context           store.IOContext
+	mergeState        *MergeState
+	fieldInfosBuilder *FieldInfosBuilder
+}
+
+func newSegmentMerger(readers []*SegmentReader, segmentInfo *SegmentInfo,
+	infoStream util.InfoStream, dir store.Directory, termIndexInterval int,
+	checkAbort CheckAbort, fieldNumbers *FieldNumbers,
+	context store.IOContext) *SegmentMerger {
+
+	ans := &SegmentMerger{
+		directory:         dir,
+		termIndexInterval: termIndexInterval,
+		codec:             segmentInfo.Codec().(Codec),
+		context:           context,
+		mergeState:        newMergeState(readers, segmentInfo, infoStream, checkAbort),
+		fieldInfosBuilder: NewFieldInfosBuilder(fieldNumbers),
+	}
+	segmentInfo.SetDocCount(ans.setDocMaps())
+	return ans
+}
+
+/*
+Returns why a segment with the given FieldInfos can't be merged yet,
+or "" if it can: the merger can't write term vectors, nor doc values
+other than numeric and sorted ones.
+*/
+func unmergeableFields(fieldInfos FieldInfos) string {
+	if fieldInfos.HasVectors {
+		return "term vectors"
+	}
+	for _, fi := range fieldInfos.Values {
+		switch fi.DocValuesType() {
+		case 0, DOC_VALUES_TYPE_NUMERIC, DOC_VALUES_TYPE_SORTED:
+		default:
+			return fmt.Sprintf("%v doc values of field %v", fi.DocValuesType(), fi.Name)
+		}
+	}
+	return ""
+}
+
+/* True if any merging should happen */
+func (m *SegmentMerger) shouldMerge() bool {
+	return m.mergeState.segmentInfo.DocCount() > 0
+}
+
+/*
+Merges the readers into the directory passed to the constructor.
+Returns the MergeState of the merged segment, holding its FieldInfos.
+*/
+func (m *SegmentMerger) merge() (*MergeState, error) {
+	assert2(m.shouldMerge(), "Merge would result in 0 document segment")
+	// NOTE: it's important to add calls to checkAbort.Work(...) if you
+	// make any changes to this method that will spend a lot of time.
+	// The frequency of this check impacts how long Rollback() takes to
+	// actually stop the routines.
+	m.mergeFieldInfos()
+	// IndexWriter.registerMerge() rejects such segments
+	if what := unmergeableFields(m.mergeState.fieldInfos); what != "" {
+		return nil, errors.New(fmt.Sprintf(
+			"merging %v is not supported yet: %v", what, m.mergeState.segmentInfo.Name))
+	}
+
+	numMerged, err := m.mergeFields()
+	if err != nil {
+		return nil, err
+	}
+	assert2(numMerged == m.mergeState.segmentInfo.DocCount(),
+		"merged %v docs instead of %v", numMerged, m.mergeState.segmentInfo.DocCount())
+
+	segmentWriteState := NewSegmentWriteState(m.mergeState.infoStream,
+		m.directory, m.mergeState.segmentInfo, m.mergeState.fieldInfos,
+		m.termIndexInterval, nil, m.context)
+	if err = m.mergeTerms(segmentWriteState); err != nil {
+		return nil, err
+	}
+	if m.mergeState.fieldInfos.HasDocValues {
+		if err = m.mergeDocValues(segmentWriteState); err != nil {
+			return nil, err
+		}
+	}
+	if m.mergeState.fieldInfos.HasNorms {
+		if err = m.mergeNorms(segmentWriteState); err != nil {
+			return nil, err
+		}
+	}
+	if m.mergeState.fieldInfos.HasPointValues {
+		if err = m.mergePoints(segmentWriteState); err != nil {
+			return nil, err
+		}
+	}
+
+	// write the merged infos
+	fieldInfosWriter := m.codec.FieldInfosFormat().FieldInfosWriter()
+	if err = fieldInfosWriter(m.directory, m.mergeState.segmentInfo.Name,
+		"", m.mergeState.fieldInfos, m.context); err != nil {
+		return nil, err
+	}
+	return m.mergeState, nil
+}
+
+func (m *SegmentMerger) mergeFieldInfos() {
+	for _, reader := range m.mergeState.readers {
+		for _, fi := range reader.FieldInfos().Values {
+			m.fieldInfosBuilder.Add(fi)
+		}
+	}
+	m.mergeState.fieldInfos = m.fieldInfosBuilder.Finish()
+}
+
+/* Remaps docIDs around deletions, returning the number of live docs. */
+func (m *SegmentMerger) setDocMaps() int {
+	n := len(m.mergeState.readers)
+	m.mergeState.docMaps = make([][]int, n)
+	m.mergeState.docBase = make([]int, n)
+	docBase := 0
+	for i, reader := range m.mergeState.readers {
+		m.mergeState.docBase[i] = docBase
+		if liveDocs := reader.LiveDocs(); liveDocs != nil {
+			docMap := make([]int, reader.MaxDoc())
+			del := 0
+			for j := range docMap {
+				if liveDocs.At(j) {
+					docMap[j] = j - del
+				} else {
+					docMap[j] = -1
+					del++
+				}
+			}
+			m.mergeState.docMaps[i] = docMap
+		}
+		docBase += reader.NumDocs()
+	}
+	return docBase
+}
+
+// codecs/StoredFieldsWriter.java
+
+/* Merges the stored fields of the live docs, returning the number of docs merged. */
+func (m *SegmentMerger) mergeFields() (docCount int, err error) {
+	var fieldsWriter StoredFieldsWriter
+	if fieldsWriter, err = m.codec.StoredFieldsFormat().FieldsWriter(
+		m.directory, m.mergeState.segmentInfo, m.context); err != nil {
+		return 0, err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(fieldsWriter)
+		} else {
+			fieldsWriter.Abort()
+			util.CloseWhileSuppressingError(fieldsWriter)
+		}
+	}()
+
+	fieldInfos := m.mergeState.fieldInfos
+	for _, reader := range m.mergeState.readers {
+		liveDocs := reader.LiveDocs()
+		for i, maxDoc := 0, reader.MaxDoc(); i < maxDoc; i++ {
+			if liveDocs != nil && !liveDocs.At(i) {
+				// skip deleted docs
+				continue
+			}
+			visitor := docu.NewDocumentStoredFieldVisitor()
+			if err = reader.VisitDocument(i, visitor); err != nil {
+				return 0, err
+			}
+			if err = fieldsWriter.StartDocument(); err != nil {
+				return 0, err
+			}
+			for _, field := range visitor.Document().Fields() {
+				if err = fieldsWriter.WriteField(fieldInfos.FieldInfoByName(field.Name()), field); err != nil {
+					return 0, err
+				}
+			}
+			if err = fieldsWriter.FinishDocument(); err != nil {
+				return 0, err
+			}
+			docCount++
+			if err = m.mergeState.checkAbort.Work(300); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err = fieldsWriter.Finish(fieldInfos, docCount); err != nil {
+		return 0, err
+	}
+	success = true
+	return docCount, nil
+}
+
+// codecs/FieldsConsumer.java
+
+func (m *SegmentMerger) mergeTerms(state *SegmentWriteState) (err error) {
+	var consumer FieldsConsumer
+	if consumer, err = m.codec.PostingsFormat().FieldsConsumer(state); err != nil {
+		return err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(consumer)
+		} else {
+			util.CloseWhileSuppressingError(consumer)
+		}
+	}()
+
+	// fields are written in name order, as on flush
+	var names []string
+	for _, fi := range m.mergeState.fieldInfos.Values {
+		if fi.IsIndexed() {
+			names = append(names, fi.Name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var subs []*termsEnumSub
+		for i, reader := range m.mergeState.readers {
+			if fi := reader.FieldInfos().FieldInfoByName(name); fi == nil || !fi.IsIndexed() {
+				continue
+			}
+			if terms := reader.Fields().Terms(name); terms != nil {
+				subs = append(subs, &termsEnumSub{index: i, terms: terms.Iterator(nil)})
+			}
+		}
+		if len(subs) == 0 {
+			continue
+		}
+		var termsConsumer TermsConsumer
+		if termsConsumer, err = consumer.AddField(m.mergeState.fieldInfos.FieldInfoByName(name)); err != nil {
+			return err
+		}
+		if err = m.mergeTermsField(termsConsumer, m.mergeState.fieldInfos.FieldInfoByName(name), subs); err != nil {
+			return err
+		}
+	}
+	success = true
+	return nil
+}
+
+/* The TermsEnum of one of the merged readers, and its current term. */
+type termsEnumSub struct {
+	index   int // of the reader
+	terms   TermsEnum
+	current []byte // nil once exhausted
+}
+
+func (sub *termsEnumSub) next() (err error) {
+	var term []byte
+	if term, err = sub.terms.Next(); err == nil && term != nil {
+		// the enum may reuse its buffer
+		sub.current = append(sub.current[:0], term...)
+	} else {
+		sub.current = nil
+	}
+	return err
+}
+
+// codecs/TermsConsumer.java
+
+/*
+Merges the terms of one field, interleaving the terms enums of the
+readers in term order, and feeding each term's postings to the
+PostingsConsumer.
+*/
+func (m *SegmentMerger) mergeTermsField(termsConsumer TermsConsumer,
+	fi *FieldInfo, subs []*termsEnumSub) (err error) {
+
+	for _, sub := range subs {
+		if err = sub.next(); err != nil {
+			return err
+		}
+	}
+
+	indexOptions := fi.IndexOptions()
+	var sumTotalTermFreq, sumDocFreq, sumDFSinceLastAbortCheck int64
+	visitedDocs := util.NewFixedBitSetOf(m.mergeState.segmentInfo.DocCount())
+	var term []byte
+	for {
+		// find the smallest current term, and the readers having it
+		term = nil
+		var matches []*termsEnumSub
+		for _, sub := range subs {
+			if sub.current == nil {
+				continue
+			}
+			if term == nil || util.UTF8SortedAsUnicodeLess(sub.current, term) {
+				term = sub.current
+				matches = append(matches[:0], sub)
+			} else if !util.UTF8SortedAsUnicodeLess(term, sub.current) {
+				matches = append(matches, sub)
+			}
+		}
+		if term == nil {
+			break
+		}
+		term = append([]byte(nil), term...)
+
+		var postingsConsumer codec.PostingsConsumer
+		if postingsConsumer, err = termsConsumer.StartTerm(term); err != nil {
+			return err
+		}
+		var stats *codec.TermStats
+		if stats, err = m.mergePostings(postingsConsumer, indexOptions, matches, visitedDocs); err != nil {
+			return err
+		}
+		if stats.DocFreq > 0 {
+			if err = termsConsumer.FinishTerm(term, stats); err != nil {
+				return err
+			}
+			if indexOptions == INDEX_OPT_DOCS_ONLY {
+				sumTotalTermFreq += int64(stats.DocFreq)
+			} else {
+				sumTotalTermFreq += stats.TotalTermFreq
+			}
+			sumDocFreq += int64(stats.DocFreq)
+			if sumDFSinceLastAbortCheck += int64(stats.DocFreq); sumDFSinceLastAbortCheck > 60000 {
+				if err = m.mergeState.checkAbort.Work(float64(sumDFSinceLastAbortCheck) / 5); err != nil {
+					return err
+				}
+				sumDFSinceLastAbortCheck = 0
+			}
+		}
+
+		for _, sub := range matches {
+			if err = sub.next(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if indexOptions == INDEX_OPT_DOCS_ONLY {
+		sumTotalTermFreq = -1
+	}
+	return termsConsumer.Finish(sumTotalTermFreq, sumDocFreq, visitedDocs.Cardinality())
+}
+
+// codecs/PostingsConsumer.java
+
+/*
+Feeds the postings of the current term of each of subs, in reader
+order, to postingsConsumer, mapping their docIDs to the merged
+segment's.
+*/
+func (m *SegmentMerger) mergePostings(postingsConsumer codec.PostingsConsumer,
+	indexOptions IndexOptions, subs []*termsEnumSub,
+	visitedDocs *util.FixedBitSet) (stats *codec.TermStats, err error) {
+
+	df := 0
+	var totTF int64
+	for _, sub := range subs {
+		liveDocs := m.mergeState.readers[sub.index].LiveDocs()
+		var postings DocsEnum
+		var postingsAndPositions DocsAndPositionsEnum
+		switch {
+		case indexOptions == INDEX_OPT_DOCS_ONLY:
+			postings, err = sub.terms.DocsByFlags(liveDocs, nil, 0)
+		case indexOptions == INDEX_OPT_DOCS_AND_FREQS:
+			postings, err = sub.terms.DocsByFlags(liveDocs, nil, DOCS_ENUM_FLAG_FREQS)
+		default:
+			flags := 0
+			if indexOptions == INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS {
+				flags = DOCS_POSITIONS_ENUM_FLAG_OFF_SETS
+			}
+			postingsAndPositions, err = sub.terms.DocsAndPositionsByFlags(liveDocs, nil, flags)
+			postings = postingsAndPositions
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			var doc int
+			if doc, err = postings.NextDoc(); err != nil {
+				return nil, err
+			}
+			if doc == NO_MORE_DOCS {
+				break
+			}
+			doc = m.mergeState.mapDoc(sub.index, doc)
+			visitedDocs.Set(doc)
+
+			freq := -1
+			if indexOptions != INDEX_OPT_DOCS_ONLY {
+				if freq, err = postings.Freq(); err != nil {
+					return nil, err
+				}
+				totTF += int64(freq)
+			}
+			if err = postingsConsumer.StartDoc(doc, freq); err != nil {
+				return nil, err
+			}
+			if postingsAndPositions != nil {
+				for i := 0; i < freq; i++ {
+					var position int
+					if position, err = postingsAndPositions.NextPosition(); err != nil {
+						return nil, err
+					}
+					var payload []byte
+					if payload, err = postingsAndPositions.Payload(); err != nil {
+						return nil, err
+					}
+					startOffset, endOffset := -1, -1
+					if indexOptions == INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS {
+						startOffset, endOffset = postingsAndPositions.StartOffset(), postingsAndPositions.EndOffset()
+					}
+					if err = postingsConsumer.AddPosition(position, payload, startOffset, endOffset); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if err = postingsConsumer.FinishDoc(); err != nil {
+				return nil, err
+			}
+			df++
+		}
+	}
+	if indexOptions == INDEX_OPT_DOCS_ONLY {
+		totTF = -1
+	}
+	return codec.NewTermStats(df, totTF), nil
+}
+
+func (m *SegmentMerger) mergeDocValues(state *SegmentWriteState) (err error) {
+	var consumer DocValuesConsumer
+	if consumer, err = m.codec.DocValuesFormat().FieldsConsumer(state); err != nil {
+		return err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(consumer)
+		} else {
+			util.CloseWhileSuppressingError(consumer)
+		}
+	}()
+
+	for _, fi := range m.mergeState.fieldInfos.Values {
+		switch fi.DocValuesType() {
+		case DOC_VALUES_TYPE_NUMERIC:
+			toMerge := make([]NumericDocValues, len(m.mergeState.readers))
+			for i, reader := range m.mergeState.readers {
+				if toMerge[i], err = reader.NumericDocValues(fi.Name); err != nil {
+					return err
+				}
+			}
+			err = m.mergeNumericField(consumer, fi, toMerge)
+		case DOC_VALUES_TYPE_SORTED:
+			toMerge := make([]SortedDocValues, len(m.mergeState.readers))
+			for i, reader := range m.mergeState.readers {
+				if toMerge[i], err = reader.SortedDocValues(fi.Name); err != nil {
+					return err
+				}
+			}
+			err = m.mergeSortedField(consumer, fi, toMerge)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	success = true
+	return nil
+}
+
+/* Rebuilds the point trees of each field from the live points of all readers. */
+func (m *SegmentMerger) mergePoints(state *SegmentWriteState) (err error) {
+	format := m.codec.PointsFormat()
+	if format == nil {
+		return errors.New(fmt.Sprintf("codec %v cannot index points: %v",
+			m.codec.Name(), m.mergeState.segmentInfo.Name))
+	}
+	var writer PointsWriter
+	if writer, err = format.FieldsWriter(state); err != nil {
+		return err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(writer)
+		} else {
+			util.CloseWhileSuppressingError(writer)
+		}
+	}()
+
+	for _, fi := range m.mergeState.fieldInfos.Values {
+		if fi.PointDimensionCount() == 0 {
+			continue
+		}
+		if err = writer.WriteField(fi, func(visit func(docID int, value int64) error) error {
+			for i, reader := range m.mergeState.readers {
+				values, err := reader.PointValues(fi.Name)
+				if err != nil {
+					return err
+				}
+				if values == nil {
+					continue // no points for this field in this segment
+				}
+				if err = values.VisitAll(func(docID int, value int64) error {
+					if docID = m.mergeState.mapDoc(i, docID); docID == -1 {
+						return nil // deleted
+					}
+					return visit(docID, value)
+				}); err != nil {
+					return err
+				}
+				if err = m.mergeState.checkAbort.Work(float64(values.Size()) / 5); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	success = true
+	return nil
+}
+
+func (m *SegmentMerger) mergeNorms(state *SegmentWriteState) (err error) {
+	var consumer DocValuesConsumer
+	if consumer, err = m.codec.NormsFormat().NormsConsumer(state); err != nil {
+		return err
+	}
+	var success = false
+	defer func() {
+		if success {
+			err = util.Close(consumer)
+		} else {
+			util.CloseWhileSuppressingError(consumer)
+		}
+	}()
+
+	for _, fi := range m.mergeState.fieldInfos.Values {
+		if !fi.HasNorms() {
+			continue
+		}
+		toMerge := make([]NumericDocValues, len(m.mergeState.readers))
+		for i, reader := range m.mergeState.readers {
+			var norms NumericDocValues
+			if norms, err = reader.NormValues(fi.Name); err != nil {
+				return err
+			}
+			if norms == nil {
+				// every doc has a norm, the empty one for readers without
+				norms = func(docID int) int64 { return 0 }
+			}
+			toMerge[i] = norms
+		}
+		if err = m.mergeNumericField(consumer, fi, toMerge); err != nil {
+			return err
+		}
+	}
+	success = true
+	return nil
+}
+
+// codecs/DocValuesConsumer.java
+
+/*
+Merges the numeric doc values of a field, skipping deleted docs. Docs
+of readers without values for the field are missing.
+*/
+func (m *SegmentMerger) mergeNumericField(consumer DocValuesConsumer,
+	fi *FieldInfo, toMerge []NumericDocValues) error {
+
+	return consumer.AddNumericField(fi, func() func() (interface{}, bool) {
+		readerUpto, docIDUpto := 0, 0
+		return func() (interface{}, bool) {
+			for readerUpto < len(toMerge) {
+				reader := m.mergeState.readers[readerUpto]
+				if docIDUpto == reader.MaxDoc() {
+					readerUpto++
+					docIDUpto = 0
+					continue
+				}
+				docID := docIDUpto
+				docIDUpto++
+				if liveDocs := reader.LiveDocs(); liveDocs != nil && !liveDocs.At(docID) {
+					continue
+				}
+				if values := toMerge[readerUpto]; values != nil {
+					return values(docID), true
+				}
+				return nil, true
+			}
+			return nil, false
+		}
+	})
+}
+
+/*
+Merges the sorted doc values of a field: the values still in use by
+live docs are merged into a single sorted set, and the docs of each
+reader are mapped to the ords of their values in it.
+*/
+func (m *SegmentMerger) mergeSortedField(consumer DocValuesConsumer,
+	fi *FieldInfo, toMerge []SortedDocValues) error {
+
+	// step 1: mark the values still in use by each reader
+	var values [][]byte
+	segOrds := make([][]int, len(toMerge)) // segment ord -> merged ord, -1 if unused
+	for i, dv := range toMerge {
+		if dv == nil {
+			continue
+		}
+		segOrds[i] = make([]int, dv.ValueCount())
+		liveDocs := m.mergeState.readers[i].LiveDocs()
+		if liveDocs == nil {
+			for ord := range segOrds[i] {
+				segOrds[i][ord] = 1
+			}
+		} else {
+			for docID, maxDoc := 0, m.mergeState.readers[i].MaxDoc(); docID < maxDoc; docID++ {
+				if ord := dv.Ord(docID); ord >= 0 && liveDocs.At(docID) {
+					segOrds[i][ord] = 1
+				}
+			}
+		}
+		for ord, used := range segOrds[i] {
+			if used == 1 {
+				values = append(values, append([]byte(nil), dv.LookupOrd(ord)...))
+			}
+		}
+	}
+
+	// step 2: create the ordinal map (this conceptually does the
+	// "merging")
+	sort.Sort(bytesSlice(values))
+	unique := values[:0]
+	for i, v := range values {
+		if i == 0 || util.UTF8SortedAsUnicodeLess(unique[len(unique)-1], v) {
+			unique = append(unique, v)
+		}
+	}
+	values = unique
+	for i, dv := range toMerge {
+		for ord, used := range segOrds[i] {
+			if used == 1 {
+				segOrds[i][ord] = sort.Search(len(values), func(j int) bool {
+					return !util.UTF8SortedAsUnicodeLess(values[j], dv.LookupOrd(ord))
+				})
+			} else {
+				segOrds[i][ord] = -1
+			}
+		}
+	}
+
+	// step 3: add field
+	return consumer.AddSortedField(fi,
+		// ord -> value
+		func() func() ([]byte, bool) {
+			ord := 0
+			return func() ([]byte, bool) {
+				if ord == len(values) {
+					return nil, false
+				}
+				ord++
+				return values[ord-1], true
+			}
+		},
+		// doc -> ord
+		func() func() (interface{}, bool) {
+			readerUpto, docIDUpto := 0, 0
+			return func() (interface{}, bool) {
+				for readerUpto < len(toMerge) {
+					reader := m.mergeState.readers[readerUpto]
+					if docIDUpto == reader.MaxDoc() {
+						readerUpto++
+						docIDUpto = 0
+						continue
+					}
+					docID := docIDUpto
+					docIDUpto++
+					if liveDocs := reader.LiveDocs(); liveDocs != nil && !liveDocs.At(docID) {
+						continue
+					}
+					if dv := toMerge[readerUpto]; dv != nil {
+						if segOrd := dv.Ord(docID); segOrd != -1 {
+							return int64(segOrds[readerUpto][segOrd]), true
+						}
+					}
+					return int64(-1), true
+				}
+				return nil, false
+			}
+		})
+}
+
+type bytesSlice [][]byte
+
+func (a bytesSlice) Len() int           { return len(a) }
+func (a bytesSlice) Less(i, j int) bool { return util.UTF8SortedAsUnicodeLess(a[i], a[j]) }
+func (a bytesSlice) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
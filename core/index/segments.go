@@ -11,6 +11,7 @@ import (
 	. "github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
+	"io"
 	"sync/atomic"
 )
 
@@ -72,39 +73,20 @@ func NewSegmentReader(si *SegmentCommitInfo,
 		}
 	}()
 
-	codec := si.Info.Codec().(Codec)
 	if si.HasDeletions() {
-		panic("not supported yet")
+		// NOTE: the bitvector is stored using the regular directory, not cfs
+		if r.liveDocs, err = si.Info.Codec().(Codec).LiveDocsFormat().ReadLiveDocs(
+			si.Info.Dir, si, store.IO_CONTEXT_READONCE); err != nil {
+			return nil, err
+		}
 	} else {
 		assert(si.DelCount() == 0)
 	}
 	r.numDocs = si.Info.DocCount() - si.DelCount()
-
-	if r.fieldInfos.HasDocValues {
-		r.initDocValuesProducers(codec)
-	}
 	success = true
 	return r, nil
 }
 
-/* initialize the per-field DocValuesProducer */
-func (r *SegmentReader) initDocValuesProducers(codec Codec) error {
-	// var dir store.Directory
-	// if r.core.cfsReader != nil {
-	// 	dir = r.core.cfsReader
-	// } else {
-	// 	dir = r.si.Info.Dir
-	// }
-	// dvFormat := codec.DocValuesFormat()
-
-	// termsIndexDivisor := r.core.termsIndexDivisor
-	if !r.si.HasFieldUpdates() {
-		panic("not implemented yet")
-	}
-
-	panic("not implemented yet")
-}
-
 /* Reads the most recent FieldInfos of the given segment info. */
 func ReadFieldInfos(info *SegmentCommitInfo) (fis FieldInfos, err error) {
 	var dir store.Directory
@@ -144,7 +126,6 @@ func (r *SegmentReader) LiveDocs() util.Bits {
 }
 
 func (r *SegmentReader) doClose() error {
-	panic("not implemented yet")
 	r.core.decRef()
 	return nil
 }
@@ -230,7 +211,22 @@ func (r *SegmentReader) TermInfosIndexDivisor() int {
 
 func (r *SegmentReader) NumericDocValues(field string) (v NumericDocValues, err error) {
 	r.ensureOpen()
-	panic("not implemented yet")
+	fi := r.fieldInfos.FieldInfoByName(field)
+	if fi == nil || fi.DocValuesType() != DOC_VALUES_TYPE_NUMERIC {
+		// Field does not exist or does not index numeric doc values
+		return nil, nil
+	}
+	return r.core.dvProducer.Numeric(fi)
+}
+
+func (r *SegmentReader) PointValues(field string) (v PointValues, err error) {
+	r.ensureOpen()
+	fi := r.fieldInfos.FieldInfoByName(field)
+	if fi == nil || fi.PointDimensionCount() == 0 {
+		// Field does not exist or does not index points
+		return nil, nil
+	}
+	return r.core.pointsReader.PointValues(fi)
 }
 
 func (r *SegmentReader) BinaryDocValues(field string) (v BinaryDocValues, err error) {
@@ -240,7 +236,12 @@ func (r *SegmentReader) BinaryDocValues(field string) (v BinaryDocValues, err er
 
 func (r *SegmentReader) SortedDocValues(field string) (v SortedDocValues, err error) {
 	r.ensureOpen()
-	panic("not implemented yet")
+	fi := r.fieldInfos.FieldInfoByName(field)
+	if fi == nil || fi.DocValuesType() != DOC_VALUES_TYPE_SORTED {
+		// Field does not exist or does not index sorted doc values
+		return nil, nil
+	}
+	return r.core.dvProducer.Sorted(fi)
 }
 
 func (r *SegmentReader) SortedSetDocValues(field string) (v SortedSetDocValues, err error) {
@@ -253,8 +254,28 @@ func (r *SegmentReader) NormValues(field string) (v NumericDocValues, err error)
 	return r.core.normValues(r.fieldInfos, field)
 }
 
+/*
+Called when the shared core for this SegmentReader is closed.
+
+This listener is called only once all SegmentReaders sharing the same
+core are closed. At this point it is safe for apps to evict this
+reader from any caches keyed on CoreCacheKey(). This is the same
+interface that FieldCache uses, internally, to evict entries.
+*/
 type CoreClosedListener interface {
-	onClose(r interface{})
+	OnClose(ownerCoreCacheKey interface{})
+}
+
+/* Expert: adds a CoreClosedListener to this reader's shared core */
+func (r *SegmentReader) AddCoreClosedListener(listener CoreClosedListener) {
+	r.ensureOpen()
+	r.core.addListener <- listener
+}
+
+/* Expert: removes a CoreClosedListener from this reader's shared core */
+func (r *SegmentReader) RemoveCoreClosedListener(listener CoreClosedListener) {
+	r.ensureOpen()
+	r.core.removeListener <- listener
 }
 
 // index/SegmentCoreReaders.java
@@ -264,6 +285,8 @@ type SegmentCoreReaders struct {
 
 	fields        FieldsProducer
 	normsProducer DocValuesProducer
+	dvProducer    DocValuesProducer
+	pointsReader  PointsReader
 
 	termsIndexDivisor int
 
@@ -331,21 +354,22 @@ func newSegmentCoreReaders(owner *SegmentReader, dir store.Directory, si *Segmen
 					}
 				}
 			case <-self.notifyListener:
-				fmt.Println("Shutting down SegmentCoreReaders...")
+				// fmt.Println("Shutting down SegmentCoreReaders...")
 				isRunning = false
 				for _, v := range coreClosedListeners {
-					v.onClose(self)
+					v.OnClose(self)
 				}
+				self.notifyListener <- true // listeners are notified
 			}
 		}
-		fmt.Println("Listeners are done.")
+		// fmt.Println("Listeners are done.")
 	}()
 
 	var success = false
 	ans := self
 	defer func() {
 		if !success {
-			fmt.Println("Failed to initialize SegmentCoreReaders.")
+			// fmt.Println("Failed to initialize SegmentCoreReaders.")
 			ans.decRef()
 		}
 	}()
@@ -392,6 +416,21 @@ func newSegmentCoreReaders(owner *SegmentReader, dir store.Directory, si *Segmen
 		assert(self.normsProducer != nil)
 	}
 
+	if fieldInfos.HasDocValues {
+		// fmt.Println("Obtaining DocValuesProducer...")
+		if self.dvProducer, err = codec.DocValuesFormat().FieldsProducer(segmentReadState); err != nil {
+			return nil, err
+		}
+		assert(self.dvProducer != nil)
+	}
+
+	if fieldInfos.HasPointValues {
+		if self.pointsReader, err = codec.PointsFormat().FieldsReader(segmentReadState); err != nil {
+			return nil, err
+		}
+		assert(self.pointsReader != nil)
+	}
+
 	// fmt.Println("Obtaining StoredFieldsReader...")
 	if self.fieldsReaderOrig, err = si.Info.Codec().(Codec).StoredFieldsFormat().FieldsReader(cfsDir, si.Info, fieldInfos, context); err != nil {
 		return nil, err
@@ -418,7 +457,7 @@ func (r *SegmentCoreReaders) normValues(infos FieldInfos,
 	} else if fi := infos.FieldInfoByName(field); fi != nil && fi.HasNorms() {
 		assert(r.normsProducer != nil)
 		if ndv, err = r.normsProducer.Numeric(fi); err == nil {
-			r.normsLocal()[field] = norms
+			r.normsLocal()[field] = ndv
 		} // else Field does not exist
 	}
 	return
@@ -426,10 +465,15 @@ func (r *SegmentCoreReaders) normValues(infos FieldInfos,
 
 func (r *SegmentCoreReaders) decRef() {
 	if atomic.AddInt32(&r.refCount, -1) == 0 {
-		fmt.Println("--- closing core readers")
+		// fmt.Println("--- closing core readers")
+		var cfsReader io.Closer
+		if r.cfsReader != nil { // non-compound segment; don't pass a typed nil
+			cfsReader = r.cfsReader
+		}
 		util.Close( /*self.termVectorsLocal, self.fieldsReaderLocal,  r.normsLocal,*/
 			r.fields, r.termVectorsReaderOrig, r.fieldsReaderOrig,
-			r.cfsReader, r.normsProducer)
+			cfsReader, r.normsProducer, r.dvProducer, r.pointsReader)
 		r.notifyListener <- true
+		<-r.notifyListener // wait until listeners are notified
 	}
 }
@@ -0,0 +1,234 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
+	"sync"
+)
+
+// index/SnapshotDeletionPolicy.java
+
+/*
+An IndexDeletionPolicy that wraps any other IndexDeletionPolicy and
+adds the ability to hold and later release snapshots of an index.
+While a snapshot is held, the IndexWriter will not remove any files
+associated with it even if the index is otherwise being actively,
+arbitrarily changed. Because we wrap another arbitrary
+IndexDeletionPolicy, this gives you the freedom to continue using
+whatever IndexDeletionPolicy you would normally want to use with
+your index.
+
+This class maintains all snapshots in-memory, and so the information
+is not persisted and not protected against system failures.
+
+NOTE: Sharing a SnapshotDeletionPolicy across writers is not
+supported; each writer must use its own instance (see Clone()).
+*/
+type SnapshotDeletionPolicy struct {
+	sync.Locker
+	// Records how many snapshots are held against each commit
+	// generation
+	refCounts map[int64]int
+	// Used to map gen to IndexCommit.
+	indexCommits map[int64]IndexCommit
+	// Wrapped IndexDeletionPolicy
+	primary IndexDeletionPolicy
+	// Most recently committed IndexCommit.
+	lastCommit IndexCommit
+	// Used to detect misuse
+	initCalled bool
+}
+
+/* Sole constructor, taking the incoming IndexDeletionPolicy to wrap. */
+func NewSnapshotDeletionPolicy(primary IndexDeletionPolicy) *SnapshotDeletionPolicy {
+	return &SnapshotDeletionPolicy{
+		Locker:       &sync.Mutex{},
+		refCounts:    make(map[int64]int),
+		indexCommits: make(map[int64]IndexCommit),
+		primary:      primary,
+	}
+}
+
+func (p *SnapshotDeletionPolicy) onCommit(commits []IndexCommit) error {
+	p.Lock()
+	defer p.Unlock()
+	if err := p.primary.onCommit(p.wrapCommits(commits)); err != nil {
+		return err
+	}
+	p.lastCommit = commits[len(commits)-1]
+	return nil
+}
+
+func (p *SnapshotDeletionPolicy) onInit(commits []IndexCommit) error {
+	p.Lock()
+	defer p.Unlock()
+	p.initCalled = true
+	if err := p.primary.onInit(p.wrapCommits(commits)); err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		if _, ok := p.refCounts[commit.Generation()]; ok {
+			p.indexCommits[commit.Generation()] = commit
+		}
+	}
+	if len(commits) > 0 {
+		p.lastCommit = commits[len(commits)-1]
+	}
+	return nil
+}
+
+/*
+Release a snapshotted commit.
+*/
+func (p *SnapshotDeletionPolicy) Release(commit IndexCommit) error {
+	p.Lock()
+	defer p.Unlock()
+	return p.releaseGen(commit.Generation())
+}
+
+/*
+Release a snapshot by generation.
+*/
+func (p *SnapshotDeletionPolicy) releaseGen(gen int64) error {
+	if !p.initCalled {
+		return errors.New("this instance is not being used by IndexWriter; be sure to use the instance set on the writer's IndexWriterConfig")
+	}
+	refCount, ok := p.refCounts[gen]
+	if !ok {
+		return errors.New(fmt.Sprintf("commit gen=%v is not currently snapshotted", gen))
+	}
+	assert(refCount > 0)
+	if refCount--; refCount == 0 {
+		delete(p.refCounts, gen)
+		delete(p.indexCommits, gen)
+	} else {
+		p.refCounts[gen] = refCount
+	}
+	return nil
+}
+
+/* Increments the refCount for this IndexCommit. */
+func (p *SnapshotDeletionPolicy) incRef(ic IndexCommit) {
+	gen := ic.Generation()
+	if _, ok := p.refCounts[gen]; !ok {
+		p.indexCommits[gen] = p.lastCommit
+	}
+	p.refCounts[gen]++
+}
+
+/*
+Snapshots the last commit and returns it. Once a commit is
+'snapshotted,' it is protected from deletion (as long as this
+IndexDeletionPolicy is used). The snapshot can be removed by calling
+Release() followed by a call to the writer's Commit().
+
+NOTE: while the snapshot is held, the files it references will not be
+deleted, which will consume additional disk space in your index. If
+you take a snapshot at a particularly bad time (say just before you
+call ForceMerge) then in the worst case this could consume an extra 1X
+of your total index size, until you release the snapshot.
+*/
+func (p *SnapshotDeletionPolicy) Snapshot() (IndexCommit, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.snapshot()
+}
+
+func (p *SnapshotDeletionPolicy) snapshot() (IndexCommit, error) {
+	if !p.initCalled {
+		return nil, errors.New("this instance is not being used by IndexWriter; be sure to use the instance set on the writer's IndexWriterConfig")
+	}
+	if p.lastCommit == nil {
+		// No commit yet, eg this is a new IndexWriter:
+		return nil, errors.New("No index commit to snapshot")
+	}
+	p.incRef(p.lastCommit)
+	return p.lastCommit, nil
+}
+
+/* Returns all IndexCommits held by at least one snapshot. */
+func (p *SnapshotDeletionPolicy) Snapshots() []IndexCommit {
+	p.Lock()
+	defer p.Unlock()
+	ans := make([]IndexCommit, 0, len(p.indexCommits))
+	for _, commit := range p.indexCommits {
+		ans = append(ans, commit)
+	}
+	return ans
+}
+
+/* Returns the total number of snapshots currently held. */
+func (p *SnapshotDeletionPolicy) SnapshotCount() int {
+	p.Lock()
+	defer p.Unlock()
+	total := 0
+	for _, refCount := range p.refCounts {
+		total += refCount
+	}
+	return total
+}
+
+/*
+Retrieve an IndexCommit from its generation; returns nil if this
+IndexCommit is not currently snapshotted.
+*/
+func (p *SnapshotDeletionPolicy) IndexCommit(gen int64) IndexCommit {
+	p.Lock()
+	defer p.Unlock()
+	return p.indexCommits[gen]
+}
+
+func (p *SnapshotDeletionPolicy) Clone() IndexDeletionPolicy {
+	p.Lock()
+	defer p.Unlock()
+	other := NewSnapshotDeletionPolicy(p.primary.Clone())
+	for gen, refCount := range p.refCounts {
+		other.refCounts[gen] = refCount
+	}
+	for gen, commit := range p.indexCommits {
+		other.indexCommits[gen] = commit
+	}
+	return other
+}
+
+/* Wraps each IndexCommit as a snapshotCommitPoint. */
+func (p *SnapshotDeletionPolicy) wrapCommits(commits []IndexCommit) []IndexCommit {
+	wrappedCommits := make([]IndexCommit, len(commits))
+	for i, ic := range commits {
+		wrappedCommits[i] = &snapshotCommitPoint{p, ic}
+	}
+	return wrappedCommits
+}
+
+/* Wraps a provided IndexCommit and prevents it from being deleted. */
+type snapshotCommitPoint struct {
+	owner *SnapshotDeletionPolicy
+	// The IndexCommit we are preventing from deletion.
+	cp IndexCommit
+}
+
+func (scp *snapshotCommitPoint) String() string {
+	return fmt.Sprintf("SnapshotDeletionPolicy.SnapshotCommitPoint(%v)", scp.cp)
+}
+
+/*
+Delete is only forwarded to the wrapped commit when it's not
+snapshotted. Called by the primary policy from within onInit() or
+onCommit(), so the owner is already locked.
+*/
+func (scp *snapshotCommitPoint) Delete() {
+	// Suppress the delete request if this commit point is currently
+	// snapshotted.
+	if _, ok := scp.owner.refCounts[scp.cp.Generation()]; !ok {
+		scp.cp.Delete()
+	}
+}
+
+func (scp *snapshotCommitPoint) SegmentsFileName() string    { return scp.cp.SegmentsFileName() }
+func (scp *snapshotCommitPoint) FileNames() []string         { return scp.cp.FileNames() }
+func (scp *snapshotCommitPoint) Directory() store.Directory  { return scp.cp.Directory() }
+func (scp *snapshotCommitPoint) IsDeleted() bool             { return scp.cp.IsDeleted() }
+func (scp *snapshotCommitPoint) SegmentCount() int           { return scp.cp.SegmentCount() }
+func (scp *snapshotCommitPoint) Generation() int64           { return scp.cp.Generation() }
+func (scp *snapshotCommitPoint) UserData() map[string]string { return scp.cp.UserData() }
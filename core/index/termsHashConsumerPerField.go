@@ -282,7 +282,13 @@ func (w *FreqProxTermsWriterPerField) writeProx(termId, proxCode int) {
 }
 
 func (w *FreqProxTermsWriterPerField) writeOffsets(termId, offsetAccum int) {
-	panic("not implemented yet")
+	startOffset := offsetAccum + w.offsetAttribute.StartOffset()
+	endOffset := offsetAccum + w.offsetAttribute.EndOffset()
+	postings := w.freqProxPostingsArray
+	assert(startOffset-postings.lastOffsets[termId] >= 0)
+	w.writeVInt(1, startOffset-postings.lastOffsets[termId])
+	w.writeVInt(1, endOffset-startOffset)
+	postings.lastOffsets[termId] = startOffset
 }
 
 func (w *FreqProxTermsWriterPerField) newTerm(termId int) {
@@ -322,7 +328,15 @@ func (w *FreqProxTermsWriterPerField) addTerm(termId int) {
 	assert(!w.hasFreq || postings.termFreqs[termId] > 0)
 
 	if !w.hasFreq {
-		panic("not implemented yet")
+		assert(postings.termFreqs == nil)
+		if w.docState.docID != postings.lastDocIDs[termId] {
+			// New document; now encode docCode for previous doc:
+			assert(w.docState.docID > postings.lastDocIDs[termId])
+			w.writeVInt(0, postings.lastDocCodes[termId])
+			postings.lastDocCodes[termId] = w.docState.docID - postings.lastDocIDs[termId]
+			postings.lastDocIDs[termId] = w.docState.docID
+			w.fieldState.uniqueTermCount++
+		}
 	} else if w.docState.docID != postings.lastDocIDs[termId] {
 		assert2(w.docState.docID > postings.lastDocIDs[termId],
 			"id: %v postings ID: %v termID: %v",
@@ -348,7 +362,8 @@ func (w *FreqProxTermsWriterPerField) addTerm(termId int) {
 		if w.hasProx {
 			w.writeProx(termId, w.fieldState.position)
 			if w.hasOffsets {
-				panic("niy")
+				postings.lastOffsets[termId] = 0
+				w.writeOffsets(termId, w.fieldState.offset)
 			}
 		} else {
 			assert(!w.hasOffsets)
@@ -623,7 +638,7 @@ func (w *FreqProxTermsWriterPerField) flush(fieldName string,
 			if readPositions || readOffsets {
 				// we did record positions (& maybe payload) and/or offsets
 				position := 0
-				// offset := 0
+				offset := 0
 				for j := 0; j < termFreq; j++ {
 					var thisPayload []byte
 
@@ -639,7 +654,27 @@ func (w *FreqProxTermsWriterPerField) flush(fieldName string,
 						}
 
 						if readOffsets {
-							panic("not implemented yet")
+							n, err := prox.ReadVInt()
+							if err != nil {
+								return err
+							}
+							startOffset := offset + int(n)
+							if n, err = prox.ReadVInt(); err != nil {
+								return err
+							}
+							endOffset := startOffset + int(n)
+							if writePositions {
+								if writeOffsets {
+									assert(startOffset >= 0 && endOffset >= startOffset)
+									err = postingsConsumer.AddPosition(position, thisPayload, startOffset, endOffset)
+								} else {
+									err = postingsConsumer.AddPosition(position, thisPayload, -1, -1)
+								}
+								if err != nil {
+									return err
+								}
+							}
+							offset = startOffset
 						} else if writePositions {
 							err = postingsConsumer.AddPosition(position, thisPayload, -1, -1)
 							if err != nil {
@@ -0,0 +1,125 @@
+package index
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// index/UpgradeIndexMergePolicy.java
+
+/*
+This MergePolicy is used for upgrading all existing segments of an
+index when calling IndexWriter.forceMerge(). All other methods
+delegate to the base MergePolicy given to the constructor. This
+allows for an as-cheap-as possible upgrade of an older index by only
+upgrading segments that are created by previous Lucene versions.
+forceMerge does no longer really merge; it is just used to "forceMerge"
+older segment versions away.
+
+For a fully customizeable upgrade, you can use this like any other
+MergePolicy, wrapping the writer's current one, and force merge.
+
+WARNING: This merge policy may reorder documents if the index was
+partially upgraded before calling forceMerge (e.g., documents were
+added). If your application relies on "monotonicity" of doc IDs
+(which means that the order in which the documents were added to the
+index is preserved), do a forceMerge(1) instead. Please note, the
+delegate MergePolicy may also reorder documents.
+*/
+type UpgradeIndexMergePolicy struct {
+	base MergePolicy
+	// Returns true if the given segment should be upgraded. The
+	// default implementation returns true for all segments written by
+	// a version other than util.VERSION_LATEST. Override it to e.g.
+	// also upgrade segments of the current version.
+	ShouldUpgradeSegment func(info *SegmentCommitInfo) bool
+}
+
+/* Wrap the given MergePolicy and intercept forceMerge requests to only upgrade segments written with previous Lucene versions. */
+func NewUpgradeIndexMergePolicy(base MergePolicy) *UpgradeIndexMergePolicy {
+	return &UpgradeIndexMergePolicy{
+		base: base,
+		ShouldUpgradeSegment: func(info *SegmentCommitInfo) bool {
+			return !util.VERSION_LATEST.Equals(info.Info.Version())
+		},
+	}
+}
+
+func (mp *UpgradeIndexMergePolicy) SetNoCFSRatio(noCFSRatio float64) {
+	mp.base.SetNoCFSRatio(noCFSRatio)
+}
+
+func (mp *UpgradeIndexMergePolicy) SetMaxCFSSegmentSizeMB(v float64) {
+	mp.base.SetMaxCFSSegmentSizeMB(v)
+}
+
+func (mp *UpgradeIndexMergePolicy) UseCompoundFile(infos *SegmentInfos,
+	mergedInfo *SegmentCommitInfo, w *IndexWriter) (bool, error) {
+	return mp.base.UseCompoundFile(infos, mergedInfo, w)
+}
+
+func (mp *UpgradeIndexMergePolicy) FindMerges(trigger MergeTrigger,
+	infos *SegmentInfos, w *IndexWriter) (MergeSpecification, error) {
+	return mp.base.FindMerges(trigger, infos, w)
+}
+
+func (mp *UpgradeIndexMergePolicy) FindForcedMerges(infos *SegmentInfos,
+	maxSegmentCount int, segmentsToMerge map[*SegmentCommitInfo]bool,
+	w *IndexWriter) (MergeSpecification, error) {
+
+	// first find all old segments
+	oldSegments := make(map[*SegmentCommitInfo]bool)
+	for _, info := range infos.Segments {
+		if v, ok := segmentsToMerge[info]; ok && mp.ShouldUpgradeSegment(info) {
+			oldSegments[info] = v
+		}
+	}
+
+	mp.message(w, "findForcedMerges: segmentsToUpgrade=%v", len(oldSegments))
+
+	if len(oldSegments) == 0 {
+		return nil, nil
+	}
+
+	spec, err := mp.base.FindForcedMerges(infos, maxSegmentCount, oldSegments, w)
+	if err != nil {
+		return nil, err
+	}
+
+	// remove all segments that are in merge specification from
+	// oldSegments, the resulting set contains all segments that are
+	// left over and will be merged to one additional segment:
+	for _, merge := range spec {
+		for _, info := range merge.segments {
+			delete(oldSegments, info)
+		}
+	}
+
+	if len(oldSegments) > 0 {
+		mp.message(w, "findForcedMerges: %v does not want to merge all old segments, merge remaining ones into new segment: %v",
+			mp.base, len(oldSegments))
+		var newInfos []*SegmentCommitInfo
+		for _, info := range infos.Segments {
+			if _, ok := oldSegments[info]; ok {
+				newInfos = append(newInfos, info)
+			}
+		}
+		spec = append(spec, NewOneMerge(newInfos))
+	}
+	return spec, nil
+}
+
+func (mp *UpgradeIndexMergePolicy) String() string {
+	return fmt.Sprintf("[UpgradeIndexMergePolicy->%v]", mp.base)
+}
+
+func (mp *UpgradeIndexMergePolicy) verbose(w *IndexWriter) bool {
+	return w != nil && w.infoStream.IsEnabled("UPGMP")
+}
+
+func (mp *UpgradeIndexMergePolicy) message(w *IndexWriter, message string, args ...interface{}) {
+	if mp.verbose(w) {
+		w.infoStream.Message("UPGMP", message, args...)
+	}
+}
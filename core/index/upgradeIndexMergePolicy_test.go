@@ -0,0 +1,107 @@
+package index
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/index/model"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"testing"
+)
+
+// Forced merges the first mergeSize of the segments it's asked to.
+type recordingMergePolicy struct {
+	MergePolicy
+	mergeSize int
+	asked     []string
+}
+
+func (mp *recordingMergePolicy) FindForcedMerges(infos *SegmentInfos, maxSegmentCount int,
+	segmentsToMerge map[*SegmentCommitInfo]bool, w *IndexWriter) (MergeSpecification, error) {
+
+	var candidates []*SegmentCommitInfo
+	for _, info := range infos.Segments {
+		if segmentsToMerge[info] {
+			candidates = append(candidates, info)
+			mp.asked = append(mp.asked, info.Info.Name)
+		}
+	}
+	if mp.mergeSize == 0 || len(candidates) < mp.mergeSize {
+		return nil, nil
+	}
+	return MergeSpecification{NewOneMerge(candidates[:mp.mergeSize])}, nil
+}
+
+func TestUpgradeIndexMergePolicy(t *testing.T) {
+	d := store.NewRAMDirectory()
+	sis := &SegmentInfos{}
+	segmentsToMerge := make(map[*SegmentCommitInfo]bool)
+	for i, version := range []util.Version{
+		util.VERSION_45, util.VERSION_45, util.VERSION_LATEST, util.VERSION_49, util.VERSION_LATEST,
+	} {
+		si := model.NewSegmentInfo(d, version, fmt.Sprintf("_%v", i), 10, false, noDeletesCodec{}, nil)
+		info := NewSegmentCommitInfo(si, 0, -1, -1, -1)
+		sis.Segments = append(sis.Segments, info)
+		segmentsToMerge[info] = true
+	}
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+
+	forceMerge := func(mp *UpgradeIndexMergePolicy) []string {
+		spec, err := mp.FindForcedMerges(sis, 1, segmentsToMerge, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mergeNames(spec)
+	}
+
+	// old segments left over by the delegate are merged together
+	base := &recordingMergePolicy{mergeSize: 2}
+	assertMerges(t, []string{"[_0 _1]", "[_3]"}, forceMerge(NewUpgradeIndexMergePolicy(base)))
+	assertEquals(t, "[_0 _1 _3]", fmt.Sprintf("%v", base.asked))
+
+	base = &recordingMergePolicy{}
+	assertMerges(t, []string{"[_0 _1 _3]"}, forceMerge(NewUpgradeIndexMergePolicy(base)))
+
+	// up-to-date segments are never handed to the delegate
+	base = &recordingMergePolicy{mergeSize: 2}
+	mp := NewUpgradeIndexMergePolicy(base)
+	mp.ShouldUpgradeSegment = func(info *SegmentCommitInfo) bool { return false }
+	assertMerges(t, nil, forceMerge(mp))
+	assertEquals(t, 0, len(base.asked))
+
+	base = &recordingMergePolicy{}
+	mp = NewUpgradeIndexMergePolicy(base)
+	mp.ShouldUpgradeSegment = func(info *SegmentCommitInfo) bool { return true }
+	assertMerges(t, []string{"[_0 _1 _2 _3 _4]"}, forceMerge(mp))
+}
+
+func TestUpgradeIndexMergePolicyOverTiered(t *testing.T) {
+	d := store.NewRAMDirectory()
+	w := &IndexWriter{directory: d, infoStream: util.NO_OUTPUT}
+	w.readerPool = newReaderPool(w)
+	w.MergeControl = newMergeControl(w.infoStream, w.readerPool)
+	sis := &SegmentInfos{}
+	segmentsToMerge := make(map[*SegmentCommitInfo]bool)
+	for i, version := range []util.Version{
+		util.VERSION_45, util.VERSION_45, util.VERSION_LATEST, util.VERSION_49, util.VERSION_LATEST,
+	} {
+		info := addSyntheticSegment(t, sis, d, 10, 1024)
+		info.Info = model.NewSegmentInfo(d, version, fmt.Sprintf("_%v", i), 10, false, noDeletesCodec{}, nil)
+		info.Info.SetFiles(map[string]bool{fmt.Sprintf("_%v.dat", i): true})
+		segmentsToMerge[info] = true
+	}
+
+	forceMerge := func(base *TieredMergePolicy) []string {
+		spec, err := NewUpgradeIndexMergePolicy(base).FindForcedMerges(sis, 1, segmentsToMerge, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mergeNames(spec)
+	}
+
+	assertMerges(t, []string{"[_0 _1 _3]"}, forceMerge(NewTieredMergePolicy()))
+
+	// the old segment left over by the delegate gets a merge of its own
+	assertMerges(t, []string{"[_1 _3]", "[_0]"},
+		forceMerge(NewTieredMergePolicy().SetMaxMergeAtOnceExplicit(2)))
+}
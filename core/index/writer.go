@@ -90,12 +90,17 @@ type ClosingControl struct {
 }
 
 func newClosingControl() *ClosingControl {
-	ans := &ClosingControl{
+	return &ClosingControl{
 		closer: make(chan func() (bool, error)),
 		done:   make(chan error),
 	}
-	go ans.daemon()
-	return ans
+}
+
+// Starts the daemon serving close requests. Only called once the
+// IndexWriter is fully constructed, so that a failed construction
+// doesn't leak it.
+func (cc *ClosingControl) start() {
+	go cc.daemon()
 }
 
 func (cc *ClosingControl) daemon() {
@@ -137,7 +142,10 @@ index. If you try to add more than this, you'll hit panic.
 */
 const MAX_DOCS = math.MaxInt32 - 128
 
-/* test only */
+/*
+The document limit actually enforced. Always MAX_DOCS, except when
+tests lower it through setMaxDocs in writer_test.go.
+*/
 var actualMaxDocs = MAX_DOCS
 
 const UNBOUNDED_MAX_MERGE_SEGMENTS = -1
@@ -148,6 +156,9 @@ const WRITE_LOCK_NAME = "write.lock"
 /* Source of a segment which results from a flush. */
 const SOURCE_FLUSH = "flush"
 
+// Source of a segment which results from a merge of other segments.
+const SOURCE_MERGE = "merge"
+
 /*
 Absolute hard maximum length for a term, in bytes once encoded as
 UTF8. If a term arrives from the analyzer longer than this length,
@@ -208,12 +219,21 @@ type IndexWriter struct {
 	// used by forceMerge to note those needing merging
 	segmentsToMerge map[*SegmentCommitInfo]bool
 
+	// segments being written by flushes and merges; their files are
+	// not referenced by the deleter until the segment is checkpointed
+	segmentsInFlight map[string]bool
+
 	writeLock store.Lock
 
 	mergeScheduler  MergeScheduler
 	mergeExceptions []*OneMerge
 	didMessageState bool
 
+	// called after each successful commit; may be nil
+	commitHook CommitHook
+	// write a checksum manifest after each successful commit
+	writeChecksumManifest bool
+
 	flushCount        int32 // atomic
 	flushDeletesCount int32 // atomic
 
@@ -293,20 +313,24 @@ func NewIndexWriter(d store.Directory, conf *IndexWriterConfig) (w *IndexWriter,
 		Locker:         &sync.Mutex{},
 		ClosingControl: newClosingControl(),
 
-		segmentsToMerge: make(map[*SegmentCommitInfo]bool),
-		mergeExceptions: make([]*OneMerge, 0),
-		doAfterFlush:    func() error { return nil },
-		doBeforeFlush:   func() error { return nil },
-		commitLock:      &sync.Mutex{},
-		fullFlushLock:   &sync.Mutex{},
+		segmentsToMerge:  make(map[*SegmentCommitInfo]bool),
+		segmentsInFlight: make(map[string]bool),
+		mergeExceptions:  make([]*OneMerge, 0),
+		doAfterFlush:     func() error { return nil },
+		doBeforeFlush:    func() error { return nil },
+		commitLock:       &sync.Mutex{},
+		fullFlushLock:    &sync.Mutex{},
 
 		config:         conf,
 		directory:      d,
 		analyzer:       conf.analyzer,
 		infoStream:     conf.infoStream,
 		mergeScheduler: conf.mergeScheduler,
+		commitHook:     conf.commitHook,
 		codec:          conf.codec,
 
+		writeChecksumManifest: conf.writeChecksumManifest,
+
 		bufferedUpdatesStream: newBufferedUpdatesStream(conf.infoStream),
 		poolReaders:           conf.readerPooling,
 
@@ -317,14 +341,24 @@ func NewIndexWriter(d store.Directory, conf *IndexWriterConfig) (w *IndexWriter,
 	ans.readerPool = newReaderPool(ans)
 	ans.MergeControl = newMergeControl(conf.infoStream, ans.readerPool)
 
-	conf.setIndexWriter(ans)
+	if err = conf.setIndexWriter(ans); err != nil {
+		return nil, err
+	}
+	conf.initMergeScheduler(d)
+
+	// NoLockFactory hands out a lock that always succeeds, so nothing
+	// stops a second writer from opening the same index.
+	if _, ok := d.LockFactory().(*store.NoLockFactory); ok {
+		log.Printf("WARNING: %v uses NoLockFactory; concurrent writers may corrupt the index", d)
+	}
 
 	// obtain write lock
 	if ok, err := ans.writeLock.ObtainWithin(conf.writeLockTimeout); !ok || err != nil {
 		if err != nil {
 			return nil, err
 		}
-		return nil, errors.New(fmt.Sprintf("Index locked for write: %v", ans.writeLock))
+		return nil, store.NewLockObtainFailedError(fmt.Sprintf("Index locked for write: %v", ans.writeLock),
+			ans.writeLock.FailureReason())
 	}
 
 	var success bool = false
@@ -426,6 +460,7 @@ func NewIndexWriter(d store.Directory, conf *IndexWriterConfig) (w *IndexWriter,
 		ans.messageState()
 	}
 
+	ans.ClosingControl.start()
 	success = true
 	return ans, nil
 }
@@ -500,28 +535,33 @@ the same time that this method is invoked.
 func (w *IndexWriter) Close() error {
 	assert2(w.pendingCommit == nil,
 		"cannot close: prepareCommit was already called with no corresponding call to commit")
-	// Ensure that only one goroutine actaully gets to do the closing
-	w.commitLock.Lock()
-	defer w.commitLock.Unlock()
-	return w.close(func() (ok bool, err error) {
-		defer func() {
-			if !ok { // be certain to close the index on any error
-				defer recover() // suppress so we keep returning original error
-				w.rollbackInternal()
+	var committed *SegmentInfos
+	err := func() error {
+		// Ensure that only one goroutine actaully gets to do the closing
+		w.commitLock.Lock()
+		defer w.commitLock.Unlock()
+		return w.close(func() (ok bool, err error) {
+			defer func() {
+				if !ok { // be certain to close the index on any error
+					defer recover() // suppress so we keep returning original error
+					w.rollbackInternal()
+				}
+			}()
+			if w.infoStream.IsEnabled("IW") {
+				w.infoStream.Message("IW", "now flush at close")
 			}
-		}()
-		if w.infoStream.IsEnabled("IW") {
-			w.infoStream.Message("IW", "now flush at close")
-		}
-		if err = w.flush(true, true); err != nil {
-			return
-		}
-		w.waitForMerges()
-		if err = w.commitInternal(w.config.MergePolicy()); err != nil {
-			return
-		}
-		return w.rollbackInternal() // ie close, since we just committed
-	})
+			if err = w.flush(true, true); err != nil {
+				return
+			}
+			w.waitForMerges()
+			if committed, err = w.commitInternal(w.config.MergePolicy()); err != nil {
+				return
+			}
+			return w.rollbackInternal() // ie close, since we just committed
+		})
+	}()
+	w.runCommitHook(committed)
+	return err
 }
 
 // Retuns the Directory used by this index.
@@ -596,6 +636,9 @@ func (w *IndexWriter) UpdateDocument(term *Term, doc []IndexableField, analyzer
 
 	ok, err := w.docWriter.updateDocument(doc, analyzer, term)
 	if err != nil {
+		// clean up after a failed flush right away; the update's
+		// error is the one to report
+		w.docWriter.processEvents(w, false, false)
 		return err
 	}
 	if ok {
@@ -613,6 +656,10 @@ func (w *IndexWriter) newSegmentName() string {
 	// Ian: but why?
 	w.Lock()
 	defer w.Unlock()
+	return w._newSegmentName()
+}
+
+func (w *IndexWriter) _newSegmentName() string {
 	// Important to increment changeCount so that the segmentInfos is
 	// written on close. Otherwise we could close, re-open and
 	// re-return the same segment name that was previously returned
@@ -620,7 +667,9 @@ func (w *IndexWriter) newSegmentName() string {
 	w.changeCount++
 	w.segmentInfos.changed()
 	defer func() { w.segmentInfos.counter++ }()
-	return fmt.Sprintf("_%v", strconv.FormatInt(int64(w.segmentInfos.counter), 36))
+	name := fmt.Sprintf("_%v", strconv.FormatInt(int64(w.segmentInfos.counter), 36))
+	w.segmentsInFlight[name] = true
+	return name
 }
 
 /*
@@ -772,6 +821,8 @@ merge requested by the MergePolicy.
 func (w *IndexWriter) nextMerge() *OneMerge {
 	w.Lock() // synchronized
 	defer w.Unlock()
+	w.MergeControl.Lock()
+	defer w.MergeControl.Unlock()
 
 	if w.pendingMerges.Len() == 0 {
 		return nil
@@ -823,7 +874,7 @@ func (w *IndexWriter) rollbackInternal() (ok bool, err error) {
 					// we tried to be nice about it: do the minimum
 					// don't leak a segments_N file if there is a pending commit
 					if w.pendingCommit != nil {
-						w.pendingCommit.rollbackCommit(w.directory)
+						w.pendingCommit.RollbackCommit(w.directory)
 						w.deleter.decRefInfos(w.pendingCommit)
 					}
 					w.pendingCommit = nil
@@ -835,13 +886,19 @@ func (w *IndexWriter) rollbackInternal() (ok bool, err error) {
 			}
 		}()
 
-		func() {
+		stopMerges := func() {
 			w.Lock()
 			defer w.Unlock()
-
-			w.abortAllMerges()
+			w.MergeControl.Lock()
+			defer w.MergeControl.Unlock()
 			w.stopMerges = true
-		}()
+		}
+		// Must not hold IW's lock while waiting for the running merges
+		// to abort: they need it to finish. Stop merges first so none
+		// can register meanwhile.
+		stopMerges()
+		w.abortAllMerges()
+		stopMerges()
 
 		if w.infoStream.IsEnabled("IW") {
 			w.infoStream.Message("IW", "rollback: done finish merges")
@@ -861,8 +918,11 @@ func (w *IndexWriter) rollbackInternal() (ok bool, err error) {
 			w.Lock()
 			defer w.Unlock()
 
+			// flushes were aborted above, so their files can go too
+			w.segmentsInFlight = make(map[string]bool)
+
 			if w.pendingCommit != nil {
-				w.pendingCommit.rollbackCommit(w.directory)
+				w.pendingCommit.RollbackCommit(w.directory)
 				w.deleter.decRefInfos(w.pendingCommit)
 				w.pendingCommit = nil
 			}
@@ -1006,6 +1066,7 @@ func (w *IndexWriter) publishFlushedSegment(newSegment *SegmentCommitInfo,
 	}
 	newSegment.SetBufferedUpdatesGen(nextGen)
 	w.segmentInfos.Segments = append(w.segmentInfos.Segments, newSegment)
+	defer delete(w.segmentsInFlight, newSegment.Info.Name)
 	return w._checkpoint()
 }
 
@@ -1015,6 +1076,19 @@ func (w *IndexWriter) resetMergeExceptions() {
 	panic("not implemented yet")
 }
 
+/*
+Expert: prepare for commit. This does the first phase of 2-phase
+commit: it flushes all pending changes and writes the new segments_N
+file, but doesn't make it visible to readers. Call Commit() to finish
+the commit, or Rollback() to revert it.
+*/
+func (w *IndexWriter) PrepareCommit() error {
+	w.ensureOpen()
+	w.commitLock.Lock()
+	defer w.commitLock.Unlock()
+	return w.prepareCommitInternal(w.config.MergePolicy())
+}
+
 /*
 Requires commitLock
 */
@@ -1156,15 +1230,20 @@ devices.
 */
 func (w *IndexWriter) Commit() error {
 	w.ensureOpen()
-	w.commitLock.Lock()
-	defer w.commitLock.Unlock()
-	return w.commitInternal(w.config.MergePolicy())
+	committed, err := func() (*SegmentInfos, error) {
+		w.commitLock.Lock()
+		defer w.commitLock.Unlock()
+		return w.commitInternal(w.config.MergePolicy())
+	}()
+	w.runCommitHook(committed)
+	return err
 }
 
 /*
-Assume commitLock is locked.
+Assume commitLock is locked. Returns the SegmentInfos committed, if
+any, for the caller to run the commit hook on.
 */
-func (w *IndexWriter) commitInternal(mergePolicy MergePolicy) error {
+func (w *IndexWriter) commitInternal(mergePolicy MergePolicy) (*SegmentInfos, error) {
 	if w.infoStream.IsEnabled("IW") {
 		w.infoStream.Message("IW", "commit: start")
 	}
@@ -1181,7 +1260,7 @@ func (w *IndexWriter) commitInternal(mergePolicy MergePolicy) error {
 		}
 		err := w.prepareCommitInternal(mergePolicy)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		if w.infoStream.IsEnabled("IW") {
@@ -1191,7 +1270,7 @@ func (w *IndexWriter) commitInternal(mergePolicy MergePolicy) error {
 	return w.finishCommit()
 }
 
-func (w *IndexWriter) finishCommit() (err error) {
+func (w *IndexWriter) finishCommit() (committed *SegmentInfos, err error) {
 	var commitCompleted bool
 	var finished bool
 	var committedSegmentsFileName string
@@ -1216,7 +1295,7 @@ func (w *IndexWriter) finishCommit() (err error) {
 		if w.infoStream.IsEnabled("IW") {
 			w.infoStream.Message("IW", "commit: pendingCommit == nil; skip")
 		}
-		return nil
+		return nil, nil
 	}
 
 	defer func() {
@@ -1236,7 +1315,7 @@ func (w *IndexWriter) finishCommit() (err error) {
 	if w.infoStream.IsEnabled("IW") {
 		w.infoStream.Message("IW", "commit: pendingCommit != nil")
 	}
-	if committedSegmentsFileName, err = w.pendingCommit.finishCommit(w.directory); err != nil {
+	if committedSegmentsFileName, err = w.pendingCommit.FinishCommit(w.directory); err != nil {
 		return
 	}
 
@@ -1252,14 +1331,45 @@ func (w *IndexWriter) finishCommit() (err error) {
 	w.lastCommitChangeCount = w.pendingCommitChangeCount
 	w.rollbackSegments = w.pendingCommit.createBackupSegmentInfos()
 
+	if w.writeChecksumManifest {
+		// the commit is already durable, and CheckIndex reports a
+		// missing or stale manifest; just report it
+		if err := writeChecksumManifest(w.directory, w.pendingCommit); err != nil {
+			log.Printf("failed to write checksum manifest for %v: %v",
+				committedSegmentsFileName, err)
+			if w.infoStream.IsEnabled("IW") {
+				w.infoStream.Message("IW", "commit: failed to write checksum manifest: %v", err)
+			}
+		}
+	}
+
 	finished = true
+	committed = w.pendingCommit
 
 	if w.infoStream.IsEnabled("IW") {
 		w.infoStream.Message("IW", "commit: wrote segments file '%v'", committedSegmentsFileName)
 		w.infoStream.Message("IW", fmt.Sprintf("commit: took %v", time.Now().Sub(w.startCommitTime)))
 		w.infoStream.Message("IW", "commit: done")
 	}
-	return nil
+	return committed, nil
+}
+
+/*
+Calls the commit hook, if any, on a finished commit. Must be called
+without holding commitLock or IW's lock, so that the hook may call
+back into the writer.
+*/
+func (w *IndexWriter) runCommitHook(committed *SegmentInfos) {
+	if committed == nil || w.commitHook == nil {
+		return
+	}
+	if err := w.commitHook(committed.generation, committed.userData); err != nil {
+		// the commit is already durable; just report it
+		log.Printf("commit hook failed for generation %v: %v", committed.generation, err)
+		if w.infoStream.IsEnabled("IW") {
+			w.infoStream.Message("IW", "commit hook failed: %v", err)
+		}
+	}
 }
 
 /*
@@ -1417,8 +1527,317 @@ func (w *IndexWriter) _applyAllDeletesAndUpdates() error {
 Merges the indicated segments, replacing them in the stack with a
 single segment.
 */
-func (w *IndexWriter) merge(merge *OneMerge) error {
-	panic("not implemented yet")
+func (w *IndexWriter) merge(merge *OneMerge) (err error) {
+	var success = false
+	defer func() {
+		func() {
+			w.Lock() // synchronized
+			defer w.Unlock()
+			w.MergeControl.Lock()
+			defer w.MergeControl.Unlock()
+			w.mergeFinish(merge)
+			if !success {
+				if w.infoStream.IsEnabled("IW") {
+					w.infoStream.Message("IW", "hit error during merge")
+				}
+				if merge.info != nil && w.segmentInfos.indexOf(merge.info) == -1 {
+					w.deleter.refresh(merge.info.Info.Name)
+				}
+			}
+			if merge.info != nil {
+				delete(w.segmentsInFlight, merge.info.Info.Name)
+			}
+		}()
+		// This merge (and, generally, any change to the segments) may
+		// now enable new merges, so we call merge policy & update
+		// pending merges.
+		if success && !merge.isAborted() {
+			_, err = w.updatePendingMerges(w.config.MergePolicy(), MERGE_FINISHED, merge.maxNumSegments)
+		}
+	}()
+
+	// Refuse merges that would overflow the doc count before anything
+	// is written, so the policy can pick a smaller merge.
+	if err = merge.checkDocCount(w.readerPool, actualMaxDocs); err != nil {
+		if w.infoStream.IsEnabled("IW") {
+			w.infoStream.Message("IW", "skip merge %v: %v",
+				w.readerPool.segmentsToString(merge.segments), err)
+		}
+		return err
+	}
+	if err = merge.checkAborted(); err != nil {
+		return err
+	}
+	if w.infoStream.IsEnabled("IW") {
+		w.infoStream.Message("IW", "now merge\n  merge=%v\n  index=%v",
+			w.readerPool.segmentsToString(merge.segments),
+			w.readerPool.segmentsToString(w.segmentInfos.Segments))
+	}
+	if err = w.mergeInit(merge); err != nil {
+		return err
+	}
+	if err = w.mergeMiddle(merge, newCheckAbort(merge)); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+/*
+Does initial setup for a merge, which is fast but holds the
+synchronized lock on IndexWriter instance.
+*/
+func (w *IndexWriter) mergeInit(merge *OneMerge) error {
+	w.Lock() // synchronized
+	defer w.Unlock()
+
+	assert(merge.registerDone)
+	assert2(w.tragedy == nil, "this writer hit an unrecoverable error; cannot merge\n%v", w.tragedy)
+
+	if merge.info != nil {
+		// mergeInit already done
+		return nil
+	}
+	if merge.isAborted() {
+		return merge.checkAborted()
+	}
+	w.testPoint("startMergeInit")
+
+	// Lock order: IW -> BD
+	result, err := w.bufferedUpdatesStream.applyDeletesAndUpdates(w.readerPool, merge.segments)
+	if err != nil {
+		return err
+	}
+	if result.anyDeletes {
+		if err = w._checkpoint(); err != nil {
+			return err
+		}
+	}
+	// Bind a new segment name here so even with ConcurrentMergePolicy
+	// we keep deterministic segment names.
+	si := NewSegmentInfo(w.directory, util.VERSION_LATEST, w._newSegmentName(), -1, false, w.codec, nil)
+	setDiagnosticsAndDetails(si, SOURCE_MERGE, map[string]string{
+		"mergeMaxNumSegments": strconv.Itoa(merge.maxNumSegments),
+		"mergeFactor":         strconv.Itoa(len(merge.segments)),
+	})
+	merge.info = NewSegmentCommitInfo(si, 0, -1, -1, -1)
+
+	// Lock order: IW -> BD
+	merge.info.SetBufferedUpdatesGen(result.gen)
+	w.bufferedUpdatesStream.prune(w.segmentInfos)
+
+	if w.infoStream.IsEnabled("IW") {
+		w.infoStream.Message("IW", "merge seg=%v %v", si.Name,
+			w.readerPool.segmentsToString(merge.segments))
+	}
+	return nil
+}
+
+/*
+Does the actual (time-consuming) work of the merge, but without
+holding synchronized lock on IndexWriter instance. The merged segment
+is written through merge.directory(), and checkAbort is told about
+the work done so that the merge stops once aborted.
+*/
+func (w *IndexWriter) mergeMiddle(merge *OneMerge, checkAbort CheckAbort) (err error) {
+	if err = merge.checkAborted(); err != nil {
+		return err
+	}
+
+	context := merge.context()
+	directory := merge.directory(w.directory)
+	dirWrapper := store.NewTrackingDirectoryWrapper(directory)
+
+	var success = false
+	defer func() {
+		if !success {
+			w.closeMergeReaders(merge)
+		}
+	}()
+
+	for _, info := range merge.segments {
+		// Hold onto the "live" reader; we will use this to commit merged
+		// deletes
+		reader, err := NewSegmentReader(info, DEFAULT_TERMS_INDEX_DIVISOR, context)
+		if err != nil {
+			return err
+		}
+		merge.readers = append(merge.readers, reader)
+	}
+
+	merger := newSegmentMerger(merge.readers, merge.info.Info, w.infoStream, dirWrapper,
+		w.config.TermIndexInterval(), checkAbort, w.globalFieldNumberMap, context)
+	if err = merge.checkAborted(); err != nil {
+		return err
+	}
+
+	// This is where all the work happens:
+	var mergeState *MergeState
+	if merger.shouldMerge() {
+		if mergeState, err = merger.merge(); err != nil {
+			return err
+		}
+	} else {
+		mergeState = merger.mergeState
+	}
+	merge.info.Info.SetFiles(dirWrapper.CreatedFiles())
+
+	if w.infoStream.IsEnabled("IW") {
+		if merge.info.Info.DocCount() == 0 {
+			w.infoStream.Message("IW", "merge away fully deleted segments")
+		} else {
+			w.infoStream.Message("IW", "merge codec=%v docCount=%v; merged segment has %v",
+				w.codec, merge.info.Info.DocCount(), mergeState.fieldInfos)
+		}
+	}
+
+	// Very important to do this before opening the reader because
+	// codec must know if prox was written for this segment:
+	useCompoundFile, err := func() (bool, error) {
+		w.Lock() // synchronized
+		defer w.Unlock()
+		return w.config.MergePolicy().UseCompoundFile(w.segmentInfos, merge.info, w)
+	}()
+	if err != nil {
+		return err
+	}
+
+	if useCompoundFile {
+		if w.infoStream.IsEnabled("IW") {
+			w.infoStream.Message("IW", "create compound file")
+		}
+		filesToRemove, err := createCompoundFile(w.infoStream, directory, checkAbort, merge.info.Info, context)
+		if err != nil {
+			return err
+		}
+
+		if err = func() error {
+			w.Lock() // synchronized
+			defer w.Unlock()
+			w.deleter.deleteNewFiles(filesToRemove)
+			// The merge may have been aborted while the compound file was
+			// built, in which case merge() removes its files.
+			if merge.isAborted() {
+				return merge.checkAborted()
+			}
+			return nil
+		}(); err != nil {
+			return err
+		}
+		merge.info.Info.SetUseCompoundFile(true)
+	}
+
+	// Have codec write SegmentInfo. Must do this after creating CFS so
+	// that 1) .si isn't slurped into CFS, and 2) .si reflects
+	// useCompoundFile=true change above:
+	if err = w.codec.SegmentInfoFormat().SegmentInfoWriter().Write(
+		directory, merge.info.Info, mergeState.fieldInfos, context); err != nil {
+		return err
+	}
+
+	// TODO: ideally we would freeze merge.info here!!
+	// because any changes after writing the .si will be lost...
+
+	if w.infoStream.IsEnabled("IW") {
+		if size, err := merge.info.SizeInBytes(); err == nil {
+			w.infoStream.Message("IW", "merged segment size=%.3f MB vs estimate=%.3f MB",
+				float64(size)/1024/1024, float64(merge.estimatedMergeBytes)/1024/1024)
+		}
+	}
+
+	if err = w.commitMerge(merge, mergeState); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+/*
+Commits the merged segment, replacing the merged ones in the segment
+infos, and checkpoints so the merged away files can be deleted.
+*/
+func (w *IndexWriter) commitMerge(merge *OneMerge, mergeState *MergeState) error {
+	w.Lock() // synchronized
+	defer w.Unlock()
+
+	w.testPoint("startCommitMerge")
+	assert2(w.tragedy == nil, "this writer hit an unrecoverable error; cannot complete merge\n%v", w.tragedy)
+
+	if w.infoStream.IsEnabled("IW") {
+		w.infoStream.Message("IW", "commitMerge: %v index=%v",
+			w.readerPool.segmentsToString(merge.segments), w.segString())
+	}
+
+	assert(merge.registerDone)
+
+	// If merge was explicitly aborted, or, if rollback() or
+	// rollbackTransaction() had been called since our merge started
+	// (which results in an unqualified deleter.refresh() call that
+	// will remove any index file that current segments does not
+	// reference), we abort this merge
+	if merge.isAborted() {
+		if w.infoStream.IsEnabled("IW") {
+			w.infoStream.Message("IW", "commitMerge: skip: it was aborted")
+		}
+		w.deleter.deleteNewFiles(merge.info.Files())
+		return merge.checkAborted()
+	}
+
+	// If the doc store we are using has been closed and is in now
+	// compound format (but wasn't when we started), then we will
+	// switch to the compound format as well:
+	assert(w.segmentInfos.indexOf(merge.info) == -1)
+
+	// Deletions made while merging would have to be carried over to
+	// the merged segment (see Lucene's commitMergedDeletes); nothing
+	// can delete docs concurrently yet, so the merge readers must
+	// still be current:
+	for i, info := range merge.segments {
+		assert2(info.Info.DocCount()-w.readerPool.numDeletedDocs(info) == merge.readers[i].NumDocs(),
+			"segment %v got deletions while merging", info.Info.Name)
+	}
+
+	dropSegment := merge.info.Info.DocCount() == 0 && !w.keepFullyDeletedSegments
+	w.segmentInfos.applyMergeChanges(merge, dropSegment)
+
+	// Now deduct the deleted docs that we just reclaimed from this
+	// merge:
+	if delDocCount := merge.totalDocCount - merge.info.Info.DocCount(); delDocCount != 0 {
+		atomic.AddInt64(&w.pendingNumDocs, -int64(delDocCount))
+	}
+
+	if dropSegment {
+		assert(w.segmentInfos.indexOf(merge.info) == -1)
+		w.deleter.deleteNewFiles(merge.info.Files())
+	}
+
+	// Must close before checkpoint, otherwise IFD won't be able to
+	// delete the held-open files from the merge readers:
+	if err := w.closeMergeReaders(merge); err != nil {
+		return err
+	}
+
+	// Must note the change to segmentInfos so any commits in-flight
+	// don't lose it (IFD will incRef/protect the new files we created):
+	if err := w._checkpoint(); err != nil {
+		return err
+	}
+
+	if w.infoStream.IsEnabled("IW") {
+		w.infoStream.Message("IW", "after commitMerge: %v", w.segString())
+	}
+	return nil
+}
+
+/* Closes the readers the merge opened, returning the first error. */
+func (w *IndexWriter) closeMergeReaders(merge *OneMerge) (err error) {
+	for _, reader := range merge.readers {
+		if err2 := reader.Close(); err2 != nil && err == nil {
+			err = err2
+		}
+	}
+	merge.readers = nil
+	return
 }
 
 /*
@@ -1426,9 +1845,71 @@ Checks whether this merge involves any segments already participating
 in a merge. If not, this merge is "registered", meaning we record
 that its semgents are now participating in a merge, and true is
 returned. Else (the merge conflicts) false is returned.
+
+Must be called with the writer's lock held.
 */
 func (w *IndexWriter) registerMerge(merge *OneMerge) (bool, error) {
-	panic("not implemented yet")
+	if merge.registerDone {
+		return true, nil
+	}
+	assert(len(merge.segments) > 0)
+
+	// abortAllMerges() may be going through the merges at the same time
+	w.MergeControl.Lock()
+	defer w.MergeControl.Unlock()
+
+	if w.stopMerges {
+		merge.abort()
+		return false, MergeAbortedError(fmt.Sprintf("merge is aborted: %v",
+			w.readerPool.segmentsToString(merge.segments)))
+	}
+
+	for _, info := range merge.segments {
+		if _, ok := w.mergingSegments[info]; ok {
+			if w.infoStream.IsEnabled("IW") {
+				w.infoStream.Message("IW", "reject merge %v: segment %v is already marked for merge",
+					w.readerPool.segmentsToString(merge.segments), w.readerPool.segmentToString(info))
+			}
+			return false, nil
+		}
+		if w.segmentInfos.indexOf(info) == -1 {
+			if w.infoStream.IsEnabled("IW") {
+				w.infoStream.Message("IW", "reject merge %v: segment %v does not exist in live infos",
+					w.readerPool.segmentsToString(merge.segments), w.readerPool.segmentToString(info))
+			}
+			return false, nil
+		}
+		// Rather than failing the merge later on, leave alone the
+		// segments the merger can't handle yet:
+		fieldInfos, err := ReadFieldInfos(info)
+		if err != nil {
+			return false, err
+		}
+		if what := unmergeableFields(fieldInfos); what != "" {
+			if w.infoStream.IsEnabled("IW") {
+				w.infoStream.Message("IW", "reject merge %v: segment %v has %v, which can't be merged yet",
+					w.readerPool.segmentsToString(merge.segments), w.readerPool.segmentToString(info), what)
+			}
+			return false, nil
+		}
+	}
+
+	w.pendingMerges.PushBack(merge)
+	if w.infoStream.IsEnabled("IW") {
+		w.infoStream.Message("IW", "add merge to pendingMerges: %v [total %v pending]",
+			w.readerPool.segmentsToString(merge.segments), w.pendingMerges.Len())
+	}
+
+	// OK it does not conflict; now record that this merge is running
+	// (while synchronized) to avoid race condition where two
+	// conflicting merges from different routines, start
+	for _, info := range merge.segments {
+		w.mergingSegments[info] = true
+	}
+
+	// Merge is now registered
+	merge.registerDone = true
+	return true, nil
 }
 
 func setDiagnostics(info *SegmentInfo, source string) {
@@ -1516,7 +1997,7 @@ func (w *IndexWriter) startCommit(toSync *SegmentInfos) error {
 		w.infoStream.Message("IW", "startCommit(): start")
 	}
 
-	if err := func() error {
+	if skip, err := func() (bool, error) {
 		w.Lock()
 		defer w.Unlock()
 
@@ -1528,7 +2009,7 @@ func (w *IndexWriter) startCommit(toSync *SegmentInfos) error {
 			}
 			w.deleter.decRefFiles(w.filesToCommit)
 			w.filesToCommit = nil
-			return nil
+			return true, nil
 		}
 
 		if w.infoStream.IsEnabled("IW") {
@@ -1536,8 +2017,8 @@ func (w *IndexWriter) startCommit(toSync *SegmentInfos) error {
 				w.readerPool.segmentsToString(toSync.Segments), w.changeCount)
 		}
 
-		return w.assertFilesExist(toSync)
-	}(); err != nil {
+		return false, w.assertFilesExist(toSync)
+	}(); err != nil || skip {
 		return err
 	}
 
@@ -1596,7 +2077,7 @@ func (w *IndexWriter) startCommit(toSync *SegmentInfos) error {
 		if !success {
 			pendingCommitSet = false
 			w.pendingCommit = nil
-			toSync.rollbackCommit(w.directory)
+			toSync.RollbackCommit(w.directory)
 		}
 	}()
 
@@ -1646,6 +2127,41 @@ func (w *IndexWriter) deletePendingFiles() {
 	w.deleter.deletePendingFiles()
 }
 
+/*
+Keeps the given files of a commit from being deleted until
+decRefDeleter() releases them. Returns false if this writer is
+closed, since it can no longer delete them anyway. Fails if any of
+the files was already deleted.
+*/
+func (w *IndexWriter) incRefDeleter(files []string) (bool, error) {
+	w.Lock() // synchronized
+	defer w.Unlock()
+	if w.ClosingControl._closed {
+		return false, nil
+	}
+	for _, file := range files {
+		if !w.deleter.exists(file) {
+			return false, errors.New(fmt.Sprintf(
+				"file '%v' of the commit was already deleted", file))
+		}
+	}
+	w.deleter.incRefFiles(files)
+	return true, nil
+}
+
+/*
+Releases files kept by incRefDeleter(), deleting those no longer
+referenced. If this writer is closed meanwhile, the files are left to
+the next writer to clean up.
+*/
+func (w *IndexWriter) decRefDeleter(files []string) {
+	w.Lock() // synchronized
+	defer w.Unlock()
+	if !w.ClosingControl._closed {
+		w.deleter.decRefFiles(files)
+	}
+}
+
 /*
 NOTE: this method creates a compound file for all files returned by
 info.files(). While, generally, this may include separate norms and
@@ -1695,7 +2211,7 @@ func createCompoundFile(infoStream util.InfoStream,
 			if length, err = directory.FileLength(file); err != nil {
 				return
 			}
-			if err = checkAbort.work(float64(length)); err != nil {
+			if err = checkAbort.Work(float64(length)); err != nil {
 				return
 			}
 		}
@@ -1724,11 +2240,17 @@ func (w *IndexWriter) deleteNewFiles(files []string) error {
 	panic("not implemented yet")
 }
 
-/* Cleans up residuals from a segment that could not be entirely flushed due to an error */
+/*
+Cleans up residuals from a segment that could not be entirely flushed
+due to an error, along with any other unreferenced index files, e.g.
+left by earlier failures. Segments still being flushed or merged are
+left alone.
+*/
 func (w *IndexWriter) flushFailed(info *SegmentInfo) error {
 	w.Lock()
 	defer w.Unlock()
-	return w.deleter.refresh(info.Name)
+	delete(w.segmentsInFlight, info.Name)
+	return w.deleter.refresh("")
 }
 
 func (w *IndexWriter) purge(forced bool) (n int, err error) {
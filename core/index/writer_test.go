@@ -0,0 +1,104 @@
+package index
+
+import (
+	"errors"
+	acore "github.com/balzaczyy/golucene/analysis/core"
+	docu "github.com/balzaczyy/golucene/core/document"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Internal test hook which lowers the document limit enforced by
+IndexWriter, returning the previous limit so callers can restore it.
+It is not synchronized with running writers; set it before opening
+one. maxDocs must be in (0, MAX_DOCS].
+*/
+func setMaxDocs(maxDocs int) int {
+	assert2(maxDocs > 0 && maxDocs <= MAX_DOCS,
+		"maxDocs must be > 0 and <= %v (got %v)", MAX_DOCS, maxDocs)
+	prev := actualMaxDocs
+	actualMaxDocs = maxDocs
+	return prev
+}
+
+func TestNewIndexWriterErrorDoesNotLeakRoutines(t *testing.T) {
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	d := store.NewRAMDirectory()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		// there is no index to append to
+		conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+		conf.SetOpenMode(OPEN_MODE_APPEND)
+		if _, err := NewIndexWriter(d, conf); err == nil {
+			t.Fatal("Expected opening a missing index to fail")
+		}
+	}
+	// give any leftover routine a chance to exit
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected %v routines, but %v", before, after)
+	}
+}
+
+// Fails creating files with the given extension while failing is set.
+type failingCreateDirectory struct {
+	store.Directory
+	ext     string
+	failing bool
+}
+
+func (d *failingCreateDirectory) CreateOutput(name string, ctx store.IOContext) (store.IndexOutput, error) {
+	if d.failing && strings.HasSuffix(name, d.ext) {
+		return nil, errors.New("fake disk full")
+	}
+	return d.Directory.CreateOutput(name, ctx)
+}
+
+func TestAbortedSegmentIsNoLongerInFlight(t *testing.T) {
+	if DefaultSimilarity == nil {
+		DefaultSimilarity = func() Similarity { return constantSimilarity{} }
+	}
+	d := &failingCreateDirectory{store.NewRAMDirectory(), ".fdt", true}
+	conf := NewIndexWriterConfig(util.VERSION_LATEST, acore.NewWhitespaceAnalyzer())
+	w, err := NewIndexWriter(d, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	addDocument := func() error {
+		doc := docu.NewDocument()
+		doc.Add(docu.NewTextFieldFromString("body", "some text", docu.STORE_YES))
+		return w.AddDocument(doc.Fields())
+	}
+	inFlight := func() int {
+		w.Lock()
+		defer w.Unlock()
+		return len(w.segmentsInFlight)
+	}
+
+	// the stored fields can't be written, which aborts the segment
+	if err = addDocument(); err == nil {
+		t.Fatal("Expected adding a document to fail")
+	}
+	assertEquals(t, 0, inFlight())
+
+	d.failing = false
+	if err = addDocument(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 1, inFlight())
+	if err = w.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, 0, inFlight())
+}
@@ -0,0 +1,275 @@
+package search
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/index"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+)
+
+// search/similarities/BM25Similarity.java
+
+/*
+BM25 Similarity. Introduced in Stephen E. Robertson, Steve Walker,
+Susan Jones, Micheline Hancock-Beaulieu, and Mike Gatford. Okapi at
+TREC-3. In Proceedings of the Third Text REtrieval Conference
+(TREC 1994). Gaithersburg, USA, November 1994.
+
+A document's score for a term is
+
+	idf * freq * (k1 + 1) / (freq + k1 * (1 - b + b * fieldLength / avgFieldLength))
+
+so repeated terms saturate instead of adding up linearly, and matches
+in fields shorter than average score higher.
+*/
+type BM25Similarity struct {
+	k1 float32
+	b  float32
+	// True if overlap tokens (tokens with a position of increment of
+	// zero) are discounted from the document's length.
+	discountOverlaps bool
+}
+
+/*
+BM25 with the supplied parameter values.
+
+k1 controls non-linear term frequency normalization (saturation), and
+b controls to what degree document length normalizes tf values.
+*/
+func NewBM25SimilarityWith(k1, b float32) *BM25Similarity {
+	return &BM25Similarity{k1: k1, b: b, discountOverlaps: true}
+}
+
+/* BM25 with these default values: k1 = 1.2, b = 0.75 */
+func NewBM25Similarity() *BM25Similarity {
+	return NewBM25SimilarityWith(1.2, 0.75)
+}
+
+func (sim *BM25Similarity) K1() float32 { return sim.k1 }
+func (sim *BM25Similarity) B() float32  { return sim.b }
+
+/* Implemented as log(1 + (numDocs - docFreq + 0.5)/(docFreq + 0.5)). */
+func (sim *BM25Similarity) idf(docFreq, numDocs int64) float32 {
+	return float32(math.Log(1 + (float64(numDocs-docFreq)+0.5)/(float64(docFreq)+0.5)))
+}
+
+/* Implemented as 1 / (distance + 1). */
+func (sim *BM25Similarity) sloppyFreq(distance int) float32 {
+	return 1.0 / float32(distance+1)
+}
+
+/* The default implementation computes the average as sumTotalTermFreq / maxDoc, or 1 if the statistic is not available. */
+func (sim *BM25Similarity) avgFieldLength(collectionStats CollectionStatistics) float32 {
+	if sumTotalTermFreq := collectionStats.sumTotalTermFreq; sumTotalTermFreq > 0 {
+		return float32(float64(sumTotalTermFreq) / float64(collectionStats.maxDoc))
+	}
+	return 1
+}
+
+/* The default implementation encodes boost / sqrt(length) with SmallFloat, the same as DefaultSimilarity. */
+func (sim *BM25Similarity) encodeNormValue(boost float32, fieldLength int) int64 {
+	return int64(util.FloatToByte315(boost / float32(math.Sqrt(float64(fieldLength)))))
+}
+
+/* The default implementation returns 1 / f^2 where f is SmallFloat.Byte315ToFloat(b). */
+func (sim *BM25Similarity) decodeNormValue(norm int64) float32 {
+	return BM25_NORM_TABLE[int(norm&0xff)]
+}
+
+/* Cache of decoded bytes. */
+var BM25_NORM_TABLE []float32 = func() []float32 {
+	table := make([]float32, 256)
+	for i, _ := range table {
+		f := util.Byte315ToFloat(byte(i))
+		table[i] = 1.0 / (f * f)
+	}
+	return table
+}()
+
+/*
+Sets whether overlap tokens (tokens with 0 position increment) are
+ignored when computing norm. By default this is true, meaning
+overlap tokens do not count when computing norms.
+*/
+func (sim *BM25Similarity) SetDiscountOverlaps(v bool) {
+	sim.discountOverlaps = v
+}
+
+func (sim *BM25Similarity) Coord(overlap, maxOverlap int) float32 {
+	return 1
+}
+
+func (sim *BM25Similarity) QueryNorm(valueForNormalization float32) float32 {
+	return 1
+}
+
+func (sim *BM25Similarity) ComputeNorm(state *index.FieldInvertState) int64 {
+	numTerms := state.Length()
+	if sim.discountOverlaps {
+		numTerms -= state.NumOverlap()
+	}
+	return sim.encodeNormValue(state.Boost(), numTerms)
+}
+
+func (sim *BM25Similarity) idfExplainTerm(collectionStats CollectionStatistics, termStats TermStatistics) Explanation {
+	df, max := termStats.DocFreq, collectionStats.maxDoc
+	idf := sim.idf(df, max)
+	return newExplanation(idf, fmt.Sprintf("idf(docFreq=%v, maxDocs=%v)", df, max))
+}
+
+func (sim *BM25Similarity) idfExplainPhrase(collectionStats CollectionStatistics, termStats []TermStatistics) Explanation {
+	details := make([]Explanation, len(termStats))
+	var idf float32 = 0
+	for i, stat := range termStats {
+		details[i] = sim.idfExplainTerm(collectionStats, stat)
+		idf += details[i].Value()
+	}
+	ans := newExplanation(idf, "idf(), sum of:")
+	ans.details = details
+	return ans
+}
+
+func (sim *BM25Similarity) computeWeight(queryBoost float32,
+	collectionStats CollectionStatistics, termStats ...TermStatistics) SimWeight {
+
+	var idf Explanation
+	if len(termStats) == 1 {
+		idf = sim.idfExplainTerm(collectionStats, termStats[0])
+	} else {
+		idf = sim.idfExplainPhrase(collectionStats, termStats)
+	}
+
+	avgdl := sim.avgFieldLength(collectionStats)
+
+	// compute freq-independent part of bm25 equation across all norm values
+	cache := make([]float32, 256)
+	for i, _ := range cache {
+		cache[i] = sim.k1 * ((1 - sim.b) + sim.b*sim.decodeNormValue(int64(i))/avgdl)
+	}
+	return newBM25Stats(collectionStats.field, idf, queryBoost, avgdl, cache)
+}
+
+func (sim *BM25Similarity) simScorer(w SimWeight, ctx *index.AtomicReaderContext) (SimScorer, error) {
+	stats := w.(*bm25Stats)
+	norms, err := ctx.Reader().(index.AtomicReader).NormValues(stats.field)
+	if err != nil {
+		return nil, err
+	}
+	return newBM25DocScorer(sim, stats, norms), nil
+}
+
+func (sim *BM25Similarity) String() string {
+	return fmt.Sprintf("BM25(k1=%v,b=%v)", sim.k1, sim.b)
+}
+
+type bm25DocScorer struct {
+	owner       *BM25Similarity
+	stats       *bm25Stats
+	weightValue float32 // boost * idf * (k1 + 1)
+	norms       NumericDocValues
+	cache       []float32
+}
+
+func newBM25DocScorer(owner *BM25Similarity, stats *bm25Stats, norms NumericDocValues) *bm25DocScorer {
+	return &bm25DocScorer{
+		owner:       owner,
+		stats:       stats,
+		weightValue: stats.weight * (owner.k1 + 1),
+		norms:       norms,
+		cache:       stats.cache,
+	}
+}
+
+func (s *bm25DocScorer) Score(doc int, freq float32) float32 {
+	// if there are no norms, we act as if b=0
+	norm := s.owner.k1
+	if s.norms != nil {
+		norm = s.cache[int(s.norms(doc)&0xff)]
+	}
+	return s.weightValue * freq / (freq + norm)
+}
+
+func (s *bm25DocScorer) computeSlopFactor(distance int) float32 {
+	return s.owner.sloppyFreq(distance)
+}
+
+func (s *bm25DocScorer) explain(doc int, freq Explanation) Explanation {
+	return s.owner.explainScore(doc, freq, s.stats, s.norms)
+}
+
+/* Collection statistics for the BM25 model. */
+type bm25Stats struct {
+	// BM25's idf
+	idf Explanation
+	// The average document length.
+	avgdl float32
+	// query's inner boost
+	queryBoost float32
+	// query's outer boost (only for explain)
+	topLevelBoost float32
+	// weight (idf * boost)
+	weight float32
+	// field name, for pulling norms
+	field string
+	// precomputed norm[256] with k1 * ((1 - b) + b * dl / avgdl)
+	cache []float32
+}
+
+func newBM25Stats(field string, idf Explanation, queryBoost, avgdl float32, cache []float32) *bm25Stats {
+	ans := &bm25Stats{
+		field:      field,
+		idf:        idf,
+		queryBoost: queryBoost,
+		avgdl:      avgdl,
+		cache:      cache,
+	}
+	ans.Normalize(1, 1)
+	return ans
+}
+
+func (stats *bm25Stats) ValueForNormalization() float32 {
+	// we return a TF-IDF like normalization to be nice, but we don't
+	// actually normalize ourselves.
+	queryWeight := stats.idf.Value() * stats.queryBoost
+	return queryWeight * queryWeight
+}
+
+func (stats *bm25Stats) Normalize(queryNorm, topLevelBoost float32) {
+	// we don't normalize with queryNorm at all, we just capture the
+	// top-level boost
+	stats.topLevelBoost = topLevelBoost
+	stats.weight = stats.idf.Value() * stats.queryBoost * topLevelBoost
+}
+
+func (sim *BM25Similarity) explainScore(doc int, freq Explanation,
+	stats *bm25Stats, norms NumericDocValues) Explanation {
+
+	result := newExplanation(0, fmt.Sprintf("score(doc=%v,freq=%v), product of:", doc, freq.Value()))
+
+	boostExpl := newExplanation(stats.queryBoost*stats.topLevelBoost, "boost")
+	if boostExpl.value != 1 {
+		result.addDetail(boostExpl)
+	}
+
+	result.addDetail(stats.idf)
+
+	tfNormExpl := newExplanation(0, "tfNorm, computed from:")
+	tfNormExpl.addDetail(freq)
+	tfNormExpl.addDetail(newExplanation(sim.k1, "parameter k1"))
+	f := freq.Value()
+	if norms == nil {
+		tfNormExpl.addDetail(newExplanation(0, "parameter b (norms omitted for field)"))
+		tfNormExpl.value = (f * (sim.k1 + 1)) / (f + sim.k1)
+	} else {
+		doclen := sim.decodeNormValue(norms(doc))
+		tfNormExpl.addDetail(newExplanation(sim.b, "parameter b"))
+		tfNormExpl.addDetail(newExplanation(stats.avgdl, "avgFieldLength"))
+		tfNormExpl.addDetail(newExplanation(doclen, "fieldLength"))
+		tfNormExpl.value = (f * (sim.k1 + 1)) /
+			(f + sim.k1*(1-sim.b+sim.b*doclen/stats.avgdl))
+	}
+	result.addDetail(tfNormExpl)
+	result.value = boostExpl.value * stats.idf.Value() * tfNormExpl.value
+	return result
+}
@@ -39,3 +39,18 @@ func (c *BooleanClause) IsProhibited() bool {
 func (c *BooleanClause) IsRequired() bool {
 	return c.occur == MUST
 }
+
+func (c *BooleanClause) Equals(o *BooleanClause) bool {
+	return o != nil && c.occur == o.occur && c.query.Equals(o.query)
+}
+
+func (c *BooleanClause) HashCode() int {
+	hash := c.query.HashCode()
+	if c.IsRequired() {
+		hash ^= 1
+	}
+	if c.IsProhibited() {
+		hash ^= 2
+	}
+	return hash
+}
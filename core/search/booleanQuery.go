@@ -2,6 +2,7 @@ package search
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/balzaczyy/golucene/core/index"
 	"github.com/balzaczyy/golucene/core/util"
 )
@@ -36,6 +37,31 @@ func (q *BooleanQuery) AddClause(clause *BooleanClause) {
 	q.clauses = append(q.clauses, clause)
 }
 
+/*
+Specifies a minimum number of the optional BooleanClauses which must
+be satisfied.
+
+By default no optional clauses are necessary for a match (unless
+there are no required clauses). If this method is used, then the
+specified number of clauses is required.
+
+Use of this method is totally independent of specifying that any
+specific clauses are required (or prohibited). This number will only
+be compared against the number of matching optional clauses.
+*/
+func (q *BooleanQuery) SetMinimumNumberShouldMatch(min int) {
+	q.minNrShouldMatch = min
+}
+
+func (q *BooleanQuery) MinimumNumberShouldMatch() int {
+	return q.minNrShouldMatch
+}
+
+/* Returns the set of clauses in this query. */
+func (q *BooleanQuery) Clauses() []*BooleanClause {
+	return q.clauses
+}
+
 type BooleanWeight struct {
 	owner        *BooleanQuery
 	similarity   Similarity
@@ -95,14 +121,71 @@ func (w *BooleanWeight) Normalize(norm, topLevelBoost float32) {
 }
 
 func (w *BooleanWeight) Explain(context *index.AtomicReaderContext, doc int) (Explanation, error) {
-	panic("not implemented yet")
+	minShouldMatch := w.owner.minNrShouldMatch
+	sumExpl := newComplexExplanation(false, 0, "sum of:")
+	coord, shouldMatchCount := 0, 0
+	var sum float32
+	fail := false
+	for i, subWeight := range w.weights {
+		c := w.owner.clauses[i]
+		e, err := subWeight.Explain(context, doc)
+		if err != nil {
+			return nil, err
+		}
+		if e.IsMatch() {
+			if !c.IsProhibited() {
+				sumExpl.addDetail(e)
+				sum += e.Value()
+				coord++
+			} else {
+				r := newExplanation(0, fmt.Sprintf("match on prohibited clause (%v)", c.query))
+				r.addDetail(e)
+				sumExpl.addDetail(r)
+				fail = true
+			}
+			if c.occur == SHOULD {
+				shouldMatchCount++
+			}
+		} else if c.IsRequired() {
+			r := newExplanation(0, fmt.Sprintf("no match on required clause (%v)", c.query))
+			r.addDetail(e)
+			sumExpl.addDetail(r)
+			fail = true
+		}
+	}
+	if fail {
+		sumExpl.description = "Failure to meet condition(s) of required/prohibited clause(s)"
+		return sumExpl, nil
+	}
+	if shouldMatchCount < minShouldMatch {
+		sumExpl.description = fmt.Sprintf(
+			"Failure to match minimum number of optional clauses: %v", minShouldMatch)
+		return sumExpl, nil
+	}
+
+	sumExpl.match = coord > 0
+	sumExpl.value = sum
+
+	coordFactor := float32(1)
+	if !w.disableCoord {
+		coordFactor = w.coord(coord, w.maxCoord)
+	}
+	if coordFactor == 1 {
+		return sumExpl, nil // eliminate wrapper
+	}
+	result := newComplexExplanation(sumExpl.IsMatch(), sum*coordFactor, "product of:")
+	result.addDetail(sumExpl)
+	result.addDetail(newExplanation(coordFactor, fmt.Sprintf("coord(%v/%v)", coord, w.maxCoord)))
+	return result, nil
 }
 
 func (w *BooleanWeight) BulkScorer(context *index.AtomicReaderContext,
 	scoreDocsInOrder bool, acceptDocs util.Bits) (BulkScorer, error) {
 
 	if scoreDocsInOrder || w.owner.minNrShouldMatch > 1 {
-		panic("not implemented yet")
+		// BooleanScorer cannot score in order, nor count matchers
+		// reliably enough for a minimum
+		return w.inOrderBulkScorer(context, acceptDocs)
 	}
 
 	var prohibited, optional []BulkScorer
@@ -117,7 +200,8 @@ func (w *BooleanWeight) BulkScorer(context *index.AtomicReaderContext,
 				return nil, nil
 			}
 		} else if c.IsRequired() {
-			panic("not implemented yet")
+			// BooleanScorer cannot deal with required clauses
+			return w.inOrderBulkScorer(context, acceptDocs)
 		} else if c.IsProhibited() {
 			prohibited = append(prohibited, subScorer)
 		} else {
@@ -128,6 +212,102 @@ func (w *BooleanWeight) BulkScorer(context *index.AtomicReaderContext,
 	return newBooleanScorer(w, w.disableCoord, w.owner.minNrShouldMatch, optional, prohibited, w.maxCoord), nil
 }
 
+func (w *BooleanWeight) inOrderBulkScorer(context *index.AtomicReaderContext,
+	acceptDocs util.Bits) (BulkScorer, error) {
+
+	scorer, err := w.Scorer(context, acceptDocs)
+	if err != nil || scorer == nil {
+		return nil, err
+	}
+	return newDefaultScorer(scorer), nil
+}
+
+/*
+Returns an in-order Scorer combining the sub scorers: required
+clauses are intersected, optional clauses are unioned (and also
+required when a minimum number should match), and docs matching any
+prohibited clause are excluded.
+*/
+func (w *BooleanWeight) Scorer(context *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+
+	var required, prohibited, optional []Scorer
+	for i, subWeight := range w.weights {
+		c := w.owner.clauses[i]
+		subScorer, err := subWeight.Scorer(context, acceptDocs)
+		if err != nil {
+			return nil, err
+		}
+		if subScorer == nil {
+			if c.IsRequired() {
+				return nil, nil
+			}
+		} else if c.IsRequired() {
+			required = append(required, subScorer)
+		} else if c.IsProhibited() {
+			prohibited = append(prohibited, subScorer)
+		} else {
+			optional = append(optional, subScorer)
+		}
+	}
+
+	minShouldMatch := w.owner.minNrShouldMatch
+	if len(required) == 0 && minShouldMatch == 0 {
+		// at least one optional clause must match
+		minShouldMatch = 1
+	}
+	if len(optional) < minShouldMatch {
+		// either a query without any positive clause, or too few
+		// optional clauses to satisfy the minimum
+		return nil, nil
+	}
+
+	// the coord factors by number of matching clauses
+	coords := make([]float32, len(required)+len(optional)+1)
+	noCoords := make([]float32, len(coords))
+	for i, _ := range coords {
+		if w.disableCoord {
+			coords[i] = 1
+		} else {
+			coords[i] = w.coord(i, w.maxCoord)
+		}
+		noCoords[i] = 1
+	}
+
+	var scorer Scorer
+	if len(optional) == 0 {
+		scorer = w.conjunction(required, coords[len(required)])
+	} else if len(required) == 0 {
+		scorer = w.disjunction(optional, minShouldMatch, coords)
+	} else {
+		scorer = newReqOptSumScorer(w, w.conjunction(required, 1),
+			w.disjunction(optional, minShouldMatch, noCoords),
+			minShouldMatch > 0, len(required), coords)
+	}
+
+	if len(prohibited) > 0 {
+		scorer = newReqExclScorer(w, scorer, w.disjunction(prohibited, 1, noCoords))
+	}
+	return scorer, nil
+}
+
+func (w *BooleanWeight) conjunction(scorers []Scorer, coord float32) Scorer {
+	if len(scorers) == 1 && coord == 1 {
+		return scorers[0]
+	}
+	return newConjunctionScorer(w, scorers, coord)
+}
+
+func (w *BooleanWeight) disjunction(scorers []Scorer, minShouldMatch int, coords []float32) Scorer {
+	if len(scorers) == 1 && coords[1] == 1 {
+		return scorers[0]
+	}
+	if len(scorers) == 1 {
+		return newConjunctionScorer(w, scorers, coords[1])
+	}
+	return newDisjunctionSumScorer(w, scorers, minShouldMatch, coords)
+}
+
 func (w *BooleanWeight) IsScoresDocsOutOfOrder() bool {
 	if w.owner.minNrShouldMatch > 1 {
 		// BS2 (in-order) will be used by scorer()
@@ -156,12 +336,23 @@ func (q *BooleanQuery) CreateWeight(searcher *IndexSearcher) (Weight, error) {
 }
 
 func (q *BooleanQuery) Rewrite(reader index.IndexReader) Query {
-	if q.minNrShouldMatch == 0 && len(q.clauses) == 1 {
-		panic("not implemented yet")
+	if q.minNrShouldMatch == 0 && len(q.clauses) == 1 { // optimize 1-clause queries
+		if c := q.clauses[0]; !c.IsProhibited() { // just return clause
+			query := c.query.Rewrite(reader) // rewrite first
+			if q.boost == 1 {
+				return query
+			}
+			if query != c.query {
+				// the rewritten query is ours to boost; queries can't
+				// be cloned, so the original one is left wrapped
+				query.SetBoost(q.boost * query.Boost())
+				return query
+			}
+		}
 	}
 
 	var clone *BooleanQuery // recursively rewrite
-	for _, c := range q.clauses {
+	for i, c := range q.clauses {
 		if query := c.query.Rewrite(reader); query != c.query {
 			// clause rewrote: must clone
 			if clone == nil {
@@ -169,9 +360,9 @@ func (q *BooleanQuery) Rewrite(reader index.IndexReader) Query {
 				// initialize it if a rewritten clause differs from the
 				// original clause (and hasn't been initialized already). If
 				// nothing difers, the clone isn't needlessly created
-				panic("not implemented yet")
+				clone = q.clone()
 			}
-			panic("not implemented yet")
+			clone.clauses[i] = NewBooleanClause(query, c.occur)
 		}
 	}
 	if clone != nil {
@@ -180,6 +371,40 @@ func (q *BooleanQuery) Rewrite(reader index.IndexReader) Query {
 	return q
 }
 
+func (q *BooleanQuery) clone() *BooleanQuery {
+	ans := NewBooleanQueryDisableCoord(q.disableCoord)
+	ans.clauses = make([]*BooleanClause, len(q.clauses))
+	copy(ans.clauses, q.clauses)
+	ans.minNrShouldMatch = q.minNrShouldMatch
+	ans.boost = q.boost
+	return ans
+}
+
+func (q *BooleanQuery) Equals(o Query) bool {
+	other, ok := o.(*BooleanQuery)
+	if !ok || !q.AbstractQuery.Equals(o) || q.disableCoord != other.disableCoord ||
+		q.minNrShouldMatch != other.minNrShouldMatch || len(q.clauses) != len(other.clauses) {
+		return false
+	}
+	for i, clause := range q.clauses {
+		if !clause.Equals(other.clauses[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *BooleanQuery) HashCode() int {
+	hash := q.AbstractQuery.HashCode() ^ q.minNrShouldMatch
+	for _, clause := range q.clauses {
+		hash = 31*hash + clause.HashCode()
+	}
+	if q.disableCoord {
+		hash += 17
+	}
+	return hash
+}
+
 func (q *BooleanQuery) ToString(field string) string {
 	var buf bytes.Buffer
 	needParens := q.Boost() != 1 || q.minNrShouldMatch > 0
@@ -216,11 +441,11 @@ func (q *BooleanQuery) ToString(field string) string {
 	}
 
 	if q.minNrShouldMatch > 0 {
-		panic("not implemented yet")
+		buf.WriteString(fmt.Sprintf("~%v", q.minNrShouldMatch))
 	}
 
 	if q.Boost() != 1 {
-		panic("not implemented yet")
+		buf.WriteString(fmt.Sprintf("^%v", q.boost))
 	}
 
 	return buf.String()
@@ -0,0 +1,377 @@
+package search
+
+import (
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/search/model"
+)
+
+// search/ConjunctionScorer.java
+
+/* Scorer for conjunctions, sets of queries, all of which are required. */
+type conjunctionScorer struct {
+	*abstractScorer
+	scorers []Scorer
+	coord   float32
+	lastDoc int
+}
+
+func newConjunctionScorer(w Weight, scorers []Scorer, coord float32) *conjunctionScorer {
+	assert(len(scorers) > 0)
+	ans := &conjunctionScorer{scorers: scorers, coord: coord, lastDoc: -1}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *conjunctionScorer) DocId() int {
+	return s.lastDoc
+}
+
+func (s *conjunctionScorer) Freq() (int, error) {
+	return len(s.scorers), nil
+}
+
+func (s *conjunctionScorer) NextDoc() (doc int, err error) {
+	if doc, err = s.scorers[0].NextDoc(); err != nil {
+		return 0, err
+	}
+	return s.doNext(doc)
+}
+
+func (s *conjunctionScorer) Advance(target int) (doc int, err error) {
+	if doc, err = s.scorers[0].Advance(target); err != nil {
+		return 0, err
+	}
+	return s.doNext(doc)
+}
+
+/* Moves all scorers to the first doc on or after the lead's doc that all of them match. */
+func (s *conjunctionScorer) doNext(doc int) (int, error) {
+	var err error
+	for doc != NO_MORE_DOCS {
+		found := true
+		for _, other := range s.scorers[1:] {
+			otherDoc := other.DocId()
+			if otherDoc < doc {
+				if otherDoc, err = other.Advance(doc); err != nil {
+					return 0, err
+				}
+			}
+			if otherDoc > doc {
+				// other is beyond: advance the lead, and start over
+				if doc, err = s.scorers[0].Advance(otherDoc); err != nil {
+					return 0, err
+				}
+				found = false
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	s.lastDoc = doc
+	return doc, nil
+}
+
+func (s *conjunctionScorer) Score() (float32, error) {
+	var sum float32
+	for _, scorer := range s.scorers {
+		score, err := scorer.Score()
+		if err != nil {
+			return 0, err
+		}
+		sum += score
+	}
+	return sum * s.coord, nil
+}
+
+func (s *conjunctionScorer) String() string {
+	return fmt.Sprintf("conjunction(%v)", s.weight)
+}
+
+// search/DisjunctionSumScorer.java
+// search/MinShouldMatchSumScorer.java
+
+/*
+A Scorer for OR like queries, counterpart of conjunctionScorer. A doc
+matches if at least minimumNrMatchers of the sub scorers match it;
+its score is the sum of the scores of the matching sub scorers,
+times the coord factor for the number of matchers.
+*/
+type disjunctionSumScorer struct {
+	*abstractScorer
+	subScorers        []Scorer
+	minimumNrMatchers int
+	// indexed by the number of matchers
+	coord      []float32
+	doc        int
+	nrMatchers int
+}
+
+func newDisjunctionSumScorer(w Weight, subScorers []Scorer,
+	minimumNrMatchers int, coord []float32) *disjunctionSumScorer {
+
+	assert2(minimumNrMatchers > 0, "Minimum nr of matchers must be positive")
+	assert2(len(subScorers) > 1, "There must be at least 2 subScorers")
+	ans := &disjunctionSumScorer{
+		subScorers:        subScorers,
+		minimumNrMatchers: minimumNrMatchers,
+		coord:             coord,
+		doc:               -1,
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *disjunctionSumScorer) DocId() int {
+	return s.doc
+}
+
+func (s *disjunctionSumScorer) Freq() (int, error) {
+	return s.nrMatchers, nil
+}
+
+func (s *disjunctionSumScorer) NextDoc() (int, error) {
+	return s.Advance(s.doc + 1)
+}
+
+func (s *disjunctionSumScorer) Advance(target int) (int, error) {
+	for {
+		// move all sub scorers behind target, then find the least doc
+		s.doc, s.nrMatchers = NO_MORE_DOCS, 0
+		for _, sub := range s.subScorers {
+			doc := sub.DocId()
+			if doc < target {
+				var err error
+				if doc, err = sub.Advance(target); err != nil {
+					return 0, err
+				}
+			}
+			if doc < s.doc {
+				s.doc, s.nrMatchers = doc, 1
+			} else if doc == s.doc {
+				s.nrMatchers++
+			}
+		}
+		if s.doc == NO_MORE_DOCS || s.nrMatchers >= s.minimumNrMatchers {
+			return s.doc, nil
+		}
+		target = s.doc + 1
+	}
+}
+
+func (s *disjunctionSumScorer) Score() (float32, error) {
+	var sum float32
+	for _, sub := range s.subScorers {
+		if sub.DocId() == s.doc {
+			score, err := sub.Score()
+			if err != nil {
+				return 0, err
+			}
+			sum += score
+		}
+	}
+	return sum * s.coord[s.nrMatchers], nil
+}
+
+func (s *disjunctionSumScorer) String() string {
+	return fmt.Sprintf("disjunction(%v)", s.weight)
+}
+
+// search/ReqExclScorer.java
+
+/*
+A Scorer for queries with a required subscorer and an excluding
+(prohibited) sub DocIdSetIterator.
+*/
+type reqExclScorer struct {
+	*abstractScorer
+	reqScorer Scorer
+	exclDisi  DocIdSetIterator
+	doc       int
+}
+
+func newReqExclScorer(w Weight, reqScorer Scorer, exclDisi DocIdSetIterator) *reqExclScorer {
+	ans := &reqExclScorer{reqScorer: reqScorer, exclDisi: exclDisi, doc: -1}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *reqExclScorer) DocId() int {
+	return s.doc
+}
+
+func (s *reqExclScorer) Freq() (int, error) {
+	return s.reqScorer.Freq()
+}
+
+func (s *reqExclScorer) NextDoc() (doc int, err error) {
+	if doc, err = s.reqScorer.NextDoc(); err != nil {
+		return 0, err
+	}
+	return s.toNonExcluded(doc)
+}
+
+func (s *reqExclScorer) Advance(target int) (doc int, err error) {
+	if doc, err = s.reqScorer.Advance(target); err != nil {
+		return 0, err
+	}
+	return s.toNonExcluded(doc)
+}
+
+/* Advances the required scorer to the first doc on or after doc that's not excluded. */
+func (s *reqExclScorer) toNonExcluded(doc int) (int, error) {
+	var err error
+	for doc != NO_MORE_DOCS {
+		exclDoc := s.exclDisi.DocId()
+		if exclDoc < doc {
+			if exclDoc, err = s.exclDisi.Advance(doc); err != nil {
+				return 0, err
+			}
+		}
+		if exclDoc != doc {
+			break // not excluded
+		}
+		if doc, err = s.reqScorer.NextDoc(); err != nil {
+			return 0, err
+		}
+	}
+	s.doc = doc
+	return doc, nil
+}
+
+func (s *reqExclScorer) Score() (float32, error) {
+	return s.reqScorer.Score()
+}
+
+func (s *reqExclScorer) String() string {
+	return fmt.Sprintf("reqExcl(%v)", s.weight)
+}
+
+// search/ReqOptSumScorer.java
+
+/*
+A Scorer for queries with a required part and an optional part. The
+optional part only adds to the score; unless it's required itself,
+i.e. when it comes with a minimum number of matchers, in which case
+the docs are the conjunction of both parts.
+
+The score is the sum of both parts, times the coord factor for the
+number of required clauses plus the number of matching optional
+clauses, as both parts score without coord.
+*/
+type reqOptSumScorer struct {
+	*abstractScorer
+	reqScorer   Scorer
+	optScorer   Scorer
+	optRequired bool
+	nrRequired  int
+	// indexed by the number of clauses that match
+	coord []float32
+}
+
+func newReqOptSumScorer(w Weight, reqScorer, optScorer Scorer, optRequired bool,
+	nrRequired int, coord []float32) *reqOptSumScorer {
+
+	ans := &reqOptSumScorer{
+		reqScorer:   reqScorer,
+		optScorer:   optScorer,
+		optRequired: optRequired,
+		nrRequired:  nrRequired,
+		coord:       coord,
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *reqOptSumScorer) DocId() int {
+	return s.reqScorer.DocId()
+}
+
+func (s *reqOptSumScorer) NextDoc() (doc int, err error) {
+	if doc, err = s.reqScorer.NextDoc(); err != nil || !s.optRequired {
+		return
+	}
+	return s.doNext(doc)
+}
+
+func (s *reqOptSumScorer) Advance(target int) (doc int, err error) {
+	if doc, err = s.reqScorer.Advance(target); err != nil || !s.optRequired {
+		return
+	}
+	return s.doNext(doc)
+}
+
+/* Moves both parts to the first doc on or after doc that both match. */
+func (s *reqOptSumScorer) doNext(doc int) (int, error) {
+	var err error
+	for doc != NO_MORE_DOCS {
+		optDoc := s.optScorer.DocId()
+		if optDoc < doc {
+			if optDoc, err = s.optScorer.Advance(doc); err != nil {
+				return 0, err
+			}
+		}
+		if optDoc == doc {
+			break
+		}
+		if doc, err = s.reqScorer.Advance(optDoc); err != nil {
+			return 0, err
+		}
+	}
+	return doc, nil
+}
+
+/* Returns whether the optional part matches the current doc, moving it there if needed. */
+func (s *reqOptSumScorer) optMatches() (bool, error) {
+	doc := s.reqScorer.DocId()
+	optDoc := s.optScorer.DocId()
+	if optDoc < doc {
+		var err error
+		if optDoc, err = s.optScorer.Advance(doc); err != nil {
+			return false, err
+		}
+	}
+	return optDoc == doc, nil
+}
+
+func (s *reqOptSumScorer) Freq() (int, error) {
+	// we might have deferred advance()
+	ok, err := s.optMatches()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 2, nil
+	}
+	return 1, nil
+}
+
+func (s *reqOptSumScorer) Score() (float32, error) {
+	sum, err := s.reqScorer.Score()
+	if err != nil {
+		return 0, err
+	}
+	nrMatchers := s.nrRequired
+	ok, err := s.optMatches()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		score, err := s.optScorer.Score()
+		if err != nil {
+			return 0, err
+		}
+		sum += score
+		if disjunction, ok := s.optScorer.(*disjunctionSumScorer); ok {
+			nrMatchers += disjunction.nrMatchers
+		} else {
+			nrMatchers++
+		}
+	}
+	return sum * s.coord[nrMatchers], nil
+}
+
+func (s *reqOptSumScorer) String() string {
+	return fmt.Sprintf("reqOpt(%v)", s.weight)
+}
@@ -16,6 +16,9 @@ type ScoreDoc struct {
 	Doc int
 	/** Only set by {@link TopDocs#merge} */
 	shardIndex int
+	// Only set by TopFieldCollector: the values of the sort fields,
+	// as reported by FieldComparator.Value().
+	Fields []interface{}
 }
 
 func newScoreDoc(doc int, score float32) *ScoreDoc {
@@ -23,7 +26,7 @@ func newScoreDoc(doc int, score float32) *ScoreDoc {
 }
 
 func newShardedScoreDoc(doc int, score float32, shardIndex int) *ScoreDoc {
-	return &ScoreDoc{score, doc, shardIndex}
+	return &ScoreDoc{score, doc, shardIndex, nil}
 }
 
 func (d *ScoreDoc) String() string {
@@ -185,7 +188,7 @@ func (c *abstractTopDocsCollector) TopDocsRange(start, howMany int) TopDocs {
 	}
 
 	// Get the requested results from pq.
-	c.populateResults(results, howMany)
+	c.TopDocsCreator.populateResults(results, howMany)
 
 	return c.newTopDocs(results, start)
 }
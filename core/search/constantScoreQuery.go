@@ -0,0 +1,143 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/index"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// search/ConstantScoreQuery.java
+
+/*
+A query that wraps another query and simply returns a constant score
+equal to the query boost for every document that matches the query.
+It therefore simply strips off all scores and returns a constant one.
+*/
+type ConstantScoreQuery struct {
+	*AbstractQuery
+	query Query
+}
+
+/*
+Creates a new query that returns a constant score equal to the query
+boost for every document matching the given query.
+*/
+func NewConstantScoreQuery(query Query) *ConstantScoreQuery {
+	assert2(query != nil, "Query may not be nil")
+	ans := &ConstantScoreQuery{query: query}
+	ans.AbstractQuery = NewAbstractQuery(ans)
+	return ans
+}
+
+/* Returns the encapsulated query. */
+func (q *ConstantScoreQuery) Query() Query {
+	return q.query
+}
+
+func (q *ConstantScoreQuery) Rewrite(reader index.IndexReader) Query {
+	if rewritten := q.query.Rewrite(reader); rewritten != q.query {
+		ans := NewConstantScoreQuery(rewritten)
+		ans.boost = q.boost
+		return ans
+	}
+	return q
+}
+
+func (q *ConstantScoreQuery) CreateWeight(ss *IndexSearcher) (Weight, error) {
+	innerWeight, err := ss.createNonScoringWeight(q.query)
+	if err != nil {
+		return nil, err
+	}
+	ans := &constantWeight{owner: q, innerWeight: innerWeight}
+	ans.constantScoreWeight = newConstantScoreWeight(q, ans)
+	return ans, nil
+}
+
+func (q *ConstantScoreQuery) Equals(o Query) bool {
+	other, ok := o.(*ConstantScoreQuery)
+	return ok && q.AbstractQuery.Equals(o) && q.query.Equals(other.query)
+}
+
+func (q *ConstantScoreQuery) HashCode() int {
+	return 31*q.AbstractQuery.HashCode() + q.query.HashCode()
+}
+
+func (q *ConstantScoreQuery) ToString(field string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "ConstantScore(%v)", q.query.ToString(field))
+	if q.boost != 1.0 {
+		buf.WriteString(fmt.Sprintf("^%v", q.boost))
+	}
+	return buf.String()
+}
+
+type constantWeight struct {
+	*constantScoreWeight
+	owner       *ConstantScoreQuery
+	innerWeight Weight
+}
+
+func (w *constantWeight) ValueForNormalization() float32 {
+	// we calculate sumOfSquaredWeights of the inner weight, but ignore it (just to initialize everything)
+	w.innerWeight.ValueForNormalization()
+	return w.constantScoreWeight.ValueForNormalization()
+}
+
+func (w *constantWeight) Normalize(norm float32, topLevelBoost float32) {
+	w.constantScoreWeight.Normalize(norm, topLevelBoost)
+	// we normalize the inner weight, but ignore it (just to initialize everything)
+	w.innerWeight.Normalize(norm, topLevelBoost)
+}
+
+func (w *constantWeight) Scorer(ctx *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+
+	scorer, err := w.innerWeight.Scorer(ctx, acceptDocs)
+	if scorer == nil || err != nil {
+		return nil, err
+	}
+	return newConstantScorer(scorer, w, w.queryWeight), nil
+}
+
+/* Iterates the docs of the wrapped scorer, scoring each with theScore. */
+type constantScorer struct {
+	*abstractScorer
+	docIdSetIterator Scorer
+	theScore         float32
+}
+
+func newConstantScorer(docIdSetIterator Scorer, w Weight, theScore float32) *constantScorer {
+	ans := &constantScorer{
+		docIdSetIterator: docIdSetIterator,
+		theScore:         theScore,
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *constantScorer) DocId() int {
+	return s.docIdSetIterator.DocId()
+}
+
+func (s *constantScorer) NextDoc() (int, error) {
+	return s.docIdSetIterator.NextDoc()
+}
+
+func (s *constantScorer) Advance(target int) (int, error) {
+	return s.docIdSetIterator.Advance(target)
+}
+
+func (s *constantScorer) Score() (float32, error) {
+	assert(s.docIdSetIterator.DocId() != NO_MORE_DOCS)
+	return s.theScore, nil
+}
+
+func (s *constantScorer) Freq() (int, error) {
+	return 1, nil
+}
+
+func (s *constantScorer) String() string {
+	return fmt.Sprintf("scorer(%v)", s.weight)
+}
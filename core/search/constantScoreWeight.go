@@ -0,0 +1,71 @@
+package search
+
+import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/index"
+)
+
+// search/ConstantScoreWeight.java
+
+/*
+A Weight which scores every matching document alike, with the
+query's boost times the query norm, available to scorers as
+queryWeight once normalized. Embedders only provide the Scorer.
+*/
+type constantScoreWeight struct {
+	*WeightImpl
+	query       Query
+	spi         WeightImplSPI
+	queryNorm   float32
+	queryWeight float32
+}
+
+func newConstantScoreWeight(query Query, spi WeightImplSPI) *constantScoreWeight {
+	return &constantScoreWeight{
+		WeightImpl: newWeightImpl(spi),
+		query:      query,
+		spi:        spi,
+	}
+}
+
+func (w *constantScoreWeight) String() string {
+	return fmt.Sprintf("weight(%v)", w.query)
+}
+
+func (w *constantScoreWeight) ValueForNormalization() float32 {
+	w.queryWeight = w.query.Boost()
+	return w.queryWeight * w.queryWeight
+}
+
+func (w *constantScoreWeight) Normalize(norm float32, topLevelBoost float32) {
+	w.queryNorm = norm * topLevelBoost
+	w.queryWeight *= w.queryNorm
+}
+
+func (w *constantScoreWeight) IsScoresDocsOutOfOrder() bool {
+	return false
+}
+
+func (w *constantScoreWeight) Explain(ctx *index.AtomicReaderContext, doc int) (Explanation, error) {
+	scorer, err := w.spi.Scorer(ctx, ctx.Reader().(index.AtomicReader).LiveDocs())
+	if err != nil {
+		return nil, err
+	}
+	if scorer != nil {
+		newDoc, err := scorer.Advance(doc)
+		if err != nil {
+			return nil, err
+		}
+		if newDoc == doc {
+			ans := newComplexExplanation(true, w.queryWeight,
+				fmt.Sprintf("%v, product of:", w.query))
+			ans.details = []Explanation{
+				newExplanation(w.query.Boost(), "boost"),
+				newExplanation(w.queryNorm, "queryNorm"),
+			}
+			return ans, nil
+		}
+	}
+	return newComplexExplanation(false, 0,
+		fmt.Sprintf("%v doesn't match id %v", w.query, doc)), nil
+}
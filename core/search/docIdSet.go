@@ -0,0 +1,98 @@
+package search
+
+import (
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+	"reflect"
+)
+
+// search/DocIdSet.java
+
+/*
+A DocIdSet contains a set of doc ids. Implementing classes must only
+implement Iterator() to provide access to the set.
+*/
+type DocIdSet interface {
+	// Provides a DocIdSetIterator to access the set. This
+	// implementation can return nil if there are no docs that match.
+	Iterator() (DocIdSetIterator, error)
+	// Return the memory usage of this object in bytes.
+	RamBytesUsed() int64
+}
+
+// util/BitDocIdSet.java
+
+var BIT_DOC_ID_SET_BASE_RAM_BYTES_USED = util.ShallowSizeOfInstance(reflect.TypeOf(BitDocIdSet{}))
+
+/* A DocIdSet backed by a bit set. */
+type BitDocIdSet struct {
+	set  *util.OpenBitSet
+	cost int64
+}
+
+/* Wraps set, holding cost set bits. */
+func NewBitDocIdSet(set *util.OpenBitSet, cost int64) *BitDocIdSet {
+	return &BitDocIdSet{set, cost}
+}
+
+func (s *BitDocIdSet) Iterator() (DocIdSetIterator, error) {
+	if s.cost == 0 {
+		return nil, nil
+	}
+	return &bitSetIterator{set: s.set, doc: -1}, nil
+}
+
+/* Returns the number of docs in this set. */
+func (s *BitDocIdSet) Cost() int64 {
+	return s.cost
+}
+
+func (s *BitDocIdSet) RamBytesUsed() int64 {
+	return BIT_DOC_ID_SET_BASE_RAM_BYTES_USED + util.SizeOf(s.set.RealBits())
+}
+
+/*
+Fills a BitDocIdSet with all docs of it, an iterator over docs below
+maxDoc.
+*/
+func newBitDocIdSetOf(it DocIdSetIterator, maxDoc int) (*BitDocIdSet, error) {
+	if maxDoc < 1 {
+		maxDoc = 1
+	}
+	set := util.NewOpenBitSetOf(int64(maxDoc))
+	var cost int64
+	doc, err := it.NextDoc()
+	for ; err == nil && doc != NO_MORE_DOCS; doc, err = it.NextDoc() {
+		set.Set(int64(doc))
+		cost++
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewBitDocIdSet(set, cost), nil
+}
+
+// util/BitSetIterator.java
+
+/* A DocIdSetIterator which iterates over set bits in a bit set. */
+type bitSetIterator struct {
+	set *util.OpenBitSet
+	doc int
+}
+
+func (it *bitSetIterator) DocId() int {
+	return it.doc
+}
+
+func (it *bitSetIterator) NextDoc() (int, error) {
+	return it.Advance(it.doc + 1)
+}
+
+func (it *bitSetIterator) Advance(target int) (int, error) {
+	if next := it.set.NextSetBit(int64(target)); next >= 0 {
+		it.doc = int(next)
+	} else {
+		it.doc = NO_MORE_DOCS
+	}
+	return it.doc, nil
+}
@@ -0,0 +1,248 @@
+package search
+
+import (
+	"bytes"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/index"
+)
+
+// search/FieldComparator.java
+
+/*
+Expert: a FieldComparator compares hits so as to determine their sort
+order when collecting the top results with TopFieldCollector.
+
+Values of competitive hits are copied into slots, numbered from 0 to
+numHits-1. The comparator compares slots among each other, and
+documents of the current segment against the bottom slot, i.e. the
+weakest hit in the queue once it is full.
+
+Comparators report the natural order of their values; reversed sorts
+are handled by the collector.
+*/
+type FieldComparator interface {
+	// Compare hit at slot1 with hit at slot2. Returns a negative value
+	// if slot1 sorts before slot2, a positive one if it sorts after,
+	// and 0 if they are equal.
+	Compare(slot1, slot2 int) int
+	// Set the bottom slot, i.e. the weakest entry in the queue.
+	SetBottom(slot int)
+	// Compare the bottom of the queue with doc of the current segment,
+	// like Compare(bottom, doc) would.
+	CompareBottom(doc int) (int, error)
+	// Copy the value of doc of the current segment into slot.
+	Copy(slot, doc int) error
+	// Set a new segment; all subsequent docIDs are relative to it.
+	SetNextReader(ctx *index.AtomicReaderContext) error
+	// Set the Scorer to use in case a document's score is needed.
+	SetScorer(scorer Scorer)
+	// Return the actual value in the slot.
+	Value(slot int) interface{}
+}
+
+func compareFloat32(a, b float32) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+/* Sorts by descending relevance. */
+type relevanceComparator struct {
+	scores []float32
+	bottom float32
+	scorer Scorer
+}
+
+func newRelevanceComparator(numHits int) *relevanceComparator {
+	return &relevanceComparator{scores: make([]float32, numHits)}
+}
+
+func (c *relevanceComparator) Compare(slot1, slot2 int) int {
+	return compareFloat32(c.scores[slot2], c.scores[slot1])
+}
+
+func (c *relevanceComparator) SetBottom(slot int) {
+	c.bottom = c.scores[slot]
+}
+
+func (c *relevanceComparator) CompareBottom(doc int) (int, error) {
+	score, err := c.scorer.Score()
+	if err != nil {
+		return 0, err
+	}
+	return compareFloat32(score, c.bottom), nil
+}
+
+func (c *relevanceComparator) Copy(slot, doc int) (err error) {
+	c.scores[slot], err = c.scorer.Score()
+	return
+}
+
+func (c *relevanceComparator) SetNextReader(ctx *index.AtomicReaderContext) error {
+	return nil
+}
+
+func (c *relevanceComparator) SetScorer(scorer Scorer) {
+	c.scorer = scorer
+}
+
+func (c *relevanceComparator) Value(slot int) interface{} {
+	return c.scores[slot]
+}
+
+/* Sorts by ascending docID. */
+type docComparator struct {
+	docIDs  []int
+	docBase int
+	bottom  int
+}
+
+func newDocComparator(numHits int) *docComparator {
+	return &docComparator{docIDs: make([]int, numHits)}
+}
+
+func (c *docComparator) Compare(slot1, slot2 int) int {
+	return c.docIDs[slot1] - c.docIDs[slot2]
+}
+
+func (c *docComparator) SetBottom(slot int) {
+	c.bottom = c.docIDs[slot]
+}
+
+func (c *docComparator) CompareBottom(doc int) (int, error) {
+	return c.bottom - (c.docBase + doc), nil
+}
+
+func (c *docComparator) Copy(slot, doc int) error {
+	c.docIDs[slot] = c.docBase + doc
+	return nil
+}
+
+func (c *docComparator) SetNextReader(ctx *index.AtomicReaderContext) error {
+	c.docBase = ctx.DocBase
+	return nil
+}
+
+func (c *docComparator) SetScorer(scorer Scorer) {}
+
+func (c *docComparator) Value(slot int) interface{} {
+	return c.docIDs[slot]
+}
+
+/* Sorts by ascending NumericDocValues. */
+type longComparator struct {
+	field         string
+	values        []int64
+	bottom        int64
+	currentValues NumericDocValues
+}
+
+func newLongComparator(numHits int, field string) *longComparator {
+	return &longComparator{
+		field:  field,
+		values: make([]int64, numHits),
+	}
+}
+
+func (c *longComparator) value(doc int) int64 {
+	if c.currentValues == nil {
+		return 0 // no value in this segment
+	}
+	return c.currentValues(doc)
+}
+
+func (c *longComparator) Compare(slot1, slot2 int) int {
+	return compareInt64(c.values[slot1], c.values[slot2])
+}
+
+func (c *longComparator) SetBottom(slot int) {
+	c.bottom = c.values[slot]
+}
+
+func (c *longComparator) CompareBottom(doc int) (int, error) {
+	return compareInt64(c.bottom, c.value(doc)), nil
+}
+
+func (c *longComparator) Copy(slot, doc int) error {
+	c.values[slot] = c.value(doc)
+	return nil
+}
+
+func (c *longComparator) SetNextReader(ctx *index.AtomicReaderContext) (err error) {
+	c.currentValues, err = ctx.Reader().(index.AtomicReader).NumericDocValues(c.field)
+	return
+}
+
+func (c *longComparator) SetScorer(scorer Scorer) {}
+
+func (c *longComparator) Value(slot int) interface{} {
+	return c.values[slot]
+}
+
+/*
+Sorts by ascending SortedDocValues, comparing the actual terms since
+ords of different segments can't be compared.
+*/
+type termValComparator struct {
+	field         string
+	values        [][]byte
+	bottom        []byte
+	currentValues SortedDocValues
+}
+
+func newTermValComparator(numHits int, field string) *termValComparator {
+	return &termValComparator{
+		field:  field,
+		values: make([][]byte, numHits),
+	}
+}
+
+func (c *termValComparator) value(doc int) []byte {
+	if c.currentValues == nil {
+		return nil // no value in this segment
+	}
+	return c.currentValues.Get(doc)
+}
+
+func (c *termValComparator) Compare(slot1, slot2 int) int {
+	return bytes.Compare(c.values[slot1], c.values[slot2])
+}
+
+func (c *termValComparator) SetBottom(slot int) {
+	c.bottom = c.values[slot]
+}
+
+func (c *termValComparator) CompareBottom(doc int) (int, error) {
+	return bytes.Compare(c.bottom, c.value(doc)), nil
+}
+
+func (c *termValComparator) Copy(slot, doc int) error {
+	// the returned bytes may be reused by the doc values
+	c.values[slot] = append(c.values[slot][:0], c.value(doc)...)
+	return nil
+}
+
+func (c *termValComparator) SetNextReader(ctx *index.AtomicReaderContext) (err error) {
+	c.currentValues, err = ctx.Reader().(index.AtomicReader).SortedDocValues(c.field)
+	return
+}
+
+func (c *termValComparator) SetScorer(scorer Scorer) {}
+
+func (c *termValComparator) Value(slot int) interface{} {
+	return c.values[slot]
+}
@@ -0,0 +1,106 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/index"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// search/MatchAllDocsQuery.java
+
+/* A query that matches all documents. */
+type MatchAllDocsQuery struct {
+	*AbstractQuery
+}
+
+func NewMatchAllDocsQuery() *MatchAllDocsQuery {
+	ans := new(MatchAllDocsQuery)
+	ans.AbstractQuery = NewAbstractQuery(ans)
+	return ans
+}
+
+func (q *MatchAllDocsQuery) CreateWeight(ss *IndexSearcher) (Weight, error) {
+	ans := &matchAllDocsWeight{owner: q}
+	ans.constantScoreWeight = newConstantScoreWeight(q, ans)
+	return ans, nil
+}
+
+func (q *MatchAllDocsQuery) ToString(field string) string {
+	var buf bytes.Buffer
+	buf.WriteString("*:*")
+	if q.boost != 1.0 {
+		buf.WriteString(fmt.Sprintf("^%v", q.boost))
+	}
+	return buf.String()
+}
+
+type matchAllDocsWeight struct {
+	*constantScoreWeight
+	owner *MatchAllDocsQuery
+}
+
+func (w *matchAllDocsWeight) Scorer(ctx *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+	return newMatchAllScorer(w, ctx.Reader().MaxDoc(), acceptDocs, w.queryWeight), nil
+}
+
+func (w *matchAllDocsWeight) Explain(ctx *index.AtomicReaderContext, doc int) (Explanation, error) {
+	// explain query weight
+	ans := newComplexExplanation(true, w.queryWeight, "MatchAllDocsQuery, product of:")
+	if w.owner.boost != 1.0 {
+		ans.details = append(ans.details, newExplanation(w.owner.boost, "boost"))
+	}
+	ans.details = append(ans.details, newExplanation(w.queryNorm, "queryNorm"))
+	return ans, nil
+}
+
+type matchAllScorer struct {
+	*abstractScorer
+	score    float32
+	doc      int
+	maxDoc   int
+	liveDocs util.Bits
+}
+
+func newMatchAllScorer(w Weight, maxDoc int, liveDocs util.Bits, score float32) *matchAllScorer {
+	ans := &matchAllScorer{
+		score:    score,
+		doc:      -1,
+		maxDoc:   maxDoc,
+		liveDocs: liveDocs,
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *matchAllScorer) DocId() int {
+	return s.doc
+}
+
+func (s *matchAllScorer) NextDoc() (int, error) {
+	return s.Advance(s.doc + 1)
+}
+
+func (s *matchAllScorer) Advance(target int) (int, error) {
+	for s.doc = target; s.doc < s.maxDoc; s.doc++ {
+		if s.liveDocs == nil || s.liveDocs.At(s.doc) {
+			return s.doc, nil
+		}
+	}
+	s.doc = NO_MORE_DOCS
+	return s.doc, nil
+}
+
+func (s *matchAllScorer) Score() (float32, error) {
+	return s.score, nil
+}
+
+func (s *matchAllScorer) Freq() (int, error) {
+	return 1, nil
+}
+
+func (s *matchAllScorer) String() string {
+	return fmt.Sprintf("scorer(%v)", s.weight)
+}
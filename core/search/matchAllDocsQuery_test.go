@@ -0,0 +1,37 @@
+package search
+
+import (
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"reflect"
+	"testing"
+)
+
+func TestMatchAllScorerSkipsDeletedDocs(t *testing.T) {
+	liveDocs := boolBits{true, false, true, true, false}
+	s := newMatchAllScorer(nil, len(liveDocs), liveDocs, 1.5)
+	var docs []int
+	for {
+		doc, err := s.NextDoc()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == NO_MORE_DOCS {
+			break
+		}
+		if score, _ := s.Score(); score != 1.5 {
+			t.Errorf("Expected score 1.5, got %v", score)
+		}
+		docs = append(docs, doc)
+	}
+	if !reflect.DeepEqual(docs, []int{0, 2, 3}) {
+		t.Errorf("Expected [0 2 3], got %v", docs)
+	}
+
+	s = newMatchAllScorer(nil, len(liveDocs), liveDocs, 1)
+	if doc, _ := s.Advance(1); doc != 2 {
+		t.Errorf("Expected to advance to 2, got %v", doc)
+	}
+	if doc, _ := s.Advance(4); doc != NO_MORE_DOCS {
+		t.Errorf("Expected no more docs, got %v", doc)
+	}
+}
@@ -0,0 +1,218 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/balzaczyy/golucene/core/codec/spi"
+	"github.com/balzaczyy/golucene/core/index"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+)
+
+// search/NumericRangeQuery.java
+
+/*
+A Query that matches documents whose numeric doc value of a field is
+within the specified range. Every matching document gets a constant
+score equal to the query boost.
+
+For now the matches are found by a linear scan over the
+NumericDocValues of each segment.
+
+NOTE: NumericDocValues report 0 for documents without a value, so
+such documents match whenever 0 is within the range.
+*/
+type NumericRangeQuery struct {
+	*AbstractQuery
+	field                      string
+	min, max                   int64
+	minInclusive, maxInclusive bool
+}
+
+/*
+Creates a query matching values between min and max. Use
+math.MinInt64 or math.MaxInt64 (inclusive) for an open-ended range.
+*/
+func NewNumericRangeQuery(field string, min, max int64,
+	minInclusive, maxInclusive bool) *NumericRangeQuery {
+
+	ans := &NumericRangeQuery{
+		field:        field,
+		min:          min,
+		max:          max,
+		minInclusive: minInclusive,
+		maxInclusive: maxInclusive,
+	}
+	ans.AbstractQuery = NewAbstractQuery(ans)
+	return ans
+}
+
+/* Returns the field name for this query */
+func (q *NumericRangeQuery) Field() string { return q.field }
+
+/* Returns the lower value of this range query */
+func (q *NumericRangeQuery) Min() int64 { return q.min }
+
+/* Returns the upper value of this range query */
+func (q *NumericRangeQuery) Max() int64 { return q.max }
+
+/* Returns true if the lower endpoint is inclusive */
+func (q *NumericRangeQuery) IncludesMin() bool { return q.minInclusive }
+
+/* Returns true if the upper endpoint is inclusive */
+func (q *NumericRangeQuery) IncludesMax() bool { return q.maxInclusive }
+
+/*
+Returns the inclusive bounds of the range, or ok=false if the range
+can't match any value.
+*/
+func (q *NumericRangeQuery) bounds() (lower, upper int64, ok bool) {
+	lower, upper = q.min, q.max
+	if !q.minInclusive {
+		if lower == math.MaxInt64 {
+			return 0, 0, false
+		}
+		lower++
+	}
+	if !q.maxInclusive {
+		if upper == math.MinInt64 {
+			return 0, 0, false
+		}
+		upper--
+	}
+	return lower, upper, lower <= upper
+}
+
+func (q *NumericRangeQuery) CreateWeight(ss *IndexSearcher) (Weight, error) {
+	ans := &numericRangeWeight{owner: q}
+	ans.constantScoreWeight = newConstantScoreWeight(q, ans)
+	return ans, nil
+}
+
+func (q *NumericRangeQuery) Equals(o Query) bool {
+	other, ok := o.(*NumericRangeQuery)
+	return ok && q.AbstractQuery.Equals(o) && q.field == other.field &&
+		q.min == other.min && q.max == other.max &&
+		q.minInclusive == other.minInclusive && q.maxInclusive == other.maxInclusive
+}
+
+func (q *NumericRangeQuery) HashCode() int {
+	hash := q.AbstractQuery.HashCode()
+	hash += stringHashCode(q.field) ^ 0x4565fd66
+	hash += int(q.min^(q.min>>32)) ^ 0x733fa5fe
+	hash += int(q.max^(q.max>>32)) ^ 0x14fa55fb
+	if q.minInclusive {
+		hash += 0x14fa55fb
+	}
+	if q.maxInclusive {
+		hash += 0x733fa5fe
+	}
+	return hash
+}
+
+func (q *NumericRangeQuery) ToString(field string) string {
+	var buf bytes.Buffer
+	if q.field != field {
+		buf.WriteString(q.field)
+		buf.WriteRune(':')
+	}
+	if q.minInclusive {
+		buf.WriteRune('[')
+	} else {
+		buf.WriteRune('{')
+	}
+	fmt.Fprintf(&buf, "%v TO %v", q.min, q.max)
+	if q.maxInclusive {
+		buf.WriteRune(']')
+	} else {
+		buf.WriteRune('}')
+	}
+	if q.boost != 1.0 {
+		buf.WriteString(fmt.Sprintf("^%v", q.boost))
+	}
+	return buf.String()
+}
+
+type numericRangeWeight struct {
+	*constantScoreWeight
+	owner *NumericRangeQuery
+}
+
+func (w *numericRangeWeight) Scorer(ctx *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+
+	lower, upper, ok := w.owner.bounds()
+	if !ok {
+		return nil, nil
+	}
+	reader := ctx.Reader().(index.AtomicReader)
+	values, err := reader.NumericDocValues(w.owner.field)
+	if values == nil || err != nil {
+		return nil, err
+	}
+	return newNumericRangeScorer(w, values, reader.MaxDoc(),
+		lower, upper, acceptDocs, w.queryWeight), nil
+}
+
+/* Scans the doc values of a segment for values within [lower, upper]. */
+type numericRangeScorer struct {
+	*abstractScorer
+	values       NumericDocValues
+	maxDoc       int
+	lower, upper int64
+	acceptDocs   util.Bits
+	score        float32
+	doc          int
+}
+
+func newNumericRangeScorer(w Weight, values NumericDocValues, maxDoc int,
+	lower, upper int64, acceptDocs util.Bits, score float32) *numericRangeScorer {
+
+	ans := &numericRangeScorer{
+		values:     values,
+		maxDoc:     maxDoc,
+		lower:      lower,
+		upper:      upper,
+		acceptDocs: acceptDocs,
+		score:      score,
+		doc:        -1,
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *numericRangeScorer) DocId() int {
+	return s.doc
+}
+
+func (s *numericRangeScorer) Freq() (int, error) {
+	return 1, nil
+}
+
+func (s *numericRangeScorer) NextDoc() (int, error) {
+	return s.Advance(s.doc + 1)
+}
+
+func (s *numericRangeScorer) Advance(target int) (int, error) {
+	for doc := target; doc < s.maxDoc; doc++ {
+		if s.acceptDocs != nil && !s.acceptDocs.At(doc) {
+			continue
+		}
+		if v := s.values(doc); v >= s.lower && v <= s.upper {
+			s.doc = doc
+			return doc, nil
+		}
+	}
+	s.doc = NO_MORE_DOCS
+	return s.doc, nil
+}
+
+func (s *numericRangeScorer) Score() (float32, error) {
+	assert(s.doc != NO_MORE_DOCS)
+	return s.score, nil
+}
+
+func (s *numericRangeScorer) String() string {
+	return fmt.Sprintf("scorer(%v)", s.weight)
+}
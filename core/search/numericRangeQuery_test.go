@@ -0,0 +1,83 @@
+package search
+
+import (
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// Returns the docs matched by q over the given per-doc values.
+func numericRangeMatches(t *testing.T, q *NumericRangeQuery,
+	values []int64, acceptDocs util.Bits) []int {
+
+	lower, upper, ok := q.bounds()
+	if !ok {
+		return nil
+	}
+	s := newNumericRangeScorer(nil, func(docID int) int64 { return values[docID] },
+		len(values), lower, upper, acceptDocs, 1)
+	var docs []int
+	for {
+		doc, err := s.NextDoc()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc == NO_MORE_DOCS {
+			return docs
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func TestNumericRangeQuery(t *testing.T) {
+	values := []int64{5, -3, 10, 7, 0, 10, math.MaxInt64, math.MinInt64}
+	for _, v := range []struct {
+		min, max                   int64
+		minInclusive, maxInclusive bool
+		expected                   []int
+	}{
+		{5, 10, true, true, []int{0, 2, 3, 5}},
+		{5, 10, false, true, []int{2, 3, 5}},
+		{5, 10, true, false, []int{0, 3}},
+		{5, 10, false, false, []int{3}},
+		{-3, 0, true, true, []int{1, 4}},
+		{math.MinInt64, math.MaxInt64, true, true, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{math.MinInt64, math.MaxInt64, false, false, []int{0, 1, 2, 3, 4, 5}},
+		// empty ranges
+		{5, 5, false, true, nil},
+		{5, 6, false, false, nil},
+		{10, 5, true, true, nil},
+		{math.MaxInt64, math.MaxInt64, false, true, nil},
+		{math.MinInt64, math.MinInt64, true, false, nil},
+		{1, 4, true, true, nil},
+	} {
+		q := NewNumericRangeQuery("f", v.min, v.max, v.minInclusive, v.maxInclusive)
+		if docs := numericRangeMatches(t, q, values, nil); !reflect.DeepEqual(docs, v.expected) {
+			t.Errorf("%v: expected %v, got %v", q, v.expected, docs)
+		}
+	}
+}
+
+type boolBits []bool
+
+func (b boolBits) At(index int) bool { return b[index] }
+func (b boolBits) Length() int       { return len(b) }
+
+func TestNumericRangeQueryAcceptDocs(t *testing.T) {
+	values := []int64{1, 2, 3, 4}
+	acceptDocs := boolBits{false, true, false, true}
+	q := NewNumericRangeQuery("f", 2, 3, true, true)
+	if docs := numericRangeMatches(t, q, values, acceptDocs); !reflect.DeepEqual(docs, []int{1}) {
+		t.Errorf("Expected [1], got %v", docs)
+	}
+}
+
+func TestNumericRangeQueryToString(t *testing.T) {
+	q := NewNumericRangeQuery("price", 1, 10, true, false)
+	assertEquals(t, "price:[1 TO 10}", q.ToString(""))
+	assertEquals(t, "[1 TO 10}", q.ToString("price"))
+	q.SetBoost(2)
+	assertEquals(t, "price:[1 TO 10}^2", q.ToString(""))
+}
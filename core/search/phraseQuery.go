@@ -0,0 +1,520 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/index"
+	. "github.com/balzaczyy/golucene/core/index/model"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+	"reflect"
+	"sort"
+)
+
+// search/PhraseQuery.java
+
+/*
+A Query that matches documents containing a particular sequence of
+terms. A PhraseQuery is built by QueryParser for input like "new york".
+
+This query may be combined with other terms or queries with a
+BooleanQuery.
+*/
+type PhraseQuery struct {
+	*AbstractQuery
+	field       string
+	terms       []*index.Term
+	positions   []int
+	maxPosition int
+	slop        int
+}
+
+/* Constructs an empty phrase query. */
+func NewPhraseQuery() *PhraseQuery {
+	ans := &PhraseQuery{}
+	ans.AbstractQuery = NewAbstractQuery(ans)
+	return ans
+}
+
+/*
+Sets the number of other words permitted between words in query
+phrase. If zero, then this is an exact phrase search. For larger
+values this works like a WITHIN or NEAR operator.
+
+The slop is in fact an edit-distance, where the units correspond to
+moves of terms in the query phrase out of position. For example, to
+switch the order of two words requires two moves (the first move
+places the words atop one another), so to permit re-orderings of
+phrases, the slop must be at least two.
+
+More exact matches are scored higher than sloppier matches, thus
+search results are sorted by exactness.
+
+The slop is zero by default, requiring exact matches.
+*/
+func (q *PhraseQuery) SetSlop(s int) {
+	assert2(s >= 0, "slop value cannot be negative")
+	q.slop = s
+}
+
+func (q *PhraseQuery) Slop() int { return q.slop }
+
+/*
+Adds a term to the end of the query phrase. The relative position of
+the term is the one immediately after the last term added.
+*/
+func (q *PhraseQuery) Add(term *index.Term) {
+	position := 0
+	if n := len(q.positions); n > 0 {
+		position = q.positions[n-1] + 1
+	}
+	q.AddAt(term, position)
+}
+
+/*
+Adds a term to the end of the query phrase. The relative position of
+the term within the phrase is specified explicitly. This allows e.g.
+phrases with more than one term at the same position or phrases with
+gaps (e.g. in connection with stopwords).
+*/
+func (q *PhraseQuery) AddAt(term *index.Term, position int) {
+	if len(q.terms) == 0 {
+		q.field = term.Field
+	} else {
+		assert2(term.Field == q.field,
+			"All phrase terms must be in the same field: %v", term)
+	}
+	q.terms = append(q.terms, term)
+	q.positions = append(q.positions, position)
+	if position > q.maxPosition {
+		q.maxPosition = position
+	}
+}
+
+/* Returns the set of terms in this phrase. */
+func (q *PhraseQuery) Terms() []*index.Term {
+	return q.terms
+}
+
+/* Returns the relative positions of terms in this phrase. */
+func (q *PhraseQuery) Positions() []int {
+	return q.positions
+}
+
+func (q *PhraseQuery) Rewrite(reader index.IndexReader) Query {
+	if len(q.terms) == 1 {
+		tq := NewTermQuery(q.terms[0])
+		tq.SetBoost(q.boost)
+		return tq
+	}
+	return q
+}
+
+func (q *PhraseQuery) CreateWeight(ss *IndexSearcher) (w Weight, err error) {
+	return newPhraseWeight(q, ss)
+}
+
+func (q *PhraseQuery) Equals(o Query) bool {
+	other, ok := o.(*PhraseQuery)
+	if !ok || !q.AbstractQuery.Equals(o) || q.slop != other.slop ||
+		len(q.terms) != len(other.terms) {
+		return false
+	}
+	for i, term := range q.terms {
+		if !termEquals(term, other.terms[i]) || q.positions[i] != other.positions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *PhraseQuery) HashCode() int {
+	hash := q.AbstractQuery.HashCode() ^ q.slop
+	for i, term := range q.terms {
+		hash = 31*hash + termHashCode(term)
+		hash = 31*hash + q.positions[i]
+	}
+	return hash
+}
+
+func (q *PhraseQuery) ToString(f string) string {
+	var buf bytes.Buffer
+	if q.field != "" && q.field != f {
+		buf.WriteString(q.field)
+		buf.WriteRune(':')
+	}
+
+	buf.WriteRune('"')
+	pieces := make([]string, q.maxPosition+1)
+	for i, term := range q.terms {
+		pos := q.positions[i]
+		if s := pieces[pos]; s != "" {
+			pieces[pos] = s + "|" + string(term.Bytes)
+		} else {
+			pieces[pos] = string(term.Bytes)
+		}
+	}
+	for i, s := range pieces {
+		if i > 0 {
+			buf.WriteRune(' ')
+		}
+		if s == "" {
+			buf.WriteRune('?')
+		} else {
+			buf.WriteString(s)
+		}
+	}
+	buf.WriteRune('"')
+
+	if q.slop != 0 {
+		buf.WriteString(fmt.Sprintf("~%v", q.slop))
+	}
+	if q.boost != 1.0 {
+		buf.WriteString(fmt.Sprintf("^%v", q.boost))
+	}
+	return buf.String()
+}
+
+type PhraseWeight struct {
+	*WeightImpl
+	*PhraseQuery
+	similarity Similarity
+	stats      SimWeight
+	states     []*index.TermContext
+}
+
+func newPhraseWeight(owner *PhraseQuery, ss *IndexSearcher) (*PhraseWeight, error) {
+	ctx := ss.TopReaderContext()
+	states := make([]*index.TermContext, len(owner.terms))
+	termStats := make([]TermStatistics, len(owner.terms))
+	for i, term := range owner.terms {
+		state, err := index.NewTermContextFromTerm(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+		states[i] = state
+		termStats[i] = ss.TermStatistics(term, state)
+	}
+	sim := ss.similarity
+	ans := &PhraseWeight{
+		PhraseQuery: owner,
+		similarity:  sim,
+		stats:       sim.computeWeight(owner.boost, ss.CollectionStatistics(owner.field), termStats...),
+		states:      states,
+	}
+	ans.WeightImpl = newWeightImpl(ans)
+	return ans, nil
+}
+
+func (w *PhraseWeight) String() string {
+	return fmt.Sprintf("weight(%v)", w.PhraseQuery)
+}
+
+func (w *PhraseWeight) ValueForNormalization() float32 {
+	return w.stats.ValueForNormalization()
+}
+
+func (w *PhraseWeight) Normalize(norm float32, topLevelBoost float32) {
+	w.stats.Normalize(norm, topLevelBoost)
+}
+
+func (w *PhraseWeight) IsScoresDocsOutOfOrder() bool {
+	return false
+}
+
+func (w *PhraseWeight) Scorer(context *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+
+	assert(len(w.terms) > 0)
+	reader := context.Reader().(index.AtomicReader)
+	fieldTerms := reader.Terms(w.field)
+	if fieldTerms == nil {
+		return nil, nil
+	}
+
+	// reuse single TermsEnum below:
+	te := fieldTerms.Iterator(nil)
+	postings := make([]*phrasePositions, len(w.terms))
+	for i, term := range w.terms {
+		state := w.states[i].State(context.Ord)
+		if state == nil { // term is not present in that reader
+			return nil, nil
+		}
+		if err := te.SeekExactFromLast(term.Bytes, state); err != nil {
+			return nil, err
+		}
+		docFreq, err := te.DocFreq()
+		if err != nil {
+			return nil, err
+		}
+		postingsEnum, err := te.DocsAndPositionsByFlags(acceptDocs, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		// postingsEnum is nil if the field was indexed without
+		// positions, as the term was found above
+		if postingsEnum == nil {
+			return nil, errors.New(fmt.Sprintf(
+				"field '%v' was indexed without position data; cannot run PhraseQuery (term=%v)",
+				term.Field, string(term.Bytes)))
+		}
+		postings[i] = &phrasePositions{
+			postings: postingsEnum,
+			docFreq:  docFreq,
+			offset:   w.positions[i],
+			term:     term.Bytes,
+		}
+	}
+
+	simScorer, err := w.similarity.simScorer(w.stats, context)
+	if err != nil {
+		return nil, err
+	}
+	return newPhraseScorer(w, postings, w.slop, simScorer), nil
+}
+
+func (w *PhraseWeight) Explain(ctx *index.AtomicReaderContext, doc int) (Explanation, error) {
+	scorer, err := w.Scorer(ctx, ctx.Reader().(index.AtomicReader).LiveDocs())
+	if err != nil {
+		return nil, err
+	}
+	if scorer != nil {
+		newDoc, err := scorer.Advance(doc)
+		if err != nil {
+			return nil, err
+		}
+		if newDoc == doc {
+			freq := scorer.(*PhraseScorer).sloppyFreq
+			docScorer, err := w.similarity.simScorer(w.stats, ctx)
+			if err != nil {
+				return nil, err
+			}
+			scoreExplanation := docScorer.explain(doc,
+				newExplanation(freq, fmt.Sprintf("phraseFreq=%v", freq)))
+			ans := newComplexExplanation(true,
+				scoreExplanation.(*ExplanationImpl).value,
+				fmt.Sprintf("weight(%v in %v) [%v], result of:",
+					w.PhraseQuery, doc, reflect.TypeOf(w.similarity)))
+			ans.details = []Explanation{scoreExplanation}
+			return ans, nil
+		}
+	}
+	return newComplexExplanation(false, 0, "no matching term"), nil
+}
+
+// search/PhrasePositions.java
+
+/* Position of a term in a document that takes into account the term offset within the phrase. */
+type phrasePositions struct {
+	postings DocsAndPositionsEnum
+	docFreq  int
+	// position of the term within the phrase
+	offset int
+	term   []byte
+	// positions in the current doc, minus offset
+	positions []int
+	upto      int
+}
+
+/* Reads all positions of the current doc, shifted by the offset. */
+func (pp *phrasePositions) load() error {
+	freq, err := pp.postings.Freq()
+	if err != nil {
+		return err
+	}
+	pp.positions = pp.positions[:0]
+	for i := 0; i < freq; i++ {
+		pos, err := pp.postings.NextPosition()
+		if err != nil {
+			return err
+		}
+		pp.positions = append(pp.positions, pos-pp.offset)
+	}
+	pp.upto = 0
+	return nil
+}
+
+type byDocFreq []*phrasePositions
+
+func (a byDocFreq) Len() int           { return len(a) }
+func (a byDocFreq) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byDocFreq) Less(i, j int) bool { return a[i].docFreq < a[j].docFreq }
+
+// search/ExactPhraseScorer.java
+// search/SloppyPhraseScorer.java
+
+/*
+Scores documents matching a PhraseQuery. Docs are found by
+intersecting the postings of all terms, rarest first; for each doc
+in the intersection, the positions of all terms are then walked in
+order, and every window where the terms are within slop of their
+positions in the phrase counts as a match.
+
+An exact phrase (slop 0) only matches when all terms are at their
+phrase positions, and each match counts as one.
+*/
+type PhraseScorer struct {
+	*abstractScorer
+	postings   []*phrasePositions
+	slop       int
+	docScorer  SimScorer
+	doc        int
+	numMatches int
+	sloppyFreq float32
+	// whether two terms of the phrase are the same
+	hasRepeats bool
+}
+
+func newPhraseScorer(w Weight, postings []*phrasePositions,
+	slop int, docScorer SimScorer) *PhraseScorer {
+
+	sorted := make([]*phrasePositions, len(postings))
+	copy(sorted, postings)
+	sort.Stable(byDocFreq(sorted))
+
+	ans := &PhraseScorer{
+		postings:  sorted,
+		slop:      slop,
+		docScorer: docScorer,
+		doc:       -1,
+	}
+	for i, pp := range postings {
+		for _, pp2 := range postings[:i] {
+			if bytes.Equal(pp.term, pp2.term) {
+				ans.hasRepeats = true
+			}
+		}
+	}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *PhraseScorer) DocId() int {
+	return s.doc
+}
+
+func (s *PhraseScorer) Freq() (int, error) {
+	return s.numMatches, nil
+}
+
+func (s *PhraseScorer) NextDoc() (int, error) {
+	doc, err := s.postings[0].postings.NextDoc()
+	if err != nil {
+		return 0, err
+	}
+	return s.doNext(doc)
+}
+
+func (s *PhraseScorer) Advance(target int) (int, error) {
+	doc, err := s.postings[0].postings.Advance(target)
+	if err != nil {
+		return 0, err
+	}
+	return s.doNext(doc)
+}
+
+/* Moves all postings to the first doc on or after the lead's doc that contains the phrase. */
+func (s *PhraseScorer) doNext(doc int) (int, error) {
+	var err error
+	for doc != NO_MORE_DOCS {
+		// find a doc which has all terms
+		found := true
+		for _, pp := range s.postings[1:] {
+			other := pp.postings.DocId()
+			if other < doc {
+				if other, err = pp.postings.Advance(doc); err != nil {
+					return 0, err
+				}
+			}
+			if other > doc {
+				// other is beyond: advance the lead, and start over
+				if doc, err = s.postings[0].postings.Advance(other); err != nil {
+					return 0, err
+				}
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		// all terms are on doc: check positions
+		if err = s.phraseFreq(); err != nil {
+			return 0, err
+		}
+		if s.numMatches > 0 {
+			s.doc = doc
+			return doc, nil
+		}
+		if doc, err = s.postings[0].postings.NextDoc(); err != nil {
+			return 0, err
+		}
+	}
+	s.doc = NO_MORE_DOCS
+	return s.doc, nil
+}
+
+/*
+Walks the positions of all terms in the current doc. At every step
+the window spans from the smallest to the largest shifted position;
+it's a match when its length is within slop, then the term on the
+smallest position moves on, until one runs out of positions.
+*/
+func (s *PhraseScorer) phraseFreq() error {
+	for _, pp := range s.postings {
+		if err := pp.load(); err != nil {
+			return err
+		}
+	}
+
+	s.numMatches, s.sloppyFreq = 0, 0
+	for {
+		var first *phrasePositions
+		end := 0
+		for i, pp := range s.postings {
+			pos := pp.positions[pp.upto]
+			if i == 0 || pos < first.positions[first.upto] {
+				first = pp
+			}
+			if i == 0 || pos > end {
+				end = pos
+			}
+		}
+
+		if matchLength := end - first.positions[first.upto]; matchLength <= s.slop && !s.collides() {
+			s.numMatches++
+			s.sloppyFreq += s.docScorer.computeSlopFactor(matchLength)
+		}
+
+		if first.upto++; first.upto == len(first.positions) {
+			return nil
+		}
+	}
+}
+
+/* Returns whether two repeated terms of the phrase stand on the same position in the doc. */
+func (s *PhraseScorer) collides() bool {
+	if !s.hasRepeats {
+		return false
+	}
+	for i, pp := range s.postings {
+		for _, pp2 := range s.postings[:i] {
+			if bytes.Equal(pp.term, pp2.term) &&
+				pp.positions[pp.upto]+pp.offset == pp2.positions[pp2.upto]+pp2.offset {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *PhraseScorer) Score() (float32, error) {
+	assert(s.doc != NO_MORE_DOCS)
+	return s.docScorer.Score(s.doc, s.sloppyFreq), nil
+}
+
+func (s *PhraseScorer) String() string {
+	return fmt.Sprintf("scorer(%v)", s.weight)
+}
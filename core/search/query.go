@@ -1,8 +1,11 @@
 package search
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/balzaczyy/golucene/core/index"
+	"math"
+	"reflect"
 )
 
 // search/Query.java
@@ -16,6 +19,12 @@ type Query interface {
 	QuerySPI
 	CreateWeight(ss *IndexSearcher) (w Weight, err error)
 	Rewrite(r index.IndexReader) Query
+	// Returns true if o is a query of the same type, with the same
+	// boost, matching the same documents. Together with HashCode(),
+	// it's what a QueryCache keys on.
+	Equals(o Query) bool
+	// Returns a hash code consistent with Equals().
+	HashCode() int
 }
 
 type QuerySPI interface {
@@ -48,3 +57,39 @@ func (q *AbstractQuery) CreateWeight(ss *IndexSearcher) (w Weight, err error) {
 func (q *AbstractQuery) Rewrite(r index.IndexReader) Query {
 	return q.value
 }
+
+/*
+Compares the type and the boost only, which is enough for queries
+without parameters. Other queries compare their own fields on top of
+it.
+*/
+func (q *AbstractQuery) Equals(o Query) bool {
+	return o != nil && reflect.TypeOf(o) == reflect.TypeOf(q.value) &&
+		math.Float32bits(q.boost) == math.Float32bits(o.Boost())
+}
+
+func (q *AbstractQuery) HashCode() int {
+	return int(math.Float32bits(q.boost)) ^ stringHashCode(reflect.TypeOf(q.value).String())
+}
+
+func stringHashCode(s string) (h int) {
+	for _, ch := range s {
+		h = 31*h + int(ch)
+	}
+	return
+}
+
+func bytesHashCode(b []byte) (h int) {
+	for _, v := range b {
+		h = 31*h + int(v)
+	}
+	return
+}
+
+func termEquals(a, b *index.Term) bool {
+	return a.Field == b.Field && bytes.Equal(a.Bytes, b.Bytes)
+}
+
+func termHashCode(t *index.Term) int {
+	return 31*stringHashCode(t.Field) + bytesHashCode(t.Bytes)
+}
@@ -0,0 +1,380 @@
+package search
+
+import (
+	"container/list"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/index"
+	. "github.com/balzaczyy/golucene/core/search/model"
+	"github.com/balzaczyy/golucene/core/util"
+	"sync"
+)
+
+// search/QueryCache.java
+
+/* A cache for queries. */
+type QueryCache interface {
+	// Return a wrapper around the provided weight that will cache
+	// matching docs per-segment accordingly to the given policy.
+	DoCache(q Query, w Weight, policy QueryCachingPolicy) Weight
+}
+
+/*
+Segment readers which can notify a cache once their shared core is
+closed, so that its entries can be evicted.
+*/
+type coreClosedNotifier interface {
+	CoreCacheKey() interface{}
+	AddCoreClosedListener(listener index.CoreClosedListener)
+}
+
+// search/LRUQueryCache.java
+
+/*
+A QueryCache that evicts queries using a LRU (least-recently-used)
+eviction policy in order to remain under a given maximum size and
+number of bytes used.
+
+Matching docs are cached per segment, keyed by the segment's core
+cache key, as DocIdSets. Entries of a segment are evicted as soon as
+its core is closed.
+
+Queries are told apart by Equals() and HashCode(): the first query
+cached among equal ones is kept as the key for all of them.
+
+This class is thread-safe.
+*/
+type LRUQueryCache struct {
+	sync.Locker
+	maxSize         int
+	maxRamBytesUsed int64
+	// the cached queries as *uniqueQuery, least recently used first
+	mostRecentlyUsedQueries *list.List
+	// elements of mostRecentlyUsedQueries by query hash code
+	uniqueQueries map[int][]*list.Element
+	cache         map[interface{}]map[Query]DocIdSet
+	ramBytesUsed  int64
+
+	hitCount, missCount                  int64
+	cacheCount, cacheSize, evictionCount int64
+}
+
+/*
+Create a new instance that will cache at most maxSize queries with at
+most maxRamBytesUsed bytes of memory.
+*/
+func NewLRUQueryCache(maxSize int, maxRamBytesUsed int64) *LRUQueryCache {
+	return &LRUQueryCache{
+		Locker:                  &sync.Mutex{},
+		maxSize:                 maxSize,
+		maxRamBytesUsed:         maxRamBytesUsed,
+		mostRecentlyUsedQueries: list.New(),
+		uniqueQueries:           make(map[int][]*list.Element),
+		cache:                   make(map[interface{}]map[Query]DocIdSet),
+	}
+}
+
+// Rough estimate of the memory held by a cached query
+const QUERY_DEFAULT_RAM_BYTES_USED = 1024
+
+/*
+A cached query along with its hash code at the time it was cached, so
+that it can be found again even if it's modified afterwards.
+*/
+type uniqueQuery struct {
+	query Query
+	hash  int
+}
+
+/* Returns the element of the cached query equal to q, if any. */
+func (c *LRUQueryCache) uniqueQuery(q Query) *list.Element {
+	for _, elem := range c.uniqueQueries[q.HashCode()] {
+		if elem.Value.(*uniqueQuery).query.Equals(q) {
+			return elem
+		}
+	}
+	return nil
+}
+
+func (c *LRUQueryCache) get(q Query, coreKey interface{}) DocIdSet {
+	c.Lock()
+	defer c.Unlock()
+	if elem := c.uniqueQuery(q); elem != nil {
+		if set, ok := c.cache[coreKey][elem.Value.(*uniqueQuery).query]; ok {
+			c.hitCount++
+			c.mostRecentlyUsedQueries.MoveToBack(elem)
+			return set
+		}
+	}
+	c.missCount++
+	return nil
+}
+
+/*
+Caches set for q on the given core unless already cached. Returns
+true if this is the first entry of the core, whose closing the caller
+must then listen to.
+*/
+func (c *LRUQueryCache) putIfAbsent(q Query, coreKey interface{}, set DocIdSet) (newCore bool) {
+	c.Lock()
+	defer c.Unlock()
+	leafCache, ok := c.cache[coreKey]
+	if !ok {
+		leafCache = make(map[Query]DocIdSet)
+		c.cache[coreKey] = leafCache
+		newCore = true
+	}
+	elem := c.uniqueQuery(q)
+	if elem == nil {
+		hash := q.HashCode()
+		elem = c.mostRecentlyUsedQueries.PushBack(&uniqueQuery{q, hash})
+		c.uniqueQueries[hash] = append(c.uniqueQueries[hash], elem)
+		c.ramBytesUsed += QUERY_DEFAULT_RAM_BYTES_USED
+	} else if _, ok := leafCache[elem.Value.(*uniqueQuery).query]; ok {
+		return false
+	} else {
+		c.mostRecentlyUsedQueries.MoveToBack(elem)
+	}
+	leafCache[elem.Value.(*uniqueQuery).query] = set
+	c.ramBytesUsed += set.RamBytesUsed()
+	c.cacheCount++
+	c.cacheSize++
+	c.evictIfNecessary()
+	return
+}
+
+func (c *LRUQueryCache) evictIfNecessary() {
+	for c.mostRecentlyUsedQueries.Len() > 0 &&
+		(c.mostRecentlyUsedQueries.Len() > c.maxSize || c.ramBytesUsed > c.maxRamBytesUsed) {
+		elem := c.mostRecentlyUsedQueries.Front()
+		evicted := c.mostRecentlyUsedQueries.Remove(elem).(*uniqueQuery)
+		bucket := c.uniqueQueries[evicted.hash]
+		for i, e := range bucket {
+			if e == elem {
+				bucket = append(bucket[:i], bucket[i+1:]...)
+				break
+			}
+		}
+		if len(bucket) == 0 {
+			delete(c.uniqueQueries, evicted.hash)
+		} else {
+			c.uniqueQueries[evicted.hash] = bucket
+		}
+		c.ramBytesUsed -= QUERY_DEFAULT_RAM_BYTES_USED
+		for _, leafCache := range c.cache {
+			if set, ok := leafCache[evicted.query]; ok {
+				delete(leafCache, evicted.query)
+				c.onEviction(set)
+			}
+		}
+	}
+}
+
+func (c *LRUQueryCache) onEviction(set DocIdSet) {
+	c.ramBytesUsed -= set.RamBytesUsed()
+	c.cacheSize--
+	c.evictionCount++
+}
+
+/* Remove all cache entries for the given core cache key. */
+func (c *LRUQueryCache) ClearCoreCacheKey(coreKey interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	for _, set := range c.cache[coreKey] {
+		c.onEviction(set)
+	}
+	delete(c.cache, coreKey)
+}
+
+/* Evicts the entries of a closed segment core. */
+func (c *LRUQueryCache) OnClose(ownerCoreCacheKey interface{}) {
+	c.ClearCoreCacheKey(ownerCoreCacheKey)
+}
+
+/* Clear the content of this cache. */
+func (c *LRUQueryCache) Clear() {
+	c.Lock()
+	defer c.Unlock()
+	for _, leafCache := range c.cache {
+		for _, set := range leafCache {
+			c.onEviction(set)
+		}
+	}
+	c.cache = make(map[interface{}]map[Query]DocIdSet)
+	c.mostRecentlyUsedQueries.Init()
+	c.uniqueQueries = make(map[int][]*list.Element)
+	c.ramBytesUsed = 0
+}
+
+/* Number of times a lookup found a cached DocIdSet. */
+func (c *LRUQueryCache) HitCount() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.hitCount
+}
+
+/* Number of times a lookup found no cached DocIdSet. */
+func (c *LRUQueryCache) MissCount() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.missCount
+}
+
+/* Total number of DocIdSets which have ever been cached. */
+func (c *LRUQueryCache) CacheCount() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.cacheCount
+}
+
+/* Number of DocIdSets currently in this cache. */
+func (c *LRUQueryCache) CacheSize() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.cacheSize
+}
+
+/* Number of DocIdSets which have been evicted from this cache. */
+func (c *LRUQueryCache) EvictionCount() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.evictionCount
+}
+
+func (c *LRUQueryCache) RamBytesUsed() int64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.ramBytesUsed
+}
+
+func (c *LRUQueryCache) DoCache(q Query, w Weight, policy QueryCachingPolicy) Weight {
+	// ignore weights which are already cached
+	for {
+		cw, ok := w.(*cachingWrapperWeight)
+		if !ok {
+			break
+		}
+		w = cw.in
+	}
+	ans := &cachingWrapperWeight{cache: c, query: q, in: w, policy: policy}
+	ans.WeightImpl = newWeightImpl(ans)
+	return ans
+}
+
+type cachingWrapperWeight struct {
+	*WeightImpl
+	cache  *LRUQueryCache
+	query  Query
+	in     Weight
+	policy QueryCachingPolicy
+	used   bool // guarded by the cache's lock
+}
+
+func (w *cachingWrapperWeight) String() string {
+	return fmt.Sprintf("cached(%v)", w.in)
+}
+
+func (w *cachingWrapperWeight) Explain(ctx *index.AtomicReaderContext, doc int) (Explanation, error) {
+	return w.in.Explain(ctx, doc)
+}
+
+func (w *cachingWrapperWeight) ValueForNormalization() float32 {
+	return w.in.ValueForNormalization()
+}
+
+func (w *cachingWrapperWeight) Normalize(norm float32, topLevelBoost float32) {
+	w.in.Normalize(norm, topLevelBoost)
+}
+
+func (w *cachingWrapperWeight) IsScoresDocsOutOfOrder() bool {
+	return w.in.IsScoresDocsOutOfOrder()
+}
+
+func (w *cachingWrapperWeight) Scorer(ctx *index.AtomicReaderContext,
+	acceptDocs util.Bits) (Scorer, error) {
+
+	w.cache.Lock()
+	used := w.used
+	w.used = true
+	w.cache.Unlock()
+	if !used {
+		w.policy.OnUse(w.query)
+	}
+	reader, ok := ctx.Reader().(coreClosedNotifier)
+	if !ok || !w.policy.ShouldCache(w.query, ctx) {
+		return w.in.Scorer(ctx, acceptDocs)
+	}
+
+	coreKey := reader.CoreCacheKey()
+	set := w.cache.get(w.query, coreKey)
+	if set == nil {
+		scorer, err := w.in.Scorer(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if scorer == nil {
+			set = NewBitDocIdSet(util.NewOpenBitSet(), 0)
+		} else if set, err = newBitDocIdSetOf(scorer, ctx.Reader().MaxDoc()); err != nil {
+			return nil, err
+		}
+		if w.cache.putIfAbsent(w.query, coreKey, set) {
+			// the core's entry was added under the cache lock, so only one
+			// caller gets here; registered outside of it since closing the
+			// core calls back into the cache
+			reader.AddCoreClosedListener(w.cache)
+		}
+	}
+
+	it, err := set.Iterator()
+	if it == nil || err != nil {
+		return nil, err
+	}
+	return newCachedScorer(w, it, acceptDocs), nil
+}
+
+/* Iterates cached docs which are accepted, all scoring 0. */
+type cachedScorer struct {
+	*abstractScorer
+	it         DocIdSetIterator
+	acceptDocs util.Bits
+}
+
+func newCachedScorer(w Weight, it DocIdSetIterator, acceptDocs util.Bits) *cachedScorer {
+	ans := &cachedScorer{it: it, acceptDocs: acceptDocs}
+	ans.abstractScorer = newScorer(ans, w)
+	return ans
+}
+
+func (s *cachedScorer) DocId() int {
+	return s.it.DocId()
+}
+
+func (s *cachedScorer) NextDoc() (int, error) {
+	doc, err := s.it.NextDoc()
+	return s.accept(doc, err)
+}
+
+func (s *cachedScorer) Advance(target int) (int, error) {
+	doc, err := s.it.Advance(target)
+	return s.accept(doc, err)
+}
+
+func (s *cachedScorer) accept(doc int, err error) (int, error) {
+	for ; err == nil && doc != NO_MORE_DOCS; doc, err = s.it.NextDoc() {
+		if s.acceptDocs == nil || s.acceptDocs.At(doc) {
+			break
+		}
+	}
+	return doc, err
+}
+
+func (s *cachedScorer) Score() (float32, error) {
+	return 0, nil
+}
+
+func (s *cachedScorer) Freq() (int, error) {
+	return 1, nil
+}
+
+func (s *cachedScorer) String() string {
+	return fmt.Sprintf("scorer(%v)", s.weight)
+}
@@ -0,0 +1,111 @@
+package search
+
+import (
+	"github.com/balzaczyy/golucene/core/index"
+	"sync"
+)
+
+// search/QueryCachingPolicy.java
+
+/*
+A policy defining which filters should be cached.
+
+Implementations of this class must be thread-safe.
+*/
+type QueryCachingPolicy interface {
+	// Callback that is called every time that a cached filter is used.
+	// This is typically useful if the policy wants to track usage
+	// statistics in order to make decisions.
+	OnUse(q Query)
+	// Whether the given DocIdSet should be cached on a given segment.
+	// This method will be called on each leaf context to know if the
+	// filter should be cached on this particular leaf.
+	ShouldCache(q Query, ctx *index.AtomicReaderContext) bool
+}
+
+type alwaysCache bool
+
+func (p alwaysCache) OnUse(q Query) {}
+
+func (p alwaysCache) ShouldCache(q Query, ctx *index.AtomicReaderContext) bool {
+	return true
+}
+
+/* Policy that caches all queries on all segments. */
+const QUERY_CACHING_POLICY_ALWAYS_CACHE = alwaysCache(true)
+
+// search/UsageTrackingQueryCachingPolicy.java
+
+/*
+A QueryCachingPolicy that tracks usage statistics of recently-used
+queries in order to decide on which queries are worth caching: a
+query is cached once it has been used at least minFrequency times
+among the last historySize uses, and only on segments which are large
+enough, i.e. have at least minIndexSize documents and at least
+minSizeRatio of the documents of the whole index.
+*/
+type UsageTrackingQueryCachingPolicy struct {
+	sync.Locker
+	minIndexSize int
+	minSizeRatio float32
+	minFrequency int
+	// ring buffer of the hash codes of the most recently used queries
+	recentlyUsed []int
+	next         int
+	frequencies  map[int]int
+}
+
+/* Create a new instance with sensible defaults. */
+func NewDefaultUsageTrackingQueryCachingPolicy() *UsageTrackingQueryCachingPolicy {
+	return NewUsageTrackingQueryCachingPolicy(10000, 0.03, 2, 256)
+}
+
+func NewUsageTrackingQueryCachingPolicy(minIndexSize int, minSizeRatio float32,
+	minFrequency, historySize int) *UsageTrackingQueryCachingPolicy {
+
+	assert2(historySize > 0, "historySize must be > 0; got %v", historySize)
+	return &UsageTrackingQueryCachingPolicy{
+		Locker:       &sync.Mutex{},
+		minIndexSize: minIndexSize,
+		minSizeRatio: minSizeRatio,
+		minFrequency: minFrequency,
+		recentlyUsed: make([]int, 0, historySize),
+		frequencies:  make(map[int]int),
+	}
+}
+
+func (p *UsageTrackingQueryCachingPolicy) OnUse(q Query) {
+	key := q.HashCode()
+	p.Lock()
+	defer p.Unlock()
+	if len(p.recentlyUsed) < cap(p.recentlyUsed) {
+		p.recentlyUsed = append(p.recentlyUsed, key)
+	} else {
+		evicted := p.recentlyUsed[p.next]
+		if p.frequencies[evicted]--; p.frequencies[evicted] == 0 {
+			delete(p.frequencies, evicted)
+		}
+		p.recentlyUsed[p.next] = key
+		p.next = (p.next + 1) % len(p.recentlyUsed)
+	}
+	p.frequencies[key]++
+}
+
+/* Returns how many times q was used among the most recent uses. */
+func (p *UsageTrackingQueryCachingPolicy) Frequency(q Query) int {
+	p.Lock()
+	defer p.Unlock()
+	return p.frequencies[q.HashCode()]
+}
+
+func (p *UsageTrackingQueryCachingPolicy) ShouldCache(q Query, ctx *index.AtomicReaderContext) bool {
+	maxDoc := ctx.Reader().MaxDoc()
+	if maxDoc < p.minIndexSize {
+		return false
+	}
+	topLevelMaxDoc := index.TopLevelContext(ctx).Reader().MaxDoc()
+	if float32(maxDoc)/float32(topLevelMaxDoc) < p.minSizeRatio {
+		return false
+	}
+	return p.Frequency(q) >= p.minFrequency
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"github.com/balzaczyy/golucene/core/index"
+	"github.com/balzaczyy/golucene/core/util"
+	"testing"
+)
+
+func TestQueryEquals(t *testing.T) {
+	newQueries := func() []Query {
+		phrase := NewPhraseQuery()
+		phrase.Add(index.NewTerm("body", "new"))
+		phrase.Add(index.NewTerm("body", "york"))
+		sloppy := NewPhraseQuery()
+		sloppy.Add(index.NewTerm("body", "new"))
+		sloppy.Add(index.NewTerm("body", "york"))
+		sloppy.SetSlop(2)
+		bq := NewBooleanQuery()
+		bq.Add(NewTermQuery(index.NewTerm("body", "red")), MUST)
+		bq.Add(NewTermQuery(index.NewTerm("body", "blue")), SHOULD)
+		boosted := NewTermQuery(index.NewTerm("body", "red"))
+		boosted.SetBoost(2)
+		return []Query{
+			NewMatchAllDocsQuery(),
+			NewTermQuery(index.NewTerm("body", "red")),
+			NewTermQuery(index.NewTerm("body", "blue")),
+			NewTermQuery(index.NewTerm("title", "red")),
+			boosted,
+			NewConstantScoreQuery(NewTermQuery(index.NewTerm("body", "red"))),
+			NewNumericRangeQuery("price", 1, 10, true, true),
+			NewNumericRangeQuery("price", 1, 10, true, false),
+			phrase,
+			sloppy,
+			bq,
+		}
+	}
+	queries, copies := newQueries(), newQueries()
+	for i, q := range queries {
+		if !q.Equals(copies[i]) {
+			t.Errorf("%v should equal its copy", q)
+		}
+		if q.HashCode() != copies[i].HashCode() {
+			t.Errorf("%v and its copy have different hash codes", q)
+		}
+		for j, other := range queries {
+			if i != j && q.Equals(other) {
+				t.Errorf("%v should not equal %v", q, other)
+			}
+		}
+	}
+}
+
+func TestLRUQueryCacheEqualQueries(t *testing.T) {
+	cache := NewLRUQueryCache(2, 1<<20)
+	set := NewBitDocIdSet(util.NewOpenBitSet(), 0)
+	cache.putIfAbsent(NewTermQuery(index.NewTerm("body", "red")), "core", set)
+	if cache.get(NewTermQuery(index.NewTerm("body", "red")), "core") != set {
+		t.Error("an equal query should hit the cache")
+	}
+	if cache.get(NewTermQuery(index.NewTerm("body", "blue")), "core") != nil {
+		t.Error("a different query should miss the cache")
+	}
+	cache.putIfAbsent(NewTermQuery(index.NewTerm("body", "red")), "core", NewBitDocIdSet(util.NewOpenBitSet(), 0))
+	if cache.CacheSize() != 1 {
+		t.Errorf("equal queries should share an entry, got %v entries", cache.CacheSize())
+	}
+
+	cache.putIfAbsent(NewTermQuery(index.NewTerm("body", "blue")), "core", set)
+	cache.putIfAbsent(NewTermQuery(index.NewTerm("body", "green")), "core", set)
+	if cache.get(NewTermQuery(index.NewTerm("body", "red")), "core") != nil {
+		t.Error("the least recently used query should have been evicted")
+	}
+	if cache.CacheSize() != 2 || cache.EvictionCount() != 1 {
+		t.Errorf("expected 2 entries and 1 eviction, got %v and %v",
+			cache.CacheSize(), cache.EvictionCount())
+	}
+}
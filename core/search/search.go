@@ -24,6 +24,9 @@ type IndexSearcher struct {
 	readerContext index.IndexReaderContext
 	leafContexts  []*index.AtomicReaderContext
 	similarity    Similarity
+
+	queryCache         QueryCache
+	queryCachingPolicy QueryCachingPolicy
 }
 
 func NewIndexSearcher(r index.IndexReader) *IndexSearcher {
@@ -33,8 +36,14 @@ func NewIndexSearcher(r index.IndexReader) *IndexSearcher {
 
 func NewIndexSearcherFromContext(context index.IndexReaderContext) *IndexSearcher {
 	// assert2(context.isTopLevel, "IndexSearcher's ReaderContext must be topLevel for reader %v", context.reader())
-	defaultSimilarity := NewDefaultSimilarity()
-	ss := &IndexSearcher{nil, context.Reader(), context, context.Leaves(), defaultSimilarity}
+	defaultSimilarity := NewBM25Similarity()
+	ss := &IndexSearcher{
+		reader:             context.Reader(),
+		readerContext:      context,
+		leafContexts:       context.Leaves(),
+		similarity:         defaultSimilarity,
+		queryCachingPolicy: NewDefaultUsageTrackingQueryCachingPolicy(),
+	}
 	ss.spi = ss
 	return ss
 }
@@ -44,6 +53,20 @@ func (ss *IndexSearcher) SetSimilarity(similarity Similarity) {
 	ss.similarity = similarity
 }
 
+/*
+Set the QueryCache to use when scores are not needed. A value of nil
+means that query results are never cached, which is the default.
+*/
+func (ss *IndexSearcher) SetQueryCache(queryCache QueryCache) {
+	ss.queryCache = queryCache
+}
+
+/* Set the QueryCachingPolicy to use for query caching. */
+func (ss *IndexSearcher) SetQueryCachingPolicy(policy QueryCachingPolicy) {
+	assert2(policy != nil, "QueryCachingPolicy may not be nil")
+	ss.queryCachingPolicy = policy
+}
+
 func (ss *IndexSearcher) SearchTop(q Query, n int) (topDocs TopDocs, err error) {
 	return ss.Search(q, nil, n)
 }
@@ -56,6 +79,38 @@ func (ss *IndexSearcher) Search(q Query, f Filter, n int) (topDocs TopDocs, err
 	return ss.searchWSI(w, nil, n), nil
 }
 
+/*
+Lower-level search API: feeds c with every document matching q,
+applying f if non-nil.
+*/
+func (ss *IndexSearcher) SearchCollect(q Query, f Filter, c Collector) error {
+	w, err := ss.spi.CreateNormalizedWeight(ss.spi.WrapFilter(q, f))
+	if err != nil {
+		return err
+	}
+	return ss.spi.SearchLWC(ss.leafContexts, w, c)
+}
+
+/*
+Search implementation with arbitrary sorting: finds the top n hits
+for q, applying f if non-nil, sorted by sort. Scores of the hits are
+only computed if sort needs them.
+*/
+func (ss *IndexSearcher) SearchSort(q Query, f Filter, n int, sort *Sort) (TopFieldDocs, error) {
+	limit := ss.reader.MaxDoc()
+	if limit == 0 {
+		limit = 1
+	}
+	if n > limit {
+		n = limit
+	}
+	c := NewTopFieldCollector(sort, n, sort.NeedsScores())
+	if err := ss.SearchCollect(q, f, c); err != nil {
+		return TopFieldDocs{}, err
+	}
+	return c.TopFieldDocs(), nil
+}
+
 /** Expert: Low-level search implementation.  Finds the top <code>n</code>
  * hits for <code>query</code>, applying <code>filter</code> if non-null.
  *
@@ -106,7 +161,9 @@ func (ss *IndexSearcher) SearchLWC(leaves []*index.AtomicReaderContext, w Weight
 			return err
 		}
 		if scorer != nil {
-			err = scorer.ScoreAndCollect(c)
+			if err = scorer.ScoreAndCollect(c); err != nil {
+				return err
+			}
 		} // TODO catch CollectionTerminatedException
 	}
 	return
@@ -170,6 +227,18 @@ func (ss *IndexSearcher) CreateNormalizedWeight(q Query) (w Weight, err error) {
 	return w, nil
 }
 
+/*
+Creates a Weight for q whose scores are not needed, so that its
+matching docs can be cached by the QueryCache.
+*/
+func (ss *IndexSearcher) createNonScoringWeight(q Query) (Weight, error) {
+	w, err := q.CreateWeight(ss)
+	if err != nil || ss.queryCache == nil {
+		return w, err
+	}
+	return ss.queryCache.DoCache(q, w, ss.queryCachingPolicy), nil
+}
+
 func (ss *IndexSearcher) Rewrite(q Query) (Query, error) {
 	log.Printf("Rewriting '%v'...", q)
 	after := q.Rewrite(ss.reader)
@@ -241,6 +310,9 @@ type SimScorer interface {
 	 * @return document's score
 	 */
 	Score(doc int, freq float32) float32
+	// Computes the amount of a sloppy phrase match, based on an edit
+	// distance.
+	computeSlopFactor(distance int) float32
 	// Explain the score for a single document
 	explain(int, Explanation) Explanation
 }
@@ -281,6 +353,15 @@ type ITFIDFSimilarity interface {
 	 * @return a score factor based on the term's document frequency
 	 */
 	idf(docFreq int64, numDocs int64) float32
+	// Computes the amount of a sloppy phrase match, based on an edit
+	// distance. This value is summed for each sloppy phrase match in a
+	// document to form the frequency passed to tf().
+	//
+	// A given phrase match with a small edit distance to a document
+	// passage more closely matches the document, so implementations of
+	// this method usually return larger values when the edit distance
+	// is small and smaller values when it is large.
+	sloppyFreq(distance int) float32
 	// Compute an index-time normalization value for this field instance.
 	//
 	// This value will be stored in a single byte lossy representation
@@ -360,6 +441,10 @@ func (ss *tfIDFSimScorer) Score(doc int, freq float32) float32 {
 	return raw * ss.owner.spi.decodeNormValue(ss.norms(doc)) // normalize for field
 }
 
+func (ss *tfIDFSimScorer) computeSlopFactor(distance int) float32 {
+	return ss.owner.spi.sloppyFreq(distance)
+}
+
 func (ss *tfIDFSimScorer) explain(doc int, freq Explanation) Explanation {
 	return ss.owner.explainScore(doc, freq, ss.stats, ss.norms)
 }
@@ -510,6 +595,10 @@ func (ds *DefaultSimilarity) tf(freq float32) float32 {
 	return float32(math.Sqrt(float64(freq)))
 }
 
+func (ds *DefaultSimilarity) sloppyFreq(distance int) float32 {
+	return 1.0 / float32(distance+1)
+}
+
 func (ds *DefaultSimilarity) idf(docFreq int64, numDocs int64) float32 {
 	return float32(math.Log(float64(numDocs)/float64(docFreq+1))) + 1.0
 }
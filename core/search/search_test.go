@@ -40,6 +40,7 @@ func TestKeywordSearch(t *testing.T) {
 		t.Error("Should have one leaf.")
 	}
 	ss := NewIndexSearcher(r)
+	ss.SetSimilarity(NewDefaultSimilarity()) // expected hits are ranked by TF-IDF
 	docs, err := ss.SearchTop(NewTermQuery(index.NewTerm("content", "bat")), 10)
 	if err != nil {
 		t.Error(err)
@@ -16,7 +16,7 @@ to implement an information retrieval model. If you are instead
 looking for a convenient way to alter Lucene's scoring, consider
 extending a high-level implementation such as TFIDFSimilarity, which
 implements the vector space model with this API, or just tweaking the
-default implementation: DefaultSimilarity.
+default implementation: BM25Similarity.
 
 Similarity determines how Lucene weights terms, and Lucene interacts
 with this class at both index-time and query-time.
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// search/SortField.java
+
+/* Specifies the type of the terms to be sorted, or special types such as relevance or document index order. */
+type SortFieldType int
+
+const (
+	// Sort by document score (relevance). Sort values are float32 and
+	// higher values are at the front.
+	SORT_FIELD_SCORE = SortFieldType(iota)
+	// Sort by document number (index order). Sort values are int and
+	// lower values are at the front.
+	SORT_FIELD_DOC
+	// Sort using the NumericDocValues of a field. Sort values are int64
+	// and lower values are at the front.
+	SORT_FIELD_LONG
+	// Sort using the SortedDocValues of a field. Sort values are []byte
+	// and lower values are at the front.
+	SORT_FIELD_STRING
+)
+
+func (t SortFieldType) String() string {
+	switch t {
+	case SORT_FIELD_SCORE:
+		return "SCORE"
+	case SORT_FIELD_DOC:
+		return "DOC"
+	case SORT_FIELD_LONG:
+		return "LONG"
+	case SORT_FIELD_STRING:
+		return "STRING"
+	}
+	panic(fmt.Sprintf("unknown sort field type: %v", int(t)))
+}
+
+/*
+Stores information about how to sort documents by terms in an
+individual field. Fields must be indexed with doc values of the
+matching kind in order to sort by them; documents without a value
+sort as 0, or as the empty string.
+*/
+type SortField struct {
+	field   string
+	typ     SortFieldType
+	reverse bool
+}
+
+/* Represents sorting by document score (relevance). */
+var SORT_FIELD_FIELD_SCORE = NewSortField("", SORT_FIELD_SCORE, false)
+
+/* Represents sorting by document number (index order). */
+var SORT_FIELD_FIELD_DOC = NewSortField("", SORT_FIELD_DOC, false)
+
+/*
+Creates a sort, possibly in reverse, by terms in the given field with
+the type of term values explicitly given. field can be empty if typ
+is SCORE or DOC.
+*/
+func NewSortField(field string, typ SortFieldType, reverse bool) *SortField {
+	assert2(field != "" || typ == SORT_FIELD_SCORE || typ == SORT_FIELD_DOC,
+		"field can only be empty when type is SCORE or DOC")
+	return &SortField{field, typ, reverse}
+}
+
+/* Returns the name of the field, empty for SCORE and DOC sorts. */
+func (f *SortField) Field() string {
+	return f.field
+}
+
+/* Returns the type of contents in the field. */
+func (f *SortField) Type() SortFieldType {
+	return f.typ
+}
+
+/* Returns whether the sort should be reversed. */
+func (f *SortField) Reverse() bool {
+	return f.reverse
+}
+
+/* Whether the relevance score is needed to sort documents. */
+func (f *SortField) NeedsScores() bool {
+	return f.typ == SORT_FIELD_SCORE
+}
+
+func (f *SortField) String() string {
+	var buf bytes.Buffer
+	switch f.typ {
+	case SORT_FIELD_SCORE:
+		buf.WriteString("<score>")
+	case SORT_FIELD_DOC:
+		buf.WriteString("<doc>")
+	default:
+		fmt.Fprintf(&buf, "<%v: \"%v\">", f.typ, f.field)
+	}
+	if f.reverse {
+		buf.WriteString("!")
+	}
+	return buf.String()
+}
+
+/* Returns the FieldComparator to use for sorting. */
+func (f *SortField) comparator(numHits int) FieldComparator {
+	switch f.typ {
+	case SORT_FIELD_SCORE:
+		return newRelevanceComparator(numHits)
+	case SORT_FIELD_DOC:
+		return newDocComparator(numHits)
+	case SORT_FIELD_LONG:
+		return newLongComparator(numHits, f.field)
+	case SORT_FIELD_STRING:
+		return newTermValComparator(numHits, f.field)
+	}
+	panic(fmt.Sprintf("illegal sort type: %v", f.typ))
+}
+
+// search/Sort.java
+
+/*
+Encapsulates sort criteria for returned hits.
+
+Documents are sorted by the first SortField, ties being broken by the
+following ones, and eventually by document number.
+*/
+type Sort struct {
+	fields []*SortField
+}
+
+/* Represents sorting by computed relevance. */
+var SORT_RELEVANCE = NewSort(SORT_FIELD_FIELD_SCORE)
+
+/* Represents sorting by index order. */
+var SORT_INDEXORDER = NewSort(SORT_FIELD_FIELD_DOC)
+
+/* Sorts in succession by the criteria in each SortField. */
+func NewSort(fields ...*SortField) *Sort {
+	assert2(len(fields) > 0, "There must be at least 1 sort field")
+	return &Sort{fields}
+}
+
+/* Representation of the sort criteria. */
+func (s *Sort) Fields() []*SortField {
+	return s.fields
+}
+
+/* Whether the relevance score is needed to sort documents. */
+func (s *Sort) NeedsScores() bool {
+	for _, f := range s.fields {
+		if f.NeedsScores() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sort) String() string {
+	var buf bytes.Buffer
+	for i, f := range s.fields {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(f.String())
+	}
+	return buf.String()
+}
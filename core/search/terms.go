@@ -51,6 +51,15 @@ func (q *TermQuery) CreateWeight(ss *IndexSearcher) (w Weight, err error) {
 	return NewTermWeight(q, ss, termState), nil
 }
 
+func (q *TermQuery) Equals(o Query) bool {
+	other, ok := o.(*TermQuery)
+	return ok && q.AbstractQuery.Equals(o) && termEquals(q.term, other.term)
+}
+
+func (q *TermQuery) HashCode() int {
+	return q.AbstractQuery.HashCode() ^ termHashCode(q.term)
+}
+
 func (q *TermQuery) ToString(field string) string {
 	var buf bytes.Buffer
 	if q.term.Field != field {
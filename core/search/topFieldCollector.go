@@ -0,0 +1,204 @@
+package search
+
+import (
+	"container/heap"
+	"github.com/balzaczyy/golucene/core/index"
+	"math"
+)
+
+// search/TopFieldDocs.java
+
+/* Represents hits returned by IndexSearcher.SearchSort(). */
+type TopFieldDocs struct {
+	TopDocs
+	// The fields which were used to sort results by.
+	Fields []*SortField
+}
+
+// search/TopFieldCollector.java
+
+/* A hit of the queue, whose sort values are held in slot. */
+type fieldValueHitEntry struct {
+	*ScoreDoc
+	slot int
+}
+
+/*
+A Collector that sorts by SortField using FieldComparators, keeping
+the top numHits documents. Each returned ScoreDoc holds the values of
+the sort fields in Fields.
+
+Documents must be collected in order, so that ties on all sort fields
+are broken by document number.
+*/
+type TopFieldCollector struct {
+	*abstractTopDocsCollector
+	sort        *Sort
+	comparators []FieldComparator
+	reverseMul  []int
+	numHits     int
+	trackScores bool
+	maxScore    float32
+	bottom      *fieldValueHitEntry
+	queueFull   bool
+	docBase     int
+	scorer      Scorer
+	err         error // first error hit while switching segments
+}
+
+/*
+Creates a new TopFieldCollector from the given arguments. If
+trackScores is true, each hit gets its score, and the maximum score
+is tracked, even if the sort doesn't need scores.
+*/
+func NewTopFieldCollector(sort *Sort, numHits int, trackScores bool) *TopFieldCollector {
+	assert2(numHits > 0, "numHits must be > 0; please use TotalHitCountCollector if you just need the total hit count")
+	c := &TopFieldCollector{
+		sort:        sort,
+		numHits:     numHits,
+		trackScores: trackScores,
+		maxScore:    float32(math.Inf(-1)),
+	}
+	for _, field := range sort.fields {
+		c.comparators = append(c.comparators, field.comparator(numHits))
+		if field.reverse {
+			c.reverseMul = append(c.reverseMul, -1)
+		} else {
+			c.reverseMul = append(c.reverseMul, 1)
+		}
+	}
+	pq := &PriorityQueue{}
+	pq.less = func(i, j int) bool {
+		// the least competitive hit sorts on top of the queue
+		return c.compare(pq.items[i].(*fieldValueHitEntry), pq.items[j].(*fieldValueHitEntry)) > 0
+	}
+	c.abstractTopDocsCollector = newTopDocsCollector(c, pq)
+	return c
+}
+
+/* Compares the sort order of two hits of the queue. */
+func (c *TopFieldCollector) compare(a, b *fieldValueHitEntry) int {
+	for i, comparator := range c.comparators {
+		if cmp := c.reverseMul[i] * comparator.Compare(a.slot, b.slot); cmp != 0 {
+			return cmp
+		}
+	}
+	// avoid random sort order that could lead to duplicates
+	return a.Doc - b.Doc
+}
+
+func (c *TopFieldCollector) SetNextReader(ctx *index.AtomicReaderContext) {
+	c.docBase = ctx.DocBase
+	for _, comparator := range c.comparators {
+		if err := comparator.SetNextReader(ctx); err != nil && c.err == nil {
+			c.err = err
+		}
+	}
+}
+
+func (c *TopFieldCollector) SetScorer(scorer Scorer) {
+	c.scorer = scorer
+	for _, comparator := range c.comparators {
+		comparator.SetScorer(scorer)
+	}
+}
+
+func (c *TopFieldCollector) Collect(doc int) (err error) {
+	if c.err != nil {
+		return c.err
+	}
+	score := float32(math.NaN())
+	if c.trackScores {
+		if score, err = c.scorer.Score(); err != nil {
+			return err
+		}
+		if score > c.maxScore {
+			c.maxScore = score
+		}
+	}
+	c.TotalHits++
+
+	if c.queueFull {
+		if competitive, err := c.competitive(doc); err != nil || !competitive {
+			return err
+		}
+		// This hit is competitive - replace bottom element in queue & updateTop
+		if err = c.copy(c.bottom.slot, doc); err != nil {
+			return err
+		}
+		c.bottom.Doc, c.bottom.Score = c.docBase+doc, score
+		c.bottom = c.pq.updateTop().(*fieldValueHitEntry)
+	} else {
+		// Startup transient: queue hasn't gathered numHits yet
+		slot := c.TotalHits - 1
+		if err = c.copy(slot, doc); err != nil {
+			return err
+		}
+		heap.Push(c.pq, &fieldValueHitEntry{newScoreDoc(c.docBase+doc, score), slot})
+		if c.queueFull = c.TotalHits == c.numHits; !c.queueFull {
+			return nil
+		}
+		c.bottom = c.pq.items[0].(*fieldValueHitEntry)
+	}
+	for _, comparator := range c.comparators {
+		comparator.SetBottom(c.bottom.slot)
+	}
+	return nil
+}
+
+/* Whether doc sorts before the bottom of the full queue. */
+func (c *TopFieldCollector) competitive(doc int) (bool, error) {
+	for i, comparator := range c.comparators {
+		cmp, err := comparator.CompareBottom(doc)
+		if err != nil {
+			return false, err
+		}
+		if cmp *= c.reverseMul[i]; cmp < 0 {
+			// Definitely not competitive.
+			return false, nil
+		} else if cmp > 0 {
+			// Definitely competitive.
+			return true, nil
+		}
+	}
+	// Here cmp == 0 for all comparators. Since docs are visited in doc
+	// Id order, this doc cannot compete with any other document in the
+	// queue.
+	return false, nil
+}
+
+func (c *TopFieldCollector) copy(slot, doc int) error {
+	for _, comparator := range c.comparators {
+		if err := comparator.Copy(slot, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TopFieldCollector) populateResults(results []*ScoreDoc, howMany int) {
+	for i := howMany - 1; i >= 0; i-- {
+		entry := heap.Pop(c.pq).(*fieldValueHitEntry)
+		entry.Fields = make([]interface{}, len(c.comparators))
+		for j, comparator := range c.comparators {
+			entry.Fields[j] = comparator.Value(entry.slot)
+		}
+		results[i] = entry.ScoreDoc
+	}
+}
+
+func (c *TopFieldCollector) newTopDocs(results []*ScoreDoc, start int) TopDocs {
+	if results == nil {
+		results = []*ScoreDoc{}
+	}
+	maxScore := math.NaN()
+	if c.trackScores && c.TotalHits > 0 {
+		maxScore = float64(c.maxScore)
+	}
+	return TopDocs{c.TotalHits, results, maxScore}
+}
+
+/* Returns the top docs along with the fields they were sorted by. */
+func (c *TopFieldCollector) TopFieldDocs() TopFieldDocs {
+	return TopFieldDocs{c.TopDocs(), c.sort.fields}
+}
@@ -0,0 +1,12 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestSortString(t *testing.T) {
+	sort := NewSort(NewSortField("price", SORT_FIELD_LONG, true), SORT_FIELD_FIELD_SCORE)
+	assertEquals(t, `<LONG: "price">!,<score>`, sort.String())
+	assertEquals(t, true, sort.NeedsScores())
+	assertEquals(t, false, SORT_INDEXORDER.NeedsScores())
+}
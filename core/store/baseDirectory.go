@@ -1,6 +1,8 @@
 package store
 
-import ()
+import (
+	"errors"
+)
 
 type BaseDirectorySPI interface {
 	// DirectoryImplSPI
@@ -52,6 +54,27 @@ func (d *BaseDirectory) LockFactory() LockFactory {
 
 func (d *BaseDirectory) EnsureOpen() {
 	if !d.IsOpen {
-		panic("this Directory is closed")
+		panic(AlreadyClosedError("this Directory is closed"))
+	}
+}
+
+/*
+Recovers the AlreadyClosedError raised by EnsureOpen() into err, so
+public methods returning an error report a closed Directory that way
+instead of panicking. Any other panic is propagated. Must be deferred
+directly:
+
+	func (d *FSDirectory) ListAll() (paths []string, err error) {
+		defer recoverAlreadyClosed(&err)
+		d.EnsureOpen()
+		...
+*/
+func recoverAlreadyClosed(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok && errors.Is(e, ErrAlreadyClosed) {
+			*err = e
+			return
+		}
+		panic(r)
 	}
 }
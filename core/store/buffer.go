@@ -11,14 +11,17 @@ type SeekReader interface {
 	Length() int64
 }
 
-/* Minimum buffer size allowed */
-const MIN_BUFFER_SIZE = 8
+const (
+	MIN_BUFFER_SIZE = 8       // Minimum buffer size allowed
+	MAX_BUFFER_SIZE = 1 << 24 // Maximum buffer size allowed
+)
 
 /* Base implementation class for buffered IndexInput. */
 type BufferedIndexInput struct {
 	*IndexInputImpl
 	spi            SeekReader
 	bufferSize     int
+	normalSize     int // buffer size to restore on READ_ADVICE_NORMAL
 	buffer         []byte
 	bufferStart    int64
 	bufferLength   int
@@ -31,11 +34,79 @@ func newBufferedIndexInput(spi SeekReader, desc string, context IOContext) *Buff
 
 func newBufferedIndexInputBySize(spi SeekReader, desc string, bufferSize int) *BufferedIndexInput {
 	checkBufferSize(bufferSize)
-	ans := &BufferedIndexInput{spi: spi, bufferSize: bufferSize}
+	ans := &BufferedIndexInput{spi: spi, bufferSize: bufferSize, normalSize: bufferSize}
 	ans.IndexInputImpl = NewIndexInputImpl(desc, ans)
 	return ans
 }
 
+/*
+Sequential reads get a large buffer so each refill fetches more of
+the file at once; random reads get a small one so a seek doesn't
+waste time filling bytes that are thrown away.
+*/
+func (in *BufferedIndexInput) SetReadAdvice(advice ReadAdvice) {
+	switch advice {
+	case READ_ADVICE_SEQUENTIAL:
+		if in.normalSize < DEFAULT_BUFFER_SIZE {
+			in.setBufferSize(DEFAULT_BUFFER_SIZE)
+			return
+		}
+	case READ_ADVICE_RANDOM:
+		if in.normalSize > BUFFER_SIZE {
+			in.setBufferSize(BUFFER_SIZE)
+			return
+		}
+	}
+	in.setBufferSize(in.normalSize)
+}
+
+/*
+Changes the buffer size, e.g. a larger one for streaming a whole file
+or a smaller one for random lookups; it also becomes the size restored
+by READ_ADVICE_NORMAL. Returns an error if newSize is not between
+MIN_BUFFER_SIZE and MAX_BUFFER_SIZE.
+*/
+func (in *BufferedIndexInput) SetBufferSize(newSize int) error {
+	if newSize < MIN_BUFFER_SIZE || newSize > MAX_BUFFER_SIZE {
+		return errors.New(fmt.Sprintf(
+			"bufferSize must be between %v and %v (got %v)",
+			MIN_BUFFER_SIZE, MAX_BUFFER_SIZE, newSize))
+	}
+	in.normalSize = newSize
+	in.setBufferSize(newSize)
+	return nil
+}
+
+/*
+Changes the buffer size. The unread buffered bytes are kept if they
+fit into the new buffer; otherwise the buffer is dropped, and the next
+read re-seeks and refills it from the current file pointer.
+*/
+func (in *BufferedIndexInput) setBufferSize(newSize int) {
+	assert(in.buffer == nil || in.bufferSize == len(in.buffer))
+	if newSize == in.bufferSize {
+		return
+	}
+	checkBufferSize(newSize)
+	in.bufferSize = newSize
+	if in.buffer != nil {
+		numToCopy := in.bufferLength - in.bufferPosition
+		in.bufferStart += int64(in.bufferPosition)
+		in.bufferPosition = 0
+		if numToCopy <= newSize {
+			newBuffer := make([]byte, newSize)
+			copy(newBuffer, in.buffer[in.bufferLength-numToCopy:in.bufferLength])
+			in.bufferLength = numToCopy
+			in.newBuffer(newBuffer)
+		} else {
+			// refill() allocates the buffer lazily and seeks first, so
+			// readers tracking their own position resync
+			in.buffer = nil
+			in.bufferLength = 0
+		}
+	}
+}
+
 func (in *BufferedIndexInput) newBuffer(newBuffer []byte) {
 	// Subclasses can do something here
 	in.buffer = newBuffer
@@ -54,8 +125,8 @@ func (in *BufferedIndexInput) ReadByte() (b byte, err error) {
 }
 
 func checkBufferSize(bufferSize int) {
-	assert2(bufferSize >= MIN_BUFFER_SIZE,
-		"bufferSize must be at least MIN_BUFFER_SIZE (got %v)",
+	assert2(bufferSize >= MIN_BUFFER_SIZE && bufferSize <= MAX_BUFFER_SIZE,
+		"bufferSize must be between MIN_BUFFER_SIZE and MAX_BUFFER_SIZE (got %v)",
 		bufferSize)
 }
 
@@ -63,6 +134,14 @@ func (in *BufferedIndexInput) ReadBytes(buf []byte) error {
 	return in.ReadBytesBuffered(buf, true)
 }
 
+/*
+Reads len(buf) bytes. Whatever is left in the buffer is served first.
+If the rest is smaller than the buffer and useBuffer is true, the
+buffer is refilled and the rest copied from it; otherwise the rest is
+read straight into buf, and the buffer is emptied so the next read
+refills from the new file pointer. Callers that keep their own buffer
+pass false to avoid copying the bytes twice.
+*/
 func (in *BufferedIndexInput) ReadBytesBuffered(buf []byte, useBuffer bool) error {
 	available := in.bufferLength - in.bufferPosition
 	if length := len(buf); length <= available {
@@ -89,7 +168,7 @@ func (in *BufferedIndexInput) ReadBytesBuffered(buf []byte, useBuffer bool) erro
 			if in.bufferLength < length {
 				// Throw an exception when refill() could not read len bytes:
 				copy(buf, in.buffer[0:in.bufferLength])
-				return errors.New(fmt.Sprintf("read past EOF: %v", in))
+				return newEOFError(in)
 			} else {
 				copy(buf, in.buffer[0:length])
 				in.bufferPosition += length
@@ -105,7 +184,7 @@ func (in *BufferedIndexInput) ReadBytesBuffered(buf []byte, useBuffer bool) erro
 			length := len(buf)
 			after := in.bufferStart + int64(in.bufferPosition) + int64(length)
 			if after > in.spi.Length() {
-				return errors.New(fmt.Sprintf("read past EOF: %v", in))
+				return newEOFError(in)
 			}
 			if err := in.spi.readInternal(buf); err != nil {
 				return err
@@ -248,7 +327,6 @@ func (in *BufferedIndexInput) ReadVLong() (n int64, err error) {
 	return in.DataInputImpl.ReadVLong()
 }
 
-// use panic/recover to handle error
 func (in *BufferedIndexInput) refill() error {
 	start := in.bufferStart + int64(in.bufferPosition)
 	end := start + int64(in.bufferSize)
@@ -257,14 +335,22 @@ func (in *BufferedIndexInput) refill() error {
 	}
 	newLength := int(end - start)
 	if newLength <= 0 {
-		return errors.New(fmt.Sprintf("read past EOF: %v", in))
+		return newEOFError(in)
 	}
 
 	if in.buffer == nil {
 		in.newBuffer(make([]byte, in.bufferSize)) // allocate buffer lazily
-		in.spi.seekInternal(int64(in.bufferStart))
+		if err := in.spi.seekInternal(int64(in.bufferStart)); err != nil {
+			return err
+		}
+	}
+	if err := in.spi.readInternal(in.buffer[0:newLength]); err != nil {
+		// leave the buffer empty so we don't serve a partial read
+		in.bufferStart = start
+		in.bufferPosition = 0
+		in.bufferLength = 0
+		return err
 	}
-	in.spi.readInternal(in.buffer[0:newLength])
 	in.bufferLength = newLength
 	in.bufferStart = start
 	in.bufferPosition = 0
@@ -290,6 +376,7 @@ func (in *BufferedIndexInput) Seek(pos int64) error {
 func (in *BufferedIndexInput) Clone() *BufferedIndexInput {
 	ans := &BufferedIndexInput{
 		bufferSize:     in.bufferSize,
+		normalSize:     in.normalSize,
 		buffer:         nil,
 		bufferStart:    in.FilePointer(),
 		bufferLength:   0,
@@ -299,8 +386,86 @@ func (in *BufferedIndexInput) Clone() *BufferedIndexInput {
 	return ans
 }
 
+/* Slices the input embedding this BufferedIndexInput, through a clone of it. */
 func (in *BufferedIndexInput) Slice(desc string, offset, length int64) (IndexInput, error) {
-	panic("not implemented yet")
+	base, ok := in.spi.(IndexInput)
+	assert2(ok, "slice() is not supported by %v", in)
+	return wrapIndexInput(desc, base, offset, length), nil
+}
+
+/*
+Wraps a portion of another IndexInput with buffering. The other input
+is cloned, so the file is not reopened and reading the slice doesn't
+move other's file pointer.
+*/
+func wrapIndexInput(desc string, other IndexInput, offset, length int64) IndexInput {
+	assert2(offset >= 0 && length >= 0 && offset+length <= other.Length(),
+		"slice() %v out of bounds: %v", desc, other)
+	return newSlicedIndexInput(desc, other.Clone(), offset, length)
+}
+
+/* Implementation of an IndexInput that reads from a portion of a file. */
+type SlicedIndexInput struct {
+	*BufferedIndexInput
+	base       IndexInput
+	isClone    bool // clones don't own base
+	fileOffset int64
+	length     int64
+}
+
+func newSlicedIndexInput(desc string, base IndexInput, offset, length int64) *SlicedIndexInput {
+	ans := &SlicedIndexInput{base: base, fileOffset: offset, length: length}
+	ans.BufferedIndexInput = newBufferedIndexInputBySize(ans, fmt.Sprintf(
+		"SlicedIndexInput(%v in %v slice=%v:%v)", desc, base, offset, offset+length),
+		BUFFER_SIZE)
+	return ans
+}
+
+func (in *SlicedIndexInput) readInternal(buf []byte) error {
+	start := in.FilePointer()
+	if start+int64(len(buf)) > in.length {
+		return newEOFError(in)
+	}
+	if err := in.base.Seek(in.fileOffset + start); err != nil {
+		return err
+	}
+	return in.base.ReadBytes(buf)
+}
+
+func (in *SlicedIndexInput) seekInternal(pos int64) error {
+	return nil // nothing to do
+}
+
+func (in *SlicedIndexInput) Close() error {
+	if !in.isClone {
+		return in.base.Close()
+	}
+	return nil
+}
+
+func (in *SlicedIndexInput) Length() int64 {
+	return in.length
+}
+
+func (in *SlicedIndexInput) Clone() IndexInput {
+	// the clone reads through its own clone of base, so that its
+	// file pointer is independent, but leaves closing to the original
+	ans := &SlicedIndexInput{
+		in.BufferedIndexInput.Clone(),
+		in.base.Clone(),
+		true,
+		in.fileOffset,
+		in.length,
+	}
+	ans.spi = ans
+	return ans
+}
+
+/* Slices the same base input, so nested slices compose their offsets. */
+func (in *SlicedIndexInput) Slice(desc string, offset, length int64) (IndexInput, error) {
+	assert2(offset >= 0 && length >= 0 && offset+length <= in.length,
+		"slice() %v out of bounds: %v", desc, in)
+	return newSlicedIndexInput(desc, in.base.Clone(), in.fileOffset+offset, length), nil
 }
 
 /* The default buffer size in bytes. */
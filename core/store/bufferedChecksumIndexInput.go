@@ -16,7 +16,7 @@ type BufferedChecksumIndexInput struct {
 	digest hash.Hash32
 }
 
-func newBufferedChecksumIndexInput(main IndexInput) *BufferedChecksumIndexInput {
+func NewBufferedChecksumIndexInput(main IndexInput) *BufferedChecksumIndexInput {
 	ans := &BufferedChecksumIndexInput{
 		main:   main,
 		digest: crc32.NewIEEE(),
@@ -56,6 +56,10 @@ func (in *BufferedChecksumIndexInput) Length() int64 {
 	return in.main.Length()
 }
 
+func (in *BufferedChecksumIndexInput) SetReadAdvice(advice ReadAdvice) {
+	in.main.SetReadAdvice(advice)
+}
+
 func (in *BufferedChecksumIndexInput) Clone() IndexInput {
 	panic("not supported")
 }
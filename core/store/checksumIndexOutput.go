@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	"hash"
+	"hash/crc32"
+)
+
+// store/ChecksumIndexOutput.java
+
+/*
+Writes bytes through to a primary IndexOutput, computing checksum.
+Useful to checksum an output whose own Checksum() isn't reliable, or
+to checksum just the bytes written through this wrapper.
+*/
+type ChecksumIndexOutput struct {
+	*IndexOutputImpl
+	main   IndexOutput
+	digest hash.Hash32
+}
+
+func NewChecksumIndexOutput(main IndexOutput) *ChecksumIndexOutput {
+	ans := &ChecksumIndexOutput{
+		main:   main,
+		digest: crc32.NewIEEE(),
+	}
+	ans.IndexOutputImpl = NewIndexOutput(ans)
+	return ans
+}
+
+func (out *ChecksumIndexOutput) WriteByte(b byte) error {
+	out.digest.Write([]byte{b})
+	return out.main.WriteByte(b)
+}
+
+func (out *ChecksumIndexOutput) WriteBytes(p []byte) error {
+	out.digest.Write(p)
+	return out.main.WriteBytes(p)
+}
+
+func (out *ChecksumIndexOutput) Checksum() int64 {
+	return int64(out.digest.Sum32())
+}
+
+func (out *ChecksumIndexOutput) FilePointer() int64 {
+	return out.main.FilePointer()
+}
+
+/*
+Appends the codec footer (magic, algorithm ID and the checksum of all
+bytes written so far), to be validated with codec.CheckFooter().
+*/
+func (out *ChecksumIndexOutput) WriteFooter() error {
+	return codec.WriteFooter(out)
+}
+
+func (out *ChecksumIndexOutput) Close() error {
+	return out.main.Close()
+}
+
+func (out *ChecksumIndexOutput) String() string {
+	return fmt.Sprintf("ChecksumIndexOutput(%v)", out.main)
+}
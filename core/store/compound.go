@@ -134,7 +134,39 @@ func (d *CompoundFileDirectory) OpenInput(name string, context IOContext) (in In
 	panic(fmt.Sprintf("No sub-file with id %v found (fileName=%v files: %v)", id, name, keys))
 }
 
+/*
+Verifies the checksum of the named sub-file against its codec footer.
+The footer sits at the end of the entry, not at the end of the
+compound file, so all of the entry's bytes are read up to there.
+Compound files older than CFD_VERSION_CHECKSUM carry no footers and
+are not verified.
+*/
+func (d *CompoundFileDirectory) CheckEntry(name string) (err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	assert(!d.openForWrite)
+	if d.version < CFD_VERSION_CHECKSUM {
+		return nil
+	}
+	id := util.StripSegmentName(name)
+	entry, ok := d.entries[id]
+	if !ok {
+		return errors.New(fmt.Sprintf("No sub-file with id %v found (fileName=%v)", id, name))
+	}
+	if entry.length < codec.FOOTER_LENGTH {
+		return errors.New(fmt.Sprintf(
+			"sub-file %v is too short (%v bytes) to hold a codec footer", name, entry.length))
+	}
+	in, err := d.handle.Slice(name, entry.offset, d.handle.Length()-entry.offset)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return codec.SkipToFooter(NewBufferedChecksumIndexInput(in), entry.length-codec.FOOTER_LENGTH)
+}
+
 func (d *CompoundFileDirectory) ListAll() (paths []string, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	// if self.writer != nil {
 	// 	return self.writer.ListAll()
@@ -167,6 +199,7 @@ func (d *CompoundFileDirectory) FileLength(name string) (n int64, err error) {
 }
 
 func (d *CompoundFileDirectory) CreateOutput(name string, context IOContext) (out IndexOutput, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	return d.writer.createOutput(name, context)
 }
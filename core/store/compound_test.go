@@ -1,8 +1,13 @@
 package store
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/balzaczyy/golucene/core/codec"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -24,3 +29,133 @@ func TestCheckHeaderWin8(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// Writes a compound data file holding one entry, with a codec footer,
+// surrounded by unrelated bytes, and opens it as a compound directory.
+func newTestCompoundEntry(t *testing.T, path string, corrupt bool) *CompoundFileDirectory {
+	entry := []byte("entry payload")
+	var footer [8]byte
+	magic := int32(codec.FOOTER_MAGIC)
+	binary.BigEndian.PutUint32(footer[:], uint32(magic))
+	entry = append(entry, footer[:]...)
+	var sum [8]byte
+	binary.BigEndian.PutUint64(sum[:], uint64(crc32.ChecksumIEEE(entry)))
+	entry = append(entry, sum[:]...)
+	if corrupt {
+		entry[0] ^= 0xFF
+	}
+
+	data := append([]byte("head"), entry...)
+	data = append(data, "tail bytes"...)
+	if err := ioutil.WriteFile(filepath.Join(path, "_0.cfs"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfd := &CompoundFileDirectory{
+		directory: d,
+		entries:   map[string]FileSlice{".pos": {4, int64(len(entry))}},
+		version:   CFD_VERSION_CHECKSUM,
+	}
+	cfd.DirectoryImpl = NewDirectoryImpl(cfd)
+	cfd.BaseDirectory = NewBaseDirectory(cfd)
+	if cfd.handle, err = d.OpenInput("_0.cfs", IO_CONTEXT_DEFAULT); err != nil {
+		t.Fatal(err)
+	}
+	return cfd
+}
+
+func TestCompoundCheckEntry(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	cfd := newTestCompoundEntry(t, path, false)
+	if err = cfd.CheckEntry("_0.pos"); err != nil {
+		t.Error(err)
+	}
+	cfd.handle.Close()
+
+	cfd = newTestCompoundEntry(t, path, true)
+	if err = cfd.CheckEntry("_0.pos"); err == nil {
+		t.Error("corrupted entry should fail validation")
+	}
+	cfd.handle.Close()
+}
+
+func TestCompoundInterleavedEntryReads(t *testing.T) {
+	d := NewRAMDirectory()
+	defer d.Close()
+	w, err := NewCompoundFileDirectory(d, "_0.cfs", IO_CONTEXT_DEFAULT, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"_0.a", "_0.b"} {
+		out, err := w.CreateOutput(name, IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, 100)
+		for j := range data {
+			data[j] = byte(100*i + j)
+		}
+		assert2(out.WriteBytes(data) == nil, "write failed")
+		assert2(out.Close() == nil, "close failed")
+	}
+	assert2(w.Close() == nil, "close failed")
+
+	cfd, err := NewCompoundFileDirectory(d, "_0.cfs", IO_CONTEXT_DEFAULT, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cfd.Close()
+	open := func(name string) IndexInput {
+		in, err := cfd.OpenInput(name, IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return in
+	}
+	slicer, err := cfd.CreateSlicer("_0.b", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slicer.Close()
+
+	// reading one sub-file never moves another's position
+	inputs := []IndexInput{open("_0.a"), open("_0.b"), slicer.OpenFullSlice(), slicer.OpenSlice("b", 50, 50)}
+	starts := []int{0, 100, 100, 150}
+	for i := 0; i < 50; i++ {
+		for j, in := range inputs {
+			if b, err := in.ReadByte(); err != nil || b != byte(starts[j]+i) {
+				t.Fatalf("%v: read %v (%v) at %v, expected %v", in, b, err, i, starts[j]+i)
+			}
+		}
+	}
+
+	// nor concurrently, each reader with its own input
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func(name string, start int) {
+			in, err := cfd.OpenInput(name, IO_CONTEXT_DEFAULT)
+			if err == nil {
+				for j := 0; j < 100 && err == nil; j++ {
+					var b byte
+					if b, err = in.ReadByte(); err == nil && b != byte(start+j) {
+						err = fmt.Errorf("%v: read %v at %v, expected %v", in, b, j, start+j)
+					}
+				}
+			}
+			done <- err
+		}([]string{"_0.a", "_0.b"}[i%2], 100*(i%2))
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
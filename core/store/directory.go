@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/balzaczyy/golucene/core/util"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -35,6 +36,8 @@ type IOContext struct {
 	MergeInfo *MergeInfo
 	FlushInfo *FlushInfo
 	readOnce  bool
+	// overrides the default buffer size for the context, if > 0
+	bufferSize int
 }
 
 func NewIOContextForFlush(flushInfo *FlushInfo) IOContext {
@@ -71,6 +74,30 @@ func NewIOContextForMerge(mergeInfo *MergeInfo) IOContext {
 	}
 }
 
+/*
+Returns a copy of this context asking inputs and outputs to use
+buffers of the given size, e.g. larger buffers for merges on spinning
+disks. A size of 0 restores the default for the context type.
+*/
+func (ctx IOContext) WithBufferSize(size int) IOContext {
+	assert2(size == 0 || size >= MIN_BUFFER_SIZE && size <= MAX_BUFFER_SIZE,
+		"bufferSize must be 0 or between MIN_BUFFER_SIZE and MAX_BUFFER_SIZE (got %v)", size)
+	ctx.bufferSize = size
+	return ctx
+}
+
+/*
+Returns the read advice matching this context: merges and read-once
+files are consumed front to back, everything else is left to the
+default.
+*/
+func (ctx IOContext) ReadAdvice() ReadAdvice {
+	if ctx.context == IO_CONTEXT_TYPE_MERGE || ctx.readOnce {
+		return READ_ADVICE_SEQUENTIAL
+	}
+	return READ_ADVICE_NORMAL
+}
+
 func (ctx IOContext) String() string {
 	return fmt.Sprintf("IOContext [context=%v, mergeInfo=%v, flushInfo=%v, readOnce=%v",
 		ctx.context, ctx.MergeInfo, ctx.FlushInfo, ctx.readOnce)
@@ -120,6 +147,9 @@ type Lock interface {
 	// Returns true if the resource is currently locked. Note that one
 	// must still call obtain() before using the resource.
 	IsLocked() bool
+	// Returns the root cause recorded by the last failed obtain, if
+	// any.
+	FailureReason() error
 }
 
 type LockImpl struct {
@@ -133,6 +163,10 @@ func NewLockImpl(self Lock) *LockImpl {
 	return &LockImpl{self: self}
 }
 
+func (lock *LockImpl) FailureReason() error {
+	return lock.failureReason
+}
+
 func (lock *LockImpl) ObtainWithin(lockWaitTimeout int64) (locked bool, err error) {
 	lock.failureReason = nil
 	locked, err = lock.self.Obtain()
@@ -146,11 +180,7 @@ func (lock *LockImpl) ObtainWithin(lockWaitTimeout int64) (locked bool, err erro
 	maxSleepCount := lockWaitTimeout / LOCK_POOL_INTERVAL
 	for sleepCount := int64(0); !locked; locked, err = lock.self.Obtain() {
 		if lockWaitTimeout != LOCK_OBTAIN_WAIT_FOREVER && sleepCount >= maxSleepCount {
-			reason := fmt.Sprintf("Lock obtain time out: %v", lock)
-			if lock.failureReason != nil {
-				reason = fmt.Sprintf("%v: %v", reason, lock.failureReason)
-			}
-			err = errors.New(reason)
+			err = NewLockObtainFailedError(fmt.Sprintf("Lock obtain time out: %v", lock), lock.failureReason)
 			return
 		}
 		sleepCount++
@@ -224,7 +254,8 @@ type Directory interface {
 	// Returns true iff a file with the given name exists.
 	// @deprecated This method will be removed in 5.0
 	FileExists(name string) bool
-	// Removes an existing file in the directory.
+	// Removes an existing file in the directory. If the file doesn't
+	// exist, the returned error satisfies os.IsNotExist().
 	DeleteFile(name string) error
 	// Returns the length of a file in the directory. This method
 	// follows the following contract:
@@ -261,6 +292,7 @@ type Directory interface {
 type DirectoryImplSPI interface {
 	OpenInput(string, IOContext) (IndexInput, error)
 	LockFactory() LockFactory
+	EnsureOpen()
 }
 
 type DirectoryImpl struct {
@@ -276,7 +308,7 @@ func (d *DirectoryImpl) OpenChecksumInput(name string, ctx IOContext) (ChecksumI
 	if err != nil {
 		return nil, err
 	}
-	return newBufferedChecksumIndexInput(in), nil
+	return NewBufferedChecksumIndexInput(in), nil
 }
 
 /*
@@ -291,6 +323,49 @@ func (d *DirectoryImpl) LockID() string {
 	return fmt.Sprintf("%v", d)
 }
 
+/*
+Obtains the named lock and returns a Closer releasing it, so callers
+can simply defer its Close():
+
+	lock, err := directory.OpenLock("my.lock")
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	// code to execute while locked
+
+Unlike WithLock, the lock is tried only once; an error is returned
+if it's held elsewhere. Closing the returned Closer more than once is
+a no-op.
+*/
+func (d *DirectoryImpl) OpenLock(name string) (io.Closer, error) {
+	lock := d.spi.LockFactory().Make(name)
+	ok, err := lock.Obtain()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Lock obtain failed: %v", lock))
+	}
+	return &lockCloser{lock: lock}, nil
+}
+
+type lockCloser struct {
+	sync.Mutex
+	lock   Lock
+	closed bool
+}
+
+func (c *lockCloser) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.lock.Close()
+}
+
 func (d *DirectoryImpl) String() string {
 	return fmt.Sprintf("@hex lockFactory=%v", d.spi.LockFactory)
 }
@@ -317,119 +392,136 @@ func (d *DirectoryImpl) Copy(to Directory, src, dest string, ctx IOContext) (err
 	defer func() {
 		if success {
 			err = util.Close(os, is)
-		} else {
-			util.CloseWhileSuppressingError(os, is)
+			return
+		}
+		util.CloseWhileSuppressingError(os, is)
+		if os != nil {
+			// don't leave a partially copied file behind
+			defer func() {
+				recover() // ignore panic
+			}()
+			to.DeleteFile(dest) // ignore error
 		}
-		defer func() {
-			recover() // ignore panic
-		}()
-		to.DeleteFile(dest) // ignore error
 	}()
 
-	os, err = to.CreateOutput(dest, ctx)
-	if err != nil {
+	if is, err = d.spi.OpenInput(src, ctx); err != nil {
 		return err
 	}
-	is, err = d.spi.OpenInput(src, ctx)
-	if err != nil {
+	is.SetReadAdvice(ctx.ReadAdvice())
+	if os, err = to.CreateOutput(dest, ctx); err != nil {
 		return err
 	}
-	err = os.CopyBytes(is, is.Length())
-	if err != nil {
-		return err
+	buf := make([]byte, BUFFER_SIZE)
+	for left := is.Length(); left > 0; {
+		n := int64(len(buf))
+		if left < n {
+			n = left
+		}
+		if err = is.ReadBytes(buf[:n]); err != nil {
+			return err
+		}
+		if err = os.WriteBytes(buf[:n]); err != nil {
+			return err
+		}
+		left -= n
 	}
 	success = true
 	return nil
 }
 
-// func (d *DirectoryImpl) CreateSlicer(name string, context IOContext) (is IndexInputSlicer, err error) {
-// 	d.EnsureOpen()
-// 	base, err := d.OpenInput(name, context)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return simpleIndexInputSlicer{base}, nil
-// }
-
-// func (d *DirectoryImpl) EnsureOpen() {
-// 	if !d.IsOpen {
-// 		log.Print("This Directory is closed.")
-// 		panic("this Directory is closed")
-// 	}
-// }
-
-// type IndexInputSlicer interface {
-// 	io.Closer
-// 	OpenSlice(desc string, offset, length int64) IndexInput
-// 	OpenFullSlice() IndexInput
-// }
-
-// type simpleIndexInputSlicer struct {
-// 	base IndexInput
-// }
-
-// func (is simpleIndexInputSlicer) OpenSlice(desc string, offset, length int64) IndexInput {
-// 	return newSlicedIndexInput(fmt.Sprintf("SlicedIndexInput(%v in %v)", desc, is.base),
-// 		is.base, offset, length)
-// }
-
-// func (is simpleIndexInputSlicer) Close() error {
-// 	return is.base.Close()
-// }
-
-// func (is simpleIndexInputSlicer) OpenFullSlice() IndexInput {
-// 	return is.base
-// }
-
-// type SlicedIndexInput struct {
-// 	*BufferedIndexInput
-// 	base       IndexInput
-// 	fileOffset int64
-// 	length     int64
-// }
-
-// func newSlicedIndexInput(desc string, base IndexInput, fileOffset, length int64) *SlicedIndexInput {
-// 	return newSlicedIndexInputBySize(desc, base, fileOffset, length, BUFFER_SIZE)
-// }
-
-// func newSlicedIndexInputBySize(desc string, base IndexInput, fileOffset, length int64, bufferSize int) *SlicedIndexInput {
-// 	ans := &SlicedIndexInput{base: base, fileOffset: fileOffset, length: length}
-// 	ans.BufferedIndexInput = newBufferedIndexInputBySize(ans, fmt.Sprintf(
-// 		"SlicedIndexInput(%v in %v slice=%v:%v)",
-// 		desc, base, fileOffset, fileOffset+length), bufferSize)
-// 	return ans
-// }
-
-// func (in *SlicedIndexInput) readInternal(buf []byte) (err error) {
-// 	start := in.FilePointer()
-// 	if start+int64(len(buf)) > in.length {
-// 		return errors.New(fmt.Sprintf("read past EOF: %v", in))
-// 	}
-// 	in.base.Seek(in.fileOffset + start)
-// 	return in.base.ReadBytesBuffered(buf, false)
-// }
-
-// func (in *SlicedIndexInput) seekInternal(pos int64) error {
-// 	return nil // nothing
-// }
-
-// func (in *SlicedIndexInput) Close() error {
-// 	return in.base.Close()
-// }
-
-// func (in *SlicedIndexInput) Length() int64 {
-// 	return in.length
-// }
-
-// func (in *SlicedIndexInput) Clone() IndexInput {
-// 	return &SlicedIndexInput{
-// 		in.BufferedIndexInput.Clone(),
-// 		in.base.Clone(),
-// 		in.fileOffset,
-// 		in.length,
-// 	}
-// }
-
-// func (in *SlicedIndexInput) String() string {
-// 	return in.desc
-// }
+/*
+Creates an IndexInputSlicer for the given file name. It returns a
+simple default implementation; directories with a cheaper slicing
+strategy may override it.
+*/
+func (d *DirectoryImpl) CreateSlicer(name string, ctx IOContext) (slicer IndexInputSlicer, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.spi.EnsureOpen()
+	base, err := d.spi.OpenInput(name, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newSimpleIndexInputSlicer(base), nil
+}
+
+/* Allows to create one or more sliced IndexInput instances from a single file handle. */
+type IndexInputSlicer interface {
+	io.Closer
+	// Returns an IndexInput slice starting at the given offset with the given length.
+	OpenSlice(desc string, offset, length int64) IndexInput
+	// Returns an IndexInput slice starting at offset 0 with a length equal to the
+	// length of the underlying file.
+	OpenFullSlice() IndexInput
+}
+
+/*
+Slices read through their own clones of base, so each has its own
+file pointer, and share base without owning it. The slicer and the
+full slice each hold a reference to base, which is closed once both
+are released, whatever the order.
+*/
+type simpleIndexInputSlicer struct {
+	sync.Mutex
+	base      IndexInput
+	refCount  int
+	closed    bool
+	fullSlice *fullSliceIndexInput
+}
+
+func newSimpleIndexInputSlicer(base IndexInput) *simpleIndexInputSlicer {
+	return &simpleIndexInputSlicer{base: base, refCount: 1}
+}
+
+func (is *simpleIndexInputSlicer) OpenSlice(desc string, offset, length int64) IndexInput {
+	assert2(offset >= 0 && length >= 0 && offset+length <= is.base.Length(),
+		"slice() %v out of bounds: %v", desc, is.base)
+	ans := newSlicedIndexInput(fmt.Sprintf("SlicedIndexInput(%v in %v)", desc, is.base),
+		is.base.Clone(), offset, length)
+	ans.isClone = true
+	return ans
+}
+
+func (is *simpleIndexInputSlicer) Close() error {
+	is.Lock()
+	defer is.Unlock()
+	if is.closed {
+		return nil
+	}
+	is.closed = true
+	return is.decRef()
+}
+
+func (is *simpleIndexInputSlicer) OpenFullSlice() IndexInput {
+	is.Lock()
+	defer is.Unlock()
+	if is.fullSlice == nil {
+		is.refCount++
+		is.fullSlice = &fullSliceIndexInput{is.base.Clone(), is, false}
+	}
+	return is.fullSlice
+}
+
+// Must be called with the slicer locked.
+func (is *simpleIndexInputSlicer) decRef() error {
+	assert(is.refCount > 0)
+	if is.refCount--; is.refCount == 0 {
+		return is.base.Close()
+	}
+	return nil
+}
+
+type fullSliceIndexInput struct {
+	IndexInput
+	owner  *simpleIndexInputSlicer
+	closed bool
+}
+
+func (in *fullSliceIndexInput) Close() error {
+	in.owner.Lock()
+	defer in.owner.Unlock()
+	if in.closed {
+		return nil
+	}
+	in.closed = true
+	return in.owner.decRef()
+}
@@ -1,10 +1,21 @@
 package store
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"github.com/balzaczyy/golucene/core/codec"
 	"github.com/balzaczyy/golucene/core/util"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func newTestIOContext(r *rand.Rand) IOContext {
@@ -52,3 +63,711 @@ func TestReadingFromSlicedIndexInputOSX(t *testing.T) {
 	codec.CheckHeader(posIn, "Lucene41PostingsWriterPos", 0, 0)
 	// codec header mismatch: actual header=0 vs expected header=1071082519 (resource: SlicedIndexInput(SlicedIndexInput(_0_Lucene41_0.pos in SimpleFSIndexInput(path='/private/tmp/kc/index/belfrysample/_0.cfs')) in SimpleFSIndexInput(path='/private/tmp/kc/index/belfrysample/_0.cfs') slice=1461:3426))
 }
+
+func TestDeleteFile(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	fsDir, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []Directory{NewRAMDirectory(), fsDir} {
+		out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+		assert2(err == nil, "%v", err)
+		assert2(out.WriteInt(42) == nil, "write failed")
+		assert2(out.Close() == nil, "close failed")
+
+		if err = d.DeleteFile("a.bin"); err != nil {
+			t.Errorf("%v: %v", d, err)
+		}
+		if d.FileExists("a.bin") {
+			t.Errorf("%v: deleted file still exists", d)
+		}
+		if n, _ := d.FileLength("a.bin"); n != 0 {
+			t.Errorf("%v: deleted file has length %v", d, n)
+		}
+		if err = d.DeleteFile("a.bin"); !os.IsNotExist(err) {
+			t.Errorf("%v: expected not-exist error, got %v", d, err)
+		}
+	}
+}
+
+func TestFSDirectorySync(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := NewSimpleFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteInt(42) == nil, "write failed")
+	assert2(out.Close() == nil, "close failed")
+	assertEquals(t, d.staleFiles["a.bin"], true)
+
+	if err = d.Sync([]string{"a.bin"}); err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(d.staleFiles), 0)
+	// syncing again is a no-op
+	if err = d.Sync([]string{"a.bin"}); err != nil {
+		t.Error(err)
+	}
+
+	if err = d.Sync([]string{"missing.bin"}); err == nil {
+		t.Error("Sync of a missing file should fail")
+	}
+}
+
+var errTransient = errors.New("transient")
+
+// Fails the first failures calls of each operation with err.
+type flakyDirectory struct {
+	*RAMDirectory
+	failures int
+	err      error
+	calls    map[string]int
+}
+
+func (d *flakyDirectory) fail(op string) error {
+	d.calls[op]++
+	if d.calls[op] <= d.failures {
+		return d.err
+	}
+	return nil
+}
+
+func (d *flakyDirectory) OpenInput(name string, ctx IOContext) (IndexInput, error) {
+	if err := d.fail("OpenInput"); err != nil {
+		return nil, err
+	}
+	return d.RAMDirectory.OpenInput(name, ctx)
+}
+
+func (d *flakyDirectory) CreateOutput(name string, ctx IOContext) (IndexOutput, error) {
+	if err := d.fail("CreateOutput"); err != nil {
+		return nil, err
+	}
+	return d.RAMDirectory.CreateOutput(name, ctx)
+}
+
+func (d *flakyDirectory) DeleteFile(name string) error {
+	if err := d.fail("DeleteFile"); err != nil {
+		return err
+	}
+	return d.RAMDirectory.DeleteFile(name)
+}
+
+func (d *flakyDirectory) Sync(names []string) error {
+	if err := d.fail("Sync"); err != nil {
+		return err
+	}
+	return d.RAMDirectory.Sync(names)
+}
+
+func TestRetryingDirectoryWrapper(t *testing.T) {
+	isTransient := func(err error) bool { return err == errTransient }
+	flaky := &flakyDirectory{NewRAMDirectory(), 2, errTransient, make(map[string]int)}
+	d := NewRetryingDirectoryWrapper(flaky, isTransient, 3, time.Microsecond)
+
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.Close() == nil, "close failed")
+	assert2(d.Sync([]string{"a.bin"}) == nil, "sync failed")
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(in.Close() == nil, "close failed")
+	assert2(d.DeleteFile("a.bin") == nil, "delete failed")
+	for _, op := range []string{"CreateOutput", "Sync", "OpenInput", "DeleteFile"} {
+		assertEquals(t, flaky.calls[op], 3)
+	}
+
+	// gives up after maxRetries
+	flaky = &flakyDirectory{NewRAMDirectory(), 5, errTransient, make(map[string]int)}
+	d = NewRetryingDirectoryWrapper(flaky, isTransient, 3, time.Microsecond)
+	assertEquals(t, d.Sync(nil), errTransient)
+	assertEquals(t, flaky.calls["Sync"], 4)
+
+	// other errors are not retried
+	errPermanent := errors.New("permanent")
+	flaky = &flakyDirectory{NewRAMDirectory(), 5, errPermanent, make(map[string]int)}
+	d = NewRetryingDirectoryWrapper(flaky, isTransient, 3, time.Microsecond)
+	assertEquals(t, d.Sync(nil), errPermanent)
+	assertEquals(t, flaky.calls["Sync"], 1)
+}
+
+func crcOf(t *testing.T, d Directory, name string) uint32 {
+	in, err := d.OpenInput(name, IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	buf := make([]byte, in.Length())
+	if err = in.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	return crc32.ChecksumIEEE(buf)
+}
+
+// Output failing once more than limit bytes were written.
+type failingOutput struct {
+	IndexOutput
+	limit int
+}
+
+func (out *failingOutput) WriteBytes(b []byte) error {
+	if out.limit -= len(b); out.limit < 0 {
+		return errors.New("disk full")
+	}
+	return out.IndexOutput.WriteBytes(b)
+}
+
+type failingOutputDirectory struct {
+	*RAMDirectory
+}
+
+func (d failingOutputDirectory) CreateOutput(name string, ctx IOContext) (IndexOutput, error) {
+	out, err := d.RAMDirectory.CreateOutput(name, ctx)
+	return &failingOutput{out, BUFFER_SIZE}, err
+}
+
+func TestCopy(t *testing.T) {
+	src := NewRAMDirectory()
+	out, err := src.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 3*BUFFER_SIZE+17; i++ {
+		assert2(out.WriteByte(byte(r.Intn(256))) == nil, "write failed")
+	}
+	assert2(out.Close() == nil, "close failed")
+
+	dest := NewRAMDirectory()
+	if err = src.Copy(dest, "a.bin", "b.bin", IO_CONTEXT_READONCE); err != nil {
+		t.Fatal(err)
+	}
+	n1, _ := src.FileLength("a.bin")
+	n2, _ := dest.FileLength("b.bin")
+	assertEquals(t, n1, n2)
+	assertEquals(t, crcOf(t, src, "a.bin"), crcOf(t, dest, "b.bin"))
+
+	// a failed copy leaves nothing behind
+	failing := failingOutputDirectory{NewRAMDirectory()}
+	if err = src.Copy(failing, "a.bin", "b.bin", IO_CONTEXT_DEFAULT); err == nil {
+		t.Error("copy should fail")
+	}
+	assertEquals(t, failing.FileExists("b.bin"), false)
+}
+
+func TestChecksumFooter(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	out := NewChecksumIndexOutput(o)
+	for i := 0; i < 3*BUFFER_SIZE; i++ {
+		assert2(out.WriteByte(byte(i)) == nil, "write failed")
+	}
+	assert2(out.WriteFooter() == nil, "write footer failed")
+	assert2(out.Close() == nil, "close failed")
+
+	checkFooter := func() error {
+		in, err := d.OpenChecksumInput("a.bin", IO_CONTEXT_READONCE)
+		assert2(err == nil, "%v", err)
+		defer in.Close()
+		assert2(in.Seek(in.Length()-codec.FOOTER_LENGTH) == nil, "seek failed")
+		_, err = codec.CheckFooter(in)
+		return err
+	}
+	if err = checkFooter(); err != nil {
+		t.Fatal(err)
+	}
+
+	// flip a byte in the middle of the file
+	f, err := os.OpenFile(filepath.Join(path, "a.bin"), os.O_RDWR, 0)
+	assert2(err == nil, "%v", err)
+	_, err = f.WriteAt([]byte{0xFF}, BUFFER_SIZE)
+	assert2(err == nil, "%v", err)
+	f.Close()
+
+	err = checkFooter()
+	if _, ok := err.(*codec.CorruptIndexError); !ok {
+		t.Errorf("expected CorruptIndexError, got %v", err)
+	}
+}
+
+// RAMOutputStream feeds its checksum a byte at a time, while the
+// reading side sees larger chunks; both must agree.
+func TestRAMChecksumFooter(t *testing.T) {
+	d := NewRAMDirectory()
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteInt(-3) == nil, "write failed")
+	assert2(out.WriteLong(42) == nil, "write failed")
+	assert2(codec.WriteFooter(out) == nil, "write footer failed")
+	assert2(out.Close() == nil, "close failed")
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_READONCE)
+	assert2(err == nil, "%v", err)
+	defer in.Close()
+	cin := NewBufferedChecksumIndexInput(in)
+	_, err = cin.ReadInt()
+	assert2(err == nil, "%v", err)
+	_, err = cin.ReadLong()
+	assert2(err == nil, "%v", err)
+	if _, err = codec.CheckFooter(cin); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createRAMFile(d *RAMDirectory, name string, v int32) error {
+	out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		return err
+	}
+	if err = out.WriteInt(v); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func writeRAMFile(t testing.TB, d *RAMDirectory, name string, v int32) {
+	if err := createRAMFile(d, name, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRAMDirectoryOpenDoesNotLock(t *testing.T) {
+	d := NewRAMDirectory()
+	writeRAMFile(t, d, "a.bin", 42)
+
+	// a writer holding the file map lock must not block readers
+	d.fileMapLock.Lock()
+	done := make(chan error)
+	go func() {
+		in, err := d.OpenInput("a.bin", IO_CONTEXT_READ)
+		if err == nil {
+			in.Close()
+			_, err = d.FileLength("a.bin")
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("OpenInput blocked on the file map lock")
+	}
+	d.fileMapLock.Unlock()
+}
+
+func TestRAMDirectoryConcurrentCreateDeleteOpen(t *testing.T) {
+	d := NewRAMDirectory()
+	const stable = 8
+	for i := 0; i < stable; i++ {
+		writeRAMFile(t, d, fmt.Sprintf("stable%v", i), int32(i))
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan bool)
+	errs := make(chan error, 16)
+	// churn: create and delete other files
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				name := fmt.Sprintf("tmp%v_%v", w, i%4)
+				if err := createRAMFile(d, name, int32(i)); err != nil {
+					errs <- err
+					return
+				}
+				if i%2 == 1 {
+					if err := d.DeleteFile(name); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	// readers: stable files must always be visible and intact
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				name := fmt.Sprintf("stable%v", (r+i)%stable)
+				in, err := d.OpenInput(name, IO_CONTEXT_READ)
+				if err != nil {
+					errs <- err
+					return
+				}
+				v, err := in.ReadInt()
+				in.Close()
+				if err != nil || v != int32((r+i)%stable) {
+					errs <- errors.New(fmt.Sprintf("%v: read %v, %v", name, v, err))
+					return
+				}
+			}
+		}(r)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	names, err := d.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, name := range names {
+		size += d.GetRAMFile(name).RamBytesUsed()
+	}
+	if size != d.RamBytesUsed() {
+		t.Errorf("RamBytesUsed()=%v, but files use %v", d.RamBytesUsed(), size)
+	}
+}
+
+func BenchmarkRAMDirectoryOpenInputParallel(b *testing.B) {
+	d := NewRAMDirectory()
+	for i := 0; i < 16; i++ {
+		writeRAMFile(b, d, fmt.Sprintf("f%v", i), int32(i))
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			in, err := d.OpenInput(fmt.Sprintf("f%v", i%16), IO_CONTEXT_READ)
+			if err != nil {
+				b.Fatal(err)
+			}
+			in.Close()
+		}
+	})
+}
+
+func TestNIOFSConcurrentReads(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := NewNIOFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	data := make([]byte, 64*BUFFER_SIZE+7)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteBytes(data) == nil, "write failed")
+	assert2(out.Close() == nil, "close failed")
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if in.Length() != int64(len(data)) {
+		t.Fatalf("Length()=%v, expected %v", in.Length(), len(data))
+	}
+
+	// every goroutine reads its own region through its own clone, or
+	// through a slice of one, many times over
+	const routines = 16
+	region := len(data) / routines
+	var wg sync.WaitGroup
+	errs := make(chan error, routines)
+	for i := 0; i < routines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := i * region
+			var input IndexInput = in.Clone()
+			if i%2 == 1 {
+				var err error
+				if input, err = input.Slice("slice", int64(start), int64(region)); err != nil {
+					errs <- err
+					return
+				}
+				start = 0
+			}
+			buf := make([]byte, region)
+			for round := 0; round < 10; round++ {
+				if err := input.Seek(int64(start)); err != nil {
+					errs <- err
+					return
+				}
+				if err := input.ReadBytes(buf); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(buf, data[i*region:(i+1)*region]) {
+					errs <- errors.New(fmt.Sprintf(
+						"routine %v read corrupted data in round %v", i, round))
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// reading past the end fails rather than returning short data
+	clone := in.Clone()
+	assert2(clone.Seek(int64(len(data)-3)) == nil, "seek failed")
+	if err = clone.ReadBytes(make([]byte, 4)); err == nil {
+		t.Error("expected error reading past EOF")
+	}
+}
+
+func TestTrackingDirectoryWrapper(t *testing.T) {
+	d := NewTrackingDirectoryWrapper(NewRAMDirectory())
+	defer d.Close()
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.DeleteFile("b.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	created := d.CreatedFiles()
+	if len(created) != 2 || !created["a.bin"] || !created["c.bin"] {
+		t.Errorf("CreatedFiles() returned %v", created)
+	}
+	// the result is a snapshot
+	created["x.bin"] = true
+	if d.ContainsFile("x.bin") {
+		t.Error("CreatedFiles() leaked the internal set")
+	}
+
+	d.ClearCreated()
+	if created = d.CreatedFiles(); len(created) != 0 {
+		t.Errorf("CreatedFiles() returned %v after ClearCreated()", created)
+	}
+	if !d.FileExists("a.bin") {
+		t.Error("ClearCreated() should not delete files")
+	}
+}
+
+// Overrides a single method; everything else falls through.
+type upperCaseDirectory struct {
+	*FilterDirectory
+}
+
+func (d *upperCaseDirectory) CreateOutput(name string, ctx IOContext) (IndexOutput, error) {
+	return d.Directory.CreateOutput(strings.ToUpper(name), ctx)
+}
+
+func TestFilterDirectory(t *testing.T) {
+	ram := NewRAMDirectory()
+	d := &upperCaseDirectory{NewFilterDirectory(ram)}
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteInt(42) == nil, "write failed")
+	assert2(out.Close() == nil, "close failed")
+
+	if !ram.FileExists("A.BIN") {
+		t.Fatal("overridden CreateOutput() was not used")
+	}
+	if names, err := d.ListAll(); err != nil || len(names) != 1 || names[0] != "A.BIN" {
+		t.Errorf("ListAll() returned %v, %v", names, err)
+	}
+	if n, err := d.FileLength("A.BIN"); err != nil || n != 4 {
+		t.Errorf("FileLength() returned %v, %v", n, err)
+	}
+	in, err := d.OpenInput("A.BIN", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := in.ReadInt(); err != nil || n != 42 {
+		t.Errorf("ReadInt() returned %v, %v", n, err)
+	}
+	assert2(in.Close() == nil, "close failed")
+	if err = d.DeleteFile("A.BIN"); err != nil || ram.FileExists("A.BIN") {
+		t.Errorf("DeleteFile() was not forwarded: %v", err)
+	}
+}
+
+func TestUnwrapDirectory(t *testing.T) {
+	ram := NewRAMDirectory()
+	if UnwrapDirectory(ram) != Directory(ram) {
+		t.Error("unwrapping a plain Directory should return it")
+	}
+	nrt := NewNRTCachingDirectory(
+		NewTrackingDirectoryWrapper(NewFilterDirectory(ram)), 5, 60)
+	if UnwrapDirectory(nrt) != Directory(ram) {
+		t.Errorf("failed to unwrap %v", nrt)
+	}
+	if Spins(nrt) {
+		t.Errorf("%v is backed by a RAMDirectory and shouldn't spin", nrt)
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	d := NewRAMDirectory()
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(codec.WriteHeader(out, "foo", 0) == nil, "write header failed")
+	assert2(out.Close() == nil, "close failed")
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_READONCE)
+	assert2(err == nil, "%v", err)
+	_, err = codec.CheckHeader(in, "bar", 0, 0)
+	switch e := err.(type) {
+	case *codec.CorruptIndexError:
+		if !strings.Contains(e.ResourceDescription(), "a.bin") {
+			t.Errorf("expected resource to name a.bin, got %v", e.ResourceDescription())
+		}
+	default:
+		t.Errorf("expected CorruptIndexError, got %v", err)
+	}
+	if !errors.Is(err, codec.ErrCorruptIndex) {
+		t.Errorf("expected errors.Is(%v, ErrCorruptIndex)", err)
+	}
+
+	// read past EOF
+	assert2(in.Seek(in.Length()) == nil, "seek failed")
+	if _, err = in.ReadByte(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected EOF error, got %v", err)
+	}
+	assert2(in.Close() == nil, "close failed")
+
+	// lock timeout
+	l1, l2 := d.MakeLock("test.lock"), d.MakeLock("test.lock")
+	ok, err := l1.Obtain()
+	assert2(ok && err == nil, "obtain failed: %v", err)
+	ok, err = l2.ObtainWithin(0)
+	if ok || !errors.Is(err, ErrLockObtainFailed) {
+		t.Errorf("expected LockObtainFailedError, got %v %v", ok, err)
+	}
+	assert2(l1.Close() == nil, "release failed")
+
+	// closed directory
+	assert2(d.Close() == nil, "close failed")
+	if _, err = d.ListAll(); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError from ListAll(), got %v", err)
+	}
+	if _, err = d.OpenInput("a.bin", IO_CONTEXT_DEFAULT); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError from OpenInput(), got %v", err)
+	}
+	if _, err = d.CreateOutput("b.bin", IO_CONTEXT_DEFAULT); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError from CreateOutput(), got %v", err)
+	}
+	if err = d.DeleteFile("a.bin"); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError from DeleteFile(), got %v", err)
+	}
+}
+
+func TestDefaultSlicer(t *testing.T) {
+	d := NewRAMDirectory()
+	defer d.Close()
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	for i := 0; i < 100; i++ {
+		assert2(out.WriteByte(byte(i)) == nil, "write failed")
+	}
+	assert2(out.Close() == nil, "close failed")
+
+	slicer, err := d.CreateSlicer("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slicer.Close()
+
+	check := func(in IndexInput, offset, length int) {
+		if in.Length() != int64(length) {
+			t.Errorf("%v: expected length %v, got %v", in, length, in.Length())
+		}
+		for i := 0; i < length; i++ {
+			b, err := in.ReadByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b != byte(offset+i) {
+				t.Fatalf("%v: expected %v at %v, got %v", in, offset+i, i, b)
+			}
+		}
+	}
+	check(slicer.OpenFullSlice(), 0, 100)
+	check(slicer.OpenSlice("s", 10, 20), 10, 20)
+
+	// every slice has its own file pointer
+	full := slicer.OpenFullSlice()
+	assert2(full.Seek(0) == nil, "seek failed")
+	parts := []IndexInput{slicer.OpenSlice("s1", 10, 20), slicer.OpenSlice("s2", 50, 20)}
+	for i := 0; i < 20; i++ {
+		for j, in := range []IndexInput{full, parts[0], parts[1]} {
+			b, err := in.ReadByte()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := byte([]int{0, 10, 50}[j] + i); b != expected {
+				t.Fatalf("%v: expected %v at %v, got %v", in, expected, i, b)
+			}
+		}
+	}
+}
+
+func TestBufferedChecksumSmallWrites(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	expected := crc32.ChecksumIEEE(data)
+	// byte by byte, in chunks not dividing the buffer, and mixing
+	// small writes with ones larger than the buffer
+	for _, sizes := range [][]int{{1}, {7}, {3, 300, 1, 64}} {
+		bc := newBufferedChecksumWithBuffer(crc32.NewIEEE(), 32)
+		for off, i := 0, 0; off < len(data); i++ {
+			n := sizes[i%len(sizes)]
+			if off+n > len(data) {
+				n = len(data) - off
+			}
+			bc.Write(data[off : off+n])
+			off += n
+		}
+		if sum := bc.Sum32(); sum != expected {
+			t.Errorf("writes of %v bytes: checksum %x, expected %x", sizes, sum, expected)
+		}
+	}
+}
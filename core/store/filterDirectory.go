@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+)
+
+// store/FilterDirectory.java
+
+/*
+Directory implementation that delegates calls to another directory.
+This type can be used to add limitations on top of an existing
+Directory implementation such as rate limiting or to add additional
+sanity checks for tests. However, if you plan to write your own
+Directory implementation, you should consider embedding
+DirectoryImpl or BaseDirectory rather than FilterDirectory, as
+FilterDirectory is meant to wrap an existing Directory.
+*/
+type FilterDirectory struct {
+	Directory
+}
+
+func NewFilterDirectory(in Directory) *FilterDirectory {
+	assert(in != nil)
+	return &FilterDirectory{in}
+}
+
+// Return the wrapped Directory.
+func (d *FilterDirectory) Delegate() Directory {
+	return d.Directory
+}
+
+/*
+Returns the innermost Directory under any number of FilterDirectory
+layers, e.g. to check which kind of Directory an index really lives
+in. Wrappers are detected by their Delegate() method, so types
+embedding *FilterDirectory are peeled as well.
+*/
+func UnwrapDirectory(dir Directory) Directory {
+	for {
+		filter, ok := dir.(interface {
+			Delegate() Directory
+		})
+		if !ok {
+			return dir
+		}
+		dir = filter.Delegate()
+	}
+}
+
+func (d *FilterDirectory) String() string {
+	return fmt.Sprintf("FilterDirectory(%v)", d.Directory)
+}
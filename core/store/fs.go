@@ -10,7 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
-	// "time"
+	"time"
 )
 
 type NoSuchDirectoryError struct {
@@ -105,6 +105,7 @@ func FSDirectoryListAll(path string) (paths []string, err error) {
 }
 
 func (d *FSDirectory) ListAll() (paths []string, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	return FSDirectoryListAll(d.path)
 }
@@ -117,6 +118,7 @@ func (d *FSDirectory) FileExists(name string) bool {
 
 // Returns the length in bytes of a file in the directory.
 func (d *FSDirectory) FileLength(name string) (n int64, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	fi, err := os.Stat(filepath.Join(d.path, name))
 	if err != nil {
@@ -125,8 +127,20 @@ func (d *FSDirectory) FileLength(name string) (n int64, err error) {
 	return fi.Size(), nil
 }
 
+// Returns the time the named file was last modified.
+func (d *FSDirectory) FileModified(name string) (t time.Time, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	fi, err := os.Stat(filepath.Join(d.path, name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fi.ModTime(), nil
+}
+
 // Removes an existing file in the directory.
 func (d *FSDirectory) DeleteFile(name string) (err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	if err = os.Remove(filepath.Join(d.path, name)); err == nil {
 		d.staleFilesLock.Lock()
@@ -140,12 +154,19 @@ func (d *FSDirectory) DeleteFile(name string) (err error) {
 Creates an IndexOutput for the file with the given name.
 */
 func (d *FSDirectory) CreateOutput(name string, ctx IOContext) (out IndexOutput, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	err = d.ensureCanWrite(name)
 	if err != nil {
 		return nil, err
 	}
-	return newFSIndexOutput(d, name)
+	// never buffer less than CHUNK_SIZE, but honor larger (e.g. merge)
+	// buffers requested by the context
+	size := CHUNK_SIZE
+	if n := bufferSize(ctx); n > size {
+		size = n
+	}
+	return newFSIndexOutput(d, name, size)
 }
 
 func (d *FSDirectory) ensureCanWrite(name string) error {
@@ -176,19 +197,35 @@ func (d *FSDirectory) onIndexOutputClosed(name string) {
 	d.staleFiles[name] = true
 }
 
+/*
+Fsyncs the named files, then the directory itself so that newly
+created files survive a crash. Only files written since they were
+last synced are fsync'ed; syncing the same names again is a no-op.
+Returns an error if a named file doesn't exist.
+*/
 func (d *FSDirectory) Sync(names []string) (err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 
 	toSync := make(map[string]bool)
 	d.staleFilesLock.RLock()
 	for _, name := range names {
 		if _, ok := d.staleFiles[name]; ok {
-			continue
+			toSync[name] = true
 		}
-		toSync[name] = true
 	}
 	d.staleFilesLock.RUnlock()
 
+	for _, name := range names {
+		if toSync[name] {
+			continue
+		}
+		// already synced, but it must still be there
+		if _, err = os.Stat(filepath.Join(d.path, name)); err != nil {
+			return err
+		}
+	}
+
 	for name, _ := range toSync {
 		err = d.fsync(name)
 		if err != nil {
@@ -206,6 +243,8 @@ func (d *FSDirectory) Sync(names []string) (err error) {
 		}
 	}
 
+	d.staleFilesLock.Lock()
+	defer d.staleFilesLock.Unlock()
 	for name, _ := range toSync {
 		delete(d.staleFiles, name)
 	}
@@ -229,30 +268,7 @@ func (d *FSDirectory) Close() error {
 }
 
 func (d *FSDirectory) fsync(name string) error {
-	// var err, err2 error
-	// var success = false
-	// var retryCount = 0
-	// for !success && retryCount < 5 {
-	// 	retryCount++
-	// 	if err2, success = func() (error, bool) {
-	// 		file, err := os.Open(filepath.Join(d.path, name))
-	// 		if err != nil {
-	// 			return err, false
-	// 		}
-	// 		defer file.Close()
-	// 		return file.Sync(), true
-	// 	}(); err2 != nil {
-	// 		if err == nil {
-	// 			err = err2
-	// 		}
-	// 		time.Sleep(5 * time.Millisecond)
-	// 	}
-	// }
-	// return err
-
-	// Go's fsync doesn't work the same way as Java
-	// disabled since I got "fsync: Access is denied" all the time
-	return nil
+	return util.Fsync(filepath.Join(d.path, name), false)
 }
 
 func (d *FSDirectory) String() string {
@@ -336,7 +352,7 @@ type FSIndexOutput struct {
 	name string
 }
 
-func newFSIndexOutput(parent *FSDirectory, name string) (*FSIndexOutput, error) {
+func newFSIndexOutput(parent *FSDirectory, name string, bufferSize int) (*FSIndexOutput, error) {
 	file, err := os.OpenFile(filepath.Join(parent.path, name), os.O_CREATE|os.O_EXCL|os.O_RDWR, 0660)
 	if err != nil {
 		return nil, err
@@ -358,7 +374,7 @@ func newFSIndexOutput(parent *FSDirectory, name string) (*FSIndexOutput, error)
 				offset += n
 			}
 			return offset, nil
-		}), CHUNK_SIZE),
+		}), bufferSize),
 		parent,
 		name,
 	}, nil
@@ -32,6 +32,9 @@ type IndexInput interface {
 	// Creates a slice of this index input, with the given description,
 	// offset, and length. The slice is seeked to the beginning.
 	Slice(desc string, offset, length int64) (IndexInput, error)
+	// Hints how this input is going to be read, so implementations can
+	// tune buffering or prefetching. It never changes what is read.
+	SetReadAdvice(advice ReadAdvice)
 }
 
 type IndexInputImpl struct {
@@ -51,12 +54,38 @@ func (in *IndexInputImpl) String() string {
 	return in.desc
 }
 
+// Default implementation ignores the advice.
+func (in *IndexInputImpl) SetReadAdvice(advice ReadAdvice) {}
+
+/* Expected access pattern of an IndexInput. */
+type ReadAdvice int
+
+const (
+	READ_ADVICE_NORMAL     = ReadAdvice(0)
+	READ_ADVICE_SEQUENTIAL = ReadAdvice(1)
+	READ_ADVICE_RANDOM     = ReadAdvice(2)
+)
+
+func (advice ReadAdvice) String() string {
+	switch advice {
+	case READ_ADVICE_SEQUENTIAL:
+		return "SEQUENTIAL"
+	case READ_ADVICE_RANDOM:
+		return "RANDOM"
+	default:
+		return "NORMAL"
+	}
+}
+
 const (
 	BUFFER_SIZE       = 1024
 	MERGE_BUFFER_SIZE = 4096
 )
 
 func bufferSize(context IOContext) int {
+	if context.bufferSize > 0 {
+		return context.bufferSize
+	}
 	switch context.context {
 	case IO_CONTEXT_TYPE_MERGE:
 		// The normal read buffer size defaults to 1024, but
@@ -74,7 +103,9 @@ func bufferSize(context IOContext) int {
 // store/ByteArrayDataInput.java
 
 // DataInput backed by a byte array.
-// Warning: this class omits all low-level checks.
+// Warning: this class omits most low-level checks; only reads of
+// bytes and fixed-width values fail with io.ErrUnexpectedEOF when
+// they would run past the limit.
 type ByteArrayDataInput struct {
 	*util.DataInputImpl
 	bytes []byte
@@ -121,20 +152,29 @@ func (in *ByteArrayDataInput) SkipBytes(count int64) {
 }
 
 func (in *ByteArrayDataInput) ReadShort() (n int16, err error) {
+	if in.limit-in.Pos < 2 {
+		return 0, io.ErrUnexpectedEOF
+	}
 	in.Pos += 2
 	return (int16(in.bytes[in.Pos-2]) << 8) | int16(in.bytes[in.Pos-1]), nil
 }
 
 func (in *ByteArrayDataInput) ReadInt() (n int32, err error) {
+	if in.limit-in.Pos < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
 	in.Pos += 4
 	return (int32(in.bytes[in.Pos-4]) << 24) | (int32(in.bytes[in.Pos-3]) << 16) |
 		(int32(in.bytes[in.Pos-2]) << 8) | int32(in.bytes[in.Pos-1]), nil
 }
 
 func (in *ByteArrayDataInput) ReadLong() (n int64, err error) {
+	if in.limit-in.Pos < 8 {
+		return 0, io.ErrUnexpectedEOF
+	}
 	i1, _ := in.ReadInt()
 	i2, _ := in.ReadInt()
-	return (int64(i1) << 32) | int64(i2), nil
+	return (int64(i1) << 32) | int64(i2)&0xFFFFFFFF, nil
 }
 
 func (in *ByteArrayDataInput) ReadVInt() (n int32, err error) {
@@ -231,11 +271,17 @@ func (in *ByteArrayDataInput) ReadVLong() (n int64, err error) {
 }
 
 func (in *ByteArrayDataInput) ReadByte() (b byte, err error) {
+	if in.Pos >= in.limit {
+		return 0, io.ErrUnexpectedEOF
+	}
 	in.Pos++
 	return in.bytes[in.Pos-1], nil
 }
 
 func (in *ByteArrayDataInput) ReadBytes(buf []byte) error {
+	if in.limit-in.Pos < len(buf) {
+		return io.ErrUnexpectedEOF
+	}
 	copy(buf, in.bytes[in.Pos:in.Pos+len(buf)])
 	in.Pos += len(buf)
 	return nil
@@ -1,11 +1,17 @@
 package store
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/balzaczyy/golucene/core/util"
+	"io"
 	"io/ioutil"
 	"math"
 	"math/rand"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -253,9 +259,1047 @@ func (in *MyBufferedIndexInput) Slice(desc string, offset, length int64) (IndexI
 }
 
 func (in *MyBufferedIndexInput) Clone() IndexInput {
-	return &MyBufferedIndexInput{
+	ans := &MyBufferedIndexInput{
 		in.BufferedIndexInput.Clone(),
 		in.pos,
 		in.length,
 	}
+	ans.spi = ans
+	return ans
+}
+
+func TestReadAdvice(t *testing.T) {
+	for _, advice := range []ReadAdvice{READ_ADVICE_SEQUENTIAL, READ_ADVICE_RANDOM, READ_ADVICE_NORMAL} {
+		input := newMyBufferedIndexInput(TEST_FILE_LENGTH)
+		// switch in the middle of a buffered region
+		if err := checkReadBytes(input, 10, 0, t); err != nil {
+			t.Fatal(err)
+		}
+		input.SetReadAdvice(advice)
+		if err := checkReadBytes(input, int(TEST_FILE_LENGTH)-10, 10, t); err != nil {
+			t.Errorf("%v: %v", advice, err)
+		}
+	}
+}
+
+func TestSetBufferSize(t *testing.T) {
+	// MyBufferedIndexInput tracks its own position, so dropped buffered
+	// bytes must be re-read from the right place
+	input := newMyBufferedIndexInput(TEST_FILE_LENGTH)
+	pos := 0
+	for _, size := range []int{16, 4096, 64, MIN_BUFFER_SIZE, BUFFER_SIZE, MAX_BUFFER_SIZE} {
+		// switch in the middle of a buffered region
+		if err := checkReadBytes(input, 10, pos, t); err != nil {
+			t.Fatal(err)
+		}
+		pos += 10
+		if err := input.SetBufferSize(size); err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, input.bufferSize, size)
+		large := size + 5 // past the buffer
+		if large > 5000 {
+			large = 5000
+		}
+		for _, n := range []int{3, large, 1} {
+			if err := checkReadBytes(input, n, pos, t); err != nil {
+				t.Fatalf("size %v: %v", size, err)
+			}
+			pos += n
+		}
+	}
+
+	for _, size := range []int{0, MIN_BUFFER_SIZE - 1, MAX_BUFFER_SIZE + 1} {
+		if err := input.SetBufferSize(size); err == nil {
+			t.Errorf("expected error for buffer size %v", size)
+		}
+	}
+	assertEquals(t, input.bufferSize, MAX_BUFFER_SIZE)
+
+	// READ_ADVICE_NORMAL restores the size set by SetBufferSize
+	input.SetBufferSize(64)
+	input.SetReadAdvice(READ_ADVICE_SEQUENTIAL)
+	input.SetReadAdvice(READ_ADVICE_NORMAL)
+	assertEquals(t, input.bufferSize, 64)
+}
+
+func benchmarkReadAdvice(b *testing.B, advice ReadAdvice) {
+	f, err := ioutil.TempFile(TEMP_DIR, "IndexInput")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	if err = writeBytes(f, TEST_FILE_LENGTH); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(TEST_FILE_LENGTH)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in, err := newSimpleFSIndexInput("bench", f.Name(), IO_CONTEXT_DEFAULT)
+		if err != nil {
+			b.Fatal(err)
+		}
+		in.SetReadAdvice(advice)
+		for j := int64(0); j < TEST_FILE_LENGTH; j++ {
+			if _, err = in.ReadByte(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		in.Close()
+	}
+}
+
+func BenchmarkReadSequential(b *testing.B) { benchmarkReadAdvice(b, READ_ADVICE_SEQUENTIAL) }
+func BenchmarkReadRandom(b *testing.B)     { benchmarkReadAdvice(b, READ_ADVICE_RANDOM) }
+
+// Copies a file the way a merge would, with the given merge buffer size.
+func copyForMerge(d Directory, src, dest string, bufferSize int) error {
+	ctx := NewIOContextForMerge(&MergeInfo{MergeMaxNumSegments: -1}).WithBufferSize(bufferSize)
+	return d.Copy(d, src, dest, ctx)
+}
+
+func TestMergeBufferSize(t *testing.T) {
+	path, err := ioutil.TempDir(TEMP_DIR, "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, d, "a.bin", TEST_FILE_LENGTH)
+
+	ctx := NewIOContextForMerge(&MergeInfo{MergeMaxNumSegments: -1})
+	assertEquals(t, bufferSize(ctx), MERGE_BUFFER_SIZE)
+	assertEquals(t, bufferSize(ctx.WithBufferSize(1<<20)), 1<<20)
+
+	for i, size := range []int{0, 1 << 20} {
+		dest := fmt.Sprintf("b%v.bin", i)
+		if err = copyForMerge(d, "a.bin", dest, size); err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, crcOf(t, d, dest), crcOf(t, d, "a.bin"))
+	}
+}
+
+func writeTestFile(t testing.TB, d Directory, name string, length int64) {
+	out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < length; i++ {
+		if err = out.WriteByte(byten(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func benchmarkMergeBufferSize(b *testing.B, bufferSize int) {
+	path, err := ioutil.TempDir(TEMP_DIR, "golucene")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := OpenFSDirectory(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// merges copy whole segments, so use a larger file than other tests
+	const length = 16 << 20
+	writeTestFile(b, d, "a.bin", length)
+	b.SetBytes(length)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = copyForMerge(d, "a.bin", "b.bin", bufferSize); err != nil {
+			b.Fatal(err)
+		}
+		if err = d.DeleteFile("b.bin"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMergeDefaultBuffer(b *testing.B) { benchmarkMergeBufferSize(b, 0) }
+func BenchmarkMergeLargeBuffer(b *testing.B)   { benchmarkMergeBufferSize(b, 256<<10) }
+
+var vIntValues = []int32{0, 1, 127, 128, 16383, 16384, 2097151, 2097152,
+	268435455, 268435456, math.MaxInt32, -1, math.MinInt32}
+
+// Negative vLongs are not supported by WriteVLong().
+var vLongValues = []int64{0, 1, 127, 128, 16383, 16384, 1<<35 - 1, 1 << 35,
+	1<<56 - 1, 1 << 56, math.MaxInt64}
+
+// Writes all vInt and vLong test values to name in d, returning its content.
+func writeVarInts(t *testing.T, d Directory, name string) []byte {
+	out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vIntValues {
+		if err = out.WriteVInt(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range vLongValues {
+		if err = out.WriteVLong(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return readAllBytes(t, d, name)
+}
+
+func readAllBytes(t *testing.T, d Directory, name string) []byte {
+	in, err := d.OpenInput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	buf := make([]byte, in.Length())
+	if err = in.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func verifyVarInts(t *testing.T, in util.DataInput) {
+	for _, v := range vIntValues {
+		n, err := in.ReadVInt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, n, v)
+	}
+	for _, v := range vLongValues {
+		n, err := in.ReadVLong()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, n, v)
+	}
+}
+
+func TestVIntVLongRoundTrip(t *testing.T) {
+	path, err := ioutil.TempDir(TEMP_DIR, "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	fsDir, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ramDir := NewRAMDirectory()
+	bytes := writeVarInts(t, ramDir, "v.bin")
+	// 1 byte per 7 bits
+	assertEquals(t, len(writeVarInts(t, fsDir, "v.bin")), len(bytes))
+	assertEquals(t, bytes[2], byte(127))
+	assertEquals(t, bytes[3], byte(0x80))
+	assertEquals(t, bytes[4], byte(1))
+
+	verifyVarInts(t, NewByteArrayDataInput(bytes))
+	for _, d := range []Directory{ramDir, fsDir} {
+		in, err := d.OpenInput("v.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyVarInts(t, in)
+		in.Close()
+	}
+}
+
+func TestVIntVLongInvalid(t *testing.T) {
+	d := NewRAMDirectory()
+	for _, c := range []struct {
+		bytes []byte
+		vLong bool
+	}{
+		// a vInt has at most 5 bytes, and only 4 bits in the last one
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0x1F}, false},
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, false},
+		// a vLong has at most 9 bytes
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}, true},
+		// truncated input
+		{[]byte{0x80}, false},
+		{[]byte{0xFF, 0xFF}, true},
+	} {
+		out, err := d.CreateOutput("bad.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.WriteBytes(c.bytes); err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		in, err := d.OpenInput("bad.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.vLong {
+			_, err = in.ReadVLong()
+		} else {
+			_, err = in.ReadVInt()
+		}
+		if err == nil {
+			t.Errorf("%v should not decode", c.bytes)
+		}
+		in.Close()
+	}
+}
+
+var fixedShortValues = []int16{0, 1, -1, 0x7F, 0x80, 0xFF, math.MaxInt16, math.MinInt16}
+
+var fixedIntValues = []int32{0, 1, -1, -2, 0xFF, 0x80 << 16, math.MaxInt32, math.MinInt32}
+
+var fixedLongValues = []int64{0, 1, -1, 0xFFFFFFFF, 1 << 32, -1 << 32,
+	math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64}
+
+func writeFixedInts(t *testing.T, d Directory, name string) []byte {
+	out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range fixedShortValues {
+		if err = out.WriteShort(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range fixedIntValues {
+		if err = out.WriteInt(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range fixedLongValues {
+		if err = out.WriteLong(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return readAllBytes(t, d, name)
+}
+
+func verifyFixedInts(t *testing.T, in util.DataInput) {
+	for _, v := range fixedShortValues {
+		n, err := in.ReadShort()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, n, v)
+	}
+	for _, v := range fixedIntValues {
+		n, err := in.ReadInt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, n, v)
+	}
+	for _, v := range fixedLongValues {
+		n, err := in.ReadLong()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, n, v)
+	}
+}
+
+func TestZLong(t *testing.T) {
+	values := []int64{0, 1, -1, 63, -64, 1 << 40, -(1 << 40), math.MaxInt64, math.MinInt64}
+	dir := NewRAMDirectory()
+	out, err := dir.CreateOutput("zlong", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if err = out.WriteZLong(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	in, err := dir.OpenInput("zlong", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	for _, v := range values {
+		got, err := in.ReadZLong()
+		if err != nil {
+			t.Fatalf("Reading %v failed: %v", v, err)
+		}
+		assertEquals(t, got, v)
+	}
+	assertEquals(t, in.FilePointer(), in.Length())
+}
+
+func TestFixedIntRoundTrip(t *testing.T) {
+	path, err := ioutil.TempDir(TEMP_DIR, "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	fsDir, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ramDir := NewRAMDirectory()
+	bytes := writeFixedInts(t, ramDir, "f.bin")
+	assertEquals(t, len(bytes),
+		2*len(fixedShortValues)+4*len(fixedIntValues)+8*len(fixedLongValues))
+	assertEquals(t, string(writeFixedInts(t, fsDir, "f.bin")), string(bytes))
+	// big-endian: MinInt16 is the last short
+	assertEquals(t, fmt.Sprintf("% x", bytes[14:16]), "80 00")
+	// -1 is all ones, 0x800000 is not sign extended
+	assertEquals(t, fmt.Sprintf("% x", bytes[16+8:16+16]), "ff ff ff ff ff ff ff fe")
+	assertEquals(t, fmt.Sprintf("% x", bytes[16+20:16+24]), "00 80 00 00")
+
+	verifyFixedInts(t, NewByteArrayDataInput(bytes))
+	for _, d := range []Directory{ramDir, fsDir} {
+		in, err := d.OpenInput("f.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyFixedInts(t, in)
+		in.Close()
+	}
+}
+
+func TestFixedIntPastEOF(t *testing.T) {
+	d := NewRAMDirectory()
+	for _, c := range []struct {
+		size  int
+		width int
+	}{
+		{0, 2}, {1, 2}, {3, 4}, {0, 8}, {4, 8}, {7, 8},
+	} {
+		data := make([]byte, c.size)
+		out, err := d.CreateOutput("eof.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.WriteBytes(data); err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		in, err := d.OpenInput("eof.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range []util.DataInput{in, NewByteArrayDataInput(data)} {
+			switch c.width {
+			case 2:
+				_, err = r.ReadShort()
+			case 4:
+				_, err = r.ReadInt()
+			case 8:
+				_, err = r.ReadLong()
+			}
+			if err == nil {
+				t.Errorf("%v-byte read from %v bytes should fail", c.width, c.size)
+			}
+		}
+		in.Close()
+	}
+
+	in := NewByteArrayDataInput([]byte{1, 2, 3})
+	if _, err := in.ReadShort(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.ReadShort(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if b, err := in.ReadByte(); err != nil || b != 3 {
+		t.Errorf("failed read should not consume bytes: %v, %v", b, err)
+	}
+	if _, err := in.ReadByte(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	d := NewRAMDirectory()
+	long := strings.Repeat("é", util.STRING_CHUNK_SIZE) // spans chunks
+	values := []string{"", "lucene", "café", "日本語", "😀", long}
+	out, err := d.CreateOutput("s.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range values {
+		if err = out.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// length prefixes count bytes, not runes
+	bytes := readAllBytes(t, d, "s.bin")
+	assertEquals(t, bytes[1], byte(6))
+	assertEquals(t, bytes[8], byte(5))
+	assertEquals(t, bytes[14], byte(9))
+	assertEquals(t, bytes[24], byte(4))
+
+	in, err := d.OpenInput("s.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	for _, r := range []util.DataInput{in, NewByteArrayDataInput(bytes)} {
+		for _, s := range values {
+			got, err := r.ReadString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEquals(t, got, s)
+		}
+	}
+}
+
+func TestStringInvalidLength(t *testing.T) {
+	d := NewRAMDirectory()
+	for _, prefix := range [][]byte{
+		{0xFF, 0xFF, 0xFF, 0xFF, 0x0F}, // -1
+		{0xFF, 0xFF, 0xFF, 0xFF, 0x07}, // math.MaxInt32, but only 3 bytes follow
+	} {
+		out, err := d.CreateOutput("bad.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.WriteBytes(append(prefix, 'a', 'b', 'c')); err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		in, err := d.OpenInput("bad.bin", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = in.ReadString(); err == nil {
+			t.Errorf("string with length prefix %v should not decode", prefix)
+		}
+		in.Close()
+	}
+}
+
+func writeStringMaps(t *testing.T, d Directory, name string, m map[string]string, s map[string]bool) []byte {
+	out, err := d.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.WriteStringStringMap(m); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.WriteStringSet(s); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return readAllBytes(t, d, name)
+}
+
+func TestStringMapRoundTrip(t *testing.T) {
+	d := NewRAMDirectory()
+	m := map[string]string{"os": "linux", "source": "flush", "lucene.version": "4.10.0", "": "empty key"}
+	s := map[string]bool{"_0.cfs": true, "_0.cfe": true, "_0.si": true}
+	bytes := writeStringMaps(t, d, "m.bin", m, s)
+
+	// same content, possibly different iteration order
+	m2, s2 := make(map[string]string), make(map[string]bool)
+	for k, v := range m {
+		m2[k] = v
+	}
+	for v, _ := range s {
+		s2[v] = true
+	}
+	if string(writeStringMaps(t, d, "m2.bin", m2, s2)) != string(bytes) {
+		t.Error("equal maps should be written as identical bytes")
+	}
+
+	in := NewByteArrayDataInput(bytes)
+	got, err := in.ReadStringStringMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(got), len(m))
+	for k, v := range m {
+		assertEquals(t, got[k], v)
+	}
+	gotSet, err := in.ReadStringSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEquals(t, len(gotSet), len(s))
+	for v, _ := range s {
+		assertEquals(t, gotSet[v], true)
+	}
+
+	// empty and nil maps both read back as empty, non-nil maps
+	for _, m := range []map[string]string{map[string]string{}, nil} {
+		in = NewByteArrayDataInput(writeStringMaps(t, d, "e.bin", m, nil))
+		got, err = in.ReadStringStringMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Errorf("expected empty non-nil map, got %#v", got)
+		}
+		gotSet, err = in.ReadStringSet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotSet == nil || len(gotSet) != 0 {
+			t.Errorf("expected empty non-nil set, got %#v", gotSet)
+		}
+	}
+
+	// negative size from a corrupt input
+	if _, err = NewByteArrayDataInput([]byte{0xFF, 0xFF, 0xFF, 0xFF}).ReadStringStringMap(); err == nil {
+		t.Error("negative map size should be rejected")
+	}
+}
+
+func TestReaderAtIndexInput(t *testing.T) {
+	data := make([]byte, 3*BUFFER_SIZE+17)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	in := NewReaderAtIndexInput("test", bytes.NewReader(data), int64(len(data)))
+	defer in.Close()
+	if in.Length() != int64(len(data)) {
+		t.Fatalf("length=%v", in.Length())
+	}
+
+	// seek around, across buffer boundaries
+	for _, pos := range []int64{0, 5, BUFFER_SIZE - 1, 2*BUFFER_SIZE + 3, 7, int64(len(data)) - 1} {
+		if err := in.Seek(pos); err != nil {
+			t.Fatal(err)
+		}
+		b, err := in.ReadByte()
+		if err != nil || b != data[pos] {
+			t.Errorf("byte at %v: %v != %v, %v", pos, b, data[pos], err)
+		}
+		if in.FilePointer() != pos+1 {
+			t.Errorf("file pointer=%v after reading at %v", in.FilePointer(), pos)
+		}
+	}
+
+	buf := make([]byte, 2*BUFFER_SIZE)
+	if err := in.Seek(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[10:10+len(buf)]) {
+		t.Error("ReadBytes returned wrong content")
+	}
+
+	// clones and slices keep their own position
+	clone := in.Clone()
+	if clone.FilePointer() != in.FilePointer() {
+		t.Errorf("clone at %v, original at %v", clone.FilePointer(), in.FilePointer())
+	}
+	slice, err := in.Slice("slice", 100, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = clone.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := clone.ReadByte(); err != nil || b != data[0] {
+		t.Errorf("clone: %v, %v", b, err)
+	}
+	if b, err := slice.ReadByte(); err != nil || b != data[100] {
+		t.Errorf("slice: %v, %v", b, err)
+	}
+	if in.FilePointer() != int64(10+len(buf)) {
+		t.Errorf("original moved to %v", in.FilePointer())
+	}
+	if err = slice.Seek(49); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := slice.ReadByte(); err != nil || b != data[149] {
+		t.Errorf("slice end: %v, %v", b, err)
+	}
+	if _, err = slice.ReadByte(); err == nil {
+		t.Error("expected error reading past the end of the slice")
+	}
+
+	if err = in.Seek(int64(len(data)) - 2); err != nil {
+		t.Fatal(err)
+	}
+	if err = in.ReadBytes(make([]byte, 4)); err == nil {
+		t.Error("expected error reading past EOF")
+	}
+}
+
+// Records the length of every positioned read.
+type recordingReaderAt struct {
+	*bytes.Reader
+	reads []int
+}
+
+func (r *recordingReaderAt) ReadAt(buf []byte, off int64) (int, error) {
+	r.reads = append(r.reads, len(buf))
+	return r.Reader.ReadAt(buf, off)
+}
+
+func TestReadBytesBuffered(t *testing.T) {
+	data := make([]byte, 10*BUFFER_SIZE)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	r := &recordingReaderAt{Reader: bytes.NewReader(data)}
+	in := NewReaderAtIndexInput("test", r, int64(len(data)))
+	buf := make([]byte, 3*BUFFER_SIZE)
+
+	read := func(pos int64, size int, useBuffer bool) {
+		if err := in.ReadBytesBuffered(buf[:size], useBuffer); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:size], data[pos:pos+int64(size)]) {
+			t.Fatalf("wrong bytes reading %v at %v (useBuffer=%v)", size, pos, useBuffer)
+		}
+		if in.FilePointer() != pos+int64(size) {
+			t.Fatalf("file pointer=%v after reading %v at %v", in.FilePointer(), size, pos)
+		}
+	}
+	lastRead := func() int { return r.reads[len(r.reads)-1] }
+
+	read(0, 10, true)
+	if len(r.reads) != 1 || lastRead() != BUFFER_SIZE {
+		t.Errorf("small read should fill the buffer: %v", r.reads)
+	}
+	// the rest of the buffer is served first, the remainder bypasses it
+	read(10, BUFFER_SIZE+100, false)
+	if len(r.reads) != 2 || lastRead() != 110 {
+		t.Errorf("unbuffered read should not refill: %v", r.reads)
+	}
+	// the buffer was invalidated, so this refills from the new position
+	read(BUFFER_SIZE+110, 4, true)
+	if len(r.reads) != 3 || lastRead() != BUFFER_SIZE {
+		t.Errorf("expected a refill: %v", r.reads)
+	}
+	// large reads bypass the buffer even if it's allowed
+	read(BUFFER_SIZE+114, 2*BUFFER_SIZE, true)
+	if lastRead() != 2*BUFFER_SIZE-(BUFFER_SIZE-4) {
+		t.Errorf("large read went through the buffer: %v", r.reads)
+	}
+
+	// random interleaving, including seeks
+	rnd := random()
+	pos := in.FilePointer()
+	for i := 0; i < 1000; i++ {
+		if rnd.Intn(10) == 0 {
+			pos = rnd.Int63n(int64(len(data)))
+			if err := in.Seek(pos); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var size int
+		if rnd.Intn(2) == 0 {
+			size = rnd.Intn(16)
+		} else {
+			size = rnd.Intn(len(buf))
+		}
+		if left := int64(len(data)) - pos; int64(size) > left {
+			size = int(left)
+		}
+		read(pos, size, rnd.Intn(2) == 0)
+		pos += int64(size)
+	}
+}
+
+func TestRandomAccessInput(t *testing.T) {
+	path, err := ioutil.TempDir(TEMP_DIR, "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, d, "a.bin", TEST_FILE_LENGTH)
+	data := make([]byte, TEST_FILE_LENGTH)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+
+	fsInput, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsInput.Close()
+	readerAtInput := NewReaderAtIndexInput("a.bin", bytes.NewReader(data), TEST_FILE_LENGTH)
+	if _, ok := readerAtInput.(RandomAccessSlicer); !ok {
+		t.Fatal("ReaderAtIndexInput should be a RandomAccessSlicer")
+	}
+
+	const offset, length = 1000, 50000
+	rnd := random()
+	positions := make([]int64, 500)
+	for i := range positions {
+		positions[i] = rnd.Int63n(length - 8)
+	}
+	readAll := func(ra RandomAccessInput, order []int) []int64 {
+		values := make([]int64, len(positions))
+		for _, i := range order {
+			pos := positions[i]
+			b, err := ra.ReadByteAt(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := ra.ReadShortAt(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := ra.ReadIntAt(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, err := ra.ReadLongAt(pos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b != byte(l>>56) || s != int16(l>>48) || n != int32(l>>32) {
+				t.Fatalf("inconsistent values at %v: %x %x %x %x", pos, b, s, n, l)
+			}
+			values[i] = l
+		}
+		return values
+	}
+
+	for _, in := range []IndexInput{fsInput, readerAtInput} {
+		if err = in.Seek(77); err != nil {
+			t.Fatal(err)
+		}
+		ra, err := NewRandomAccessInput(in, offset, length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEquals(t, ra.Length(), int64(length))
+		first := readAll(ra, rnd.Perm(len(positions)))
+		second := readAll(ra, rnd.Perm(len(positions)))
+		for i, pos := range positions {
+			expected := int64(0)
+			for _, b := range data[offset+pos : offset+pos+8] {
+				expected = expected<<8 | int64(b)
+			}
+			if first[i] != expected || second[i] != expected {
+				t.Errorf("%v: long at %v: %x, %x != %x", in, pos, first[i], second[i], expected)
+			}
+		}
+
+		// concurrent readers
+		var wg sync.WaitGroup
+		results := make([][]int64, 4)
+		for g := range results {
+			wg.Add(1)
+			go func(g int, order []int) {
+				defer wg.Done()
+				values := make([]int64, len(positions))
+				for _, i := range order {
+					values[i], _ = ra.ReadLongAt(positions[i])
+				}
+				results[g] = values
+			}(g, rnd.Perm(len(positions)))
+		}
+		wg.Wait()
+		for _, values := range results {
+			for i := range positions {
+				if values[i] != first[i] {
+					t.Fatalf("%v: concurrent read at %v: %x != %x", in, positions[i], values[i], first[i])
+				}
+			}
+		}
+
+		if _, err = ra.ReadLongAt(length - 7); err == nil {
+			t.Errorf("%v: expected error reading past the end", in)
+		}
+		if _, err = ra.ReadByteAt(-1); err == nil {
+			t.Errorf("%v: expected error reading before the start", in)
+		}
+		assertEquals(t, in.FilePointer(), int64(77))
+	}
+}
+
+func TestNestedSlice(t *testing.T) {
+	data := make([]byte, 3*BUFFER_SIZE)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	fsDir, err := OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsDir.Close()
+
+	for _, d := range []Directory{NewRAMDirectory(), fsDir} {
+		out, err := d.CreateOutput("slices", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = out.WriteBytes(data); err != nil {
+			t.Fatal(err)
+		}
+		if err = out.Close(); err != nil {
+			t.Fatal(err)
+		}
+		in, err := d.OpenInput("slices", IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		outer, err := in.Slice("outer", 100, 2*BUFFER_SIZE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inner, err := outer.Slice("inner", BUFFER_SIZE-10, BUFFER_SIZE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inner.Length() != BUFFER_SIZE {
+			t.Errorf("%v: expected length %v, got %v", d, BUFFER_SIZE, inner.Length())
+		}
+		// position 0 of the inner slice maps to 100+BUFFER_SIZE-10
+		buf := make([]byte, BUFFER_SIZE)
+		if err = inner.ReadBytes(buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, data[100+BUFFER_SIZE-10:100+2*BUFFER_SIZE-10]) {
+			t.Errorf("%v: inner slice returned wrong content", d)
+		}
+		if _, err = inner.ReadByte(); err == nil {
+			t.Errorf("%v: expected error reading past the end of the slice", d)
+		}
+		if err = outer.Seek(BUFFER_SIZE - 10); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := outer.ReadByte(); err != nil || b != buf[0] {
+			t.Errorf("%v: outer slice read %v (%v), expected %v", d, b, err, buf[0])
+		}
+		if in.FilePointer() != 0 {
+			t.Errorf("%v: slicing moved the original to %v", d, in.FilePointer())
+		}
+		if err = in.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+/* Counts how many times it, or any of its clones, is closed. */
+type closeCountingIndexInput struct {
+	IndexInput
+	closed *int32
+}
+
+func (in closeCountingIndexInput) Close() error {
+	atomic.AddInt32(in.closed, 1)
+	return in.IndexInput.Close()
+}
+
+func (in closeCountingIndexInput) Clone() IndexInput {
+	return closeCountingIndexInput{in.IndexInput.Clone(), in.closed}
+}
+
+func TestSlicedIndexInputClone(t *testing.T) {
+	const offset, length = 10, 5 * BUFFER_SIZE
+	var closed int32
+	base := closeCountingIndexInput{newMyBufferedIndexInput(TEST_FILE_LENGTH), &closed}
+	slice := newSlicedIndexInput("slice", base, offset, length)
+	clone := slice.Clone()
+
+	// both read the whole slice at the same time
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, in := range []IndexInput{slice, clone} {
+		wg.Add(1)
+		go func(i int, in IndexInput) {
+			defer wg.Done()
+			for pos := int64(0); pos < length; pos++ {
+				if b, err := in.ReadByte(); err != nil || b != byten(offset+pos) {
+					errs[i] = fmt.Errorf("byte %v: %v (%v)", pos, b, err)
+					return
+				}
+			}
+		}(i, in)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := clone.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 0 {
+		t.Errorf("closing the clone closed the base %v times", closed)
+	}
+	if err := slice.Seek(0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := slice.ReadByte(); err != nil || b != byten(offset) {
+		t.Errorf("original after closing clone: %v (%v)", b, err)
+	}
+	if err := slice.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 1 {
+		t.Errorf("expected the original to close the base once, got %v", closed)
+	}
+}
+
+func TestSimpleIndexInputSlicerClose(t *testing.T) {
+	orders := [][]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	for _, order := range orders {
+		var closed int32
+		base := closeCountingIndexInput{newMyBufferedIndexInput(TEST_FILE_LENGTH), &closed}
+		slicer := newSimpleIndexInputSlicer(base)
+		closers := []io.Closer{slicer, slicer.OpenFullSlice(), slicer.OpenSlice("slice", 10, 20)}
+		released := make([]bool, len(closers))
+		for i, n := range order {
+			// closing twice must be harmless
+			for j := 0; j < 2; j++ {
+				if err := closers[n].Close(); err != nil {
+					t.Fatalf("%v: %v", order, err)
+				}
+			}
+			released[n] = true
+			want := int32(0)
+			if released[0] && released[1] {
+				want = 1 // base is only owned by the slicer and the full slice
+			}
+			if c := atomic.LoadInt32(&closed); c != want {
+				t.Fatalf("%v: expected base closed %v times after step %v, got %v", order, want, i, c)
+			}
+		}
+	}
 }
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// store/LockVerifyServer.java
+
+/*
+Simple server to which clients using VerifyingLockFactory connect and
+report each lock obtain/release. The server fails if it ever sees the
+lock obtained by a client while another client still holds it, or
+released by a client that doesn't hold it.
+*/
+type LockVerifyServer struct {
+	listener net.Listener
+	sync.Locker
+	lockedID int32 // -1 if nobody holds the lock
+}
+
+func NewLockVerifyServer(listener net.Listener) *LockVerifyServer {
+	return &LockVerifyServer{
+		listener: listener,
+		Locker:   &sync.Mutex{},
+		lockedID: -1,
+	}
+}
+
+// Address clients should connect to.
+func (s *LockVerifyServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+/*
+Accepts maxClients connections and verifies their messages until all
+of them disconnect. Returns the first locking error seen, if any.
+*/
+func (s *LockVerifyServer) Serve(maxClients int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, maxClients)
+	for i := 0; i < maxClients; i++ {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			if err := s.handle(conn); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs // nil if no client failed
+}
+
+func (s *LockVerifyServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *LockVerifyServer) handle(conn io.ReadWriter) error {
+	var buf [5]byte
+	for {
+		if _, err := io.ReadFull(conn, buf[:]); err != nil {
+			if err == io.EOF {
+				return nil // client is done
+			}
+			return err
+		}
+		command, id := buf[0], int32(binary.BigEndian.Uint32(buf[1:]))
+		if err := s.apply(command, id); err != nil {
+			return err
+		}
+		if _, err := conn.Write(buf[:1]); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *LockVerifyServer) apply(command byte, id int32) error {
+	s.Lock()
+	defer s.Unlock()
+	switch command {
+	case 1:
+		// Locked
+		if s.lockedID != -1 {
+			return errors.New(fmt.Sprintf(
+				"id %v got lock, but %v already holds the lock", id, s.lockedID))
+		}
+		s.lockedID = id
+	case 0:
+		// Unlocked
+		if s.lockedID != id {
+			return errors.New(fmt.Sprintf(
+				"id %v released the lock, but %v is the one holding the lock", id, s.lockedID))
+		}
+		s.lockedID = -1
+	default:
+		return errors.New(fmt.Sprintf("Unrecognized command: %v", command))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"net"
+	"testing"
+)
+
+func startLockVerifyServer(t *testing.T, clients int) (*LockVerifyServer, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewLockVerifyServer(l)
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(clients)
+	}()
+	return server, done
+}
+
+func dialVerifyingLockFactory(t *testing.T, server *LockVerifyServer, lf LockFactory, id int32) (*VerifyingLockFactory, net.Conn) {
+	conn, err := net.Dial("tcp", server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewVerifyingLockFactory(lf, id, conn), conn
+}
+
+func TestVerifyingLockFactory(t *testing.T) {
+	server, done := startLockVerifyServer(t, 2)
+	defer server.Close()
+
+	shared := newSingleInstanceLockFactory()
+	f1, c1 := dialVerifyingLockFactory(t, server, shared, 1)
+	f2, c2 := dialVerifyingLockFactory(t, server, shared, 2)
+
+	for i := 0; i < 3; i++ {
+		for _, f := range []*VerifyingLockFactory{f1, f2} {
+			lock := f.Make("test.lock")
+			ok, err := lock.Obtain()
+			if !ok || err != nil {
+				t.Fatalf("obtain failed: %v %v", ok, err)
+			}
+			if err = lock.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	c1.Close()
+	c2.Close()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerifyingLockFactoryDoubleObtain(t *testing.T) {
+	server, done := startLockVerifyServer(t, 2)
+	defer server.Close()
+
+	// NoLockFactory never blocks, so both clients get the "lock"
+	f1, c1 := dialVerifyingLockFactory(t, server, NO_LOCK_FACTORY, 1)
+	f2, c2 := dialVerifyingLockFactory(t, server, NO_LOCK_FACTORY, 2)
+	if _, err := f1.Make("test.lock").Obtain(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f2.Make("test.lock").Obtain(); err == nil {
+		t.Error("second obtain should fail verification")
+	}
+	c1.Close()
+	c2.Close()
+	if err := <-done; err == nil {
+		t.Error("server should report the double obtain")
+	}
+}
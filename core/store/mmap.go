@@ -0,0 +1,401 @@
+//go:build unix
+
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// store/MMapDirectory.java
+
+/*
+Default max chunk size: 1 GB on 64 bit platforms, and 256 MB on 32 bit
+platforms, where the address space is scarce.
+*/
+var DEFAULT_MAX_CHUNK_SIZE = func() int {
+	if strconv.IntSize == 64 {
+		return 1 << 30
+	}
+	return 1 << 28
+}()
+
+/*
+File-based Directory implementation that uses mmap for reading, and
+FSDirectory's output for writing.
+
+Every OpenInput() maps the file in chunks of at most MaxChunkSize()
+bytes, and the returned IndexInput reads directly from the mapped
+memory. Clones and slices share the mapping with independent
+positions; it's unmapped when the input returned by OpenInput() is
+closed, or at the latest when the directory is closed. Reads through
+clones or slices of an unmapped input fail with AlreadyClosedError
+instead of touching the released memory.
+
+NOTE: closing an input while another goroutine is still reading from
+one of its clones is not safe, as in Lucene: the check and the read
+are not atomic.
+*/
+type MMapDirectory struct {
+	*FSDirectory
+	chunkSizePower uint
+	mappingsLock   sync.Mutex
+	mappings       map[*mmapFile]bool // active mappings, released on Close()
+}
+
+func NewMMapDirectory(path string) (*MMapDirectory, error) {
+	return NewMMapDirectoryWithChunkSize(path, DEFAULT_MAX_CHUNK_SIZE)
+}
+
+/*
+Creates an MMapDirectory which maps files in chunks of at most
+maxChunkSize bytes, rounded down to a power of 2. Smaller chunks need
+less contiguous address space, at the cost of more mappings.
+*/
+func NewMMapDirectoryWithChunkSize(path string, maxChunkSize int) (d *MMapDirectory, err error) {
+	assert2(maxChunkSize > 0, "Maximum chunk size for mmap must be >0")
+	d = &MMapDirectory{
+		chunkSizePower: uint(bits.Len(uint(maxChunkSize)) - 1),
+		mappings:       make(map[*mmapFile]bool),
+	}
+	if d.FSDirectory, err = newFSDirectory(d, path); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+/* Returns the current mmap chunk size. */
+func (d *MMapDirectory) MaxChunkSize() int {
+	return 1 << d.chunkSizePower
+}
+
+func (d *MMapDirectory) OpenInput(name string, context IOContext) (IndexInput, error) {
+	in, err := d.openMMapInput(name)
+	if err != nil {
+		return nil, err
+	}
+	in.SetReadAdvice(context.ReadAdvice())
+	return in, nil
+}
+
+func (d *MMapDirectory) openMMapInput(name string) (in *MMapIndexInput, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	fpath := filepath.Join(d.path, name)
+	file, err := mapFile(fpath, d.chunkSizePower)
+	if err != nil {
+		return nil, err
+	}
+	d.mappingsLock.Lock()
+	defer d.mappingsLock.Unlock()
+	d.mappings[file] = true
+	return newMMapIndexInput(fmt.Sprintf("MMapIndexInput(path=\"%v\")", fpath),
+		d, file, 0, file.length), nil
+}
+
+func (d *MMapDirectory) release(file *mmapFile) error {
+	d.mappingsLock.Lock()
+	delete(d.mappings, file)
+	d.mappingsLock.Unlock()
+	return file.unmap()
+}
+
+/*
+Slices returned by the slicer share one mapping and never copy. They
+become unusable once the slicer is closed.
+*/
+func (d *MMapDirectory) CreateSlicer(name string, ctx IOContext) (IndexInputSlicer, error) {
+	full, err := d.openMMapInput(name)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapIndexInputSlicer{full}, nil
+}
+
+/* Unmaps all mappings which are still open, then closes the directory. */
+func (d *MMapDirectory) Close() error {
+	d.mappingsLock.Lock()
+	files := d.mappings
+	d.mappings = make(map[*mmapFile]bool)
+	d.mappingsLock.Unlock()
+
+	var err error
+	for file, _ := range files {
+		if err2 := file.unmap(); err == nil {
+			err = err2
+		}
+	}
+	if err2 := d.FSDirectory.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+type mmapIndexInputSlicer struct {
+	full *MMapIndexInput
+}
+
+func (s *mmapIndexInputSlicer) OpenSlice(desc string, offset, length int64) IndexInput {
+	return s.full.slice(desc, offset, length)
+}
+
+func (s *mmapIndexInputSlicer) OpenFullSlice() IndexInput {
+	return s.full.Clone()
+}
+
+func (s *mmapIndexInputSlicer) Close() error {
+	return s.full.Close()
+}
+
+// Returns the path of dir if it's an MMapDirectory, for Spins().
+func mmapDirectoryPath(dir Directory) (string, bool) {
+	if d, ok := dir.(*MMapDirectory); ok {
+		return d.path, true
+	}
+	return "", false
+}
+
+/* A file mapped in chunks, shared by an input and all its clones and slices. */
+type mmapFile struct {
+	length     int64
+	chunkPower uint
+	chunkMask  int64
+	raw        [][]byte // as returned by mmap, page aligned
+	chunks     [][]byte // chunk i holds bytes [i<<chunkPower, (i+1)<<chunkPower)
+	closed     int32
+}
+
+func mapFile(path string, chunkPower uint) (m *mmapFile, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// the mappings stay valid after the file is closed
+	defer f.Close()
+	fstat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := int64(1) << chunkPower
+	m = &mmapFile{
+		length:     fstat.Size(),
+		chunkPower: chunkPower,
+		chunkMask:  chunkSize - 1,
+	}
+	pageMask := int64(os.Getpagesize() - 1)
+	for offset := int64(0); offset < m.length; offset += chunkSize {
+		length := m.length - offset
+		if length > chunkSize {
+			length = chunkSize
+		}
+		// mmap offsets must be page aligned, chunks don't need to be
+		aligned := offset &^ pageMask
+		var raw []byte
+		if raw, err = syscall.Mmap(int(f.Fd()), aligned, int(offset-aligned+length),
+			syscall.PROT_READ, syscall.MAP_SHARED); err != nil {
+			m.unmap()
+			return nil, errors.New(fmt.Sprintf("mmap failed for %v at offset %v: %v", path, offset, err))
+		}
+		m.raw = append(m.raw, raw)
+		m.chunks = append(m.chunks, raw[offset-aligned:])
+	}
+	return m, nil
+}
+
+func (m *mmapFile) isClosed() bool {
+	return atomic.LoadInt32(&m.closed) != 0
+}
+
+func (m *mmapFile) unmap() (err error) {
+	if !atomic.CompareAndSwapInt32(&m.closed, 0, 1) {
+		return nil
+	}
+	for _, raw := range m.raw {
+		if err2 := syscall.Munmap(raw); err == nil {
+			err = err2
+		}
+	}
+	m.raw, m.chunks = nil, nil
+	return
+}
+
+/*
+Advises the kernel on the access pattern of the mappings covering
+[off, off+length). Advice is only a hint, so errors are ignored.
+*/
+func (m *mmapFile) advise(off, length int64, advice ReadAdvice) {
+	if m.isClosed() || length == 0 {
+		return
+	}
+	first, last := off>>m.chunkPower, (off+length-1)>>m.chunkPower
+	for _, raw := range m.raw[first : last+1] {
+		madvise(raw, advice)
+	}
+}
+
+/*
+Copies len(buf) bytes at pos into buf; the caller checks the bounds.
+If the file was truncated after it was mapped, touching the missing
+pages faults; that's reported as an error instead of crashing.
+*/
+func (m *mmapFile) read(buf []byte, pos int64, resource interface{}) (err error) {
+	if m.isClosed() {
+		return AlreadyClosedError(fmt.Sprintf("Already closed: %v", resource))
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r)
+			}
+			err = errors.New(fmt.Sprintf(
+				"fault reading mapped file at %v, was it truncated? %v: %v", pos, r, resource))
+		}
+	}()
+	for len(buf) > 0 {
+		n := copy(buf, m.chunks[pos>>m.chunkPower][pos&m.chunkMask:])
+		buf = buf[n:]
+		pos += int64(n)
+	}
+	return nil
+}
+
+// store/ByteBufferIndexInput.java
+
+/* An IndexInput reading directly from a memory mapped file. */
+type MMapIndexInput struct {
+	*IndexInputImpl
+	dir  *MMapDirectory
+	file *mmapFile
+	// is this instance a clone and hence does not own the mapping
+	isClone bool
+	// start offset: non-zero in the slice case
+	off    int64
+	length int64
+	pos    int64
+}
+
+func newMMapIndexInput(desc string, dir *MMapDirectory, file *mmapFile, off, length int64) *MMapIndexInput {
+	ans := &MMapIndexInput{dir: dir, file: file, off: off, length: length}
+	ans.IndexInputImpl = NewIndexInputImpl(desc, ans)
+	return ans
+}
+
+func (in *MMapIndexInput) ReadByte() (byte, error) {
+	var buf [1]byte
+	err := in.ReadBytes(buf[:])
+	return buf[0], err
+}
+
+func (in *MMapIndexInput) ReadBytes(buf []byte) error {
+	if in.pos+int64(len(buf)) > in.length {
+		return newEOFError(in)
+	}
+	if err := in.file.read(buf, in.off+in.pos, in); err != nil {
+		return err
+	}
+	in.pos += int64(len(buf))
+	return nil
+}
+
+func (in *MMapIndexInput) ReadShort() (int16, error) {
+	var buf [2]byte
+	if err := in.ReadBytes(buf[:]); err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(buf[:])), nil
+}
+
+func (in *MMapIndexInput) ReadInt() (int32, error) {
+	var buf [4]byte
+	if err := in.ReadBytes(buf[:]); err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(buf[:])), nil
+}
+
+func (in *MMapIndexInput) ReadLong() (int64, error) {
+	var buf [8]byte
+	if err := in.ReadBytes(buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf[:])), nil
+}
+
+func (in *MMapIndexInput) ReadBytesBuffered(buf []byte, useBuffer bool) error {
+	return in.ReadBytes(buf)
+}
+
+func (in *MMapIndexInput) FilePointer() int64 {
+	return in.pos
+}
+
+func (in *MMapIndexInput) Seek(pos int64) error {
+	if pos < 0 || pos > in.length {
+		return newEOFError(in)
+	}
+	in.pos = pos
+	return nil
+}
+
+func (in *MMapIndexInput) Length() int64 {
+	return in.length
+}
+
+/* Applies advice with madvise to the part of the mapping this input covers. */
+func (in *MMapIndexInput) SetReadAdvice(advice ReadAdvice) {
+	in.file.advise(in.off, in.length, advice)
+}
+
+/* Clones share the mapping, but keep their own position. */
+func (in *MMapIndexInput) Clone() IndexInput {
+	ans := *in
+	ans.isClone = true
+	ans.IndexInputImpl = NewIndexInputImpl(in.desc, &ans)
+	return &ans
+}
+
+func (in *MMapIndexInput) Slice(desc string, offset, length int64) (IndexInput, error) {
+	return in.slice(desc, offset, length), nil
+}
+
+func (in *MMapIndexInput) slice(desc string, offset, length int64) *MMapIndexInput {
+	assert2(offset >= 0 && length >= 0 && offset+length <= in.length,
+		"slice() %v out of bounds: %v", desc, in)
+	ans := newMMapIndexInput(desc, in.dir, in.file, in.off+offset, length)
+	ans.isClone = true
+	return ans
+}
+
+/* Unmaps the file, unless this is a clone or a slice. */
+func (in *MMapIndexInput) Close() error {
+	if in.isClone {
+		return nil
+	}
+	return in.dir.release(in.file)
+}
+
+func (in *MMapIndexInput) RandomAccessSlice(offset, length int64) (RandomAccessInput, error) {
+	if offset < 0 || length < 0 || offset+length > in.length {
+		return nil, errors.New(fmt.Sprintf(
+			"slice() offset=%v length=%v out of bounds: %v", offset, length, in))
+	}
+	start := in.off + offset
+	return &randomAccessInput{
+		desc:   in.String(),
+		length: length,
+		read: func(buf []byte, pos int64) error {
+			return in.file.read(buf, start+pos, in)
+		},
+	}, nil
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package store
+
+import (
+	"syscall"
+)
+
+/* Passes advice on to the kernel for the page aligned mapping b. */
+func madvise(b []byte, advice ReadAdvice) error {
+	switch advice {
+	case READ_ADVICE_SEQUENTIAL:
+		return syscall.Madvise(b, syscall.MADV_SEQUENTIAL)
+	case READ_ADVICE_RANDOM:
+		return syscall.Madvise(b, syscall.MADV_RANDOM)
+	default:
+		return syscall.Madvise(b, syscall.MADV_NORMAL)
+	}
+}
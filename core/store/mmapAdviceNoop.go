@@ -0,0 +1,8 @@
+//go:build unix && !linux
+
+package store
+
+// The syscall package only exposes madvise on linux.
+func madvise(b []byte, advice ReadAdvice) error {
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package store
+
+// MMapDirectory needs syscall.Mmap, so it only exists on unix.
+func mmapDirectoryPath(dir Directory) (string, bool) {
+	return "", false
+}
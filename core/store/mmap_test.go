@@ -0,0 +1,304 @@
+//go:build unix
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestMMapDirectory(t *testing.T, maxChunkSize int, data []byte) *MMapDirectory {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(path) })
+	d, err := NewMMapDirectoryWithChunkSize(path, maxChunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteBytes(data) == nil, "write failed")
+	assert2(out.Close() == nil, "close failed")
+	return d
+}
+
+func TestMMapDirectoryReads(t *testing.T) {
+	data := make([]byte, 3*4096+123)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	// tiny chunks, so that most reads cross chunk boundaries
+	d := newTestMMapDirectory(t, 100, data)
+	defer d.Close()
+	if d.MaxChunkSize() != 64 {
+		t.Errorf("MaxChunkSize()=%v, expected 64", d.MaxChunkSize())
+	}
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Length() != int64(len(data)) {
+		t.Fatalf("Length()=%v, expected %v", in.Length(), len(data))
+	}
+	buf := make([]byte, len(data))
+	if err = in.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Error("read corrupted data")
+	}
+	if err = in.ReadBytes(make([]byte, 1)); err == nil {
+		t.Error("expected error reading past EOF")
+	}
+
+	// clones have their own position
+	clone := in.Clone()
+	assert2(clone.Seek(62) == nil, "seek failed")
+	assert2(in.Seek(1000) == nil, "seek failed")
+	if b, err := clone.ReadByte(); err != nil || b != data[62] {
+		t.Errorf("clone read %v (%v), expected %v", b, err, data[62])
+	}
+	if clone.FilePointer() != 63 || in.FilePointer() != 1000 {
+		t.Errorf("positions %v and %v, expected 63 and 1000", clone.FilePointer(), in.FilePointer())
+	}
+
+	// slices read their own range of the shared mapping
+	slice, err := in.Slice("slice", 4000, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf = make([]byte, 200)
+	if err = slice.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[4000:4200]) {
+		t.Error("slice read corrupted data")
+	}
+
+	// closing the input unmaps the file for all its clones and slices
+	if err = in.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert2(clone.Seek(0) == nil, "seek failed")
+	if err = clone.ReadBytes(make([]byte, 8)); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError from a clone, got %v", err)
+	}
+	if len(d.mappings) != 0 {
+		t.Errorf("%v mappings left after close", len(d.mappings))
+	}
+}
+
+func TestMMapDirectoryClose(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	d := newTestMMapDirectory(t, 4096, data)
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part, err := in.Slice("part", 4090, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 20)
+	if err = part.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[4090:4110]) {
+		t.Error("slice read corrupted data")
+	}
+
+	// closing the directory releases the mappings still open
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	assert2(part.Seek(0) == nil, "seek failed")
+	if err = part.ReadBytes(buf); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError after directory close, got %v", err)
+	}
+	if err = in.Close(); err != nil {
+		t.Errorf("closing an input after its directory: %v", err)
+	}
+}
+
+func TestMMapDirectorySlicer(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	d := newTestMMapDirectory(t, 4096, data)
+
+	slicer, err := d.CreateSlicer("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := slicer.OpenFullSlice()
+	part := slicer.OpenSlice("part", 4090, 20)
+	buf := make([]byte, 20)
+	if err = part.ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[4090:4110]) {
+		t.Error("slice read corrupted data")
+	}
+	if full.Length() != int64(len(data)) || full.FilePointer() != 0 {
+		t.Errorf("full slice length=%v position=%v", full.Length(), full.FilePointer())
+	}
+
+	// closing the directory releases the mappings still open
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = full.ReadBytes(buf); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError after directory close, got %v", err)
+	}
+	if err = slicer.Close(); err != nil {
+		t.Errorf("closing the slicer twice: %v", err)
+	}
+}
+
+func TestMMapDirectoryTruncatedFile(t *testing.T) {
+	data := make([]byte, 3*os.Getpagesize())
+	d := newTestMMapDirectory(t, DEFAULT_MAX_CHUNK_SIZE, data)
+	defer d.Close()
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if err = os.Truncate(filepath.Join(d.path, "a.bin"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// must fail instead of crashing on the missing pages
+	assert2(in.Seek(int64(2*os.Getpagesize())) == nil, "seek failed")
+	if _, err = in.ReadInt(); err == nil {
+		t.Error("expected an error reading a truncated file")
+	}
+	if _, err = in.ReadByte(); err == nil {
+		t.Error("expected an error reading a byte of a truncated file")
+	}
+}
+
+func TestMMapReadAdvice(t *testing.T) {
+	data := make([]byte, 5*os.Getpagesize()+17)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	d := newTestMMapDirectory(t, 2*os.Getpagesize(), data)
+	defer d.Close()
+
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	mm := in.(*MMapIndexInput)
+	for _, advice := range []ReadAdvice{READ_ADVICE_SEQUENTIAL, READ_ADVICE_RANDOM, READ_ADVICE_NORMAL} {
+		for _, raw := range mm.file.raw {
+			if err = madvise(raw, advice); err != nil {
+				t.Errorf("madvise(%v): %v", advice, err)
+			}
+		}
+		// advice on a slice only covers its chunks, and never changes what is read
+		slice, err := in.Slice("slice", 3*int64(os.Getpagesize())-5, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slice.SetReadAdvice(advice)
+		buf := make([]byte, 10)
+		if err = slice.ReadBytes(buf); err != nil {
+			t.Fatal(err)
+		}
+		start := 3*os.Getpagesize() - 5
+		if !bytes.Equal(buf, data[start:start+10]) {
+			t.Errorf("advice %v: read corrupted data", advice)
+		}
+	}
+}
+
+func TestMMapReadByte(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	d := newTestMMapDirectory(t, 100, data)
+	defer d.Close()
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slice, err := in.(*MMapIndexInput).Slice("slice", 60, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 200; i++ {
+		if b, err := slice.ReadByte(); err != nil || b != data[60+i] {
+			t.Fatalf("byte %v: read %v (%v), expected %v", i, b, err, data[60+i])
+		}
+	}
+	if _, err = slice.ReadByte(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected EOF error, got %v", err)
+	}
+
+	assert2(in.Close() == nil, "close failed")
+	assert2(slice.Seek(0) == nil, "seek failed")
+	if _, err = slice.ReadByte(); !errors.Is(err, ErrAlreadyClosed) {
+		t.Errorf("expected AlreadyClosedError, got %v", err)
+	}
+}
+
+func TestMMapDirectorySpins(t *testing.T) {
+	d := newTestMMapDirectory(t, 100, nil)
+	defer d.Close()
+	nio, err := NewNIOFSDirectory(d.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nio.Close()
+	if Spins(d) != Spins(nio) {
+		t.Errorf("Spins() differs between %v and %v on the same path", d, nio)
+	}
+}
+
+func BenchmarkMMapReadByte(b *testing.B) {
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	d, err := NewMMapDirectory(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer d.Close()
+	out, err := d.CreateOutput("a.bin", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	assert2(out.WriteBytes(make([]byte, 1<<16)) == nil, "write failed")
+	assert2(out.Close() == nil, "close failed")
+	in, err := d.OpenInput("a.bin", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer in.Close()
+	b.SetBytes(1 << 16)
+	for i := 0; i < b.N; i++ {
+		assert2(in.Seek(0) == nil, "seek failed")
+		for j := 0; j < 1<<16; j++ {
+			if _, err = in.ReadByte(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
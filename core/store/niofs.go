@@ -0,0 +1,134 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// store/NIOFSDirectory.java
+
+/*
+An FSDirectory implementation that reads with positional reads
+(os.File.ReadAt), so multiple goroutines can read from the same file
+without synchronizing on a shared file offset.
+
+Unlike SimpleFSDirectory, reading from clones of one IndexInput never
+blocks on each other; every clone only owns its buffer and position.
+*/
+type NIOFSDirectory struct {
+	*FSDirectory
+}
+
+func NewNIOFSDirectory(path string) (d *NIOFSDirectory, err error) {
+	d = &NIOFSDirectory{}
+	d.FSDirectory, err = newFSDirectory(d, path)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (d *NIOFSDirectory) OpenInput(name string, context IOContext) (in IndexInput, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	fpath := filepath.Join(d.path, name)
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	fstat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ans := newNIOFSIndexInput(fmt.Sprintf("NIOFSIndexInput(path='%v')", fpath),
+		f, 0, fstat.Size(), bufferSize(context))
+	ans.isClone = false
+	ans.SetReadAdvice(context.ReadAdvice())
+	return ans, nil
+}
+
+type NIOFSIndexInput struct {
+	*BufferedIndexInput
+	// the file we read from; shared by clones and slices
+	file *os.File
+	// is this instance a clone and hence does not own the file to close it
+	isClone bool
+	// start offset: non-zero in the slice case
+	off int64
+	// end offset (start+length)
+	end int64
+}
+
+func newNIOFSIndexInput(desc string, file *os.File, off, length int64, bufferSize int) *NIOFSIndexInput {
+	ans := &NIOFSIndexInput{file: file, isClone: true, off: off, end: off + length}
+	ans.BufferedIndexInput = newBufferedIndexInputBySize(ans, desc, bufferSize)
+	return ans
+}
+
+func (in *NIOFSIndexInput) Close() error {
+	if !in.isClone {
+		return in.file.Close()
+	}
+	return nil
+}
+
+func (in *NIOFSIndexInput) Clone() IndexInput {
+	ans := &NIOFSIndexInput{
+		in.BufferedIndexInput.Clone(),
+		in.file,
+		true,
+		in.off,
+		in.end,
+	}
+	ans.spi = ans
+	return ans
+}
+
+func (in *NIOFSIndexInput) Slice(desc string, offset, length int64) (IndexInput, error) {
+	assert2(offset >= 0 && length >= 0 && offset+length <= in.Length(),
+		"slice() %v out of bounds: %v", desc, in)
+	return newNIOFSIndexInput(desc, in.file, in.off+offset, length, in.bufferSize), nil
+}
+
+func (in *NIOFSIndexInput) Length() int64 {
+	return in.end - in.off
+}
+
+func (in *NIOFSIndexInput) readInternal(buf []byte) error {
+	position := in.off + in.FilePointer()
+	if position+int64(len(buf)) > in.end {
+		return newEOFError(in)
+	}
+
+	// ReadAt() reports a short read with an error, but keep reading
+	// until buf is full, in case the platform returns partial reads
+	for total := 0; total < len(buf); {
+		n, err := in.file.ReadAt(buf[total:], position+int64(total))
+		total += n
+		if total == len(buf) {
+			break
+		}
+		if err == nil && n > 0 {
+			continue
+		}
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return errors.New(fmt.Sprintf("%v: %v", err, in))
+	}
+	return nil
+}
+
+func (in *NIOFSIndexInput) seekInternal(pos int64) error { return nil }
+
+func (in *NIOFSIndexInput) RandomAccessSlice(offset, length int64) (RandomAccessInput, error) {
+	if offset < 0 || length < 0 || offset+length > in.Length() {
+		return nil, errors.New(fmt.Sprintf(
+			"slice() offset=%v length=%v out of bounds: %v", offset, length, in))
+	}
+	return newReaderAtRandomAccessInput(in.String(), in.file, in.off+offset, length), nil
+}
@@ -0,0 +1,59 @@
+package store
+
+// store/NoLockFactory.java
+
+/*
+Use this LockFactory to disable locking entirely. Only one instance
+of this lock is created. Use the NO_LOCK_FACTORY var to get the
+instance.
+
+This is only safe for directories which will never be written, e.g.
+an index opened purely for searching. Opening an IndexWriter against
+a directory using this factory allows two writers to modify the same
+index concurrently, which will corrupt it.
+*/
+type NoLockFactory struct {
+	*LockFactoryImpl
+}
+
+var NO_LOCK_FACTORY = &NoLockFactory{&LockFactoryImpl{}}
+
+var singletonNoLock = newNoLock()
+
+func (f *NoLockFactory) Make(name string) Lock {
+	return singletonNoLock
+}
+
+func (f *NoLockFactory) Clear(name string) error {
+	return nil
+}
+
+func (f *NoLockFactory) String() string {
+	return "NoLockFactory"
+}
+
+type NoLock struct {
+	*LockImpl
+}
+
+func newNoLock() *NoLock {
+	ans := &NoLock{}
+	ans.LockImpl = NewLockImpl(ans)
+	return ans
+}
+
+func (lock *NoLock) Obtain() (ok bool, err error) {
+	return true, nil
+}
+
+func (lock *NoLock) Close() error {
+	return nil
+}
+
+func (lock *NoLock) IsLocked() bool {
+	return false
+}
+
+func (lock *NoLock) String() string {
+	return "NoLock"
+}
@@ -38,7 +38,7 @@ which point all writes will not be cached (until the net bytes falls
 below 60 MB).
 */
 type NRTCachingDirectory struct {
-	Directory
+	*FilterDirectory
 	sync.Locker
 
 	cache             *RAMDirectory
@@ -56,7 +56,7 @@ and 2) the total cached bytes is <= maxCachedMB.
 */
 func NewNRTCachingDirectory(delegate Directory, maxMergeSizeMB, maxCachedMB float64) (nrt *NRTCachingDirectory) {
 	nrt = &NRTCachingDirectory{
-		Directory:         delegate,
+		FilterDirectory:   NewFilterDirectory(delegate),
 		Locker:            &sync.Mutex{},
 		cache:             NewRAMDirectory(),
 		maxMergeSizeBytes: int64(maxMergeSizeMB * 1024 * 1024),
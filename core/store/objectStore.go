@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+/*
+A flat key/value blob store, e.g. S3, GCS or any HTTP server that
+supports range requests. Objects are written whole and never
+modified in place.
+
+Get() and Delete() of a missing key must return an error satisfying
+os.IsNotExist().
+*/
+type ObjectStore interface {
+	// Returns a reader over the object's content and its length.
+	Get(key string) (io.ReaderAt, int64, error)
+	// Creates or replaces the object with the content of r.
+	Put(key string, r io.Reader) error
+	// Returns the keys of all objects starting with prefix.
+	List(prefix string) ([]string, error)
+	Delete(key string) error
+}
+
+/*
+A Directory storing each index file as an object in an ObjectStore.
+
+Outputs are buffered in memory and uploaded with a single Put() when
+closed, so a file only becomes visible once it's complete. Inputs
+read through positioned reads on the ReaderAt returned by Get(),
+except for files no larger than maxCachedFileSize, which are kept in
+memory after being written or first read; segments_N, .si and other
+small per-commit files are then served without a round-trip. Index
+files are write-once, so the cache isn't checked against the store.
+
+Locking defaults to SingleInstanceLockFactory: it only protects
+against other writers in the same process, since most object stores
+offer no atomic create-if-absent to build a lock on.
+
+Sync() is a no-op, as a closed file is already durable.
+*/
+type ObjectStoreDirectory struct {
+	*DirectoryImpl
+	*BaseDirectory
+
+	store             ObjectStore
+	maxCachedFileSize int64
+
+	cacheLock sync.Locker
+	cache     map[string][]byte
+}
+
+func NewObjectStoreDirectory(store ObjectStore, maxCachedFileSize int64) *ObjectStoreDirectory {
+	assert(store != nil)
+	ans := &ObjectStoreDirectory{
+		store:             store,
+		maxCachedFileSize: maxCachedFileSize,
+		cacheLock:         &sync.Mutex{},
+		cache:             make(map[string][]byte),
+	}
+	ans.DirectoryImpl = NewDirectoryImpl(ans)
+	ans.BaseDirectory = NewBaseDirectory(ans)
+	ans.SetLockFactory(newSingleInstanceLockFactory())
+	return ans
+}
+
+/*
+Identifies the index by its ObjectStore rather than by this instance,
+so directories opened over the same store share lock names: the
+store's String() when it implements fmt.Stringer (e.g. a bucket URL),
+otherwise the store value itself.
+*/
+func (d *ObjectStoreDirectory) LockID() string {
+	var id string
+	if s, ok := d.store.(fmt.Stringer); ok {
+		id = s.String()
+	} else {
+		id = fmt.Sprintf("%T@%p", d.store, d.store)
+	}
+	var digest int
+	for _, ch := range id {
+		digest = 31*digest + int(ch)
+	}
+	return fmt.Sprintf("lucene-%v", strconv.FormatUint(uint64(digest), 10))
+}
+
+func (d *ObjectStoreDirectory) cached(name string) ([]byte, bool) {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+	data, ok := d.cache[name]
+	return data, ok
+}
+
+func (d *ObjectStoreDirectory) setCached(name string, data []byte) {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+	if data == nil {
+		delete(d.cache, name)
+	} else {
+		d.cache[name] = data
+	}
+}
+
+func (d *ObjectStoreDirectory) ListAll() (names []string, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	return d.store.List("")
+}
+
+func (d *ObjectStoreDirectory) FileExists(name string) bool {
+	_, err := d.FileLength(name)
+	return err == nil
+}
+
+func (d *ObjectStoreDirectory) FileLength(name string) (length int64, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	if data, ok := d.cached(name); ok {
+		return int64(len(data)), nil
+	}
+	_, length, err = d.store.Get(name)
+	return length, err
+}
+
+func (d *ObjectStoreDirectory) DeleteFile(name string) (err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	d.setCached(name, nil)
+	return d.store.Delete(name)
+}
+
+func (d *ObjectStoreDirectory) CreateOutput(name string, ctx IOContext) (out IndexOutput, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	d.setCached(name, nil)
+	return &objectStoreOutput{NewRAMOutputStream(NewRAMFileBuffer(), true), d, name, false}, nil
+}
+
+func (d *ObjectStoreDirectory) Sync(names []string) error {
+	return nil
+}
+
+func (d *ObjectStoreDirectory) OpenInput(name string, ctx IOContext) (in IndexInput, err error) {
+	defer recoverAlreadyClosed(&err)
+	d.EnsureOpen()
+	var r io.ReaderAt
+	var length int64
+	if data, ok := d.cached(name); ok {
+		r, length = bytes.NewReader(data), int64(len(data))
+	} else if r, length, err = d.store.Get(name); err != nil {
+		return nil, err
+	} else if length <= d.maxCachedFileSize {
+		data := make([]byte, length)
+		if n, err := r.ReadAt(data, 0); n < len(data) {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, &os.PathError{Op: "read", Path: name, Err: err}
+		}
+		d.setCached(name, data)
+		r = bytes.NewReader(data)
+	}
+	in = newReaderAtIndexInput(name, r, 0, length, bufferSize(ctx))
+	in.SetReadAdvice(ctx.ReadAdvice())
+	return in, nil
+}
+
+// Closes the directory and drops the cache. The store is left as is.
+func (d *ObjectStoreDirectory) Close() error {
+	d.IsOpen = false
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+	d.cache = make(map[string][]byte)
+	return nil
+}
+
+func (d *ObjectStoreDirectory) String() string {
+	return fmt.Sprintf("ObjectStoreDirectory(%v)@%v", d.store, d.DirectoryImpl.String())
+}
+
+// Buffers a file in memory and uploads it on Close().
+type objectStoreOutput struct {
+	*RAMOutputStream
+	owner  *ObjectStoreDirectory
+	name   string
+	closed bool
+}
+
+func (out *objectStoreOutput) Close() error {
+	if out.closed {
+		return nil
+	}
+	out.closed = true
+	if err := out.RAMOutputStream.Close(); err != nil {
+		return err
+	}
+	data := make([]byte, out.FilePointer())
+	if err := out.WriteToBytes(data); err != nil {
+		return err
+	}
+	if err := out.owner.store.Put(out.name, bytes.NewReader(data)); err != nil {
+		return err
+	}
+	if int64(len(data)) <= out.owner.maxCachedFileSize {
+		out.owner.setCached(out.name, data)
+	}
+	return nil
+}
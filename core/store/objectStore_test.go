@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// In-memory ObjectStore counting Get() calls.
+type memObjectStore struct {
+	sync.Mutex
+	objects map[string][]byte
+	gets    int
+}
+
+func newMemObjectStore() *memObjectStore {
+	return &memObjectStore{objects: make(map[string][]byte)}
+}
+
+func (s *memObjectStore) Get(key string) (io.ReaderAt, int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.gets++
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, 0, &os.PathError{Op: "get", Path: key, Err: os.ErrNotExist}
+	}
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+func (s *memObjectStore) Put(key string, r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+func (s *memObjectStore) List(prefix string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memObjectStore) Delete(key string) error {
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.objects[key]; !ok {
+		return &os.PathError{Op: "delete", Path: key, Err: os.ErrNotExist}
+	}
+	delete(s.objects, key)
+	return nil
+}
+
+func writeObjectStoreFile(t *testing.T, dir Directory, name string, size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byten(int64(i))
+	}
+	existed := dir.FileExists(name)
+	out, err := dir.CreateOutput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.WriteBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	if !existed && dir.FileExists(name) {
+		t.Errorf("%v visible before Close()", name)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func readObjectStoreFile(t *testing.T, dir Directory, name string) []byte {
+	in, err := dir.OpenInput(name, IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	data := make([]byte, in.Length())
+	if err = in.ReadBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestObjectStoreDirectory(t *testing.T) {
+	store := newMemObjectStore()
+	dir := NewObjectStoreDirectory(store, 100)
+	small := writeObjectStoreFile(t, dir, "small", 10)
+	large := writeObjectStoreFile(t, dir, "large", 3*BUFFER_SIZE+5)
+
+	names, err := dir.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "large,small" {
+		t.Errorf("ListAll() returned %v", names)
+	}
+	if n, err := dir.FileLength("large"); err != nil || n != int64(len(large)) {
+		t.Errorf("FileLength(large)=%v, %v", n, err)
+	}
+
+	store.gets = 0
+	if b := readObjectStoreFile(t, dir, "small"); !bytes.Equal(b, small) {
+		t.Error("wrong content for small")
+	}
+	if store.gets != 0 {
+		t.Errorf("small file written through the directory was not cached")
+	}
+	if b := readObjectStoreFile(t, dir, "large"); !bytes.Equal(b, large) {
+		t.Error("wrong content for large")
+	}
+
+	// a second directory over the same store sees the same files, and
+	// caches small ones on first read
+	other := NewObjectStoreDirectory(store, 100)
+	defer other.Close()
+	store.gets = 0
+	for i := 0; i < 2; i++ {
+		if b := readObjectStoreFile(t, other, "small"); !bytes.Equal(b, small) {
+			t.Error("wrong content for small")
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("small file fetched %v times", store.gets)
+	}
+
+	// overwriting replaces the cached copy
+	small = writeObjectStoreFile(t, dir, "small", 20)
+	if b := readObjectStoreFile(t, dir, "small"); !bytes.Equal(b, small) {
+		t.Error("stale content after overwrite")
+	}
+
+	if err = dir.DeleteFile("small"); err != nil {
+		t.Fatal(err)
+	}
+	if dir.FileExists("small") {
+		t.Error("small still exists after DeleteFile()")
+	}
+	if _, err = dir.OpenInput("small", IO_CONTEXT_DEFAULT); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error opening deleted file, got %v", err)
+	}
+	if err = dir.DeleteFile("small"); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error deleting twice, got %v", err)
+	}
+	if names, _ = dir.ListAll(); len(names) != 1 || names[0] != "large" {
+		t.Errorf("ListAll() returned %v after delete", names)
+	}
+
+	if err = dir.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.objects["large"]; !ok {
+		t.Error("Close() should leave the store intact")
+	}
+}
+
+func TestObjectStoreDirectoryLockID(t *testing.T) {
+	store := newMemObjectStore()
+	d1 := NewObjectStoreDirectory(store, 0)
+	d2 := NewObjectStoreDirectory(store, 0)
+	if d1.LockID() != d2.LockID() {
+		t.Errorf("directories over the same store have lock ids %v and %v", d1.LockID(), d2.LockID())
+	}
+	if other := NewObjectStoreDirectory(newMemObjectStore(), 0); other.LockID() == d1.LockID() {
+		t.Errorf("directories over different stores share lock id %v", d1.LockID())
+	}
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bufio"
 	"hash"
 	"hash/crc32"
 	"io"
@@ -14,6 +15,7 @@ type OutputStreamIndexOutput struct {
 
 	crc hash.Hash32
 	os  io.WriteCloser
+	buf *bufio.Writer
 
 	bytesWritten int64
 }
@@ -23,6 +25,7 @@ func newOutputStreamIndexOutput(out io.WriteCloser, bufferSize int) *OutputStrea
 	ans := &OutputStreamIndexOutput{
 		crc: crc32.NewIEEE(),
 		os:  out,
+		buf: bufio.NewWriterSize(out, bufferSize),
 	}
 	ans.IndexOutputImpl = NewIndexOutput(ans)
 	return ans
@@ -30,7 +33,7 @@ func newOutputStreamIndexOutput(out io.WriteCloser, bufferSize int) *OutputStrea
 
 func (out *OutputStreamIndexOutput) WriteByte(b byte) error {
 	out.crc.Write([]byte{b})
-	if _, err := out.os.Write([]byte{b}); err != nil {
+	if err := out.buf.WriteByte(b); err != nil {
 		return err
 	}
 	out.bytesWritten++
@@ -39,7 +42,7 @@ func (out *OutputStreamIndexOutput) WriteByte(b byte) error {
 
 func (out *OutputStreamIndexOutput) WriteBytes(p []byte) error {
 	out.crc.Write(p)
-	if _, err := out.os.Write(p); err != nil {
+	if _, err := out.buf.Write(p); err != nil {
 		return err
 	}
 	out.bytesWritten += int64(len(p))
@@ -47,7 +50,11 @@ func (out *OutputStreamIndexOutput) WriteBytes(p []byte) error {
 }
 
 func (out *OutputStreamIndexOutput) Close() error {
-	return out.os.Close()
+	err := out.buf.Flush()
+	if err2 := out.os.Close(); err == nil {
+		err = err2
+	}
+	return err
 }
 
 func (out *OutputStreamIndexOutput) FilePointer() int64 {
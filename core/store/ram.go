@@ -38,31 +38,51 @@ type RAMDirectory struct {
 
 	sizeInBytes int64 // synchronized
 
-	fileMap     map[string]*RAMFile // synchronized
-	fileMapLock *sync.RWMutex
+	// map[string]*RAMFile, copy-on-write: a published map is never
+	// modified, so readers load it without locking, while writers
+	// replace it under fileMapLock.
+	fileMap     atomic.Value
+	fileMapLock sync.Locker
 }
 
 func NewRAMDirectory() *RAMDirectory {
-	ans := &RAMDirectory{
-		fileMap:     make(map[string]*RAMFile),
-		fileMapLock: &sync.RWMutex{},
-	}
+	ans := &RAMDirectory{fileMapLock: &sync.Mutex{}}
+	ans.fileMap.Store(make(map[string]*RAMFile))
 	ans.DirectoryImpl = NewDirectoryImpl(ans)
 	ans.BaseDirectory = NewBaseDirectory(ans)
 	ans.SetLockFactory(newSingleInstanceLockFactory())
 	return ans
 }
 
+// Returns the current file map, which must not be modified.
+func (rd *RAMDirectory) files() map[string]*RAMFile {
+	return rd.fileMap.Load().(map[string]*RAMFile)
+}
+
+/*
+Publishes a modified copy of the file map. Assumes fileMapLock is
+locked.
+*/
+func (rd *RAMDirectory) updateFiles(update func(files map[string]*RAMFile)) {
+	old := rd.files()
+	files := make(map[string]*RAMFile, len(old)+1)
+	for name, file := range old {
+		files[name] = file
+	}
+	update(files)
+	rd.fileMap.Store(files)
+}
+
 func (d *RAMDirectory) LockID() string {
 	return fmt.Sprintf("lucene-%v", util.ItoHex(int64(uintptr(unsafe.Pointer(&d)))))
 }
 
 func (rd *RAMDirectory) ListAll() (names []string, err error) {
+	defer recoverAlreadyClosed(&err)
 	rd.EnsureOpen()
-	rd.fileMapLock.RLock()
-	defer rd.fileMapLock.RUnlock()
-	names = make([]string, 0, len(rd.fileMap))
-	for name, _ := range rd.fileMap {
+	files := rd.files()
+	names = make([]string, 0, len(files))
+	for name, _ := range files {
 		names = append(names, name)
 	}
 	return names, nil
@@ -71,18 +91,15 @@ func (rd *RAMDirectory) ListAll() (names []string, err error) {
 // Returns true iff the named file exists in this directory
 func (rd *RAMDirectory) FileExists(name string) bool {
 	rd.EnsureOpen()
-	rd.fileMapLock.RLock()
-	defer rd.fileMapLock.RUnlock()
-	_, ok := rd.fileMap[name]
+	_, ok := rd.files()[name]
 	return ok
 }
 
 // Returns the length in bytes of a file in the directory.
 func (rd *RAMDirectory) FileLength(name string) (length int64, err error) {
+	defer recoverAlreadyClosed(&err)
 	rd.EnsureOpen()
-	rd.fileMapLock.RLock()
-	defer rd.fileMapLock.RUnlock()
-	if file, ok := rd.fileMap[name]; ok {
+	if file, ok := rd.files()[name]; ok {
 		return file.Length(), nil
 	}
 	return 0, os.ErrNotExist
@@ -98,30 +115,37 @@ func (rd *RAMDirectory) RamBytesUsed() int64 {
 }
 
 /* Removes an existing file in the directory */
-func (rd *RAMDirectory) DeleteFile(name string) error {
+func (rd *RAMDirectory) DeleteFile(name string) (err error) {
+	defer recoverAlreadyClosed(&err)
 	rd.EnsureOpen()
-	rd.fileMapLock.RLock()
-	defer rd.fileMapLock.RUnlock()
-	if file, ok := rd.fileMap[name]; ok {
+	rd.fileMapLock.Lock()
+	defer rd.fileMapLock.Unlock()
+	if file, ok := rd.files()[name]; ok {
+		rd.updateFiles(func(files map[string]*RAMFile) {
+			delete(files, name)
+		})
 		file.directory = nil
 		atomic.AddInt64(&rd.sizeInBytes, -file.sizeInBytes)
 		return nil
 	}
-	return errors.New(name)
+	return &os.PathError{Op: "delete", Path: name, Err: os.ErrNotExist}
 }
 
 // Creates a new, empty file in the directory with the given name.
 // Returns a stream writing this file:
 func (rd *RAMDirectory) CreateOutput(name string, context IOContext) (out IndexOutput, err error) {
+	defer recoverAlreadyClosed(&err)
 	rd.EnsureOpen()
 	file := rd.newRAMFile()
 	rd.fileMapLock.Lock()
 	defer rd.fileMapLock.Unlock()
-	if existing, ok := rd.fileMap[name]; ok {
+	if existing, ok := rd.files()[name]; ok {
 		atomic.AddInt64(&rd.sizeInBytes, -existing.sizeInBytes)
 		existing.directory = nil
 	}
-	rd.fileMap[name] = file
+	rd.updateFiles(func(files map[string]*RAMFile) {
+		files[name] = file
+	})
 	return NewRAMOutputStream(file, true), nil
 }
 
@@ -136,10 +160,12 @@ func (rd *RAMDirectory) Sync(names []string) error {
 	return nil
 }
 
-// Returns a stream reading an existing file.
+// Returns a stream reading an existing file. Doesn't lock, so
+// concurrent opens don't contend with each other or with writers.
 func (rd *RAMDirectory) OpenInput(name string, context IOContext) (in IndexInput, err error) {
+	defer recoverAlreadyClosed(&err)
 	rd.EnsureOpen()
-	if file, ok := rd.fileMap[name]; ok {
+	if file, ok := rd.files()[name]; ok {
 		return newRAMInputStream(name, file)
 	}
 	return nil, errors.New(name)
@@ -150,22 +176,22 @@ func (rd *RAMDirectory) Close() error {
 	rd.IsOpen = false
 	rd.fileMapLock.Lock()
 	defer rd.fileMapLock.Unlock()
-	rd.fileMap = make(map[string]*RAMFile)
+	rd.fileMap.Store(make(map[string]*RAMFile))
 	return nil
 }
 
 /* test-only */
 func (rd *RAMDirectory) GetRAMFile(name string) *RAMFile {
-	rd.fileMapLock.Lock()
-	defer rd.fileMapLock.Unlock()
-	return rd.fileMap[name]
+	return rd.files()[name]
 }
 
 /* test-only */
 func (d *RAMDirectory) PutRAMFile(name string, file *RAMFile) {
 	d.fileMapLock.Lock()
 	defer d.fileMapLock.Unlock()
-	d.fileMap[name] = file
+	d.updateFiles(func(files map[string]*RAMFile) {
+		files[name] = file
+	})
 }
 
 /* test-only */
@@ -317,6 +343,9 @@ func newSingleInstanceLock(locks map[string]bool, locksLock sync.Locker, name st
 func (lock *SingleInstanceLock) Obtain() (ok bool, err error) {
 	lock.locksLock.Lock() // synchronized
 	defer lock.locksLock.Unlock()
+	if _, ok := lock.locks[lock.name]; ok {
+		return false, nil
+	}
 	lock.locks[lock.name] = true
 	return true, nil
 }
@@ -418,7 +447,7 @@ func (in *RAMInputStream) switchCurrentBuffer(enforceEOF bool) error {
 	if in.bufferStart > in.length || in.currentBufferIndex >= in.file.numBuffers() {
 		// end of file reached, no more buffer left
 		if enforceEOF {
-			return errors.New(fmt.Sprintf("read past EOF: %v", in))
+			return newEOFError(in)
 		}
 		// Force EOF if a read takes place at this position
 		in.currentBufferIndex--
@@ -455,11 +484,14 @@ func (in *RAMInputStream) Seek(pos int64) error {
 }
 
 func (in *RAMInputStream) Slice(desc string, offset, length int64) (IndexInput, error) {
-	panic("not implemented yet")
+	return wrapIndexInput(desc, in, offset, length), nil
 }
 
+/* Clones share the RAMFile, but keep their own position. */
 func (in *RAMInputStream) Clone() IndexInput {
-	panic("not implemented yet")
+	ans := *in
+	ans.IndexInputImpl = NewIndexInputImpl(in.desc, &ans)
+	return &ans
 }
 
 func (in *RAMInputStream) String() string {
@@ -630,6 +662,26 @@ func (out *RAMOutputStream) Flush() error {
 	return nil
 }
 
+/*
+Sets the position where the next write will occur, e.g. to patch a
+length prefix written earlier. Seeking doesn't shrink the file, but
+the checksum, if any, no longer matches the file's content once
+anything was overwritten.
+*/
+func (out *RAMOutputStream) Seek(pos int64) error {
+	// set the file length in case we seek back and flush() has not
+	// been called yet
+	out.setFileLength()
+	assert2(pos >= 0 && pos <= out.file.length,
+		"seek position %v out of bounds [0, %v]", pos, out.file.length)
+	if out.currentBuffer == nil || pos < out.bufferStart || pos >= out.bufferStart+int64(out.bufferLength) {
+		out.currentBufferIndex = int(pos / BUFFER_SIZE)
+		out.switchCurrentBuffer()
+	}
+	out.bufferPosition = int(pos % BUFFER_SIZE)
+	return nil
+}
+
 func (out *RAMOutputStream) FilePointer() int64 {
 	if out.currentBufferIndex < 0 {
 		return 0
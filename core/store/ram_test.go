@@ -1,6 +1,9 @@
 package store
 
 import (
+	"bytes"
+	"github.com/balzaczyy/golucene/core/util"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +31,180 @@ func TestIO(t *testing.T) {
 	assert2(err == nil, "%v", err)
 	assertEquals(t, s, testdata)
 }
+
+func TestNoLockFactory(t *testing.T) {
+	dir := NewRAMDirectory()
+	dir.SetLockFactory(NO_LOCK_FACTORY)
+
+	lock := dir.MakeLock("write.lock")
+	ok, err := lock.Obtain()
+	assert2(ok && err == nil, "obtain failed: %v", err)
+	// a second obtain also succeeds since nothing is really locked
+	ok, err = dir.MakeLock("write.lock").Obtain()
+	assert2(ok && err == nil, "obtain failed: %v", err)
+	assertEquals(t, lock.IsLocked(), false)
+	assertEquals(t, lock.Close(), nil)
+
+	names, err := dir.ListAll()
+	assert2(err == nil, "%v", err)
+	assertEquals(t, len(names), 0)
+}
+
+func TestOpenLock(t *testing.T) {
+	dir := NewRAMDirectory()
+
+	lock, err := dir.OpenLock("write.lock")
+	assert2(err == nil, "%v", err)
+	_, err = dir.OpenLock("write.lock")
+	assertEquals(t, err != nil, true)
+
+	assertEquals(t, lock.Close(), nil)
+	// releasing twice is safe
+	assertEquals(t, lock.Close(), nil)
+
+	lock2, err := dir.OpenLock("write.lock")
+	assert2(err == nil, "%v", err)
+	assertEquals(t, lock2.Close(), nil)
+}
+
+func TestReadMixedRecords(t *testing.T) {
+	const numRecords = 300 // spans several buffers
+	dir := NewRAMDirectory()
+	out, err := dir.CreateOutput("records", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	var ends []int64
+	for i := 0; i < numRecords; i++ {
+		assert2(out.WriteVInt(int32(i*i)) == nil, "")
+		assert2(out.WriteString(strings.Repeat("x", i%20)) == nil, "")
+		assert2(out.WriteLong(int64(i)<<40) == nil, "")
+		ends = append(ends, out.FilePointer())
+	}
+	assert2(out.Close() == nil, "")
+
+	in, err := dir.OpenInput("records", IO_CONTEXT_DEFAULT)
+	assert2(err == nil, "%v", err)
+	// slices of RAM inputs are BufferedIndexInputs
+	slice, err := in.Slice("records", 0, in.Length())
+	assert2(err == nil, "%v", err)
+	_, ok := slice.(*SlicedIndexInput)
+	assertEquals(t, ok, true)
+
+	for _, in := range []IndexInput{in, slice} {
+		for i := 0; i < numRecords; i++ {
+			n, err := in.ReadVInt()
+			assert2(err == nil, "%v", err)
+			assertEquals(t, n, int32(i*i))
+			s, err := in.ReadString()
+			assert2(err == nil, "%v", err)
+			assertEquals(t, s, strings.Repeat("x", i%20))
+			l, err := in.ReadLong()
+			assert2(err == nil, "%v", err)
+			assertEquals(t, l, int64(i)<<40)
+			assertEquals(t, in.FilePointer(), ends[i])
+		}
+		_, err = in.ReadVInt()
+		assertEquals(t, err != nil, true)
+	}
+}
+
+func TestCopyBytes(t *testing.T) {
+	const offset, numBytes = 100, 3 * util.DATA_OUTPUT_COPY_BUFFER_SIZE
+	data := make([]byte, offset+numBytes+10)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	dir := NewRAMDirectory()
+	write := func(name string, f func(out IndexOutput) error) int64 {
+		out, err := dir.CreateOutput(name, IO_CONTEXT_DEFAULT)
+		if err != nil {
+			t.Fatalf("CreateOutput(%v) failed: %v", name, err)
+		}
+		if err = f(out); err != nil {
+			t.Fatalf("Writing %v failed: %v", name, err)
+		}
+		checksum := out.Checksum()
+		if err = out.Close(); err != nil {
+			t.Fatalf("Closing %v failed: %v", name, err)
+		}
+		return checksum
+	}
+	write("src", func(out IndexOutput) error { return out.WriteBytes(data) })
+
+	in, err := dir.OpenInput("src", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatalf("OpenInput(src) failed: %v", err)
+	}
+	if err = in.Seek(offset); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	copied := write("copy", func(out IndexOutput) error {
+		if err := out.CopyBytes(in, numBytes); err != nil {
+			return err
+		}
+		assertEquals(t, out.FilePointer(), int64(numBytes))
+		return nil
+	})
+	expected := write("expected", func(out IndexOutput) error {
+		return out.WriteBytes(data[offset : offset+numBytes])
+	})
+	assertEquals(t, copied, expected)
+
+	dest, err := dir.OpenInput("copy", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatalf("OpenInput(copy) failed: %v", err)
+	}
+	buf := make([]byte, numBytes)
+	if err = dest.ReadBytes(buf); err != nil {
+		t.Fatalf("ReadBytes failed: %v", err)
+	}
+	assertEquals(t, bytes.Equal(buf, data[offset:offset+numBytes]), true)
+
+	// only 10 bytes are left
+	out, err := dir.CreateOutput("short", IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatalf("CreateOutput(short) failed: %v", err)
+	}
+	defer out.Close()
+	assertEquals(t, out.CopyBytes(in, 11) != nil, true)
+}
+
+func TestRAMOutputStreamSeek(t *testing.T) {
+	payload := make([]byte, 2*BUFFER_SIZE+100)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	buffer := NewRAMOutputStreamBuffer()
+	for round := 0; round < 2; round++ {
+		// length prefix, patched once the payload is written
+		assert2(buffer.WriteInt(-1) == nil, "")
+		assert2(buffer.WriteBytes(payload) == nil, "")
+		end := buffer.FilePointer()
+		assert2(buffer.Seek(0) == nil, "")
+		assert2(buffer.WriteInt(int32(len(payload))) == nil, "")
+		assert2(buffer.Seek(end) == nil, "")
+		assert2(buffer.WriteString("end") == nil, "")
+
+		dir := NewRAMDirectory()
+		out, err := dir.CreateOutput("block", IO_CONTEXT_DEFAULT)
+		assert2(err == nil, "%v", err)
+		assert2(buffer.WriteTo(out) == nil, "")
+		assert2(out.Close() == nil, "")
+
+		in, err := dir.OpenInput("block", IO_CONTEXT_DEFAULT)
+		assert2(err == nil, "%v", err)
+		assertEquals(t, in.Length(), end+4)
+		n, err := in.ReadInt()
+		assert2(err == nil, "%v", err)
+		assertEquals(t, n, int32(len(payload)))
+		buf := make([]byte, n)
+		assert2(in.ReadBytes(buf) == nil, "")
+		assertEquals(t, bytes.Equal(buf, payload), true)
+		s, err := in.ReadString()
+		assert2(err == nil, "%v", err)
+		assertEquals(t, s, "end")
+
+		// reuse the buffer for the next block
+		buffer.Reset()
+		assertEquals(t, buffer.FilePointer(), int64(0))
+	}
+}
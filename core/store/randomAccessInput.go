@@ -0,0 +1,133 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// store/RandomAccessInput.java
+
+/*
+Random access reads of fixed-size big-endian values at absolute
+positions, without a file pointer. Safe for concurrent use; reads
+never move the position of the IndexInput it was created from.
+*/
+type RandomAccessInput interface {
+	ReadByteAt(pos int64) (byte, error)
+	ReadShortAt(pos int64) (int16, error)
+	ReadIntAt(pos int64) (int32, error)
+	ReadLongAt(pos int64) (int64, error)
+	Length() int64
+}
+
+/*
+Implemented by IndexInputs that can serve random access slices
+without seeking, e.g. via positioned reads.
+*/
+type RandomAccessSlicer interface {
+	RandomAccessSlice(offset, length int64) (RandomAccessInput, error)
+}
+
+/*
+Creates a RandomAccessInput over the given range of in. Uses in's own
+RandomAccessSlicer implementation if it has one; otherwise reads
+through a private slice, one seek+read at a time.
+*/
+func NewRandomAccessInput(in IndexInput, offset, length int64) (RandomAccessInput, error) {
+	if slicer, ok := in.(RandomAccessSlicer); ok {
+		return slicer.RandomAccessSlice(offset, length)
+	}
+	slice, err := in.Slice("randomaccess", offset, length)
+	if err != nil {
+		return nil, err
+	}
+	var lock sync.Mutex
+	return &randomAccessInput{
+		desc:   fmt.Sprintf("%v", slice),
+		length: length,
+		read: func(buf []byte, pos int64) error {
+			lock.Lock()
+			defer lock.Unlock()
+			if err := slice.Seek(pos); err != nil {
+				return err
+			}
+			return slice.ReadBytes(buf)
+		},
+	}, nil
+}
+
+func newReaderAtRandomAccessInput(desc string, r io.ReaderAt, offset, length int64) *randomAccessInput {
+	return &randomAccessInput{
+		desc:   desc,
+		length: length,
+		read: func(buf []byte, pos int64) error {
+			n, err := r.ReadAt(buf, offset+pos)
+			if n == len(buf) {
+				return nil
+			}
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		},
+	}
+}
+
+type randomAccessInput struct {
+	desc   string
+	length int64
+	// reads exactly len(buf) bytes at pos; must be safe for concurrent use
+	read func(buf []byte, pos int64) error
+}
+
+func (ra *randomAccessInput) readAt(pos int64, n int) ([]byte, error) {
+	if pos < 0 || pos+int64(n) > ra.length {
+		return nil, errors.New(fmt.Sprintf(
+			"read of %v bytes at %v is out of bounds: %v", n, pos, ra))
+	}
+	buf := make([]byte, n)
+	return buf, ra.read(buf, pos)
+}
+
+func (ra *randomAccessInput) ReadByteAt(pos int64) (byte, error) {
+	buf, err := ra.readAt(pos, 1)
+	if err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+func (ra *randomAccessInput) ReadShortAt(pos int64) (int16, error) {
+	buf, err := ra.readAt(pos, 2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(buf)), nil
+}
+
+func (ra *randomAccessInput) ReadIntAt(pos int64) (int32, error) {
+	buf, err := ra.readAt(pos, 4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(buf)), nil
+}
+
+func (ra *randomAccessInput) ReadLongAt(pos int64) (int64, error) {
+	buf, err := ra.readAt(pos, 8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+func (ra *randomAccessInput) Length() int64 {
+	return ra.length
+}
+
+func (ra *randomAccessInput) String() string {
+	return fmt.Sprintf("RandomAccessInput(%v)", ra.desc)
+}
@@ -1,7 +1,10 @@
 package store
 
 import (
+	"fmt"
 	"math"
+	"sync"
+	"time"
 )
 
 // store/RateLimiter.java
@@ -25,59 +28,83 @@ type RateLimiter interface {
 		Note: the implementation is thread-safe
 	*/
 	Pause(bytes int64) int64
+	// How many bytes caller should add up itself before invoking Pause().
+	MinPauseCheckBytes() int64
 }
 
+// How many milliseconds' worth of bytes to accumulate before pausing.
+const MIN_PAUSE_CHECK_MSEC = 5
+
 // Simple class to rate limit IO
-// Ian: volatile is not supported
 type SimpleRateLimiter struct {
-	mbPerSec           float64 // volatile
-	minPauseCheckBytes int64   // volatile
+	sync.Locker
+	mbPerSec           float64
+	minPauseCheckBytes int64
 	lastNS             int64
 }
 
 // mbPerSec is the MB/sec max IO rate
-func newSimpleRateLimiter(mbPerSec float64) *SimpleRateLimiter {
-	ans := &SimpleRateLimiter{}
+func NewSimpleRateLimiter(mbPerSec float64) *SimpleRateLimiter {
+	ans := &SimpleRateLimiter{Locker: &sync.Mutex{}}
 	ans.SetMbPerSec(mbPerSec)
+	ans.lastNS = time.Now().UnixNano()
 	return ans
 }
 
 func (srl *SimpleRateLimiter) SetMbPerSec(mbPerSec float64) {
+	srl.Lock()
+	defer srl.Unlock()
 	srl.mbPerSec = mbPerSec
-	panic("not implemented yet")
+	if bytes := MIN_PAUSE_CHECK_MSEC / 1000.0 * mbPerSec * 1024 * 1024; bytes < math.MaxInt64 {
+		srl.minPauseCheckBytes = int64(bytes)
+	} else {
+		srl.minPauseCheckBytes = math.MaxInt64
+	}
 }
 
 func (srl *SimpleRateLimiter) MbPerSec() float64 {
+	srl.Lock()
+	defer srl.Unlock()
 	return srl.mbPerSec
 }
 
+func (srl *SimpleRateLimiter) MinPauseCheckBytes() int64 {
+	srl.Lock()
+	defer srl.Unlock()
+	return srl.minPauseCheckBytes
+}
+
 /*
 Pause, if necessary, to keep the instantaneous IO rate at or below
 the target. Be sure to only call this method when bytes >
-minPauseCheckBytes(), otherwise it will pause way too long!
+MinPauseCheckBytes(), otherwise it will pause way too long! Returns
+the nanoseconds paused.
 */
 func (srl *SimpleRateLimiter) Pause(bytes int64) int64 {
-	panic("not implemented yet")
-	// if bytes == 1 {
-	// 	return 0
-	// }
-
-	// // TODO: this is purely instantaneous rate; maybe we
-	// // should also offer decayed recent history one?
-	// srl.lastNS += int64(float64(bytes) * srl.nsPerByte)
-	// targetNS := srl.lastNS
-	// startNS := time.Now().UnixNano()
-	// curNS := startNS
-	// if srl.lastNS < curNS {
-	// 	srl.lastNS = curNS
-	// }
-
-	// // While loop because sleep doesn't always sleep enough:
-	// for pauseNS := targetNS - curNS; pauseNS > 0; pauseNS = targetNS - curNS {
-	// 	time.Sleep(time.Duration(pauseNS * int64(time.Nanosecond)))
-	// 	curNS = time.Now().UnixNano()
-	// }
-	// return curNS - startNS
+	startNS := time.Now().UnixNano()
+
+	srl.Lock()
+	secondsToPause := float64(bytes) / 1024 / 1024 / srl.mbPerSec
+	// Take the lock only while computing the target, so that
+	// concurrent callers each reserve their own slot of time:
+	targetNS := srl.lastNS + int64(1000000000*secondsToPause)
+	if startNS >= targetNS {
+		// OK, current time is already beyond the target sleep time, no
+		// pausing to do.
+		srl.lastNS = startNS
+		srl.Unlock()
+		return 0
+	}
+	srl.lastNS = targetNS
+	srl.Unlock()
+
+	// While loop because sleep doesn't always sleep enough:
+	curNS := startNS
+	for pauseNS := targetNS - curNS; pauseNS > 0; pauseNS = targetNS - curNS {
+		time.Sleep(time.Duration(pauseNS))
+		curNS = time.Now().UnixNano()
+	}
+	return curNS - startNS
 }
 
 // store/RateLimitedDirectoryWrapper.java
@@ -85,24 +112,24 @@ func (srl *SimpleRateLimiter) Pause(bytes int64) int64 {
 // A Directory wrapper that allows IndexOutput rate limiting using
 // IO context specific rate limiters.
 type RateLimitedDirectoryWrapper struct {
-	Directory
-	// we need to be volatile here to make sure we see all the values
-	// that are set / modified concurrently
-	// Ian: volatile is not supported
-	contextRateLimiters []RateLimiter // volatile
+	*FilterDirectory
+	// guards the rate limiters, which are set / modified concurrently
+	sync.Locker
+	contextRateLimiters []RateLimiter
 	isOpen              bool
 }
 
 func NewRateLimitedDirectoryWrapper(wrapped Directory) *RateLimitedDirectoryWrapper {
-	panic("not implemented yet")
-	// return &RateLimitedDirectoryWrapper{
-	// 	Directory:           wrapped,
-	// 	contextRateLimiters: make([]RateLimiter, 4), // TODO magic number
-	// 	isOpen:              true,
-	// }
+	return &RateLimitedDirectoryWrapper{
+		FilterDirectory:     NewFilterDirectory(wrapped),
+		Locker:              &sync.Mutex{},
+		contextRateLimiters: make([]RateLimiter, IO_CONTEXT_TYPE_DEFAULT),
+		isOpen:              true,
+	}
 }
 
-func (w *RateLimitedDirectoryWrapper) CreateOutput(name string, ctx IOContext) (IndexOutput, error) {
+func (w *RateLimitedDirectoryWrapper) CreateOutput(name string, ctx IOContext) (out IndexOutput, err error) {
+	defer recoverAlreadyClosed(&err)
 	w.EnsureOpen()
 	output, err := w.Directory.CreateOutput(name, ctx)
 	if err == nil {
@@ -118,12 +145,14 @@ func (w *RateLimitedDirectoryWrapper) CreateOutput(name string, ctx IOContext) (
 // 	return w.Directory.Close()
 // }
 
-// func (w *RateLimitedDirectoryWrapper) String() string {
-// 	return fmt.Sprintf("RateLimitedDirectoryWrapper(%v)", w.Directory)
-// }
+func (w *RateLimitedDirectoryWrapper) String() string {
+	return fmt.Sprintf("RateLimitedDirectoryWrapper(%v)", w.Directory)
+}
 
 func (w *RateLimitedDirectoryWrapper) rateLimiter(ctx IOContextType) RateLimiter {
 	assert(int(ctx) != 0)
+	w.Lock()
+	defer w.Unlock()
 	return w.contextRateLimiters[int(ctx)-1]
 }
 
@@ -147,6 +176,8 @@ func (w *RateLimitedDirectoryWrapper) SetMaxWriteMBPerSec(mbPerSec float64, cont
 	if context == 0 {
 		panic("Context must not be nil")
 	}
+	w.Lock()
+	defer w.Unlock()
 	ord := context - 1
 	limiter := w.contextRateLimiters[ord]
 	if mbPerSec <= 0 {
@@ -159,7 +190,7 @@ func (w *RateLimitedDirectoryWrapper) SetMaxWriteMBPerSec(mbPerSec float64, cont
 		limiter.SetMbPerSec(mbPerSec)
 		// atomic.StorePointer(&(w.contextRateLimiters[ord]), limiter) // cross the mem barrier again
 	} else {
-		w.contextRateLimiters[ord] = newSimpleRateLimiter(mbPerSec)
+		w.contextRateLimiters[ord] = NewSimpleRateLimiter(mbPerSec)
 		// atomic.StorePointer(&(w.contextRateLimiters[ord]), newSimpleRateLimiter(mbPerSec))
 	}
 }
@@ -173,12 +204,33 @@ Passing an instance of rate limiter compared to settng it using
 setMaxWriteMBPersec() allows to use the same limiter instance across
 several directories globally limiting IO across them.
 */
-func (w *RateLimitedDirectoryWrapper) setRateLimiter(mergeWriteRateLimiter RateLimiter, context int) {
-	panic("not implemented yet")
+func (w *RateLimitedDirectoryWrapper) SetRateLimiter(mergeWriteRateLimiter RateLimiter, context int) {
+	if !w.isOpen {
+		panic("this Directory is closed")
+	}
+	if context == 0 {
+		panic("Context must not be nil")
+	}
+	w.Lock()
+	defer w.Unlock()
+	w.contextRateLimiters[context-1] = mergeWriteRateLimiter
 }
 
-func (w *RateLimitedDirectoryWrapper) MaxWriteMBPerSec(context int) {
-	panic("not implemented yet")
+/*
+See SetMaxWriteMBPerSec(). Returns 0 if there is no limit for the
+given context.
+*/
+func (w *RateLimitedDirectoryWrapper) MaxWriteMBPerSec(context int) float64 {
+	if !w.isOpen {
+		panic("this Directory is closed")
+	}
+	if context == 0 {
+		panic("Context must not be nil")
+	}
+	if limiter := w.rateLimiter(IOContextType(context)); limiter != nil {
+		return limiter.MbPerSec()
+	}
+	return 0
 }
 
 // store/RateLimitedIndexOutput.java
@@ -190,17 +242,20 @@ type flushBuffer interface {
 /* A rate limiting IndexOutput */
 type RateLimitedIndexOutput struct {
 	*IndexOutputImpl
-	delegate    IndexOutput
-	rateLimiter RateLimiter
+	delegate                  IndexOutput
+	rateLimiter               RateLimiter
+	bytesSinceLastPause       int64 // how many bytes we've written since we last called Pause()
+	currentMinPauseCheckBytes int64 // cached here so we don't ask the rate limiter on each write
 }
 
 func newRateLimitedIndexOutput(rateLimiter RateLimiter, delegate IndexOutput) *RateLimitedIndexOutput {
-	panic("not implemented yet")
-	// ans := &RateLimitedIndexOutput{}
-	// ans.BufferedIndexOutput = NewBufferedIndexOutput(DEFAULT_BUFFER_SIZE, ans)
-	// ans.delegate = delegate
-	// ans.rateLimiter = rateLimiter
-	// return ans
+	ans := &RateLimitedIndexOutput{
+		delegate:                  delegate,
+		rateLimiter:               rateLimiter,
+		currentMinPauseCheckBytes: rateLimiter.MinPauseCheckBytes(),
+	}
+	ans.IndexOutputImpl = NewIndexOutput(ans)
+	return ans
 }
 
 func (out *RateLimitedIndexOutput) Close() error {
@@ -208,7 +263,7 @@ func (out *RateLimitedIndexOutput) Close() error {
 }
 
 func (out *RateLimitedIndexOutput) FilePointer() int64 {
-	panic("not implementd yet")
+	return out.delegate.FilePointer()
 }
 
 func (out *RateLimitedIndexOutput) Checksum() int64 {
@@ -216,22 +271,25 @@ func (out *RateLimitedIndexOutput) Checksum() int64 {
 }
 
 func (out *RateLimitedIndexOutput) WriteByte(b byte) error {
-	panic("not implemented yet")
+	out.bytesSinceLastPause++
+	out.checkRate()
+	return out.delegate.WriteByte(b)
 }
 
 func (out *RateLimitedIndexOutput) WriteBytes(p []byte) error {
-	panic("not implemented yet")
+	out.bytesSinceLastPause += int64(len(p))
+	out.checkRate()
+	return out.delegate.WriteBytes(p)
 }
 
-// func (out *RateLimitedIndexOutput) FlushBuffer(buf []byte) error {
-// 	out.rateLimiter.Pause(int64(len(buf)))
-// 	if v, ok := out.delegate.(flushBuffer); ok {
-// 		return v.FlushBuffer(buf)
-// 	}
-// 	panic("double check if flushBuffer interface is satisfied")
-// 	return out.delegate.WriteBytes(buf)
-// }
+func (out *RateLimitedIndexOutput) checkRate() {
+	if out.bytesSinceLastPause > out.currentMinPauseCheckBytes {
+		out.rateLimiter.Pause(out.bytesSinceLastPause)
+		out.bytesSinceLastPause = 0
+		out.currentMinPauseCheckBytes = out.rateLimiter.MinPauseCheckBytes()
+	}
+}
 
-// func (out *RateLimitedIndexOutput) Length() (int64, error) {
-// 	return out.delegate.Length()
-// }
+func (out *RateLimitedIndexOutput) String() string {
+	return fmt.Sprintf("RateLimitedIndexOutput(%v)", out.delegate)
+}
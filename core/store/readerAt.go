@@ -0,0 +1,79 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+An IndexInput over an arbitrary io.ReaderAt, e.g. a remote object
+fetched with HTTP range requests. Every buffer refill is a single
+positioned read, so the input keeps no state in the backing reader,
+and clones and slices share it freely. The ReaderAt is owned by the
+caller; Close() doesn't close it.
+*/
+type ReaderAtIndexInput struct {
+	*BufferedIndexInput
+	r io.ReaderAt
+	// start offset: non-zero in the slice case
+	off int64
+	// end offset (start+length)
+	end int64
+}
+
+func NewReaderAtIndexInput(desc string, r io.ReaderAt, length int64) IndexInput {
+	assert2(length >= 0, "invalid length %v: %v", length, desc)
+	return newReaderAtIndexInput(desc, r, 0, length, BUFFER_SIZE)
+}
+
+func newReaderAtIndexInput(desc string, r io.ReaderAt, off, length int64, bufferSize int) *ReaderAtIndexInput {
+	ans := &ReaderAtIndexInput{r: r, off: off, end: off + length}
+	ans.BufferedIndexInput = newBufferedIndexInputBySize(ans, desc, bufferSize)
+	return ans
+}
+
+func (in *ReaderAtIndexInput) Close() error {
+	return nil
+}
+
+func (in *ReaderAtIndexInput) Clone() IndexInput {
+	ans := &ReaderAtIndexInput{in.BufferedIndexInput.Clone(), in.r, in.off, in.end}
+	ans.spi = ans
+	return ans
+}
+
+func (in *ReaderAtIndexInput) Slice(desc string, offset, length int64) (IndexInput, error) {
+	assert2(offset >= 0 && length >= 0 && offset+length <= in.Length(),
+		"slice() %v out of bounds: %v", desc, in)
+	return newReaderAtIndexInput(desc, in.r, in.off+offset, length, in.bufferSize), nil
+}
+
+func (in *ReaderAtIndexInput) Length() int64 {
+	return in.end - in.off
+}
+
+func (in *ReaderAtIndexInput) readInternal(buf []byte) error {
+	position := in.off + in.FilePointer()
+	if position+int64(len(buf)) > in.end {
+		return newEOFError(in)
+	}
+	n, err := in.r.ReadAt(buf, position)
+	if n == len(buf) {
+		return nil // io.ReaderAt may return io.EOF along with a full read
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return errors.New(fmt.Sprintf("%v: %v", err, in))
+}
+
+func (in *ReaderAtIndexInput) seekInternal(pos int64) error { return nil }
+
+func (in *ReaderAtIndexInput) RandomAccessSlice(offset, length int64) (RandomAccessInput, error) {
+	if offset < 0 || length < 0 || offset+length > in.Length() {
+		return nil, errors.New(fmt.Sprintf(
+			"slice() offset=%v length=%v out of bounds: %v", offset, length, in))
+	}
+	return newReaderAtRandomAccessInput(in.String(), in.r, in.off+offset, length), nil
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+/*
+A FilterDirectory that retries OpenInput, CreateOutput, DeleteFile and
+Sync when the wrapped Directory fails with a transient error, e.g. a
+blip on NFS or a FUSE mounted object store. Which errors are
+transient is decided by the given isTransient function; any other
+error is returned immediately.
+
+Each retry waits twice as long as the previous one, starting from
+backoff, for at most maxRetries retries.
+*/
+type RetryingDirectoryWrapper struct {
+	*FilterDirectory
+	isTransient func(err error) bool
+	maxRetries  int
+	backoff     time.Duration
+}
+
+func NewRetryingDirectoryWrapper(in Directory, isTransient func(err error) bool,
+	maxRetries int, backoff time.Duration) *RetryingDirectoryWrapper {
+
+	assert2(isTransient != nil, "isTransient must not be nil")
+	assert2(maxRetries >= 0, "maxRetries must be >= 0 (got %v)", maxRetries)
+	return &RetryingDirectoryWrapper{
+		FilterDirectory: NewFilterDirectory(in),
+		isTransient:     isTransient,
+		maxRetries:      maxRetries,
+		backoff:         backoff,
+	}
+}
+
+func (d *RetryingDirectoryWrapper) retry(op, name string, f func() error) (err error) {
+	delay := d.backoff
+	for retry := 0; ; retry++ {
+		if err = f(); err == nil || retry >= d.maxRetries || !d.isTransient(err) {
+			return
+		}
+		log.Printf("%v %v failed (%v), retry %v of %v in %v",
+			op, name, err, retry+1, d.maxRetries, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (d *RetryingDirectoryWrapper) OpenInput(name string, ctx IOContext) (in IndexInput, err error) {
+	err = d.retry("OpenInput", name, func() (err error) {
+		in, err = d.Directory.OpenInput(name, ctx)
+		return
+	})
+	return
+}
+
+func (d *RetryingDirectoryWrapper) CreateOutput(name string, ctx IOContext) (out IndexOutput, err error) {
+	err = d.retry("CreateOutput", name, func() (err error) {
+		out, err = d.Directory.CreateOutput(name, ctx)
+		return
+	})
+	return
+}
+
+func (d *RetryingDirectoryWrapper) DeleteFile(name string) error {
+	return d.retry("DeleteFile", name, func() error {
+		return d.Directory.DeleteFile(name)
+	})
+}
+
+func (d *RetryingDirectoryWrapper) Sync(names []string) error {
+	return d.retry("Sync", fmt.Sprintf("%v", names), func() error {
+		return d.Directory.Sync(names)
+	})
+}
+
+func (d *RetryingDirectoryWrapper) String() string {
+	return fmt.Sprintf("RetryingDirectoryWrapper(%v)", d.Directory)
+}
@@ -41,11 +41,14 @@ func (lock *SimpleFSLock) Obtain() (ok bool, err error) {
 	} else { // IO error
 		return
 	}
+	// like createNewFile() in Java, fail if the lock file already exists
 	var f *os.File
-	if f, err = os.Create(lock.file); err == nil {
+	if f, err = os.OpenFile(lock.file, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); err == nil {
 		fmt.Printf("File '%v' is created.\n", f.Name())
 		ok = true
 		defer f.Close()
+	} else if os.IsExist(err) {
+		err = nil // held by someone else
 	}
 	return
 
@@ -121,7 +124,8 @@ func NewSimpleFSDirectory(path string) (d *SimpleFSDirectory, err error) {
 	return
 }
 
-func (d *SimpleFSDirectory) OpenInput(name string, context IOContext) (IndexInput, error) {
+func (d *SimpleFSDirectory) OpenInput(name string, context IOContext) (in IndexInput, err error) {
+	defer recoverAlreadyClosed(&err)
 	d.EnsureOpen()
 	fpath := filepath.Join(d.path, name)
 	// fmt.Printf("Opening %v...\n", fpath)
@@ -259,7 +263,7 @@ func (in *SimpleFSIndexInput) readInternal(buf []byte) error {
 	}
 
 	if position+int64(length) > in.end {
-		return errors.New(fmt.Sprintf("read past EOF: %v", in))
+		return newEOFError(in)
 	}
 
 	total := 0
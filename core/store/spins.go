@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// util/IOUtils.java
+
+/*
+If the directory is known not to be backed by a spinning disk (e.g.
+RAMDirectory, or a file system on an SSD), returns false. Otherwise
+returns true, which is the conservative answer for unknown storage.
+
+Lives here rather than in util, since util cannot depend on store.
+*/
+func Spins(dir Directory) bool {
+	dir = UnwrapDirectory(dir)
+	switch d := dir.(type) {
+	case *RAMDirectory:
+		return false
+	case *SimpleFSDirectory:
+		return spinsPath(d.path)
+	case *NIOFSDirectory:
+		return spinsPath(d.path)
+	}
+	if path, ok := mmapDirectoryPath(dir); ok {
+		return spinsPath(path)
+	}
+	return true
+}
+
+/*
+Looks up the block device the path is mounted on and checks whether
+Linux reports it as rotational. Any failure along the way (including
+non-Linux platforms) is treated as a spinning disk.
+*/
+func spinsPath(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	device, ok := mountDevice(path)
+	if !ok {
+		return true
+	}
+	// /sys/dev/block/<major:minor> links to the device or, for a
+	// partition, to a subdirectory of its device
+	sysPath, err := filepath.EvalSymlinks(filepath.Join("/sys/dev/block", device))
+	if err != nil {
+		return true
+	}
+	for _, dir := range []string{sysPath, filepath.Dir(sysPath)} {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, "queue", "rotational")); err == nil {
+			return strings.TrimSpace(string(b)) != "0"
+		}
+	}
+	return true
+}
+
+// Returns the major:minor device id of the longest mount point
+// containing path, as listed by /proc/self/mountinfo.
+func mountDevice(path string) (device string, ok bool) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	var longest string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoint := fields[4]
+		if !hasPathPrefix(path, mountPoint) || len(mountPoint) < len(longest) {
+			continue
+		}
+		longest, device, ok = mountPoint, fields[2], true
+	}
+	return
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
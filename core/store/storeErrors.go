@@ -0,0 +1,80 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Sentinels matched by the typed errors below via errors.Is.
+var (
+	ErrAlreadyClosed    = errors.New("already closed")
+	ErrLockObtainFailed = errors.New("lock obtain failed")
+)
+
+// store/AlreadyClosedException.java
+
+/*
+Raised when an operation is attempted on a closed Directory, input or
+output. EnsureOpen panics with a value of this type.
+*/
+type AlreadyClosedError string
+
+func (err AlreadyClosedError) Error() string {
+	return string(err)
+}
+
+func (err AlreadyClosedError) Is(target error) bool {
+	return target == ErrAlreadyClosed
+}
+
+// store/LockObtainFailedException.java
+
+/*
+Returned when a Lock could not be obtained within the allotted time.
+FailureReason, when set, is the root cause reported by the Lock.
+*/
+type LockObtainFailedError struct {
+	msg           string
+	FailureReason error
+}
+
+func NewLockObtainFailedError(msg string, reason error) *LockObtainFailedError {
+	return &LockObtainFailedError{msg, reason}
+}
+
+func (err *LockObtainFailedError) Error() string {
+	if err.FailureReason != nil {
+		return fmt.Sprintf("%v: %v", err.msg, err.FailureReason)
+	}
+	return err.msg
+}
+
+func (err *LockObtainFailedError) Is(target error) bool {
+	return target == ErrLockObtainFailed
+}
+
+func (err *LockObtainFailedError) Unwrap() error {
+	return err.FailureReason
+}
+
+/*
+Returned when an IndexInput is read beyond its length. It matches
+io.ErrUnexpectedEOF, same as the DataInput implementations in this
+package.
+*/
+type EOFError struct {
+	resource string
+}
+
+func newEOFError(in interface{}) *EOFError {
+	return &EOFError{fmt.Sprintf("%v", in)}
+}
+
+func (err *EOFError) Error() string {
+	return fmt.Sprintf("read past EOF: %v", err.resource)
+}
+
+func (err *EOFError) Is(target error) bool {
+	return target == io.ErrUnexpectedEOF
+}
@@ -16,7 +16,7 @@ func ChecksumEntireFile(input IndexInput) (hash int64, err error) {
 	if err = clone.Seek(0); err != nil {
 		return 0, err
 	}
-	in := newBufferedChecksumIndexInput(clone)
+	in := NewBufferedChecksumIndexInput(clone)
 	assert(in.FilePointer() == 0)
 	if err = in.Seek(in.Length() - codec.FOOTER_LENGTH); err != nil {
 		return 0, err
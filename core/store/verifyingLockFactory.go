@@ -0,0 +1,132 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// store/VerifyingLockFactory.java
+
+/*
+A LockFactory that wraps another LockFactory and verifies that each
+lock obtain/release is "correct" (never results in two processes
+holding the lock at the same time). It does this by contacting an
+external server (LockVerifyServer) to assert that at most one process
+holds the lock at a time. To use this, you should also run
+LockVerifyServer on the host and port matching what you pass to the
+constructor.
+
+Each message is one byte (1 for obtain, 0 for release) followed by
+the 4-byte id of the client. The server echoes the byte back once it
+has verified the request.
+*/
+type VerifyingLockFactory struct {
+	lf   LockFactory
+	id   int32
+	conn io.ReadWriter
+	lock sync.Locker // serializes round trips on conn
+}
+
+/*
+Creates a VerifyingLockFactory which wraps lf, identifying itself
+with id, and verifies each obtain/release through conn (typically a
+net.Conn to a LockVerifyServer).
+*/
+func NewVerifyingLockFactory(lf LockFactory, id int32, conn io.ReadWriter) *VerifyingLockFactory {
+	return &VerifyingLockFactory{
+		lf:   lf,
+		id:   id,
+		conn: conn,
+		lock: &sync.Mutex{},
+	}
+}
+
+func (f *VerifyingLockFactory) verify(message byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var buf [5]byte
+	buf[0] = message
+	binary.BigEndian.PutUint32(buf[1:], uint32(f.id))
+	if _, err := f.conn.Write(buf[:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(f.conn, buf[:1]); err != nil {
+		return errors.New(fmt.Sprintf("Lock server died because of locking error: %v", err))
+	}
+	if buf[0] != message {
+		return errors.New("Protocol violation.")
+	}
+	return nil
+}
+
+func (f *VerifyingLockFactory) Make(name string) Lock {
+	return newCheckedLock(f, f.lf.Make(name))
+}
+
+func (f *VerifyingLockFactory) Clear(name string) error {
+	return f.lf.Clear(name)
+}
+
+func (f *VerifyingLockFactory) SetLockPrefix(prefix string) {
+	f.lf.SetLockPrefix(prefix)
+}
+
+func (f *VerifyingLockFactory) LockPrefix() string {
+	return f.lf.LockPrefix()
+}
+
+func (f *VerifyingLockFactory) String() string {
+	return fmt.Sprintf("VerifyingLockFactory(%v)", f.lf)
+}
+
+type CheckedLock struct {
+	*LockImpl
+	sync.Locker
+	owner *VerifyingLockFactory
+	lock  Lock
+}
+
+func newCheckedLock(owner *VerifyingLockFactory, lock Lock) *CheckedLock {
+	ans := &CheckedLock{
+		Locker: &sync.Mutex{},
+		owner:  owner,
+		lock:   lock,
+	}
+	ans.LockImpl = NewLockImpl(ans)
+	return ans
+}
+
+func (lock *CheckedLock) Obtain() (ok bool, err error) {
+	lock.Lock() // synchronized
+	defer lock.Unlock()
+	if ok, err = lock.lock.Obtain(); ok && err == nil {
+		err = lock.owner.verify(1)
+	}
+	return
+}
+
+func (lock *CheckedLock) Close() error {
+	lock.Lock() // synchronized
+	defer lock.Unlock()
+	if lock.lock.IsLocked() {
+		if err := lock.owner.verify(0); err != nil {
+			return err
+		}
+		return lock.lock.Close()
+	}
+	return nil
+}
+
+func (lock *CheckedLock) IsLocked() bool {
+	lock.Lock() // synchronized
+	defer lock.Unlock()
+	return lock.lock.IsLocked()
+}
+
+func (lock *CheckedLock) String() string {
+	return fmt.Sprintf("CheckedLock(%v)", lock.lock)
+}
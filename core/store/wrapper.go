@@ -11,14 +11,14 @@ import (
 A delegating Directory that records which files were written to and deleted.
 */
 type TrackingDirectoryWrapper struct {
-	Directory
+	*FilterDirectory
 	sync.Locker
 	createdFilenames map[string]bool // synchronized
 }
 
 func NewTrackingDirectoryWrapper(other Directory) *TrackingDirectoryWrapper {
 	return &TrackingDirectoryWrapper{
-		Directory:        other,
+		FilterDirectory:  NewFilterDirectory(other),
 		Locker:           &sync.Mutex{},
 		createdFilenames: make(map[string]bool),
 	}
@@ -69,3 +69,22 @@ func (w *TrackingDirectoryWrapper) ContainsFile(name string) bool {
 	_, ok := w.createdFilenames[name]
 	return ok
 }
+
+// Returns a snapshot of the names of the files created and not
+// deleted since the last ClearCreated().
+func (w *TrackingDirectoryWrapper) CreatedFiles() map[string]bool {
+	w.Lock()
+	defer w.Unlock()
+	ans := make(map[string]bool)
+	for name, _ := range w.createdFilenames {
+		ans[name] = true
+	}
+	return ans
+}
+
+// Forgets all files recorded so far; the files themselves are kept.
+func (w *TrackingDirectoryWrapper) ClearCreated() {
+	w.Lock()
+	defer w.Unlock()
+	w.createdFilenames = make(map[string]bool)
+}
@@ -0,0 +1,165 @@
+package bkd
+
+import (
+	"github.com/balzaczyy/golucene/core/store"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Writes values (indexed by docID) into a tree and opens a reader on it.
+func newTestBKDReader(t *testing.T, maxPointsInLeafNode int, values []int64) *BKDReader {
+	return newTestBKDReaderWithHeap(t, maxPointsInLeafNode, DEFAULT_MAX_POINTS_SORT_IN_HEAP, values)
+}
+
+func newTestBKDReaderWithHeap(t *testing.T, maxPointsInLeafNode, maxPointsSortInHeap int,
+	values []int64) *BKDReader {
+
+	path, err := ioutil.TempDir("", "golucene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(path) })
+	dir, err := store.OpenFSDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dir.CreateOutput("bkd", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewBKDWriterWithOptions(dir, "tmp", maxPointsInLeafNode, maxPointsSortInHeap)
+	for docID, v := range values {
+		if err = w.Add(v, docID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexFP, err := w.Finish(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// temporary files are gone
+	files, err := dir.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, []string{"bkd"}) {
+		t.Errorf("Expected only the tree file, got %v", files)
+	}
+	in, err := dir.OpenInput("bkd", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { in.Close() })
+	r, err := NewBKDReader(in, indexFP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(values)) {
+		t.Errorf("Expected size %v, got %v", len(values), r.Size())
+	}
+	return r
+}
+
+func verifyIntersect(t *testing.T, r *BKDReader, values []int64, min, max int64) {
+	var expected, actual []int
+	for docID, v := range values {
+		if v >= min && v <= max {
+			expected = append(expected, docID)
+		}
+	}
+	if err := r.Intersect(min, max, func(docID int) {
+		actual = append(actual, docID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("[%v, %v]: expected %v, got %v", min, max, expected, actual)
+	}
+}
+
+func TestBKDBasics(t *testing.T) {
+	values := []int64{5, -3, 10, 7, 0, 10, math.MaxInt64, math.MinInt64, 7, 7}
+	r := newTestBKDReader(t, 2, values)
+	if r.MinValue() != math.MinInt64 || r.MaxValue() != math.MaxInt64 {
+		t.Errorf("Expected full range, got [%v, %v]", r.MinValue(), r.MaxValue())
+	}
+	for _, v := range [][2]int64{
+		{5, 10}, {7, 7}, {-3, 0}, {6, 6}, {1, 4}, {11, 100}, {10, 5},
+		{math.MinInt64, math.MaxInt64}, {math.MinInt64, math.MinInt64},
+		{math.MaxInt64, math.MaxInt64},
+	} {
+		verifyIntersect(t, r, values, v[0], v[1])
+	}
+}
+
+func TestBKDEmpty(t *testing.T) {
+	r := newTestBKDReader(t, 4, nil)
+	verifyIntersect(t, r, nil, math.MinInt64, math.MaxInt64)
+}
+
+func TestBKDRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for _, numValues := range []int{1, 3, 17, 1000} {
+		values := make([]int64, numValues)
+		for i := range values {
+			values[i] = rnd.Int63n(200) - 100
+		}
+		r := newTestBKDReader(t, 1+rnd.Intn(16), values)
+		for i := 0; i < 50; i++ {
+			a, b := rnd.Int63n(240)-120, rnd.Int63n(240)-120
+			if a > b {
+				a, b = b, a
+			}
+			verifyIntersect(t, r, values, a, b)
+		}
+	}
+}
+
+func TestBKDOfflineSort(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	values := make([]int64, 5000)
+	for i := range values {
+		values[i] = rnd.Int63n(1000) - 500
+	}
+	values[17], values[42] = math.MinInt64, math.MaxInt64
+	r := newTestBKDReaderWithHeap(t, 10, 100, values)
+	if r.MinValue() != math.MinInt64 || r.MaxValue() != math.MaxInt64 {
+		t.Errorf("Expected full range, got [%v, %v]", r.MinValue(), r.MaxValue())
+	}
+	for i := 0; i < 50; i++ {
+		a, b := rnd.Int63n(1200)-600, rnd.Int63n(1200)-600
+		if a > b {
+			a, b = b, a
+		}
+		verifyIntersect(t, r, values, a, b)
+	}
+	verifyIntersect(t, r, values, math.MinInt64, math.MaxInt64)
+
+	// VisitAll returns every point in value order
+	count, prev := 0, int64(math.MinInt64)
+	if err := r.VisitAll(func(docID int, value int64) error {
+		if value < prev {
+			t.Fatalf("value %v after %v", value, prev)
+		}
+		if values[docID] != value {
+			t.Fatalf("doc %v: value %v, want %v", docID, value, values[docID])
+		}
+		prev = value
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(values) {
+		t.Errorf("VisitAll visited %v points; expected %v", count, len(values))
+	}
+}
@@ -0,0 +1,164 @@
+package bkd
+
+import (
+	"github.com/balzaczyy/golucene/core/codec"
+	"github.com/balzaczyy/golucene/core/store"
+)
+
+// util/bkd/BKDReader.java
+
+/*
+Handles intersection of a range with a single dimension block KD-tree
+previously written with BKDWriter.
+*/
+type BKDReader struct {
+	in                  store.IndexInput
+	maxPointsInLeafNode int
+	pointCount          int64
+	minValue, maxValue  int64
+	numLeaves           int
+	splitValues         []int64
+	leafBlockFPs        []int64
+}
+
+/*
+Loads the tree index at indexFP into memory; leaf blocks are read on
+demand from clones of in, which remains owned by the caller.
+*/
+func NewBKDReader(in store.IndexInput, indexFP int64) (r *BKDReader, err error) {
+	if err = in.Seek(indexFP); err != nil {
+		return nil, err
+	}
+	if _, err = codec.CheckHeader(in, BKD_CODEC_NAME, BKD_VERSION_START, BKD_VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	r = &BKDReader{in: in}
+	var n int32
+	if n, err = in.ReadVInt(); err != nil {
+		return nil, err
+	}
+	r.maxPointsInLeafNode = int(n)
+	if r.pointCount, err = in.ReadVLong(); err != nil {
+		return nil, err
+	}
+	if r.minValue, err = in.ReadLong(); err != nil {
+		return nil, err
+	}
+	if r.maxValue, err = in.ReadLong(); err != nil {
+		return nil, err
+	}
+	if n, err = in.ReadVInt(); err != nil {
+		return nil, err
+	}
+	r.numLeaves = int(n)
+	r.splitValues = make([]int64, r.numLeaves)
+	for i := 1; i < r.numLeaves; i++ {
+		if r.splitValues[i], err = in.ReadLong(); err != nil {
+			return nil, err
+		}
+	}
+	r.leafBlockFPs = make([]int64, r.numLeaves)
+	for i := range r.leafBlockFPs {
+		if r.leafBlockFPs[i], err = in.ReadVLong(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+/* Total number of points in the tree */
+func (r *BKDReader) Size() int64 { return r.pointCount }
+
+/* Minimum value of all points, or 0 if there are none */
+func (r *BKDReader) MinValue() int64 { return r.minValue }
+
+/* Maximum value of all points, or 0 if there are none */
+func (r *BKDReader) MaxValue() int64 { return r.maxValue }
+
+/*
+Calls visit with the docID of every point whose value is within
+[min, max], both inclusive. A document is visited once per matching
+value.
+*/
+func (r *BKDReader) Intersect(min, max int64, visit func(docID int)) error {
+	if r.pointCount == 0 || min > max {
+		return nil
+	}
+	return r.intersect(r.in.Clone(), 1, r.minValue, r.maxValue, min, max, visit)
+}
+
+func (r *BKDReader) intersect(in store.IndexInput, nodeID int,
+	cellMin, cellMax, min, max int64, visit func(docID int)) error {
+
+	if cellMax < min || cellMin > max {
+		// cell is outside the query range
+		return nil
+	}
+	inside := cellMin >= min && cellMax <= max
+	if nodeID >= r.numLeaves {
+		return r.visitLeaf(in, nodeID-r.numLeaves, inside, min, max, visit)
+	}
+	split := r.splitValues[nodeID]
+	if err := r.intersect(in, 2*nodeID, cellMin, split, min, max, visit); err != nil {
+		return err
+	}
+	return r.intersect(in, 2*nodeID+1, split, cellMax, min, max, visit)
+}
+
+/* Visits the matching docs of a leaf block; all of them if inside. */
+func (r *BKDReader) visitLeaf(in store.IndexInput, leaf int, inside bool,
+	min, max int64, visit func(docID int)) error {
+
+	if err := in.Seek(r.leafBlockFPs[leaf]); err != nil {
+		return err
+	}
+	count, err := in.ReadVInt()
+	if err != nil {
+		return err
+	}
+	for i := int32(0); i < count; i++ {
+		docID, err := in.ReadVInt()
+		if err != nil {
+			return err
+		}
+		value, err := in.ReadLong()
+		if err != nil {
+			return err
+		}
+		if inside || value >= min && value <= max {
+			visit(int(docID))
+		}
+	}
+	return nil
+}
+
+/*
+Calls visit with every point, in (value, docID) order, e.g. to merge
+trees.
+*/
+func (r *BKDReader) VisitAll(visit func(docID int, value int64) error) error {
+	in := r.in.Clone()
+	for _, fp := range r.leafBlockFPs {
+		if err := in.Seek(fp); err != nil {
+			return err
+		}
+		count, err := in.ReadVInt()
+		if err != nil {
+			return err
+		}
+		for i := int32(0); i < count; i++ {
+			docID, err := in.ReadVInt()
+			if err != nil {
+				return err
+			}
+			value, err := in.ReadLong()
+			if err != nil {
+				return err
+			}
+			if err = visit(int(docID), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
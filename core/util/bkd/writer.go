@@ -0,0 +1,409 @@
+package bkd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/offline"
+	"sort"
+	"sync/atomic"
+)
+
+// util/bkd/BKDWriter.java
+
+const (
+	BKD_CODEC_NAME      = "BKD"
+	BKD_VERSION_START   = 0
+	BKD_VERSION_CURRENT = BKD_VERSION_START
+
+	DEFAULT_MAX_POINTS_IN_LEAF_NODE = 1024
+
+	// Points buffered before sorting offline: 16 MB of heap.
+	DEFAULT_MAX_POINTS_SORT_IN_HEAP = 1 << 20
+)
+
+type point struct {
+	value int64
+	docID int
+}
+
+type points []point
+
+func (p points) Len() int      { return len(p) }
+func (p points) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p points) Less(i, j int) bool {
+	if p[i].value != p[j].value {
+		return p[i].value < p[j].value
+	}
+	return p[i].docID < p[j].docID
+}
+
+/*
+Offline points are records of the value, with its sign bit flipped,
+followed by the docID, both big-endian, so that their byte order is
+the (value, docID) order.
+*/
+const bytesPerOfflinePoint = 12
+
+func encodePoint(buf []byte, p point) {
+	binary.BigEndian.PutUint64(buf, uint64(p.value)^(1<<63))
+	binary.BigEndian.PutUint32(buf[8:], uint32(p.docID))
+}
+
+func decodePoint(buf []byte) point {
+	return point{
+		int64(binary.BigEndian.Uint64(buf) ^ (1 << 63)),
+		int(binary.BigEndian.Uint32(buf[8:])),
+	}
+}
+
+/*
+Recursively builds a single dimension block KD-tree to assign all
+incoming points to smallish leaf blocks, ideal for efficient range
+searching.
+
+The points are sorted by value and split evenly into a power-of-two
+number of leaf blocks, each holding at most maxPointsInLeafNode
+points. Each inner node of the balanced tree records the value it
+splits on, so a reader can skip every leaf block whose cell doesn't
+overlap the query range, and visit every document of a cell fully
+inside the range without checking values.
+
+Up to maxPointsSortInHeap points are buffered and sorted in memory;
+beyond that, all points are spilled to a temporary file in tempDir
+and sorted with the OfflineSorter.
+*/
+type BKDWriter struct {
+	tempDir             store.Directory
+	tempFileNamePrefix  string
+	maxPointsInLeafNode int
+	maxPointsSortInHeap int
+
+	heapPoints points
+	pointCount int64
+
+	// non-nil once the points were spilled to disk:
+	offlinePoints     *offline.ByteSequencesWriter
+	offlinePointsName string
+	scratch           [bytesPerOfflinePoint]byte
+	tempCounter       int64
+}
+
+/*
+Creates a writer with the default leaf size and heap budget; temporary
+files are created in tempDir, named after tempFileNamePrefix.
+*/
+func NewBKDWriter(tempDir store.Directory, tempFileNamePrefix string) *BKDWriter {
+	return NewBKDWriterWithOptions(tempDir, tempFileNamePrefix,
+		DEFAULT_MAX_POINTS_IN_LEAF_NODE, DEFAULT_MAX_POINTS_SORT_IN_HEAP)
+}
+
+func NewBKDWriterWithOptions(tempDir store.Directory, tempFileNamePrefix string,
+	maxPointsInLeafNode, maxPointsSortInHeap int) *BKDWriter {
+
+	assert2(maxPointsInLeafNode > 0,
+		"maxPointsInLeafNode must be > 0; got %v", maxPointsInLeafNode)
+	assert2(maxPointsSortInHeap >= maxPointsInLeafNode,
+		"maxPointsSortInHeap must be >= maxPointsInLeafNode; got %v vs %v",
+		maxPointsSortInHeap, maxPointsInLeafNode)
+	return &BKDWriter{
+		tempDir:             tempDir,
+		tempFileNamePrefix:  tempFileNamePrefix,
+		maxPointsInLeafNode: maxPointsInLeafNode,
+		maxPointsSortInHeap: maxPointsSortInHeap,
+	}
+}
+
+/* Adds a value of the given document; a document may have many values. */
+func (w *BKDWriter) Add(value int64, docID int) error {
+	p := point{value, docID}
+	if w.offlinePoints == nil && len(w.heapPoints) < w.maxPointsSortInHeap {
+		w.heapPoints = append(w.heapPoints, p)
+	} else {
+		if w.offlinePoints == nil {
+			if err := w.spillToOffline(); err != nil {
+				return err
+			}
+		}
+		if err := w.writeOffline(p); err != nil {
+			return err
+		}
+	}
+	w.pointCount++
+	return nil
+}
+
+/* How many points have been added so far */
+func (w *BKDWriter) PointCount() int64 {
+	return w.pointCount
+}
+
+/* Moves the buffered points to a temporary file, once they exceed the heap budget. */
+func (w *BKDWriter) spillToOffline() error {
+	name, out, err := w.createTempOutput()
+	if err != nil {
+		return err
+	}
+	w.offlinePoints, w.offlinePointsName = offline.NewByteSequencesWriter(out), name
+	for _, p := range w.heapPoints {
+		if err = w.writeOffline(p); err != nil {
+			return err
+		}
+	}
+	w.heapPoints = nil
+	return nil
+}
+
+func (w *BKDWriter) writeOffline(p point) error {
+	encodePoint(w.scratch[:], p)
+	return w.offlinePoints.Write(w.scratch[:])
+}
+
+func (w *BKDWriter) createTempOutput() (string, store.IndexOutput, error) {
+	for {
+		name := fmt.Sprintf("%v_bkd_%v.tmp", w.tempFileNamePrefix, atomic.AddInt64(&w.tempCounter, 1))
+		if !w.tempDir.FileExists(name) {
+			out, err := w.tempDir.CreateOutput(name, store.IO_CONTEXT_DEFAULT)
+			return name, out, err
+		}
+	}
+}
+
+/*
+Writes the leaf blocks followed by the tree index to out, and returns
+the file pointer where the index starts, to be passed to
+NewBKDReader(). Temporary files are removed, also on error.
+*/
+func (w *BKDWriter) Finish(out store.IndexOutput) (indexFP int64, err error) {
+	defer func() {
+		err = util.CloseWhileHandlingError(err, w)
+	}()
+
+	var reader *pointReader
+	if w.offlinePoints == nil {
+		sort.Sort(w.heapPoints)
+		reader = newHeapPointReader(w.heapPoints)
+	} else {
+		if reader, err = w.sortOffline(); err != nil {
+			return 0, err
+		}
+		defer func() {
+			err = util.CloseWhileHandlingError(err, reader)
+		}()
+	}
+
+	numLeaves := 1
+	for int64(numLeaves*w.maxPointsInLeafNode) < w.pointCount {
+		numLeaves *= 2
+	}
+	var minValue, maxValue int64
+	if w.pointCount > 0 {
+		p, err := reader.peek()
+		if err != nil {
+			return 0, err
+		}
+		minValue = p.value
+	}
+	splitValues := make([]int64, numLeaves)
+	leafBlockFPs := make([]int64, numLeaves)
+	if err = w.build(out, 1, numLeaves, w.pointCount, reader, splitValues, leafBlockFPs); err != nil {
+		return 0, err
+	}
+	if w.pointCount > 0 {
+		maxValue = reader.last.value
+	}
+
+	indexFP = out.FilePointer()
+	if err = codec.WriteHeader(out, BKD_CODEC_NAME, BKD_VERSION_CURRENT); err != nil {
+		return 0, err
+	}
+	if err = out.WriteVInt(int32(w.maxPointsInLeafNode)); err != nil {
+		return 0, err
+	}
+	if err = out.WriteVLong(w.pointCount); err != nil {
+		return 0, err
+	}
+	if err = out.WriteLong(minValue); err != nil {
+		return 0, err
+	}
+	if err = out.WriteLong(maxValue); err != nil {
+		return 0, err
+	}
+	if err = out.WriteVInt(int32(numLeaves)); err != nil {
+		return 0, err
+	}
+	for _, v := range splitValues[1:] { // node 0 is unused
+		if err = out.WriteLong(v); err != nil {
+			return 0, err
+		}
+	}
+	for _, fp := range leafBlockFPs {
+		if err = out.WriteVLong(fp); err != nil {
+			return 0, err
+		}
+	}
+	return indexFP, nil
+}
+
+/* Sorts the spilled points, and opens a reader over the sorted file. */
+func (w *BKDWriter) sortOffline() (reader *pointReader, err error) {
+	unsorted := w.offlinePointsName
+	if err = w.offlinePoints.Close(); err != nil {
+		return nil, err
+	}
+	w.offlinePoints = nil
+
+	sorter := offline.NewOfflineSorter(w.tempDir, w.tempFileNamePrefix+"_bkd_sort",
+		util.UTF8SortedAsUnicodeComparator)
+	sorted, err := sorter.Sort(unsorted)
+	if err != nil {
+		return nil, err
+	}
+	util.DeleteFilesIgnoringErrors(w.tempDir, unsorted)
+	w.offlinePointsName = sorted
+
+	in, err := w.tempDir.OpenInput(sorted, store.IO_CONTEXT_READONCE)
+	if err != nil {
+		return nil, err
+	}
+	return newOfflinePointReader(in), nil
+}
+
+/*
+Removes the temporary files, if any. Finish() calls this; it's only
+needed when the writer is dropped before it.
+*/
+func (w *BKDWriter) Close() error {
+	if w.offlinePoints != nil {
+		util.CloseWhileSuppressingError(w.offlinePoints)
+		w.offlinePoints = nil
+	}
+	if w.offlinePointsName != "" {
+		util.DeleteFilesIgnoringErrors(w.tempDir, w.offlinePointsName)
+		w.offlinePointsName = ""
+	}
+	w.heapPoints = nil
+	return nil
+}
+
+/*
+Writes the next count sorted points as the subtree rooted at nodeID.
+Nodes are numbered as in a heap: the root is 1, children of n are 2n
+and 2n+1, and nodes from numLeaves on are leaves.
+*/
+func (w *BKDWriter) build(out store.IndexOutput, nodeID, numLeaves int, count int64,
+	reader *pointReader, splitValues, leafBlockFPs []int64) error {
+
+	if nodeID >= numLeaves {
+		// leaf node
+		assert2(count <= int64(w.maxPointsInLeafNode),
+			"leaf has %v points; max is %v", count, w.maxPointsInLeafNode)
+		leafBlockFPs[nodeID-numLeaves] = out.FilePointer()
+		if err := out.WriteVInt(int32(count)); err != nil {
+			return err
+		}
+		for i := int64(0); i < count; i++ {
+			p, err := reader.next()
+			if err != nil {
+				return err
+			}
+			if err = out.WriteVInt(int32(p.docID)); err != nil {
+				return err
+			}
+			if err = out.WriteLong(p.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// inner node: left subtree takes the extra point, if any
+	mid := (count + 1) / 2
+	if err := w.build(out, 2*nodeID, numLeaves, mid, reader, splitValues, leafBlockFPs); err != nil {
+		return err
+	}
+	if mid < count {
+		// split on the first value of the right subtree
+		p, err := reader.peek()
+		if err != nil {
+			return err
+		}
+		splitValues[nodeID] = p.value
+	} else if mid > 0 {
+		splitValues[nodeID] = reader.last.value
+	}
+	return w.build(out, 2*nodeID+1, numLeaves, count-mid, reader, splitValues, leafBlockFPs)
+}
+
+/*
+Reads sorted points back one at a time, from the heap or from the
+offline sorted file, with one point of look-ahead.
+*/
+type pointReader struct {
+	read     func() (point, error)
+	closer   func() error
+	buffered bool
+	buf      point
+	last     point // the last point returned by next()
+}
+
+func newHeapPointReader(pts points) *pointReader {
+	upto := 0
+	return &pointReader{
+		read: func() (point, error) {
+			p := pts[upto]
+			upto++
+			return p, nil
+		},
+		closer: func() error { return nil },
+	}
+}
+
+func newOfflinePointReader(in store.IndexInput) *pointReader {
+	reader := offline.NewByteSequencesReader(in)
+	return &pointReader{
+		read: func() (point, error) {
+			record, err := reader.Next()
+			if err != nil {
+				return point{}, err
+			}
+			assert2(len(record) == bytesPerOfflinePoint,
+				"offline point has %v bytes; expected %v", len(record), bytesPerOfflinePoint)
+			return decodePoint(record), nil
+		},
+		closer: in.Close,
+	}
+}
+
+func (r *pointReader) peek() (point, error) {
+	if !r.buffered {
+		p, err := r.read()
+		if err != nil {
+			return point{}, err
+		}
+		r.buf, r.buffered = p, true
+	}
+	return r.buf, nil
+}
+
+func (r *pointReader) next() (point, error) {
+	p, err := r.peek()
+	if err != nil {
+		return point{}, err
+	}
+	r.buffered = false
+	r.last = p
+	return p, nil
+}
+
+func (r *pointReader) Close() error {
+	return r.closer()
+}
+
+func assert2(ok bool, msg string, args ...interface{}) {
+	if !ok {
+		panic(fmt.Sprintf(msg, args...))
+	}
+}
@@ -16,6 +16,8 @@ Note: The maximum capacity BytesRef instance passed to add() must not
 be longer than BYTE_BLOCK_SIZE-2. The internal storage is limited to
 2GB total byte storage.
 */
+const BYTES_REF_HASH_DEFAULT_CAPACITY = 16
+
 type BytesRefHash struct {
 	pool       *ByteBlockPool
 	bytesStart []int
@@ -86,6 +88,20 @@ func (h *BytesRefHash) compact() []int {
 	return h.ids
 }
 
+/*
+Populates and returns a BytesRef with the bytes for the given
+bytesID.
+
+Note: the given bytesID must be a positive integer less than the
+current size (Size())
+*/
+func (h *BytesRefHash) Get(bytesId int, ref *BytesRef) *BytesRef {
+	assert2(h.bytesStart != nil, "bytesStart is nil - not initialized")
+	assert2(bytesId < len(h.bytesStart), "bytesId exceeds byteStart len: %v", len(h.bytesStart))
+	h.pool.SetBytesRef(ref, h.bytesStart[bytesId])
+	return ref
+}
+
 type bytesRefIntroSorter struct {
 	*IntroSorter
 	owner    *BytesRefHash
@@ -377,3 +393,37 @@ type BytesStartArray interface {
 	// clears the BytesStartArray and returns the cleared instance.
 	Clear() []int
 }
+
+/* A simple BytesStartArray that tracks memory allocation using a private Counter instance. */
+type DirectBytesStartArray struct {
+	initSize   int
+	bytesStart []int
+	bytesUsed  Counter
+}
+
+func NewDirectBytesStartArray(initSize int, counter Counter) *DirectBytesStartArray {
+	return &DirectBytesStartArray{
+		initSize:  initSize,
+		bytesUsed: counter,
+	}
+}
+
+func (a *DirectBytesStartArray) Clear() []int {
+	a.bytesStart = nil
+	return nil
+}
+
+func (a *DirectBytesStartArray) Grow() []int {
+	assert(a.bytesStart != nil)
+	a.bytesStart = GrowIntSlice(a.bytesStart, len(a.bytesStart)+1)
+	return a.bytesStart
+}
+
+func (a *DirectBytesStartArray) Init() []int {
+	a.bytesStart = make([]int, Oversize(a.initSize, NUM_BYTES_INT))
+	return a.bytesStart
+}
+
+func (a *DirectBytesStartArray) BytesUsed() Counter {
+	return a.bytesUsed
+}
@@ -1,5 +1,10 @@
 package util
 
+import (
+	"bytes"
+	"fmt"
+)
+
 // util/BytesRef.java
 
 /* An empty byte slice for convenience */
@@ -66,8 +71,23 @@ func (br *BytesRef) bytesEquals(other []byte) bool {
 	return true
 }
 
+/* Returns hex encoded bytes, e.g. "[61 62 63]". */
 func (br *BytesRef) String() string {
-	panic("not implemented yet")
+	return fmt.Sprintf("[% x]", br.ToBytes())
+}
+
+/* Interprets stored bytes as UTF8 bytes, returning the resulting string. */
+func (br *BytesRef) UTF8ToString() string {
+	return string(br.ToBytes())
+}
+
+/*
+Unsigned byte order comparison, which is also the UTF8 as Unicode
+code point order: a prefix sorts before any longer input, so the
+empty BytesRef sorts first.
+*/
+func (br *BytesRef) CompareTo(other *BytesRef) int {
+	return bytes.Compare(br.ToBytes(), other.ToBytes())
 }
 
 func (br *BytesRef) ToBytes() []byte {
@@ -107,6 +127,23 @@ func UTF8SortedAsUnicodeLess(aBytes, bBytes []byte) bool {
 	return aLen < bLen
 }
 
+/* Compares two BytesRef, returning <0, 0 or >0 like BytesRef.CompareTo(). */
+type BytesRefComparator func(a, b *BytesRef) int
+
+var UTF8SortedAsUnicodeComparator = BytesRefComparator(func(a, b *BytesRef) int {
+	return a.CompareTo(b)
+})
+
+/* Sorts a list of BytesRef with the given comparator, e.g. via sort.Sort(). */
+type BytesRefSorter struct {
+	Refs       []*BytesRef
+	Comparator BytesRefComparator
+}
+
+func (s BytesRefSorter) Len() int           { return len(s.Refs) }
+func (s BytesRefSorter) Less(i, j int) bool { return s.Comparator(s.Refs[i], s.Refs[j]) < 0 }
+func (s BytesRefSorter) Swap(i, j int)      { s.Refs[i], s.Refs[j] = s.Refs[j], s.Refs[i] }
+
 type BytesRefs [][]byte
 
 func (br BytesRefs) Len() int {
@@ -164,19 +201,21 @@ func (b *BytesRefBuilder) Grow(capacity int) {
 	b.ref.Bytes = GrowByteSlice(b.ref.Bytes, capacity)
 }
 
-func (b *BytesRefBuilder) append(bytes []byte) {
+/* Append the provided bytes to this builder. */
+func (b *BytesRefBuilder) Append(bytes []byte) {
 	b.Grow(b.ref.Length + len(bytes))
 	copy(b.ref.Bytes[b.ref.Length:], bytes)
 	b.ref.Length += len(bytes)
 }
 
-func (b *BytesRefBuilder) clear() {
+/* Reset this builder to the empty state. */
+func (b *BytesRefBuilder) Clear() {
 	b.SetLength(0)
 }
 
 func (b *BytesRefBuilder) Copy(ref []byte) {
-	b.clear()
-	b.append(ref)
+	b.Clear()
+	b.Append(ref)
 }
 
 func (b *BytesRefBuilder) Get() *BytesRef {
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestBytesRefCompareTo(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "a", -1},
+		{"a", "", 1},
+		{"ab", "abc", -1}, // a prefix sorts first
+		{"abc", "ab", 1},
+		{"abc", "abd", -1},
+		{"b", "abc", 1},
+		{"\x7f", "\xc3\xa9", -1}, // bytes compare unsigned
+		{"abc", "abc", 0},
+	}
+	for _, test := range tests {
+		// slice into a larger array to exercise offset
+		a := NewBytesRef([]byte("x"+test.a+"x"), 1, len(test.a))
+		b := NewBytesRefFrom([]byte(test.b))
+		if got := a.CompareTo(b); got != test.want {
+			t.Errorf("%q vs %q: expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestBytesRefSorter(t *testing.T) {
+	words := []string{"b", "ab", "", "abc", "\xc3\xa9", "a", "\x7f"}
+	want := []string{"", "a", "ab", "abc", "b", "\x7f", "\xc3\xa9"}
+	refs := make([]*BytesRef, len(words))
+	for i, w := range words {
+		refs[i] = NewBytesRefFrom([]byte(w))
+	}
+	sort.Sort(BytesRefSorter{refs, UTF8SortedAsUnicodeComparator})
+	for i, ref := range refs {
+		if ref.UTF8ToString() != want[i] {
+			t.Errorf("%v: expected %q, got %q", i, want[i], ref.UTF8ToString())
+		}
+	}
+}
+
+func TestBytesRefBuilder(t *testing.T) {
+	b := NewBytesRefBuilder()
+	b.Append([]byte("foo"))
+	b.Append([]byte("bar"))
+	if s := b.Get().UTF8ToString(); s != "foobar" {
+		t.Errorf("expected foobar, got %q", s)
+	}
+	if s := b.Get().String(); s != "[66 6f 6f 62 61 72]" {
+		t.Errorf("unexpected String(): %v", s)
+	}
+	b.Clear()
+	if b.Length() != 0 {
+		t.Errorf("expected empty builder, got %v", b.Get())
+	}
+	b.Grow(100)
+	if len(b.Bytes()) < 100 {
+		t.Errorf("expected capacity >= 100, got %v", len(b.Bytes()))
+	}
+	b.Copy([]byte("x"))
+	b.Set(0, 'y')
+	if s := b.Get().UTF8ToString(); s != "y" {
+		t.Errorf("expected y, got %q", s)
+	}
+}
@@ -22,7 +22,20 @@ bits if possible. Also, calling length() on the returned bits may
 return a value greater than numBits.
 */
 func EnsureFixedBitSet(bits *FixedBitSet, numBits int) *FixedBitSet {
-	panic("not implemented yet")
+	if numBits < bits.numBits {
+		return bits
+	}
+	numWords := fbits2words(numBits)
+	arr := bits.bits
+	if numWords >= len(arr) {
+		arr = make([]int64, Oversize(numWords+1, NUM_BYTES_LONG))
+		copy(arr, bits.bits)
+	}
+	return &FixedBitSet{
+		bits:     arr,
+		numBits:  len(arr) << 6,
+		numWords: len(arr),
+	}
 }
 
 /* returns the number of 64 bit words it would take to hold numBits */
@@ -56,7 +69,7 @@ func (b *FixedBitSet) IsCacheable() bool {
 }
 
 func (b *FixedBitSet) RamBytesUsed() int64 {
-	panic("not implemented yet")
+	return SizeOf(b.bits)
 }
 
 /*
@@ -68,12 +81,15 @@ func (b *FixedBitSet) Cardinality() int {
 }
 
 func (b *FixedBitSet) At(index int) bool {
-	panic("not implemented yet")
+	assert2(index >= 0 && index < b.numBits, "index=%v, numBits=%v", index, b.numBits)
+	wordNum := index >> 6 // div 64
+	bitmask := int64(1) << uint(index&63)
+	return (b.bits[wordNum] & bitmask) != 0
 }
 
 func (b *FixedBitSet) Set(index int) {
 	assert2(index >= 0 && index < b.numBits, "index=%v, numBits=%v", index, b.numBits)
 	wordNum := index >> 6 // div 64
-	bitmask := int64(1 << uint(index))
+	bitmask := int64(1) << uint(index&63)
 	b.bits[wordNum] |= bitmask
 }
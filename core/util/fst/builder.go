@@ -210,9 +210,7 @@ func (b *Builder) Add(input *util.IntsRef, output interface{}) error {
 	// }
 
 	// de-dup NO_OUTPUT since it must be a singleton:
-	if output == NO_OUTPUT {
-		output = NO_OUTPUT
-	}
+	output = dedupNoOutput(output)
 
 	assert2(b.lastInput.Length() == 0 || !input.Less(b.lastInput.Get()),
 		"inputs are added out of order, lastInput=%v vs input=%v",
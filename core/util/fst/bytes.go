@@ -1,6 +1,7 @@
 package fst
 
 import (
+	"errors"
 	"fmt"
 	"github.com/balzaczyy/golucene/core/util"
 )
@@ -13,6 +14,7 @@ type BytesStore struct {
 	blockMask uint32
 	current   []byte
 	nextWrite uint32
+	finished  bool // read-only after finish()
 }
 
 func newBytesStore() *BytesStore {
@@ -31,6 +33,36 @@ func newBytesStoreFromBits(blockBits uint32) *BytesStore {
 	return self
 }
 
+const (
+	// blocks smaller than this cost more in slice headers than they save
+	MIN_RECOMMENDED_BLOCK_BITS = 6
+	// largest block loadFST3() accepts
+	MAX_RECOMMENDED_BLOCK_BITS = 30
+	// the number of blocks recommendBlockBits() aims for
+	RECOMMENDED_BLOCK_COUNT = 4
+)
+
+/*
+Returns the block bits for a store expected to hold about
+expectedBytes, so that they fit into RECOMMENDED_BLOCK_COUNT blocks.
+
+Larger blocks mean fewer slices to hop between when reading, and
+fewer allocations when writing; but the last block is allocated in
+full, so up to a block's worth of memory is wasted until finish()
+trims it, and growing the store allocates that much at a time. A few
+blocks keep the waste to a fraction of the total while most reads
+stay within one block. The result is clamped to
+[MIN_RECOMMENDED_BLOCK_BITS, MAX_RECOMMENDED_BLOCK_BITS].
+*/
+func recommendBlockBits(expectedBytes int64) uint32 {
+	blockBits := uint32(MIN_RECOMMENDED_BLOCK_BITS)
+	for blockBits < MAX_RECOMMENDED_BLOCK_BITS &&
+		int64(RECOMMENDED_BLOCK_COUNT)<<blockBits < expectedBytes {
+		blockBits++
+	}
+	return blockBits
+}
+
 func newBytesStoreFromInput(in util.DataInput, numBytes int64, maxBlockSize uint32) (bs *BytesStore, err error) {
 	var blockSize uint32 = 2
 	var blockBits uint32 = 1
@@ -58,10 +90,16 @@ func newBytesStoreFromInput(in util.DataInput, numBytes int64, maxBlockSize uint
 	}
 	// So .getPosition still works
 	self.nextWrite = uint32(len(self.blocks[len(self.blocks)-1]))
+	self.finished = true
 	return self, nil
 }
 
+func (bs *BytesStore) ensureWritable() {
+	assert2(!bs.finished, "BytesStore is read-only after finish()")
+}
+
 func (bs *BytesStore) WriteByte(b byte) error {
+	bs.ensureWritable()
 	if bs.nextWrite == bs.blockSize {
 		bs.current = make([]byte, bs.blockSize)
 		bs.blocks = append(bs.blocks, bs.current)
@@ -73,8 +111,14 @@ func (bs *BytesStore) WriteByte(b byte) error {
 }
 
 func (bs *BytesStore) WriteBytes(buf []byte) error {
-	var offset uint32 = 0
-	length := uint32(len(buf))
+	return bs.WriteBytesRange(buf, 0, len(buf))
+}
+
+func (bs *BytesStore) WriteBytesRange(buf []byte, off, size int) error {
+	bs.ensureWritable()
+	assert2(off >= 0 && size >= 0 && off+size <= len(buf),
+		"offset=%v length=%v len(buf)=%v", off, size, len(buf))
+	offset, length := uint32(off), uint32(size)
 	for length > 0 {
 		chunk := bs.blockSize - bs.nextWrite
 		if length <= chunk {
@@ -95,10 +139,19 @@ func (bs *BytesStore) WriteBytes(buf []byte) error {
 	return nil
 }
 
+/*
+Overwrites len(b) already written bytes starting at absolute position
+dest, spanning blocks as needed. The write position is not changed.
+*/
 func (s *BytesStore) writeBytesAt(dest int64, b []byte) {
+	s.ensureWritable()
 	length := len(b)
-	assert2(dest+int64(length) <= s.position(),
-		"dest=%v pos=%v len=%v", dest, s.position(), length)
+	assert2(dest >= 0 && dest+int64(length) <= s.getPosition(),
+		"writeBytesAt: dest=%v len=%v is outside of the %v bytes written",
+		dest, length, s.getPosition())
+	if length == 0 {
+		return
+	}
 
 	end := dest + int64(length)
 	blockIndex := int(end >> s.blockBits)
@@ -122,9 +175,27 @@ func (s *BytesStore) writeBytesAt(dest int64, b []byte) {
 	}
 }
 
-func (s *BytesStore) copyBytesInside(src, dest int64, length int) {
-	assert(src < dest)
+/*
+Copies length bytes from absolute position src to absolute position
+dest, both within the already written bytes. Overlapping ranges are
+handled safely in either direction.
+*/
+func (s *BytesStore) copyBytes(src, dest int64, length int) {
+	s.ensureWritable()
+	assert2(src >= 0 && dest >= 0 && length >= 0 &&
+		src+int64(length) <= s.getPosition() && dest+int64(length) <= s.getPosition(),
+		"copyBytes: src=%v dest=%v len=%v is outside of the %v bytes written",
+		src, dest, length, s.getPosition())
+	if length == 0 || src == dest {
+		return
+	}
+	if src > dest {
+		s.copyBytesForward(src, dest, length)
+		return
+	}
 
+	// Copy backwards, from the end, so that an overlapping source is
+	// read before it is overwritten:
 	end := src + int64(length)
 
 	blockIndex := int(end >> s.blockBits)
@@ -148,10 +219,35 @@ func (s *BytesStore) copyBytesInside(src, dest int64, length int) {
 	}
 }
 
+// Copies from the start via a scratch buffer, for src > dest.
+func (s *BytesStore) copyBytesForward(src, dest int64, length int) {
+	chunk := int(s.blockSize)
+	if length < chunk {
+		chunk = length
+	}
+	buf := make([]byte, chunk)
+	for length > 0 {
+		if length < len(buf) {
+			buf = buf[:length]
+		}
+		blockIndex := int(src >> s.blockBits)
+		offset := int(src & int64(s.blockMask))
+		n := copy(buf, s.blocks[blockIndex][offset:])
+		if n < len(buf) {
+			copy(buf[n:], s.blocks[blockIndex+1])
+		}
+		s.writeBytesAt(dest, buf)
+		src += int64(len(buf))
+		dest += int64(len(buf))
+		length -= len(buf)
+	}
+}
+
 /* Reverse from srcPos, inclusive, to destPos, inclusive. */
 func (s *BytesStore) reverse(srcPos, destPos int64) {
+	s.ensureWritable()
 	assert(srcPos < destPos)
-	assert(destPos < s.position())
+	assert(destPos < s.getPosition())
 	// fmt.Printf("reverse src=%v dest=%v\n", srcPos, destPos)
 
 	srcBlockIndex := int(srcPos >> s.blockBits)
@@ -171,20 +267,19 @@ func (s *BytesStore) reverse(srcPos, destPos int64) {
 		if src++; src == int(s.blockSize) {
 			srcBlockIndex++
 			srcBlock = s.blocks[srcBlockIndex]
-			fmt.Printf("  set destBlock=%v srcBlock=%v\n", destBlock, srcBlock)
 			src = 0
 		}
 
 		if dest--; dest == -1 {
 			destBlockIndex--
 			destBlock = s.blocks[destBlockIndex]
-			fmt.Printf("  set destBlock=%v srcBlock=%v\n", destBlock, srcBlock)
 			dest = int(s.blockSize - 1)
 		}
 	}
 }
 
 func (s *BytesStore) skipBytes(length int) {
+	s.ensureWritable()
 	for length > 0 {
 		chunk := int(s.blockSize) - int(s.nextWrite)
 		if length <= chunk {
@@ -198,20 +293,91 @@ func (s *BytesStore) skipBytes(length int) {
 	}
 }
 
-func (s *BytesStore) position() int64 {
+/*
+Discards all bytes from newLen on, so that the next write appends at
+newLen. Whole trailing blocks are dropped.
+*/
+func (s *BytesStore) truncate(newLen int64) error {
+	s.ensureWritable()
+	if newLen < 0 || newLen > s.getPosition() {
+		return errors.New(fmt.Sprintf(
+			"cannot truncate to %v: only %v bytes written", newLen, s.getPosition()))
+	}
+	blockIndex := int(newLen >> s.blockBits)
+	s.nextWrite = uint32(newLen & int64(s.blockMask))
+	if s.nextWrite == 0 {
+		blockIndex--
+		s.nextWrite = s.blockSize
+	}
+	for i := blockIndex + 1; i < len(s.blocks); i++ {
+		s.blocks[i] = nil
+	}
+	s.blocks = s.blocks[:blockIndex+1]
+	if newLen == 0 {
+		s.current = nil
+	} else {
+		s.current = s.blocks[blockIndex]
+	}
+	assert(newLen == s.getPosition())
+	return nil
+}
+
+/* Returns the total number of bytes written so far. */
+func (s *BytesStore) getPosition() int64 {
 	return int64(len(s.blocks)-1)*int64(s.blockSize) + int64(s.nextWrite)
 }
 
-func (s *BytesStore) finish() {
+/*
+Discards all written bytes so the store can be reused, e.g. across
+FST builds. The first block is kept (cleared) if it has the full
+block size, so that small FSTs don't allocate again; a block trimmed
+by finish() is dropped instead.
+*/
+func (s *BytesStore) Reset() {
+	var first []byte
+	if len(s.blocks) > 0 && len(s.blocks[0]) == int(s.blockSize) {
+		first = s.blocks[0]
+		for i := range first {
+			first[i] = 0
+		}
+	}
+	for i := range s.blocks {
+		s.blocks[i] = nil
+	}
+	s.blocks = s.blocks[:0]
+	if first != nil {
+		s.blocks = append(s.blocks, first)
+		s.current = first
+		s.nextWrite = 0
+	} else {
+		s.current = nil
+		s.nextWrite = s.blockSize
+	}
+	s.finished = false
+}
+
+/*
+Freezes the store for reading: the last partial block is trimmed to
+the bytes actually written, so every block but the last has the full
+block size and readers see exactly getPosition() bytes. Any further
+write panics until Reset(). Returns the final length.
+*/
+func (s *BytesStore) finish() int64 {
 	if s.current != nil {
 		lastBuffer := make([]byte, s.nextWrite)
 		copy(lastBuffer, s.current[:s.nextWrite])
 		s.blocks[len(s.blocks)-1] = lastBuffer
 		s.current = nil
 	}
+	s.finished = true
+	return s.getPosition()
 }
 
-/* Writes all of our bytes to the target DataOutput. */
+/*
+Writes all of our bytes, block by block, to the target DataOutput.
+The store should be finished first, so that no unwritten tail of the
+last block is included.
+*/
 func (s *BytesStore) writeTo(out util.DataOutput) error {
 	for _, block := range s.blocks {
 		err := out.WriteBytes(block)
@@ -349,7 +515,7 @@ func (r *BytesStoreReverseReader) setPosition(pos int64) {
 	// setPosition(0), the next byte you read is
 	// bytes[0] ... but I would expect bytes[-1] (ie,
 	// EOF)...?
-	bufferIndex := int32(pos >> r.owner.blockSize)
+	bufferIndex := int32(pos >> r.owner.blockBits)
 	r.nextBuffer = bufferIndex - 1
 	r.current = r.owner.blocks[bufferIndex]
 	r.nextRead = int32(uint32(pos) & r.owner.blockMask)
@@ -0,0 +1,448 @@
+package fst
+
+import (
+	"bytes"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+	"testing"
+)
+
+func storeContent(t *testing.T, s *BytesStore) []byte {
+	buf := make([]byte, s.getPosition())
+	if err := s.forwardReader().ReadBytes(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// Minimal DataWriter to exercise DataOutputImpl's default WriteBytesRange.
+type sliceWriter struct {
+	bytes.Buffer
+}
+
+func (w *sliceWriter) WriteBytes(buf []byte) error {
+	_, err := w.Write(buf)
+	return err
+}
+
+func TestWriteBytesRange(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	for _, c := range []struct{ offset, length int }{
+		{0, 0}, {0, len(src)}, {3, 9}, {7, 1}, {5, len(src) - 5},
+	} {
+		// small blocks so that ranges span several of them
+		for _, blockBits := range []uint32{2, 4, 15} {
+			s1, s2 := newBytesStoreFromBits(blockBits), newBytesStoreFromBits(blockBits)
+			for _, s := range []*BytesStore{s1, s2} {
+				if err := s.WriteByte('>'); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s1.WriteBytesRange(src, c.offset, c.length); err != nil {
+				t.Fatal(err)
+			}
+			if err := s2.WriteBytes(src[c.offset : c.offset+c.length]); err != nil {
+				t.Fatal(err)
+			}
+			if b1, b2 := storeContent(t, s1), storeContent(t, s2); !bytes.Equal(b1, b2) {
+				t.Errorf("blockBits=%v offset=%v length=%v: %q != %q",
+					blockBits, c.offset, c.length, b1, b2)
+			}
+		}
+
+		w := &sliceWriter{}
+		if err := util.NewDataOutput(w).WriteBytesRange(src, c.offset, c.length); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Bytes(), src[c.offset:c.offset+c.length]) {
+			t.Errorf("offset=%v length=%v: got %q", c.offset, c.length, w.Bytes())
+		}
+	}
+}
+
+func TestFixedIntRoundTrip(t *testing.T) {
+	s := newBytesStoreFromBits(2) // 4-byte blocks, so values span blocks
+	if err := s.WriteByte(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteShort(math.MinInt16); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteInt(math.MinInt32); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteLong(math.MaxInt64); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteLong(0xFFFFFFFF); err != nil {
+		t.Fatal(err)
+	}
+	in := s.forwardReader()
+	in.skipBytes(1)
+	if n, err := in.ReadShort(); err != nil || n != math.MinInt16 {
+		t.Errorf("short: %v, %v", n, err)
+	}
+	if n, err := in.ReadInt(); err != nil || n != math.MinInt32 {
+		t.Errorf("int: %v, %v", n, err)
+	}
+	for _, v := range []int64{math.MaxInt64, 0xFFFFFFFF} {
+		if n, err := in.ReadLong(); err != nil || n != v {
+			t.Errorf("long: %v != %v, %v", n, v, err)
+		}
+	}
+}
+
+func TestBytesStoreReset(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	s := newBytesStoreFromBits(3)
+	if err := s.WriteBytes(src); err != nil {
+		t.Fatal(err)
+	}
+	first := s.blocks[0]
+	s.Reset()
+	if s.getPosition() != 0 || len(s.blocks) != 1 {
+		t.Fatalf("position=%v blocks=%v after Reset", s.getPosition(), len(s.blocks))
+	}
+	if len(storeContent(t, s)) != 0 {
+		t.Error("reset store should be empty")
+	}
+
+	// behaves like a fresh store, in the retained block
+	fresh := newBytesStoreFromBits(3)
+	for _, store := range []*BytesStore{s, fresh} {
+		if err := store.WriteByte('>'); err != nil {
+			t.Fatal(err)
+		}
+		store.skipBytes(2)
+		if err := store.WriteBytes(src[5:15]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if &s.blocks[0][0] != &first[0] {
+		t.Error("first block was not reused")
+	}
+	if b1, b2 := storeContent(t, s), storeContent(t, fresh); !bytes.Equal(b1, b2) {
+		t.Errorf("%q != %q", b1, b2)
+	}
+
+	// a block trimmed by finish() is not reused
+	s = newBytesStoreFromBits(3)
+	if err := s.WriteBytes(src[:3]); err != nil {
+		t.Fatal(err)
+	}
+	s.finish()
+	s.Reset()
+	if s.getPosition() != 0 || len(s.blocks) != 0 {
+		t.Fatalf("position=%v blocks=%v after Reset", s.getPosition(), len(s.blocks))
+	}
+	if err := s.WriteBytes(src); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(storeContent(t, s), src) {
+		t.Errorf("unexpected content %q", storeContent(t, s))
+	}
+}
+
+func TestReverseReader(t *testing.T) {
+	const n = 19
+	for _, c := range []struct {
+		blockBits   uint32
+		allowSingle bool
+	}{
+		{2, true},  // 4 full 4-byte blocks plus 3 bytes
+		{5, true},  // single block, ReverseBytesReader
+		{5, false}, // single block, BytesStoreReverseReader
+	} {
+		s := newBytesStoreFromBits(c.blockBits)
+		for i := 0; i < n; i++ {
+			if err := s.WriteByte(byte(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := s.reverseReaderAllowSingle(c.allowSingle)
+		assert(r.reversed())
+
+		r.setPosition(n - 1)
+		for i := int64(n - 1); i >= 0; i-- {
+			if pos := r.getPosition(); pos != i {
+				t.Fatalf("%T: position %v, expected %v", r, pos, i)
+			}
+			if b, err := r.ReadByte(); err != nil || b != byte(i) {
+				t.Fatalf("%T: read %v at %v: %v", r, b, i, err)
+			}
+		}
+		if pos := r.getPosition(); pos != -1 {
+			t.Errorf("%T: position %v after reading all bytes", r, pos)
+		}
+
+		// around block edges: first byte of a block, last byte of the
+		// previous one
+		for _, pos := range []int64{3, 4, 7, 8, 16, 18} {
+			r.setPosition(pos)
+			if p := r.getPosition(); p != pos {
+				t.Errorf("%T: setPosition(%v) moved to %v", r, pos, p)
+			}
+			buf := make([]byte, 2)
+			if err := r.ReadBytes(buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf[0] != byte(pos) || buf[1] != byte(pos-1) || r.getPosition() != pos-2 {
+				t.Errorf("%T: read %v from %v, now at %v", r, buf, pos, r.getPosition())
+			}
+		}
+
+		r.setPosition(18)
+		r.skipBytes(5)
+		if b, err := r.ReadByte(); err != nil || b != 13 {
+			t.Errorf("%T: read %v after skip: %v", r, b, err)
+		}
+	}
+}
+
+func TestWriteBytesAt(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	for _, c := range []struct {
+		dest  int64
+		patch string
+	}{
+		{1, "XY"},      // within the first block
+		{9, "XY"},      // within a middle block
+		{7, "XY"},      // straddling two blocks
+		{6, "XYZWVUT"}, // spanning three blocks
+		{18, "XY"},     // up to the end
+		{0, ""},
+	} {
+		s := newBytesStoreFromBits(2)
+		if err := s.WriteBytes(src); err != nil {
+			t.Fatal(err)
+		}
+		s.writeBytesAt(c.dest, []byte(c.patch))
+		if pos := s.getPosition(); pos != int64(len(src)) {
+			t.Errorf("dest=%v: position moved to %v", c.dest, pos)
+		}
+		expected := append([]byte(nil), src...)
+		copy(expected[c.dest:], c.patch)
+		if got := storeContent(t, s); !bytes.Equal(got, expected) {
+			t.Errorf("dest=%v: %q != %q", c.dest, got, expected)
+		}
+	}
+
+	for _, dest := range []int64{-1, 19, 20} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("dest=%v: writing past the written bytes should panic", dest)
+				}
+			}()
+			s := newBytesStoreFromBits(2)
+			s.WriteBytes(src)
+			s.writeBytesAt(dest, []byte("XY"))
+		}()
+	}
+}
+
+// Returns a BytesStore with 4-byte blocks and the same bytes as src.
+func newTestStore(t *testing.T, src []byte) *BytesStore {
+	s := newBytesStoreFromBits(2)
+	if err := s.WriteBytes(src); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCopyBytes(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	for _, c := range []struct {
+		src, dest int64
+		length    int
+	}{
+		{0, 10, 5}, // disjoint, forward
+		{12, 1, 6}, // disjoint, backward
+		{2, 5, 10}, // overlapping, src < dest
+		{5, 2, 10}, // overlapping, src > dest
+		{3, 4, 15}, // off by one, across all blocks
+		{4, 3, 15}, // off by one, across all blocks
+		{0, 0, 20}, // in place
+		{19, 0, 1}, // single byte
+		{7, 11, 0}, // empty
+		{0, 4, 16}, // block aligned
+	} {
+		s := newTestStore(t, src)
+		s.copyBytes(c.src, c.dest, c.length)
+		expected := append([]byte(nil), src...)
+		copy(expected[c.dest:], src[c.src:c.src+int64(c.length)])
+		if got := storeContent(t, s); !bytes.Equal(got, expected) {
+			t.Errorf("copyBytes(%v, %v, %v): %q != %q", c.src, c.dest, c.length, got, expected)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	for _, c := range []struct{ src, dest int64 }{
+		{0, 19}, {0, 1}, {3, 4}, {2, 9}, {5, 14}, {8, 11}, {13, 19},
+	} {
+		s := newTestStore(t, src)
+		s.reverse(c.src, c.dest)
+		expected := append([]byte(nil), src...)
+		for i, j := c.src, c.dest; i < j; i, j = i+1, j-1 {
+			expected[i], expected[j] = expected[j], expected[i]
+		}
+		if got := storeContent(t, s); !bytes.Equal(got, expected) {
+			t.Errorf("reverse(%v, %v): %q != %q", c.src, c.dest, got, expected)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	for _, newLen := range []int64{20, 19, 16, 13, 12, 4, 3, 1, 0} {
+		s := newTestStore(t, src)
+		if err := s.truncate(newLen); err != nil {
+			t.Fatal(err)
+		}
+		if pos := s.getPosition(); pos != newLen {
+			t.Errorf("truncate(%v): position %v", newLen, pos)
+		}
+		if blocks := int64(len(s.blocks)); blocks != (newLen+3)/4 {
+			t.Errorf("truncate(%v): %v blocks left", newLen, blocks)
+		}
+		if err := s.WriteBytes([]byte("XYZWV")); err != nil {
+			t.Fatal(err)
+		}
+		expected := append(append([]byte(nil), src[:newLen]...), "XYZWV"...)
+		if got := storeContent(t, s); !bytes.Equal(got, expected) {
+			t.Errorf("truncate(%v): %q != %q", newLen, got, expected)
+		}
+	}
+
+	s := newTestStore(t, src)
+	for _, newLen := range []int64{21, -1} {
+		if err := s.truncate(newLen); err == nil {
+			t.Errorf("truncate(%v) of %v bytes should fail", newLen, len(src))
+		}
+	}
+	if pos := s.getPosition(); pos != int64(len(src)) {
+		t.Errorf("failed truncate changed position to %v", pos)
+	}
+}
+
+func TestFinishWriteTo(t *testing.T) {
+	src := []byte("0123456789abcdefghij")
+	s := newTestStore(t, src[:18]) // last block only half full
+	if n := s.finish(); n != 18 {
+		t.Fatalf("finish() returned %v", n)
+	}
+	for i, block := range s.blocks {
+		if i < len(s.blocks)-1 && len(block) != int(s.blockSize) || i == len(s.blocks)-1 && len(block) != 2 {
+			t.Errorf("block %v has %v bytes", i, len(block))
+		}
+	}
+	if b := storeContent(t, s); !bytes.Equal(b, src[:18]) {
+		t.Errorf("%q != %q", b, src[:18])
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("write after finish() should panic")
+			}
+		}()
+		s.WriteByte('x')
+	}()
+
+	dir := store.NewRAMDirectory()
+	out, err := dir.CreateOutput("fst", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.writeTo(out); err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	in, err := dir.OpenInput("fst", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if in.Length() != 18 {
+		t.Fatalf("wrote %v bytes", in.Length())
+	}
+	loaded, err := newBytesStoreFromInput(in, in.Length(), 1<<3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.getPosition() != 18 {
+		t.Errorf("loaded position=%v", loaded.getPosition())
+	}
+	if b := storeContent(t, loaded); !bytes.Equal(b, src[:18]) {
+		t.Errorf("%q != %q", b, src[:18])
+	}
+
+	// Reset makes the store writable again
+	s.Reset()
+	if err = s.WriteBytes(src); err != nil {
+		t.Fatal(err)
+	}
+	if b := storeContent(t, s); !bytes.Equal(b, src) {
+		t.Errorf("%q != %q", b, src)
+	}
+}
+
+func TestRecommendBlockBits(t *testing.T) {
+	for _, c := range []struct {
+		expected int64
+		bits     uint32
+	}{
+		{-1, MIN_RECOMMENDED_BLOCK_BITS},
+		{0, MIN_RECOMMENDED_BLOCK_BITS},
+		{100, MIN_RECOMMENDED_BLOCK_BITS},
+		{4 << 6, 6},
+		{4<<6 + 1, 7},
+		{1 << 20, 18},
+		{1<<20 + 1, 19},
+		{1 << 40, MAX_RECOMMENDED_BLOCK_BITS},
+		{math.MaxInt64, MAX_RECOMMENDED_BLOCK_BITS},
+	} {
+		bits := recommendBlockBits(c.expected)
+		if bits != c.bits {
+			t.Errorf("recommendBlockBits(%v)=%v, expected %v", c.expected, bits, c.bits)
+		}
+		if c.expected > 0 && bits < MAX_RECOMMENDED_BLOCK_BITS &&
+			c.expected > int64(RECOMMENDED_BLOCK_COUNT)<<bits {
+			t.Errorf("%v bytes need more than %v blocks of %v bits", c.expected, RECOMMENDED_BLOCK_COUNT, bits)
+		}
+	}
+}
+
+func TestLargeBlockRoundTrip(t *testing.T) {
+	src := make([]byte, 3<<20+123)
+	for i := range src {
+		src[i] = byte(i * 31 >> 3)
+	}
+	s := newBytesStoreFromBits(recommendBlockBits(int64(len(src))))
+	if s.blockBits != 20 {
+		t.Fatalf("blockBits=%v", s.blockBits)
+	}
+	if err := s.WriteBytes(src); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.blocks) != RECOMMENDED_BLOCK_COUNT {
+		t.Errorf("%v blocks", len(s.blocks))
+	}
+	if n := s.finish(); n != int64(len(src)) {
+		t.Fatalf("finish() returned %v", n)
+	}
+	if b := storeContent(t, s); !bytes.Equal(b, src) {
+		t.Error("forward read differs")
+	}
+	r := s.reverseReader()
+	for _, pos := range []int{len(src) - 1, len(src) - 2, 1 << 20, 1<<20 - 1} {
+		r.setPosition(int64(pos))
+		if b, err := r.ReadByte(); err != nil || b != src[pos] {
+			t.Errorf("reverse read at %v: %v != %v, %v", pos, b, src[pos], err)
+		}
+	}
+}
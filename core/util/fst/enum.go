@@ -75,7 +75,7 @@ func (e *FSTEnum) incr() {
 		copy(newArcs, e.arcs)
 		e.arcs = newArcs
 	}
-	if len(e.output) < e.upto {
+	if len(e.output) <= e.upto {
 		newOutput := make([]interface{}, util.Oversize(e.upto+1, util.NUM_BYTES_OBJECT_REF))
 		copy(newOutput, e.output)
 		e.output = newOutput
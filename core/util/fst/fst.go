@@ -154,7 +154,7 @@ type FST struct {
 	allowArrayArcs bool
 
 	cachedRootArcs          []*Arc
-	assertingCachedRootArcs []*Arc // only set wit assert
+	assertingCachedRootArcs []*Arc // only set with debugRootArcs
 
 	version int32
 
@@ -216,58 +216,60 @@ func loadFST3(in util.DataInput, outputs Outputs, maxBlockBits uint32) (fst *FST
 	if err != nil {
 		return nil, err
 	}
-	if b, err := in.ReadByte(); err == nil {
-		fst.packed = (b == 1)
-	} else {
+	var b byte
+	if b, err = in.ReadByte(); err != nil {
 		return nil, err
 	}
-	if b, err := in.ReadByte(); err == nil {
-		if b == 1 {
-			// accepts empty string
-			// 1 KB blocks:
-			emptyBytes := newBytesStoreFromBits(10)
-			if numBytes, err := in.ReadVInt(); err == nil {
-				// log.Printf("Number of bytes: %v", numBytes)
-				emptyBytes.CopyBytes(in, int64(numBytes))
-
-				// De-serialize empty-string output:
-				var reader BytesReader
-				if fst.packed {
-					// log.Printf("Forward reader.")
-					reader = emptyBytes.forwardReader()
-				} else {
-					// log.Printf("Reverse reader.")
-					reader = emptyBytes.reverseReader()
-					// NoOutputs uses 0 bytes when writing its output,
-					// so we have to check here else BytesStore gets
-					// angry:
-					if numBytes > 0 {
-						reader.setPosition(int64(numBytes - 1))
-					}
-				}
-				// log.Printf("Reading final output from %v to %v...\n", reader, outputs)
-				fst.emptyOutput, err = outputs.ReadFinalOutput(reader)
-			}
-		} // else emptyOutput = nil
-	}
-	if err != nil {
+	fst.packed = (b == 1)
+	if b, err = in.ReadByte(); err != nil {
 		return nil, err
 	}
+	if b == 1 {
+		// accepts empty string
+		var numBytes int32
+		if numBytes, err = in.ReadVInt(); err != nil {
+			return nil, err
+		}
+		emptyBytes := newBytesStoreFromBits(recommendBlockBits(int64(numBytes)))
+		// log.Printf("Number of bytes: %v", numBytes)
+		if err = emptyBytes.CopyBytes(in, int64(numBytes)); err != nil {
+			return nil, err
+		}
 
-	if t, err := in.ReadByte(); err == nil {
-		switch t {
-		case 0:
-			fst.inputType = INPUT_TYPE_BYTE1
-		case 1:
-			fst.inputType = INPUT_TYPE_BYTE2
-		case 2:
-			fst.inputType = INPUT_TYPE_BYTE4
-		default:
-			panic(fmt.Sprintf("invalid input type %v", t))
+		// De-serialize empty-string output:
+		var reader BytesReader
+		if fst.packed {
+			// log.Printf("Forward reader.")
+			reader = emptyBytes.forwardReader()
+		} else {
+			// log.Printf("Reverse reader.")
+			reader = emptyBytes.reverseReader()
+			// NoOutputs uses 0 bytes when writing its output,
+			// so we have to check here else BytesStore gets
+			// angry:
+			if numBytes > 0 {
+				reader.setPosition(int64(numBytes - 1))
+			}
 		}
-	} else {
+		// log.Printf("Reading final output from %v to %v...\n", reader, outputs)
+		if fst.emptyOutput, err = outputs.ReadFinalOutput(reader); err != nil {
+			return nil, err
+		}
+	} // else emptyOutput = nil
+
+	if b, err = in.ReadByte(); err != nil {
 		return nil, err
 	}
+	switch b {
+	case 0:
+		fst.inputType = INPUT_TYPE_BYTE1
+	case 1:
+		fst.inputType = INPUT_TYPE_BYTE2
+	case 2:
+		fst.inputType = INPUT_TYPE_BYTE4
+	default:
+		return nil, codec.NewCorruptIndexError(fmt.Sprintf("invalid input type %v", b), in)
+	}
 
 	if fst.packed {
 		if fst.nodeRefToAddress, err = packed.NewPackedReader(in); err != nil {
@@ -275,27 +277,36 @@ func loadFST3(in util.DataInput, outputs Outputs, maxBlockBits uint32) (fst *FST
 		}
 	} // else nodeRefToAddress = nil
 
-	if fst.startNode, err = in.ReadVLong(); err == nil {
-		if fst.nodeCount, err = in.ReadVLong(); err == nil {
-			if fst.arcCount, err = in.ReadVLong(); err == nil {
-				if fst.arcWithOutputCount, err = in.ReadVLong(); err == nil {
-					if numBytes, err := in.ReadVLong(); err == nil {
-						if fst.bytes, err = newBytesStoreFromInput(in, numBytes, 1<<maxBlockBits); err == nil {
-							fst.NO_OUTPUT = outputs.NoOutput()
-
-							err = fst.cacheRootArcs()
-
-							// NOTE: bogus because this is only used during
-							// building; we need to break out mutable FST from
-							// immutable
-							// fst.allowArrayArcs = false
-						}
-					}
-				}
-			}
-		}
+	if fst.startNode, err = in.ReadVLong(); err != nil {
+		return nil, err
+	}
+	if fst.nodeCount, err = in.ReadVLong(); err != nil {
+		return nil, err
+	}
+	if fst.arcCount, err = in.ReadVLong(); err != nil {
+		return nil, err
+	}
+	if fst.arcWithOutputCount, err = in.ReadVLong(); err != nil {
+		return nil, err
+	}
+	var numBytes int64
+	if numBytes, err = in.ReadVLong(); err != nil {
+		return nil, err
 	}
-	return fst, err
+	if fst.bytes, err = newBytesStoreFromInput(in, numBytes, 1<<maxBlockBits); err != nil {
+		return nil, err
+	}
+	fst.NO_OUTPUT = outputs.NoOutput()
+
+	if err = fst.cacheRootArcs(); err != nil {
+		return nil, err
+	}
+
+	// NOTE: bogus because this is only used during
+	// building; we need to break out mutable FST from
+	// immutable
+	// fst.allowArrayArcs = false
+	return fst, nil
 }
 
 func (t *FST) ramBytesUsed(arcs []*Arc) int64 {
@@ -336,15 +347,32 @@ func (t *FST) getNodeAddress(node int64) int64 {
 	}
 }
 
+/*
+If set, a second copy of the root arcs is kept, and every lookup hitting
+the root arc cache checks that the cached arcs were not modified by a
+caller (LUCENE-5152). This is what Lucene does with assertions enabled;
+it's too slow to be on by default, as it defeats the cache.
+*/
+var debugRootArcs = false
+
+/*
+Caches the root node's arcs with labels < 0x80 in an array indexed by
+label, so FindTargetArc() resolves the first transition of a lookup
+without reading the node. Other labels are found by reading the node.
+*/
 func (t *FST) cacheRootArcs() error {
 	t.cachedRootArcs = make([]*Arc, 0x80)
-	t.readRootArcs(t.cachedRootArcs)
+	if err := t.readRootArcs(t.cachedRootArcs); err != nil {
+		return err
+	}
 	t.cachedArcsBytesUsed += int(t.ramBytesUsed(t.cachedRootArcs))
 
-	if err := t.setAssertingRootArcs(t.cachedRootArcs); err != nil {
-		return err
+	if debugRootArcs {
+		if err := t.setAssertingRootArcs(t.cachedRootArcs); err != nil {
+			return err
+		}
+		t.assertRootArcs()
 	}
-	t.assertRootArcs()
 	return nil
 }
 
@@ -444,6 +472,17 @@ func CompareFSTValue(a, b interface{}) bool {
 	return equals(a, b)
 }
 
+/*
+Looks up the output for input. found is false if the input is not
+accepted by the FST.
+*/
+func (t *FST) Get(input []byte) (output interface{}, found bool, err error) {
+	if output, err = GetFSTOutput(t, input); err != nil || output == nil {
+		return nil, false, err
+	}
+	return output, true, nil
+}
+
 func (t *FST) EmptyOutput() interface{} {
 	return t.emptyOutput
 }
@@ -463,10 +502,12 @@ func (t *FST) Save(out util.DataOutput) error {
 	_, ok := t.nodeRefToAddress.(packed.Mutable)
 	assert2(!t.packed || ok, "cannot save a FST which has been loaded from disk ")
 	err := codec.WriteHeader(out, FST_FILE_FORMAT_NAME, VERSION_CURRENT)
-	if err == nil && t.packed {
-		err = out.WriteByte(1)
-	} else {
-		err = out.WriteByte(0)
+	if err == nil {
+		if t.packed {
+			err = out.WriteByte(1)
+		} else {
+			err = out.WriteByte(0)
+		}
 	}
 	// TODO: really we should encode this as an arc, arriving
 	// to the root node, instead of special casing here:
@@ -532,7 +573,7 @@ func (t *FST) Save(out util.DataOutput) error {
 			if err == nil {
 				err = out.WriteVLong(t.arcWithOutputCount)
 				if err == nil {
-					err = out.WriteVLong(t.bytes.position())
+					err = out.WriteVLong(t.bytes.getPosition())
 					if err == nil {
 						err = t.bytes.writeTo(out)
 					}
@@ -543,6 +584,49 @@ func (t *FST) Save(out util.DataOutput) error {
 	return err
 }
 
+/* Writes this FST to a new file in dir, followed by a codec footer. */
+func (t *FST) SaveTo(dir store.Directory, name string) (err error) {
+	var out store.IndexOutput
+	if out, err = dir.CreateOutput(name, store.IO_CONTEXT_DEFAULT); err != nil {
+		return err
+	}
+	success := false
+	defer func() {
+		if success {
+			err = out.Close()
+		} else {
+			util.CloseWhileSuppressingError(out)
+		}
+	}()
+	if err = t.Save(out); err != nil {
+		return err
+	}
+	if err = codec.WriteFooter(out); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+/*
+Reads an FST previously written by SaveTo(), verifying the codec
+footer checksum.
+*/
+func LoadFSTFrom(dir store.Directory, name string, outputs Outputs) (fst *FST, err error) {
+	var in store.ChecksumIndexInput
+	if in, err = dir.OpenChecksumInput(name, store.IO_CONTEXT_READONCE); err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	if fst, err = LoadFST(in, outputs); err != nil {
+		return nil, err
+	}
+	if _, err = codec.CheckFooter(in); err != nil {
+		return nil, err
+	}
+	return fst, nil
+}
+
 func (t *FST) writeLabel(out util.DataOutput, v int) error {
 	assert2(v >= 0, "v=%v", v)
 	if t.inputType == INPUT_TYPE_BYTE1 {
@@ -558,12 +642,14 @@ func (t *FST) writeLabel(out util.DataOutput, v int) error {
 func (t *FST) readLabel(in util.DataInput) (v int, err error) {
 	switch t.inputType {
 	case INPUT_TYPE_BYTE1: // Unsigned byte
-		if b, err := in.ReadByte(); err == nil {
+		var b byte
+		if b, err = in.ReadByte(); err == nil {
 			v = int(b)
 		}
 	case INPUT_TYPE_BYTE2: // Unsigned short
-		if s, err := in.ReadShort(); err == nil {
-			v = int(s)
+		var s int16
+		if s, err = in.ReadShort(); err == nil {
+			v = int(uint16(s))
 		}
 	default:
 		v, err = AsInt(in.ReadVInt())
@@ -577,7 +663,7 @@ func targetHasArcs(arc *Arc) bool {
 
 /* Serializes new node by appending its bytes to the end of the current []byte */
 func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
-	// fmt.Printf("FST.addNode pos=%v numArcs=%v\n", t.bytes.position(), nodeIn.NumArcs)
+	// fmt.Printf("FST.addNode pos=%v numArcs=%v\n", t.bytes.getPosition(), nodeIn.NumArcs)
 	if nodeIn.NumArcs == 0 {
 		if nodeIn.IsFinal {
 			return FST_FINAL_END_NODE, nil
@@ -585,7 +671,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 		return FST_NON_FINAL_END_NODE, nil
 	}
 
-	startAddress := t.bytes.position()
+	startAddress := t.bytes.getPosition()
 	// fmt.Printf("  startAddr=%v\n", startAddress)
 
 	doFixedArray := t.shouldExpand(nodeIn)
@@ -600,7 +686,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 
 	lastArc := nodeIn.NumArcs - 1
 
-	lastArcStart := t.bytes.position()
+	lastArcStart := t.bytes.getPosition()
 	maxBytesPerArc := 0
 	for arcIdx := 0; arcIdx < nodeIn.NumArcs; arcIdx++ {
 		arc := nodeIn.Arcs[arcIdx]
@@ -644,7 +730,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 		}
 
 		// fmt.Printf("  write arc: label=%c flags=%v target=%v pos=%v output=%v\n",
-		// 	rune(arc.label), flags, target.node, t.bytes.position(),
+		// 	rune(arc.label), flags, target.node, t.bytes.getPosition(),
 		// 	t.outputs.outputToString(arc.output))
 
 		if arc.output != NO_OUTPUT {
@@ -673,8 +759,8 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 		// just write the arcs "like normal" on first pass, but record
 		// how many bytes each one took, and max byte size:
 		if doFixedArray {
-			t.bytesPerArc[arcIdx] = int(t.bytes.position() - lastArcStart)
-			lastArcStart = t.bytes.position()
+			t.bytesPerArc[arcIdx] = int(t.bytes.getPosition() - lastArcStart)
+			lastArcStart = t.bytes.getPosition()
 			if t.bytesPerArc[arcIdx] > maxBytesPerArc {
 				maxBytesPerArc = t.bytesPerArc[arcIdx]
 			}
@@ -697,7 +783,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 		fixedArrayStart := startAddress + int64(headerLen)
 
 		// expand the arcs in place, backwards
-		srcPos := t.bytes.position()
+		srcPos := t.bytes.getPosition()
 		destPos := fixedArrayStart + int64(nodeIn.NumArcs)*int64(maxBytesPerArc)
 		assert(destPos >= srcPos)
 		if destPos > srcPos {
@@ -709,7 +795,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 					assert2(destPos > srcPos,
 						"destPos=%v srcPos=%v arcIdx=%v maxBytesPerArc=%v bytesPerArc[arcIdx]=%v nodeIn.numArcs=%v",
 						destPos, srcPos, arcIdx, maxBytesPerArc, t.bytesPerArc[arcIdx], nodeIn.NumArcs)
-					t.bytes.copyBytesInside(srcPos, destPos, t.bytesPerArc[arcIdx])
+					t.bytes.copyBytes(srcPos, destPos, t.bytesPerArc[arcIdx])
 				}
 			}
 		}
@@ -718,7 +804,7 @@ func (t *FST) addNode(nodeIn *UnCompiledNode) (int64, error) {
 		t.bytes.writeBytesAt(startAddress, header[:headerLen])
 	}
 
-	thisNodeAddress := t.bytes.position() - 1
+	thisNodeAddress := t.bytes.getPosition() - 1
 
 	t.bytes.reverse(startAddress, thisNodeAddress)
 
@@ -955,9 +1041,11 @@ func (t *FST) FindTargetArc(labelToMatch int, follow *Arc, arc *Arc, in BytesRea
 
 	// Short-circuit if this arc is in the root arc cache:
 	if follow.target == t.startNode && labelToMatch < len(t.cachedRootArcs) {
-		// LUCENE-5152: detect tricky cases where caller
-		// modified previously returned cached root-arcs:
-		t.assertRootArcs()
+		if debugRootArcs {
+			// LUCENE-5152: detect tricky cases where caller
+			// modified previously returned cached root-arcs:
+			t.assertRootArcs()
+		}
 		if result := t.cachedRootArcs[labelToMatch]; result != nil {
 			arc.copyFrom(result)
 			return arc, nil
@@ -997,7 +1085,7 @@ func (t *FST) FindTargetArc(labelToMatch int, follow *Arc, arc *Arc, in BytesRea
 			}
 		}
 		arc.posArcsStart = in.getPosition()
-		for low, high := 0, arc.numArcs-1; low < high; {
+		for low, high := 0, arc.numArcs-1; low <= high; {
 			// log.Println("    cycle")
 			mid := int(uint(low+high) / 2)
 			in.setPosition(arc.posArcsStart)
@@ -0,0 +1,263 @@
+package fst
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/codec"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"github.com/balzaczyy/golucene/core/util/packed"
+	"math"
+	"sort"
+	"testing"
+)
+
+// Builds an FST mapping each term to its own bytes.
+func buildTestFST(tb testing.TB, terms []string) *FST {
+	outputs := ByteSequenceOutputsSingleton()
+	b := NewBuilder(INPUT_TYPE_BYTE1, 0, 0, true, false, int(math.MaxInt32),
+		outputs, false, packed.PackedInts.COMPACT, true, 15)
+	sorted := append([]string(nil), terms...)
+	sort.Strings(sorted)
+	scratch := util.NewIntsRefBuilder()
+	for _, term := range sorted {
+		if err := b.Add(ToIntsRef([]byte(term), scratch), []byte(term)); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	fst, err := b.Finish()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return fst
+}
+
+// Terms starting with most ASCII letters, plus a few first bytes
+// outside of the root arc cache.
+func testTerms() []string {
+	var terms []string
+	for c := 'a'; c <= 'z'; c++ {
+		for i := 0; i < 20; i++ {
+			terms = append(terms, fmt.Sprintf("%c%v", c, i))
+		}
+	}
+	return append(terms, "été", "über", "\xff\x01")
+}
+
+func TestRootArcCache(t *testing.T) {
+	debugRootArcs = true
+	defer func() { debugRootArcs = false }()
+
+	terms := testTerms()
+	fst := buildTestFST(t, terms)
+	assert(len(fst.cachedRootArcs) == 0x80)
+	assert(fst.cachedRootArcs['a'] != nil && fst.cachedRootArcs['A'] == nil)
+
+	lookups := append(terms, "", "A1", "a", "zz", "é", "\xfe")
+	cached := make([]interface{}, len(lookups))
+	for i, input := range lookups {
+		output, err := GetFSTOutput(fst, []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached[i] = output
+	}
+
+	fst.cachedRootArcs = nil
+	for i, input := range lookups {
+		output, err := GetFSTOutput(fst, []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equals(output, cached[i]) {
+			t.Errorf("%q: cached lookup returned %v, uncached %v", input, cached[i], output)
+		}
+		if i < len(terms) && !equals(output, []byte(input)) {
+			t.Errorf("%q: unexpected output %v", input, output)
+		}
+	}
+}
+
+func buildFST(t *testing.T, outputs Outputs, words []string,
+	output func(i int) interface{}, allowArrayArcs bool) *FST {
+
+	b := NewBuilder(INPUT_TYPE_BYTE1, 0, 0, true, true, int(math.MaxInt32),
+		outputs, false, packed.PackedInts.COMPACT, allowArrayArcs, 15)
+	scratch := util.NewIntsRefBuilder()
+	for i, word := range words {
+		if err := b.Add(ToIntsRef([]byte(word), scratch), output(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fst, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fst
+}
+
+func TestBuilderSetAndMap(t *testing.T) {
+	words := []string{"cat", "cats", "dog", "dogs", "stop", "stopped", "stops", "top", "tops"}
+	unknown := []string{"", "ca", "catss", "do", "sto", "stopp", "tip", "zebra"}
+
+	set := buildFST(t, NoOutputsSingleton(), words, func(int) interface{} { return NO_OUTPUT }, true)
+	ords := buildFST(t, PositiveIntOutputsSingleton(), words, func(i int) interface{} { return int64(i + 1) }, true)
+	for i, word := range words {
+		if output, err := GetFSTOutput(set, []byte(word)); err != nil || output != NO_OUTPUT {
+			t.Errorf("set: %q not found: %v %v", word, output, err)
+		}
+		if output, err := GetFSTOutput(ords, []byte(word)); err != nil || output != int64(i+1) {
+			t.Errorf("map: expected %v for %q, got %v %v", i+1, word, output, err)
+		}
+	}
+	for _, word := range unknown {
+		for _, fst := range []*FST{set, ords} {
+			if output, err := GetFSTOutput(fst, []byte(word)); err != nil || output != nil {
+				t.Errorf("%v: expected %q not found, got %v %v", fst.outputs, word, output, err)
+			}
+		}
+	}
+}
+
+func TestBytesRefFSTEnum(t *testing.T) {
+	words := append(testTerms(), "a1234567890123", "a12345678901234567890")
+	sort.Strings(words)
+	// with array arcs the root (and the 'a' node) use the fixed-array
+	// encoding; without, every node is scanned linearly
+	for _, allowArrayArcs := range []bool{true, false} {
+		fst := buildFST(t, PositiveIntOutputsSingleton(), words,
+			func(i int) interface{} { return int64(i + 1) }, allowArrayArcs)
+
+		e := NewBytesRefFSTEnum(fst)
+		for i, word := range words {
+			io, err := e.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if io == nil {
+				t.Fatalf("arrayArcs=%v: enum ended early at %q", allowArrayArcs, word)
+			}
+			if got := string(io.Input.ToBytes()); got != word || io.Output != int64(i+1) {
+				t.Errorf("arrayArcs=%v: expected %q:%v, got %q:%v",
+					allowArrayArcs, word, i+1, got, io.Output)
+			}
+			if output, found, err := fst.Get([]byte(word)); err != nil || !found || output != int64(i+1) {
+				t.Errorf("arrayArcs=%v: Get(%q) = %v %v %v", allowArrayArcs, word, output, found, err)
+			}
+		}
+		if io, err := e.Next(); err != nil || io != nil {
+			t.Errorf("arrayArcs=%v: expected end of enum, got %v %v", allowArrayArcs, io, err)
+		}
+		if _, found, err := fst.Get([]byte("a12345678901")); err != nil || found {
+			t.Errorf("arrayArcs=%v: unexpected prefix match %v", allowArrayArcs, err)
+		}
+	}
+}
+
+func TestSaveLoadFST(t *testing.T) {
+	words := append([]string{""}, testTerms()...)
+	sort.Strings(words)
+	fst := buildFST(t, PositiveIntOutputsSingleton(), words,
+		func(i int) interface{} { return int64(i + 1) }, true)
+
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	if err := fst.SaveTo(dir, "test.fst"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFSTFrom(dir, "test.fst", PositiveIntOutputsSingleton())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, word := range words {
+		if output, found, err := loaded.Get([]byte(word)); err != nil || !found || output != int64(i+1) {
+			t.Errorf("Get(%q) = %v %v %v, expected %v", word, output, found, err, i+1)
+		}
+	}
+	if _, found, err := loaded.Get([]byte("zz")); err != nil || found {
+		t.Errorf("unexpected match for unknown input: %v", err)
+	}
+
+	// flip a byte in the middle of the file
+	in, err := dir.OpenInput("test.fst", store.IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, in.Length())
+	if err = in.ReadBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	in.Close()
+	data[len(data)/2] ^= 0xFF
+	out, err := dir.CreateOutput("corrupt.fst", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.WriteBytes(data); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	if _, err = LoadFSTFrom(dir, "corrupt.fst", PositiveIntOutputsSingleton()); !errors.Is(err, codec.ErrCorruptIndex) {
+		t.Errorf("expected CorruptIndexError, got %v", err)
+	}
+}
+
+func TestOutputsPushedToRoot(t *testing.T) {
+	words := []string{"ab", "ac", "ad", "b", "ba"}
+	values := []int64{5, 7, 5, 0, 3}
+	fst := buildFST(t, PositiveIntOutputsSingleton(), words,
+		func(i int) interface{} { return values[i] }, true)
+	for i, word := range words {
+		want := interface{}(values[i])
+		if values[i] == 0 {
+			want = NO_OUTPUT
+		}
+		if output, found, err := fst.Get([]byte(word)); err != nil || !found || output != want {
+			t.Errorf("Get(%q) = %v %v %v, expected %v", word, output, found, err, want)
+		}
+	}
+
+	// the common part of the outputs below 'a' sits on the root arc
+	in := fst.BytesReader()
+	arc, err := fst.FindTargetArc('a', fst.FirstArc(&Arc{}), &Arc{}, in)
+	if err != nil || arc == nil {
+		t.Fatal("root arc not found", err)
+	}
+	if arc.Output != int64(5) {
+		t.Errorf("expected output 5 on root arc 'a', got %v", arc.Output)
+	}
+
+	bytesFST := buildFST(t, ByteSequenceOutputsSingleton(), words,
+		func(i int) interface{} { return []byte(words[i][:1] + "x") }, true)
+	arc, err = bytesFST.FindTargetArc('b', bytesFST.FirstArc(&Arc{}), &Arc{}, bytesFST.BytesReader())
+	if err != nil || arc == nil {
+		t.Fatal("root arc not found", err)
+	}
+	if !equals(arc.Output, []byte("bx")) {
+		t.Errorf("expected output bx on root arc 'b', got %v", arc.Output)
+	}
+	for _, word := range words {
+		if output, _, err := bytesFST.Get([]byte(word)); err != nil || !equals(output, []byte(word[:1]+"x")) {
+			t.Errorf("Get(%q) = %v %v", word, output, err)
+		}
+	}
+}
+
+func benchmarkFirstArc(b *testing.B, cached bool) {
+	fst := buildTestFST(b, testTerms())
+	if !cached {
+		fst.cachedRootArcs = nil
+	}
+	in := fst.BytesReader()
+	root := fst.FirstArc(&Arc{})
+	arc := &Arc{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if found, err := fst.FindTargetArc('a'+i%26, root, arc, in); found == nil || err != nil {
+			b.Fatal("root arc not found", err)
+		}
+	}
+}
+
+func BenchmarkFirstArcCached(b *testing.B)   { benchmarkFirstArc(b, true) }
+func BenchmarkFirstArcUncached(b *testing.B) { benchmarkFirstArc(b, false) }
@@ -82,6 +82,25 @@ func (out *abstractOutputs) merge(first, second interface{}) interface{} {
 
 var NO_OUTPUT = newNoOutputs()
 
+/*
+Java compares outputs with equals(); here outputs that mean "no
+output" (0 for PositiveIntOutputs, empty bytes for
+ByteSequenceOutputs) are mapped to the NO_OUTPUT singleton instead.
+*/
+func dedupNoOutput(output interface{}) interface{} {
+	switch v := output.(type) {
+	case int64:
+		if v == 0 {
+			return NO_OUTPUT
+		}
+	case []byte:
+		if len(v) == 0 {
+			return NO_OUTPUT
+		}
+	}
+	return output
+}
+
 /* A nil FST Outputs implementation; use this if you just want to build an FSA. */
 type NoOutputs struct {
 	*abstractOutputs
@@ -94,7 +113,9 @@ func newNoOutputs() *NoOutputs {
 }
 
 func (o *NoOutputs) Common(output1, output2 interface{}) interface{} {
-	panic("niy")
+	assert(output1 == NO_OUTPUT)
+	assert(output2 == NO_OUTPUT)
+	return NO_OUTPUT
 }
 
 func (o *NoOutputs) Subtract(output1, output2 interface{}) interface{} {
@@ -104,7 +125,9 @@ func (o *NoOutputs) Subtract(output1, output2 interface{}) interface{} {
 }
 
 func (o *NoOutputs) Add(prefix, output interface{}) interface{} {
-	panic("not implemented yet")
+	assert(prefix == NO_OUTPUT)
+	assert(output == NO_OUTPUT)
+	return NO_OUTPUT
 }
 
 func (o *NoOutputs) merge(first, second interface{}) interface{} {
@@ -129,6 +152,109 @@ func (o *NoOutputs) outputToString(output interface{}) string {
 	return ""
 }
 
+func (o *NoOutputs) ramBytesUsed(output interface{}) int64 {
+	return 0
+}
+
+func (o *NoOutputs) String() string {
+	return "NoOutputs"
+}
+
+/* Returns the NoOutputs singleton, for building an FSA (a set). */
+func NoOutputsSingleton() *NoOutputs {
+	return NO_OUTPUT
+}
+
+// util/fst/PositiveIntOutputs.java
+
+/*
+An FST Outputs implementation where each output is a non-negative
+int64 value. NO_OUTPUT stands for 0.
+*/
+type PositiveIntOutputs struct {
+	*abstractOutputs
+}
+
+var positiveIntOutputs = newPositiveIntOutputs()
+
+func newPositiveIntOutputs() *PositiveIntOutputs {
+	ans := &PositiveIntOutputs{}
+	ans.abstractOutputs = &abstractOutputs{ans}
+	return ans
+}
+
+func PositiveIntOutputsSingleton() *PositiveIntOutputs {
+	return positiveIntOutputs
+}
+
+func (out *PositiveIntOutputs) Common(output1, output2 interface{}) interface{} {
+	if output1 == NO_OUTPUT || output2 == NO_OUTPUT {
+		return NO_OUTPUT
+	}
+	if n1, n2 := output1.(int64), output2.(int64); n1 < n2 {
+		return n1
+	} else {
+		return n2
+	}
+}
+
+func (out *PositiveIntOutputs) Subtract(output, inc interface{}) interface{} {
+	if inc == NO_OUTPUT {
+		return output
+	}
+	n, d := output.(int64), inc.(int64)
+	assert2(d <= n, "cannot subtract %v from %v", d, n)
+	if n == d {
+		return NO_OUTPUT
+	}
+	return n - d
+}
+
+func (out *PositiveIntOutputs) Add(prefix, output interface{}) interface{} {
+	if prefix == NO_OUTPUT {
+		return output
+	} else if output == NO_OUTPUT {
+		return prefix
+	}
+	return prefix.(int64) + output.(int64)
+}
+
+func (out *PositiveIntOutputs) Write(output interface{}, o util.DataOutput) error {
+	if output == NO_OUTPUT {
+		return o.WriteVLong(0)
+	}
+	n := output.(int64)
+	assert2(n > 0, "output must be positive, got %v", n)
+	return o.WriteVLong(n)
+}
+
+func (out *PositiveIntOutputs) Read(in util.DataInput) (interface{}, error) {
+	n, err := in.ReadVLong()
+	if err != nil || n == 0 {
+		return NO_OUTPUT, err
+	}
+	return n, nil
+}
+
+func (out *PositiveIntOutputs) NoOutput() interface{} {
+	return NO_OUTPUT
+}
+
+func (out *PositiveIntOutputs) outputToString(output interface{}) string {
+	if output == NO_OUTPUT {
+		return "0"
+	}
+	return fmt.Sprintf("%v", output)
+}
+
+func (out *PositiveIntOutputs) ramBytesUsed(output interface{}) int64 {
+	return util.NUM_BYTES_LONG
+}
+
+func (out *PositiveIntOutputs) String() string {
+	return "PositiveIntOutputs"
+}
+
 // fst/ByteSequenceOutputs.java
 
 /**
@@ -283,7 +409,7 @@ func GetFSTOutput(fst *FST, input []byte) (output interface{}, err error) {
 	for _, v := range input {
 		ret, err := fst.FindTargetArc(int(v), arc, arc, fstReader)
 		if ret == nil || err != nil {
-			return ret, err
+			return nil, err
 		}
 		output = fst.outputs.Add(output, arc.Output)
 	}
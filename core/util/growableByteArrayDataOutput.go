@@ -0,0 +1,59 @@
+package util
+
+// util/GrowableByteArrayDataOutput.java
+
+/*
+A DataOutput that can be used to build a []byte. The backing array
+grows by oversizing, as in Oversize(), and is kept across Reset(), so
+it is well suited to buffering many short records.
+*/
+type GrowableByteArrayDataOutput struct {
+	*DataOutputImpl
+	bytes  []byte
+	length int
+}
+
+/* Create a GrowableByteArrayDataOutput with the given initial capacity. */
+func NewGrowableByteArrayDataOutput(cp int) *GrowableByteArrayDataOutput {
+	ans := &GrowableByteArrayDataOutput{bytes: make([]byte, Oversize(cp, 1))}
+	ans.DataOutputImpl = NewDataOutput(ans)
+	return ans
+}
+
+/* Ensures the backing array holds at least minSize bytes. */
+func (out *GrowableByteArrayDataOutput) grow(minSize int) {
+	if minSize > len(out.bytes) {
+		bytes := make([]byte, Oversize(minSize, 1))
+		copy(bytes, out.bytes[:out.length])
+		out.bytes = bytes
+	}
+}
+
+func (out *GrowableByteArrayDataOutput) WriteByte(b byte) error {
+	out.grow(out.length + 1)
+	out.bytes[out.length] = b
+	out.length++
+	return nil
+}
+
+func (out *GrowableByteArrayDataOutput) WriteBytes(b []byte) error {
+	out.grow(out.length + len(b))
+	copy(out.bytes[out.length:], b)
+	out.length += len(b)
+	return nil
+}
+
+/* Returns the bytes written so far; they are only valid until the next write or Reset(). */
+func (out *GrowableByteArrayDataOutput) Bytes() []byte {
+	return out.bytes[:out.length]
+}
+
+/* Returns the number of bytes written so far. */
+func (out *GrowableByteArrayDataOutput) Position() int {
+	return out.length
+}
+
+/* Discards the written bytes, keeping the backing array for reuse. */
+func (out *GrowableByteArrayDataOutput) Reset() {
+	out.length = 0
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGrowableByteArrayDataOutput(t *testing.T) {
+	out := NewGrowableByteArrayDataOutput(10)
+	initialCap := cap(out.bytes)
+
+	var expected []byte
+	for i := 0; i < 100; i++ {
+		if i%3 == 0 {
+			out.WriteByte(byte(i))
+			expected = append(expected, byte(i))
+		} else {
+			b := bytes.Repeat([]byte{byte(i)}, i%7)
+			out.WriteBytes(b)
+			expected = append(expected, b...)
+		}
+	}
+	if cap(out.bytes) <= initialCap {
+		t.Errorf("Expected to grow past %v bytes, but got %v", initialCap, cap(out.bytes))
+	}
+	if out.Position() != len(expected) || !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Expected %v, but got %v", expected, out.Bytes())
+	}
+
+	// the backing array is reused after Reset()
+	backing := &out.bytes[0]
+	out.Reset()
+	if out.Position() != 0 || len(out.Bytes()) != 0 {
+		t.Errorf("Expected no bytes after Reset(), but got %v", out.Bytes())
+	}
+	out.WriteString("reused")
+	if &out.bytes[0] != backing {
+		t.Error("Expected Reset() to keep the backing array")
+	}
+	if !bytes.Equal(out.Bytes(), append([]byte{6}, "reused"...)) {
+		t.Errorf("Expected the string only, but got %v", out.Bytes())
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"errors"
+	"fmt"
 )
 
 // store/DataInput.java
@@ -24,6 +25,7 @@ type DataInput interface {
 	ReadVInt() (n int32, err error)
 	ReadLong() (n int64, err error)
 	ReadVLong() (n int64, err error)
+	ReadZLong() (n int64, err error)
 	ReadString() (s string, err error)
 	ReadStringStringMap() (m map[string]string, err error)
 	ReadStringSet() (m map[string]bool, err error)
@@ -68,8 +70,9 @@ func (in *DataInputImpl) ReadBytesBuffered(buf []byte, useBuffer bool) error {
 }
 
 func (in *DataInputImpl) ReadShort() (n int16, err error) {
-	if b1, err := in.Reader.ReadByte(); err == nil {
-		if b2, err := in.Reader.ReadByte(); err == nil {
+	var b1, b2 byte
+	if b1, err = in.Reader.ReadByte(); err == nil {
+		if b2, err = in.Reader.ReadByte(); err == nil {
 			return (int16(b1) << 8) | int16(b2), nil
 		}
 	}
@@ -77,10 +80,11 @@ func (in *DataInputImpl) ReadShort() (n int16, err error) {
 }
 
 func (in *DataInputImpl) ReadInt() (n int32, err error) {
-	if b1, err := in.Reader.ReadByte(); err == nil {
-		if b2, err := in.Reader.ReadByte(); err == nil {
-			if b3, err := in.Reader.ReadByte(); err == nil {
-				if b4, err := in.Reader.ReadByte(); err == nil {
+	var b1, b2, b3, b4 byte
+	if b1, err = in.Reader.ReadByte(); err == nil {
+		if b2, err = in.Reader.ReadByte(); err == nil {
+			if b3, err = in.Reader.ReadByte(); err == nil {
+				if b4, err = in.Reader.ReadByte(); err == nil {
 					return (int32(b1) << 24) | (int32(b2) << 16) | (int32(b3) << 8) | int32(b4), nil
 				}
 			}
@@ -90,7 +94,8 @@ func (in *DataInputImpl) ReadInt() (n int32, err error) {
 }
 
 func (in *DataInputImpl) ReadVInt() (n int32, err error) {
-	if b, err := in.Reader.ReadByte(); err == nil {
+	var b byte
+	if b, err = in.Reader.ReadByte(); err == nil {
 		n = int32(b) & 0x7F
 		if b < 128 {
 			return n, nil
@@ -142,8 +147,18 @@ func (in *DataInputImpl) ReadVLong() (int64, error) {
 	return in.readVLong(false)
 }
 
+/* Reads a zig-zag encoded variable-length integer. See WriteZLong(). */
+func (in *DataInputImpl) ReadZLong() (int64, error) {
+	n, err := in.readVLong(true)
+	if err != nil {
+		return 0, err
+	}
+	return ZigZagDecodeLong(n), nil
+}
+
 func (in *DataInputImpl) readVLong(allowNegative bool) (n int64, err error) {
-	if b, err := in.Reader.ReadByte(); err == nil {
+	var b byte
+	if b, err = in.Reader.ReadByte(); err == nil {
 		n = int64(b & 0x7F)
 		if b < 128 {
 			return n, nil
@@ -189,7 +204,14 @@ func (in *DataInputImpl) readVLong(allowNegative bool) (n int64, err error) {
 											return n, nil
 										}
 										if allowNegative {
-											panic("niy")
+											// the 10th byte only carries the sign bit
+											if b, err = in.Reader.ReadByte(); err != nil {
+												return 0, err
+											}
+											if b > 1 {
+												return 0, errors.New("Invalid vLong detected (more than 64 bits)")
+											}
+											return n | (int64(b) << 63), nil
 										}
 										return 0, errors.New("Invalid vLong detected (negative values disallowed)")
 									}
@@ -204,13 +226,45 @@ func (in *DataInputImpl) readVLong(allowNegative bool) (n int64, err error) {
 	return 0, err
 }
 
+/* Strings longer than this are read in chunks of this size. */
+const STRING_CHUNK_SIZE = 8192
+
+/*
+Reads a string, as written by DataOutput.WriteString(): the length in
+bytes as a VInt, followed by the UTF-8 encoded bytes.
+
+The length comes from the input and can't be trusted, so a negative
+one is rejected, and a long string is read in chunks: a corrupt length
+then fails at EOF instead of allocating a huge buffer up front.
+*/
 func (in *DataInputImpl) ReadString() (s string, err error) {
 	length, err := in.ReadVInt()
 	if err != nil {
 		return "", err
 	}
-	bytes := make([]byte, length)
-	err = in.Reader.ReadBytes(bytes)
+	if length < 0 {
+		return "", errors.New(fmt.Sprintf("Invalid string length: %v", length))
+	}
+	if length <= STRING_CHUNK_SIZE {
+		bytes := make([]byte, length)
+		if err = in.Reader.ReadBytes(bytes); err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	}
+	chunk := make([]byte, STRING_CHUNK_SIZE)
+	var bytes []byte
+	for left := int(length); left > 0; {
+		n := left
+		if n > STRING_CHUNK_SIZE {
+			n = STRING_CHUNK_SIZE
+		}
+		if err = in.Reader.ReadBytes(chunk[:n]); err != nil {
+			return "", err
+		}
+		bytes = append(bytes, chunk[:n]...)
+		left -= n
+	}
 	return string(bytes), nil
 }
 
@@ -219,6 +273,9 @@ func (in *DataInputImpl) ReadStringStringMap() (m map[string]string, err error)
 	if err != nil {
 		return nil, err
 	}
+	if count < 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid map size: %v", count))
+	}
 	m = make(map[string]string)
 	for i := int32(0); i < count; i++ {
 		key, err := in.ReadString()
@@ -239,6 +296,9 @@ func (in *DataInputImpl) ReadStringSet() (s map[string]bool, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if count < 0 {
+		return nil, errors.New(fmt.Sprintf("Invalid set size: %v", count))
+	}
 	s = make(map[string]bool)
 	for i := int32(0); i < count; i++ {
 		key, err := in.ReadString()
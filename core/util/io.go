@@ -4,6 +4,9 @@ import (
 	_ "errors"
 	_ "fmt"
 	"io"
+	"os"
+	"runtime"
+	"time"
 )
 
 type CompoundError struct {
@@ -108,8 +111,35 @@ func DeleteFilesIgnoringErrors(dir FileDeleter, files ...string) {
 /*
 Ensure that any writes to the given file is written to the storage
 device that contains it.
+
+isDir tells whether fileToSync is a directory. Directories can't be
+fsync'ed on all platforms (e.g. Windows), so errors are only reported
+for them on Linux and Mac OS X.
 */
-func Fsync(fileToSync string, isDir bool) error {
-	// TODO enable fsync, now just ignored
-	return nil
+func Fsync(fileToSync string, isDir bool) (err error) {
+	// Windows needs write access to flush a file
+	flag := os.O_RDWR
+	if isDir {
+		flag = os.O_RDONLY
+	}
+	var file *os.File
+	if file, err = os.OpenFile(fileToSync, flag, 0); err == nil {
+		defer file.Close()
+		for retry := 0; retry < 5; retry++ {
+			var err2 error
+			if err2 = file.Sync(); err2 == nil {
+				return nil
+			}
+			if err == nil {
+				err = err2
+			}
+			// Pause 5 msec
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if isDir && runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		// dir fsync isn't supported on this platform
+		return nil
+	}
+	return err
 }
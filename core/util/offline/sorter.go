@@ -0,0 +1,304 @@
+package offline
+
+import (
+	"container/heap"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"sort"
+	"sync/atomic"
+)
+
+// util/OfflineSorter.java
+
+const (
+	// Default RAM buffer used to sort a single run: 16 MB.
+	DEFAULT_RAM_BUFFER_SIZE = 16 << 20
+	// Maximum number of temporary files before doing an intermediate merge.
+	DEFAULT_MAX_TEMP_FILES = 128
+)
+
+// Rough per-record overhead of a buffered BytesRef.
+const bytesRefOverhead = util.NUM_BYTES_OBJECT_REF*2 + util.NUM_BYTES_ARRAY_HEADER
+
+/*
+On-disk sorting of byte sequences, for inputs that don't fit in RAM.
+Records are read from an input file, sorted in runs of at most
+ramBufferSize bytes, written to temporary files in dir, and merged
+back with a k-way merge into a single sorted temporary file.
+
+Input and output files use the format written by ByteSequencesWriter:
+each record is a VInt length followed by its bytes.
+*/
+type OfflineSorter struct {
+	dir           store.Directory
+	tempPrefix    string
+	comparator    util.BytesRefComparator
+	ramBufferSize int64
+	maxTempFiles  int
+	tempCounter   int64
+}
+
+/* Creates a sorter with the default RAM buffer size and merge factor. */
+func NewOfflineSorter(dir store.Directory, tempPrefix string,
+	comparator util.BytesRefComparator) *OfflineSorter {
+
+	return NewOfflineSorterWith(dir, tempPrefix, comparator,
+		DEFAULT_RAM_BUFFER_SIZE, DEFAULT_MAX_TEMP_FILES)
+}
+
+/*
+Expert: creates a sorter which sorts runs of up to ramBufferSize
+bytes, and merges whenever maxTempFiles runs have accumulated.
+*/
+func NewOfflineSorterWith(dir store.Directory, tempPrefix string,
+	comparator util.BytesRefComparator, ramBufferSize int64, maxTempFiles int) *OfflineSorter {
+
+	assert2(ramBufferSize > 0, "ramBufferSize must be positive, got %v", ramBufferSize)
+	assert2(maxTempFiles >= 2, "maxTempFiles must be >= 2, got %v", maxTempFiles)
+	return &OfflineSorter{
+		dir:           dir,
+		tempPrefix:    tempPrefix,
+		comparator:    comparator,
+		ramBufferSize: ramBufferSize,
+		maxTempFiles:  maxTempFiles,
+	}
+}
+
+func assert2(ok bool, msg string, args ...interface{}) {
+	if !ok {
+		panic(fmt.Sprintf(msg, args...))
+	}
+}
+
+/*
+Sorts the records of input into a new temporary file and returns its
+name; input is left untouched. All intermediate files are removed,
+also when an error is returned.
+*/
+func (s *OfflineSorter) Sort(input string) (output string, err error) {
+	var segments []string
+	defer func() {
+		if err != nil {
+			util.DeleteFilesIgnoringErrors(s.dir, segments...)
+		}
+	}()
+
+	in, err := s.dir.OpenInput(input, store.IO_CONTEXT_READONCE)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	reader := NewByteSequencesReader(in)
+
+	for {
+		var refs []*util.BytesRef
+		if refs, err = s.readPartition(reader); err != nil {
+			return "", err
+		}
+		if len(refs) == 0 {
+			break
+		}
+		var segment string
+		if segment, err = s.sortPartition(refs); err != nil {
+			return "", err
+		}
+		segments = append(segments, segment)
+
+		// merge if we hit the limit of temp files
+		if len(segments) >= s.maxTempFiles {
+			if segment, err = s.mergePartitions(segments); err != nil {
+				return "", err
+			}
+			segments = []string{segment}
+		}
+	}
+
+	switch len(segments) {
+	case 0: // empty input
+		if output, err = s.writeRecords(nil); err != nil {
+			return "", err
+		}
+		return output, nil
+	case 1:
+		return segments[0], nil
+	default:
+		if output, err = s.mergePartitions(segments); err != nil {
+			return "", err
+		}
+		return output, nil
+	}
+}
+
+// Reads records until the RAM buffer is full or the input is exhausted.
+func (s *OfflineSorter) readPartition(reader *ByteSequencesReader) (refs []*util.BytesRef, err error) {
+	used := int64(0)
+	for used < s.ramBufferSize {
+		var record []byte
+		if record, err = reader.Next(); err != nil || record == nil {
+			return refs, err
+		}
+		refs = append(refs, util.NewBytesRefFrom(record))
+		used += int64(len(record)) + bytesRefOverhead
+	}
+	return refs, nil
+}
+
+func (s *OfflineSorter) sortPartition(refs []*util.BytesRef) (string, error) {
+	sort.Sort(util.BytesRefSorter{Refs: refs, Comparator: s.comparator})
+	return s.writeRecords(refs)
+}
+
+func (s *OfflineSorter) writeRecords(refs []*util.BytesRef) (name string, err error) {
+	var out store.IndexOutput
+	if name, out, err = s.createTempOutput(); err != nil {
+		return "", err
+	}
+	w := NewByteSequencesWriter(out)
+	for _, ref := range refs {
+		if err = w.Write(ref.ToBytes()); err != nil {
+			break
+		}
+	}
+	if err = util.CloseWhileHandlingError(err, w); err != nil {
+		util.DeleteFilesIgnoringErrors(s.dir, name)
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *OfflineSorter) createTempOutput() (string, store.IndexOutput, error) {
+	for {
+		name := fmt.Sprintf("%v_%v.tmp", s.tempPrefix, atomic.AddInt64(&s.tempCounter, 1))
+		if !s.dir.FileExists(name) {
+			out, err := s.dir.CreateOutput(name, store.IO_CONTEXT_DEFAULT)
+			return name, out, err
+		}
+	}
+}
+
+/* Merges the sorted segments into a new file, deleting the segments on success. */
+func (s *OfflineSorter) mergePartitions(segments []string) (name string, err error) {
+	var out store.IndexOutput
+	if name, out, err = s.createTempOutput(); err != nil {
+		return "", err
+	}
+	w := NewByteSequencesWriter(out)
+	var inputs []store.IndexInput
+	defer func() {
+		for _, in := range inputs {
+			util.CloseWhileSuppressingError(in)
+		}
+		if err = util.CloseWhileHandlingError(err, w); err != nil {
+			util.DeleteFilesIgnoringErrors(s.dir, name)
+			name = ""
+		} else {
+			util.DeleteFilesIgnoringErrors(s.dir, segments...)
+		}
+	}()
+
+	queue := &recordQueue{comparator: s.comparator}
+	for _, segment := range segments {
+		var in store.IndexInput
+		if in, err = s.dir.OpenInput(segment, store.IO_CONTEXT_READONCE); err != nil {
+			return
+		}
+		inputs = append(inputs, in)
+		r := NewByteSequencesReader(in)
+		var record []byte
+		if record, err = r.Next(); err != nil {
+			return
+		}
+		if record != nil {
+			queue.items = append(queue.items, &mergeItem{util.NewBytesRefFrom(record), r})
+		}
+	}
+	heap.Init(queue)
+
+	for queue.Len() > 0 {
+		top := queue.items[0]
+		if err = w.Write(top.ref.ToBytes()); err != nil {
+			return
+		}
+		var record []byte
+		if record, err = top.reader.Next(); err != nil {
+			return
+		}
+		if record == nil {
+			heap.Pop(queue)
+		} else {
+			top.ref = util.NewBytesRefFrom(record)
+			heap.Fix(queue, 0)
+		}
+	}
+	return
+}
+
+type mergeItem struct {
+	ref    *util.BytesRef
+	reader *ByteSequencesReader
+}
+
+// Min-heap of the current record of each segment being merged.
+type recordQueue struct {
+	items      []*mergeItem
+	comparator util.BytesRefComparator
+}
+
+func (q *recordQueue) Len() int { return len(q.items) }
+func (q *recordQueue) Less(i, j int) bool {
+	return q.comparator(q.items[i].ref, q.items[j].ref) < 0
+}
+func (q *recordQueue) Swap(i, j int)      { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *recordQueue) Push(x interface{}) { q.items = append(q.items, x.(*mergeItem)) }
+func (q *recordQueue) Pop() interface{} {
+	n := len(q.items)
+	ans := q.items[n-1]
+	q.items = q.items[:n-1]
+	return ans
+}
+
+/* Writes records as a VInt length followed by the bytes. */
+type ByteSequencesWriter struct {
+	out store.IndexOutput
+}
+
+func NewByteSequencesWriter(out store.IndexOutput) *ByteSequencesWriter {
+	return &ByteSequencesWriter{out}
+}
+
+func (w *ByteSequencesWriter) Write(record []byte) error {
+	if err := w.out.WriteVInt(int32(len(record))); err != nil {
+		return err
+	}
+	return w.out.WriteBytes(record)
+}
+
+func (w *ByteSequencesWriter) Close() error {
+	return w.out.Close()
+}
+
+/* Reads records written by ByteSequencesWriter. It doesn't own in. */
+type ByteSequencesReader struct {
+	in store.IndexInput
+}
+
+func NewByteSequencesReader(in store.IndexInput) *ByteSequencesReader {
+	return &ByteSequencesReader{in}
+}
+
+/* Returns the next record, or nil at the end of the input. */
+func (r *ByteSequencesReader) Next() ([]byte, error) {
+	if r.in.FilePointer() >= r.in.Length() {
+		return nil, nil
+	}
+	n, err := r.in.ReadVInt()
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, n)
+	if err = r.in.ReadBytes(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,120 @@
+package offline
+
+import (
+	"bytes"
+	"github.com/balzaczyy/golucene/core/store"
+	"github.com/balzaczyy/golucene/core/util"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func writeRecords(t *testing.T, dir store.Directory, name string, records [][]byte) {
+	out, err := dir.CreateOutput(name, store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewByteSequencesWriter(out)
+	for _, record := range records {
+		if err = w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readRecords(t *testing.T, dir store.Directory, name string) (records [][]byte) {
+	in, err := dir.OpenInput(name, store.IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	r := NewByteSequencesReader(in)
+	for {
+		record, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record == nil {
+			return
+		}
+		records = append(records, record)
+	}
+}
+
+func checkSort(t *testing.T, records [][]byte, comparator util.BytesRefComparator,
+	ramBufferSize int64, maxTempFiles int) {
+
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	writeRecords(t, dir, "input", records)
+
+	sorter := NewOfflineSorterWith(dir, "sort", comparator, ramBufferSize, maxTempFiles)
+	output, err := sorter.Sort("input")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := make([]*util.BytesRef, len(records))
+	for i, record := range records {
+		expected[i] = util.NewBytesRefFrom(record)
+	}
+	sort.Sort(util.BytesRefSorter{Refs: expected, Comparator: comparator})
+	sorted := readRecords(t, dir, output)
+	if len(sorted) != len(expected) {
+		t.Fatalf("expected %v records, got %v", len(expected), len(sorted))
+	}
+	for i, record := range sorted {
+		if !bytes.Equal(record, expected[i].ToBytes()) {
+			t.Fatalf("record %v: expected %v, got %v", i, expected[i].ToBytes(), record)
+		}
+		if i > 0 && comparator(util.NewBytesRefFrom(sorted[i-1]), util.NewBytesRefFrom(record)) > 0 {
+			t.Fatalf("record %v out of order: %v > %v", i, sorted[i-1], record)
+		}
+	}
+
+	// only the input and the sorted output are left behind
+	files, err := dir.ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if len(files) != 2 || files[0] != "input" || files[1] != output {
+		t.Errorf("unexpected files left: %v", files)
+	}
+}
+
+func randomRecords(r *rand.Rand, n int) [][]byte {
+	records := make([][]byte, n)
+	for i := range records {
+		records[i] = make([]byte, r.Intn(20))
+		r.Read(records[i])
+	}
+	// a few prefixes and duplicates
+	return append(records, []byte{}, []byte{}, []byte("ab"), []byte("abc"), []byte("ab"))
+}
+
+func TestOfflineSorterSmallBuffer(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	records := randomRecords(r, 2000)
+	// a tiny buffer and merge factor force many runs and intermediate merges
+	checkSort(t, records, util.UTF8SortedAsUnicodeComparator, 512, 3)
+}
+
+func TestOfflineSorterSingleRun(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	checkSort(t, randomRecords(r, 100), util.UTF8SortedAsUnicodeComparator,
+		DEFAULT_RAM_BUFFER_SIZE, DEFAULT_MAX_TEMP_FILES)
+}
+
+func TestOfflineSorterEmpty(t *testing.T) {
+	checkSort(t, nil, util.UTF8SortedAsUnicodeComparator, 512, 3)
+}
+
+func TestOfflineSorterComparator(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	reverse := func(a, b *util.BytesRef) int { return b.CompareTo(a) }
+	checkSort(t, randomRecords(r, 500), reverse, 256, 4)
+}
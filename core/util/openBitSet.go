@@ -107,7 +107,7 @@ func bits2words(numBits int64) int {
 }
 
 /* Expert: returns the []int64 storing the bits */
-// func (b *OpenBitSet) RealBits() []int64 { return b.bits }
+func (b *OpenBitSet) RealBits() []int64 { return b.bits }
 
 // L724
 
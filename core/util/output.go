@@ -13,10 +13,13 @@ safe (it keeps internal state like file position).
 */
 type DataOutput interface {
 	DataWriter
+	WriteBytesRange(buf []byte, offset, length int) error
+	WriteShort(i int16) error
 	WriteInt(i int32) error
 	WriteVInt(i int32) error
 	WriteLong(i int64) error
 	WriteVLong(i int64) error
+	WriteZLong(i int64) error
 	WriteString(s string) error
 	CopyBytes(input DataInput, numBytes int64) error
 	WriteStringStringMap(m map[string]string) error
@@ -38,6 +41,17 @@ func NewDataOutput(part DataWriter) *DataOutputImpl {
 	return &DataOutputImpl{Writer: part}
 }
 
+/*
+Writes a short as two bytes, high-order byte first.
+*/
+func (out *DataOutputImpl) WriteShort(i int16) error {
+	err := out.Writer.WriteByte(byte(i >> 8))
+	if err == nil {
+		err = out.Writer.WriteByte(byte(i))
+	}
+	return err
+}
+
 /*
 Writes an int as four bytes.
 
@@ -100,6 +114,15 @@ func (out *DataOutputImpl) WriteVInt(i int32) error {
 	return out.Writer.WriteByte(byte(i))
 }
 
+/*
+Writes length bytes of buf, starting at offset. Writers which can
+copy from the range directly should override this; by default it
+writes the sub-slice.
+*/
+func (out *DataOutputImpl) WriteBytesRange(buf []byte, offset, length int) error {
+	return out.Writer.WriteBytes(buf[offset : offset+length])
+}
+
 /*
 Writes a long as eight bytes.
 
@@ -125,6 +148,15 @@ func (out *DataOutputImpl) WriteVLong(i int64) error {
 	return out.writeNegativeVLong(i)
 }
 
+/*
+Writes a long in a variable-length format. Writes between one and ten
+bytes. Small values or values representable as small negative values
+take fewer bytes, thanks to zig-zag encoding.
+*/
+func (out *DataOutputImpl) WriteZLong(i int64) error {
+	return out.writeNegativeVLong(ZigZagEncodeLong(i))
+}
+
 /* write a potentially negative gLong */
 func (out *DataOutputImpl) writeNegativeVLong(i int64) error {
 	for (i & ^0x7F) != 0 {
@@ -184,7 +216,9 @@ func (out *DataOutputImpl) CopyBytes(input DataInput, numBytes int64) error {
 Writes a string map.
 
 First the size is written as an int32, followed by each key-value
-pair written as two consecutive strings.
+pair written as two consecutive strings, in key order so that equal
+maps are always written as the same bytes. The int32 size keeps the
+format readable by Lucene 4.x.
 */
 func (out *DataOutputImpl) WriteStringStringMap(m map[string]string) error {
 	if m == nil {
@@ -218,7 +252,7 @@ func (out *DataOutputImpl) WriteStringStringMap(m map[string]string) error {
 Writes a String set.
 
 First the size is written as an int32, followed by each value written
-as a string.
+as a string, in sorted order.
 */
 func (out *DataOutputImpl) WriteStringSet(m map[string]bool) error {
 	if m == nil {
@@ -228,8 +262,14 @@ func (out *DataOutputImpl) WriteStringSet(m map[string]bool) error {
 	if err != nil {
 		return err
 	}
-	for value, _ := range m {
-		err = out.WriteString(value)
+	// enforce value order during serialization
+	var values []string
+	for v, _ := range m {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		err = out.WriteString(v)
 		if err != nil {
 			return err
 		}
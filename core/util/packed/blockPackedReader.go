@@ -0,0 +1,112 @@
+package packed
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/util"
+)
+
+// packed/BlockPackedReaderIterator.java
+
+/* Reads a VLong written by writeVLong, which may be negative. */
+func readVLong(in DataInput) (int64, error) {
+	var i int64
+	for shift := uint(0); shift < 56; shift += 7 {
+		b, err := in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		i |= int64(b&0x7F) << shift
+		if b&0x80 == 0 {
+			return i, nil
+		}
+	}
+	b, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	return i | int64(b)<<56, nil
+}
+
+// packed/BlockPackedReader.java
+
+/* Provides random access to a stream written with BlockPackedWriter. */
+type BlockPackedReader struct {
+	blockShift uint
+	blockMask  int64
+	valueCount int64
+	minValues  []int64 // nil when all blocks have a min of 0
+	subReaders []PackedIntsReader
+}
+
+/* Loads the blocks of a stream of valueCount values from in into memory. */
+func NewBlockPackedReader(in DataInput, packedIntsVersion int32,
+	blockSize int, valueCount int64) (*BlockPackedReader, error) {
+
+	n := numBlocks(valueCount, blockSize)
+	ans := &BlockPackedReader{
+		blockShift: uint(checkBlockSize(blockSize, BLOCK_PACKED_MIN_BLOCK_SIZE, BLOCK_PACKED_MAX_BLOCK_SIZE)),
+		blockMask:  int64(blockSize - 1),
+		valueCount: valueCount,
+		subReaders: make([]PackedIntsReader, n),
+	}
+	for i := 0; i < n; i++ {
+		token, err := in.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		bitsPerValue := int(token) >> BLOCK_PACKED_BPV_SHIFT
+		if bitsPerValue > 64 {
+			return nil, errors.New(fmt.Sprintf("Corrupted: bitsPerValue=%v", bitsPerValue))
+		}
+		if token&BLOCK_PACKED_MIN_VALUE_EQUALS_0 == 0 {
+			if ans.minValues == nil {
+				ans.minValues = make([]int64, n)
+			}
+			var v int64
+			if v, err = readVLong(in); err != nil {
+				return nil, err
+			}
+			ans.minValues[i] = util.ZigZagDecodeLong(1 + v)
+		}
+		if bitsPerValue == 0 {
+			ans.subReaders[i] = newNilReader(blockSize)
+			continue
+		}
+		size := valueCount - int64(i)*int64(blockSize)
+		if size > int64(blockSize) {
+			size = int64(blockSize)
+		}
+		if ans.subReaders[i], err = ReaderNoHeader(in, PackedFormat(PACKED),
+			packedIntsVersion, int32(size), uint32(bitsPerValue)); err != nil {
+			return nil, err
+		}
+	}
+	return ans, nil
+}
+
+func (r *BlockPackedReader) Get(index int64) int64 {
+	assert(index >= 0 && index < r.valueCount)
+	block := int(index >> r.blockShift)
+	idx := int(index & r.blockMask)
+	if r.minValues == nil {
+		return r.subReaders[block].Get(idx)
+	}
+	return r.minValues[block] + r.subReaders[block].Get(idx)
+}
+
+/* Returns the number of values. */
+func (r *BlockPackedReader) Size() int64 {
+	return r.valueCount
+}
+
+func (r *BlockPackedReader) RamBytesUsed() int64 {
+	var sizeInBytes int64
+	if r.minValues != nil {
+		sizeInBytes = util.SizeOf(r.minValues)
+	}
+	for _, reader := range r.subReaders {
+		sizeInBytes += reader.RamBytesUsed()
+	}
+	return sizeInBytes
+}
@@ -0,0 +1,206 @@
+package packed
+
+import (
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+)
+
+// packed/AbstractBlockPackedWriter.java
+
+const (
+	BLOCK_PACKED_MIN_BLOCK_SIZE     = 64
+	BLOCK_PACKED_MAX_BLOCK_SIZE     = 1 << (30 - 3)
+	BLOCK_PACKED_MIN_VALUE_EQUALS_0 = 1 << 0
+	BLOCK_PACKED_BPV_SHIFT          = 1
+)
+
+/*
+Same as DataOutput.WriteVLong but accepts negative values: at most 9
+bytes are written, the last one holding the 8 remaining bits.
+*/
+func writeVLong(out util.DataOutput, i int64) error {
+	for k := 0; (i & ^0x7F) != 0 && k < 8; k++ {
+		if err := out.WriteByte(byte((i & 0x7F) | 0x80)); err != nil {
+			return err
+		}
+		i = int64(uint64(i) >> 7)
+	}
+	return out.WriteByte(byte(i))
+}
+
+type abstractBlockPackedWriterSPI interface {
+	flush() error
+}
+
+/*
+Base for writers which buffer values in blocks of blockSize and
+flush each full block through the spi.
+*/
+type abstractBlockPackedWriter struct {
+	spi      abstractBlockPackedWriterSPI
+	out      util.DataOutput
+	values   []int64
+	blocks   []byte
+	off      int
+	ord      int64
+	finished bool
+}
+
+func newAbstractBlockPackedWriter(spi abstractBlockPackedWriterSPI,
+	out util.DataOutput, blockSize int) *abstractBlockPackedWriter {
+
+	checkBlockSize(blockSize, BLOCK_PACKED_MIN_BLOCK_SIZE, BLOCK_PACKED_MAX_BLOCK_SIZE)
+	return &abstractBlockPackedWriter{
+		spi:    spi,
+		out:    out,
+		values: make([]int64, blockSize),
+	}
+}
+
+/* Reset this writer to wrap out. The block size remains unchanged. */
+func (w *abstractBlockPackedWriter) Reset(out util.DataOutput) {
+	assert(out != nil)
+	w.out = out
+	w.off = 0
+	w.ord = 0
+	w.finished = false
+}
+
+func (w *abstractBlockPackedWriter) checkNotFinished() {
+	assert2(!w.finished, "Already finished")
+}
+
+/* Append a new long. */
+func (w *abstractBlockPackedWriter) Add(l int64) error {
+	w.checkNotFinished()
+	if w.off == len(w.values) {
+		if err := w.spi.flush(); err != nil {
+			return err
+		}
+	}
+	w.values[w.off] = l
+	w.off++
+	w.ord++
+	return nil
+}
+
+/*
+Flush all buffered data to disk. This instance is not usable anymore
+after this method has been called until Reset() has been called.
+*/
+func (w *abstractBlockPackedWriter) Finish() error {
+	w.checkNotFinished()
+	if w.off > 0 {
+		if err := w.spi.flush(); err != nil {
+			return err
+		}
+	}
+	w.finished = true
+	return nil
+}
+
+/* Return the number of values which have been added. */
+func (w *abstractBlockPackedWriter) Ord() int64 {
+	return w.ord
+}
+
+func (w *abstractBlockPackedWriter) writeValues(bitsRequired int) error {
+	encoder := GetPackedIntsEncoder(PackedFormat(PACKED), VERSION_CURRENT, uint32(bitsRequired))
+	iterations := len(w.values) / encoder.ByteValueCount()
+	blockSize := encoder.ByteBlockCount() * iterations
+	if len(w.blocks) < blockSize {
+		w.blocks = make([]byte, blockSize)
+	}
+	for i := w.off; i < len(w.values); i++ {
+		w.values[i] = 0
+	}
+	encoder.encodeLongToByte(w.values, w.blocks, iterations)
+	blockCount := PackedFormat(PACKED).ByteCount(VERSION_CURRENT, int32(w.off), uint32(bitsRequired))
+	return w.out.WriteBytes(w.blocks[:blockCount])
+}
+
+// packed/BlockPackedWriter.java
+
+/*
+A writer for large sequences of int64 values which is able to encode
+negative values too.
+
+The sequence is divided into fixed-size blocks and each block is
+encoded as the delta from its minimum value, using as few bits as
+possible.
+
+Format:
+  - BlockCount blocks, where BlockCount = ceil(ValueCount / BlockSize)
+  - each block is Token, then Min?, then PackedDeltas?
+  - Token: a byte holding BitsPerValue << 1, with the lowest bit set
+    when Min is 0 and therefore omitted
+  - Min: zig-zag encoded minimum value of the block, minus one,
+    written with at most 9 bytes
+  - PackedDeltas: deltas from Min, packed with BitsPerValue bits each;
+    absent when BitsPerValue is 0
+*/
+type BlockPackedWriter struct {
+	*abstractBlockPackedWriter
+}
+
+func NewBlockPackedWriter(out util.DataOutput, blockSize int) *BlockPackedWriter {
+	ans := new(BlockPackedWriter)
+	ans.abstractBlockPackedWriter = newAbstractBlockPackedWriter(ans, out, blockSize)
+	return ans
+}
+
+func (w *BlockPackedWriter) flush() error {
+	assert(w.off > 0)
+	min, max := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, v := range w.values[:w.off] {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	delta := max - min
+	bitsRequired := 0
+	if delta != 0 {
+		bitsRequired = UnsignedBitsRequired(delta)
+	}
+	if bitsRequired == 64 {
+		// no need to delta-encode
+		min = 0
+	} else if min > 0 {
+		// make min as small as possible so that writeVLong requires fewer bytes
+		if min = max - MaxValue(bitsRequired); min < 0 {
+			min = 0
+		}
+	}
+
+	token := bitsRequired << BLOCK_PACKED_BPV_SHIFT
+	if min == 0 {
+		token |= BLOCK_PACKED_MIN_VALUE_EQUALS_0
+	}
+	if err := w.out.WriteByte(byte(token)); err != nil {
+		return err
+	}
+
+	if min != 0 {
+		if err := writeVLong(w.out, util.ZigZagEncodeLong(min)-1); err != nil {
+			return err
+		}
+	}
+
+	if bitsRequired > 0 {
+		if min != 0 {
+			for i := 0; i < w.off; i++ {
+				w.values[i] -= min
+			}
+		}
+		if err := w.writeValues(bitsRequired); err != nil {
+			return err
+		}
+	}
+
+	w.off = 0
+	return nil
+}
@@ -0,0 +1,198 @@
+package packed
+
+import (
+	"errors"
+	"fmt"
+	"github.com/balzaczyy/golucene/core/util"
+	"math"
+)
+
+// packed/MonotonicBlockPackedWriter.java
+
+/*
+A writer for large monotonically increasing sequences of positive
+int64 values.
+
+The sequence is divided into fixed-size blocks and for each block,
+the average value per ord is computed, followed by the delta from
+the expected value for every ord, using as few bits as possible.
+Each block has an overhead between 6 and 14 bytes.
+
+Format:
+  - BlockCount blocks, where BlockCount = ceil(ValueCount / BlockSize)
+  - each block is Header, then PackedDeltas
+  - Header: ZLong Min, Int Float32bits(Average), VInt BitsPerValue
+  - PackedDeltas: non-negative deltas from Min + Average * index,
+    packed with BitsPerValue bits each; absent when BitsPerValue is 0
+
+Min is lowered below the first value when needed so that every delta
+is non-negative. Streams written before VERSION_MONOTONIC_WITHOUT_ZIGZAG
+store Min as a VLong and zig-zag encode the deltas instead.
+*/
+type MonotonicBlockPackedWriter struct {
+	*abstractBlockPackedWriter
+}
+
+func NewMonotonicBlockPackedWriter(out util.DataOutput, blockSize int) *MonotonicBlockPackedWriter {
+	ans := new(MonotonicBlockPackedWriter)
+	ans.abstractBlockPackedWriter = newAbstractBlockPackedWriter(ans, out, blockSize)
+	return ans
+}
+
+func (w *MonotonicBlockPackedWriter) Add(l int64) error {
+	assert2(l >= 0, "values must be positive, got %v", l)
+	return w.abstractBlockPackedWriter.Add(l)
+}
+
+func (w *MonotonicBlockPackedWriter) flush() error {
+	assert(w.off > 0)
+
+	// TODO: perform a true linear regression?
+	min := w.values[0]
+	var avg float32
+	if w.off > 1 {
+		avg = float32(w.values[w.off-1]-min) / float32(w.off-1)
+	}
+
+	// adjust min so that all deltas will be positive
+	for i := 1; i < w.off; i++ {
+		if expected := expectedValue(min, avg, i); expected > w.values[i] {
+			min -= expected - w.values[i]
+		}
+	}
+
+	var maxDelta int64
+	for i := 0; i < w.off; i++ {
+		w.values[i] -= expectedValue(min, avg, i)
+		if w.values[i] > maxDelta {
+			maxDelta = w.values[i]
+		}
+	}
+
+	err := w.out.WriteZLong(min)
+	if err == nil {
+		err = w.out.WriteInt(int32(math.Float32bits(avg)))
+	}
+	if err != nil {
+		return err
+	}
+	if maxDelta == 0 {
+		err = w.out.WriteVInt(0)
+	} else {
+		bitsRequired := BitsRequired(maxDelta)
+		if err = w.out.WriteVInt(int32(bitsRequired)); err == nil {
+			err = w.writeValues(bitsRequired)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.off = 0
+	return nil
+}
+
+/* Returns the value the block's linear model predicts at index. */
+func expectedValue(origin int64, average float32, index int) int64 {
+	return origin + int64(average*float32(index))
+}
+
+// packed/MonotonicBlockPackedReader.java
+
+/*
+Provides random access to a stream written with
+MonotonicBlockPackedWriter.
+
+Only the per-block headers are decoded up front. The value at index
+lives in block index>>blockShift at idx = index&blockMask, and is
+rebuilt as Min + int64(Average*idx) + delta[idx], with the same
+float32 rounding as the writer, so the result is exact. Deltas of
+streams older than VERSION_MONOTONIC_WITHOUT_ZIGZAG are zig-zag
+decoded first.
+*/
+type MonotonicBlockPackedReader struct {
+	zigZag     bool
+	blockShift uint
+	blockMask  int64
+	valueCount int64
+	minValues  []int64
+	averages   []float32
+	subReaders []PackedIntsReader
+}
+
+/* Loads the blocks of a stream of valueCount values from in into memory. */
+func NewMonotonicBlockPackedReader(in util.DataInput, packedIntsVersion int32,
+	blockSize int, valueCount int64) (*MonotonicBlockPackedReader, error) {
+
+	n := numBlocks(valueCount, blockSize)
+	ans := &MonotonicBlockPackedReader{
+		zigZag:     packedIntsVersion < VERSION_MONOTONIC_WITHOUT_ZIGZAG,
+		blockShift: uint(checkBlockSize(blockSize, BLOCK_PACKED_MIN_BLOCK_SIZE, BLOCK_PACKED_MAX_BLOCK_SIZE)),
+		blockMask:  int64(blockSize - 1),
+		valueCount: valueCount,
+		minValues:  make([]int64, n),
+		averages:   make([]float32, n),
+		subReaders: make([]PackedIntsReader, n),
+	}
+	for i := 0; i < n; i++ {
+		var err error
+		if ans.zigZag {
+			ans.minValues[i], err = in.ReadVLong()
+		} else {
+			ans.minValues[i], err = in.ReadZLong()
+		}
+		if err != nil {
+			return nil, err
+		}
+		var avgBits int32
+		if avgBits, err = in.ReadInt(); err != nil {
+			return nil, err
+		}
+		ans.averages[i] = math.Float32frombits(uint32(avgBits))
+		var bitsPerValue int32
+		if bitsPerValue, err = in.ReadVInt(); err != nil {
+			return nil, err
+		}
+		if bitsPerValue < 0 || bitsPerValue > 64 {
+			return nil, errors.New(fmt.Sprintf("Corrupted: bitsPerValue=%v", bitsPerValue))
+		}
+		if bitsPerValue == 0 {
+			ans.subReaders[i] = newNilReader(blockSize)
+			continue
+		}
+		size := valueCount - int64(i)*int64(blockSize)
+		if size > int64(blockSize) {
+			size = int64(blockSize)
+		}
+		if ans.subReaders[i], err = ReaderNoHeader(in, PackedFormat(PACKED),
+			packedIntsVersion, int32(size), uint32(bitsPerValue)); err != nil {
+			return nil, err
+		}
+	}
+	return ans, nil
+}
+
+func (r *MonotonicBlockPackedReader) Get(index int64) int64 {
+	assert(index >= 0 && index < r.valueCount)
+	block := int(index >> r.blockShift)
+	idx := int(index & r.blockMask)
+	delta := r.subReaders[block].Get(idx)
+	if r.zigZag {
+		delta = util.ZigZagDecodeLong(delta)
+	}
+	return expectedValue(r.minValues[block], r.averages[block], idx) + delta
+}
+
+/* Returns the number of values. */
+func (r *MonotonicBlockPackedReader) Size() int64 {
+	return r.valueCount
+}
+
+func (r *MonotonicBlockPackedReader) RamBytesUsed() int64 {
+	sizeInBytes := util.SizeOf(r.minValues) + util.AlignObjectSize(
+		util.NUM_BYTES_ARRAY_HEADER+util.NUM_BYTES_FLOAT*int64(len(r.averages)))
+	for _, reader := range r.subReaders {
+		sizeInBytes += reader.RamBytesUsed()
+	}
+	return sizeInBytes
+}
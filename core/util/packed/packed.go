@@ -229,6 +229,9 @@ type PackedIntsDecoder interface {
 	// Read 8 * iterations * blockCount() blocks from blocks, decodethem and write
 	// iterations * valueCount() values inot values.
 	decodeByteToLong(blocks []byte, values []int64, iterations int)
+	// Read iterations * byteBlockCount() blocks from blocks, decode
+	// them and write iterations * byteValueCount() values into values.
+	DecodeByteToInt(blocks []byte, values []int, iterations int)
 }
 
 func GetPackedIntsEncoder(format PackedFormat, version int32, bitsPerValue uint32) PackedIntsEncoder {
@@ -274,7 +277,7 @@ func (r *abstractReader) getBulk(index int, arr []int64) int {
 	if length < gets {
 		gets = length
 	}
-	for i, _ := range arr {
+	for i, _ := range arr[:gets] {
 		arr[i] = r.spi.Get(index + i)
 	}
 	return gets
@@ -346,6 +349,8 @@ type Mutable interface {
 	// generated data will return a reader with the same number of bits
 	// per value.
 	Save(out util.DataOutput) error
+	// The underlying format.
+	Format() PackedFormat
 }
 
 type abstractMutableSPI interface {
@@ -371,7 +376,10 @@ func (m *abstractMutable) setBulk(index int, arr []int64) int {
 	assert2(length > 0, "len must be > 0 (got %v)", length)
 	assert(index >= 0 && index < m.spi.Size())
 
-	for i, v := range arr {
+	if size := m.spi.Size() - index; size < length {
+		length = size
+	}
+	for i, v := range arr[:length] {
 		m.spi.Set(index+i, v)
 	}
 	return length
@@ -379,34 +387,28 @@ func (m *abstractMutable) setBulk(index int, arr []int64) int {
 
 /* Fill the mutable [from,to) with val. */
 func (m *abstractMutable) fill(from, to int, val int64) {
-	panic("niy")
-	// assert(val < MaxValue(m.BitsPerValue()))
-	// assert(from <= to)
-	// for i := from; i < to; i++ {
-	// 	m.spi.Set(i, val)
-	// }
+	assert(from <= to)
+	for i := from; i < to; i++ {
+		m.spi.Set(i, val)
+	}
 }
 
 /* Sets all values to 0 */
 func (m *abstractMutable) Clear() {
-	panic("niy")
-	// m.fill(0, int(m.Size()), 0)
+	m.fill(0, m.spi.Size(), 0)
 }
 
 func (m *abstractMutable) Save(out util.DataOutput) error {
-	panic("niy")
-	// writer := WriterNoHeader(out, m.format, int(m.valueCount), m.bitsPerValue, DEFAULT_BUFFER_SIZE)
-	// err := writer.writeHeader()
-	// if err != nil {
-	// 	return err
-	// }
-	// for i := 0; i < int(m.valueCount); i++ {
-	// 	err = writer.Add(m.spi.Get(i))
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
-	// return writer.Finish()
+	self := m.spi.(Mutable)
+	writer := WriterNoHeader(out, self.Format(), self.Size(), self.BitsPerValue(), DEFAULT_BUFFER_SIZE)
+	err := writer.writeHeader()
+	for i, size := 0, self.Size(); i < size && err == nil; i++ {
+		err = writer.Add(self.Get(i))
+	}
+	if err != nil {
+		return err
+	}
+	return writer.Finish()
 }
 
 func (m *abstractMutable) Format() PackedFormat {
@@ -625,19 +627,28 @@ func asUint32(n int32, err error) (n2 uint32, err2 error) {
 	return uint32(n), err
 }
 
+/*
+Restore a Reader from a stream, reading the header written by
+WriterFor() or Mutable.Save().
+*/
 func NewPackedReader(in DataInput) (r PackedIntsReader, err error) {
-	if version, err := codec.CheckHeader(in, PACKED_CODEC_NAME, PACKED_VERSION_START, VERSION_CURRENT); err == nil {
-		if bitsPerValue, err := asUint32(in.ReadVInt()); err == nil {
-			// assert bitsPerValue > 0 && bitsPerValue <= 64
-			if valueCount, err := in.ReadVInt(); err == nil {
-				if id, err := in.ReadVInt(); err == nil {
-					format := PackedFormat(id)
-					return ReaderNoHeader(in, format, version, valueCount, bitsPerValue)
-				}
-			}
-		}
+	var version int32
+	if version, err = codec.CheckHeader(in, PACKED_CODEC_NAME, PACKED_VERSION_START, VERSION_CURRENT); err != nil {
+		return nil, err
+	}
+	var bitsPerValue uint32
+	if bitsPerValue, err = asUint32(in.ReadVInt()); err != nil {
+		return nil, err
 	}
-	return
+	assert2(bitsPerValue > 0 && bitsPerValue <= 64, "bitsPerValue=%v", bitsPerValue)
+	var valueCount, id int32
+	if valueCount, err = in.ReadVInt(); err != nil {
+		return nil, err
+	}
+	if id, err = in.ReadVInt(); err != nil {
+		return nil, err
+	}
+	return ReaderNoHeader(in, PackedFormat(id), version, valueCount, bitsPerValue)
 }
 
 /*
@@ -736,6 +747,26 @@ func WriterNoHeader(out DataOutput, format PackedFormat,
 	return newPackedWriter(format, out, valueCount, bitsPerValue, mem)
 }
 
+/*
+Create a packed integer array writer for the given output, value
+count and number of bits per value, picking the format like
+MutableFor() does. Unlike WriterNoHeader(), a header recording the
+format and bitsPerValue is written first, so NewPackedReader() can
+restore the values.
+*/
+func WriterFor(out DataOutput, valueCount, bitsPerValue int,
+	acceptableOverheadRatio float32) (Writer, error) {
+
+	assert(valueCount >= 0)
+	formatAndBits := FastestFormatAndBits(valueCount, bitsPerValue, acceptableOverheadRatio)
+	writer := WriterNoHeader(out, formatAndBits.Format, valueCount,
+		formatAndBits.BitsPerValue, DEFAULT_BUFFER_SIZE)
+	if err := writer.writeHeader(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
 /*
 Returns how many bits are required to hold values up to and including maxValue
 NOTE: This method returns at least 1.
@@ -41,27 +41,32 @@ func newPacked16ThreeBlocksFromInput(version int32, in DataInput, valueCount int
 
 func (p *Packed16ThreeBlocks) Get(index int) int64 {
 	o := index * 3
-	return int64(p.blocks[o])<<32 | int64(p.blocks[o+1])<<16 | int64(p.blocks[o])
+	return int64(uint16(p.blocks[o]))<<32 | int64(uint16(p.blocks[o+1]))<<16 | int64(uint16(p.blocks[o+2]))
 }
 
 func (r *Packed16ThreeBlocks) getBulk(index int, arr []int64) int {
-	panic("niy")
+	return r.MutableImpl.getBulk(index, arr)
 }
 
 func (r *Packed16ThreeBlocks) Set(index int, value int64) {
-	panic("not implemented yet")
+	o := index * 3
+	r.blocks[o] = int16(value >> 32)
+	r.blocks[o+1] = int16(value >> 16)
+	r.blocks[o+2] = int16(value)
 }
 
 func (r *Packed16ThreeBlocks) setBulk(index int, arr []int64) int {
-	panic("not implemented yet")
+	return r.MutableImpl.setBulk(index, arr)
 }
 
 func (r *Packed16ThreeBlocks) fill(from, to int, val int64) {
-	panic("niy")
+	r.MutableImpl.fill(from, to, val)
 }
 
 func (r *Packed16ThreeBlocks) Clear() {
-	panic("niy")
+	for i := range r.blocks {
+		r.blocks[i] = 0
+	}
 }
 
 func (p *Packed16ThreeBlocks) RamBytesUsed() int64 {
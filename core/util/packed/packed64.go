@@ -89,7 +89,15 @@ func (p *Packed64) getBulk(index int, arr []int64) int {
 	// go to the next block where the value does not span across two blocks
 	offsetInBlocks := index % decoder.LongValueCount()
 	if offsetInBlocks != 0 {
-		panic("niy")
+		for i := offsetInBlocks; i < decoder.LongValueCount() && length > 0; i++ {
+			arr[off] = p.Get(index)
+			off++
+			index++
+			length--
+		}
+		if length == 0 {
+			return index - originalIndex
+		}
 	}
 
 	// bulk get
@@ -150,7 +158,15 @@ func (p *Packed64) setBulk(index int, arr []int64) int {
 	// go to the next block where the value does not span across two blocks
 	offsetInBlocks := index % encoder.LongValueCount()
 	if offsetInBlocks != 0 {
-		panic("niy")
+		for i := offsetInBlocks; i < encoder.LongValueCount() && length > 0; i++ {
+			p.Set(index, arr[off])
+			off++
+			index++
+			length--
+		}
+		if length == 0 {
+			return index - originalIndex
+		}
 	}
 
 	// bulk set
@@ -188,9 +204,11 @@ func (p *Packed64) RamBytesUsed() int64 {
 }
 
 func (p *Packed64) fill(from, to int, val int64) {
-	panic("niy")
+	p.MutableImpl.fill(from, to, val)
 }
 
 func (p *Packed64) Clear() {
-	panic("niy")
+	for i := range p.blocks {
+		p.blocks[i] = 0
+	}
 }
@@ -151,7 +151,7 @@ func (p *Packed64SingleBlock) setBulk(index int, arr []int64) int {
 }
 
 func (p *Packed64SingleBlock) fill(from, to int, val int64) {
-	panic("niy")
+	p.MutableImpl.fill(from, to, val)
 }
 
 func (p *Packed64SingleBlock) Format() PackedFormat {
@@ -40,23 +40,28 @@ func (r *Packed8ThreeBlocks) Get(index int) int64 {
 }
 
 func (r *Packed8ThreeBlocks) getBulk(index int, arr []int64) int {
-	panic("niy")
+	return r.MutableImpl.getBulk(index, arr)
 }
 
 func (r *Packed8ThreeBlocks) Set(index int, value int64) {
-	panic("not implemented yet")
+	o := index * 3
+	r.blocks[o] = byte(value >> 16)
+	r.blocks[o+1] = byte(value >> 8)
+	r.blocks[o+2] = byte(value)
 }
 
-func (r *Packed8ThreeBlocks) setBulk(idnex int, arr []int64) int {
-	panic("niy")
+func (r *Packed8ThreeBlocks) setBulk(index int, arr []int64) int {
+	return r.MutableImpl.setBulk(index, arr)
 }
 
 func (r *Packed8ThreeBlocks) fill(from, to int, val int64) {
-	panic("niy")
+	r.MutableImpl.fill(from, to, val)
 }
 
 func (r *Packed8ThreeBlocks) Clear() {
-	panic("niy")
+	for i := range r.blocks {
+		r.blocks[i] = 0
+	}
 }
 
 func (r *Packed8ThreeBlocks) RamBytesUsed() int64 {
@@ -196,7 +196,7 @@ func (b *PackedLongValuesBuilderImpl) packFrom(values []int64,
 			bitsRequired = BitsRequired(maxValue)
 		}
 		mutable := MutableFor(len(values), bitsRequired, acceptableOverheadRatio)
-		for i := 0; i < len(values); i++ {
+		for i := 0; i < len(values); {
 			i += mutable.setBulk(i, values[i:])
 		}
 		b.values[block] = mutable
@@ -2,6 +2,7 @@ package packed
 
 import (
 	"fmt"
+	"github.com/balzaczyy/golucene/core/store"
 	"math"
 	"math/rand"
 	"testing"
@@ -59,3 +60,400 @@ func TestUnsignedBitsRequired(t *testing.T) {
 		t.Errorf("-158146830731166066 -> 64bit (got %v)", n)
 	}
 }
+
+func TestEncodeDecodeInts(t *testing.T) {
+	for _, format := range []PackedFormat{PackedFormat(PACKED), PackedFormat(PACKED_SINGLE_BLOCK)} {
+		for bpv := uint32(1); bpv <= 32; bpv++ {
+			if !format.IsSupported(int(bpv)) {
+				continue
+			}
+			encoder := GetPackedIntsEncoder(format, VERSION_CURRENT, bpv)
+			decoder := GetPackedIntsDecoder(format, VERSION_CURRENT, bpv)
+			iterations := 3
+			values := make([]int, iterations*encoder.ByteValueCount())
+			for i := range values {
+				values[i] = int(rand.Int63n(int64(1) << bpv))
+			}
+			blocks := make([]byte, iterations*encoder.ByteBlockCount())
+			encoder.EncodeIntToByte(values, blocks, iterations)
+
+			decoded := make([]int, len(values))
+			decoder.DecodeByteToInt(blocks, decoded, iterations)
+			for i, v := range values {
+				if decoded[i] != v {
+					t.Fatalf("format=%v bpv=%v: decoded[%v]=%v, expected %v",
+						format, bpv, i, decoded[i], v)
+				}
+			}
+		}
+	}
+}
+
+func randomValues(r *rand.Rand, n, bpv int) []int64 {
+	values := make([]int64, n)
+	for i := range values {
+		if bpv == 64 {
+			values[i] = r.Int63() ^ (r.Int63() << 1)
+		} else {
+			values[i] = r.Int63n(MaxValue(bpv) + 1)
+		}
+	}
+	// always include the extremes
+	values[0], values[n-1] = MaxValue(bpv), 0
+	return values
+}
+
+func checkReader(t *testing.T, desc string, r PackedIntsReader, values []int64) {
+	if r.Size() != len(values) {
+		t.Fatalf("%v: expected size %v, got %v", desc, len(values), r.Size())
+	}
+	for i, v := range values {
+		if got := r.Get(i); got != v {
+			t.Fatalf("%v: value %v: expected %v, got %v", desc, i, v, got)
+		}
+	}
+}
+
+func TestMutableRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+
+	// 1000 values don't fill the last block of any encoding
+	const valueCount = 1000
+	for _, bpv := range []int{1, 7, 12, 24, 31, 48, 64} {
+		values := randomValues(r, valueCount, bpv)
+		var mutables []Mutable
+		for _, ratio := range []float32{PackedInts.COMPACT, PackedInts.DEFAULT, PackedInts.FASTEST} {
+			mutables = append(mutables, MutableFor(valueCount, bpv, ratio))
+		}
+		if PackedFormat(PACKED_SINGLE_BLOCK).IsSupported(bpv) {
+			mutables = append(mutables, MutableForFormat(valueCount, bpv, PackedFormat(PACKED_SINGLE_BLOCK)))
+		}
+
+		for _, m := range mutables {
+			desc := fmt.Sprintf("bpv=%v %v", bpv, m)
+			for i, v := range values {
+				m.Set(i, v)
+			}
+			checkReader(t, desc, m, values)
+
+			out, err := dir.CreateOutput("packed", store.IO_CONTEXT_DEFAULT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = m.Save(out); err != nil {
+				t.Fatal(err)
+			}
+			out.Close()
+			in, err := dir.OpenInput("packed", store.IO_CONTEXT_READONCE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewPackedReader(in)
+			in.Close()
+			if err != nil {
+				t.Fatalf("%v: %v", desc, err)
+			}
+			checkReader(t, desc+" (saved)", reader, values)
+
+			m.Clear()
+			checkReader(t, desc+" (cleared)", m, make([]int64, valueCount))
+			dir.DeleteFile("packed")
+		}
+	}
+}
+
+func TestWriterFor(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+
+	for _, bpv := range []int{1, 7, 12, 31, 64} {
+		for _, ratio := range []float32{PackedInts.COMPACT, PackedInts.FASTEST} {
+			values := randomValues(r, 777, bpv)
+			out, err := dir.CreateOutput("packed", store.IO_CONTEXT_DEFAULT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := WriterFor(out, len(values), bpv, ratio)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range values {
+				if err = w.Add(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Finish(); err != nil {
+				t.Fatal(err)
+			}
+			out.Close()
+
+			in, err := dir.OpenInput("packed", store.IO_CONTEXT_READONCE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewPackedReader(in)
+			in.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkReader(t, fmt.Sprintf("bpv=%v ratio=%v", bpv, ratio), reader, values)
+			dir.DeleteFile("packed")
+		}
+	}
+}
+
+func TestPacked64BulkUnaligned(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	const bpv = 7
+	values := randomValues(r, 500, bpv)
+	m := MutableForFormat(len(values), bpv, PackedFormat(PACKED))
+	for off := 0; off < len(values); {
+		n := 1 + r.Intn(100)
+		if off+n > len(values) {
+			n = len(values) - off
+		}
+		off += m.setBulk(off, values[off:off+n])
+	}
+	checkReader(t, "setBulk", m, values)
+
+	got := make([]int64, len(values))
+	for off := 3; off < len(values); {
+		n := 1 + r.Intn(100)
+		if off+n > len(values) {
+			n = len(values) - off
+		}
+		off += m.getBulk(off, got[off:off+n])
+	}
+	for i := 3; i < len(values); i++ {
+		if got[i] != values[i] {
+			t.Fatalf("getBulk: value %v: expected %v, got %v", i, values[i], got[i])
+		}
+	}
+}
+
+func TestMonotonicBlockPacked(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+
+	const valueCount = 1000
+	increasing := make([]int64, valueCount)
+	constant := make([]int64, valueCount)
+	linear := make([]int64, valueCount)
+	for i := 1; i < valueCount; i++ {
+		increasing[i] = increasing[i-1] + 1 + r.Int63n(1<<20)
+	}
+	for i := range constant {
+		constant[i] = 1 << 40
+	}
+	for i := range linear {
+		linear[i] = 1000 + int64(i)*37 + r.Int63n(5)
+	}
+	// flat then steep, so min must drop below the first value
+	steps := make([]int64, valueCount)
+	for i := range steps {
+		if i%64 < 60 {
+			steps[i] = int64(i)
+		} else {
+			steps[i] = int64(i) * 1000
+		}
+	}
+
+	for name, values := range map[string][]int64{
+		"increasing": increasing,
+		"constant":   constant,
+		"linear":     linear,
+		"steps":      steps,
+	} {
+		for _, blockSize := range []int{64, 256} {
+			msg := fmt.Sprintf("%v blockSize=%v", name, blockSize)
+			out, err := dir.CreateOutput("monotonic", store.IO_CONTEXT_DEFAULT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := NewMonotonicBlockPackedWriter(out, blockSize)
+			for _, v := range values {
+				if err = w.Add(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Finish(); err != nil {
+				t.Fatal(err)
+			}
+			if w.Ord() != valueCount {
+				t.Errorf("%v: ord=%v", msg, w.Ord())
+			}
+			out.Close()
+
+			in, err := dir.OpenInput("monotonic", store.IO_CONTEXT_READONCE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewMonotonicBlockPackedReader(in, VERSION_CURRENT, blockSize, valueCount)
+			if err == nil && in.FilePointer() != in.Length() {
+				t.Errorf("%v: %v bytes left unread", msg, in.Length()-in.FilePointer())
+			}
+			in.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range values {
+				if got := reader.Get(int64(i)); got != v {
+					t.Fatalf("%v: values[%v]=%v, got %v", msg, i, v, got)
+				}
+			}
+			dir.DeleteFile("monotonic")
+		}
+	}
+}
+
+func TestMonotonicBlockPackedZigZag(t *testing.T) {
+	// [5 5 9] as written before VERSION_MONOTONIC_WITHOUT_ZIGZAG:
+	// VLong min 5, average 2.0, 2 bits per value, zig-zag deltas [0 -2 0]
+	in := store.NewByteArrayDataInput([]byte{5, 0x40, 0, 0, 0, 2, 0x30})
+	reader, err := NewMonotonicBlockPackedReader(in, PACKED_VERSION_BYTE_ALIGNED, 64, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []int64{5, 5, 9} {
+		if got := reader.Get(int64(i)); got != v {
+			t.Errorf("values[%v]=%v, got %v", i, v, got)
+		}
+	}
+}
+
+func TestBlockPacked(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+
+	const valueCount = 1000
+	small := make([]int64, valueCount)
+	negative := make([]int64, valueCount)
+	extreme := make([]int64, valueCount)
+	for i := range small {
+		small[i] = 1000 + r.Int63n(16)
+		negative[i] = -r.Int63n(1 << 30)
+		extreme[i] = r.Int63()
+		if i%2 == 0 {
+			extreme[i] = math.MinInt64 + r.Int63n(10)
+		}
+	}
+
+	for name, values := range map[string][]int64{
+		"small":    small,
+		"constant": make([]int64, valueCount),
+		"negative": negative,
+		"extreme":  extreme,
+	} {
+		for _, blockSize := range []int{64, 256} {
+			msg := fmt.Sprintf("%v blockSize=%v", name, blockSize)
+			out, err := dir.CreateOutput("block", store.IO_CONTEXT_DEFAULT)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := NewBlockPackedWriter(out, blockSize)
+			for _, v := range values {
+				if err = w.Add(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.Finish(); err != nil {
+				t.Fatal(err)
+			}
+			out.Close()
+
+			in, err := dir.OpenInput("block", store.IO_CONTEXT_READONCE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader, err := NewBlockPackedReader(in, VERSION_CURRENT, blockSize, valueCount)
+			if err == nil && in.FilePointer() != in.Length() {
+				t.Errorf("%v: %v bytes left unread", msg, in.Length()-in.FilePointer())
+			}
+			in.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, v := range values {
+				if got := reader.Get(int64(i)); got != v {
+					t.Fatalf("%v: values[%v]=%v, got %v", msg, i, v, got)
+				}
+			}
+			dir.DeleteFile("block")
+		}
+	}
+}
+
+func TestPackedLongValuesRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, bpv := range []int{1, 3, 7, 13, 21, 31, 47, 62} {
+		// a full page and a partial one not made of whole bulk blocks:
+		// packing a page used to skip values whenever setBulk() stored
+		// less than all of them at once
+		values := randomValues(r, 256+200, bpv)
+		b := newPackedLongValuesBuilder(256, PackedInts.COMPACT)
+		for _, v := range values {
+			b.Add(v)
+		}
+		packed := b.Build()
+		if packed.Size() != int64(len(values)) {
+			t.Fatalf("bpv=%v: expected size %v, got %v", bpv, len(values), packed.Size())
+		}
+		it := packed.Iterator()
+		for i, expected := range values {
+			if v, _ := it(); v.(int64) != expected {
+				t.Fatalf("bpv=%v: value %v: expected %v, got %v", bpv, i, expected, v)
+			}
+		}
+	}
+}
+
+func TestComputeIterationsSmallReader(t *testing.T) {
+	for _, bpv := range []uint32{1, 7, 21, 64} {
+		op := newBulkOperation(PackedFormat(PACKED), bpv)
+		// a budget far larger than needed for 10 values
+		iterations := op.computeIterations(10, 1<<20)
+		if iterations*op.ByteValueCount() < 10 {
+			t.Errorf("bpv=%v: %v iterations can't hold 10 values", bpv, iterations)
+		}
+		if (iterations-1)*op.ByteValueCount() >= 10 {
+			t.Errorf("bpv=%v: %v iterations are more than 10 values need", bpv, iterations)
+		}
+	}
+
+	// writing a handful of values with the default buffer used to panic
+	dir := store.NewRAMDirectory()
+	defer dir.Close()
+	values := []int64{5, 0, 127, 64, 3}
+	out, err := dir.CreateOutput("packed", store.IO_CONTEXT_DEFAULT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := WriterFor(out, len(values), 7, PackedInts.COMPACT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if err = w.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	in, err := dir.OpenInput("packed", store.IO_CONTEXT_READONCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	reader, err := NewPackedReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReader(t, "small reader", reader, values)
+}
@@ -0,0 +1,224 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+)
+
+// util/PagedBytes.java
+
+/*
+Represents a logical []byte as a series of pages. You can write-once
+into the logical []byte (append only), using copy, and then retrieve
+slices (BytesRef) into it using fill.
+*/
+type PagedBytes struct {
+	blocks       [][]byte
+	blockEnd     []int
+	blockSize    int
+	blockBits    uint
+	blockMask    int
+	didSkipBytes bool
+	frozen       bool
+	upto         int
+	currentBlock []byte
+}
+
+/* 1<<blockBits must be bigger than biggest single BytesRef slice that will be pulled. */
+func NewPagedBytes(blockBits uint) *PagedBytes {
+	assert2(blockBits > 0 && blockBits <= 31, "blockBits=%v", blockBits)
+	blockSize := 1 << blockBits
+	return &PagedBytes{
+		blockSize: blockSize,
+		blockBits: blockBits,
+		blockMask: blockSize - 1,
+		upto:      blockSize,
+	}
+}
+
+func (pb *PagedBytes) addBlock(block []byte) {
+	pb.blocks = append(pb.blocks, block)
+	pb.blockEnd = append(pb.blockEnd, pb.upto)
+}
+
+/* Read this many bytes from in. */
+func (pb *PagedBytes) CopyFrom(in DataInput, byteCount int64) error {
+	for byteCount > 0 {
+		left := pb.blockSize - pb.upto
+		if left == 0 {
+			if pb.currentBlock != nil {
+				pb.addBlock(pb.currentBlock)
+			}
+			pb.currentBlock = make([]byte, pb.blockSize)
+			pb.upto = 0
+			left = pb.blockSize
+		}
+		if int64(left) < byteCount {
+			if err := in.ReadBytes(pb.currentBlock[pb.upto:pb.blockSize]); err != nil {
+				return err
+			}
+			pb.upto = pb.blockSize
+			byteCount -= int64(left)
+		} else {
+			if err := in.ReadBytes(pb.currentBlock[pb.upto : pb.upto+int(byteCount)]); err != nil {
+				return err
+			}
+			pb.upto += int(byteCount)
+			break
+		}
+	}
+	return nil
+}
+
+/*
+Copy bytes in, setting out to the copied bytes. The bytes must fit
+in a single block. The PagedBytes can't be frozen after this is
+used, since a partially filled block may be skipped.
+*/
+func (pb *PagedBytes) Copy(bytes []byte, out *BytesRef) {
+	left := pb.blockSize - pb.upto
+	if len(bytes) > left || pb.currentBlock == nil {
+		if pb.currentBlock != nil {
+			pb.addBlock(pb.currentBlock)
+			pb.didSkipBytes = true
+		}
+		pb.currentBlock = make([]byte, pb.blockSize)
+		pb.upto = 0
+		assert2(len(bytes) <= pb.blockSize, "%v bytes don't fit in block size %v",
+			len(bytes), pb.blockSize)
+	}
+	out.Bytes = pb.currentBlock
+	out.Offset = pb.upto
+	out.Length = len(bytes)
+	copy(pb.currentBlock[pb.upto:], bytes)
+	pb.upto += len(bytes)
+}
+
+/*
+Commits final []byte, trimming it if necessary and if trim=true, and
+returns a Reader over the frozen bytes.
+*/
+func (pb *PagedBytes) Freeze(trim bool) *PagedBytesReader {
+	assert2(!pb.frozen, "already frozen")
+	assert2(!pb.didSkipBytes, "cannot freeze when Copy(bytes, out) was used")
+	if trim && pb.upto < pb.blockSize {
+		pb.currentBlock = append([]byte(nil), pb.currentBlock[:pb.upto]...)
+	}
+	if pb.currentBlock == nil {
+		pb.currentBlock = EMPTY_BYTES
+	}
+	pb.addBlock(pb.currentBlock)
+	pb.frozen = true
+	pb.currentBlock = nil
+	return newPagedBytesReader(pb)
+}
+
+/* Returns the current write position, to be passed to the Reader later. */
+func (pb *PagedBytes) Pointer() int64 {
+	if pb.currentBlock == nil {
+		return 0
+	}
+	return int64(len(pb.blocks))*int64(pb.blockSize) + int64(pb.upto)
+}
+
+/*
+Copy bytes in, writing the length as a 1 or 2 byte vInt prefix, and
+returns the pointer to read them back with PagedBytesReader.Fill().
+*/
+func (pb *PagedBytes) CopyUsingLengthPrefix(bytes []byte) (int64, error) {
+	if len(bytes) >= 32768 {
+		return 0, errors.New(fmt.Sprintf("max length is 32767 (got %v)", len(bytes)))
+	}
+	if pb.upto+len(bytes)+2 > pb.blockSize {
+		if len(bytes)+2 > pb.blockSize {
+			return 0, errors.New(fmt.Sprintf(
+				"block size %v is too small to store length %v bytes",
+				pb.blockSize, len(bytes)))
+		}
+		if pb.currentBlock != nil {
+			pb.addBlock(pb.currentBlock)
+		}
+		pb.currentBlock = make([]byte, pb.blockSize)
+		pb.upto = 0
+	}
+
+	pointer := pb.Pointer()
+	if len(bytes) < 128 {
+		pb.currentBlock[pb.upto] = byte(len(bytes))
+		pb.upto++
+	} else {
+		pb.currentBlock[pb.upto] = byte(0x80 | (len(bytes) >> 8))
+		pb.currentBlock[pb.upto+1] = byte(len(bytes))
+		pb.upto += 2
+	}
+	copy(pb.currentBlock[pb.upto:], bytes)
+	pb.upto += len(bytes)
+	return pointer, nil
+}
+
+/*
+Provides methods to read BytesRefs from a frozen PagedBytes. Slices
+within a single block are returned as views, without copying.
+*/
+type PagedBytesReader struct {
+	blocks    [][]byte
+	blockEnds []int
+	blockBits uint
+	blockMask int
+	blockSize int
+}
+
+func newPagedBytesReader(pb *PagedBytes) *PagedBytesReader {
+	return &PagedBytesReader{
+		blocks:    pb.blocks,
+		blockEnds: pb.blockEnd,
+		blockBits: pb.blockBits,
+		blockMask: pb.blockMask,
+		blockSize: pb.blockSize,
+	}
+}
+
+/*
+Gets a slice out of PagedBytes starting at start with a given length.
+If the slice spans across a block border this method will allocate
+sufficient resources and copy the paged data.
+
+Slices spanning more than two blocks are not supported.
+*/
+func (r *PagedBytesReader) FillSlice(b *BytesRef, start int64, length int) {
+	assert2(length >= 0, "length=%v", length)
+	assert2(length <= r.blockSize+1, "length=%v", length)
+	b.Length = length
+	if length == 0 {
+		return
+	}
+	index := int(start >> r.blockBits)
+	offset := int(start & int64(r.blockMask))
+	if r.blockSize-offset >= length {
+		// within block
+		b.Bytes = r.blocks[index]
+		b.Offset = offset
+	} else {
+		// split
+		b.Bytes = make([]byte, length)
+		b.Offset = 0
+		n := copy(b.Bytes, r.blocks[index][offset:])
+		copy(b.Bytes[n:], r.blocks[index+1])
+	}
+}
+
+/* Reads length as 1 or 2 byte vInt prefix, starting at start. */
+func (r *PagedBytesReader) Fill(b *BytesRef, start int64) {
+	index := int(start >> r.blockBits)
+	offset := int(start & int64(r.blockMask))
+	block := r.blocks[index]
+	b.Bytes = block
+	if block[offset]&128 == 0 {
+		b.Length = int(block[offset])
+		b.Offset = offset + 1
+	} else {
+		b.Length = int(block[offset]&0x7f)<<8 | int(block[offset+1])
+		b.Offset = offset + 2
+		assert(b.Length > 0)
+	}
+}
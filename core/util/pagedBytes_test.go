@@ -0,0 +1,105 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+type sliceDataInput struct {
+	*DataInputImpl
+	data []byte
+}
+
+func newSliceDataInput(data []byte) *sliceDataInput {
+	ans := &sliceDataInput{data: data}
+	ans.DataInputImpl = NewDataInput(ans)
+	return ans
+}
+
+func (in *sliceDataInput) ReadByte() (byte, error) {
+	if len(in.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b, nil
+}
+
+func (in *sliceDataInput) ReadBytes(buf []byte) error {
+	if len(in.data) < len(buf) {
+		return io.ErrUnexpectedEOF
+	}
+	in.data = in.data[copy(buf, in.data):]
+	return nil
+}
+
+func TestPagedBytesLengthPrefix(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pb := NewPagedBytes(10)
+	var values [][]byte
+	var pointers []int64
+	for i := 0; i < 5000; i++ {
+		// mostly short values, some needing a 2 byte length prefix
+		n := r.Intn(20)
+		if i%100 == 0 {
+			n = 128 + r.Intn(500)
+		}
+		value := make([]byte, n)
+		r.Read(value)
+		pointer, err := pb.CopyUsingLengthPrefix(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+		pointers = append(pointers, pointer)
+	}
+	if _, err := pb.CopyUsingLengthPrefix(make([]byte, 1023)); err == nil {
+		t.Error("expected error for value larger than a block")
+	}
+
+	reader := pb.Freeze(true)
+	b := NewEmptyBytesRef()
+	for i, pointer := range pointers {
+		reader.Fill(b, pointer)
+		if !bytes.Equal(b.ToBytes(), values[i]) {
+			t.Fatalf("%v: expected %v, got %v", i, values[i], b.ToBytes())
+		}
+	}
+}
+
+func TestPagedBytesFillSlice(t *testing.T) {
+	const blockBits = 4
+	data := make([]byte, 10<<blockBits+3)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	pb := NewPagedBytes(blockBits)
+	if err := pb.CopyFrom(newSliceDataInput(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if pb.Pointer() != int64(len(data)) {
+		t.Errorf("expected pointer %v, got %v", len(data), pb.Pointer())
+	}
+	reader := pb.Freeze(false)
+
+	b := NewEmptyBytesRef()
+	for start := 0; start < len(data); start++ {
+		for length := 0; length <= 1<<blockBits && start+length <= len(data); length++ {
+			reader.FillSlice(b, int64(start), length)
+			if !bytes.Equal(b.ToBytes(), data[start:start+length]) {
+				t.Fatalf("slice %v:%v: expected %v, got %v",
+					start, length, data[start:start+length], b.ToBytes())
+			}
+		}
+	}
+
+	// slices within a block are views on the same page
+	reader.FillSlice(b, 17, 4)
+	view := b.Bytes
+	reader.FillSlice(b, 20, 8)
+	if &view[0] != &b.Bytes[0] {
+		t.Error("expected slices of the same block to share the page")
+	}
+}
@@ -32,6 +32,15 @@ func (so *SetOnce) Set(obj interface{}) {
 	assert2(so.obj == obj, "The object cannot be set twice!")
 }
 
+/*
+Sets the given object if none was set before, and returns true if it
+did so.
+*/
+func (so *SetOnce) TrySet(obj interface{}) (ok bool) {
+	so.Do(func() { so.obj, ok = obj, true })
+	return
+}
+
 // Returns the object set by Set().
 func (so *SetOnce) Get() interface{} {
 	return so.obj
@@ -1,11 +1,17 @@
 package core_test
 
 import (
+	"bytes"
 	"fmt"
+	acore "github.com/balzaczyy/golucene/analysis/core"
+	"github.com/balzaczyy/golucene/analysis/miscellaneous"
 	std "github.com/balzaczyy/golucene/analysis/standard"
+	"github.com/balzaczyy/golucene/core/analysis"
 	_ "github.com/balzaczyy/golucene/core/codec/lucene410"
+	"github.com/balzaczyy/golucene/core/codec/spi"
 	docu "github.com/balzaczyy/golucene/core/document"
 	"github.com/balzaczyy/golucene/core/index"
+	"github.com/balzaczyy/golucene/core/index/model"
 	"github.com/balzaczyy/golucene/core/search"
 	"github.com/balzaczyy/golucene/core/store"
 	"github.com/balzaczyy/golucene/core/util"
@@ -13,8 +19,14 @@ import (
 	// "github.com/balzaczyy/golucene/test_framework/analysis"
 	// . "github.com/balzaczyy/golucene/test_framework/util"
 	. "github.com/balzaczyy/gounit"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Hook up custom test logic into Go's test runner.
@@ -23,7 +35,7 @@ func TestBefore(t *testing.T) {
 
 	// util.SetDefaultInfoStream(util.NewPrintStreamInfoStream(os.Stdout))
 	index.DefaultSimilarity = func() index.Similarity {
-		return search.NewDefaultSimilarity()
+		return search.NewBM25Similarity()
 	}
 	// This controls how suite-level rules are nested. It is important
 	// that _all_ rules declared in testcase are executed in proper
@@ -122,6 +134,403 @@ func TestBasicIndexAndSearch(t *testing.T) {
 // 	})
 // }
 
+func TestCommitHook(t *testing.T) {
+	directory := store.NewRAMDirectory()
+	defer directory.Close()
+
+	var generations []int64
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetCommitHook(func(generation int64, userData map[string]string) error {
+		generations = append(generations, generation)
+		return nil
+	})
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	for i := 0; i < 2; i++ {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("foo", "bar", docu.STORE_YES))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		err = writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	It(t).Should("fire after each commit (got %v)", generations).Assert(len(generations) == 2)
+	It(t).Should("report increasing generations (got %v)", generations).Verify(
+		generations[1] == generations[0]+1)
+	files, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("report the committed generation").Verify(
+		index.LastCommitGeneration(files) == generations[1])
+
+	d := docu.NewDocument()
+	d.Add(docu.NewTextFieldFromString("foo", "baz", docu.STORE_YES))
+	err = writer.AddDocument(d.Fields())
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.Rollback()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("not fire on rollback (got %v)", generations).Verify(len(generations) == 2)
+}
+
+func TestCommitHookCallsBackIntoWriter(t *testing.T) {
+	directory := store.NewRAMDirectory()
+	defer directory.Close()
+
+	var writer *index.IndexWriter
+	var generations []int64
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetCommitHook(func(generation int64, userData map[string]string) error {
+		generations = append(generations, generation)
+		if len(generations) > 1 {
+			return nil
+		}
+		// commit again from within the hook
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("foo", "hook", docu.STORE_YES))
+		if err := writer.AddDocument(d.Fields()); err != nil {
+			return err
+		}
+		return writer.Commit()
+	})
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	d := docu.NewDocument()
+	d.Add(docu.NewTextFieldFromString("foo", "bar", docu.STORE_YES))
+	err = writer.AddDocument(d.Fields())
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	done := make(chan error)
+	go func() { done <- writer.Commit() }()
+	select {
+	case err = <-done:
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("commit hook deadlocked calling back into the writer")
+	}
+	It(t).Should("fire for both commits (got %v)", generations).Verify(
+		len(generations) == 2 && generations[1] == generations[0]+1)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+}
+
+func TestSnapshotDeletionPolicy(t *testing.T) {
+	directory := store.NewRAMDirectory()
+	defer directory.Close()
+
+	sdp := index.NewSnapshotDeletionPolicy(index.DEFAULT_DELETION_POLICY)
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetIndexDeletionPolicy(sdp)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+
+	_, err = sdp.Snapshot()
+	It(t).Should("fail without any commit").Verify(err != nil)
+
+	addAndCommit := func(value string) {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("foo", value, docu.STORE_YES))
+		err := writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		err = writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	addAndCommit("bar")
+	snapshot, err := sdp.Snapshot()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("hold one snapshot").Verify(sdp.SnapshotCount() == 1)
+	It(t).Should("find the snapshot by generation").Verify(
+		sdp.IndexCommit(snapshot.Generation()) == snapshot)
+
+	for _, value := range []string{"baz", "qux", "quux"} {
+		addAndCommit(value)
+		for _, name := range snapshot.FileNames() {
+			It(t).Should("keep snapshotted file %v", name).Verify(directory.FileExists(name))
+		}
+	}
+
+	err = sdp.Release(snapshot)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("hold no snapshot").Verify(sdp.SnapshotCount() == 0)
+	err = sdp.Release(snapshot)
+	It(t).Should("fail to release twice").Verify(err != nil)
+
+	// the next commit lets the wrapped policy delete it
+	addAndCommit("corge")
+	It(t).Should("delete released commit %v", snapshot.SegmentsFileName()).Verify(
+		!directory.FileExists(snapshot.SegmentsFileName()))
+}
+
+func TestPersistentSnapshotDeletionPolicy(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+	snapshots := store.NewRAMDirectory()
+	defer snapshots.Close()
+
+	// each session stands for a restart: a new writer, and a new policy
+	// loading what the previous one saved
+	openWriter := func(directory store.Directory) (*index.IndexWriter, *index.PersistentSnapshotDeletionPolicy) {
+		psdp, err := index.NewPersistentSnapshotDeletionPolicy(index.DEFAULT_DELETION_POLICY, snapshots)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+		conf.SetIndexDeletionPolicy(psdp)
+		writer, err := index.NewIndexWriter(directory, conf)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		return writer, psdp
+	}
+	addAndCommit := func(writer *index.IndexWriter, value string) {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("foo", value, docu.STORE_YES))
+		err := writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		err = writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	writer, psdp := openWriter(directory)
+	addAndCommit(writer, "bar")
+	snapshot, err := psdp.Snapshot()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("save the snapshot").Verify(snapshots.FileExists(psdp.LastSaveFile()))
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	writer, psdp = openWriter(directory)
+	pinned := psdp.Snapshots()
+	It(t).Should("load the snapshot, got %v", pinned).Assert(
+		len(pinned) == 1 && pinned[0].Generation() == snapshot.Generation())
+	for _, value := range []string{"baz", "qux"} {
+		addAndCommit(writer, value)
+		for _, name := range snapshot.FileNames() {
+			It(t).Should("keep snapshotted file %v", name).Verify(directory.FileExists(name))
+		}
+	}
+	err = psdp.Release(pinned[0])
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	addAndCommit(writer, "quux")
+	It(t).Should("delete released commit %v", snapshot.SegmentsFileName()).Verify(
+		!directory.FileExists(snapshot.SegmentsFileName()))
+	_, err = psdp.Snapshot()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	// a snapshot of a commit that's gone is dropped on open
+	empty := store.NewRAMDirectory()
+	defer empty.Close()
+	writer, psdp = openWriter(empty)
+	defer writer.Close()
+	It(t).Should("drop the missing commit, got %v", psdp.Snapshots()).Verify(
+		psdp.SnapshotCount() == 0)
+	psdp, err = index.NewPersistentSnapshotDeletionPolicy(index.DEFAULT_DELETION_POLICY, snapshots)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("persist the dropped snapshot").Verify(psdp.SnapshotCount() == 0)
+}
+
+// Indexes one document per value into a new single segment index.
+// Values should be single, non stop words, so all docs share the same
+// field length.
+func newSingleFieldIndex(t *testing.T, path, field string, values ...string) store.Directory {
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	return newSingleFieldIndexWithConfig(t, path, conf, field, values...)
+}
+
+func newSingleFieldIndexWithConfig(t *testing.T, path string,
+	conf *index.IndexWriterConfig, field string, values ...string) store.Directory {
+
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for _, v := range values {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString(field, v, docu.STORE_YES))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	return directory
+}
+
+// Fails to create outputs whose name ends with ext, if ext is set.
+type failingOutputDirectory struct {
+	store.Directory
+	ext string
+}
+
+func (d *failingOutputDirectory) CreateOutput(name string, ctx store.IOContext) (store.IndexOutput, error) {
+	if d.ext != "" && strings.HasSuffix(name, d.ext) {
+		return nil, fmt.Errorf("cannot create %v", name)
+	}
+	return d.Directory.CreateOutput(name, ctx)
+}
+
+func TestFailedFlushReclaimsOrphanFiles(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	fsDir, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	directory := &failingOutputDirectory{Directory: fsDir}
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetMergePolicy(index.NO_MERGE_POLICY).SetMaxBufferedDocs(2)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	addDoc := func(id string) error {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+		return writer.AddDocument(d.Fields())
+	}
+
+	err = addDoc("doc0")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.PrepareCommit()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	before, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	// leftovers of an earlier failure, not referenced by any commit
+	orphans := []string{"_9.fdt", "_9.si"}
+	for _, name := range orphans {
+		err = ioutil.WriteFile(filepath.Join(path, name), []byte("junk"), 0644)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	// the second buffered doc triggers a flush, which can't write its
+	// field infos
+	directory.ext = ".fnm"
+	err = addDoc("doc1")
+	if err == nil {
+		err = addDoc("doc2")
+	}
+	It(t).Should("fail to flush").Assert(err != nil)
+	directory.ext = ""
+
+	after, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	sort.Strings(before)
+	sort.Strings(after)
+	It(t).Should("leave only the files of the pending commit: %v vs %v", after, before).
+		Verify(strings.Join(after, " ") == strings.Join(before, " "))
+
+	err = writer.Commit()
+	It(t).Should("finish the pending commit: %v", err).Assert(err == nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("see the prepared doc").Verify(reader.NumDocs() == 1)
+}
+
+func TestParallelCompositeReader(t *testing.T) {
+	root, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(root)
+
+	main := newSingleFieldIndex(t, filepath.Join(root, "main"), "id", "one", "two", "three", "four")
+	defer main.Close()
+	tags := newSingleFieldIndex(t, filepath.Join(root, "tags"), "tag", "red", "blue", "green", "blue")
+	defer tags.Close()
+
+	r1, err := index.OpenDirectoryReader(main)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer r1.Close()
+	r2, err := index.OpenDirectoryReader(tags)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer r2.Close()
+
+	reader, err := index.NewParallelCompositeReader(false, r1, r2)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("have 4 docs, got %v", reader.NumDocs()).Assert(reader.NumDocs() == 4)
+
+	res, err := search.NewIndexSearcher(reader).SearchTop(
+		search.NewTermQuery(index.NewTerm("tag", "blue")), 10)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	var ids []string
+	for _, hit := range res.ScoreDocs {
+		doc, err := reader.Document(hit.Doc)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		It(t).Should("load fields of both indexes").Verify(doc.Get("tag") == "blue")
+		ids = append(ids, doc.Get("id"))
+	}
+	It(t).Should("find tag in docs two and four, got %v", ids).Verify(len(ids) == 2 &&
+		(ids[0] == "two" && ids[1] == "four" || ids[0] == "four" && ids[1] == "two"))
+
+	// doc counts must line up
+	short := newSingleFieldIndex(t, filepath.Join(root, "short"), "tag", "red", "blue", "green")
+	defer short.Close()
+	r3, err := index.OpenDirectoryReader(short)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer r3.Close()
+	_, err = index.NewParallelCompositeReader(false, r1, r3)
+	It(t).Should("reject readers with different maxDoc").Verify(err != nil)
+}
+
+func TestChecksumManifest(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer()).
+		SetWriteChecksumManifest(true)
+	directory := newSingleFieldIndexWithConfig(t, path, conf, "id", "one", "two", "six")
+	defer directory.Close()
+	It(t).Should("write the manifest").Assert(directory.FileExists(index.CHECKSUMS_FILENAME))
+
+	checkManifest := func() *index.ChecksumManifestStatus {
+		var buf bytes.Buffer
+		checker := index.NewCheckIndex(directory, false, &buf)
+		checker.SetVerifyChecksumManifest(true)
+		status := checker.CheckIndex(nil)
+		It(t).Should("report manifest status").Assert(status.ChecksumManifestStatus != nil)
+		return status.ChecksumManifestStatus
+	}
+
+	status := checkManifest()
+	It(t).Should("match the unchanged index: %v", status.Mismatched).Verify(len(status.Mismatched) == 0)
+	It(t).Should("not be stale").Verify(!status.Stale)
+	It(t).Should("verify all files").Verify(status.NumVerified > 1 && len(status.Unverified) == 0)
+
+	// silently flip a bit in the middle of a segment file
+	files, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	var victim string
+	for _, name := range files {
+		if strings.HasPrefix(name, "_") && !strings.HasSuffix(name, ".si") {
+			victim = name
+			break
+		}
+	}
+	It(t).Should("find a segment file in %v", files).Assert(victim != "")
+	f, err := os.OpenFile(filepath.Join(path, victim), os.O_RDWR, 0)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	info, err := f.Stat()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	b := make([]byte, 1)
+	_, err = f.ReadAt(b, info.Size()/2)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	b[0] ^= 0x10
+	_, err = f.WriteAt(b, info.Size()/2)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("has no error").Assert(f.Close() == nil)
+
+	status = checkManifest()
+	It(t).Should("detect corrupted %v, got %v", victim, status.Mismatched).Verify(
+		len(status.Mismatched) == 1 && status.Mismatched[0] == victim)
+}
+
 func isSimilar(f1, f2, delta float32) bool {
 	diff := f1 - f2
 	return diff >= 0 && diff < delta || diff < 0 && -diff < delta
@@ -130,3 +539,1273 @@ func isSimilar(f1, f2, delta float32) bool {
 func TestAfter(t *testing.T) {
 	// AfterSuite(t)
 }
+
+func TestUnreferencedFilesReclaimedOnOpen(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+
+	directory := newSingleFieldIndex(t, path, "id", "one", "two", "six")
+	defer directory.Close()
+	referenced, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	// leftovers of a flush that never made it into a commit
+	orphans := []string{"_9.fdt", "_9.si", "_9_Lucene41_0.doc"}
+	for _, name := range append(orphans, "notes.txt") {
+		err = ioutil.WriteFile(filepath.Join(path, name), []byte("junk"), 0644)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	for _, name := range orphans {
+		It(t).Should("remove orphan %v", name).Verify(!directory.FileExists(name))
+	}
+	It(t).Should("keep non-index files").Verify(directory.FileExists("notes.txt"))
+	for _, name := range referenced {
+		if name == "write.lock" {
+			continue
+		}
+		It(t).Should("keep referenced file %v", name).Verify(directory.FileExists(name))
+	}
+}
+
+func TestStoredFieldsRoundTrip(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	// two writer sessions, so the docs end up in two segments
+	for session, ids := range [][]string{{"one", "two", "six"}, {"ten", "red"}} {
+		conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+		writer, err := index.NewIndexWriter(directory, conf)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		for i, id := range ids {
+			n := session*10 + i
+			d := docu.NewDocument()
+			d.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+			d.Add(docu.NewStoredFieldFromInt("int", int32(-n)))
+			d.Add(docu.NewStoredFieldFromLong("long", int64(n)<<40))
+			d.Add(docu.NewStoredFieldFromFloat("float", float32(n)+0.5))
+			d.Add(docu.NewStoredFieldFromString("empty", ""))
+			if n%2 == 0 { // vary the number of stored fields per doc
+				d.Add(docu.NewStoredFieldFromDouble("double", float64(n)/3))
+				d.Add(docu.NewStoredFieldFromBytes("bytes", []byte{byte(n), 0, 0xff}))
+			}
+			err = writer.AddDocument(d.Fields())
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+		}
+		err = writer.Close()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("have 5 docs, got %v", reader.NumDocs()).Assert(reader.NumDocs() == 5)
+	It(t).Should("have 2 segments").Verify(len(reader.Leaves()) == 2)
+
+	expected := map[string]int{"one": 0, "two": 1, "six": 2, "ten": 10, "red": 11}
+	for docID := 0; docID < reader.MaxDoc(); docID++ {
+		doc, err := reader.Document(docID)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		id := doc.Get("id")
+		n, ok := expected[id]
+		It(t).Should("find a known id, got %q", id).Assert(ok)
+		delete(expected, id)
+
+		i, ok := doc.GetInt("int")
+		It(t).Should("get int %v, got %v", -n, i).Verify(ok && i == int32(-n))
+		l, ok := doc.GetLong("long")
+		It(t).Should("get long %v, got %v", int64(n)<<40, l).Verify(ok && l == int64(n)<<40)
+		f, ok := doc.GetFloat("float")
+		It(t).Should("get float %v, got %v", float32(n)+0.5, f).Verify(ok && f == float32(n)+0.5)
+		It(t).Should("format the int as string").Verify(doc.Get("int") == fmt.Sprintf("%v", -n))
+		It(t).Should("keep the empty string field").Verify(doc.GetField("empty") != nil && doc.Get("empty") == "")
+		d, ok := doc.GetDouble("double")
+		b := doc.GetBinaryValue("bytes")
+		if n%2 == 0 {
+			It(t).Should("get double %v, got %v", float64(n)/3, d).Verify(ok && d == float64(n)/3)
+			It(t).Should("get bytes, got %v", b).Verify(bytes.Equal(b, []byte{byte(n), 0, 0xff}))
+		} else {
+			It(t).Should("have no double").Verify(!ok)
+			It(t).Should("have no bytes").Verify(b == nil)
+		}
+		_, ok = doc.GetLong("int")
+		It(t).Should("not convert between numeric types").Verify(!ok)
+	}
+	It(t).Should("see every doc once, missing %v", expected).Verify(len(expected) == 0)
+}
+
+// Accepts a single field and records every value it's handed.
+type singleFieldVisitor struct {
+	docu.StoredFieldVisitorAdapter
+	field   string
+	visited []string
+}
+
+func (v *singleFieldVisitor) NeedsField(fi *model.FieldInfo) (spi.StoredFieldVisitorStatus, error) {
+	if fi.Name == v.field {
+		return spi.STORED_FIELD_VISITOR_STATUS_YES, nil
+	}
+	return spi.STORED_FIELD_VISITOR_STATUS_NO, nil
+}
+
+func (v *singleFieldVisitor) StringField(fi *model.FieldInfo, value string) error {
+	v.visited = append(v.visited, fi.Name)
+	return nil
+}
+
+func (v *singleFieldVisitor) LongField(fi *model.FieldInfo, value int64) error {
+	v.visited = append(v.visited, fmt.Sprintf("%v=%v", fi.Name, value))
+	return nil
+}
+
+func (v *singleFieldVisitor) BinaryField(fi *model.FieldInfo, value []byte) error {
+	v.visited = append(v.visited, fi.Name)
+	return nil
+}
+
+func TestStoredFieldVisitorSkipsFields(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for i, id := range []string{"one", "two", "six"} {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+		d.Add(docu.NewStoredFieldFromBytes("bytes", []byte(strings.Repeat(id, 100))))
+		d.Add(docu.NewStoredFieldFromInt("int", int32(i)))
+		d.Add(docu.NewStoredFieldFromLong("long", int64(i)*1000))
+		d.Add(docu.NewStoredFieldFromDouble("double", float64(i)))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+
+	for docID := 0; docID < reader.MaxDoc(); docID++ {
+		// the long is preceded by fields of every width, which must all be
+		// skipped to land on it
+		visitor := &singleFieldVisitor{field: "long"}
+		err = reader.DocumentWithVisitor(docID, visitor)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		expected := fmt.Sprintf("long=%v", docID*1000)
+		It(t).Should("only decode %v, got %v", expected, visitor.visited).Verify(
+			len(visitor.visited) == 1 && visitor.visited[0] == expected)
+
+		loader := docu.NewDocumentStoredFieldVisitor("id", "double")
+		err = reader.DocumentWithVisitor(docID, loader)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		doc := loader.Document()
+		It(t).Should("load 2 fields, got %v", len(doc.Fields())).Verify(len(doc.Fields()) == 2)
+		It(t).Should("load the id").Verify(doc.Get("id") != "")
+		d, ok := doc.GetDouble("double")
+		It(t).Should("load double %v, got %v", docID, d).Verify(ok && d == float64(docID))
+		It(t).Should("not load bytes").Verify(doc.GetBinaryValue("bytes") == nil)
+	}
+}
+
+func TestPositionsAndOffsets(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	withOffsets := docu.NewFieldTypeFrom(docu.TEXT_FIELD_TYPE_NOT_STORED)
+	withOffsets.SetIndexOptions(model.INDEX_OPT_DOCS_AND_FREQS_AND_POSITIONS_AND_OFFSETS)
+	docsOnly := docu.NewFieldTypeFrom(docu.TEXT_FIELD_TYPE_NOT_STORED)
+	docsOnly.SetIndexOptions(model.INDEX_OPT_DOCS_ONLY)
+
+	// more than a block of docs fills doc blocks, and the terms
+	// repeated in each doc fill several position blocks
+	const numDocs = 200
+	repeats := func(docID int) int {
+		if docID == 0 {
+			return 20
+		}
+		return 1 + docID%3
+	}
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for i := 0; i < numDocs; i++ {
+		d := docu.NewDocument()
+		d.Add(docu.NewFieldFromString("body", strings.Repeat("red blue ", repeats(i))+"green", withOffsets))
+		d.Add(docu.NewTextFieldFromString("title", "hello world hello", docu.STORE_NO))
+		d.Add(docu.NewFieldFromString("tags", "tag tag", docsOnly))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("have 1 segment").Assert(len(reader.Leaves()) == 1)
+	leaf := reader.Leaves()[0].Reader().(index.AtomicReader)
+
+	seek := func(field, term string) model.TermsEnum {
+		termsEnum := leaf.Terms(field).Iterator(nil)
+		ok, err := termsEnum.SeekExact([]byte(term))
+		It(t).Should("find %v:%v (%v)", field, term, err).Assert(ok && err == nil)
+		return termsEnum
+	}
+
+	// "blue" is the 2nd token of each "red blue " run
+	postings, err := seek("body", "blue").DocsAndPositions(nil, nil)
+	It(t).Should("has no error: %v", err).Assert(err == nil && postings != nil)
+	for i := 0; i < numDocs; i++ {
+		doc, err := postings.NextDoc()
+		It(t).Should("be on doc %v, got %v (%v)", i, doc, err).Assert(doc == i && err == nil)
+		freq, _ := postings.Freq()
+		It(t).Should("have freq %v, got %v", repeats(i), freq).Assert(freq == repeats(i))
+		if i >= 10 && i%100 != 0 {
+			continue // leave positions unread, to be skipped block-wise
+		}
+		for j := 0; j < freq; j++ {
+			pos, err := postings.NextPosition()
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+			It(t).Should("doc %v: position %v, got %v", i, 2*j+1, pos).Assert(pos == 2*j+1)
+			start, end := postings.StartOffset(), postings.EndOffset()
+			It(t).Should("doc %v: offsets [%v,%v), got [%v,%v)", i, 9*j+4, 9*j+8, start, end).Assert(
+				start == 9*j+4 && end == 9*j+8)
+		}
+	}
+	doc, err := postings.NextDoc()
+	It(t).Should("be exhausted, got %v (%v)", doc, err).Verify(doc == math.MaxInt32 && err == nil)
+
+	// the offset of the last token accumulates over all runs
+	postings, err = seek("body", "green").DocsAndPositions(nil, nil)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	doc, err = postings.Advance(5)
+	It(t).Should("advance to 5, got %v (%v)", doc, err).Assert(doc == 5 && err == nil)
+	pos, _ := postings.NextPosition()
+	It(t).Should("position %v, got %v", 2*repeats(5), pos).Verify(pos == 2*repeats(5))
+	It(t).Should("start offset %v, got %v", 9*repeats(5), postings.StartOffset()).Verify(
+		postings.StartOffset() == 9*repeats(5))
+
+	// positions without offsets
+	postings, err = seek("title", "hello").DocsAndPositions(nil, nil)
+	It(t).Should("has no error: %v", err).Assert(err == nil && postings != nil)
+	postings.NextDoc()
+	for _, expected := range []int{0, 2} {
+		pos, err := postings.NextPosition()
+		It(t).Should("position %v, got %v (%v)", expected, pos, err).Verify(pos == expected && err == nil)
+		It(t).Should("have no offsets").Verify(postings.StartOffset() == -1 && postings.EndOffset() == -1)
+	}
+
+	// a docs-only field has no positions, but still lists every doc
+	termsEnum := seek("tags", "tag")
+	postings, err = termsEnum.DocsAndPositions(nil, nil)
+	It(t).Should("have no positions (%v)", err).Verify(postings == nil && err == nil)
+	docs, err := termsEnum.Docs(nil, nil)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	count := 0
+	for doc, err = docs.NextDoc(); err == nil && doc != math.MaxInt32; doc, err = docs.NextDoc() {
+		It(t).Should("be on doc %v, got %v", count, doc).Assert(doc == count)
+		count++
+	}
+	It(t).Should("list %v docs, got %v (%v)", numDocs, count, err).Verify(count == numDocs && err == nil)
+}
+
+func TestPhraseQuery(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+
+	directory := newSingleFieldIndex(t, path, "body",
+		"quick brown fox",
+		"quick lazy brown fox",
+		"brown quick fox",
+		"quick red fox jumps")
+	defer directory.Close()
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	searcher := search.NewIndexSearcher(reader)
+
+	phrase := func(slop int, terms ...string) []int {
+		q := search.NewPhraseQuery()
+		for _, term := range terms {
+			q.Add(index.NewTerm("body", term))
+		}
+		q.SetSlop(slop)
+		res, err := searcher.SearchTop(q, 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		var docs []int
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+		}
+		return docs
+	}
+
+	docs := phrase(0, "quick", "brown")
+	It(t).Should("match adjacent terms only, got %v", docs).Verify(
+		fmt.Sprintf("%v", docs) == "[0]")
+	docs = phrase(0, "brown", "fox")
+	It(t).Should("match adjacent terms only, got %v", docs).Verify(
+		fmt.Sprintf("%v", docs) == "[0 1]")
+	docs = phrase(0, "fox", "quick")
+	It(t).Should("match terms in order only, got %v", docs).Verify(len(docs) == 0)
+
+	// one move lets a term in between, two moves swap the terms;
+	// the exact match scores highest
+	docs = phrase(1, "quick", "brown")
+	It(t).Should("match with a term in between, got %v", docs).Verify(
+		fmt.Sprintf("%v", docs) == "[0 1]")
+	docs = phrase(2, "quick", "brown")
+	It(t).Should("match swapped terms, got %v", docs).Verify(
+		len(docs) == 3 && docs[0] == 0)
+
+	docs = phrase(0, "quick", "red", "fox")
+	It(t).Should("match three terms, got %v", docs).Verify(
+		fmt.Sprintf("%v", docs) == "[3]")
+}
+
+func TestBooleanQuery(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+
+	directory := newSingleFieldIndex(t, path, "body",
+		"red green blue",
+		"red green",
+		"red",
+		"green blue",
+		"yellow")
+	defer directory.Close()
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	searcher := search.NewIndexSearcher(reader)
+
+	type clause struct {
+		term  string
+		occur search.Occur
+	}
+	boolean := func(minShouldMatch int, clauses ...clause) *search.BooleanQuery {
+		q := search.NewBooleanQuery()
+		for _, c := range clauses {
+			q.Add(search.NewTermQuery(index.NewTerm("body", c.term)), c.occur)
+		}
+		q.SetMinimumNumberShouldMatch(minShouldMatch)
+		return q
+	}
+	matches := func(q search.Query) (string, []*search.ScoreDoc) {
+		res, err := searcher.SearchTop(q, 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		var docs []int
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+		}
+		sort.Ints(docs)
+		return fmt.Sprintf("%v", docs), res.ScoreDocs
+	}
+
+	for _, test := range []struct {
+		q        *search.BooleanQuery
+		expected string
+	}{
+		{boolean(0, clause{"red", search.MUST}, clause{"green", search.MUST}), "[0 1]"},
+		{boolean(0, clause{"red", search.SHOULD}, clause{"blue", search.SHOULD}), "[0 1 2 3]"},
+		{boolean(0, clause{"red", search.MUST}, clause{"blue", search.MUST_NOT}), "[1 2]"},
+		{boolean(0, clause{"red", search.SHOULD}, clause{"green", search.SHOULD},
+			clause{"blue", search.MUST_NOT}), "[1 2]"},
+		{boolean(0, clause{"green", search.MUST}, clause{"red", search.SHOULD}), "[0 1 3]"},
+		{boolean(1, clause{"red", search.MUST}, clause{"green", search.SHOULD},
+			clause{"blue", search.SHOULD}), "[0 1]"},
+		{boolean(2, clause{"red", search.SHOULD}, clause{"green", search.SHOULD},
+			clause{"blue", search.SHOULD}), "[0 1 3]"},
+		{boolean(3, clause{"red", search.SHOULD}, clause{"green", search.SHOULD}), "[]"},
+		{boolean(0, clause{"yellow", search.SHOULD}), "[4]"},
+		{boolean(0, clause{"blue", search.MUST_NOT}), "[]"},
+	} {
+		docs, hits := matches(test.q)
+		It(t).Should("match %v for %v, got %v", test.expected, test.q, docs).Verify(docs == test.expected)
+		for _, hit := range hits {
+			explain, err := searcher.Explain(test.q, hit.Doc)
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+			It(t).Should("explain %v in %v as a match with score %v, got:\n%v",
+				test.q, hit.Doc, hit.Score, explain).Verify(
+				explain.IsMatch() && isSimilar(hit.Score, explain.Value(), 0.001))
+		}
+	}
+
+	// optional clauses add to the score of required ones
+	_, hits := matches(boolean(0, clause{"green", search.MUST}, clause{"red", search.SHOULD}))
+	It(t).Should("rank doc 3 last, got %v", hits).Verify(hits[2].Doc == 3)
+
+	explain, err := searcher.Explain(boolean(0,
+		clause{"red", search.MUST}, clause{"blue", search.MUST_NOT}), 0)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("not explain a prohibited doc as a match").Verify(!explain.IsMatch())
+}
+
+func TestBM25Similarity(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+
+	corpus := []string{
+		"apple banana",
+		"apple apple cherry",
+		"apple",
+		"banana cherry date",
+	}
+	directory := newSingleFieldIndex(t, path, "body", corpus...)
+	defer directory.Close()
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	searcher := search.NewIndexSearcher(reader)
+
+	search1 := func(term string) []*search.ScoreDoc {
+		res, err := searcher.SearchTop(search.NewTermQuery(index.NewTerm("body", term)), 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		return res.ScoreDocs
+	}
+
+	// same doc, same freq: the rarer term scores higher
+	date, banana := search1("date"), search1("banana")
+	It(t).Should("find date in doc 3, got %v", date).Assert(len(date) == 1 && date[0].Doc == 3)
+	It(t).Should("find banana in 2 docs, got %v", banana).Assert(len(banana) == 2)
+	for _, hit := range banana {
+		if hit.Doc == 3 {
+			It(t).Should("score rarer term higher (%v vs %v)", date[0].Score, hit.Score).Verify(
+				date[0].Score > hit.Score)
+		}
+	}
+
+	// idf * tf * (k1 + 1) / (tf + k1 * (1 - b + b * dl / avgdl)), where
+	// dl is the field length as it survives the lossy norm encoding
+	k1, b := 1.2, 0.75
+	avgdl := 9.0 / 4 // 9 tokens in 4 docs
+	idf := math.Log(1 + (4-3+0.5)/(3+0.5))
+	expected := map[int]float64{}
+	for doc, tf := range map[int]float64{0: 1, 1: 2, 2: 1} {
+		length := len(strings.Fields(corpus[doc]))
+		norm := util.FloatToByte315(float32(1 / math.Sqrt(float64(length))))
+		dl := float64(search.BM25_NORM_TABLE[byte(norm)])
+		expected[doc] = idf * tf * (k1 + 1) / (tf + k1*(1-b+b*dl/avgdl))
+	}
+	hits := search1("apple")
+	It(t).Should("find apple in 3 docs, got %v", hits).Assert(len(hits) == 3)
+	for i, hit := range hits {
+		It(t).Should("score doc %v as %v, got %v", hit.Doc, expected[hit.Doc], hit.Score).Verify(
+			isSimilar(hit.Score, float32(expected[hit.Doc]), 0.0001))
+		if i > 0 {
+			It(t).Should("rank by score, got %v", hits).Verify(
+				expected[hits[i-1].Doc] >= expected[hit.Doc])
+		}
+	}
+	// the shortest field beats the repeated term
+	It(t).Should("rank docs 2, 1, 0, got %v", hits).Verify(
+		hits[0].Doc == 2 && hits[1].Doc == 1 && hits[2].Doc == 0)
+}
+
+func TestNormValues(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	// two writer sessions, so the docs end up in two segments
+	corpus := [][]string{{"red", "red green blue gray", "red green"},
+		{"red green blue gray pink cyan teal navy plum", "red green blue"}}
+	for _, values := range corpus {
+		conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+		writer, err := index.NewIndexWriter(directory, conf)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		for _, v := range values {
+			d := docu.NewDocument()
+			d.Add(docu.NewTextFieldFromString("body", v, docu.STORE_NO))
+			d.Add(docu.NewFieldFromString("id", v, docu.STRING_FIELD_TYPE_STORED))
+			err = writer.AddDocument(d.Fields())
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+		}
+		err = writer.Close()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+
+	leaves := reader.Context().Leaves()
+	It(t).Should("have a segment per session").Assert(len(leaves) == len(corpus))
+	for i, leaf := range leaves {
+		r := leaf.Reader().(index.AtomicReader)
+		norms, err := r.NormValues("body")
+		It(t).Should("has no error: %v", err).Assert(err == nil && norms != nil)
+		for doc, v := range corpus[i] {
+			length := len(strings.Fields(v))
+			expected := int64(util.FloatToByte315(float32(1 / math.Sqrt(float64(length)))))
+			It(t).Should("read norm %v of %q, got %v", expected, v, norms(doc)).Verify(
+				norms(doc) == expected)
+		}
+
+		norms, err = r.NormValues("id")
+		It(t).Should("omit norms of id, got %v", err).Verify(err == nil && norms == nil)
+		norms, err = r.NormValues("missing")
+		It(t).Should("have no norms for missing field, got %v", err).Verify(err == nil && norms == nil)
+	}
+}
+
+func TestAnalyzers(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	sentence := "The Quick brown fox, jumped over the lazy dog's back!"
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	d := docu.NewDocument()
+	d.Add(docu.NewTextFieldFromString("standard", sentence, docu.STORE_NO))
+	err = writer.AddDocument(d.Fields())
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	d = docu.NewDocument()
+	d.Add(docu.NewTextFieldFromString("whitespace", sentence, docu.STORE_NO))
+	err = writer.AddDocumentWithAnalyzer(d.Fields(), acore.NewWhitespaceAnalyzer())
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	leaf := reader.Leaves()[0].Reader().(index.AtomicReader)
+	verifyTerms := func(field string, expected, unexpected []string) {
+		seekExact := func(term string) (bool, error) {
+			return leaf.Terms(field).Iterator(nil).SeekExact([]byte(term))
+		}
+		for _, term := range expected {
+			ok, err := seekExact(term)
+			It(t).Should("index %v:%v (%v)", field, term, err).Verify(ok && err == nil)
+		}
+		for _, term := range unexpected {
+			ok, err := seekExact(term)
+			It(t).Should("not index %v:%v (%v)", field, term, err).Verify(!ok && err == nil)
+		}
+	}
+
+	// lowercased, split at punctuation, without stop words
+	verifyTerms("standard",
+		[]string{"quick", "brown", "fox", "jumped", "over", "lazy", "dog's", "back"},
+		[]string{"the", "The", "Quick", "fox,", "back!"})
+	// as is, split at whitespace only
+	verifyTerms("whitespace",
+		[]string{"The", "Quick", "brown", "fox,", "jumped", "over", "the", "lazy", "dog's", "back!"},
+		[]string{"quick", "fox", "back", "fox, jumped"})
+}
+
+func TestPerFieldAnalyzerWrapper(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	analyzer := miscellaneous.NewPerFieldAnalyzerWrapper(std.NewStandardAnalyzer(),
+		map[string]analysis.Analyzer{"special": acore.NewWhitespaceAnalyzer()})
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, analyzer)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	text := "Qwerty Uiop, Asdf"
+	d := docu.NewDocument()
+	d.Add(docu.NewTextFieldFromString("field", text, docu.STORE_NO))
+	d.Add(docu.NewTextFieldFromString("special", text, docu.STORE_NO))
+	err = writer.AddDocument(d.Fields())
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	leaf := reader.Leaves()[0].Reader().(index.AtomicReader)
+	for _, v := range []struct {
+		field, term string
+		indexed     bool
+	}{
+		{"field", "qwerty", true},
+		{"field", "uiop", true},
+		{"field", "Qwerty", false},
+		{"special", "Qwerty", true},
+		{"special", "Uiop,", true},
+		{"special", "qwerty", false},
+		{"special", "uiop", false},
+	} {
+		ok, err := leaf.Terms(v.field).Iterator(nil).SeekExact([]byte(v.term))
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		It(t).Should("index %v:%v: %v", v.field, v.term, v.indexed).Verify(ok == v.indexed)
+	}
+}
+
+func TestMatchAllAndConstantScoreQuery(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	newDoc := func(id, body string) []model.IndexableField {
+		d := docu.NewDocument()
+		d.Add(docu.NewFieldFromString("id", id, docu.STRING_FIELD_TYPE_STORED))
+		d.Add(docu.NewTextFieldFromString("body", body, docu.STORE_NO))
+		return d.Fields()
+	}
+	for i, body := range []string{"red green", "red", "green blue", "red red blue"} {
+		err = writer.AddDocument(newDoc(fmt.Sprintf("%v", i), body))
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	searcher := search.NewIndexSearcher(reader)
+
+	matchAll := search.NewMatchAllDocsQuery()
+	res, err := searcher.SearchTop(matchAll, 10)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("match NumDocs %v, got %v", reader.NumDocs(), res.TotalHits).Verify(
+		res.TotalHits == reader.NumDocs())
+
+	for _, boost := range []float32{1, 2.5} {
+		q := search.NewConstantScoreQuery(search.NewTermQuery(index.NewTerm("body", "red")))
+		q.SetBoost(boost)
+		res, err = searcher.SearchTop(q, 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		var docs []int
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+			// BM25 doesn't normalize queries
+			It(t).Should("score %v as %v, got %v", hit.Doc, boost, hit.Score).Verify(hit.Score == boost)
+			explain, err := searcher.Explain(q, hit.Doc)
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+			It(t).Should("explain %v as %v, got:\n%v", hit.Doc, hit.Score, explain).Verify(
+				explain.IsMatch() && explain.Value() == hit.Score)
+		}
+		sort.Ints(docs)
+		It(t).Should("match [0 1 3], got %v", docs).Verify(fmt.Sprintf("%v", docs) == "[0 1 3]")
+	}
+
+	// the boost shows relative to other clauses
+	q := search.NewBooleanQuery()
+	boosted := search.NewConstantScoreQuery(search.NewTermQuery(index.NewTerm("body", "red")))
+	boosted.SetBoost(3)
+	q.Add(boosted, search.SHOULD)
+	q.Add(search.NewConstantScoreQuery(search.NewTermQuery(index.NewTerm("body", "blue"))), search.SHOULD)
+	res, err = searcher.SearchTop(q, 10)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	scores := make(map[int]float32)
+	for _, hit := range res.ScoreDocs {
+		scores[hit.Doc] = hit.Score
+	}
+	It(t).Should("score red 3 times blue, got %v", scores).Verify(
+		isSimilar(scores[0], 3*scores[2], 0.0001))
+}
+
+func TestLRUQueryCache(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for _, body := range []string{"red green", "red", "green blue", "red red blue"} {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("body", body, docu.STORE_NO))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	searcher := search.NewIndexSearcher(reader)
+	cache := search.NewLRUQueryCache(10, 1<<20)
+	searcher.SetQueryCache(cache)
+	searcher.SetQueryCachingPolicy(search.QUERY_CACHING_POLICY_ALWAYS_CACHE)
+
+	var hits []string
+	for i := 0; i < 2; i++ {
+		q := search.NewConstantScoreQuery(search.NewTermQuery(index.NewTerm("body", "red")))
+		res, err := searcher.SearchTop(q, 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		var docs []int
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+		}
+		sort.Ints(docs)
+		hits = append(hits, fmt.Sprintf("%v", docs))
+	}
+	It(t).Should("match [0 1 3] twice, got %v", hits).Verify(
+		hits[0] == "[0 1 3]" && hits[1] == hits[0])
+	It(t).Should("miss then hit, got %v misses and %v hits", cache.MissCount(), cache.HitCount()).Verify(
+		cache.MissCount() == 1 && cache.HitCount() == 1)
+	It(t).Should("cache 1 doc id set, got %v", cache.CacheSize()).Verify(cache.CacheSize() == 1)
+
+	err = reader.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("evict entries of closed reader, got %v", cache.CacheSize()).Verify(
+		cache.CacheSize() == 0 && cache.EvictionCount() == 1)
+}
+
+func TestLRUQueryCacheConcurrentScorers(t *testing.T) {
+	directory := store.NewRAMDirectory()
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetMaxBufferedDocs(2)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for _, body := range []string{"red green", "red", "green blue", "red red blue"} {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("body", body, docu.STORE_NO))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	searcher := search.NewIndexSearcher(reader)
+	cache := search.NewLRUQueryCache(10, 1<<20)
+	q := search.NewTermQuery(index.NewTerm("body", "red"))
+	w, err := searcher.CreateNormalizedWeight(q)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	w = cache.DoCache(q, w, search.QUERY_CACHING_POLICY_ALWAYS_CACHE)
+
+	// one weight shared by routines scoring the same segments
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			for _, leaf := range reader.Leaves() {
+				if _, err := w.Scorer(leaf, nil); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		err = <-errs
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	It(t).Should("cache 1 doc id set per segment, got %v", cache.CacheSize()).Verify(
+		cache.CacheSize() == int64(len(reader.Leaves())))
+
+	err = reader.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("evict entries of closed reader, got %v", cache.CacheSize()).Verify(
+		cache.CacheSize() == 0)
+}
+
+func TestNumericRangeQuery(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetMaxBufferedDocs(2) // spread the doc values over several segments
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for i, price := range []int64{5, 10, 25, 50, 75, -1} {
+		d := docu.NewDocument()
+		d.Add(docu.NewFieldFromString("id", fmt.Sprintf("%v", i), docu.STRING_FIELD_TYPE_STORED))
+		if price >= 0 { // the last doc has no price
+			d.Add(docu.NewNumericDocValuesField("price", price))
+		}
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("flush 3 segments, got %v", len(reader.Leaves())).Verify(len(reader.Leaves()) == 3)
+	searcher := search.NewIndexSearcher(reader)
+
+	for _, c := range []struct {
+		min, max                   int64
+		minInclusive, maxInclusive bool
+		expected                   string
+	}{
+		{10, 50, true, true, "[1 2 3]"},
+		{10, 50, false, false, "[2]"},
+		{50, 1000, false, true, "[4]"},
+		{math.MinInt64, 5, true, true, "[0 5]"}, // a missing value reads as 0
+		{60, 70, true, true, "[]"},
+	} {
+		q := search.NewNumericRangeQuery("price", c.min, c.max, c.minInclusive, c.maxInclusive)
+		res, err := searcher.SearchTop(q, 10)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		docs := []int{}
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+		}
+		sort.Ints(docs)
+		It(t).Should("match %v for %v, got %v", c.expected, q, docs).Verify(
+			fmt.Sprintf("%v", docs) == c.expected && res.TotalHits == len(docs))
+	}
+}
+
+func TestSearchSort(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetMaxBufferedDocs(3) // 2 segments, so values are compared across segments
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	for i, body := range []string{"red green", "red", "green blue", "red red blue", "blue", "green"} {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("body", body, docu.STORE_NO))
+		d.Add(docu.NewNumericDocValuesField("price", []int64{30, 10, 20, 10, 30, 10}[i]))
+		d.Add(docu.NewSortedDocValuesField("name", []byte([]string{"b", "c", "a", "a", "a", "b"}[i])))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("flush 2 segments, got %v", len(reader.Leaves())).Verify(len(reader.Leaves()) == 2)
+	searcher := search.NewIndexSearcher(reader)
+	q := search.NewTermQuery(index.NewTerm("body", "red"))
+
+	top, err := searcher.SearchTop(q, 10)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	byScore, err := searcher.SearchSort(q, nil, 10, search.SORT_RELEVANCE)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("find %v hits, got %v", top.TotalHits, byScore.TotalHits).Assert(
+		byScore.TotalHits == top.TotalHits && len(byScore.ScoreDocs) == len(top.ScoreDocs))
+	for i, hit := range byScore.ScoreDocs {
+		It(t).Should("rank %v as %v, got %v", i, top.ScoreDocs[i], hit).Verify(
+			hit.Doc == top.ScoreDocs[i].Doc && hit.Score == top.ScoreDocs[i].Score &&
+				hit.Fields[0] == hit.Score)
+	}
+
+	all := search.NewMatchAllDocsQuery()
+	price := search.NewSortField("price", search.SORT_FIELD_LONG, false)
+	name := search.NewSortField("name", search.SORT_FIELD_STRING, false)
+	for _, c := range []struct {
+		sort     *search.Sort
+		expected string
+	}{
+		{search.NewSort(price), "[1 3 5 2 0 4]"},
+		{search.NewSort(price, name), "[3 5 1 2 4 0]"},
+		{search.NewSort(search.NewSortField("price", search.SORT_FIELD_LONG, true),
+			search.NewSortField("name", search.SORT_FIELD_STRING, true)), "[0 4 2 1 5 3]"},
+		{search.NewSort(name, search.NewSortField("", search.SORT_FIELD_DOC, true)), "[4 3 2 5 0 1]"},
+		{search.NewSort(search.NewSortField("", search.SORT_FIELD_DOC, true)), "[5 4 3 2 1 0]"},
+	} {
+		res, err := searcher.SearchSort(all, nil, 10, c.sort)
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		var docs []int
+		for _, hit := range res.ScoreDocs {
+			docs = append(docs, hit.Doc)
+		}
+		It(t).Should("sort by %v as %v, got %v", c.sort, c.expected, docs).Verify(
+			fmt.Sprintf("%v", docs) == c.expected)
+	}
+
+	// only the top hits are kept, along with their sort values
+	res, err := searcher.SearchSort(all, nil, 2, search.NewSort(price, name))
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("count 6 hits, got %v", res.TotalHits).Verify(res.TotalHits == 6)
+	var hits []string
+	for _, hit := range res.ScoreDocs {
+		hits = append(hits, fmt.Sprintf("%v:%v/%s", hit.Doc, hit.Fields[0], hit.Fields[1]))
+	}
+	It(t).Should("keep [3:10/a 5:10/b], got %v", hits).Verify(
+		fmt.Sprintf("%v", hits) == "[3:10/a 5:10/b]")
+}
+
+func TestNoMerges(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetMergePolicy(index.NO_MERGE_POLICY)
+	conf.SetMergeScheduler(index.NO_MERGE_SCHEDULER)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	// one segment per commit, far more than any policy would leave
+	const numSegments = 25
+	for i := 0; i < numSegments; i++ {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("id", fmt.Sprintf("doc%v", i), docu.STORE_YES))
+		err = writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		err = writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("keep all %v segments (got %v)", numSegments, len(reader.Leaves())).Verify(
+		len(reader.Leaves()) == numSegments)
+	It(t).Should("keep all docs").Verify(reader.NumDocs() == numSegments)
+}
+
+func TestIndexWriterConfig(t *testing.T) {
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	_, ok := conf.MergePolicy().(*index.TieredMergePolicy)
+	It(t).Should("default to TieredMergePolicy: %v", conf.MergePolicy()).Verify(ok)
+	_, ok = conf.MergeScheduler().(*index.ConcurrentMergeScheduler)
+	It(t).Should("default to ConcurrentMergeScheduler: %v", conf.MergeScheduler()).Verify(ok)
+	_, ok = conf.IndexDeletionPolicy().(index.KeepOnlyLastCommitDeletionPolicy)
+	It(t).Should("default to KeepOnlyLastCommitDeletionPolicy").Verify(ok)
+	It(t).Should("default to CREATE_OR_APPEND").Verify(conf.OpenMode() == index.OPEN_MODE_CREATE_OR_APPEND)
+	It(t).Should("not flush by doc count").Verify(conf.MaxBufferedDocs() == index.DISABLE_AUTO_FLUSH)
+	It(t).Should("flush by RAM usage").Verify(conf.RAMBufferSizeMB() == index.DEFAULT_RAM_BUFFER_SIZE_MB)
+
+	conf.SetOpenMode(index.OPEN_MODE_CREATE).
+		SetMaxBufferedDocs(10).
+		SetRAMBufferSizeMB(index.DISABLE_AUTO_FLUSH).
+		SetMergePolicy(index.NO_MERGE_POLICY).
+		SetMergeScheduler(index.NO_MERGE_SCHEDULER)
+	It(t).Should("chain all setters").Verify(conf.OpenMode() == index.OPEN_MODE_CREATE &&
+		conf.MaxBufferedDocs() == 10 && conf.RAMBufferSizeMB() == index.DISABLE_AUTO_FLUSH &&
+		conf.MergePolicy() == index.NO_MERGE_POLICY && conf.MergeScheduler() == index.NO_MERGE_SCHEDULER)
+
+	d1, d2 := store.NewRAMDirectory(), store.NewRAMDirectory()
+	defer d1.Close()
+	defer d2.Close()
+	writer, err := index.NewIndexWriter(d1, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+	_, err = index.NewIndexWriter(d2, conf)
+	It(t).Should("refuse to share the config with another writer").Verify(err != nil)
+}
+
+func TestOpenMode(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	// adds numDocs docs with a writer opened in mode, and returns the
+	// number of docs in the index afterwards
+	indexDocs := func(mode index.OpenMode, numDocs int) (int, error) {
+		conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+		writer, err := index.NewIndexWriter(directory, conf.SetOpenMode(mode))
+		if err != nil {
+			return 0, err
+		}
+		for i := 0; i < numDocs; i++ {
+			d := docu.NewDocument()
+			d.Add(docu.NewTextFieldFromString("id", fmt.Sprintf("doc%v", i), docu.STORE_YES))
+			if err = writer.AddDocument(d.Fields()); err != nil {
+				return 0, err
+			}
+		}
+		if err = writer.Close(); err != nil {
+			return 0, err
+		}
+		reader, err := index.OpenDirectoryReader(directory)
+		if err != nil {
+			return 0, err
+		}
+		defer reader.Close()
+		return reader.NumDocs(), nil
+	}
+
+	_, err = indexDocs(index.OPEN_MODE_APPEND, 1)
+	It(t).Should("fail to append to an empty directory").Verify(err != nil)
+
+	numDocs, err := indexDocs(index.OPEN_MODE_CREATE_OR_APPEND, 3)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("create the index, got %v docs", numDocs).Verify(numDocs == 3)
+	oldFiles, err := directory.ListAll()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+
+	numDocs, err = indexDocs(index.OPEN_MODE_APPEND, 2)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("append to the index, got %v docs", numDocs).Verify(numDocs == 5)
+
+	numDocs, err = indexDocs(index.OPEN_MODE_CREATE_OR_APPEND, 1)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("append to the index, got %v docs", numDocs).Verify(numDocs == 6)
+
+	numDocs, err = indexDocs(index.OPEN_MODE_CREATE, 1)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("overwrite the index, got %v docs", numDocs).Verify(numDocs == 1)
+	for _, name := range oldFiles {
+		if name == index.WRITE_LOCK_NAME || name == index.INDEX_FILENAME_SEGMENTS_GEN {
+			continue // rewritten rather than deleted
+		}
+		It(t).Should("delete old file %v", name).Verify(!directory.FileExists(name))
+	}
+
+	// the write lock is held until the writer is closed
+	writer, err := index.NewIndexWriter(directory,
+		index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer()))
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	_, err = index.NewIndexWriter(directory,
+		index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer()))
+	It(t).Should("fail to obtain the write lock").Verify(err != nil)
+	err = writer.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+}
+
+func TestOpenDirectoryReaderAt(t *testing.T) {
+	// RAMInputStream can't be cloned yet, which compound files need
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	sdp := index.NewSnapshotDeletionPolicy(index.DEFAULT_DELETION_POLICY)
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetIndexDeletionPolicy(sdp).
+		SetMergePolicy(index.NO_MERGE_POLICY)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+
+	// one segment of numDocs docs per commit
+	addAndCommit := func(numDocs int) {
+		for i := 0; i < numDocs; i++ {
+			d := docu.NewDocument()
+			d.Add(docu.NewTextFieldFromString("id", fmt.Sprintf("doc%v", i), docu.STORE_YES))
+			err := writer.AddDocument(d.Fields())
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+		}
+		err := writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	addAndCommit(3)
+	snapshot, err := sdp.Snapshot()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	addAndCommit(2)
+
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+	It(t).Should("have two segments, got %v", len(reader.Leaves())).Assert(len(reader.Leaves()) == 2)
+	var numDocs int
+	for _, leaf := range reader.Leaves() {
+		numDocs += leaf.Reader().NumDocs()
+	}
+	It(t).Should("sum up leaves' docs, got %v", numDocs).Verify(numDocs == 5 &&
+		reader.NumDocs() == 5 && reader.MaxDoc() == 5)
+
+	// the snapshot is still readable as of its commit
+	old, err := index.OpenDirectoryReaderAt(snapshot)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer old.Close()
+	It(t).Should("see only the first segment").Verify(len(old.Leaves()) == 1 && old.NumDocs() == 3)
+}
+
+func TestOpenDirectoryReaderAtKeepsCommitFiles(t *testing.T) {
+	// RAMInputStream can't be cloned yet, which compound files need
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	// snapshots only hand out the commit; KeepOnlyLastCommit deletes it
+	sdp := index.NewSnapshotDeletionPolicy(index.DEFAULT_DELETION_POLICY)
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	conf.SetIndexDeletionPolicy(sdp).
+		SetMergePolicy(index.NO_MERGE_POLICY)
+	writer, err := index.NewIndexWriter(directory, conf)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+
+	addAndCommit := func(numDocs int) {
+		for i := 0; i < numDocs; i++ {
+			d := docu.NewDocument()
+			d.Add(docu.NewTextFieldFromString("id", fmt.Sprintf("doc%v", i), docu.STORE_YES))
+			err := writer.AddDocument(d.Fields())
+			It(t).Should("has no error: %v", err).Assert(err == nil)
+		}
+		err := writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	addAndCommit(3)
+	commit, err := sdp.Snapshot()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	old, err := index.OpenDirectoryReaderAt(commit)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	err = sdp.Release(commit)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	addAndCommit(2)
+
+	// the policy deleted the commit, but the reader still holds its files
+	It(t).Should("delete the released commit").Verify(commit.IsDeleted())
+	for _, file := range commit.FileNames() {
+		It(t).Should("keep %v while the reader is open", file).Verify(directory.FileExists(file))
+	}
+	It(t).Should("see the first commit, got %v docs", old.NumDocs()).Verify(old.NumDocs() == 3)
+
+	err = old.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("delete %v once the reader is closed", commit.SegmentsFileName()).
+		Verify(!directory.FileExists(commit.SegmentsFileName()))
+	_, err = index.OpenDirectoryReaderAt(commit)
+	It(t).Should("fail to open the commit once its files are gone").Verify(err != nil)
+}
+
+func TestOpenDirectoryReaderIfChanged(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf.SetMergePolicy(index.NO_MERGE_POLICY))
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+
+	addAndCommit := func(id string) {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+		err := writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+		err = writer.Commit()
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	addAndCommit("doc0")
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	current, err := reader.IsCurrent()
+	It(t).Should("be current (%v)", err).Verify(current && err == nil)
+	changed, err := index.OpenDirectoryReaderIfChanged(reader)
+	It(t).Should("not reopen an unchanged index (%v)", err).Verify(changed == nil && err == nil)
+
+	addAndCommit("doc1")
+	current, err = reader.IsCurrent()
+	It(t).Should("not be current after a commit (%v)", err).Verify(!current && err == nil)
+	changed, err = index.OpenDirectoryReaderIfChanged(reader)
+	It(t).Should("has no error: %v", err).Assert(err == nil && changed != nil)
+	defer changed.Close()
+	It(t).Should("see both segments").Assert(len(changed.Leaves()) == 2 && changed.NumDocs() == 2)
+	old := reader.Leaves()[0].Reader()
+	It(t).Should("share the unchanged segment's reader").Verify(changed.Leaves()[0].Reader() == old)
+
+	// the shared reader outlives the old reader
+	err = reader.Close()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	doc, err := changed.Document(0)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	It(t).Should("read the shared segment").Verify(doc.Get("id") == "doc0")
+}
+
+func TestOpenDirectoryReaderIfChangedPreparedCommit(t *testing.T) {
+	path, err := ioutil.TempDir("", "golucene")
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer os.RemoveAll(path)
+	directory, err := store.OpenFSDirectory(path)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer directory.Close()
+
+	conf := index.NewIndexWriterConfig(util.VERSION_LATEST, std.NewStandardAnalyzer())
+	writer, err := index.NewIndexWriter(directory, conf.SetMergePolicy(index.NO_MERGE_POLICY))
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer writer.Close()
+
+	addDoc := func(id string) {
+		d := docu.NewDocument()
+		d.Add(docu.NewTextFieldFromString("id", id, docu.STORE_YES))
+		err := writer.AddDocument(d.Fields())
+		It(t).Should("has no error: %v", err).Assert(err == nil)
+	}
+
+	addDoc("doc0")
+	err = writer.Commit()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	reader, err := index.OpenDirectoryReader(directory)
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	defer reader.Close()
+
+	// the prepared segments_N is in the directory but not committed
+	addDoc("doc1")
+	err = writer.PrepareCommit()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	current, err := reader.IsCurrent()
+	It(t).Should("still be current (%v)", err).Verify(current && err == nil)
+	changed, err := index.OpenDirectoryReaderIfChanged(reader)
+	It(t).Should("not reopen on a prepared commit (%v)", err).Verify(changed == nil && err == nil)
+
+	err = writer.Commit()
+	It(t).Should("has no error: %v", err).Assert(err == nil)
+	changed, err = index.OpenDirectoryReaderIfChanged(reader)
+	It(t).Should("has no error: %v", err).Assert(err == nil && changed != nil)
+	defer changed.Close()
+	It(t).Should("see the finished commit").Verify(changed.NumDocs() == 2)
+}
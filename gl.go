@@ -15,7 +15,7 @@ import (
 func main() {
 	util.SetDefaultInfoStream(util.NewPrintStreamInfoStream(os.Stdout))
 	index.DefaultSimilarity = func() index.Similarity {
-		return search.NewDefaultSimilarity()
+		return search.NewBM25Similarity()
 	}
 
 	directory, _ := store.OpenFSDirectory("test_index")
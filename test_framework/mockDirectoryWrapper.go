@@ -1278,6 +1278,11 @@ func (w *MockIndexInputWrapper) ReadVLong() (int64, error) {
 	return w.delegate.ReadVLong()
 }
 
+func (w *MockIndexInputWrapper) ReadZLong() (int64, error) {
+	w.ensureOpen()
+	return w.delegate.ReadZLong()
+}
+
 func (w *MockIndexInputWrapper) String() string {
 	return fmt.Sprintf("MockIndexInputWrapper(%v)", w.delegate)
 }
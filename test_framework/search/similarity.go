@@ -14,7 +14,7 @@ import (
 var allSims = func() []Similarity {
 	var ans []Similarity
 	ans = append(ans, NewDefaultSimilarity())
-	// ans = append(ans, newBM25Similarity())
+	ans = append(ans, NewBM25Similarity())
 	// for _, basicModel := range BASIC_MODELS {
 	// 	for _, afterEffect := range AFTER_EFFECTS {
 	// 		for _, normalization := range NORMALIZATIONS {
@@ -82,7 +82,9 @@ func newRandomIndexWriteConfig(r *rand.Rand, v util.Version, a analysis.Analyzer
 		maxRoutineCount := NextInt(Random(), 1, 4)
 		maxMergeCount := NextInt(Random(), maxRoutineCount, maxRoutineCount+4)
 		cms := index.NewConcurrentMergeScheduler()
-		cms.SetMaxMergesAndRoutines(maxMergeCount, maxRoutineCount)
+		if err := cms.SetMaxMergesAndRoutines(maxMergeCount, maxRoutineCount); err != nil {
+			panic(err)
+		}
 		c.SetMergeScheduler(cms)
 	}
 	if r.Intn(2) == 0 {
@@ -173,9 +175,9 @@ func newAlcoholicMergePolicy(r *rand.Rand /*, tz TimeZone*/) *ti.AlcoholicMergeP
 func newLogMergePolicy(r *rand.Rand) *index.LogMergePolicy {
 	var logmp *index.LogMergePolicy
 	if r.Intn(2) == 0 {
-		logmp = index.NewLogDocMergePolicy()
+		logmp = index.NewLogDocMergePolicy().LogMergePolicy
 	} else {
-		logmp = index.NewLogByteSizeMergePolicy()
+		logmp = index.NewLogByteSizeMergePolicy().LogMergePolicy
 	}
 	if Rarely(r) {
 		log.Println("Use crazy value for merge factor")
@@ -303,9 +305,11 @@ func NewField(r *rand.Rand, name, value string, typ *docu.FieldType) *docu.Field
 func newDirectoryImpl(random *rand.Rand, clazzName string) store.Directory {
 	if clazzName == "random" {
 		if Rarely(random) {
-			switch random.Intn(1) {
+			switch random.Intn(2) {
 			case 0:
 				clazzName = "SimpleFSDirectory"
+			case 1:
+				clazzName = "NIOFSDirectory"
 			}
 		} else {
 			clazzName = "RAMDirectory"
@@ -325,6 +329,12 @@ func newDirectoryImpl(random *rand.Rand, clazzName string) store.Directory {
 				panic(err)
 			}
 			return d
+		case "NIOFSDirectory":
+			d, err := store.NewNIOFSDirectory(path)
+			if err != nil {
+				panic(err)
+			}
+			return d
 		}
 		panic(fmt.Sprintf("not supported yet: %v", clazzName))
 	}